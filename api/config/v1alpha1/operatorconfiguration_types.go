@@ -0,0 +1,297 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OperatorConfigurationKind is the kind of the operator configuration file.
+const OperatorConfigurationKind = "OperatorConfiguration"
+
+//+kubebuilder:object:root=true
+
+// OperatorConfiguration configures the operator. Anything left out of the file
+// keeps the value of the equivalent command line flag.
+type OperatorConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Metrics configures the metrics endpoint.
+	// +optional
+	Metrics MetricsConfiguration `json:"metrics,omitempty"`
+	// Health configures the health and readiness endpoints.
+	// +optional
+	Health HealthConfiguration `json:"health,omitempty"`
+	// LeaderElection configures how replicas of the operator elect a leader.
+	// +optional
+	LeaderElection LeaderElectionConfiguration `json:"leaderElection,omitempty"`
+	// GracefulShutdownTimeout is how long in-flight reconciles are given to
+	// finish on SIGTERM.
+	// +optional
+	GracefulShutdownTimeout metav1.Duration `json:"gracefulShutdownTimeout,omitempty"`
+	// WatchNamespaces restricts the operator to the objects in these
+	// namespaces, so that each tenant can run its own operator with RBAC over
+	// only its namespaces. Every namespace is watched when it is empty.
+	// MicrovmHosts are cluster scoped and are always watched, and the default
+	// TLS secret must be in one of the namespaces. Changing it requires a
+	// restart.
+	// +optional
+	WatchNamespaces []string `json:"watchNamespaces,omitempty"`
+	// Controllers configures each controller.
+	// +optional
+	Controllers ControllersConfiguration `json:"controllers,omitempty"`
+	// Flintlock configures how flintlock hosts are called.
+	// +optional
+	Flintlock FlintlockConfiguration `json:"flintlock,omitempty"`
+	// Logging configures what the operator logs.
+	// +optional
+	Logging LoggingConfiguration `json:"logging,omitempty"`
+	// Tracing configures the export of OpenTelemetry spans.
+	// +optional
+	Tracing TracingConfiguration `json:"tracing,omitempty"`
+	// PhoneHome configures the server guests call back to once cloud-init
+	// has finished. Changing it requires a restart.
+	// +optional
+	PhoneHome PhoneHomeConfiguration `json:"phoneHome,omitempty"`
+	// Deletion configures how Microvms are deleted from their hosts. Changing
+	// it requires a restart.
+	// +optional
+	Deletion DeletionConfiguration `json:"deletion,omitempty"`
+	// CRDCheck is what happens when the installed CRDs do not match the
+	// operator: enforce refuses to start, warn logs the differences and
+	// starts anyway, and disabled skips the check. Changing it requires a
+	// restart.
+	// +kubebuilder:validation:Enum=enforce;warn;disabled
+	// +optional
+	CRDCheck string `json:"crdCheck,omitempty"`
+	// DryRun reconciles every Microvm, MicrovmReplicaSet and
+	// MicrovmDeployment in dry-run mode, recording what would be done in
+	// their status instead of doing it. The controllers which would otherwise
+	// scale deployments or collect garbage are not started. Changing it
+	// requires a restart.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+	// FeatureGates turns optional features on or off by name, over the
+	// --feature-gates flag. Changing it requires a restart.
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+// MetricsConfiguration configures the metrics endpoint.
+type MetricsConfiguration struct {
+	// BindAddress is the address the metrics endpoint binds to.
+	// +optional
+	BindAddress string `json:"bindAddress,omitempty"`
+}
+
+// HealthConfiguration configures the health and readiness endpoints.
+type HealthConfiguration struct {
+	// BindAddress is the address the probe endpoints bind to.
+	// +optional
+	BindAddress string `json:"bindAddress,omitempty"`
+	// CanaryHost is the address of a flintlock host which must be reachable
+	// for the operator to report ready.
+	// +optional
+	CanaryHost string `json:"canaryHost,omitempty"`
+}
+
+// LeaderElectionConfiguration configures leader election.
+type LeaderElectionConfiguration struct {
+	// Enabled makes sure only one replica of the operator reconciles at a time.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// LeaseDuration is how long a replica which is not the leader waits before
+	// trying to take over an unrenewed lease.
+	// +optional
+	LeaseDuration metav1.Duration `json:"leaseDuration,omitempty"`
+	// RenewDeadline is how long the leader keeps retrying to renew its lease.
+	// +optional
+	RenewDeadline metav1.Duration `json:"renewDeadline,omitempty"`
+	// RetryPeriod is how long replicas wait between attempts to acquire or
+	// renew the lease.
+	// +optional
+	RetryPeriod metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+// ControllersConfiguration configures each controller.
+type ControllersConfiguration struct {
+	// +optional
+	Microvm ControllerConfiguration `json:"microvm,omitempty"`
+	// +optional
+	MicrovmReplicaSet ControllerConfiguration `json:"microvmReplicaSet,omitempty"`
+	// +optional
+	MicrovmDeployment ControllerConfiguration `json:"microvmDeployment,omitempty"`
+}
+
+// ControllerConfiguration configures a single controller.
+type ControllerConfiguration struct {
+	// MaxConcurrentReconciles is how many objects are reconciled at once.
+	// Changing it requires a restart.
+	// +optional
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+	// RequeuePeriod is how long to wait before checking on an object which is
+	// still converging. It is reloaded without a restart.
+	// +optional
+	RequeuePeriod metav1.Duration `json:"requeuePeriod,omitempty"`
+	// MinReconcileInterval is the shortest interval a Microvm may ask to be
+	// reconciled at through its reconcileIntervals. Shorter intervals are
+	// raised to it, so that no Microvm can have its host polled in a tight
+	// loop. Defaults to RequeuePeriod, and is only used by the microvm
+	// controller. It is reloaded without a restart.
+	// +optional
+	MinReconcileInterval metav1.Duration `json:"minReconcileInterval,omitempty"`
+}
+
+// FlintlockConfiguration configures how flintlock hosts are called.
+type FlintlockConfiguration struct {
+	// QPS is how many calls a second are made to each host. 0 disables the
+	// limit. Changing it requires a restart.
+	// +optional
+	QPS float64 `json:"qps,omitempty"`
+	// Burst is how many calls can be made to each host in a burst above QPS.
+	// Changing it requires a restart.
+	// +optional
+	Burst int `json:"burst,omitempty"`
+	// DeleteQPS is how many VMs a second are deleted from each host, within
+	// QPS, so that tearing down a large replicaset does not crowd out other
+	// calls. 0 disables the limit. Changing it requires a restart.
+	// +optional
+	DeleteQPS float64 `json:"deleteQPS,omitempty"`
+	// MaxConcurrentDeletes is how many Microvms a MicrovmReplicaSet which is
+	// being deleted removes from each host at once. The next are only deleted
+	// once flintlock has confirmed that earlier ones on the same host are gone. 0 deletes them
+	// all at once. It is reloaded without a restart.
+	// +optional
+	MaxConcurrentDeletes int `json:"maxConcurrentDeletes,omitempty"`
+	// DefaultTLSSecretRef is the secret with the client certificate used for
+	// Microvms which do not set their own tlsSecretRef. When its namespace is
+	// empty the secret is read from the namespace of the Microvm. It is
+	// reloaded without a restart.
+	// +optional
+	DefaultTLSSecretRef *corev1.SecretReference `json:"defaultTLSSecretRef,omitempty"`
+	// CallTimeout is how long each attempt at a call to a host may take,
+	// including connecting to it. 0 lets calls take as long as the reconcile.
+	// Changing it requires a restart.
+	// +optional
+	CallTimeout metav1.Duration `json:"callTimeout,omitempty"`
+	// Retry configures how calls which read from a host are retried when it
+	// cannot be reached. Changing it requires a restart.
+	// +optional
+	Retry RetryConfiguration `json:"retry,omitempty"`
+	// Metadata configures the gRPC metadata sent with every call to a host, so
+	// that the host can attribute each operation to the operator, object and
+	// reconcile which made it. It is reloaded without a restart.
+	// +optional
+	Metadata MetadataConfiguration `json:"metadata,omitempty"`
+	// ContactTimeout is how long calls to a host may fail to reach it before
+	// MicrovmDeployments stop placing new replicas on it. Hosts which have
+	// not been called since the operator started are not affected. 0 keeps
+	// placing replicas on hosts however long they cannot be reached. It is
+	// reloaded without a restart.
+	// +optional
+	ContactTimeout metav1.Duration `json:"contactTimeout,omitempty"`
+}
+
+// DeletionConfiguration configures how Microvms are deleted from their hosts.
+type DeletionConfiguration struct {
+	// PendingGrace is how long a Microvm which is deleted while flintlock is
+	// still creating it is given for the create to settle before it is
+	// deleted.
+	// +optional
+	PendingGrace metav1.Duration `json:"pendingGrace,omitempty"`
+	// StuckTimeout is how long a VM may be deleting on its host before the
+	// Microvm is marked stuck.
+	// +optional
+	StuckTimeout metav1.Duration `json:"stuckTimeout,omitempty"`
+	// ForceStuck asks the host to delete a VM which is stuck deleting again
+	// on every reconcile, rather than only waiting for it to go.
+	// +optional
+	ForceStuck bool `json:"forceStuck,omitempty"`
+}
+
+// MetadataConfiguration configures the gRPC metadata sent to flintlock hosts.
+type MetadataConfiguration struct {
+	// Exclude names the operator's own headers which are not sent, eg
+	// x-liquidmetal-reconcile-uid. Every one of them is sent by default.
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+	// Extra is sent with every call as it is, eg to name the operator install.
+	// Keys must be lower case, and cannot replace the operator's own headers.
+	// +optional
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// RetryConfiguration configures the retries of calls to flintlock hosts. Only
+// calls which read from a host are retried, as repeating a create or delete
+// which reached the host could act on it twice.
+type RetryConfiguration struct {
+	// MaxAttempts is how many times a call is made before its error is
+	// returned. 0 or 1 disables retries.
+	// +optional
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// InitialBackoff is how long to wait before the first retry. Each retry
+	// waits twice as long as the last. Defaults to 200ms.
+	// +optional
+	InitialBackoff metav1.Duration `json:"initialBackoff,omitempty"`
+	// MaxBackoff is the longest wait between retries. Defaults to 5s.
+	// +optional
+	MaxBackoff metav1.Duration `json:"maxBackoff,omitempty"`
+}
+
+// LoggingConfiguration configures what the operator logs.
+type LoggingConfiguration struct {
+	// TraceFlintlock logs every call made to a flintlock host with its request
+	// and response, under the keys of the object being reconciled. It is very
+	// verbose and meant for debugging. It is reloaded without a restart.
+	// +optional
+	TraceFlintlock bool `json:"traceFlintlock,omitempty"`
+}
+
+// TracingConfiguration configures the export of OpenTelemetry spans for each
+// reconcile and each call made to a flintlock host. Changing it requires a
+// restart.
+type TracingConfiguration struct {
+	// Endpoint is the address of the OTLP gRPC collector the spans are
+	// exported to, such as otel-collector:4317. No spans are exported when it
+	// is empty.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// Insecure connects to the collector without TLS.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+	// SamplingRatio is the fraction of reconciles which are traced, from 0
+	// to 1.
+	// +optional
+	SamplingRatio float64 `json:"samplingRatio,omitempty"`
+}
+
+// PhoneHomeConfiguration configures the phone-home server.
+type PhoneHomeConfiguration struct {
+	// BindAddress is the address the server listens on. The server is not
+	// started, and guests are not asked to call back, when it is empty.
+	// +optional
+	BindAddress string `json:"bindAddress,omitempty"`
+	// URL is the base URL guests reach the server at, such as
+	// https://10.0.0.1:9445. It is required when BindAddress is set.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// CertDir is the directory holding the tls.crt and tls.key the server
+	// presents.
+	// +optional
+	CertDir string `json:"certDir,omitempty"`
+}
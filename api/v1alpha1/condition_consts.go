@@ -21,10 +21,99 @@ const (
 	// MicrovmDeletedFailedReason indicates the microvm failed to deleted cleanly.
 	MicrovmDeleteFailedReason = "MicrovmDeleteFailed"
 
+	// MicrovmDeleteAllowedCondition indicates that the VM of the microvm may be deleted from its host.
+	// It is only set while the microvm is protected from deletion.
+	MicrovmDeleteAllowedCondition clusterv1.ConditionType = "MicrovmDeleteAllowed"
+
+	// MicrovmDeleteProtectedReason indicates the microvm has the delete protection annotation.
+	MicrovmDeleteProtectedReason = "MicrovmDeleteProtected"
+
+	// MicrovmReleaseFailedReason indicates an external resource of the microvm could not be released.
+	MicrovmReleaseFailedReason = "MicrovmReleaseFailed"
+
+	// MicrovmShuttingDownReason indicates the guest has been asked to shut down ahead of deletion.
+	MicrovmShuttingDownReason = "MicrovmShuttingDown"
+
+	// MicrovmStuckDeletingReason indicates the microvm has been deleting on its host for longer than it should take.
+	MicrovmStuckDeletingReason = "MicrovmStuckDeleting"
+
+	// MicrovmWaitingForCreateReason indicates the delete is waiting for a pending create to settle on the host.
+	MicrovmWaitingForCreateReason = "MicrovmWaitingForCreate"
+
+	// MicrovmIdentityVerifiedCondition indicates that the host returned the VM named by the provider ID of the microvm.
+	MicrovmIdentityVerifiedCondition clusterv1.ConditionType = "MicrovmIdentityVerified"
+
+	// MicrovmIdentityMismatchReason indicates the host returned a VM with another UID than the provider ID, so the
+	// microvm leaves it alone until the provider ID is corrected.
+	MicrovmIdentityMismatchReason = "MicrovmIdentityMismatch"
+
+	// MicrovmGuestHealthyCondition indicates that the guest of the microvm is passing its liveness probe.
+	MicrovmGuestHealthyCondition clusterv1.ConditionType = "MicrovmGuestHealthy"
+
+	// MicrovmGuestUnhealthyReason indicates the guest has failed its liveness probe too many times in a row.
+	MicrovmGuestUnhealthyReason = "MicrovmGuestUnhealthy"
+
+	// MicrovmRestartingReason indicates the microvm is being recreated after its guest failed its liveness probe,
+	// or because a restart was requested.
+	MicrovmRestartingReason = "MicrovmRestarting"
+
+	// MicrovmGuestAgentReachableCondition indicates that the guest agent of the microvm answered when last asked
+	// about the guest.
+	MicrovmGuestAgentReachableCondition clusterv1.ConditionType = "MicrovmGuestAgentReachable"
+
+	// MicrovmGuestAgentUnreachableReason indicates the guest agent could not be asked about the guest.
+	MicrovmGuestAgentUnreachableReason = "MicrovmGuestAgentUnreachable"
+
+	// MicrovmWaitingForCloudInitReason indicates the VM is created but cloud-init has not yet been confirmed to have
+	// finished in the guest, as the readiness gate of the microvm asks.
+	MicrovmWaitingForCloudInitReason = "MicrovmWaitingForCloudInit"
+
+	// MicrovmCloudInitFailedReason indicates cloud-init failed or is disabled in the guest, so the readiness gate
+	// of the microvm cannot pass.
+	MicrovmCloudInitFailedReason = "MicrovmCloudInitFailed"
+
+	// MicrovmSpecSyncedCondition indicates that the VM on the host matches the spec of the microvm.
+	MicrovmSpecSyncedCondition clusterv1.ConditionType = "MicrovmSpecSynced"
+
+	// MicrovmSpecDriftedReason indicates the spec of the microvm has changed since the VM was created.
+	MicrovmSpecDriftedReason = "MicrovmSpecDrifted"
+
+	// MicrovmRecreatingReason indicates the microvm is being recreated to apply a change to its spec.
+	MicrovmRecreatingReason = "MicrovmRecreating"
+
+	// MicrovmResizingReason indicates the microvm is being recreated to apply a change to its vcpu or memory.
+	MicrovmResizingReason = "MicrovmResizing"
+
+	// MicrovmSSHKeysSyncedCondition indicates that the SSH keys in the guest match the spec.
+	MicrovmSSHKeysSyncedCondition clusterv1.ConditionType = "MicrovmSSHKeysSynced"
+
+	// MicrovmSSHKeysOutdatedReason indicates the SSH keys changed but are not rolled out by the update strategy.
+	MicrovmSSHKeysOutdatedReason = "MicrovmSSHKeysOutdated"
+
+	// MicrovmSSHKeysRotatingReason indicates the microvm is being recreated to roll out changed SSH keys.
+	MicrovmSSHKeysRotatingReason = "MicrovmSSHKeysRotating"
+
+	// MicrovmHostMigrationFailedReason indicates the host endpoint of the microvm changed, but the VM
+	// was not found at the new endpoint.
+	MicrovmHostMigrationFailedReason = "MicrovmHostMigrationFailed"
+
+	// MicrovmHostVMNameConflictReason indicates the host already has a VM with the namespace and name
+	// of the microvm, which another microvm created.
+	MicrovmHostVMNameConflictReason = "MicrovmHostVMNameConflict"
+
 	// MicrovmUnknownStateReason indicates that the microvm in in an unknown or unsupported state
 	// for reconciliation.
 	MicrovmUnknownStateReason = "MicrovmUnknownState"
 
+	// MicrovmInvalidSpecReason indicates the microvm cannot be reconciled until a mistake in its spec is fixed.
+	MicrovmInvalidSpecReason = "MicrovmInvalidSpec"
+
+	// MicrovmImageNotPinnedReason indicates the microvm is not created because an image is not given by digest.
+	MicrovmImageNotPinnedReason = "MicrovmImageNotPinned"
+
+	// MicrovmImageResolveFailedReason indicates the tag of an image could not be resolved to a digest.
+	MicrovmImageResolveFailedReason = "MicrovmImageResolveFailed"
+
 	// MicrovmReplicaSetReadyCondition indicates that the microvmreplicaset is in a complete state.
 	MicrovmReplicaSetReadyCondition clusterv1.ConditionType = "MicrovmReplicaSetReady"
 
@@ -43,6 +132,33 @@ const (
 	// MicrovmReplicaSetUpdatingReason indicates the microvm is in a pending state.
 	MicrovmReplicaSetUpdatingReason = "MicrovmReplicaSetUpdating"
 
+	// MicrovmReplicaSetReplacingFailedReason indicates failed microvms are being deleted to be replaced.
+	MicrovmReplicaSetReplacingFailedReason = "MicrovmReplicaSetReplacingFailed"
+
+	// MicrovmReplicaSetRestartingReason indicates the microvms are being recreated one at a time because a
+	// restart was requested.
+	MicrovmReplicaSetRestartingReason = "MicrovmReplicaSetRestarting"
+
+	// MicrovmReplicaSetImageResolveFailedReason indicates the tag of an image of the template could not be resolved.
+	MicrovmReplicaSetImageResolveFailedReason = "MicrovmReplicaSetImageResolveFailed"
+
+	// MicrovmReplicaSetNoHostsReason indicates neither a host nor any hosts are set.
+	MicrovmReplicaSetNoHostsReason = "MicrovmReplicaSetNoHosts"
+
+	// MicrovmReplicaSetInvalidSelectorReason indicates the selector is invalid or does not match the template.
+	MicrovmReplicaSetInvalidSelectorReason = "MicrovmReplicaSetInvalidSelector"
+
+	// MicrovmReplicaSetExternalReplicasReason indicates a scale down is blocked because only externally
+	// managed microvms are left to remove.
+	MicrovmReplicaSetExternalReplicasReason = "MicrovmReplicaSetExternalReplicas"
+
+	// MicrovmReplicaSetHostReachableCondition indicates that the hosts of the microvmreplicaset are answering.
+	MicrovmReplicaSetHostReachableCondition clusterv1.ConditionType = "MicrovmReplicaSetHostReachable"
+
+	// MicrovmReplicaSetHostUnreachableReason indicates the microvmreplicaset is degraded because one of its hosts
+	// is not answering.
+	MicrovmReplicaSetHostUnreachableReason = "MicrovmReplicaSetHostUnreachable"
+
 	// MicrovmDeploymentReadyCondition indicates that the microvmreplicaset is in a complete state.
 	MicrovmDeploymentReadyCondition clusterv1.ConditionType = "MicrovmDeploymentReady"
 
@@ -63,4 +179,182 @@ const (
 
 	// MicrovmDeploymentDeleteFailedReason indicates the microvmreplicaset failed to deleted cleanly.
 	MicrovmDeploymentDeleteFailedReason = "MicrovmDeploymentDeleteFailed"
+
+	// MicrovmDeploymentSpreadCondition indicates that the ready replicas of the deployment
+	// are spread within its spread constraints.
+	MicrovmDeploymentSpreadCondition clusterv1.ConditionType = "MicrovmDeploymentSpread"
+
+	// MicrovmDeploymentSpreadSkewExceededReason indicates the ready replicas are more unevenly
+	// spread than the spread constraints permit.
+	MicrovmDeploymentSpreadSkewExceededReason = "MicrovmDeploymentSpreadSkewExceeded"
+
+	// MicrovmDeploymentPlacementOverrideUnschedulableReason indicates replicas pinned to a host
+	// by a placement override are spread instead, as the host is not schedulable.
+	MicrovmDeploymentPlacementOverrideUnschedulableReason = "MicrovmDeploymentPlacementOverrideUnschedulable"
+
+	// MicrovmDeploymentInvalidSelectorReason indicates the selector is invalid or does not match the template.
+	MicrovmDeploymentInvalidSelectorReason = "MicrovmDeploymentInvalidSelector"
+
+	// MicrovmDeploymentDrainingReason indicates replicas are being recreated away from unschedulable hosts.
+	MicrovmDeploymentDrainingReason = "MicrovmDeploymentDraining"
+
+	// MicrovmDeploymentFailingOverReason indicates replicas are being recreated away from unreachable hosts.
+	MicrovmDeploymentFailingOverReason = "MicrovmDeploymentFailingOver"
+
+	// MicrovmDeploymentPreemptedReason indicates replicas are being recreated away from hosts needed by a
+	// higher priority deployment.
+	MicrovmDeploymentPreemptedReason = "MicrovmDeploymentPreempted"
+
+	// MicrovmDeploymentRollingOutReason indicates a changed template is being rolled out to the hosts.
+	MicrovmDeploymentRollingOutReason = "MicrovmDeploymentRollingOut"
+
+	// MicrovmDeploymentRolledBackReason indicates the rollout of the template failed and was rolled back.
+	MicrovmDeploymentRolledBackReason = "MicrovmDeploymentRolledBack"
+
+	// MicrovmDeploymentInvalidCanaryReason indicates the canary host of the rollout is not a host of the deployment.
+	MicrovmDeploymentInvalidCanaryReason = "MicrovmDeploymentInvalidCanary"
+
+	// MicrovmDeploymentReplicasReadyCondition aggregates the ready conditions of the microvmreplicasets
+	// which serve the hosts of the deployment.
+	MicrovmDeploymentReplicasReadyCondition clusterv1.ConditionType = "MicrovmDeploymentReplicasReady"
+
+	// MicrovmDeploymentHostsHealthyCondition indicates that none of the hosts of the deployment are
+	// cordoned, unreachable or preempted.
+	MicrovmDeploymentHostsHealthyCondition clusterv1.ConditionType = "MicrovmDeploymentHostsHealthy"
+
+	// MicrovmDeploymentTemplateUpToDateCondition indicates that every microvmreplicaset of the deployment
+	// was created from the current template.
+	MicrovmDeploymentTemplateUpToDateCondition clusterv1.ConditionType = "MicrovmDeploymentTemplateUpToDate"
+
+	// MicrovmDeploymentProgressingCondition indicates that the deployment is complete, or that more of
+	// its microvms have become ready within its progress deadline.
+	MicrovmDeploymentProgressingCondition clusterv1.ConditionType = "Progressing"
+
+	// MicrovmDeploymentProgressDeadlineExceededReason indicates that no more microvms of the deployment
+	// have become ready within its progress deadline.
+	MicrovmDeploymentProgressDeadlineExceededReason = "ProgressDeadlineExceeded"
+
+	// MicrovmDeploymentHostsUnhealthyReason indicates some hosts of the deployment cannot run its replicas.
+	MicrovmDeploymentHostsUnhealthyReason = "MicrovmDeploymentHostsUnhealthy"
+
+	// MicrovmDeploymentTemplateOutdatedReason indicates some microvmreplicasets were created from a previous
+	// template and are not being rolled out.
+	MicrovmDeploymentTemplateOutdatedReason = "MicrovmDeploymentTemplateOutdated"
+
+	// MicrovmAutoscalerScalingActiveCondition indicates that the autoscaler is able to read its
+	// metric and scale the target.
+	MicrovmAutoscalerScalingActiveCondition clusterv1.ConditionType = "MicrovmAutoscalerScalingActive"
+
+	// MicrovmAutoscalerTargetNotFoundReason indicates the scale target could not be found.
+	MicrovmAutoscalerTargetNotFoundReason = "MicrovmAutoscalerTargetNotFound"
+
+	// MicrovmAutoscalerMetricUnavailableReason indicates the metric could not be read.
+	MicrovmAutoscalerMetricUnavailableReason = "MicrovmAutoscalerMetricUnavailable"
+
+	// MicrovmAutoscalerScaleFailedReason indicates the target could not be updated.
+	MicrovmAutoscalerScaleFailedReason = "MicrovmAutoscalerScaleFailed"
+
+	// MicrovmHostErrorBudgetCondition indicates that the host has error budget remaining
+	// for provisioning Microvms.
+	MicrovmHostErrorBudgetCondition clusterv1.ConditionType = "MicrovmHostErrorBudget"
+
+	// MicrovmHostErrorBudgetExhaustedReason indicates the host has failed too many provisioning
+	// attempts within its window.
+	MicrovmHostErrorBudgetExhaustedReason = "MicrovmHostErrorBudgetExhausted"
+
+	// MicrovmHostQuarantinedReason indicates the microvm is waiting for its host to leave quarantine.
+	MicrovmHostQuarantinedReason = "MicrovmHostQuarantined"
+
+	// MicrovmHostIdentityVerifiedCondition indicates that the host presented the identity it is trusted with.
+	MicrovmHostIdentityVerifiedCondition clusterv1.ConditionType = "MicrovmHostIdentityVerified"
+
+	// MicrovmHostIdentityChangedReason indicates the host presented a different identity to the trusted one.
+	MicrovmHostIdentityChangedReason = "MicrovmHostIdentityChanged"
+
+	// MicrovmHostIdentityUnknownReason indicates the identity of the host could not be checked.
+	MicrovmHostIdentityUnknownReason = "MicrovmHostIdentityUnknown"
+
+	// MicrovmHostUntrustedReason indicates the microvm is waiting for the identity of its host to be trusted again.
+	MicrovmHostUntrustedReason = "MicrovmHostUntrusted"
+
+	// MicrovmHostReachableCondition indicates that the host is answering. It is set on microvmhosts, and on
+	// microvms for the host they are on.
+	MicrovmHostReachableCondition clusterv1.ConditionType = "MicrovmHostReachable"
+
+	// MicrovmHostUnreachableReason indicates the host could not be connected to.
+	MicrovmHostUnreachableReason = "MicrovmHostUnreachable"
+
+	// MicrovmHostGroupReadyCondition indicates that the members of the microvmhostgroup have been resolved.
+	MicrovmHostGroupReadyCondition clusterv1.ConditionType = "MicrovmHostGroupReady"
+
+	// MicrovmHostGroupInvalidSelectorReason indicates the selector of the microvmhostgroup is invalid.
+	MicrovmHostGroupInvalidSelectorReason = "MicrovmHostGroupInvalidSelector"
+
+	// MicrovmDeploymentHostGroupNotReadyReason indicates the referenced microvmhostgroup cannot be used yet.
+	MicrovmDeploymentHostGroupNotReadyReason = "MicrovmDeploymentHostGroupNotReady"
+
+	// MicrovmDeploymentInvalidHostSelectorReason indicates the host selector of the deployment is invalid.
+	MicrovmDeploymentInvalidHostSelectorReason = "MicrovmDeploymentInvalidHostSelector"
+
+	// MicrovmTemplateReadyCondition indicates that the microvmtemplate can be used.
+	MicrovmTemplateReadyCondition clusterv1.ConditionType = "MicrovmTemplateReady"
+
+	// MicrovmTemplateResolveFailedReason indicates the template source could not be fetched.
+	MicrovmTemplateResolveFailedReason = "MicrovmTemplateResolveFailed"
+
+	// MicrovmTemplateInvalidReason indicates the template source or its content is invalid.
+	MicrovmTemplateInvalidReason = "MicrovmTemplateInvalid"
+
+	// MicrovmDeploymentTemplateNotReadyReason indicates the referenced microvmtemplate cannot be used yet.
+	MicrovmDeploymentTemplateNotReadyReason = "MicrovmDeploymentTemplateNotReady"
+
+	// MicrovmReplicaSetTemplateNotReadyReason indicates the referenced microvmtemplate cannot be used yet.
+	MicrovmReplicaSetTemplateNotReadyReason = "MicrovmReplicaSetTemplateNotReady"
+
+	// MicrovmSnapshotReadyCondition indicates that the microvmsnapshot has been taken.
+	MicrovmSnapshotReadyCondition clusterv1.ConditionType = "MicrovmSnapshotReady"
+
+	// MicrovmSnapshotSourceNotFoundReason indicates the microvm to snapshot does not exist.
+	MicrovmSnapshotSourceNotFoundReason = "MicrovmSnapshotSourceNotFound"
+
+	// MicrovmSnapshotSourceNotReadyReason indicates the microvm to snapshot is not ready yet.
+	MicrovmSnapshotSourceNotReadyReason = "MicrovmSnapshotSourceNotReady"
+
+	// MicrovmSnapshotNotReadyReason indicates the microvm is waiting for the microvmsnapshot it restores from.
+	MicrovmSnapshotNotReadyReason = "MicrovmSnapshotNotReady"
+
+	// MicrovmSnapshotNotFoundReason indicates the microvmsnapshot the microvm restores from does not exist.
+	MicrovmSnapshotNotFoundReason = "MicrovmSnapshotNotFound"
+
+	// MicrovmMACPoolUnavailableReason indicates the microvm is waiting for its microvmmacpool to give its
+	// network interfaces an address.
+	MicrovmMACPoolUnavailableReason = "MicrovmMACPoolUnavailable"
+
+	// MicrovmMACPoolReadyCondition indicates that the microvmmacpool has addresses left to allocate.
+	MicrovmMACPoolReadyCondition clusterv1.ConditionType = "MicrovmMACPoolReady"
+
+	// MicrovmMACPoolInvalidReason indicates the range or addresses of the microvmmacpool are invalid.
+	MicrovmMACPoolInvalidReason = "MicrovmMACPoolInvalid"
+
+	// MicrovmMACPoolExhaustedReason indicates every address of the microvmmacpool has been allocated.
+	MicrovmMACPoolExhaustedReason = "MicrovmMACPoolExhausted"
+
+	// MicrovmIPPoolUnavailableReason indicates the microvm is waiting for a microvmippool to give a
+	// network interface an address.
+	MicrovmIPPoolUnavailableReason = "MicrovmIPPoolUnavailable"
+
+	// MicrovmIPPoolReadyCondition indicates that the microvmippool has addresses left to allocate.
+	MicrovmIPPoolReadyCondition clusterv1.ConditionType = "MicrovmIPPoolReady"
+
+	// MicrovmIPPoolInvalidReason indicates the subnet, range or gateway of the microvmippool are invalid.
+	MicrovmIPPoolInvalidReason = "MicrovmIPPoolInvalid"
+
+	// MicrovmIPPoolExhaustedReason indicates every address of the microvmippool has been allocated.
+	MicrovmIPPoolExhaustedReason = "MicrovmIPPoolExhausted"
+
+	// MicrovmQuotaReadyCondition indicates that the microvms of the namespace are within the microvmquota.
+	MicrovmQuotaReadyCondition clusterv1.ConditionType = "MicrovmQuotaReady"
+
+	// MicrovmQuotaExceededReason indicates the microvms of the namespace use more than the microvmquota allows.
+	MicrovmQuotaExceededReason = "MicrovmQuotaExceeded"
 )
@@ -0,0 +1,168 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WaitForReadyInterval is how often WaitForReady re-reads the object.
+const WaitForReadyInterval = time.Second
+
+// ErrTerminalFailure is returned by WaitForReady when the object has failed in
+// a way which the controllers will not recover from.
+var ErrTerminalFailure = errors.New("object has a terminal failure")
+
+// Phase is a summary of the conditions of an object, for scripting against
+// the API without interpreting them.
+// +kubebuilder:validation:Enum=Provisioning;Running;Failed;Deleting
+type Phase string
+
+const (
+	// PhaseProvisioning means the object is not yet ready, and has not failed.
+	PhaseProvisioning Phase = "Provisioning"
+	// PhaseRunning means the ready condition of the object is true.
+	PhaseRunning Phase = "Running"
+	// PhaseFailed means the object is failing, as reported by GetFailureReason.
+	PhaseFailed Phase = "Failed"
+	// PhaseDeleting means the object is being deleted.
+	PhaseDeleting Phase = "Deleting"
+)
+
+// ReadinessReporter is implemented by the kinds in this package which report
+// their readiness through a single condition.
+// +kubebuilder:object:generate=false
+type ReadinessReporter interface {
+	client.Object
+	conditions.Getter
+
+	// ReadyConditionType returns the condition which reports readiness.
+	ReadyConditionType() clusterv1.ConditionType
+}
+
+// ReadyConditionType returns the condition which reports whether the Microvm is ready.
+func (r *Microvm) ReadyConditionType() clusterv1.ConditionType {
+	return MicrovmReadyCondition
+}
+
+// ReadyConditionType returns the condition which reports whether the MicrovmReplicaSet is ready.
+func (r *MicrovmReplicaSet) ReadyConditionType() clusterv1.ConditionType {
+	return MicrovmReplicaSetReadyCondition
+}
+
+// ReadyConditionType returns the condition which reports whether the MicrovmDeployment is ready.
+func (r *MicrovmDeployment) ReadyConditionType() clusterv1.ConditionType {
+	return MicrovmDeploymentReadyCondition
+}
+
+// ReadyConditionType returns the condition which reports whether the MicrovmAutoscaler is scaling.
+func (r *MicrovmAutoscaler) ReadyConditionType() clusterv1.ConditionType {
+	return MicrovmAutoscalerScalingActiveCondition
+}
+
+// ReadyConditionType returns the condition which reports whether the MicrovmTemplate can be used.
+func (r *MicrovmTemplate) ReadyConditionType() clusterv1.ConditionType {
+	return MicrovmTemplateReadyCondition
+}
+
+// IsReady returns true if the object's ready condition is true.
+func IsReady(obj ReadinessReporter) bool {
+	return conditions.IsTrue(obj, obj.ReadyConditionType())
+}
+
+// GetFailureReason returns the reason the object is failing, or an empty string
+// if it is not. A terminal FailureReason on a Microvm takes precedence over its
+// conditions. Otherwise the reason of a false ready condition with Error
+// severity is returned.
+func GetFailureReason(obj ReadinessReporter) string {
+	if reason := terminalFailureReason(obj); reason != "" {
+		return reason
+	}
+
+	condition := conditions.Get(obj, obj.ReadyConditionType())
+	if condition == nil || condition.Status != corev1.ConditionFalse || condition.Severity != clusterv1.ConditionSeverityError {
+		return ""
+	}
+
+	return condition.Reason
+}
+
+// GetPhase returns the phase of the object, derived from its deletion
+// timestamp and conditions.
+func GetPhase(obj ReadinessReporter) Phase {
+	switch {
+	case !obj.GetDeletionTimestamp().IsZero():
+		return PhaseDeleting
+	case GetFailureReason(obj) != "":
+		return PhaseFailed
+	case IsReady(obj):
+		return PhaseRunning
+	default:
+		return PhaseProvisioning
+	}
+}
+
+// GetFailureMessage returns the message which accompanies GetFailureReason.
+func GetFailureMessage(obj ReadinessReporter) string {
+	if mvm, ok := obj.(*Microvm); ok && mvm.Status.FailureMessage != nil {
+		return *mvm.Status.FailureMessage
+	}
+
+	if GetFailureReason(obj) == "" {
+		return ""
+	}
+
+	return conditions.GetMessage(obj, obj.ReadyConditionType())
+}
+
+// WaitForReady reads the object with the given key into obj until its ready
+// condition is true, the context is done, or a terminal failure is recorded.
+func WaitForReady(ctx context.Context, c client.Reader, key client.ObjectKey, obj ReadinessReporter) error {
+	err := wait.PollImmediateUntilWithContext(ctx, WaitForReadyInterval, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, key, obj); err != nil {
+			return false, fmt.Errorf("getting %s: %w", key, err)
+		}
+
+		if reason := terminalFailureReason(obj); reason != "" {
+			return false, fmt.Errorf("%w: %s", ErrTerminalFailure, reason)
+		}
+
+		return IsReady(obj), nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for %s to be ready: %w", key, err)
+	}
+
+	return nil
+}
+
+func terminalFailureReason(obj ReadinessReporter) string {
+	if mvm, ok := obj.(*Microvm); ok && mvm.Status.FailureReason != nil {
+		return *mvm.Status.FailureReason
+	}
+
+	return ""
+}
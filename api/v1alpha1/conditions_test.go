@@ -0,0 +1,143 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+func TestIsReady(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := &infrav1.Microvm{}
+	g.Expect(infrav1.IsReady(mvm)).To(BeFalse())
+
+	conditions.MarkTrue(mvm, infrav1.MicrovmReadyCondition)
+	g.Expect(infrav1.IsReady(mvm)).To(BeTrue())
+
+	mvmRS := &infrav1.MicrovmReplicaSet{}
+	conditions.MarkTrue(mvmRS, infrav1.MicrovmReadyCondition)
+	g.Expect(infrav1.IsReady(mvmRS)).To(BeFalse(), "Expected only the replicaset's own condition to count")
+}
+
+func TestGetFailureReason(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := &infrav1.Microvm{}
+	g.Expect(infrav1.GetFailureReason(mvm)).To(BeEmpty())
+
+	conditions.MarkFalse(mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmPendingReason, clusterv1.ConditionSeverityInfo, "")
+	g.Expect(infrav1.GetFailureReason(mvm)).To(BeEmpty(), "Expected info severity not to be a failure")
+
+	conditions.MarkFalse(mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmProvisionFailedReason, clusterv1.ConditionSeverityError, "boom")
+	g.Expect(infrav1.GetFailureReason(mvm)).To(Equal(infrav1.MicrovmProvisionFailedReason))
+	g.Expect(infrav1.GetFailureMessage(mvm)).To(Equal("boom"))
+
+	mvm.Status.FailureReason = pointer.String("InvalidSpec")
+	mvm.Status.FailureMessage = pointer.String("spec is invalid")
+	g.Expect(infrav1.GetFailureReason(mvm)).To(Equal("InvalidSpec"))
+	g.Expect(infrav1.GetFailureMessage(mvm)).To(Equal("spec is invalid"))
+}
+
+func TestGetPhase(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := &infrav1.Microvm{}
+	g.Expect(infrav1.GetPhase(mvm)).To(Equal(infrav1.PhaseProvisioning))
+
+	conditions.MarkTrue(mvm, infrav1.MicrovmReadyCondition)
+	g.Expect(infrav1.GetPhase(mvm)).To(Equal(infrav1.PhaseRunning))
+
+	conditions.MarkFalse(mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmProvisionFailedReason, clusterv1.ConditionSeverityError, "boom")
+	g.Expect(infrav1.GetPhase(mvm)).To(Equal(infrav1.PhaseFailed))
+
+	mvm.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	g.Expect(infrav1.GetPhase(mvm)).To(Equal(infrav1.PhaseDeleting))
+
+	mvmD := &infrav1.MicrovmDeployment{}
+	conditions.MarkTrue(mvmD, infrav1.MicrovmDeploymentReadyCondition)
+	g.Expect(infrav1.GetPhase(mvmD)).To(Equal(infrav1.PhaseRunning))
+}
+
+func TestWaitForReady(t *testing.T) {
+	scheme := runtime.NewScheme()
+	NewWithT(t).Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	key := client.ObjectKey{Name: "mvm1", Namespace: "ns1"}
+
+	tt := []struct {
+		name     string
+		mutate   func(*infrav1.Microvm)
+		expected func(*WithT, error)
+	}{
+		{
+			name: "ready object returns immediately",
+			mutate: func(mvm *infrav1.Microvm) {
+				conditions.MarkTrue(mvm, infrav1.MicrovmReadyCondition)
+			},
+			expected: func(g *WithT, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+			},
+		},
+		{
+			name: "terminal failure returns an error",
+			mutate: func(mvm *infrav1.Microvm) {
+				mvm.Status.FailureReason = pointer.String("InvalidSpec")
+			},
+			expected: func(g *WithT, err error) {
+				g.Expect(errors.Is(err, infrav1.ErrTerminalFailure)).To(BeTrue())
+			},
+		},
+		{
+			name:   "object which never becomes ready times out",
+			mutate: func(mvm *infrav1.Microvm) {},
+			expected: func(g *WithT, err error) {
+				g.Expect(err).To(HaveOccurred())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+			tc.mutate(mvm)
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvm).Build()
+
+			ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+			defer cancel()
+
+			tc.expected(g, infrav1.WaitForReady(ctx, c, key, &infrav1.Microvm{}))
+		})
+	}
+}
@@ -19,6 +19,7 @@ package v1alpha1
 import (
 	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
 	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
@@ -27,6 +28,48 @@ const (
 	// MvmFinalizer allows ReconcileMicrovm to clean up resources associated with Microvm
 	// before removing it from the apiserver.
 	MvmFinalizer = "microvm.infrastructure.microvm.x-k8s.io"
+
+	// MicrovmUIDLabel records the UID of the Microvm a flintlock VM or another
+	// resource was created for, so that it can be garbage collected if the
+	// Microvm is deleted without releasing it.
+	MicrovmUIDLabel = "infrastructure.liquid-metal.io/microvm-uid"
+
+	// MicrovmNamespaceLabel records the namespace of the Microvm a flintlock VM
+	// was created for, when the VM is created in another namespace on its host.
+	MicrovmNamespaceLabel = "infrastructure.liquid-metal.io/microvm-namespace"
+
+	// DefaultTLSSecretAnnotation on a namespace names the secret the Microvms
+	// created in it without a tlsSecretRef are given.
+	DefaultTLSSecretAnnotation = "infrastructure.liquid-metal.io/default-tls-secret"
+
+	// DefaultBasicAuthSecretAnnotation on a namespace names the secret the
+	// Microvms created in it without a basicAuthSecret are given.
+	DefaultBasicAuthSecretAnnotation = "infrastructure.liquid-metal.io/default-basic-auth-secret"
+
+	// DeleteProtectionAnnotation set to "true" on a Microvm stops its VM from
+	// being deleted from the host, whether the Microvm is deleted, scaled
+	// away, recreated for a spec change or restarted. A deleted Microvm waits
+	// until the annotation is removed.
+	DeleteProtectionAnnotation = "liquid-metal.io/delete-protection"
+
+	// ForceDeleteAnnotation set to "true" on a deleted Microvm whose host is
+	// untrusted removes its finalizer without contacting the host, rather than
+	// waiting for the host to be trusted again. Its VM, if there is one, is
+	// left on the host to be cleaned up by hand.
+	ForceDeleteAnnotation = "infrastructure.liquid-metal.io/force-delete"
+
+	// RestartedAtAnnotation set to a new value, such as the current time, on a
+	// Microvm recreates its VM, and on a MicrovmReplicaSet or
+	// MicrovmDeployment recreates the VMs of all of their Microvms. A
+	// deployment rolls the restart out with its rollout strategy.
+	RestartedAtAnnotation = "liquid-metal.io/restartedAt"
+
+	// DryRunAnnotation set to "true" on a Microvm, MicrovmReplicaSet or
+	// MicrovmDeployment has it reconciled in dry-run mode, as every object is
+	// when the operator runs with --dry-run. The calls to flintlock and the
+	// changes to child objects a reconcile would make are recorded in the
+	// dryRun field of its status instead of being made.
+	DryRunAnnotation = "liquid-metal.io/dry-run"
 )
 
 // MicrovmSpec defines the desired state of Microvm
@@ -51,11 +94,40 @@ type MicrovmSpec struct {
 	// 		path: "/root/FINDME"
 	// 		owner: "root:root"
 	// 		permissions: "0755"
+	//
+	// It is checked when the Microvm is applied: a cloud-config must be a
+	// valid YAML mapping, a script must start with a shebang, and the metadata
+	// of the VM must fit within the 51200 bytes flintlock accepts.
 	// +optional
 	UserData *string `json:"userdata,omitempty"`
 	// SSHPublicKeys is list of SSH public keys which will be added to the Microvm.
 	// +optional
 	SSHPublicKeys []microvm.SSHPublicKey `json:"sshPublicKeys,omitempty"`
+	// Hostname is the hostname of the guest, set through the cloud-init
+	// metadata. It defaults to the name of the Microvm.
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	Hostname string `json:"hostname,omitempty"`
+	// HostVMNamespace is the namespace the VM is created in on its host. It
+	// defaults to the namespace of the Microvm. Set it, along with HostVMName,
+	// when several clusters share a host, so that their VMs do not clash.
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	HostVMNamespace string `json:"hostVMNamespace,omitempty"`
+	// HostVMName is the name the VM is created with on its host. It defaults
+	// to the name of the Microvm. The VM is not created if the host already
+	// has a VM with the same namespace and name which another Microvm created.
+	// +kubebuilder:validation:MaxLength=253
+	// +optional
+	HostVMName string `json:"hostVMName,omitempty"`
+	// DNS configures the resolver of the guest.
+	// +optional
+	DNS *DNSConfig `json:"dns,omitempty"`
+	// VendorData adds packages, commands and time settings to the cloud-init
+	// vendor data the operator generates for the users and their SSH keys, so
+	// that the guest can be customised without taking over its user data.
+	// +optional
+	VendorData *VendorData `json:"vendorData,omitempty"`
 	// TODO this needs to go and be pulled off the owning object
 	// probably needs to be part of Hosts once that becomes an array
 	// mTLS Configuration:
@@ -111,6 +183,523 @@ type MicrovmSpec struct {
 	// MicrovmProxy is the proxy server details to use when calling the microvm service. This is an
 	// alternative to using the http proxy environment variables and applied purely to the grpc service.
 	MicrovmProxy *flclient.Proxy `json:"microvmProxy,omitempty"`
+	// GracefulShutdown asks the guest to shut down cleanly before the Microvm is
+	// deleted. When unset the Microvm is deleted immediately.
+	// +optional
+	GracefulShutdown *GracefulShutdown `json:"gracefulShutdown,omitempty"`
+	// LivenessProbe checks the workload in the guest once flintlock reports the
+	// VM as created. The result is reported by the MicrovmGuestHealthy condition.
+	// +optional
+	LivenessProbe *LivenessProbe `json:"livenessProbe,omitempty"`
+	// RestartPolicy is what happens when the liveness probe fails. With Always the
+	// VM is deleted from the host and created again.
+	// +kubebuilder:validation:Enum=Always;Never
+	// +kubebuilder:default=Never
+	// +optional
+	RestartPolicy RestartPolicy `json:"restartPolicy,omitempty"`
+	// UpdateStrategy is what happens when the VM spec or SSH public keys are
+	// changed after the VM has been created. Flintlock cannot update a VM in
+	// place, so with Recreate the VM is deleted from the host and created again
+	// with the new spec. With Resize that is only done when the vcpu or memory
+	// change. Otherwise the change is only reported by the MicrovmSpecSynced
+	// and MicrovmSSHKeysSynced conditions. A guest with a graceful shutdown is
+	// shut down before its VM is deleted.
+	// +kubebuilder:validation:Enum=Ignore;Recreate;Resize
+	// +kubebuilder:default=Ignore
+	// +optional
+	UpdateStrategy UpdateStrategy `json:"updateStrategy,omitempty"`
+	// ImagePolicy is how the kernel, initrd and volume images are pinned. With
+	// Resolve, images given by tag are resolved to the digest the tag points at
+	// before the VM is first created, and the VM is created from the digest.
+	// The Microvms of a MicrovmReplicaSet are created with the digests its
+	// template resolved to, so that every replica boots the same images. With
+	// DigestOnly, the VM is not created until every image is given by digest.
+	// With Tag, flintlock pulls whatever the tags point at when the VM is
+	// created.
+	// +kubebuilder:validation:Enum=Tag;Resolve;DigestOnly
+	// +kubebuilder:default=Tag
+	// +optional
+	ImagePolicy ImagePolicy `json:"imagePolicy,omitempty"`
+	// SSHService asks for a Service pointing at the SSH port of the guest, so
+	// that it can be reached by name from within the cluster. The Service is
+	// created once the address of the guest is known, and is deleted with the
+	// Microvm. The operator only creates it when the SSHService feature gate
+	// is enabled.
+	// +optional
+	SSHService *SSHService `json:"sshService,omitempty"`
+	// GuestAgent is an agent in the guest which the operator regularly asks
+	// about the guest, and which runs commands in it. What it reports is
+	// recorded in the guest info of the status.
+	// +optional
+	GuestAgent *GuestAgent `json:"guestAgent,omitempty"`
+	// ReadinessGate is what the Microvm waits for, once flintlock reports its
+	// VM as created, before it is marked ready. With CloudInit it waits until
+	// the guest agent reports, or the guest calls back to the phone-home server
+	// of the operator, that cloud-init has finished. It is marked not ready if
+	// the guest agent reports that cloud-init failed or is disabled. With None
+	// it is ready as soon as the VM is created.
+	// +kubebuilder:validation:Enum=None;CloudInit
+	// +kubebuilder:default=None
+	// +optional
+	ReadinessGate ReadinessGate `json:"readinessGate,omitempty"`
+	// RestoreFrom is a MicrovmSnapshot, in the same namespace, to clone. Before
+	// the VM is first created its vcpu, memory, kernel, initrd and volumes are
+	// replaced by those of the snapshot, while its network interfaces and labels
+	// are kept. The Microvm waits until the snapshot is ready.
+	// +optional
+	RestoreFrom *corev1.LocalObjectReference `json:"restoreFrom,omitempty"`
+	// ClassRef is a MicrovmClass whose kernel, kernel arguments, initrd, root
+	// volume, vcpu and memory are given to the Microvm when it is applied, for
+	// any of them it does not set itself.
+	// +optional
+	ClassRef *corev1.LocalObjectReference `json:"classRef,omitempty"`
+	// MACPoolRef is a MicrovmMACPool, in the same namespace, which gives each
+	// network interface without a guestMac an address before the VM is first
+	// created. The addresses are released when the Microvm is deleted.
+	// +optional
+	MACPoolRef *corev1.LocalObjectReference `json:"macPoolRef,omitempty"`
+	// IPPools names the MicrovmIPPools, in the same namespace, which give
+	// network interfaces without an address one before the VM is first
+	// created. The gateway and nameservers of the pool are configured in the
+	// guest along with the address, which is released when the Microvm is
+	// deleted.
+	// +listType=map
+	// +listMapKey=interface
+	// +optional
+	IPPools []InterfaceIPPool `json:"ipPools,omitempty"`
+	// Priority decides which Microvms make way on a host of a MicrovmHostGroup
+	// which is at capacity. A MicrovmDeployment whose template has a higher
+	// priority preempts the lowest priority MicrovmReplicaSet of another
+	// MicrovmDeployment in the same namespace on a full host, whose replicas
+	// are recreated on its other hosts. Microvms in other namespaces, and
+	// those which are not created by a MicrovmDeployment, are never
+	// preempted.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+	// ReconcileIntervals are how often the Microvm is checked on in each state
+	// of its VM. Any left unset use the requeue period of the operator.
+	// +optional
+	ReconcileIntervals *ReconcileIntervals `json:"reconcileIntervals,omitempty"`
+}
+
+// RestartPolicy is what happens to a Microvm whose guest fails its liveness
+// probe, or to a Microvm of a MicrovmReplicaSet whose VM has failed.
+type RestartPolicy string
+
+const (
+	// RestartPolicyAlways deletes and recreates the VM.
+	RestartPolicyAlways RestartPolicy = "Always"
+	// RestartPolicyOnFailure replaces a Microvm of a MicrovmReplicaSet only
+	// when flintlock reports its VM as failed.
+	RestartPolicyOnFailure RestartPolicy = "OnFailure"
+	// RestartPolicyNever only reports the failure.
+	RestartPolicyNever RestartPolicy = "Never"
+)
+
+// UpdateStrategy is what happens to a Microvm whose VM spec no longer matches
+// the VM on its host.
+type UpdateStrategy string
+
+const (
+	// UpdateStrategyIgnore only reports the difference.
+	UpdateStrategyIgnore UpdateStrategy = "Ignore"
+	// UpdateStrategyRecreate deletes and recreates the VM.
+	UpdateStrategyRecreate UpdateStrategy = "Recreate"
+	// UpdateStrategyResize deletes and recreates the VM when its vcpu or
+	// memory change, and only reports other differences.
+	UpdateStrategyResize UpdateStrategy = "Resize"
+)
+
+// ImagePolicy is how the images of a Microvm are pinned to digests.
+type ImagePolicy string
+
+const (
+	// ImagePolicyTag uses images as they are given.
+	ImagePolicyTag ImagePolicy = "Tag"
+	// ImagePolicyResolve resolves tags to digests before the VM is created.
+	ImagePolicyResolve ImagePolicy = "Resolve"
+	// ImagePolicyDigestOnly refuses to create a VM from an image given by tag.
+	ImagePolicyDigestOnly ImagePolicy = "DigestOnly"
+)
+
+// ReadinessGate is what a Microvm waits for before it is marked ready.
+type ReadinessGate string
+
+const (
+	// ReadinessGateNone marks the Microvm ready once its VM is created.
+	ReadinessGateNone ReadinessGate = "None"
+	// ReadinessGateCloudInit marks the Microvm ready once cloud-init has
+	// finished in the guest.
+	ReadinessGateCloudInit ReadinessGate = "CloudInit"
+)
+
+// LivenessProbe describes how the workload in the guest is checked. Exactly one
+// of TCPSocket, HTTPGet or AgentExec should be set. Only the addresses of the
+// network interfaces of the guest are probed.
+type LivenessProbe struct {
+	// TCPSocket passes when a TCP connection can be opened to the guest.
+	// +optional
+	TCPSocket *TCPSocketAction `json:"tcpSocket,omitempty"`
+	// HTTPGet passes when a GET to the guest returns a 2xx or 3xx status.
+	// +optional
+	HTTPGet *HTTPGetAction `json:"httpGet,omitempty"`
+	// AgentExec passes when the agent in the guest runs a command which exits 0.
+	// +optional
+	AgentExec *AgentExecAction `json:"agentExec,omitempty"`
+	// InitialDelaySeconds is how long after the VM is created before it is first probed.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+	// PeriodSeconds is how often the guest is probed.
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+	// TimeoutSeconds is how long a single probe may take.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// FailureThreshold is how many probes in a row must fail for the guest to be
+	// considered unhealthy.
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// TCPSocketAction probes a TCP port in the guest.
+type TCPSocketAction struct {
+	// Host is the address of one of the network interfaces of the guest.
+	// +kubebuilder:validation:Required
+	Host string `json:"host"`
+	// Port is the port to connect to.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+}
+
+// HTTPGetAction probes an HTTP endpoint in the guest.
+type HTTPGetAction struct {
+	// URL is the address to GET, eg http://10.0.0.10:8080/healthz. Its host
+	// must be the address of one of the network interfaces of the guest, and
+	// redirects are not followed.
+	// +kubebuilder:validation:Required
+	URL string `json:"url"`
+}
+
+// AgentExecAction asks an agent in the guest to run a command. Flintlock has no
+// exec API, so the command is sent over HTTP to an agent listening on the
+// network of the guest, the same as for a graceful shutdown.
+type AgentExecAction struct {
+	// AgentEndpoint is the base URL of the agent in the guest, eg
+	// http://10.0.0.10:8080. A POST is made to /exec on this address, so its
+	// host must be the address of one of the network interfaces.
+	// +kubebuilder:validation:Required
+	AgentEndpoint string `json:"agentEndpoint"`
+	// Command is the command line to run.
+	// +kubebuilder:validation:MinItems=1
+	Command []string `json:"command"`
+}
+
+// InterfaceIPPool names the MicrovmIPPool a network interface is given its
+// address from.
+type InterfaceIPPool struct {
+	// Interface is the guestDeviceName of the network interface.
+	// +kubebuilder:validation:Required
+	Interface string `json:"interface"`
+	// PoolRef is the MicrovmIPPool, in the same namespace, to allocate from.
+	// +kubebuilder:validation:Required
+	PoolRef corev1.LocalObjectReference `json:"poolRef"`
+}
+
+// DNSConfig configures the resolver of a guest. It is written as a
+// systemd-resolved drop-in on every boot.
+type DNSConfig struct {
+	// Nameservers are the IP addresses of the DNS servers the guest queries.
+	// +kubebuilder:validation:MaxItems=3
+	// +optional
+	Nameservers []string `json:"nameservers,omitempty"`
+	// Searches are the domains searched for names which are not fully
+	// qualified.
+	// +kubebuilder:validation:MaxItems=6
+	// +optional
+	Searches []string `json:"searches,omitempty"`
+}
+
+// VendorData is merged into the cloud-init vendor data generated for a
+// Microvm. Lists are added to what the operator generates, never replacing
+// it. It is only read when the VM is created.
+type VendorData struct {
+	// Packages are installed on first boot.
+	// +optional
+	Packages []string `json:"packages,omitempty"`
+	// RunCommands are run late in the first boot, after the packages are
+	// installed.
+	// +optional
+	RunCommands []string `json:"runcmd,omitempty"`
+	// Timezone is the timezone of the guest, such as Europe/London.
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+	// NTPServers are the servers the guest keeps its clock in sync with.
+	// +optional
+	NTPServers []string `json:"ntpServers,omitempty"`
+}
+
+// SSHService is a Service for the SSH port of a guest. It has no selector, and
+// its endpoints are the address of one of the network interfaces of the guest.
+type SSHService struct {
+	// Port is the port sshd listens on in the guest, which the Service also
+	// exposes.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=22
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// Interface is the guest device name of the network interface whose
+	// address the Service points at. Defaults to the first interface with a
+	// static address or one from an IP pool. Guests which only use DHCP are
+	// not given a Service, as the operator never learns their address.
+	// +optional
+	Interface string `json:"interface,omitempty"`
+}
+
+// GracefulShutdown configures how the guest is asked to shut down before the
+// Microvm is deleted. Flintlock has no power management API, so the request is
+// made to an agent running in the guest. If the request fails, or the guest has
+// not finished within the grace period, the Microvm is deleted regardless.
+type GracefulShutdown struct {
+	// AgentEndpoint is the base URL of the agent in the guest, eg
+	// http://10.0.0.10:8080. A POST is made to /shutdown on this address, so
+	// its host must be the address of one of the network interfaces.
+	// +kubebuilder:validation:Required
+	AgentEndpoint string `json:"agentEndpoint"`
+	// GracePeriodSeconds is how long the guest is given to shut down before the
+	// Microvm is deleted.
+	// +kubebuilder:default=30
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	GracePeriodSeconds int32 `json:"gracePeriodSeconds,omitempty"`
+}
+
+// ReconcileIntervals are how long a Microvm waits between reconciles while its
+// VM is in each state. Longer intervals suit images which are slow to boot,
+// and shorter ones notice changes on the host sooner. Intervals shorter than
+// the minimum the operator is configured with are raised to it.
+type ReconcileIntervals struct {
+	// PendingPollSeconds is how often the VM is checked while it is waiting to
+	// be created, is pending on its host, or is waiting for its readiness gate.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PendingPollSeconds int32 `json:"pendingPollSeconds,omitempty"`
+	// RunningResyncSeconds is how often the VM is checked once it has been
+	// created. When unset it is only checked when the Microvm changes, or
+	// when the operator resyncs every object.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RunningResyncSeconds int32 `json:"runningResyncSeconds,omitempty"`
+	// DeletingPollSeconds is how often the VM is checked while it is being
+	// deleted, whether the Microvm is being deleted or its VM recreated.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	DeletingPollSeconds int32 `json:"deletingPollSeconds,omitempty"`
+}
+
+// GuestAgent is an agent in the guest which serves the same HTTP API as the
+// agent used for graceful shutdowns and exec probes. Flintlock cannot give a
+// VM a vsock device, so the agent is reached over the network, or through a
+// proxy on the host which forwards to its vsock.
+type GuestAgent struct {
+	// Endpoint is the base URL of the agent, eg http://10.0.0.10:8080. A GET is
+	// made to /info and commands are POSTed to /exec on this address.
+	// +kubebuilder:validation:Required
+	Endpoint string `json:"endpoint"`
+	// PeriodSeconds is how often the guest is asked about.
+	// +kubebuilder:default=60
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+	// Commands are run in the guest each time it is asked about, and their
+	// exit codes and the start of their output are recorded.
+	// +kubebuilder:validation:MaxItems=8
+	// +optional
+	Commands []GuestCommand `json:"commands,omitempty"`
+}
+
+// GuestCommand is a command the guest agent runs in the guest.
+type GuestCommand struct {
+	// Name identifies the result of the command.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Command is the command and its arguments. It is not run in a shell.
+	// +kubebuilder:validation:MinItems=1
+	Command []string `json:"command"`
+	// TimeoutSeconds is how long the command may run before the agent kills it.
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=60
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// CloudInitState is how far cloud-init has got in a guest.
+type CloudInitState string
+
+const (
+	// CloudInitRunning is reported while cloud-init has not finished.
+	CloudInitRunning CloudInitState = "running"
+	// CloudInitDone is reported once cloud-init has finished without errors.
+	CloudInitDone CloudInitState = "done"
+	// CloudInitError is reported once cloud-init has finished with errors.
+	CloudInitError CloudInitState = "error"
+	// CloudInitDisabled is reported when cloud-init does not run in the guest.
+	CloudInitDisabled CloudInitState = "disabled"
+)
+
+// GuestInfo is what the guest agent last reported about a guest.
+type GuestInfo struct {
+	// LastUpdateTime is when the guest agent last answered.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+	// Hostname is the hostname of the guest.
+	// +optional
+	Hostname string `json:"hostname,omitempty"`
+	// KernelVersion is the release of the kernel the guest is running.
+	// +optional
+	KernelVersion string `json:"kernelVersion,omitempty"`
+	// OSImage is the name of the operating system of the guest.
+	// +optional
+	OSImage string `json:"osImage,omitempty"`
+	// CloudInit is how far cloud-init has got.
+	// +kubebuilder:validation:Enum=running;done;error;disabled
+	// +optional
+	CloudInit CloudInitState `json:"cloudInit,omitempty"`
+	// CloudInitErrors are the errors cloud-init reported.
+	// +optional
+	CloudInitErrors []string `json:"cloudInitErrors,omitempty"`
+	// Commands are the results of the commands of the guest agent.
+	// +optional
+	Commands []GuestCommandResult `json:"commands,omitempty"`
+}
+
+// DryRunStatus is what the last reconcile in dry-run mode would have done.
+type DryRunStatus struct {
+	// ObservedGeneration is the generation of the object the actions were
+	// planned for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Actions are the calls to flintlock and the changes to child objects the
+	// reconcile would have made, in the order it would have made them.
+	// +optional
+	// +kubebuilder:validation:MaxItems=50
+	Actions []PlannedAction `json:"actions,omitempty"`
+	// Omitted is how many more actions there were than are listed.
+	// +optional
+	Omitted int32 `json:"omitted,omitempty"`
+}
+
+// PlannedAction is a call to flintlock or a change to a child object which a
+// reconcile in dry-run mode did not make.
+type PlannedAction struct {
+	// Verb is what would have been done, such as CreateMicroVM or Delete.
+	Verb string `json:"verb"`
+	// Target is what it would have been done to: the VM on its host, or a
+	// child object as Kind/name.
+	Target string `json:"target"`
+	// Reason is why it would have been done, when that is known.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// PhoneHomeStatus is the callback a guest makes to the phone-home server of
+// the operator once cloud-init has finished.
+type PhoneHomeStatus struct {
+	// TokenHash is the SHA-256 of the one-time token the current VM was given
+	// to call back with. It is cleared once the guest has called back.
+	// +optional
+	TokenHash string `json:"tokenHash,omitempty"`
+	// CalledAt is when the guest called back.
+	// +optional
+	CalledAt *metav1.Time `json:"calledAt,omitempty"`
+	// InstanceID is the cloud-init instance ID the guest reported.
+	// +optional
+	InstanceID string `json:"instanceID,omitempty"`
+	// Hostname is the hostname the guest reported.
+	// +optional
+	Hostname string `json:"hostname,omitempty"`
+	// FQDN is the fully qualified domain name the guest reported.
+	// +optional
+	FQDN string `json:"fqdn,omitempty"`
+}
+
+// GuestCommandResult is the result of a command run by the guest agent.
+type GuestCommandResult struct {
+	// Name is the name of the command.
+	Name string `json:"name"`
+	// ExitCode is the exit code of the command.
+	// +optional
+	ExitCode int32 `json:"exitCode"`
+	// Output is the start of what the command wrote to stdout and stderr.
+	// +optional
+	Output string `json:"output,omitempty"`
+	// Error is why the command could not be run, in which case there is no
+	// exit code.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// GuestHealthStatus records the liveness probes of a Microvm's guest.
+type GuestHealthStatus struct {
+	// LastProbeTime is when the guest was last probed.
+	// +optional
+	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
+	// ConsecutiveFailures is how many probes in a row have failed.
+	// +optional
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+	// Restarts is how many times the VM has been recreated after failing its
+	// liveness probe.
+	// +optional
+	Restarts int32 `json:"restarts,omitempty"`
+}
+
+// ExternalResourceRef is a resource outside flintlock, such as a Service, DNS
+// record or IP allocation, which was created for a Microvm and is released when
+// the Microvm is deleted.
+type ExternalResourceRef struct {
+	// Kind is the kind of resource, which decides how it is released, eg Service.
+	// +kubebuilder:validation:Required
+	Kind string `json:"kind"`
+	// Name identifies the resource to whatever releases it.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+// ProvisioningTimestamps records when a Microvm first reached each phase of
+// being provisioned. A phase is only ever recorded once.
+type ProvisioningTimestamps struct {
+	// AcceptedAt is when the controller first reconciled the Microvm.
+	// +optional
+	AcceptedAt *metav1.Time `json:"acceptedAt,omitempty"`
+	// CreateSentAt is when flintlock accepted the request to create the VM.
+	// +optional
+	CreateSentAt *metav1.Time `json:"createSentAt,omitempty"`
+	// PendingAt is when flintlock was first seen to report the VM as PENDING.
+	// +optional
+	PendingAt *metav1.Time `json:"pendingAt,omitempty"`
+	// CreatedAt is when flintlock was first seen to report the VM as CREATED.
+	// +optional
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+	// GuestReadyAt is when the Microvm was first marked ready.
+	// +optional
+	GuestReadyAt *metav1.Time `json:"guestReadyAt,omitempty"`
+	// BootDuration is how long the guest took to become ready after flintlock
+	// reported the VM as CREATED.
+	// +optional
+	BootDuration *metav1.Duration `json:"bootDuration,omitempty"`
+	// ProvisioningDuration is how long the Microvm took to become ready after
+	// the controller accepted it.
+	// +optional
+	ProvisioningDuration *metav1.Duration `json:"provisioningDuration,omitempty"`
 }
 
 // MicrovmStatus defines the observed state of Microvm
@@ -160,6 +749,69 @@ type MicrovmStatus struct {
 	// controller's output.
 	// +optional
 	FailureMessage *string `json:"failureMessage,omitempty"`
+	// Provisioning records when the Microvm reached each phase of being
+	// provisioned.
+	// +optional
+	Provisioning *ProvisioningTimestamps `json:"provisioning,omitempty"`
+	// GuestHealth is the result of probing the guest with the liveness probe.
+	// +optional
+	GuestHealth *GuestHealthStatus `json:"guestHealth,omitempty"`
+	// GuestInfo is what the guest agent last reported about the guest.
+	// +optional
+	GuestInfo *GuestInfo `json:"guestInfo,omitempty"`
+	// PhoneHome records the one-time token the guest was given to call back
+	// to the operator with once it has booted, and what it reported when it
+	// did.
+	// +optional
+	PhoneHome *PhoneHomeStatus `json:"phoneHome,omitempty"`
+	// ExternalResources are the resources outside flintlock which were created
+	// for the Microvm. The finalizer is not removed until all of them have been
+	// released.
+	// +optional
+	ExternalResources []ExternalResourceRef `json:"externalResources,omitempty"`
+	// ShutdownRequestedAt is when the guest was asked to shut down ahead of deletion.
+	// +optional
+	ShutdownRequestedAt *metav1.Time `json:"shutdownRequestedAt,omitempty"`
+	// DeletionStartedAt is when the VM was first seen deleting on its host, so
+	// that a delete which never finishes can be told apart from a slow one.
+	// +optional
+	DeletionStartedAt *metav1.Time `json:"deletionStartedAt,omitempty"`
+	// PreviousProviderID is the provider ID of the VM this one replaced, when
+	// the VM was last recreated to apply a change to its spec or to restart
+	// it. The new VM is given a new provider ID by its host.
+	// +optional
+	PreviousProviderID string `json:"previousProviderID,omitempty"`
+	// RestartedAt is the value of the restartedAt annotation when the VM was
+	// created, so that a new value can be told apart.
+	// +optional
+	RestartedAt string `json:"restartedAt,omitempty"`
+	// ResolvedImages are the digests the images given by tag were resolved to
+	// when the ImagePolicy is Resolve, as image@digest by image. The VM is
+	// created from the digests, and later resolutions of the same tag are
+	// ignored while the image is unchanged.
+	// +optional
+	ResolvedImages map[string]string `json:"resolvedImages,omitempty"`
+	// HostAddress is the address the host endpoint resolved to when the host
+	// last answered a call. The endpoint is resolved again on every connection,
+	// so it shows which of the addresses of a DNS name is in use.
+	// +optional
+	HostAddress string `json:"hostAddress,omitempty"`
+	// SSHServiceName is the name of the Service pointing at the SSH port of
+	// the guest, once it has been created.
+	// +optional
+	SSHServiceName string `json:"sshServiceName,omitempty"`
+	// Phase is a summary of the conditions of the Microvm: one of Provisioning,
+	// Running, Failed or Deleting.
+	// +optional
+	Phase Phase `json:"phase,omitempty"`
+	// ObservedGeneration is the most recent generation of the Microvm spec
+	// which the controller has processed successfully.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// DryRun is what the last reconcile would have done, while the Microvm is
+	// reconciled in dry-run mode.
+	// +optional
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
 	// Conditions defines current service state of the Microvm.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
@@ -167,6 +819,14 @@ type MicrovmStatus struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:resource:categories=liquidmetal,shortName=mvm
+//+kubebuilder:printcolumn:name="Host",type="string",JSONPath=".spec.host.endpoint",description="Flintlock host the microvm is placed on"
+//+kubebuilder:printcolumn:name="VMState",type="string",JSONPath=".status.vmState",description="State of the microvm on the host"
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Phase of the Microvm"
+//+kubebuilder:printcolumn:name="ProviderID",type="string",JSONPath=".spec.providerID"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Microvm is the Schema for the microvms API
 type Microvm struct {
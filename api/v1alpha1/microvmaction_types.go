@@ -0,0 +1,126 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// MicrovmActionOperation is the call a MicrovmAction records.
+type MicrovmActionOperation string
+
+const (
+	// MicrovmActionCreate is a CreateMicroVM call.
+	MicrovmActionCreate MicrovmActionOperation = "Create"
+	// MicrovmActionDelete is a DeleteMicroVM call.
+	MicrovmActionDelete MicrovmActionOperation = "Delete"
+)
+
+// MicrovmActionOutcome is how a call a MicrovmAction records ended.
+type MicrovmActionOutcome string
+
+const (
+	// MicrovmActionSucceeded means the host accepted the call.
+	MicrovmActionSucceeded MicrovmActionOutcome = "Succeeded"
+	// MicrovmActionFailed means the call returned an error.
+	MicrovmActionFailed MicrovmActionOutcome = "Failed"
+)
+
+// MicrovmActionSpec is a call which changed, or tried to change, the VMs of a
+// host.
+type MicrovmActionSpec struct {
+	// Host is the endpoint of the host the call was sent to.
+	Host string `json:"host"`
+	// Operation is the call which was made.
+	// +kubebuilder:validation:Enum=Create;Delete
+	Operation MicrovmActionOperation `json:"operation"`
+	// RequestDigest is the sha256 digest of the request sent to the host, so
+	// that the exact request can be matched against the logs of the host.
+	RequestDigest string `json:"requestDigest"`
+	// VMUID is the UID of the VM on the host: the one deleted, or the one
+	// created when the create succeeded.
+	// +optional
+	VMUID string `json:"vmUID,omitempty"`
+	// Controller is the controller of the operator which made the call.
+	// +optional
+	Controller string `json:"controller,omitempty"`
+	// Object is the object being reconciled when the call was made.
+	// +optional
+	Object *MicrovmActionObject `json:"object,omitempty"`
+	// ReconcileUID is unique to the reconcile which made the call.
+	// +optional
+	ReconcileUID types.UID `json:"reconcileUID,omitempty"`
+	// OperatorVersion is the version of the operator which made the call.
+	// +optional
+	OperatorVersion string `json:"operatorVersion,omitempty"`
+	// RequestedAt is when the call was sent.
+	RequestedAt metav1.Time `json:"requestedAt"`
+	// CompletedAt is when the call returned.
+	CompletedAt metav1.Time `json:"completedAt"`
+	// Outcome is how the call ended.
+	// +kubebuilder:validation:Enum=Succeeded;Failed
+	Outcome MicrovmActionOutcome `json:"outcome"`
+	// Error is the error the call returned, if it failed.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// MicrovmActionObject identifies the object a call was made for.
+type MicrovmActionObject struct {
+	// Namespace is the namespace of the object, if it has one.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the object.
+	Name string `json:"name"`
+	// UID is the UID of the object.
+	// +optional
+	UID types.UID `json:"uid,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster,categories=liquidmetal,shortName=mvma
+//+kubebuilder:printcolumn:name="Host",type="string",JSONPath=".spec.host"
+//+kubebuilder:printcolumn:name="Operation",type="string",JSONPath=".spec.operation"
+//+kubebuilder:printcolumn:name="Namespace",type="string",JSONPath=".spec.object.namespace"
+//+kubebuilder:printcolumn:name="Object",type="string",JSONPath=".spec.object.name"
+//+kubebuilder:printcolumn:name="Outcome",type="string",JSONPath=".spec.outcome"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MicrovmAction is the Schema for the microvmactions API. Each records a
+// single Create or Delete sent to a flintlock host, as an audit trail of the
+// changes made to the VMs of every host. They are only ever created by the
+// operator, and are never changed afterwards.
+type MicrovmAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MicrovmActionSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MicrovmActionList contains a list of MicrovmAction
+type MicrovmActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MicrovmAction `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MicrovmAction{}, &MicrovmActionList{})
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// MetricSourceType is the type of metric used to drive a MicrovmAutoscaler.
+type MetricSourceType string
+
+const (
+	// PrometheusMetricSourceType scales on the result of a Prometheus query.
+	PrometheusMetricSourceType MetricSourceType = "Prometheus"
+	// DensityMetricSourceType scales on the number of Microvms running on each host.
+	DensityMetricSourceType MetricSourceType = "Density"
+)
+
+// ScalingPolicyType is the type of a ScalingPolicy.
+type ScalingPolicyType string
+
+const (
+	// ReplicasScalingPolicy limits the change to an absolute number of replicas.
+	ReplicasScalingPolicy ScalingPolicyType = "Replicas"
+	// PercentScalingPolicy limits the change to a percentage of the current replicas.
+	PercentScalingPolicy ScalingPolicyType = "Percent"
+)
+
+// ScalingPolicySelect decides which policy is used when several apply.
+type ScalingPolicySelect string
+
+const (
+	// MaxPolicySelect selects the policy allowing the largest change.
+	MaxPolicySelect ScalingPolicySelect = "Max"
+	// MinPolicySelect selects the policy allowing the smallest change.
+	MinPolicySelect ScalingPolicySelect = "Min"
+	// DisabledPolicySelect disables scaling in this direction.
+	DisabledPolicySelect ScalingPolicySelect = "Disabled"
+)
+
+// MicrovmAutoscalerSpec defines the desired state of MicrovmAutoscaler
+type MicrovmAutoscalerSpec struct {
+	// ScaleTargetRef is the name of the MicrovmDeployment, in the same namespace,
+	// whose Replicas will be managed.
+	// +kubebuilder:validation:Required
+	ScaleTargetRef corev1.LocalObjectReference `json:"scaleTargetRef"`
+	// MinReplicas is the lower limit for the Replicas of the target.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas is the upper limit for the Replicas of the target.
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+	// Metric is the source used to calculate the desired number of replicas.
+	// +kubebuilder:validation:Required
+	Metric MetricSource `json:"metric"`
+	// Behavior configures how quickly the target is scaled up and down.
+	// +optional
+	Behavior *AutoscalerBehavior `json:"behavior,omitempty"`
+}
+
+// MetricSource describes where the autoscaler reads its metric from.
+type MetricSource struct {
+	// Type is the type of metric source.
+	// +kubebuilder:validation:Enum=Prometheus;Density
+	Type MetricSourceType `json:"type"`
+	// Prometheus is used when Type is Prometheus.
+	// +optional
+	Prometheus *PrometheusMetricSource `json:"prometheus,omitempty"`
+	// Density is used when Type is Density.
+	// +optional
+	Density *DensityMetricSource `json:"density,omitempty"`
+}
+
+// PrometheusMetricSource scales on an instant query against a Prometheus server.
+// The desired replica count is calculated as ceil(current * value / target),
+// in the same way as a HorizontalPodAutoscaler.
+type PrometheusMetricSource struct {
+	// Address is the base URL of the Prometheus server, eg http://prometheus:9090.
+	// +kubebuilder:validation:Required
+	Address string `json:"address"`
+	// Query is a PromQL expression which must return a single scalar or sample.
+	// +kubebuilder:validation:Required
+	Query string `json:"query"`
+	// TargetValue is the value of the query the autoscaler aims to maintain.
+	// +kubebuilder:validation:Required
+	TargetValue resource.Quantity `json:"targetValue"`
+}
+
+// DensityMetricSource scales so that the average number of Microvms on each of
+// the target's hosts, across all owners, approaches TargetPerHost.
+type DensityMetricSource struct {
+	// TargetPerHost is the desired number of Microvms on each host.
+	// +kubebuilder:validation:Minimum=1
+	TargetPerHost int32 `json:"targetPerHost"`
+}
+
+// AutoscalerBehavior configures scaling in each direction.
+type AutoscalerBehavior struct {
+	// ScaleUp is the behaviour when increasing replicas. Defaults to no
+	// stabilization window and allowing the replicas to double, or grow by 4,
+	// whichever is more, every 15 seconds.
+	// +optional
+	ScaleUp *ScalingRules `json:"scaleUp,omitempty"`
+	// ScaleDown is the behaviour when decreasing replicas. Defaults to a
+	// stabilization window of 300 seconds and allowing all surplus replicas to be
+	// removed at once.
+	// +optional
+	ScaleDown *ScalingRules `json:"scaleDown,omitempty"`
+}
+
+// ScalingRules configures the rate of scaling in one direction.
+type ScalingRules struct {
+	// StabilizationWindowSeconds is the number of seconds for which past
+	// recommendations are considered when scaling, to avoid flapping.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	StabilizationWindowSeconds *int32 `json:"stabilizationWindowSeconds,omitempty"`
+	// SelectPolicy decides which policy is used when several apply.
+	// +kubebuilder:validation:Enum=Max;Min;Disabled
+	// +optional
+	SelectPolicy *ScalingPolicySelect `json:"selectPolicy,omitempty"`
+	// Policies limit how much the replicas may change over a period.
+	// +optional
+	Policies []ScalingPolicy `json:"policies,omitempty"`
+}
+
+// ScalingPolicy limits a single change in replicas over a period.
+type ScalingPolicy struct {
+	// Type is the type of limit.
+	// +kubebuilder:validation:Enum=Replicas;Percent
+	Type ScalingPolicyType `json:"type"`
+	// Value is the amount of change permitted by the policy.
+	// +kubebuilder:validation:Minimum=1
+	Value int32 `json:"value"`
+	// PeriodSeconds is the window over which the policy is applied.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1800
+	PeriodSeconds int32 `json:"periodSeconds"`
+}
+
+// ScaleEvent records a change made by the autoscaler.
+type ScaleEvent struct {
+	// Time is when the change was made.
+	Time metav1.Time `json:"time"`
+	// From is the number of replicas before the change.
+	From int32 `json:"from"`
+	// To is the number of replicas after the change.
+	To int32 `json:"to"`
+}
+
+// MicrovmAutoscalerStatus defines the observed state of MicrovmAutoscaler
+type MicrovmAutoscalerStatus struct {
+	// CurrentReplicas is the Replicas last seen on the target.
+	// +optional
+	CurrentReplicas int32 `json:"currentReplicas"`
+	// DesiredReplicas is the Replicas last calculated for the target.
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas"`
+	// CurrentMetricValue is the last value read from the metric source.
+	// +optional
+	CurrentMetricValue *resource.Quantity `json:"currentMetricValue,omitempty"`
+	// LastScaleTime is the last time the autoscaler changed the target.
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+	// ScaleEvents holds the recent changes made by the autoscaler, which are
+	// used to enforce the scaling policies.
+	// +optional
+	ScaleEvents []ScaleEvent `json:"scaleEvents,omitempty"`
+	// Conditions defines current service state of the MicrovmAutoscaler.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=liquidmetal,shortName=mvma
+//+kubebuilder:printcolumn:name="Target",type="string",JSONPath=".spec.scaleTargetRef.name"
+//+kubebuilder:printcolumn:name="Current",type="integer",JSONPath=".status.currentReplicas"
+//+kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".status.desiredReplicas"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MicrovmAutoscaler is the Schema for the microvmautoscalers API
+type MicrovmAutoscaler struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MicrovmAutoscalerSpec   `json:"spec,omitempty"`
+	Status MicrovmAutoscalerStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MicrovmAutoscalerList contains a list of MicrovmAutoscaler
+type MicrovmAutoscalerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MicrovmAutoscaler `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MicrovmAutoscaler{}, &MicrovmAutoscalerList{})
+}
+
+// GetConditions returns the observations of the operational state of the MicrovmAutoscaler resource.
+func (r *MicrovmAutoscaler) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MicrovmAutoscaler to the predescribed clusterv1.Conditions.
+func (r *MicrovmAutoscaler) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
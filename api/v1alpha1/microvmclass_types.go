@@ -0,0 +1,81 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MicrovmClassSpec defines the defaults a MicrovmClass gives the Microvms
+// which name it.
+type MicrovmClassSpec struct {
+	// VCPU is how many vcpus the Microvms are allocated.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	VCPU *int64 `json:"vcpu,omitempty"`
+	// MemoryMb is how much memory, in megabytes, the Microvms are allocated.
+	// +kubebuilder:validation:Minimum=1024
+	// +optional
+	MemoryMb *int64 `json:"memoryMb,omitempty"`
+	// Kernel is the kernel the Microvms boot.
+	// +optional
+	Kernel *microvm.ContainerFileSource `json:"kernel,omitempty"`
+	// KernelCmdLine are additional kernel arguments. They are added to those
+	// of each Microvm, which keeps its own value for any argument both set.
+	// +optional
+	KernelCmdLine map[string]string `json:"kernelCmdline,omitempty"`
+	// Initrd is the initial ramdisk the Microvms boot.
+	// +optional
+	Initrd *microvm.ContainerFileSource `json:"initrd,omitempty"`
+	// RootVolume is the root volume of the Microvms.
+	// +optional
+	RootVolume *microvm.Volume `json:"rootVolume,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster,categories=liquidmetal,shortName=mvmc
+//+kubebuilder:printcolumn:name="VCPU",type="integer",JSONPath=".spec.vcpu"
+//+kubebuilder:printcolumn:name="Memory",type="integer",JSONPath=".spec.memoryMb"
+//+kubebuilder:printcolumn:name="Kernel",type="string",JSONPath=".spec.kernel.image",priority=1
+//+kubebuilder:printcolumn:name="Root Volume",type="string",JSONPath=".spec.rootVolume.image",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MicrovmClass is the Schema for the microvmclasses API. It is a named flavour
+// of Microvm, curated by the platform team, whose kernel, initrd, root volume,
+// vcpu and memory are given to the Microvms and templates which name it in
+// their classRef when they are applied. A Microvm keeps any of them it sets
+// itself, and is not changed when the class is changed afterwards.
+type MicrovmClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MicrovmClassSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MicrovmClassList contains a list of MicrovmClass
+type MicrovmClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MicrovmClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MicrovmClass{}, &MicrovmClassList{})
+}
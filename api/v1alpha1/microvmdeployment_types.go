@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
@@ -26,6 +27,15 @@ const (
 	// MvDeploymentSFinalizer allows ReconcileMicrovmDeployment to clean up resources associated with the Deployment
 	// before removing it from the apiserver.
 	MvmDeploymentFinalizer = "microvmdeployment.infrastructure.microvm.x-k8s.io"
+
+	// MicrovmDeploymentNameLabel records the name of the MicrovmDeployment a
+	// MicrovmReplicaSet, and the Microvms it creates, were made for.
+	MicrovmDeploymentNameLabel = "infrastructure.liquid-metal.io/deployment-name"
+
+	// ForceHostRemovalAnnotation set to "true" lets a host be removed from the
+	// Hosts of a MicrovmDeployment while its replicaset there still has
+	// microvms, which are then deleted straight away.
+	ForceHostRemovalAnnotation = "infrastructure.liquid-metal.io/force-host-removal"
 )
 
 type HostMap map[string]struct{}
@@ -33,17 +43,239 @@ type HostMap map[string]struct{}
 // MicrovmDeploymentSpec defines the desired state of MicrovmDeployment
 type MicrovmDeploymentSpec struct {
 	// Replicas is the number of Microvms to create on the given Host with the given
-	// Microvm spec
+	// Microvm spec. When SpreadConstraints is set, Replicas is instead the total
+	// number of Microvms to distribute across all Hosts.
 	// +kubebuilder:default=1
 	Replicas *int32 `json:"replicas,omitempty"`
-	// Host sets the host device address for Microvm creation.
-	// +kubebuilder:validation:Required
+	// Host sets the host device address for Microvm creation. It is ignored when
+	// HostGroupRef or HostSelector is set.
+	// +optional
 	Hosts []microvm.Host `json:"hosts,omitempty"`
+	// HostSelector is a label query over MicrovmHosts, whose endpoints are used
+	// instead of Hosts. A MicrovmReplicaSet is created on each MicrovmHost as
+	// it comes to match, and removed once it no longer does. It is ignored when
+	// HostGroupRef is set.
+	// +optional
+	HostSelector *metav1.LabelSelector `json:"hostSelector,omitempty"`
+	// HostGroupRef is the name of a MicrovmHostGroup, in the same namespace,
+	// whose members are used instead of Hosts. Hosts joining or leaving the
+	// group are picked up without changing the deployment.
+	// +optional
+	HostGroupRef *corev1.LocalObjectReference `json:"hostGroupRef,omitempty"`
+	// SpreadConstraints balances the Replicas across the Hosts rather than
+	// creating Replicas Microvms on every Host.
+	// +optional
+	SpreadConstraints *SpreadConstraints `json:"spreadConstraints,omitempty"`
+	// PlacementOverrides pins replicas to particular Hosts, for workloads which
+	// need to run next to a device attached to one of them. The other replicas
+	// are placed by the SpreadConstraints around them. They are only used with
+	// SpreadConstraints, as every Host runs Replicas Microvms without them.
+	// +listType=map
+	// +listMapKey=replica
+	// +optional
+	PlacementOverrides []PlacementOverride `json:"placementOverrides,omitempty"`
+	// Selector is a label query over MicrovmReplicaSets and Microvms.
+	// MicrovmReplicaSets are listed with it rather than across the whole
+	// namespace, and it is passed on to each of them as their selector. It must
+	// match the labels of the template, which are copied onto the
+	// MicrovmReplicaSets.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 	// Template is the object that describes the Microvm that will be created if
 	// insufficient replicas are detected.
 	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/replicationcontroller#pod-template
+	// The template may vary between replicas in the same way as a MicrovmReplicaSet template.
+	// The labels and annotations of the template which do not vary are copied
+	// onto the MicrovmReplicaSets, along with labels recording the deployment,
+	// the template hash and the host.
 	// +optional
 	Template MicrovmTemplateSpec `json:"template,omitempty" protobuf:"bytes,3,opt,name=template"`
+	// TemplateRef is the name of a MicrovmTemplate, in the same namespace, to use
+	// instead of Template. The deployment waits until the MicrovmTemplate has
+	// been resolved from its source. Changes to the MicrovmTemplate are rolled
+	// out in the same way as changes to Template.
+	// +optional
+	TemplateRef *corev1.LocalObjectReference `json:"templateRef,omitempty"`
+	// FailoverPolicy opts in to recreating the replicas of a Host on the other
+	// Hosts when its MicrovmHost has been unreachable for too long. The
+	// MicrovmReplicaSet of the Host is removed once they are ready.
+	// +optional
+	FailoverPolicy *FailoverPolicy `json:"failoverPolicy,omitempty"`
+	// DeletePolicy is what happens to the Microvms when the deployment is
+	// deleted. With Foreground its replicasets are deleted along with their
+	// Microvms, and the deployment is only removed once flintlock has confirmed
+	// that every VM is gone. With Orphan its replicasets are deleted but their
+	// Microvms are released and left running.
+	// +kubebuilder:validation:Enum=Foreground;Orphan
+	// +kubebuilder:default=Foreground
+	// +optional
+	DeletePolicy DeletePolicy `json:"deletePolicy,omitempty"`
+	// Rollout controls how a changed template is rolled out to the Hosts which
+	// already run a MicrovmReplicaSet. When unset, the template is only used for
+	// MicrovmReplicaSets created from then on.
+	// +optional
+	Rollout *RolloutStrategy `json:"rollout,omitempty"`
+	// ProgressDeadlineSeconds is how long the deployment may go without a new
+	// Microvm becoming ready, while it is not yet complete, before its
+	// Progressing condition is set to false with ProgressDeadlineExceeded.
+	// The deployment keeps working towards its desired state regardless. When
+	// unset, progress is not tracked.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+}
+
+// PlacementOverride pins one replica of a MicrovmDeployment to a Host.
+type PlacementOverride struct {
+	// Replica is the index of the pinned replica, from 0 to Replicas-1. The
+	// override is ignored while the deployment is scaled to Replica or fewer
+	// replicas, so that the lowest indexes are kept when scaling in.
+	// +kubebuilder:validation:Minimum=0
+	Replica int32 `json:"replica"`
+	// Host is the endpoint of the Host the replica runs on. The replica is
+	// placed by the SpreadConstraints instead while the Host is not in the
+	// deployment, or is cordoned or has failed.
+	Host string `json:"host"`
+}
+
+// RolloutStrategy describes how a changed template is rolled out. A Host is
+// updated by creating a MicrovmReplicaSet with the new template alongside the
+// old one, which is only removed once every Host has been updated.
+type RolloutStrategy struct {
+	// Canary updates a single Host first, and only updates the others once it
+	// has been ready for the soak period. The rollout is rolled back if the
+	// updated MicrovmReplicaSets fail or are not ready in time.
+	Canary *CanaryRollout `json:"canary"`
+}
+
+// CanaryRollout describes the canary Host of a rollout.
+type CanaryRollout struct {
+	// Host is the endpoint of the Host which is updated first.
+	Host string `json:"host"`
+	// SoakSeconds is how long the canary must stay ready before the other
+	// Hosts are updated.
+	// +kubebuilder:default=300
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SoakSeconds int32 `json:"soakSeconds,omitempty"`
+	// ProgressDeadlineSeconds is how long the updated MicrovmReplicaSets of
+	// each step have to become ready before the rollout is rolled back.
+	// +kubebuilder:default=600
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ProgressDeadlineSeconds int32 `json:"progressDeadlineSeconds,omitempty"`
+}
+
+// RolloutPhase is the step a rollout has reached.
+type RolloutPhase string
+
+const (
+	// RolloutPhaseCanary means the canary Host is being updated or soaked.
+	RolloutPhaseCanary RolloutPhase = "Canary"
+	// RolloutPhaseProgressing means the remaining Hosts are being updated.
+	RolloutPhaseProgressing RolloutPhase = "Progressing"
+	// RolloutPhaseComplete means every Host runs the template.
+	RolloutPhaseComplete RolloutPhase = "Complete"
+	// RolloutPhaseRolledBack means the updated MicrovmReplicaSets were removed
+	// after a failure. The template is not rolled out again until it changes.
+	RolloutPhaseRolledBack RolloutPhase = "RolledBack"
+)
+
+// RolloutStatus records the progress of the latest rollout.
+type RolloutStatus struct {
+	// TemplateHash is the hash of the template being rolled out.
+	TemplateHash string `json:"templateHash"`
+	// Phase is the step the rollout has reached.
+	Phase RolloutPhase `json:"phase"`
+	// PhaseStartedAt is when the rollout reached its phase. The progress
+	// deadline runs from here.
+	// +optional
+	PhaseStartedAt *metav1.Time `json:"phaseStartedAt,omitempty"`
+	// CanaryReadyAt is when the canary became ready. The soak period runs from
+	// here.
+	// +optional
+	CanaryReadyAt *metav1.Time `json:"canaryReadyAt,omitempty"`
+	// Message describes why the rollout was rolled back.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ProgressStatus records the progress of a deployment towards its desired
+// state.
+type ProgressStatus struct {
+	// ReadyReplicas is the number of microvms of every replicaset of the
+	// deployment, including those of a rollout, which were ready when it last
+	// made progress.
+	ReadyReplicas int32 `json:"readyReplicas"`
+	// LastProgressTime is when more microvms last became ready. The progress
+	// deadline runs from here.
+	LastProgressTime metav1.Time `json:"lastProgressTime"`
+}
+
+// HostSummary is the observed state of a single host of a MicrovmDeployment.
+type HostSummary struct {
+	// Host is the endpoint of the host.
+	Host string `json:"host"`
+	// ReplicaSet is the name of the MicrovmReplicaSet on the host, or empty if
+	// one has not been created yet.
+	// +optional
+	ReplicaSet string `json:"replicaSet,omitempty"`
+	// Replicas is the number of microvms which have been created on the host.
+	// +optional
+	Replicas int32 `json:"replicas"`
+	// ReadyReplicas is the number of microvms on the host with a Ready Condition.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+	// Condition is the ready condition of the MicrovmReplicaSet.
+	// +optional
+	Condition *clusterv1.Condition `json:"condition,omitempty"`
+	// LastSuccessfulContact is when the host last answered a flintlock call
+	// made by the operator, to the minute. It is unset when the host has not
+	// been called since the operator started.
+	// +optional
+	LastSuccessfulContact *metav1.Time `json:"lastSuccessfulContact,omitempty"`
+}
+
+// FailoverPolicy describes when the replicas on an unreachable host are
+// recreated elsewhere.
+type FailoverPolicy struct {
+	// UnreachableSeconds is how long the MicrovmHost of a Host must have been
+	// unreachable before its replicas are recreated on the other Hosts. The
+	// Host is used again once it is reachable.
+	// +kubebuilder:default=300
+	// +kubebuilder:validation:Minimum=30
+	// +optional
+	UnreachableSeconds int32 `json:"unreachableSeconds,omitempty"`
+}
+
+// SpreadTopology is the domain replicas are spread across.
+type SpreadTopology string
+
+const (
+	// HostSpreadTopology spreads replicas across the hosts.
+	HostSpreadTopology SpreadTopology = "Host"
+	// FailureDomainSpreadTopology spreads replicas across the failure domains
+	// of the hosts, and then across the hosts within each failure domain.
+	FailureDomainSpreadTopology SpreadTopology = "FailureDomain"
+)
+
+// SpreadConstraints describes how the replicas of a MicrovmDeployment are
+// distributed across its hosts.
+type SpreadConstraints struct {
+	// MaxSkew is the largest permitted difference between the number of Microvms
+	// in the most and least loaded domains. Replicas are only moved between
+	// domains, for example when a Host is added or removed, when this would be
+	// exceeded.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	MaxSkew int32 `json:"maxSkew"`
+	// TopologyKey is the domain replicas are spread across. FailureDomain uses
+	// the failure domain of the MicrovmHost with each Host endpoint; a Host
+	// without one is treated as a failure domain of its own.
+	// +kubebuilder:validation:Enum=Host;FailureDomain
+	// +kubebuilder:default=Host
+	// +optional
+	TopologyKey SpreadTopology `json:"topologyKey,omitempty"`
 }
 
 // MicrovmDeploymentStatus defines the observed state of MicrovmDeployment
@@ -61,6 +293,51 @@ type MicrovmDeploymentStatus struct {
 	// +optional
 	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
 
+	// Selector is the Selector of the spec in its string form, for the scale
+	// subresource. It is empty when the spec has no Selector.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// Phase is a summary of the conditions of the MicrovmDeployment: one of Provisioning,
+	// Running, Failed or Deleting.
+	// +optional
+	Phase Phase `json:"phase,omitempty"`
+
+	// ObservedGeneration is the most recent generation of the MicrovmDeployment spec
+	// which the controller has processed successfully.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// TemplateRevision is the revision of the MicrovmTemplate of TemplateRef
+	// which the deployment is rolling out.
+	// +optional
+	TemplateRevision int64 `json:"templateRevision,omitempty"`
+
+	// DryRun is what the last reconcile would have done, while the MicrovmDeployment is
+	// reconciled in dry-run mode.
+	// +optional
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// Rollout records the progress of the latest rollout of a changed template.
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+
+	// Progress records the progress of the deployment towards its desired
+	// state while it is incomplete. It is only kept when ProgressDeadlineSeconds
+	// is set.
+	// +optional
+	Progress *ProgressStatus `json:"progress,omitempty"`
+
+	// HostSummaries is the state of each host of the deployment and of the
+	// MicrovmReplicaSet on it, ordered by host endpoint.
+	// +optional
+	HostSummaries []HostSummary `json:"hostSummaries,omitempty"`
+
+	// NextHost is the endpoint of the host which will receive the next
+	// MicrovmReplicaSet, if one is still to be created.
+	// +optional
+	NextHost string `json:"nextHost,omitempty"`
+
 	// Represents the latest available observations of a deployments's current state.
 	// +optional
 	// +patchMergeKey=type
@@ -70,6 +347,13 @@ type MicrovmDeploymentStatus struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+//+kubebuilder:resource:categories=liquidmetal,shortName=mvmd
+//+kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".spec.replicas",description="Number of desired microvms"
+//+kubebuilder:printcolumn:name="Created",type="integer",JSONPath=".status.replicas",description="Number of created microvms"
+//+kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas",description="Number of ready microvms"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Phase of the MicrovmDeployment"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // MicrovmDeployment is the Schema for the microvmdeployments API
 type MicrovmDeployment struct {
@@ -0,0 +1,220 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// QuarantinePolicy decides what happens when a host exhausts its error budget.
+type QuarantinePolicy string
+
+const (
+	// AutoQuarantinePolicy stops new Microvms being created on the host until
+	// its error budget recovers.
+	AutoQuarantinePolicy QuarantinePolicy = "Auto"
+	// NeverQuarantinePolicy only reports on the error budget.
+	NeverQuarantinePolicy QuarantinePolicy = "Never"
+)
+
+// IdentityVerification decides what happens when a host presents a different
+// identity to the one it was first seen with.
+type IdentityVerification string
+
+const (
+	// EnforceIdentityVerification refuses to connect to the host until its
+	// identity is trusted again.
+	EnforceIdentityVerification IdentityVerification = "Enforce"
+	// WarnIdentityVerification only reports on the identity change.
+	WarnIdentityVerification IdentityVerification = "Warn"
+	// DisabledIdentityVerification does not check the identity of the host.
+	DisabledIdentityVerification IdentityVerification = "Disabled"
+)
+
+// MicrovmHostSpec defines the desired state of MicrovmHost
+type MicrovmHostSpec struct {
+	// Endpoint is the flintlock address of the host. It is matched against the
+	// host endpoint of Microvms in every namespace.
+	// +kubebuilder:validation:Required
+	Endpoint string `json:"endpoint"`
+	// ErrorBudget configures the provisioning objective for the host.
+	// +optional
+	ErrorBudget ErrorBudget `json:"errorBudget,omitempty"`
+	// Quarantine decides whether the host is quarantined when its error budget
+	// is exhausted. A quarantined host is released again once enough failures
+	// have left the window for the budget to recover.
+	// +kubebuilder:validation:Enum=Auto;Never
+	// +kubebuilder:default=Auto
+	// +optional
+	Quarantine QuarantinePolicy `json:"quarantine,omitempty"`
+	// FailureDomain is the failure domain the host belongs to, eg a rack or a
+	// zone. It is used to spread the replicas of MicrovmDeployments.
+	// +optional
+	FailureDomain string `json:"failureDomain,omitempty"`
+	// Unschedulable cordons the host. MicrovmDeployments stop placing new
+	// replicasets on it, and recreate the replicas it runs on their other hosts
+	// before removing its replicasets, so that it can be drained for maintenance.
+	// +optional
+	Unschedulable bool `json:"unschedulable,omitempty"`
+	// Identity configures how the TLS identity of the host is verified.
+	// +optional
+	Identity HostIdentityPolicy `json:"identity,omitempty"`
+}
+
+// HostIdentityPolicy configures trust on first use for the certificate a host
+// serves. The certificate fingerprint seen on the first successful connection
+// is recorded, and any later change is treated as a possible hijack of the
+// endpoint.
+type HostIdentityPolicy struct {
+	// Verification decides whether an identity change is enforced, only
+	// reported, or not checked at all.
+	// +kubebuilder:validation:Enum=Enforce;Warn;Disabled
+	// +kubebuilder:default=Enforce
+	// +optional
+	Verification IdentityVerification `json:"verification,omitempty"`
+	// Fingerprint pins the SHA-256 fingerprint of the host certificate, eg
+	// sha256:<hex>. It takes precedence over the recorded identity, and is how
+	// a rotated certificate is trusted.
+	// +kubebuilder:validation:Pattern=`^sha256:[0-9a-f]{64}$`
+	// +optional
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// HostIdentity is the TLS identity presented by a host.
+type HostIdentity struct {
+	// Fingerprint is the SHA-256 fingerprint of the host certificate.
+	Fingerprint string `json:"fingerprint"`
+	// SPIFFEID is the SPIFFE ID in the host certificate, if it has one. It is
+	// recorded for information only; the fingerprint is what is verified.
+	// +optional
+	SPIFFEID string `json:"spiffeID,omitempty"`
+	// FirstSeen is when the identity was recorded.
+	FirstSeen metav1.Time `json:"firstSeen"`
+}
+
+// ErrorBudget is a service level objective for provisioning Microvms on a host,
+// measured over a rolling window.
+type ErrorBudget struct {
+	// Objective is the fraction of provisioning attempts which should succeed,
+	// eg 0.99. Defaults to 0.99.
+	// +optional
+	Objective *resource.Quantity `json:"objective,omitempty"`
+	// WindowSeconds is the length of the rolling window.
+	// +kubebuilder:default=3600
+	// +kubebuilder:validation:Minimum=60
+	// +kubebuilder:validation:Maximum=86400
+	// +optional
+	WindowSeconds int32 `json:"windowSeconds,omitempty"`
+	// MinAttempts is the number of attempts which must be seen in the window
+	// before the budget can be considered exhausted.
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinAttempts int32 `json:"minAttempts,omitempty"`
+}
+
+// ProvisioningBucket counts the provisioning outcomes for a slice of the window.
+type ProvisioningBucket struct {
+	// Start is the beginning of the slice.
+	Start metav1.Time `json:"start"`
+	// Successes is the number of Microvms which were created.
+	Successes int32 `json:"successes"`
+	// Failures is the number of Microvms which failed to be created.
+	Failures int32 `json:"failures"`
+}
+
+// MicrovmHostStatus defines the observed state of MicrovmHost
+type MicrovmHostStatus struct {
+	// Successes is the number of successful provisioning attempts in the window.
+	// +optional
+	Successes int32 `json:"successes"`
+	// Failures is the number of failed provisioning attempts in the window.
+	// +optional
+	Failures int32 `json:"failures"`
+	// SuccessRatio is the fraction of attempts in the window which succeeded.
+	// +optional
+	SuccessRatio *resource.Quantity `json:"successRatio,omitempty"`
+	// ErrorBudgetRemaining is the fraction of the error budget which has not
+	// been used in the window.
+	// +optional
+	ErrorBudgetRemaining *resource.Quantity `json:"errorBudgetRemaining,omitempty"`
+	// Quarantined is true when no new Microvms will be created on the host.
+	// +optional
+	Quarantined bool `json:"quarantined"`
+	// Identity is the trusted identity of the host.
+	// +optional
+	Identity *HostIdentity `json:"identity,omitempty"`
+	// Untrusted is true when the host presented an unexpected identity and no
+	// connections will be made to it.
+	// +optional
+	Untrusted bool `json:"untrusted"`
+	// UnreachableSince is when the host stopped answering. It is cleared once
+	// the host answers again.
+	// +optional
+	UnreachableSince *metav1.Time `json:"unreachableSince,omitempty"`
+	// Buckets hold the outcomes which make up the window.
+	// +optional
+	Buckets []ProvisioningBucket `json:"buckets,omitempty"`
+	// Conditions defines current service state of the MicrovmHost.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster,categories=liquidmetal,shortName=mvmh
+//+kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=".spec.endpoint"
+//+kubebuilder:printcolumn:name="Unschedulable",type="boolean",JSONPath=".spec.unschedulable"
+//+kubebuilder:printcolumn:name="Quarantined",type="boolean",JSONPath=".status.quarantined"
+//+kubebuilder:printcolumn:name="Untrusted",type="boolean",JSONPath=".status.untrusted"
+//+kubebuilder:printcolumn:name="Unreachable Since",type="date",JSONPath=".status.unreachableSince"
+//+kubebuilder:printcolumn:name="Budget",type="string",JSONPath=".status.errorBudgetRemaining",description="Remaining share of the error budget"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MicrovmHost is the Schema for the microvmhosts API
+type MicrovmHost struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MicrovmHostSpec   `json:"spec,omitempty"`
+	Status MicrovmHostStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MicrovmHostList contains a list of MicrovmHost
+type MicrovmHostList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MicrovmHost `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MicrovmHost{}, &MicrovmHostList{})
+}
+
+// GetConditions returns the observations of the operational state of the MicrovmHost resource.
+func (r *MicrovmHost) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MicrovmHost to the predescribed clusterv1.Conditions.
+func (r *MicrovmHost) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
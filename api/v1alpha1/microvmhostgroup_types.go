@@ -0,0 +1,122 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// MicrovmHostGroupSpec defines the desired state of MicrovmHostGroup
+type MicrovmHostGroupSpec struct {
+	// Hosts are members of the group which are listed explicitly.
+	// +optional
+	Hosts []microvm.Host `json:"hosts,omitempty"`
+	// Selector is a label query over MicrovmHosts. Every MicrovmHost it matches
+	// is a member of the group, so hosts join and leave the group by being
+	// labelled.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// TLSSecretRef is the name of a secret, in the same namespace, with the TLS
+	// cert information for connecting to the hosts of the group. It is used by
+	// Microvms created through the group which do not set their own.
+	// +optional
+	TLSSecretRef string `json:"tlsSecretRef,omitempty"`
+	// BasicAuthSecret is the name of a secret, in the same namespace, with the
+	// basic auth token for the hosts of the group. It is used by Microvms created
+	// through the group which do not set their own.
+	// +optional
+	BasicAuthSecret string `json:"basicAuthSecret,omitempty"`
+	// Labels are added to Microvms created through the group. Labels set on the
+	// template of a MicrovmDeployment take precedence.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Capacity limits how many Microvms are placed on each host of the group.
+	// +optional
+	Capacity HostCapacity `json:"capacity,omitempty"`
+}
+
+// HostCapacity limits the Microvms placed on each host of a group.
+type HostCapacity struct {
+	// MaxMicrovmsPerHost is the number of Microvms, from any namespace, at
+	// which a host is full. MicrovmDeployments do not place a new replicaset on
+	// a full host, but keep the replicasets they already run there. Unlimited
+	// when unset.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxMicrovmsPerHost *int32 `json:"maxMicrovmsPerHost,omitempty"`
+}
+
+// MicrovmHostGroupStatus defines the observed state of MicrovmHostGroup
+type MicrovmHostGroupStatus struct {
+	// Ready is true when the members of the group have been resolved.
+	// +optional
+	// +kubebuilder:default=false
+	Ready bool `json:"ready"`
+	// Hosts are the members of the group, sorted by endpoint.
+	// +optional
+	Hosts []microvm.Host `json:"hosts,omitempty"`
+	// FullHosts are the endpoints of the members which are at capacity.
+	// +optional
+	FullHosts []string `json:"fullHosts,omitempty"`
+	// ObservedGeneration is the most recent generation of the MicrovmHostGroup
+	// spec which the controller has processed successfully.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions defines current service state of the MicrovmHostGroup.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=liquidmetal,shortName=mvmhg
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MicrovmHostGroup is the Schema for the microvmhostgroups API
+type MicrovmHostGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MicrovmHostGroupSpec   `json:"spec,omitempty"`
+	Status MicrovmHostGroupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MicrovmHostGroupList contains a list of MicrovmHostGroup
+type MicrovmHostGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MicrovmHostGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MicrovmHostGroup{}, &MicrovmHostGroupList{})
+}
+
+// GetConditions returns the observations of the operational state of the MicrovmHostGroup resource.
+func (r *MicrovmHostGroup) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MicrovmHostGroup to the predescribed clusterv1.Conditions.
+func (r *MicrovmHostGroup) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
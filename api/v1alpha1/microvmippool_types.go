@@ -0,0 +1,135 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// MicrovmIPPoolSpec defines the desired state of MicrovmIPPool.
+type MicrovmIPPoolSpec struct {
+	// Subnet is the network the addresses are in, in CIDR notation, eg
+	// 192.168.10.0/24. Its prefix length is given to the guest along with each
+	// address.
+	// +kubebuilder:validation:Required
+	Subnet string `json:"subnet"`
+	// Range limits the addresses allocated to part of the subnet. Without it
+	// every address of the subnet is allocated, other than the first and, for
+	// IPv4, the broadcast address.
+	// +optional
+	Range *IPRange `json:"range,omitempty"`
+	// Gateway is the default gateway of the guests. It must be in the subnet,
+	// and is never allocated.
+	// +optional
+	Gateway string `json:"gateway,omitempty"`
+	// Nameservers are the IP addresses of the DNS servers of the guests.
+	// +optional
+	Nameservers []string `json:"nameservers,omitempty"`
+	// Allocations are the addresses which have been allocated. They are written
+	// by the Microvm controller as it allocates addresses, and removed as the
+	// Microvms they were given to are deleted. They are kept in the spec rather
+	// than the status, as they cannot be observed again if they are lost.
+	// +listType=map
+	// +listMapKey=address
+	// +optional
+	Allocations []IPAllocation `json:"allocations,omitempty"`
+}
+
+// IPRange is a contiguous range of IP addresses.
+type IPRange struct {
+	// Start is the first address of the range, eg 192.168.10.100.
+	// +kubebuilder:validation:Required
+	Start string `json:"start"`
+	// End is the last address of the range, eg 192.168.10.199.
+	// +kubebuilder:validation:Required
+	End string `json:"end"`
+}
+
+// IPAllocation is an address of a MicrovmIPPool which has been given to a
+// network interface of a Microvm.
+type IPAllocation struct {
+	// Address is the allocated IP address.
+	Address string `json:"address"`
+	// Microvm is the name of the Microvm the address was given to.
+	Microvm string `json:"microvm"`
+	// UID is the UID of the Microvm the address was given to. The address is
+	// released once there is no longer a Microvm with this UID.
+	UID types.UID `json:"uid"`
+	// Interface is the guest device name of the network interface the address
+	// was given to.
+	Interface string `json:"interface"`
+}
+
+// MicrovmIPPoolStatus defines the observed state of MicrovmIPPool
+type MicrovmIPPoolStatus struct {
+	// Ready is true when the pool is valid and has addresses left to allocate.
+	// +optional
+	// +kubebuilder:default=false
+	Ready bool `json:"ready"`
+	// Size is how many addresses the pool holds.
+	// +optional
+	Size int64 `json:"size,omitempty"`
+	// Allocated is how many addresses have been allocated.
+	// +optional
+	Allocated int32 `json:"allocated,omitempty"`
+	// Conditions defines current service state of the MicrovmIPPool.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=liquidmetal,shortName=mvmip
+//+kubebuilder:printcolumn:name="Subnet",type="string",JSONPath=".spec.subnet"
+//+kubebuilder:printcolumn:name="Size",type="integer",JSONPath=".status.size"
+//+kubebuilder:printcolumn:name="Allocated",type="integer",JSONPath=".status.allocated"
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MicrovmIPPool is the Schema for the microvmippools API
+type MicrovmIPPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MicrovmIPPoolSpec   `json:"spec,omitempty"`
+	Status MicrovmIPPoolStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MicrovmIPPoolList contains a list of MicrovmIPPool
+type MicrovmIPPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MicrovmIPPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MicrovmIPPool{}, &MicrovmIPPoolList{})
+}
+
+// GetConditions returns the observations of the operational state of the MicrovmIPPool resource.
+func (r *MicrovmIPPool) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MicrovmIPPool to the predescribed clusterv1.Conditions.
+func (r *MicrovmIPPool) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// MicrovmMACPoolSpec defines the desired state of MicrovmMACPool. Exactly one
+// of Range or Addresses should be set.
+type MicrovmMACPoolSpec struct {
+	// Range is a contiguous range of MAC addresses to allocate from.
+	// +optional
+	Range *MACRange `json:"range,omitempty"`
+	// Addresses are the MAC addresses to allocate from.
+	// +optional
+	Addresses []string `json:"addresses,omitempty"`
+	// Allocations are the addresses which have been allocated. They are written
+	// by the Microvm controller as it allocates addresses, and removed as the
+	// Microvms they were given to are deleted. They are kept in the spec rather
+	// than the status, as they cannot be observed again if they are lost.
+	// +listType=map
+	// +listMapKey=address
+	// +optional
+	Allocations []MACAllocation `json:"allocations,omitempty"`
+}
+
+// MACRange is a contiguous range of MAC addresses.
+type MACRange struct {
+	// Start is the first address of the range, eg 02:00:00:00:00:00.
+	// +kubebuilder:validation:Required
+	Start string `json:"start"`
+	// End is the last address of the range, eg 02:00:00:00:ff:ff.
+	// +kubebuilder:validation:Required
+	End string `json:"end"`
+}
+
+// MACAllocation is an address of a MicrovmMACPool which has been given to a
+// network interface of a Microvm.
+type MACAllocation struct {
+	// Address is the allocated MAC address.
+	Address string `json:"address"`
+	// Microvm is the name of the Microvm the address was given to.
+	Microvm string `json:"microvm"`
+	// UID is the UID of the Microvm the address was given to. The address is
+	// released once there is no longer a Microvm with this UID.
+	UID types.UID `json:"uid"`
+	// Interface is the guest device name of the network interface the address
+	// was given to.
+	Interface string `json:"interface"`
+}
+
+// MicrovmMACPoolStatus defines the observed state of MicrovmMACPool
+type MicrovmMACPoolStatus struct {
+	// Ready is true when the pool is valid and has addresses left to allocate.
+	// +optional
+	// +kubebuilder:default=false
+	Ready bool `json:"ready"`
+	// Size is how many addresses the pool holds.
+	// +optional
+	Size int64 `json:"size,omitempty"`
+	// Allocated is how many addresses have been allocated.
+	// +optional
+	Allocated int32 `json:"allocated,omitempty"`
+	// Conditions defines current service state of the MicrovmMACPool.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=liquidmetal,shortName=mvmmac
+//+kubebuilder:printcolumn:name="Size",type="integer",JSONPath=".status.size"
+//+kubebuilder:printcolumn:name="Allocated",type="integer",JSONPath=".status.allocated"
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MicrovmMACPool is the Schema for the microvmmacpools API
+type MicrovmMACPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MicrovmMACPoolSpec   `json:"spec,omitempty"`
+	Status MicrovmMACPoolStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MicrovmMACPoolList contains a list of MicrovmMACPool
+type MicrovmMACPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MicrovmMACPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MicrovmMACPool{}, &MicrovmMACPoolList{})
+}
+
+// GetConditions returns the observations of the operational state of the MicrovmMACPool resource.
+func (r *MicrovmMACPool) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MicrovmMACPool to the predescribed clusterv1.Conditions.
+func (r *MicrovmMACPool) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// MicrovmQuotaSpec defines the desired state of MicrovmQuota
+type MicrovmQuotaSpec struct {
+	// Hard is the most the Microvms of the namespace may use between them. A
+	// Microvm which would take the namespace over any of the limits is refused
+	// on admission.
+	Hard QuotaLimits `json:"hard"`
+}
+
+// QuotaLimits are the limits of a MicrovmQuota. Each is unlimited when unset.
+type QuotaLimits struct {
+	// VCPU is the total number of vCPUs of the Microvms.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	VCPU *int64 `json:"vcpu,omitempty"`
+	// MemoryMb is the total memory of the Microvms, in megabytes.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MemoryMb *int64 `json:"memoryMb,omitempty"`
+	// Microvms is the number of Microvms.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Microvms *int32 `json:"microvms,omitempty"`
+}
+
+// QuotaUsage is what the Microvms of a namespace use between them.
+type QuotaUsage struct {
+	// VCPU is the total number of vCPUs of the Microvms.
+	VCPU int64 `json:"vcpu"`
+	// MemoryMb is the total memory of the Microvms, in megabytes.
+	MemoryMb int64 `json:"memoryMb"`
+	// Microvms is the number of Microvms.
+	Microvms int32 `json:"microvms"`
+}
+
+// MicrovmQuotaStatus defines the observed state of MicrovmQuota
+type MicrovmQuotaStatus struct {
+	// Ready is true when the Microvms of the namespace are within the quota.
+	// +optional
+	// +kubebuilder:default=false
+	Ready bool `json:"ready"`
+	// Used is what the Microvms of the namespace use between them, not counting
+	// those being deleted.
+	// +optional
+	Used QuotaUsage `json:"used,omitempty"`
+	// ObservedGeneration is the most recent generation of the MicrovmQuota
+	// spec which the controller has processed successfully.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions defines current service state of the MicrovmQuota.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=liquidmetal,shortName=mvmq
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready"
+//+kubebuilder:printcolumn:name="Microvms",type="integer",JSONPath=".status.used.microvms"
+//+kubebuilder:printcolumn:name="VCPU",type="integer",JSONPath=".status.used.vcpu"
+//+kubebuilder:printcolumn:name="Memory",type="integer",JSONPath=".status.used.memoryMb"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MicrovmQuota is the Schema for the microvmquotas API. It limits the
+// resources which the Microvms of its namespace may use, so that teams sharing
+// the same hosts cannot starve each other.
+type MicrovmQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MicrovmQuotaSpec   `json:"spec,omitempty"`
+	Status MicrovmQuotaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MicrovmQuotaList contains a list of MicrovmQuota
+type MicrovmQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MicrovmQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MicrovmQuota{}, &MicrovmQuotaList{})
+}
+
+// GetConditions returns the observations of the operational state of the MicrovmQuota resource.
+func (r *MicrovmQuota) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MicrovmQuota to the predescribed clusterv1.Conditions.
+func (r *MicrovmQuota) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
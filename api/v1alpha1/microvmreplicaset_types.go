@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
@@ -26,6 +27,37 @@ const (
 	// MvmRSFinalizer allows ReconcileMicrovmReplicaSet to clean up resources associated with the ReplicaSet
 	// before removing it from the apiserver.
 	MvmRSFinalizer = "microvmreplicaset.infrastructure.microvm.x-k8s.io"
+
+	// ReplicaIndexAnnotation records the index of a Microvm within its MicrovmReplicaSet.
+	ReplicaIndexAnnotation = "infrastructure.liquid-metal.io/replica-index"
+
+	// MicrovmReplicaSetNameLabel records the name of the MicrovmReplicaSet which
+	// controls a Microvm.
+	MicrovmReplicaSetNameLabel = "infrastructure.liquid-metal.io/replicaset-name"
+
+	// MicrovmReplicaSetHashLabel records a hash of the template a
+	// MicrovmReplicaSet, and the Microvms it creates, were made from.
+	MicrovmReplicaSetHashLabel = "infrastructure.liquid-metal.io/replicaset-hash"
+
+	// HostEndpointLabel records the flintlock endpoint of the host a
+	// MicrovmReplicaSet or Microvm was created for, with the characters a label
+	// value cannot hold replaced by dashes.
+	HostEndpointLabel = "infrastructure.liquid-metal.io/host-endpoint"
+
+	// PreemptedByAnnotation marks a MicrovmReplicaSet whose host is needed by a
+	// higher priority MicrovmDeployment, named as namespace/name. The
+	// MicrovmDeployment which controls it recreates its replicas on the other
+	// hosts and then removes it.
+	PreemptedByAnnotation = "infrastructure.liquid-metal.io/preempted-by"
+
+	// OwnershipAnnotation declares who manages a Microvm in a MicrovmReplicaSet
+	// or a MicrovmReplicaSet in a MicrovmDeployment.
+	OwnershipAnnotation = "infrastructure.liquid-metal.io/ownership"
+
+	// OwnershipExternal marks a child as managed outside the operator. It is
+	// counted towards its parent's replicas, but is never scaled or deleted by
+	// the controller, and is released rather than deleted with its parent.
+	OwnershipExternal = "External"
 )
 
 // MicrovmReplicaSetSpec defines the desired state of MicrovmReplicaSet
@@ -34,16 +66,102 @@ type MicrovmReplicaSetSpec struct {
 	// Microvm spec
 	// +kubebuilder:default=1
 	Replicas *int32 `json:"replicas,omitempty"`
-	// Host sets the host device address for Microvm creation.
-	// +kubebuilder:validation:Required
+	// Host sets the host device address for Microvm creation. It is ignored
+	// when Hosts is set.
+	// +optional
 	Host microvm.Host `json:"host,omitempty"`
+	// Hosts spreads the Microvms across several hosts instead of creating them
+	// all on Host. Each new Microvm is created on the host with the fewest
+	// Microvms of the replicaset, and the host each one was given is recorded
+	// in the status. When scaling in, Microvms on hosts which are no longer
+	// listed are removed first, and then those on the host with the most.
+	// +optional
+	Hosts []microvm.Host `json:"hosts,omitempty"`
+	// FailoverPolicy opts in to recreating the Microvms of one of the Hosts on
+	// the others when its MicrovmHost has been unreachable for too long. The
+	// Microvms left on it are removed once it is reachable again, as the
+	// replicaset then has more than it needs. It is only used with Hosts.
+	// +optional
+	FailoverPolicy *FailoverPolicy `json:"failoverPolicy,omitempty"`
+	// Selector is a label query over Microvms. Microvms are listed with it
+	// rather than across the whole namespace. Orphaned Microvms on the same host
+	// which match it are adopted by the replicaset, and owned Microvms which no
+	// longer match it are released. It must match the labels of the template.
+	// Nothing is adopted or released when it is not set.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 	// Template is the object that describes the Microvm that will be created if
 	// insufficient replicas are detected.
 	// More info: https://kubernetes.io/docs/concepts/workloads/controllers/replicationcontroller#pod-template
+	//
+	// The metadata name, generateName, labels and annotations, and the spec
+	// labels, hostname, hostVMNamespace, hostVMName, kernelCmdline, network interface
+	// guestMac and address, and gracefulShutdown agentEndpoint fields may use Go templates to vary between replicas, with
+	// {{ .ReplicaIndex }}, {{ .ReplicaSetName }}, {{ .HostName }} and
+	// {{ .HostEndpoint }} available, along with the add and hex functions.
+	// The userdata is never templated, so that cloud-init Jinja templates in it
+	// are passed to the guest as they are.
+	// A name which does not use {{ .ReplicaIndex }} would be the same for
+	// every replica, so it is used as the generateName instead.
+	// Network interfaces without a guestMac are given a MAC which is unique to
+	// the replica, or one from the macPoolRef if it is set.
 	// +optional
 	Template MicrovmTemplateSpec `json:"template,omitempty" protobuf:"bytes,3,opt,name=template"`
+	// TemplateRef is the name of a MicrovmTemplate, in the same namespace, to use
+	// instead of Template. The replicaset waits until the MicrovmTemplate has
+	// been resolved from its source. Like a change to Template, a change to the
+	// MicrovmTemplate is only used for the Microvms created after it.
+	// +optional
+	TemplateRef *corev1.LocalObjectReference `json:"templateRef,omitempty"`
+	// DeletePolicy is what happens to the Microvms when the replicaset is
+	// deleted. With Foreground they are deleted, and the replicaset is only
+	// removed once flintlock has confirmed that every VM is gone. With Orphan
+	// they are released and left running, to be adopted by another replicaset
+	// whose selector matches them.
+	// +kubebuilder:validation:Enum=Foreground;Orphan
+	// +kubebuilder:default=Foreground
+	// +optional
+	DeletePolicy DeletePolicy `json:"deletePolicy,omitempty"`
+	// RestartPolicy is what happens to a Microvm whose VM has failed. With
+	// OnFailure it is deleted and replaced by a new one once flintlock reports
+	// the VM as failed, and with Always also once the state of the VM on the
+	// host is unknown. With Never it is left for someone to look at. Microvms
+	// which are externally managed or protected from deletion are never
+	// replaced.
+	// +kubebuilder:validation:Enum=Never;OnFailure;Always
+	// +kubebuilder:default=Never
+	// +optional
+	RestartPolicy RestartPolicy `json:"restartPolicy,omitempty"`
+	// MaxCreatePerReconcile is how many Microvms are created at once when the
+	// replicaset is short of replicas. Any still missing are created on the
+	// next reconcile.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=5
+	// +optional
+	MaxCreatePerReconcile *int32 `json:"maxCreatePerReconcile,omitempty"`
+	// MinReadySeconds is how long a Microvm must have been ready, without any
+	// of it going unready, before it counts as available.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+	// ProviderIDList is the provider IDs of the Microvms of the replicaset. It
+	// is set by the controller when the replicaset is the infrastructure of a
+	// Cluster API MachinePool, which matches them to its Nodes.
+	// +optional
+	ProviderIDList []string `json:"providerIDList,omitempty"`
 }
 
+// DeletePolicy is what happens to the children of a MicrovmReplicaSet or
+// MicrovmDeployment when it is deleted.
+type DeletePolicy string
+
+const (
+	// DeletePolicyForeground deletes the children and waits for them to be gone.
+	DeletePolicyForeground DeletePolicy = "Foreground"
+	// DeletePolicyOrphan releases the children without deleting them.
+	DeletePolicyOrphan DeletePolicy = "Orphan"
+)
+
 // MicrovmReplicaSetStatus defines the observed state of MicrovmReplicaSet
 type MicrovmReplicaSetStatus struct {
 	// Ready is true when Replicas is Equal to ReadyReplicas.
@@ -59,6 +177,54 @@ type MicrovmReplicaSetStatus struct {
 	// +optional
 	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
 
+	// HostSummaries is the number of microvms on each host of the replicaset,
+	// ordered by host endpoint.
+	// +optional
+	HostSummaries []ReplicaSetHostSummary `json:"hostSummaries,omitempty"`
+
+	// ResolvedImages are the digests the images of the template were resolved
+	// to when its ImagePolicy is Resolve, as image@digest by image. Every
+	// Microvm is created with them in place of the tags.
+	// +optional
+	ResolvedImages map[string]string `json:"resolvedImages,omitempty"`
+
+	// AvailableReplicas is the number of microvms targeted by this ReplicaSet
+	// which have been ready for at least MinReadySeconds.
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// Selector is the Selector of the spec in its string form, for the scale
+	// subresource. It is empty when the spec has no Selector.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// Phase is a summary of the conditions of the MicrovmReplicaSet: one of Provisioning,
+	// Running, Failed or Deleting.
+	// +optional
+	Phase Phase `json:"phase,omitempty"`
+
+	// ObservedGeneration is the most recent generation of the MicrovmReplicaSet spec
+	// which the controller has processed successfully.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// TemplateRevision is the revision of the MicrovmTemplate of TemplateRef
+	// which new Microvms are created from.
+	// +optional
+	TemplateRevision int64 `json:"templateRevision,omitempty"`
+
+	// DryRun is what the last reconcile would have done, while the MicrovmReplicaSet is
+	// reconciled in dry-run mode.
+	// +optional
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// MicrovmSummaries is the state of each microvm targeted by this ReplicaSet,
+	// ordered by name.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	MicrovmSummaries []MicrovmSummary `json:"microvmSummaries,omitempty"`
+
 	// Represents the latest available observations of a replica set's current state.
 	// +optional
 	// +patchMergeKey=type
@@ -66,8 +232,62 @@ type MicrovmReplicaSetStatus struct {
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
+// MicrovmSummary is the observed state of a single microvm of a MicrovmReplicaSet.
+type MicrovmSummary struct {
+	// Name is the name of the Microvm.
+	Name string `json:"name"`
+
+	// Host is the endpoint of the host the microvm runs on.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// ReplicaIndex is the index of the replica the microvm was created as.
+	// +optional
+	ReplicaIndex *int32 `json:"replicaIndex,omitempty"`
+
+	// VMState is the state of the microvm on the host.
+	// +optional
+	VMState *microvm.VMState `json:"vmState,omitempty"`
+
+	// Ready is true when the Microvm has a Ready Condition.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// FailureReason is the reason the Microvm is failing, if it is.
+	// +optional
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+// ReplicaSetHostSummary is the observed state of a single host of a
+// MicrovmReplicaSet.
+type ReplicaSetHostSummary struct {
+	// Host is the endpoint of the host.
+	Host string `json:"host"`
+
+	// Replicas is the number of microvms which have been created on the host.
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of microvms on the host with a Ready Condition.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// Failed is true when the host has been unreachable for longer than the
+	// FailoverPolicy allows, and its microvms are being recreated elsewhere.
+	// +optional
+	Failed bool `json:"failed,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+//+kubebuilder:resource:categories=liquidmetal,shortName=mvmrs
+//+kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".spec.replicas",description="Number of desired microvms"
+//+kubebuilder:printcolumn:name="Created",type="integer",JSONPath=".status.replicas",description="Number of created microvms"
+//+kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas",description="Number of ready microvms"
+//+kubebuilder:printcolumn:name="Available",type="integer",JSONPath=".status.availableReplicas",description="Number of available microvms"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Phase of the MicrovmReplicaSet"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // MicrovmReplicaSet is the Schema for the microvmreplicasets API
 type MicrovmReplicaSet struct {
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// MicrovmSnapshotSpec defines the desired state of MicrovmSnapshot
+type MicrovmSnapshotSpec struct {
+	// SourceRef is the Microvm, in the same namespace, to snapshot. The snapshot
+	// is taken once the Microvm is ready and is not changed afterwards.
+	SourceRef corev1.LocalObjectReference `json:"sourceRef"`
+}
+
+// MicrovmSnapshotStatus defines the observed state of MicrovmSnapshot
+type MicrovmSnapshotStatus struct {
+	// Ready is true when the snapshot has been taken and can be restored from.
+	// +optional
+	// +kubebuilder:default=false
+	Ready bool `json:"ready"`
+	// SourceUID is the UID of the Microvm the snapshot was taken from.
+	// +optional
+	SourceUID types.UID `json:"sourceUID,omitempty"`
+	// TakenAt is when the snapshot was taken.
+	// +optional
+	TakenAt *metav1.Time `json:"takenAt,omitempty"`
+	// VMSpec is the spec of the source Microvm when the snapshot was taken.
+	// Flintlock cannot copy the contents of a running VM's volumes, so the
+	// snapshot holds the images the volumes, kernel and initrd were created
+	// from, and anything the guest has written since is not captured.
+	// +optional
+	VMSpec *microvm.VMSpec `json:"vmSpec,omitempty"`
+	// Conditions defines current service state of the MicrovmSnapshot.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=liquidmetal,shortName=mvmsnap
+//+kubebuilder:printcolumn:name="Source",type="string",JSONPath=".spec.sourceRef.name"
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MicrovmSnapshot is the Schema for the microvmsnapshots API
+type MicrovmSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MicrovmSnapshotSpec   `json:"spec,omitempty"`
+	Status MicrovmSnapshotStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MicrovmSnapshotList contains a list of MicrovmSnapshot
+type MicrovmSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MicrovmSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MicrovmSnapshot{}, &MicrovmSnapshotList{})
+}
+
+// GetConditions returns the observations of the operational state of the MicrovmSnapshot resource.
+func (r *MicrovmSnapshot) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MicrovmSnapshot to the predescribed clusterv1.Conditions.
+func (r *MicrovmSnapshot) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
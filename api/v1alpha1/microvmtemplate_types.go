@@ -17,7 +17,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 // MicrovmTemplateSpec defines the desired state of MicrovmTemplate
@@ -29,17 +31,78 @@ type MicrovmTemplateSpec struct {
 	Spec MicrovmSpec `json:"spec,omitempty"`
 }
 
+// TemplateSource describes where the content of a MicrovmTemplate is resolved
+// from.
+type TemplateSource struct {
+	// OCI resolves the template from an artifact in an OCI registry.
+	// +optional
+	OCI *OCITemplateSource `json:"oci,omitempty"`
+}
+
+// OCITemplateSource is an OCI artifact holding a MicrovmTemplateSpec as YAML in
+// a layer of media type application/vnd.liquid-metal.microvm.template.v1+yaml.
+type OCITemplateSource struct {
+	// Reference is the artifact, pinned by the digest of its manifest, eg
+	// ghcr.io/org/templates/ubuntu:22.04@sha256:<digest>. Any tag is informational.
+	// +kubebuilder:validation:Pattern=`^.+@sha256:[a-f0-9]{64}$`
+	Reference string `json:"reference"`
+	// PullSecretRef is the name of a kubernetes.io/dockerconfigjson Secret, in
+	// the same namespace, holding credentials for the registry.
+	// +optional
+	PullSecretRef *corev1.LocalObjectReference `json:"pullSecretRef,omitempty"`
+	// Insecure talks to the registry over plain HTTP.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// MicrovmTemplateStatus defines the observed state of MicrovmTemplate
+type MicrovmTemplateStatus struct {
+	// Ready is true when the template can be used.
+	// +optional
+	// +kubebuilder:default=false
+	Ready bool `json:"ready"`
+	// ResolvedReference is the source reference the template was last resolved from.
+	// +optional
+	ResolvedReference string `json:"resolvedReference,omitempty"`
+	// ResolvedTemplate is the content resolved from the source.
+	// +optional
+	ResolvedTemplate *MicrovmTemplateSpec `json:"resolvedTemplate,omitempty"`
+	// Revision is incremented each time the content of the template changes,
+	// whether it is edited or resolved from a new source. MicrovmReplicaSets
+	// and MicrovmDeployments which reference the template record the revision
+	// they are using.
+	// +optional
+	Revision int64 `json:"revision,omitempty"`
+	// TemplateHash is the hash of the content Revision was given for.
+	// +optional
+	TemplateHash string `json:"templateHash,omitempty"`
+	// Conditions defines current service state of the MicrovmTemplate.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=liquidmetal,shortName=mvmt
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready"
+//+kubebuilder:printcolumn:name="Revision",type="integer",JSONPath=".status.revision"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // MicrovmTemplate is the Schema for the microvmtemplates API
 type MicrovmTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
+	// Source resolves the template from outside the cluster. When it is set,
+	// Template is ignored and the resolved content is held in the status.
+	// +optional
+	Source *TemplateSource `json:"source,omitempty"`
+
 	// Template defines the Microvm that will be created from this pod template.
 	// +optional
 	Template MicrovmTemplateSpec `json:"template,omitempty"`
+
+	Status MicrovmTemplateStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -54,3 +117,33 @@ type MicrovmTemplateList struct {
 func init() {
 	SchemeBuilder.Register(&MicrovmTemplate{}, &MicrovmTemplateList{})
 }
+
+// GetConditions returns the observations of the operational state of the MicrovmTemplate resource.
+func (r *MicrovmTemplate) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MicrovmTemplate to the predescribed clusterv1.Conditions.
+func (r *MicrovmTemplate) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+// IsResolved returns true when the template holds the content of its current
+// source, or has no source.
+func (r *MicrovmTemplate) IsResolved() bool {
+	if r.Source == nil || r.Source.OCI == nil {
+		return true
+	}
+
+	return r.Status.Ready && r.Status.ResolvedTemplate != nil && r.Status.ResolvedReference == r.Source.OCI.Reference
+}
+
+// EffectiveTemplate returns the template to create Microvms from: the resolved
+// content when the template has a source, otherwise Template.
+func (r *MicrovmTemplate) EffectiveTemplate() MicrovmTemplateSpec {
+	if r.Source != nil && r.Source.OCI != nil && r.Status.ResolvedTemplate != nil {
+		return *r.Status.ResolvedTemplate
+	}
+
+	return r.Template
+}
@@ -24,428 +24,623 @@ package v1alpha1
 import (
 	"github.com/weaveworks-liquidmetal/controller-pkg/client"
 	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in HostMap) DeepCopyInto(out *HostMap) {
-	{
-		in := &in
-		*out = make(HostMap, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+func (in *AgentExecAction) DeepCopyInto(out *AgentExecAction) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostMap.
-func (in HostMap) DeepCopy() HostMap {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentExecAction.
+func (in *AgentExecAction) DeepCopy() *AgentExecAction {
 	if in == nil {
 		return nil
 	}
-	out := new(HostMap)
+	out := new(AgentExecAction)
 	in.DeepCopyInto(out)
-	return *out
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Microvm) DeepCopyInto(out *Microvm) {
+func (in *AutoscalerBehavior) DeepCopyInto(out *AutoscalerBehavior) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	if in.ScaleUp != nil {
+		in, out := &in.ScaleUp, &out.ScaleUp
+		*out = new(ScalingRules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScaleDown != nil {
+		in, out := &in.ScaleDown, &out.ScaleDown
+		*out = new(ScalingRules)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Microvm.
-func (in *Microvm) DeepCopy() *Microvm {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalerBehavior.
+func (in *AutoscalerBehavior) DeepCopy() *AutoscalerBehavior {
 	if in == nil {
 		return nil
 	}
-	out := new(Microvm)
+	out := new(AutoscalerBehavior)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Microvm) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmDeployment) DeepCopyInto(out *MicrovmDeployment) {
+func (in *CanaryRollout) DeepCopyInto(out *CanaryRollout) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmDeployment.
-func (in *MicrovmDeployment) DeepCopy() *MicrovmDeployment {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRollout.
+func (in *CanaryRollout) DeepCopy() *CanaryRollout {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmDeployment)
+	out := new(CanaryRollout)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MicrovmDeployment) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmDeploymentList) DeepCopyInto(out *MicrovmDeploymentList) {
+func (in *DNSConfig) DeepCopyInto(out *DNSConfig) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]MicrovmDeployment, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Nameservers != nil {
+		in, out := &in.Nameservers, &out.Nameservers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Searches != nil {
+		in, out := &in.Searches, &out.Searches
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmDeploymentList.
-func (in *MicrovmDeploymentList) DeepCopy() *MicrovmDeploymentList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSConfig.
+func (in *DNSConfig) DeepCopy() *DNSConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmDeploymentList)
+	out := new(DNSConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MicrovmDeploymentList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DensityMetricSource) DeepCopyInto(out *DensityMetricSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DensityMetricSource.
+func (in *DensityMetricSource) DeepCopy() *DensityMetricSource {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(DensityMetricSource)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmDeploymentSpec) DeepCopyInto(out *MicrovmDeploymentSpec) {
+func (in *DryRunStatus) DeepCopyInto(out *DryRunStatus) {
 	*out = *in
-	if in.Replicas != nil {
-		in, out := &in.Replicas, &out.Replicas
-		*out = new(int32)
-		**out = **in
-	}
-	if in.Hosts != nil {
-		in, out := &in.Hosts, &out.Hosts
-		*out = make([]microvm.Host, len(*in))
+	if in.Actions != nil {
+		in, out := &in.Actions, &out.Actions
+		*out = make([]PlannedAction, len(*in))
 		copy(*out, *in)
 	}
-	in.Template.DeepCopyInto(&out.Template)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmDeploymentSpec.
-func (in *MicrovmDeploymentSpec) DeepCopy() *MicrovmDeploymentSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunStatus.
+func (in *DryRunStatus) DeepCopy() *DryRunStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmDeploymentSpec)
+	out := new(DryRunStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmDeploymentStatus) DeepCopyInto(out *MicrovmDeploymentStatus) {
+func (in *ErrorBudget) DeepCopyInto(out *ErrorBudget) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make(v1beta1.Conditions, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Objective != nil {
+		in, out := &in.Objective, &out.Objective
+		x := (*in).DeepCopy()
+		*out = &x
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmDeploymentStatus.
-func (in *MicrovmDeploymentStatus) DeepCopy() *MicrovmDeploymentStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ErrorBudget.
+func (in *ErrorBudget) DeepCopy() *ErrorBudget {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmDeploymentStatus)
+	out := new(ErrorBudget)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmList) DeepCopyInto(out *MicrovmList) {
+func (in *ExternalResourceRef) DeepCopyInto(out *ExternalResourceRef) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Microvm, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmList.
-func (in *MicrovmList) DeepCopy() *MicrovmList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalResourceRef.
+func (in *ExternalResourceRef) DeepCopy() *ExternalResourceRef {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmList)
+	out := new(ExternalResourceRef)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MicrovmList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmReplicaSet) DeepCopyInto(out *MicrovmReplicaSet) {
+func (in *FailoverPolicy) DeepCopyInto(out *FailoverPolicy) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmReplicaSet.
-func (in *MicrovmReplicaSet) DeepCopy() *MicrovmReplicaSet {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailoverPolicy.
+func (in *FailoverPolicy) DeepCopy() *FailoverPolicy {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmReplicaSet)
+	out := new(FailoverPolicy)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MicrovmReplicaSet) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GracefulShutdown) DeepCopyInto(out *GracefulShutdown) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GracefulShutdown.
+func (in *GracefulShutdown) DeepCopy() *GracefulShutdown {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(GracefulShutdown)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmReplicaSetList) DeepCopyInto(out *MicrovmReplicaSetList) {
+func (in *GuestAgent) DeepCopyInto(out *GuestAgent) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]MicrovmReplicaSet, len(*in))
+	if in.Commands != nil {
+		in, out := &in.Commands, &out.Commands
+		*out = make([]GuestCommand, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmReplicaSetList.
-func (in *MicrovmReplicaSetList) DeepCopy() *MicrovmReplicaSetList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GuestAgent.
+func (in *GuestAgent) DeepCopy() *GuestAgent {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmReplicaSetList)
+	out := new(GuestAgent)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MicrovmReplicaSetList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmReplicaSetSpec) DeepCopyInto(out *MicrovmReplicaSetSpec) {
+func (in *GuestCommand) DeepCopyInto(out *GuestCommand) {
 	*out = *in
-	if in.Replicas != nil {
-		in, out := &in.Replicas, &out.Replicas
-		*out = new(int32)
-		**out = **in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	out.Host = in.Host
-	in.Template.DeepCopyInto(&out.Template)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmReplicaSetSpec.
-func (in *MicrovmReplicaSetSpec) DeepCopy() *MicrovmReplicaSetSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GuestCommand.
+func (in *GuestCommand) DeepCopy() *GuestCommand {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmReplicaSetSpec)
+	out := new(GuestCommand)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmReplicaSetStatus) DeepCopyInto(out *MicrovmReplicaSetStatus) {
+func (in *GuestCommandResult) DeepCopyInto(out *GuestCommandResult) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make(v1beta1.Conditions, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmReplicaSetStatus.
-func (in *MicrovmReplicaSetStatus) DeepCopy() *MicrovmReplicaSetStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GuestCommandResult.
+func (in *GuestCommandResult) DeepCopy() *GuestCommandResult {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmReplicaSetStatus)
+	out := new(GuestCommandResult)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmSpec) DeepCopyInto(out *MicrovmSpec) {
+func (in *GuestHealthStatus) DeepCopyInto(out *GuestHealthStatus) {
 	*out = *in
-	out.Host = in.Host
-	in.VMSpec.DeepCopyInto(&out.VMSpec)
-	if in.UserData != nil {
-		in, out := &in.UserData, &out.UserData
-		*out = new(string)
-		**out = **in
-	}
-	if in.SSHPublicKeys != nil {
-		in, out := &in.SSHPublicKeys, &out.SSHPublicKeys
-		*out = make([]microvm.SSHPublicKey, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.ProviderID != nil {
-		in, out := &in.ProviderID, &out.ProviderID
-		*out = new(string)
-		**out = **in
-	}
-	if in.MicrovmProxy != nil {
-		in, out := &in.MicrovmProxy, &out.MicrovmProxy
-		*out = new(client.Proxy)
-		**out = **in
+	if in.LastProbeTime != nil {
+		in, out := &in.LastProbeTime, &out.LastProbeTime
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmSpec.
-func (in *MicrovmSpec) DeepCopy() *MicrovmSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GuestHealthStatus.
+func (in *GuestHealthStatus) DeepCopy() *GuestHealthStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmSpec)
+	out := new(GuestHealthStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmStatus) DeepCopyInto(out *MicrovmStatus) {
+func (in *GuestInfo) DeepCopyInto(out *GuestInfo) {
 	*out = *in
-	if in.VMState != nil {
-		in, out := &in.VMState, &out.VMState
-		*out = new(microvm.VMState)
-		**out = **in
-	}
-	if in.FailureReason != nil {
-		in, out := &in.FailureReason, &out.FailureReason
-		*out = new(string)
-		**out = **in
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
 	}
-	if in.FailureMessage != nil {
-		in, out := &in.FailureMessage, &out.FailureMessage
-		*out = new(string)
-		**out = **in
+	if in.CloudInitErrors != nil {
+		in, out := &in.CloudInitErrors, &out.CloudInitErrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make(v1beta1.Conditions, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Commands != nil {
+		in, out := &in.Commands, &out.Commands
+		*out = make([]GuestCommandResult, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmStatus.
-func (in *MicrovmStatus) DeepCopy() *MicrovmStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GuestInfo.
+func (in *GuestInfo) DeepCopy() *GuestInfo {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmStatus)
+	out := new(GuestInfo)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmTemplate) DeepCopyInto(out *MicrovmTemplate) {
+func (in *HTTPGetAction) DeepCopyInto(out *HTTPGetAction) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Template.DeepCopyInto(&out.Template)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmTemplate.
-func (in *MicrovmTemplate) DeepCopy() *MicrovmTemplate {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPGetAction.
+func (in *HTTPGetAction) DeepCopy() *HTTPGetAction {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmTemplate)
+	out := new(HTTPGetAction)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MicrovmTemplate) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostCapacity) DeepCopyInto(out *HostCapacity) {
+	*out = *in
+	if in.MaxMicrovmsPerHost != nil {
+		in, out := &in.MaxMicrovmsPerHost, &out.MaxMicrovmsPerHost
+		*out = new(int32)
+		**out = **in
 	}
-	return nil
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostCapacity.
+func (in *HostCapacity) DeepCopy() *HostCapacity {
+	if in == nil {
+		return nil
+	}
+	out := new(HostCapacity)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmTemplateList) DeepCopyInto(out *MicrovmTemplateList) {
+func (in *HostIdentity) DeepCopyInto(out *HostIdentity) {
+	*out = *in
+	in.FirstSeen.DeepCopyInto(&out.FirstSeen)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostIdentity.
+func (in *HostIdentity) DeepCopy() *HostIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(HostIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostIdentityPolicy) DeepCopyInto(out *HostIdentityPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostIdentityPolicy.
+func (in *HostIdentityPolicy) DeepCopy() *HostIdentityPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(HostIdentityPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in HostMap) DeepCopyInto(out *HostMap) {
+	{
+		in := &in
+		*out = make(HostMap, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostMap.
+func (in HostMap) DeepCopy() HostMap {
+	if in == nil {
+		return nil
+	}
+	out := new(HostMap)
+	in.DeepCopyInto(out)
+	return *out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSummary) DeepCopyInto(out *HostSummary) {
+	*out = *in
+	if in.Condition != nil {
+		in, out := &in.Condition, &out.Condition
+		*out = new(v1beta1.Condition)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastSuccessfulContact != nil {
+		in, out := &in.LastSuccessfulContact, &out.LastSuccessfulContact
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSummary.
+func (in *HostSummary) DeepCopy() *HostSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAllocation) DeepCopyInto(out *IPAllocation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAllocation.
+func (in *IPAllocation) DeepCopy() *IPAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPRange) DeepCopyInto(out *IPRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPRange.
+func (in *IPRange) DeepCopy() *IPRange {
+	if in == nil {
+		return nil
+	}
+	out := new(IPRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterfaceIPPool) DeepCopyInto(out *InterfaceIPPool) {
+	*out = *in
+	out.PoolRef = in.PoolRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterfaceIPPool.
+func (in *InterfaceIPPool) DeepCopy() *InterfaceIPPool {
+	if in == nil {
+		return nil
+	}
+	out := new(InterfaceIPPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LivenessProbe) DeepCopyInto(out *LivenessProbe) {
+	*out = *in
+	if in.TCPSocket != nil {
+		in, out := &in.TCPSocket, &out.TCPSocket
+		*out = new(TCPSocketAction)
+		**out = **in
+	}
+	if in.HTTPGet != nil {
+		in, out := &in.HTTPGet, &out.HTTPGet
+		*out = new(HTTPGetAction)
+		**out = **in
+	}
+	if in.AgentExec != nil {
+		in, out := &in.AgentExec, &out.AgentExec
+		*out = new(AgentExecAction)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LivenessProbe.
+func (in *LivenessProbe) DeepCopy() *LivenessProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(LivenessProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MACAllocation) DeepCopyInto(out *MACAllocation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MACAllocation.
+func (in *MACAllocation) DeepCopy() *MACAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(MACAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MACRange) DeepCopyInto(out *MACRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MACRange.
+func (in *MACRange) DeepCopy() *MACRange {
+	if in == nil {
+		return nil
+	}
+	out := new(MACRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricSource) DeepCopyInto(out *MetricSource) {
+	*out = *in
+	if in.Prometheus != nil {
+		in, out := &in.Prometheus, &out.Prometheus
+		*out = new(PrometheusMetricSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Density != nil {
+		in, out := &in.Density, &out.Density
+		*out = new(DensityMetricSource)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricSource.
+func (in *MetricSource) DeepCopy() *MetricSource {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Microvm) DeepCopyInto(out *Microvm) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Microvm.
+func (in *Microvm) DeepCopy() *Microvm {
+	if in == nil {
+		return nil
+	}
+	out := new(Microvm)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Microvm) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmAction) DeepCopyInto(out *MicrovmAction) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmAction.
+func (in *MicrovmAction) DeepCopy() *MicrovmAction {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmAction) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmActionList) DeepCopyInto(out *MicrovmActionList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]MicrovmTemplate, len(*in))
+		*out = make([]MicrovmAction, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmTemplateList.
-func (in *MicrovmTemplateList) DeepCopy() *MicrovmTemplateList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmActionList.
+func (in *MicrovmActionList) DeepCopy() *MicrovmActionList {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmTemplateList)
+	out := new(MicrovmActionList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MicrovmTemplateList) DeepCopyObject() runtime.Object {
+func (in *MicrovmActionList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -453,18 +648,2049 @@ func (in *MicrovmTemplateList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MicrovmTemplateSpec) DeepCopyInto(out *MicrovmTemplateSpec) {
+func (in *MicrovmActionObject) DeepCopyInto(out *MicrovmActionObject) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmActionObject.
+func (in *MicrovmActionObject) DeepCopy() *MicrovmActionObject {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmActionObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmActionSpec) DeepCopyInto(out *MicrovmActionSpec) {
+	*out = *in
+	if in.Object != nil {
+		in, out := &in.Object, &out.Object
+		*out = new(MicrovmActionObject)
+		**out = **in
+	}
+	in.RequestedAt.DeepCopyInto(&out.RequestedAt)
+	in.CompletedAt.DeepCopyInto(&out.CompletedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmActionSpec.
+func (in *MicrovmActionSpec) DeepCopy() *MicrovmActionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmActionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmAutoscaler) DeepCopyInto(out *MicrovmAutoscaler) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmTemplateSpec.
-func (in *MicrovmTemplateSpec) DeepCopy() *MicrovmTemplateSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmAutoscaler.
+func (in *MicrovmAutoscaler) DeepCopy() *MicrovmAutoscaler {
 	if in == nil {
 		return nil
 	}
-	out := new(MicrovmTemplateSpec)
+	out := new(MicrovmAutoscaler)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmAutoscaler) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmAutoscalerList) DeepCopyInto(out *MicrovmAutoscalerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmAutoscaler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmAutoscalerList.
+func (in *MicrovmAutoscalerList) DeepCopy() *MicrovmAutoscalerList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmAutoscalerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmAutoscalerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmAutoscalerSpec) DeepCopyInto(out *MicrovmAutoscalerSpec) {
+	*out = *in
+	out.ScaleTargetRef = in.ScaleTargetRef
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	in.Metric.DeepCopyInto(&out.Metric)
+	if in.Behavior != nil {
+		in, out := &in.Behavior, &out.Behavior
+		*out = new(AutoscalerBehavior)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmAutoscalerSpec.
+func (in *MicrovmAutoscalerSpec) DeepCopy() *MicrovmAutoscalerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmAutoscalerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmAutoscalerStatus) DeepCopyInto(out *MicrovmAutoscalerStatus) {
+	*out = *in
+	if in.CurrentMetricValue != nil {
+		in, out := &in.CurrentMetricValue, &out.CurrentMetricValue
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.LastScaleTime != nil {
+		in, out := &in.LastScaleTime, &out.LastScaleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ScaleEvents != nil {
+		in, out := &in.ScaleEvents, &out.ScaleEvents
+		*out = make([]ScaleEvent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmAutoscalerStatus.
+func (in *MicrovmAutoscalerStatus) DeepCopy() *MicrovmAutoscalerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmAutoscalerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmClass) DeepCopyInto(out *MicrovmClass) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmClass.
+func (in *MicrovmClass) DeepCopy() *MicrovmClass {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmClass) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmClassList) DeepCopyInto(out *MicrovmClassList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmClassList.
+func (in *MicrovmClassList) DeepCopy() *MicrovmClassList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmClassList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmClassList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmClassSpec) DeepCopyInto(out *MicrovmClassSpec) {
+	*out = *in
+	if in.VCPU != nil {
+		in, out := &in.VCPU, &out.VCPU
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MemoryMb != nil {
+		in, out := &in.MemoryMb, &out.MemoryMb
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Kernel != nil {
+		in, out := &in.Kernel, &out.Kernel
+		*out = new(microvm.ContainerFileSource)
+		**out = **in
+	}
+	if in.KernelCmdLine != nil {
+		in, out := &in.KernelCmdLine, &out.KernelCmdLine
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Initrd != nil {
+		in, out := &in.Initrd, &out.Initrd
+		*out = new(microvm.ContainerFileSource)
+		**out = **in
+	}
+	if in.RootVolume != nil {
+		in, out := &in.RootVolume, &out.RootVolume
+		*out = new(microvm.Volume)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmClassSpec.
+func (in *MicrovmClassSpec) DeepCopy() *MicrovmClassSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmClassSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmDeployment) DeepCopyInto(out *MicrovmDeployment) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmDeployment.
+func (in *MicrovmDeployment) DeepCopy() *MicrovmDeployment {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmDeployment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmDeployment) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmDeploymentList) DeepCopyInto(out *MicrovmDeploymentList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmDeployment, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmDeploymentList.
+func (in *MicrovmDeploymentList) DeepCopy() *MicrovmDeploymentList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmDeploymentList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmDeploymentList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmDeploymentSpec) DeepCopyInto(out *MicrovmDeploymentSpec) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]microvm.Host, len(*in))
+		copy(*out, *in)
+	}
+	if in.HostSelector != nil {
+		in, out := &in.HostSelector, &out.HostSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostGroupRef != nil {
+		in, out := &in.HostGroupRef, &out.HostGroupRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.SpreadConstraints != nil {
+		in, out := &in.SpreadConstraints, &out.SpreadConstraints
+		*out = new(SpreadConstraints)
+		**out = **in
+	}
+	if in.PlacementOverrides != nil {
+		in, out := &in.PlacementOverrides, &out.PlacementOverrides
+		*out = make([]PlacementOverride, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+	if in.TemplateRef != nil {
+		in, out := &in.TemplateRef, &out.TemplateRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.FailoverPolicy != nil {
+		in, out := &in.FailoverPolicy, &out.FailoverPolicy
+		*out = new(FailoverPolicy)
+		**out = **in
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ProgressDeadlineSeconds != nil {
+		in, out := &in.ProgressDeadlineSeconds, &out.ProgressDeadlineSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmDeploymentSpec.
+func (in *MicrovmDeploymentSpec) DeepCopy() *MicrovmDeploymentSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmDeploymentSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmDeploymentStatus) DeepCopyInto(out *MicrovmDeploymentStatus) {
+	*out = *in
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(DryRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Progress != nil {
+		in, out := &in.Progress, &out.Progress
+		*out = new(ProgressStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostSummaries != nil {
+		in, out := &in.HostSummaries, &out.HostSummaries
+		*out = make([]HostSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmDeploymentStatus.
+func (in *MicrovmDeploymentStatus) DeepCopy() *MicrovmDeploymentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmDeploymentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmHost) DeepCopyInto(out *MicrovmHost) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmHost.
+func (in *MicrovmHost) DeepCopy() *MicrovmHost {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmHost)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmHost) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmHostGroup) DeepCopyInto(out *MicrovmHostGroup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmHostGroup.
+func (in *MicrovmHostGroup) DeepCopy() *MicrovmHostGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmHostGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmHostGroup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmHostGroupList) DeepCopyInto(out *MicrovmHostGroupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmHostGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmHostGroupList.
+func (in *MicrovmHostGroupList) DeepCopy() *MicrovmHostGroupList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmHostGroupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmHostGroupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmHostGroupSpec) DeepCopyInto(out *MicrovmHostGroupSpec) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]microvm.Host, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Capacity.DeepCopyInto(&out.Capacity)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmHostGroupSpec.
+func (in *MicrovmHostGroupSpec) DeepCopy() *MicrovmHostGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmHostGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmHostGroupStatus) DeepCopyInto(out *MicrovmHostGroupStatus) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]microvm.Host, len(*in))
+		copy(*out, *in)
+	}
+	if in.FullHosts != nil {
+		in, out := &in.FullHosts, &out.FullHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmHostGroupStatus.
+func (in *MicrovmHostGroupStatus) DeepCopy() *MicrovmHostGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmHostGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmHostList) DeepCopyInto(out *MicrovmHostList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmHost, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmHostList.
+func (in *MicrovmHostList) DeepCopy() *MicrovmHostList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmHostList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmHostList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmHostSpec) DeepCopyInto(out *MicrovmHostSpec) {
+	*out = *in
+	in.ErrorBudget.DeepCopyInto(&out.ErrorBudget)
+	out.Identity = in.Identity
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmHostSpec.
+func (in *MicrovmHostSpec) DeepCopy() *MicrovmHostSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmHostSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmHostStatus) DeepCopyInto(out *MicrovmHostStatus) {
+	*out = *in
+	if in.SuccessRatio != nil {
+		in, out := &in.SuccessRatio, &out.SuccessRatio
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.ErrorBudgetRemaining != nil {
+		in, out := &in.ErrorBudgetRemaining, &out.ErrorBudgetRemaining
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Identity != nil {
+		in, out := &in.Identity, &out.Identity
+		*out = new(HostIdentity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UnreachableSince != nil {
+		in, out := &in.UnreachableSince, &out.UnreachableSince
+		*out = (*in).DeepCopy()
+	}
+	if in.Buckets != nil {
+		in, out := &in.Buckets, &out.Buckets
+		*out = make([]ProvisioningBucket, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmHostStatus.
+func (in *MicrovmHostStatus) DeepCopy() *MicrovmHostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmHostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmIPPool) DeepCopyInto(out *MicrovmIPPool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmIPPool.
+func (in *MicrovmIPPool) DeepCopy() *MicrovmIPPool {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmIPPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmIPPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmIPPoolList) DeepCopyInto(out *MicrovmIPPoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmIPPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmIPPoolList.
+func (in *MicrovmIPPoolList) DeepCopy() *MicrovmIPPoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmIPPoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmIPPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmIPPoolSpec) DeepCopyInto(out *MicrovmIPPoolSpec) {
+	*out = *in
+	if in.Range != nil {
+		in, out := &in.Range, &out.Range
+		*out = new(IPRange)
+		**out = **in
+	}
+	if in.Nameservers != nil {
+		in, out := &in.Nameservers, &out.Nameservers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Allocations != nil {
+		in, out := &in.Allocations, &out.Allocations
+		*out = make([]IPAllocation, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmIPPoolSpec.
+func (in *MicrovmIPPoolSpec) DeepCopy() *MicrovmIPPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmIPPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmIPPoolStatus) DeepCopyInto(out *MicrovmIPPoolStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmIPPoolStatus.
+func (in *MicrovmIPPoolStatus) DeepCopy() *MicrovmIPPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmIPPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmList) DeepCopyInto(out *MicrovmList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Microvm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmList.
+func (in *MicrovmList) DeepCopy() *MicrovmList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmMACPool) DeepCopyInto(out *MicrovmMACPool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmMACPool.
+func (in *MicrovmMACPool) DeepCopy() *MicrovmMACPool {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmMACPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmMACPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmMACPoolList) DeepCopyInto(out *MicrovmMACPoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmMACPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmMACPoolList.
+func (in *MicrovmMACPoolList) DeepCopy() *MicrovmMACPoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmMACPoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmMACPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmMACPoolSpec) DeepCopyInto(out *MicrovmMACPoolSpec) {
+	*out = *in
+	if in.Range != nil {
+		in, out := &in.Range, &out.Range
+		*out = new(MACRange)
+		**out = **in
+	}
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Allocations != nil {
+		in, out := &in.Allocations, &out.Allocations
+		*out = make([]MACAllocation, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmMACPoolSpec.
+func (in *MicrovmMACPoolSpec) DeepCopy() *MicrovmMACPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmMACPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmMACPoolStatus) DeepCopyInto(out *MicrovmMACPoolStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmMACPoolStatus.
+func (in *MicrovmMACPoolStatus) DeepCopy() *MicrovmMACPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmMACPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmQuota) DeepCopyInto(out *MicrovmQuota) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmQuota.
+func (in *MicrovmQuota) DeepCopy() *MicrovmQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmQuota) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmQuotaList) DeepCopyInto(out *MicrovmQuotaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmQuotaList.
+func (in *MicrovmQuotaList) DeepCopy() *MicrovmQuotaList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmQuotaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmQuotaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmQuotaSpec) DeepCopyInto(out *MicrovmQuotaSpec) {
+	*out = *in
+	in.Hard.DeepCopyInto(&out.Hard)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmQuotaSpec.
+func (in *MicrovmQuotaSpec) DeepCopy() *MicrovmQuotaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmQuotaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmQuotaStatus) DeepCopyInto(out *MicrovmQuotaStatus) {
+	*out = *in
+	out.Used = in.Used
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmQuotaStatus.
+func (in *MicrovmQuotaStatus) DeepCopy() *MicrovmQuotaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmQuotaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmReplicaSet) DeepCopyInto(out *MicrovmReplicaSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmReplicaSet.
+func (in *MicrovmReplicaSet) DeepCopy() *MicrovmReplicaSet {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmReplicaSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmReplicaSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmReplicaSetList) DeepCopyInto(out *MicrovmReplicaSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmReplicaSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmReplicaSetList.
+func (in *MicrovmReplicaSetList) DeepCopy() *MicrovmReplicaSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmReplicaSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmReplicaSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmReplicaSetSpec) DeepCopyInto(out *MicrovmReplicaSetSpec) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	out.Host = in.Host
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]microvm.Host, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailoverPolicy != nil {
+		in, out := &in.FailoverPolicy, &out.FailoverPolicy
+		*out = new(FailoverPolicy)
+		**out = **in
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+	if in.TemplateRef != nil {
+		in, out := &in.TemplateRef, &out.TemplateRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.MaxCreatePerReconcile != nil {
+		in, out := &in.MaxCreatePerReconcile, &out.MaxCreatePerReconcile
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ProviderIDList != nil {
+		in, out := &in.ProviderIDList, &out.ProviderIDList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmReplicaSetSpec.
+func (in *MicrovmReplicaSetSpec) DeepCopy() *MicrovmReplicaSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmReplicaSetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmReplicaSetStatus) DeepCopyInto(out *MicrovmReplicaSetStatus) {
+	*out = *in
+	if in.HostSummaries != nil {
+		in, out := &in.HostSummaries, &out.HostSummaries
+		*out = make([]ReplicaSetHostSummary, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResolvedImages != nil {
+		in, out := &in.ResolvedImages, &out.ResolvedImages
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(DryRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MicrovmSummaries != nil {
+		in, out := &in.MicrovmSummaries, &out.MicrovmSummaries
+		*out = make([]MicrovmSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmReplicaSetStatus.
+func (in *MicrovmReplicaSetStatus) DeepCopy() *MicrovmReplicaSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmReplicaSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmSnapshot) DeepCopyInto(out *MicrovmSnapshot) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmSnapshot.
+func (in *MicrovmSnapshot) DeepCopy() *MicrovmSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmSnapshot) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmSnapshotList) DeepCopyInto(out *MicrovmSnapshotList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmSnapshotList.
+func (in *MicrovmSnapshotList) DeepCopy() *MicrovmSnapshotList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmSnapshotList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmSnapshotList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmSnapshotSpec) DeepCopyInto(out *MicrovmSnapshotSpec) {
+	*out = *in
+	out.SourceRef = in.SourceRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmSnapshotSpec.
+func (in *MicrovmSnapshotSpec) DeepCopy() *MicrovmSnapshotSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmSnapshotSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmSnapshotStatus) DeepCopyInto(out *MicrovmSnapshotStatus) {
+	*out = *in
+	if in.TakenAt != nil {
+		in, out := &in.TakenAt, &out.TakenAt
+		*out = (*in).DeepCopy()
+	}
+	if in.VMSpec != nil {
+		in, out := &in.VMSpec, &out.VMSpec
+		*out = new(microvm.VMSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmSnapshotStatus.
+func (in *MicrovmSnapshotStatus) DeepCopy() *MicrovmSnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmSnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmSpec) DeepCopyInto(out *MicrovmSpec) {
+	*out = *in
+	out.Host = in.Host
+	in.VMSpec.DeepCopyInto(&out.VMSpec)
+	if in.UserData != nil {
+		in, out := &in.UserData, &out.UserData
+		*out = new(string)
+		**out = **in
+	}
+	if in.SSHPublicKeys != nil {
+		in, out := &in.SSHPublicKeys, &out.SSHPublicKeys
+		*out = make([]microvm.SSHPublicKey, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DNS != nil {
+		in, out := &in.DNS, &out.DNS
+		*out = new(DNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VendorData != nil {
+		in, out := &in.VendorData, &out.VendorData
+		*out = new(VendorData)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ProviderID != nil {
+		in, out := &in.ProviderID, &out.ProviderID
+		*out = new(string)
+		**out = **in
+	}
+	if in.MicrovmProxy != nil {
+		in, out := &in.MicrovmProxy, &out.MicrovmProxy
+		*out = new(client.Proxy)
+		**out = **in
+	}
+	if in.GracefulShutdown != nil {
+		in, out := &in.GracefulShutdown, &out.GracefulShutdown
+		*out = new(GracefulShutdown)
+		**out = **in
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(LivenessProbe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SSHService != nil {
+		in, out := &in.SSHService, &out.SSHService
+		*out = new(SSHService)
+		**out = **in
+	}
+	if in.GuestAgent != nil {
+		in, out := &in.GuestAgent, &out.GuestAgent
+		*out = new(GuestAgent)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RestoreFrom != nil {
+		in, out := &in.RestoreFrom, &out.RestoreFrom
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.ClassRef != nil {
+		in, out := &in.ClassRef, &out.ClassRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.MACPoolRef != nil {
+		in, out := &in.MACPoolRef, &out.MACPoolRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.IPPools != nil {
+		in, out := &in.IPPools, &out.IPPools
+		*out = make([]InterfaceIPPool, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReconcileIntervals != nil {
+		in, out := &in.ReconcileIntervals, &out.ReconcileIntervals
+		*out = new(ReconcileIntervals)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmSpec.
+func (in *MicrovmSpec) DeepCopy() *MicrovmSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmStatus) DeepCopyInto(out *MicrovmStatus) {
+	*out = *in
+	if in.VMState != nil {
+		in, out := &in.VMState, &out.VMState
+		*out = new(microvm.VMState)
+		**out = **in
+	}
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(string)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
+	if in.Provisioning != nil {
+		in, out := &in.Provisioning, &out.Provisioning
+		*out = new(ProvisioningTimestamps)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GuestHealth != nil {
+		in, out := &in.GuestHealth, &out.GuestHealth
+		*out = new(GuestHealthStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GuestInfo != nil {
+		in, out := &in.GuestInfo, &out.GuestInfo
+		*out = new(GuestInfo)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PhoneHome != nil {
+		in, out := &in.PhoneHome, &out.PhoneHome
+		*out = new(PhoneHomeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExternalResources != nil {
+		in, out := &in.ExternalResources, &out.ExternalResources
+		*out = make([]ExternalResourceRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.ShutdownRequestedAt != nil {
+		in, out := &in.ShutdownRequestedAt, &out.ShutdownRequestedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.DeletionStartedAt != nil {
+		in, out := &in.DeletionStartedAt, &out.DeletionStartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ResolvedImages != nil {
+		in, out := &in.ResolvedImages, &out.ResolvedImages
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(DryRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmStatus.
+func (in *MicrovmStatus) DeepCopy() *MicrovmStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmSummary) DeepCopyInto(out *MicrovmSummary) {
+	*out = *in
+	if in.ReplicaIndex != nil {
+		in, out := &in.ReplicaIndex, &out.ReplicaIndex
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VMState != nil {
+		in, out := &in.VMState, &out.VMState
+		*out = new(microvm.VMState)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmSummary.
+func (in *MicrovmSummary) DeepCopy() *MicrovmSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmTemplate) DeepCopyInto(out *MicrovmTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(TemplateSource)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmTemplate.
+func (in *MicrovmTemplate) DeepCopy() *MicrovmTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmTemplateList) DeepCopyInto(out *MicrovmTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MicrovmTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmTemplateList.
+func (in *MicrovmTemplateList) DeepCopy() *MicrovmTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MicrovmTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmTemplateSpec) DeepCopyInto(out *MicrovmTemplateSpec) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmTemplateSpec.
+func (in *MicrovmTemplateSpec) DeepCopy() *MicrovmTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MicrovmTemplateStatus) DeepCopyInto(out *MicrovmTemplateStatus) {
+	*out = *in
+	if in.ResolvedTemplate != nil {
+		in, out := &in.ResolvedTemplate, &out.ResolvedTemplate
+		*out = new(MicrovmTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrovmTemplateStatus.
+func (in *MicrovmTemplateStatus) DeepCopy() *MicrovmTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MicrovmTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCITemplateSource) DeepCopyInto(out *OCITemplateSource) {
+	*out = *in
+	if in.PullSecretRef != nil {
+		in, out := &in.PullSecretRef, &out.PullSecretRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCITemplateSource.
+func (in *OCITemplateSource) DeepCopy() *OCITemplateSource {
+	if in == nil {
+		return nil
+	}
+	out := new(OCITemplateSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PhoneHomeStatus) DeepCopyInto(out *PhoneHomeStatus) {
+	*out = *in
+	if in.CalledAt != nil {
+		in, out := &in.CalledAt, &out.CalledAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PhoneHomeStatus.
+func (in *PhoneHomeStatus) DeepCopy() *PhoneHomeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PhoneHomeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlacementOverride) DeepCopyInto(out *PlacementOverride) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlacementOverride.
+func (in *PlacementOverride) DeepCopy() *PlacementOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(PlacementOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlannedAction) DeepCopyInto(out *PlannedAction) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlannedAction.
+func (in *PlannedAction) DeepCopy() *PlannedAction {
+	if in == nil {
+		return nil
+	}
+	out := new(PlannedAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProgressStatus) DeepCopyInto(out *ProgressStatus) {
+	*out = *in
+	in.LastProgressTime.DeepCopyInto(&out.LastProgressTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProgressStatus.
+func (in *ProgressStatus) DeepCopy() *ProgressStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProgressStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusMetricSource) DeepCopyInto(out *PrometheusMetricSource) {
+	*out = *in
+	out.TargetValue = in.TargetValue.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusMetricSource.
+func (in *PrometheusMetricSource) DeepCopy() *PrometheusMetricSource {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusMetricSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisioningBucket) DeepCopyInto(out *ProvisioningBucket) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisioningBucket.
+func (in *ProvisioningBucket) DeepCopy() *ProvisioningBucket {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisioningBucket)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisioningTimestamps) DeepCopyInto(out *ProvisioningTimestamps) {
+	*out = *in
+	if in.AcceptedAt != nil {
+		in, out := &in.AcceptedAt, &out.AcceptedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CreateSentAt != nil {
+		in, out := &in.CreateSentAt, &out.CreateSentAt
+		*out = (*in).DeepCopy()
+	}
+	if in.PendingAt != nil {
+		in, out := &in.PendingAt, &out.PendingAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CreatedAt != nil {
+		in, out := &in.CreatedAt, &out.CreatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.GuestReadyAt != nil {
+		in, out := &in.GuestReadyAt, &out.GuestReadyAt
+		*out = (*in).DeepCopy()
+	}
+	if in.BootDuration != nil {
+		in, out := &in.BootDuration, &out.BootDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ProvisioningDuration != nil {
+		in, out := &in.ProvisioningDuration, &out.ProvisioningDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisioningTimestamps.
+func (in *ProvisioningTimestamps) DeepCopy() *ProvisioningTimestamps {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisioningTimestamps)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuotaLimits) DeepCopyInto(out *QuotaLimits) {
+	*out = *in
+	if in.VCPU != nil {
+		in, out := &in.VCPU, &out.VCPU
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MemoryMb != nil {
+		in, out := &in.MemoryMb, &out.MemoryMb
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Microvms != nil {
+		in, out := &in.Microvms, &out.Microvms
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaLimits.
+func (in *QuotaLimits) DeepCopy() *QuotaLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(QuotaLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuotaUsage) DeepCopyInto(out *QuotaUsage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaUsage.
+func (in *QuotaUsage) DeepCopy() *QuotaUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(QuotaUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReconcileIntervals) DeepCopyInto(out *ReconcileIntervals) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReconcileIntervals.
+func (in *ReconcileIntervals) DeepCopy() *ReconcileIntervals {
+	if in == nil {
+		return nil
+	}
+	out := new(ReconcileIntervals)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaSetHostSummary) DeepCopyInto(out *ReplicaSetHostSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaSetHostSummary.
+func (in *ReplicaSetHostSummary) DeepCopy() *ReplicaSetHostSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaSetHostSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStatus) DeepCopyInto(out *RolloutStatus) {
+	*out = *in
+	if in.PhaseStartedAt != nil {
+		in, out := &in.PhaseStartedAt, &out.PhaseStartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CanaryReadyAt != nil {
+		in, out := &in.CanaryReadyAt, &out.CanaryReadyAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStatus.
+func (in *RolloutStatus) DeepCopy() *RolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStrategy) DeepCopyInto(out *RolloutStrategy) {
+	*out = *in
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CanaryRollout)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategy.
+func (in *RolloutStrategy) DeepCopy() *RolloutStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHService) DeepCopyInto(out *SSHService) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHService.
+func (in *SSHService) DeepCopy() *SSHService {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleEvent) DeepCopyInto(out *ScaleEvent) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleEvent.
+func (in *ScaleEvent) DeepCopy() *ScaleEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(ScaleEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScalingPolicy) DeepCopyInto(out *ScalingPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScalingPolicy.
+func (in *ScalingPolicy) DeepCopy() *ScalingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ScalingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScalingRules) DeepCopyInto(out *ScalingRules) {
+	*out = *in
+	if in.StabilizationWindowSeconds != nil {
+		in, out := &in.StabilizationWindowSeconds, &out.StabilizationWindowSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SelectPolicy != nil {
+		in, out := &in.SelectPolicy, &out.SelectPolicy
+		*out = new(ScalingPolicySelect)
+		**out = **in
+	}
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]ScalingPolicy, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScalingRules.
+func (in *ScalingRules) DeepCopy() *ScalingRules {
+	if in == nil {
+		return nil
+	}
+	out := new(ScalingRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpreadConstraints) DeepCopyInto(out *SpreadConstraints) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpreadConstraints.
+func (in *SpreadConstraints) DeepCopy() *SpreadConstraints {
+	if in == nil {
+		return nil
+	}
+	out := new(SpreadConstraints)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TCPSocketAction) DeepCopyInto(out *TCPSocketAction) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TCPSocketAction.
+func (in *TCPSocketAction) DeepCopy() *TCPSocketAction {
+	if in == nil {
+		return nil
+	}
+	out := new(TCPSocketAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateSource) DeepCopyInto(out *TemplateSource) {
+	*out = *in
+	if in.OCI != nil {
+		in, out := &in.OCI, &out.OCI
+		*out = new(OCITemplateSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateSource.
+func (in *TemplateSource) DeepCopy() *TemplateSource {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VendorData) DeepCopyInto(out *VendorData) {
+	*out = *in
+	if in.Packages != nil {
+		in, out := &in.Packages, &out.Packages
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RunCommands != nil {
+		in, out := &in.RunCommands, &out.RunCommands
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NTPServers != nil {
+		in, out := &in.NTPServers, &out.NTPServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VendorData.
+func (in *VendorData) DeepCopy() *VendorData {
+	if in == nil {
+		return nil
+	}
+	out := new(VendorData)
 	in.DeepCopyInto(out)
 	return out
 }
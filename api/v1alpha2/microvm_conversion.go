@@ -0,0 +1,522 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	infrav1alpha1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// ConvertTo converts this Microvm to the hub version (v1alpha1).
+func (src *Microvm) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*infrav1alpha1.Microvm) //nolint: forcetypeassert // only ever called with the hub
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = convertSpecTo(src.Spec)
+	dst.Status = convertStatusTo(src.Status)
+
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v1alpha1) to this version.
+func (dst *Microvm) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*infrav1alpha1.Microvm) //nolint: forcetypeassert // only ever called with the hub
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = convertSpecFrom(src.Spec)
+	dst.Status = convertStatusFrom(src.Status)
+
+	return nil
+}
+
+// ConvertTo converts this MicrovmList to the hub version (v1alpha1).
+func (src *MicrovmList) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*infrav1alpha1.MicrovmList) //nolint: forcetypeassert // only ever called with the hub
+
+	dst.ListMeta = src.ListMeta
+	dst.Items = make([]infrav1alpha1.Microvm, len(src.Items))
+
+	for i := range src.Items {
+		if err := src.Items[i].ConvertTo(&dst.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v1alpha1) to this version.
+func (dst *MicrovmList) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*infrav1alpha1.MicrovmList) //nolint: forcetypeassert // only ever called with the hub
+
+	dst.ListMeta = src.ListMeta
+	dst.Items = make([]Microvm, len(src.Items))
+
+	for i := range src.Items {
+		if err := dst.Items[i].ConvertFrom(&src.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func convertSpecTo(src MicrovmSpec) infrav1alpha1.MicrovmSpec {
+	dst := infrav1alpha1.MicrovmSpec{
+		Host: microvm.Host{
+			Name:     src.Placement.Host.Name,
+			Endpoint: string(src.Placement.Host.Endpoint),
+		},
+		VMSpec:          src.VMSpec,
+		UserData:        src.UserData,
+		SSHPublicKeys:   src.SSHPublicKeys,
+		Hostname:        src.Hostname,
+		HostVMNamespace: src.HostVMNamespace,
+		HostVMName:      src.HostVMName,
+		ProviderID:      src.ProviderID,
+		RestartPolicy:   infrav1alpha1.RestartPolicy(src.RestartPolicy),
+		UpdateStrategy:  infrav1alpha1.UpdateStrategy(src.UpdateStrategy),
+		ImagePolicy:     infrav1alpha1.ImagePolicy(src.ImagePolicy),
+		ReadinessGate:   infrav1alpha1.ReadinessGate(src.ReadinessGate),
+		RestoreFrom:     src.RestoreFrom,
+		ClassRef:        src.ClassRef,
+		MACPoolRef:      src.MACPoolRef,
+		Priority:        src.Priority,
+	}
+
+	if auth := src.Placement.Auth; auth != nil {
+		dst.TLSSecretRef = refName(auth.TLSSecretRef)
+		dst.BasicAuthSecret = refName(auth.BasicAuthSecretRef)
+	}
+
+	if src.Placement.Proxy != nil {
+		dst.MicrovmProxy = &flclient.Proxy{Endpoint: src.Placement.Proxy.Endpoint}
+	}
+
+	if src.GracefulShutdown != nil {
+		dst.GracefulShutdown = &infrav1alpha1.GracefulShutdown{
+			AgentEndpoint:      src.GracefulShutdown.AgentEndpoint,
+			GracePeriodSeconds: src.GracefulShutdown.GracePeriodSeconds,
+		}
+	}
+
+	if src.LivenessProbe != nil {
+		dst.LivenessProbe = convertProbeTo(src.LivenessProbe)
+	}
+
+	if src.SSHService != nil {
+		sshService := infrav1alpha1.SSHService(*src.SSHService)
+		dst.SSHService = &sshService
+	}
+
+	if src.GuestAgent != nil {
+		dst.GuestAgent = convertGuestAgentTo(src.GuestAgent)
+	}
+
+	if src.DNS != nil {
+		dns := infrav1alpha1.DNSConfig(*src.DNS)
+		dst.DNS = &dns
+	}
+
+	if src.VendorData != nil {
+		vendorData := infrav1alpha1.VendorData(*src.VendorData)
+		dst.VendorData = &vendorData
+	}
+
+	if src.IPPools != nil {
+		dst.IPPools = make([]infrav1alpha1.InterfaceIPPool, len(src.IPPools))
+
+		for i := range src.IPPools {
+			dst.IPPools[i] = infrav1alpha1.InterfaceIPPool(src.IPPools[i])
+		}
+	}
+
+	if src.ReconcileIntervals != nil {
+		intervals := infrav1alpha1.ReconcileIntervals(*src.ReconcileIntervals)
+		dst.ReconcileIntervals = &intervals
+	}
+
+	return dst
+}
+
+func convertSpecFrom(src infrav1alpha1.MicrovmSpec) MicrovmSpec {
+	dst := MicrovmSpec{
+		Placement: Placement{
+			Host: Host{
+				Name:     src.Host.Name,
+				Endpoint: HostEndpoint(src.Host.Endpoint),
+			},
+		},
+		VMSpec:          src.VMSpec,
+		UserData:        src.UserData,
+		SSHPublicKeys:   src.SSHPublicKeys,
+		Hostname:        src.Hostname,
+		HostVMNamespace: src.HostVMNamespace,
+		HostVMName:      src.HostVMName,
+		ProviderID:      src.ProviderID,
+		RestartPolicy:   RestartPolicy(src.RestartPolicy),
+		UpdateStrategy:  UpdateStrategy(src.UpdateStrategy),
+		ImagePolicy:     ImagePolicy(src.ImagePolicy),
+		ReadinessGate:   ReadinessGate(src.ReadinessGate),
+		RestoreFrom:     src.RestoreFrom,
+		ClassRef:        src.ClassRef,
+		MACPoolRef:      src.MACPoolRef,
+		Priority:        src.Priority,
+	}
+
+	if src.TLSSecretRef != "" || src.BasicAuthSecret != "" {
+		dst.Placement.Auth = &HostAuth{
+			TLSSecretRef:       localRef(src.TLSSecretRef),
+			BasicAuthSecretRef: localRef(src.BasicAuthSecret),
+		}
+	}
+
+	if src.MicrovmProxy != nil {
+		dst.Placement.Proxy = &Proxy{Endpoint: src.MicrovmProxy.Endpoint}
+	}
+
+	if src.GracefulShutdown != nil {
+		dst.GracefulShutdown = &GracefulShutdown{
+			AgentEndpoint:      src.GracefulShutdown.AgentEndpoint,
+			GracePeriodSeconds: src.GracefulShutdown.GracePeriodSeconds,
+		}
+	}
+
+	if src.LivenessProbe != nil {
+		dst.LivenessProbe = convertProbeFrom(src.LivenessProbe)
+	}
+
+	if src.SSHService != nil {
+		sshService := SSHService(*src.SSHService)
+		dst.SSHService = &sshService
+	}
+
+	if src.GuestAgent != nil {
+		dst.GuestAgent = convertGuestAgentFrom(src.GuestAgent)
+	}
+
+	if src.DNS != nil {
+		dns := DNSConfig(*src.DNS)
+		dst.DNS = &dns
+	}
+
+	if src.VendorData != nil {
+		vendorData := VendorData(*src.VendorData)
+		dst.VendorData = &vendorData
+	}
+
+	if src.IPPools != nil {
+		dst.IPPools = make([]InterfaceIPPool, len(src.IPPools))
+
+		for i := range src.IPPools {
+			dst.IPPools[i] = InterfaceIPPool(src.IPPools[i])
+		}
+	}
+
+	if src.ReconcileIntervals != nil {
+		intervals := ReconcileIntervals(*src.ReconcileIntervals)
+		dst.ReconcileIntervals = &intervals
+	}
+
+	return dst
+}
+
+func convertProbeTo(src *LivenessProbe) *infrav1alpha1.LivenessProbe {
+	dst := &infrav1alpha1.LivenessProbe{
+		InitialDelaySeconds: src.InitialDelaySeconds,
+		PeriodSeconds:       src.PeriodSeconds,
+		TimeoutSeconds:      src.TimeoutSeconds,
+		FailureThreshold:    src.FailureThreshold,
+	}
+
+	if src.TCPSocket != nil {
+		action := infrav1alpha1.TCPSocketAction(*src.TCPSocket)
+		dst.TCPSocket = &action
+	}
+
+	if src.HTTPGet != nil {
+		action := infrav1alpha1.HTTPGetAction(*src.HTTPGet)
+		dst.HTTPGet = &action
+	}
+
+	if src.AgentExec != nil {
+		action := infrav1alpha1.AgentExecAction(*src.AgentExec)
+		dst.AgentExec = &action
+	}
+
+	return dst
+}
+
+func convertProbeFrom(src *infrav1alpha1.LivenessProbe) *LivenessProbe {
+	dst := &LivenessProbe{
+		InitialDelaySeconds: src.InitialDelaySeconds,
+		PeriodSeconds:       src.PeriodSeconds,
+		TimeoutSeconds:      src.TimeoutSeconds,
+		FailureThreshold:    src.FailureThreshold,
+	}
+
+	if src.TCPSocket != nil {
+		action := TCPSocketAction(*src.TCPSocket)
+		dst.TCPSocket = &action
+	}
+
+	if src.HTTPGet != nil {
+		action := HTTPGetAction(*src.HTTPGet)
+		dst.HTTPGet = &action
+	}
+
+	if src.AgentExec != nil {
+		action := AgentExecAction(*src.AgentExec)
+		dst.AgentExec = &action
+	}
+
+	return dst
+}
+
+func convertGuestAgentTo(src *GuestAgent) *infrav1alpha1.GuestAgent {
+	dst := &infrav1alpha1.GuestAgent{
+		Endpoint:      src.Endpoint,
+		PeriodSeconds: src.PeriodSeconds,
+	}
+
+	if src.Commands != nil {
+		dst.Commands = make([]infrav1alpha1.GuestCommand, len(src.Commands))
+
+		for i := range src.Commands {
+			dst.Commands[i] = infrav1alpha1.GuestCommand(src.Commands[i])
+		}
+	}
+
+	return dst
+}
+
+func convertGuestAgentFrom(src *infrav1alpha1.GuestAgent) *GuestAgent {
+	dst := &GuestAgent{
+		Endpoint:      src.Endpoint,
+		PeriodSeconds: src.PeriodSeconds,
+	}
+
+	if src.Commands != nil {
+		dst.Commands = make([]GuestCommand, len(src.Commands))
+
+		for i := range src.Commands {
+			dst.Commands[i] = GuestCommand(src.Commands[i])
+		}
+	}
+
+	return dst
+}
+
+func convertStatusTo(src MicrovmStatus) infrav1alpha1.MicrovmStatus {
+	dst := infrav1alpha1.MicrovmStatus{
+		Ready:               src.Ready,
+		VMState:             src.VMState,
+		FailureReason:       src.FailureReason,
+		FailureMessage:      src.FailureMessage,
+		ShutdownRequestedAt: src.ShutdownRequestedAt,
+		DeletionStartedAt:   src.DeletionStartedAt,
+		PreviousProviderID:  src.PreviousProviderID,
+		RestartedAt:         src.RestartedAt,
+		ResolvedImages:      src.ResolvedImages,
+		HostAddress:         src.HostAddress,
+		SSHServiceName:      src.SSHServiceName,
+		Phase:               infrav1alpha1.Phase(src.Phase),
+		ObservedGeneration:  src.ObservedGeneration,
+		Conditions:          src.Conditions,
+	}
+
+	if src.ExternalResources != nil {
+		dst.ExternalResources = make([]infrav1alpha1.ExternalResourceRef, len(src.ExternalResources))
+
+		for i := range src.ExternalResources {
+			dst.ExternalResources[i] = infrav1alpha1.ExternalResourceRef(src.ExternalResources[i])
+		}
+	}
+
+	if src.GuestHealth != nil {
+		health := infrav1alpha1.GuestHealthStatus(*src.GuestHealth)
+		dst.GuestHealth = &health
+	}
+
+	if src.GuestInfo != nil {
+		dst.GuestInfo = convertGuestInfoTo(src.GuestInfo)
+	}
+
+	if src.PhoneHome != nil {
+		phoneHome := infrav1alpha1.PhoneHomeStatus(*src.PhoneHome)
+		dst.PhoneHome = &phoneHome
+	}
+
+	if src.DryRun != nil {
+		dst.DryRun = convertDryRunTo(src.DryRun)
+	}
+
+	if src.Provisioning != nil {
+		provisioning := infrav1alpha1.ProvisioningTimestamps(*src.Provisioning)
+		dst.Provisioning = &provisioning
+	}
+
+	return dst
+}
+
+func convertStatusFrom(src infrav1alpha1.MicrovmStatus) MicrovmStatus {
+	dst := MicrovmStatus{
+		Ready:               src.Ready,
+		VMState:             src.VMState,
+		FailureReason:       src.FailureReason,
+		FailureMessage:      src.FailureMessage,
+		ShutdownRequestedAt: src.ShutdownRequestedAt,
+		DeletionStartedAt:   src.DeletionStartedAt,
+		PreviousProviderID:  src.PreviousProviderID,
+		RestartedAt:         src.RestartedAt,
+		ResolvedImages:      src.ResolvedImages,
+		HostAddress:         src.HostAddress,
+		SSHServiceName:      src.SSHServiceName,
+		Phase:               Phase(src.Phase),
+		ObservedGeneration:  src.ObservedGeneration,
+		Conditions:          src.Conditions,
+	}
+
+	if src.ExternalResources != nil {
+		dst.ExternalResources = make([]ExternalResourceRef, len(src.ExternalResources))
+
+		for i := range src.ExternalResources {
+			dst.ExternalResources[i] = ExternalResourceRef(src.ExternalResources[i])
+		}
+	}
+
+	if src.GuestHealth != nil {
+		health := GuestHealthStatus(*src.GuestHealth)
+		dst.GuestHealth = &health
+	}
+
+	if src.GuestInfo != nil {
+		dst.GuestInfo = convertGuestInfoFrom(src.GuestInfo)
+	}
+
+	if src.PhoneHome != nil {
+		phoneHome := PhoneHomeStatus(*src.PhoneHome)
+		dst.PhoneHome = &phoneHome
+	}
+
+	if src.DryRun != nil {
+		dst.DryRun = convertDryRunFrom(src.DryRun)
+	}
+
+	if src.Provisioning != nil {
+		provisioning := ProvisioningTimestamps(*src.Provisioning)
+		dst.Provisioning = &provisioning
+	}
+
+	return dst
+}
+
+func convertDryRunTo(src *DryRunStatus) *infrav1alpha1.DryRunStatus {
+	dst := &infrav1alpha1.DryRunStatus{
+		ObservedGeneration: src.ObservedGeneration,
+		Omitted:            src.Omitted,
+	}
+
+	if src.Actions != nil {
+		dst.Actions = make([]infrav1alpha1.PlannedAction, len(src.Actions))
+
+		for i := range src.Actions {
+			dst.Actions[i] = infrav1alpha1.PlannedAction(src.Actions[i])
+		}
+	}
+
+	return dst
+}
+
+func convertDryRunFrom(src *infrav1alpha1.DryRunStatus) *DryRunStatus {
+	dst := &DryRunStatus{
+		ObservedGeneration: src.ObservedGeneration,
+		Omitted:            src.Omitted,
+	}
+
+	if src.Actions != nil {
+		dst.Actions = make([]PlannedAction, len(src.Actions))
+
+		for i := range src.Actions {
+			dst.Actions[i] = PlannedAction(src.Actions[i])
+		}
+	}
+
+	return dst
+}
+
+func convertGuestInfoTo(src *GuestInfo) *infrav1alpha1.GuestInfo {
+	dst := &infrav1alpha1.GuestInfo{
+		LastUpdateTime:  src.LastUpdateTime,
+		Hostname:        src.Hostname,
+		KernelVersion:   src.KernelVersion,
+		OSImage:         src.OSImage,
+		CloudInit:       infrav1alpha1.CloudInitState(src.CloudInit),
+		CloudInitErrors: src.CloudInitErrors,
+	}
+
+	if src.Commands != nil {
+		dst.Commands = make([]infrav1alpha1.GuestCommandResult, len(src.Commands))
+
+		for i := range src.Commands {
+			dst.Commands[i] = infrav1alpha1.GuestCommandResult(src.Commands[i])
+		}
+	}
+
+	return dst
+}
+
+func convertGuestInfoFrom(src *infrav1alpha1.GuestInfo) *GuestInfo {
+	dst := &GuestInfo{
+		LastUpdateTime:  src.LastUpdateTime,
+		Hostname:        src.Hostname,
+		KernelVersion:   src.KernelVersion,
+		OSImage:         src.OSImage,
+		CloudInit:       CloudInitState(src.CloudInit),
+		CloudInitErrors: src.CloudInitErrors,
+	}
+
+	if src.Commands != nil {
+		dst.Commands = make([]GuestCommandResult, len(src.Commands))
+
+		for i := range src.Commands {
+			dst.Commands[i] = GuestCommandResult(src.Commands[i])
+		}
+	}
+
+	return dst
+}
+
+func refName(ref *corev1.LocalObjectReference) string {
+	if ref == nil {
+		return ""
+	}
+
+	return ref.Name
+}
+
+func localRef(name string) *corev1.LocalObjectReference {
+	if name == "" {
+		return nil
+	}
+
+	return &corev1.LocalObjectReference{Name: name}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
+
+	infrav1alpha1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha2"
+)
+
+func TestFuzzyConversion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := infrav1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1alpha2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("for Microvm", utilconversion.FuzzTestFunc(utilconversion.FuzzTestFuncInput{
+		Scheme:      scheme,
+		Hub:         &infrav1alpha1.Microvm{},
+		Spoke:       &v1alpha2.Microvm{},
+		FuzzerFuncs: []fuzzer.FuzzerFuncs{fuzzFuncs},
+	}))
+}
+
+func fuzzFuncs(_ runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		placementFuzzer,
+	}
+}
+
+// placementFuzzer drops references with no name, as v1alpha1 stores them as
+// plain strings and cannot tell an empty reference apart from no reference.
+func placementFuzzer(in *v1alpha2.Placement, c fuzz.Continue) {
+	c.FuzzNoCustom(in)
+
+	if in.Auth == nil {
+		return
+	}
+
+	if in.Auth.TLSSecretRef != nil && in.Auth.TLSSecretRef.Name == "" {
+		in.Auth.TLSSecretRef = nil
+	}
+
+	if in.Auth.BasicAuthSecretRef != nil && in.Auth.BasicAuthSecretRef.Name == "" {
+		in.Auth.BasicAuthSecretRef = nil
+	}
+
+	if in.Auth.TLSSecretRef == nil && in.Auth.BasicAuthSecretRef == nil {
+		in.Auth = nil
+	}
+}
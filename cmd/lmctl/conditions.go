@@ -0,0 +1,114 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// conditionsObject is an object the operator reports conditions on.
+type conditionsObject interface {
+	client.Object
+	GetConditions() clusterv1.Conditions
+}
+
+// newConditionsObject returns an empty object of the given kind, which may be
+// named the way kubectl would name it.
+func newConditionsObject(kind string) (conditionsObject, bool) {
+	switch kind {
+	case "microvm", "microvms", "mvm":
+		return &infrav1.Microvm{}, true
+	case "microvmreplicaset", "microvmreplicasets", "replicaset", "mvmrs":
+		return &infrav1.MicrovmReplicaSet{}, true
+	case "microvmdeployment", "microvmdeployments", "deployment", "mvmd":
+		return &infrav1.MicrovmDeployment{}, true
+	case "microvmhost", "microvmhosts", "host", "mvmh":
+		return &infrav1.MicrovmHost{}, true
+	default:
+		return nil, false
+	}
+}
+
+func runConditions(ctx context.Context, args []string) error {
+	fs := newFlagSet("conditions", "<microvm|replicaset|deployment|host> <name>")
+	namespace := fs.String("n", "default", "Namespace of the object. Hosts are not namespaced.")
+	watch := fs.Bool("watch", false, "Keep printing the conditions each time they change.")
+	interval := fs.Duration("interval", 2*time.Second, "How often the object is checked with -watch.")
+
+	if err := parse(fs, args, 2); err != nil {
+		return err
+	}
+
+	obj, ok := newConditionsObject(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("unknown kind %q", fs.Arg(0))
+	}
+
+	key := client.ObjectKey{Namespace: *namespace, Name: fs.Arg(1)}
+	if _, isHost := obj.(*infrav1.MicrovmHost); isHost {
+		key.Namespace = ""
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	var last string
+
+	for {
+		if err := c.Get(ctx, key, obj); err != nil {
+			return fmt.Errorf("getting %s: %w", key.Name, err)
+		}
+
+		// only print again once something other than the age has changed
+		if current := fmt.Sprint(obj.GetConditions()); current != last {
+			if last != "" {
+				fmt.Println()
+			}
+
+			printConditions(obj.GetConditions())
+			last = current
+		}
+
+		if !*watch {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func printConditions(conditions clusterv1.Conditions) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "TYPE\tSTATUS\tSEVERITY\tREASON\tAGE\tMESSAGE")
+
+	for _, condition := range conditions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			condition.Type,
+			condition.Status,
+			orNone(string(condition.Severity)),
+			orNone(condition.Reason),
+			duration.HumanDuration(time.Since(condition.LastTransitionTime.Time)),
+			condition.Message,
+		)
+	}
+
+	_ = w.Flush()
+}
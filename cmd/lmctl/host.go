@@ -0,0 +1,99 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+func runDrain(ctx context.Context, args []string) error {
+	return setUnschedulable(ctx, "drain", args, true)
+}
+
+func runUncordon(ctx context.Context, args []string) error {
+	return setUnschedulable(ctx, "uncordon", args, false)
+}
+
+// setUnschedulable cordons or uncordons a host. MicrovmDeployments move the
+// replicas off an unschedulable host before removing its replicasets, so
+// cordoning a host is how it is drained.
+func setUnschedulable(ctx context.Context, name string, args []string, unschedulable bool) error {
+	fs := newFlagSet(name, "<host>")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: lmctl %s <host>\n\nThe host is the name or the endpoint of a MicrovmHost.\n", name)
+	}
+
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	host, err := findHost(ctx, c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if host.Spec.Unschedulable == unschedulable {
+		fmt.Printf("microvmhost/%s already %s\n", host.Name, schedulingState(unschedulable))
+
+		return nil
+	}
+
+	base := host.DeepCopy()
+	host.Spec.Unschedulable = unschedulable
+
+	if err := c.Patch(ctx, host, client.MergeFrom(base)); err != nil {
+		return fmt.Errorf("patching microvmhost %s: %w", host.Name, err)
+	}
+
+	fmt.Printf("microvmhost/%s %s\n", host.Name, schedulingState(unschedulable))
+
+	return nil
+}
+
+// findHost returns the MicrovmHost with the given name, or failing that the
+// one with the given endpoint.
+func findHost(ctx context.Context, c client.Client, nameOrEndpoint string) (*infrav1.MicrovmHost, error) {
+	host := &infrav1.MicrovmHost{}
+
+	err := c.Get(ctx, client.ObjectKey{Name: nameOrEndpoint}, host)
+	if err == nil {
+		return host, nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("getting microvmhost %s: %w", nameOrEndpoint, err)
+	}
+
+	hosts := &infrav1.MicrovmHostList{}
+	if err := c.List(ctx, hosts); err != nil {
+		return nil, fmt.Errorf("listing microvmhosts: %w", err)
+	}
+
+	for i := range hosts.Items {
+		if hosts.Items[i].Spec.Endpoint == nameOrEndpoint {
+			return &hosts.Items[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no microvmhost is named or has the endpoint %s", nameOrEndpoint)
+}
+
+func schedulingState(unschedulable bool) string {
+	if unschedulable {
+		return "cordoned and draining"
+	}
+
+	return "uncordoned"
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// none is printed in place of a value which has not been reported yet.
+const none = "-"
+
+func runList(ctx context.Context, args []string) error {
+	fs := newFlagSet("list", "")
+	namespace := fs.String("n", "default", "Namespace to list microvms in.")
+	allNamespaces := fs.Bool("A", false, "List microvms in every namespace.")
+	host := fs.String("host", "", "Only list the microvms on the host with this endpoint.")
+
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	opts := []client.ListOption{}
+	if !*allNamespaces {
+		opts = append(opts, client.InNamespace(*namespace))
+	}
+
+	mvms := &infrav1.MicrovmList{}
+	if err := c.List(ctx, mvms, opts...); err != nil {
+		return fmt.Errorf("listing microvms: %w", err)
+	}
+
+	sort.Slice(mvms.Items, func(i, j int) bool {
+		if mvms.Items[i].Namespace != mvms.Items[j].Namespace {
+			return mvms.Items[i].Namespace < mvms.Items[j].Namespace
+		}
+
+		return mvms.Items[i].Name < mvms.Items[j].Name
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	if *allNamespaces {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+
+	fmt.Fprintln(w, "NAME\tPHASE\tSTATE\tHOST\tADDRESS\tAGE")
+
+	for i := range mvms.Items {
+		mvm := &mvms.Items[i]
+		if *host != "" && mvm.Spec.Host.Endpoint != *host {
+			continue
+		}
+
+		if *allNamespaces {
+			fmt.Fprintf(w, "%s\t", mvm.Namespace)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			mvm.Name,
+			orNone(string(mvm.Status.Phase)),
+			orNone(vmState(mvm)),
+			orNone(mvm.Spec.Host.Endpoint),
+			orNone(guestAddress(mvm)),
+			duration.HumanDuration(time.Since(mvm.CreationTimestamp.Time)),
+		)
+	}
+
+	return w.Flush()
+}
+
+func vmState(mvm *infrav1.Microvm) string {
+	if mvm.Status.VMState == nil {
+		return ""
+	}
+
+	return string(*mvm.Status.VMState)
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return none
+	}
+
+	return value
+}
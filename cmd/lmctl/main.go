@@ -0,0 +1,147 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// lmctl inspects and manages microvms through the operator's custom resources.
+// It lists microvms along with where they run, follows the conditions the
+// operator reports on them, drains flintlock hosts and prints the commands to
+// reach a microvm over SSH. It uses the cluster from the current kubeconfig.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// errUsage is returned by a subcommand whose flags or arguments are wrong,
+// once it has printed its usage.
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand of lmctl. run is passed the arguments which follow
+// the name of the subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{name: "list", summary: "List microvms with their phase, state, host and address.", run: runList},
+	{name: "conditions", summary: "Print the conditions of an object, and follow them with -watch.", run: runConditions},
+	{name: "drain", summary: "Mark a host unschedulable so that its replicas are moved elsewhere.", run: runDrain},
+	{name: "uncordon", summary: "Mark a host schedulable again.", run: runUncordon},
+	{name: "ssh", summary: "Print the SSH command to reach a microvm, or to forward a port to it.", run: runSSH},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	cmd, ok := lookup(os.Args[1])
+	if !ok {
+		if os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+			usage(os.Stdout)
+
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "lmctl: unknown command %q\n\n", os.Args[1])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	if err := cmd.run(ctrl.SetupSignalHandler(), os.Args[2:]); err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			return
+		case errors.Is(err, errUsage):
+			os.Exit(2)
+		}
+
+		fmt.Fprintf(os.Stderr, "lmctl %s: %s\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+func lookup(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+
+	return command{}, false
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: lmctl <command> [flags] [args]\n\nCommands:\n")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+
+	fmt.Fprintf(w, "\nRun lmctl <command> -h for the flags of a command.\n")
+}
+
+func newClient() (client.Client, error) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(infrav1.AddToScheme(scheme))
+
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+
+	return c, nil
+}
+
+// newFlagSet returns the flags of a subcommand, which print their own usage
+// when they are wrong.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet("lmctl "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\nFlags:\n", strings.TrimSpace("lmctl "+name+" [flags] "+args))
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parse parses the flags of a subcommand and checks it was given nargs
+// arguments.
+func parse(fs *flag.FlagSet, args []string, nargs int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+
+		return errUsage
+	}
+
+	if fs.NArg() != nargs {
+		fmt.Fprintf(fs.Output(), "expected %d arguments, got %d\n", nargs, fs.NArg())
+		fs.Usage()
+
+		return errUsage
+	}
+
+	return nil
+}
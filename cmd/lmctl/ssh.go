@@ -0,0 +1,119 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+func runSSH(ctx context.Context, args []string) error {
+	fs := newFlagSet("ssh", "<microvm>")
+	namespace := fs.String("n", "default", "Namespace of the microvm.")
+	user := fs.String("user", "", "User to log in as. Defaults to the first user with SSH keys on the microvm.")
+	jump := fs.String("jump", "",
+		"Host to reach the microvm through, eg user@host. Defaults to the flintlock host of the microvm with -forward.")
+	forward := fs.String("forward", "",
+		"Forward a local port to the microvm instead of logging in, as <local port>:<microvm port>.")
+
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	mvm := &infrav1.Microvm{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: *namespace, Name: fs.Arg(0)}, mvm); err != nil {
+		return fmt.Errorf("getting microvm %s: %w", fs.Arg(0), err)
+	}
+
+	address := guestAddress(mvm)
+	if address == "" {
+		return fmt.Errorf("microvm %s has no static address; find its DHCP lease on %s", mvm.Name, orNone(hostName(mvm)))
+	}
+
+	if *forward != "" {
+		local, remote, ok := strings.Cut(*forward, ":")
+		if !ok {
+			return fmt.Errorf("-forward must be <local port>:<microvm port>, got %q", *forward)
+		}
+
+		via := *jump
+		if via == "" {
+			via = hostName(mvm)
+		}
+
+		if via == "" {
+			return fmt.Errorf("microvm %s has no host to forward through; set -jump", mvm.Name)
+		}
+
+		fmt.Printf("ssh -N -L %s:%s %s\n", local, net.JoinHostPort(address, remote), via)
+
+		return nil
+	}
+
+	login := *user
+	if login == "" && len(mvm.Spec.SSHPublicKeys) > 0 {
+		login = mvm.Spec.SSHPublicKeys[0].User
+	}
+
+	target := address
+	if login != "" {
+		target = login + "@" + address
+	}
+
+	if *jump != "" {
+		fmt.Printf("ssh -J %s %s\n", *jump, target)
+
+		return nil
+	}
+
+	fmt.Printf("ssh %s\n", target)
+
+	return nil
+}
+
+// guestAddress returns the first static address of the network interfaces of
+// the microvm, without its prefix length. Interfaces without one use DHCP,
+// whose leases the operator does not see.
+func guestAddress(mvm *infrav1.Microvm) string {
+	for _, iface := range mvm.Spec.NetworkInterfaces {
+		if iface.Address == "" {
+			continue
+		}
+
+		address, _, _ := strings.Cut(iface.Address, "/")
+
+		return address
+	}
+
+	return ""
+}
+
+// hostName returns the address of the flintlock host of the microvm without
+// its port, preferring the address it last answered on.
+func hostName(mvm *infrav1.Microvm) string {
+	for _, endpoint := range []string{mvm.Status.HostAddress, mvm.Spec.Host.Endpoint} {
+		if endpoint == "" {
+			continue
+		}
+
+		if host, _, err := net.SplitHostPort(endpoint); err == nil {
+			return host
+		}
+
+		return endpoint
+	}
+
+	return ""
+}
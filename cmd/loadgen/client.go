@@ -0,0 +1,132 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeAPIServer sets the fields on create which a real apiserver would, but
+// which the fake client leaves empty. Without a UID every object appears to be
+// controlled by every owner.
+type fakeAPIServer struct {
+	client.Client
+}
+
+func (f fakeAPIServer) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if obj.GetUID() == "" {
+		obj.SetUID(uuid.NewUUID())
+	}
+
+	return f.Client.Create(ctx, obj, opts...)
+}
+
+// countingClient records every request made to the apiserver, by verb.
+type countingClient struct {
+	client.Client
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newCountingClient(c client.Client) *countingClient {
+	return &countingClient{Client: c, counts: map[string]int{}}
+}
+
+func (c *countingClient) record(verb string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[verb]++
+}
+
+// Snapshot returns the counts so far and resets them.
+func (c *countingClient) Snapshot() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := c.counts
+	c.counts = map[string]int{}
+
+	return counts
+}
+
+func (c *countingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	c.record("get")
+
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *countingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	c.record("list")
+
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *countingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.record("create")
+
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *countingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.record("delete")
+
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *countingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.record("update")
+
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *countingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.record("patch")
+
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *countingClient) Status() client.StatusWriter {
+	return &countingStatusWriter{StatusWriter: c.Client.Status(), parent: c}
+}
+
+type countingStatusWriter struct {
+	client.StatusWriter
+
+	parent *countingClient
+}
+
+func (w *countingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	w.parent.record("update/status")
+
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *countingStatusWriter) Patch(
+	ctx context.Context,
+	obj client.Object,
+	patch client.Patch,
+	opts ...client.PatchOption,
+) error {
+	w.parent.record("patch/status")
+
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+func sortedVerbs(counts map[string]int) []string {
+	verbs := make([]string, 0, len(counts))
+	for verb := range counts {
+		verbs = append(verbs, verb)
+	}
+
+	sort.Strings(verbs)
+
+	return verbs
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+type reconciler interface {
+	Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error)
+}
+
+// driver stands in for the controller manager: rather than waiting on watches
+// it repeatedly reconciles every object in the namespace, top down, so that
+// each pass is a deterministic unit of work.
+type driver struct {
+	client    client.Client
+	namespace string
+
+	deployments reconciler
+	replicasets reconciler
+	microvms    reconciler
+
+	reconciles int
+	errors     int
+	latencies  []time.Duration
+}
+
+func (d *driver) reconcile(ctx context.Context, r reconciler, name string) {
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: d.namespace}}
+
+	start := time.Now()
+	_, err := r.Reconcile(ctx, req)
+	d.latencies = append(d.latencies, time.Since(start))
+	d.reconciles++
+
+	if err != nil {
+		d.errors++
+	}
+}
+
+// pass reconciles every deployment, then replicaset, then microvm once.
+func (d *driver) pass(ctx context.Context) error {
+	deployments := &infrav1.MicrovmDeploymentList{}
+	if err := d.client.List(ctx, deployments, client.InNamespace(d.namespace)); err != nil {
+		return fmt.Errorf("listing microvmdeployments: %w", err)
+	}
+
+	for _, obj := range deployments.Items {
+		d.reconcile(ctx, d.deployments, obj.Name)
+	}
+
+	replicasets := &infrav1.MicrovmReplicaSetList{}
+	if err := d.client.List(ctx, replicasets, client.InNamespace(d.namespace)); err != nil {
+		return fmt.Errorf("listing microvmreplicasets: %w", err)
+	}
+
+	for _, obj := range replicasets.Items {
+		d.reconcile(ctx, d.replicasets, obj.Name)
+	}
+
+	microvms := &infrav1.MicrovmList{}
+	if err := d.client.List(ctx, microvms, client.InNamespace(d.namespace)); err != nil {
+		return fmt.Errorf("listing microvms: %w", err)
+	}
+
+	for _, obj := range microvms.Items {
+		d.reconcile(ctx, d.microvms, obj.Name)
+	}
+
+	return nil
+}
+
+// runUntil makes passes until done returns true or the timeout expires.
+func (d *driver) runUntil(ctx context.Context, timeout time.Duration, done func(context.Context) (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		if err := d.pass(ctx); err != nil {
+			return err
+		}
+
+		finished, err := done(ctx)
+		if err != nil {
+			return err
+		}
+
+		if finished {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return errPhaseTimeout
+		}
+	}
+}
+
+// reset clears the counters collected since the last reset.
+func (d *driver) reset() {
+	d.reconciles = 0
+	d.errors = 0
+	d.latencies = nil
+}
+
+// percentile returns the p-th percentile reconcile latency since the last reset.
+func (d *driver) percentile(p float64) time.Duration {
+	if len(d.latencies) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration{}, d.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
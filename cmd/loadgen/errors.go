@@ -0,0 +1,12 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import "errors"
+
+var (
+	errStreamingUnsupported = errors.New("streaming is not supported by the in-memory backend")
+	errUnknownKind          = errors.New("unknown kind")
+	errPhaseTimeout         = errors.New("timed out waiting for phase to complete")
+)
@@ -0,0 +1,131 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// memoryFlintlock is an in-memory flintlock backend. Microvms are PENDING when
+// created and CREATED from the first Get onwards.
+type memoryFlintlock struct {
+	mu    sync.Mutex
+	vms   map[string]*flintlocktypes.MicroVM
+	next  uint64
+	calls uint64
+}
+
+func newMemoryFlintlock() *memoryFlintlock {
+	return &memoryFlintlock{vms: map[string]*flintlocktypes.MicroVM{}}
+}
+
+// Factory returns a FactoryFunc which hands out clients for this backend,
+// whatever the address.
+func (m *memoryFlintlock) Factory() flclient.FactoryFunc {
+	return func(_ string, _ ...flclient.Options) (flclient.Client, error) {
+		return m, nil
+	}
+}
+
+// Calls returns the number of API calls made against the backend.
+func (m *memoryFlintlock) Calls() uint64 {
+	return atomic.LoadUint64(&m.calls)
+}
+
+func (m *memoryFlintlock) CreateMicroVM(
+	_ context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	_ ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	atomic.AddUint64(&m.calls, 1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.next++
+	uid := fmt.Sprintf("loadgen-%d", m.next)
+
+	spec := in.Microvm
+	spec.Uid = &uid
+
+	vm := &flintlocktypes.MicroVM{
+		Spec:   spec,
+		Status: &flintlocktypes.MicroVMStatus{State: flintlocktypes.MicroVMStatus_PENDING},
+	}
+	m.vms[uid] = vm
+
+	return &flintlockv1.CreateMicroVMResponse{Microvm: vm}, nil
+}
+
+func (m *memoryFlintlock) DeleteMicroVM(
+	_ context.Context,
+	in *flintlockv1.DeleteMicroVMRequest,
+	_ ...grpc.CallOption,
+) (*emptypb.Empty, error) {
+	atomic.AddUint64(&m.calls, 1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.vms, in.Uid)
+
+	return &emptypb.Empty{}, nil
+}
+
+func (m *memoryFlintlock) GetMicroVM(
+	_ context.Context,
+	in *flintlockv1.GetMicroVMRequest,
+	_ ...grpc.CallOption,
+) (*flintlockv1.GetMicroVMResponse, error) {
+	atomic.AddUint64(&m.calls, 1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	vm, ok := m.vms[in.Uid]
+	if !ok {
+		return &flintlockv1.GetMicroVMResponse{}, nil
+	}
+
+	vm.Status.State = flintlocktypes.MicroVMStatus_CREATED
+
+	return &flintlockv1.GetMicroVMResponse{Microvm: vm}, nil
+}
+
+func (m *memoryFlintlock) ListMicroVMs(
+	_ context.Context,
+	_ *flintlockv1.ListMicroVMsRequest,
+	_ ...grpc.CallOption,
+) (*flintlockv1.ListMicroVMsResponse, error) {
+	atomic.AddUint64(&m.calls, 1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := &flintlockv1.ListMicroVMsResponse{}
+	for _, vm := range m.vms {
+		resp.Microvm = append(resp.Microvm, vm)
+	}
+
+	return resp, nil
+}
+
+func (m *memoryFlintlock) ListMicroVMsStream(
+	_ context.Context,
+	_ *flintlockv1.ListMicroVMsRequest,
+	_ ...grpc.CallOption,
+) (flintlockv1.MicroVM_ListMicroVMsStreamClient, error) {
+	return nil, errStreamingUnsupported
+}
+
+func (m *memoryFlintlock) Close() {}
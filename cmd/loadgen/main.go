@@ -0,0 +1,423 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// loadgen creates, scales and deletes large numbers of Microvm, MicrovmReplicaSet
+// or MicrovmDeployment objects and drives the operator's reconcilers over them
+// in-process, reporting reconcile throughput and the requests made to the
+// apiserver and to flintlock in each phase. By default both the apiserver and
+// flintlock are faked in memory, so that results are comparable between
+// releases.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers"
+)
+
+type options struct {
+	kind          string
+	count         int
+	replicas      int
+	scaleTo       int
+	hosts         int
+	namespace     string
+	flintlockHost string
+	realCluster   bool
+	timeout       time.Duration
+	jsonOutput    bool
+}
+
+// phaseResult is the measurement of a single phase of the run.
+type phaseResult struct {
+	Phase             string         `json:"phase"`
+	Duration          time.Duration  `json:"duration"`
+	Reconciles        int            `json:"reconciles"`
+	ReconcileErrors   int            `json:"reconcileErrors"`
+	ReconcilesPerSec  float64        `json:"reconcilesPerSecond"`
+	P50Latency        time.Duration  `json:"p50Latency"`
+	P99Latency        time.Duration  `json:"p99Latency"`
+	APIRequests       map[string]int `json:"apiRequests"`
+	FlintlockRequests uint64         `json:"flintlockRequests,omitempty"`
+}
+
+func main() {
+	opts := options{}
+
+	flag.StringVar(&opts.kind, "kind", "deployment", "Kind of object to create: microvm, replicaset or deployment.")
+	flag.IntVar(&opts.count, "count", 10, "Number of objects to create.")
+	flag.IntVar(&opts.replicas, "replicas", 2, "Initial replicas for each replicaset or deployment.")
+	flag.IntVar(&opts.scaleTo, "scale-to", 4, "Replicas to scale each replicaset or deployment to.")
+	flag.IntVar(&opts.hosts, "hosts", 2, "Number of hosts for each deployment.")
+	flag.StringVar(&opts.namespace, "namespace", "loadgen", "Namespace to create objects in.")
+	flag.StringVar(&opts.flintlockHost, "flintlock-address", "",
+		"Address of a real flintlock server. An in-memory backend is used when empty.")
+	flag.BoolVar(&opts.realCluster, "real-cluster", false,
+		"Use the cluster from the current kubeconfig rather than an in-memory apiserver. "+
+			"The operator must not be reconciling the namespace at the same time.")
+	flag.DurationVar(&opts.timeout, "timeout", 5*time.Minute, "Time allowed for each phase to complete.")
+	flag.BoolVar(&opts.jsonOutput, "json", false, "Print the results as JSON.")
+	flag.Parse()
+
+	results, err := run(context.Background(), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: %s\n", err)
+		os.Exit(1)
+	}
+
+	if opts.jsonOutput {
+		if err := json.NewEncoder(os.Stdout).Encode(results); err != nil {
+			fmt.Fprintf(os.Stderr, "loadgen: %s\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	printResults(results)
+}
+
+func run(ctx context.Context, opts options) ([]phaseResult, error) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(infrav1.AddToScheme(scheme))
+
+	var base client.Client
+
+	if opts.realCluster {
+		cfg, err := ctrl.GetConfig()
+		if err != nil {
+			return nil, fmt.Errorf("loading kubeconfig: %w", err)
+		}
+
+		base, err = client.New(cfg, client.Options{Scheme: scheme})
+		if err != nil {
+			return nil, fmt.Errorf("creating client: %w", err)
+		}
+	} else {
+		base = fakeAPIServer{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+	}
+
+	kube := newCountingClient(base)
+
+	var (
+		factory flclient.FactoryFunc = flclient.NewFlintlockClient
+		backend *memoryFlintlock
+	)
+
+	if opts.flintlockHost == "" {
+		backend = newMemoryFlintlock()
+		factory = backend.Factory()
+	}
+
+	d := &driver{
+		client:      kube,
+		namespace:   opts.namespace,
+		deployments: &controllers.MicrovmDeploymentReconciler{Client: kube, Scheme: scheme},
+		replicasets: &controllers.MicrovmReplicaSetReconciler{Client: kube, Scheme: scheme},
+		microvms:    &controllers.MicrovmReconciler{Client: kube, Scheme: scheme, MvmClientFunc: factory},
+	}
+
+	if err := ensureNamespace(ctx, base, opts.namespace); err != nil {
+		return nil, err
+	}
+
+	results := []phaseResult{}
+
+	measure := func(name string, phase func() error) error {
+		d.reset()
+		kube.Snapshot()
+
+		var flintlockBefore uint64
+		if backend != nil {
+			flintlockBefore = backend.Calls()
+		}
+
+		start := time.Now()
+
+		if err := phase(); err != nil {
+			return fmt.Errorf("%s phase: %w", name, err)
+		}
+
+		elapsed := time.Since(start)
+		result := phaseResult{
+			Phase:            name,
+			Duration:         elapsed,
+			Reconciles:       d.reconciles,
+			ReconcileErrors:  d.errors,
+			ReconcilesPerSec: float64(d.reconciles) / elapsed.Seconds(),
+			P50Latency:       d.percentile(0.5),  //nolint: gomnd // percentile
+			P99Latency:       d.percentile(0.99), //nolint: gomnd // percentile
+			APIRequests:      kube.Snapshot(),
+		}
+
+		if backend != nil {
+			result.FlintlockRequests = backend.Calls() - flintlockBefore
+		}
+
+		results = append(results, result)
+
+		return nil
+	}
+
+	objects, err := buildObjects(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := measure("create", func() error {
+		for _, obj := range objects {
+			if err := base.Create(ctx, obj); err != nil {
+				return fmt.Errorf("creating %s: %w", obj.GetName(), err)
+			}
+		}
+
+		return d.runUntil(ctx, opts.timeout, allReady(base, opts))
+	}); err != nil {
+		return results, err
+	}
+
+	if opts.kind != "microvm" {
+		if err := measure("scale", func() error {
+			if err := scaleObjects(ctx, base, opts); err != nil {
+				return err
+			}
+
+			return d.runUntil(ctx, opts.timeout, allReady(base, opts))
+		}); err != nil {
+			return results, err
+		}
+	}
+
+	if err := measure("delete", func() error {
+		for _, obj := range objects {
+			if err := base.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("deleting %s: %w", obj.GetName(), err)
+			}
+		}
+
+		return d.runUntil(ctx, opts.timeout, allGone(base, opts.namespace))
+	}); err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+func ensureNamespace(ctx context.Context, c client.Client, name string) error {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if err := c.Create(ctx, ns); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating namespace: %w", err)
+	}
+
+	return nil
+}
+
+func buildObjects(opts options) ([]client.Object, error) {
+	hosts := make([]microvm.Host, opts.hosts)
+	for i := range hosts {
+		hosts[i] = microvm.Host{Name: fmt.Sprintf("host-%d", i), Endpoint: hostEndpoint(opts, i)}
+	}
+
+	objects := []client.Object{}
+
+	for i := 0; i < opts.count; i++ {
+		meta := metav1.ObjectMeta{Name: fmt.Sprintf("loadgen-%d", i), Namespace: opts.namespace}
+		template := infrav1.MicrovmTemplateSpec{Spec: microvmSpec(hosts[i%len(hosts)])}
+
+		switch opts.kind {
+		case "microvm":
+			objects = append(objects, &infrav1.Microvm{ObjectMeta: meta, Spec: template.Spec})
+		case "replicaset":
+			objects = append(objects, &infrav1.MicrovmReplicaSet{
+				ObjectMeta: meta,
+				Spec: infrav1.MicrovmReplicaSetSpec{
+					Replicas: pointer.Int32(int32(opts.replicas)),
+					Host:     hosts[i%len(hosts)],
+					Template: template,
+				},
+			})
+		case "deployment":
+			objects = append(objects, &infrav1.MicrovmDeployment{
+				ObjectMeta: meta,
+				Spec: infrav1.MicrovmDeploymentSpec{
+					Replicas: pointer.Int32(int32(opts.replicas)),
+					Hosts:    hosts,
+					Template: template,
+				},
+			})
+		default:
+			return nil, fmt.Errorf("%w: %s", errUnknownKind, opts.kind)
+		}
+	}
+
+	return objects, nil
+}
+
+func hostEndpoint(opts options, i int) string {
+	if opts.flintlockHost != "" {
+		return opts.flintlockHost
+	}
+
+	return fmt.Sprintf("loadgen-host-%d:9090", i)
+}
+
+func microvmSpec(host microvm.Host) infrav1.MicrovmSpec {
+	return infrav1.MicrovmSpec{
+		Host: host,
+		VMSpec: microvm.VMSpec{
+			VCPU:     1,
+			MemoryMb: 512, //nolint: gomnd // small test vm
+			RootVolume: microvm.Volume{
+				Image: "ghcr.io/weaveworks-liquidmetal/capmvm-kubernetes:1.21.8",
+			},
+			Kernel: microvm.ContainerFileSource{
+				Image:    "ghcr.io/weaveworks-liquidmetal/flintlock-kernel:5.10.77",
+				Filename: "boot/vmlinux",
+			},
+			NetworkInterfaces: []microvm.NetworkInterface{
+				{GuestDeviceName: "eth1", Type: microvm.IfaceTypeMacvtap},
+			},
+		},
+	}
+}
+
+func scaleObjects(ctx context.Context, c client.Client, opts options) error {
+	replicas := pointer.Int32(int32(opts.scaleTo))
+
+	switch opts.kind {
+	case "replicaset":
+		list := &infrav1.MicrovmReplicaSetList{}
+		if err := c.List(ctx, list, client.InNamespace(opts.namespace)); err != nil {
+			return fmt.Errorf("listing microvmreplicasets: %w", err)
+		}
+
+		for i := range list.Items {
+			list.Items[i].Spec.Replicas = replicas
+			if err := c.Update(ctx, &list.Items[i]); err != nil {
+				return fmt.Errorf("scaling %s: %w", list.Items[i].Name, err)
+			}
+		}
+	case "deployment":
+		list := &infrav1.MicrovmDeploymentList{}
+		if err := c.List(ctx, list, client.InNamespace(opts.namespace)); err != nil {
+			return fmt.Errorf("listing microvmdeployments: %w", err)
+		}
+
+		for i := range list.Items {
+			list.Items[i].Spec.Replicas = replicas
+			if err := c.Update(ctx, &list.Items[i]); err != nil {
+				return fmt.Errorf("scaling %s: %w", list.Items[i].Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// allReady reports whether every top level object created by the run is ready.
+func allReady(c client.Client, opts options) func(context.Context) (bool, error) {
+	return func(ctx context.Context) (bool, error) {
+		inNamespace := client.InNamespace(opts.namespace)
+
+		switch opts.kind {
+		case "microvm":
+			list := &infrav1.MicrovmList{}
+			if err := c.List(ctx, list, inNamespace); err != nil {
+				return false, err
+			}
+
+			for _, obj := range list.Items {
+				if !obj.Status.Ready {
+					return false, nil
+				}
+			}
+		case "replicaset":
+			list := &infrav1.MicrovmReplicaSetList{}
+			if err := c.List(ctx, list, inNamespace); err != nil {
+				return false, err
+			}
+
+			for _, obj := range list.Items {
+				if !obj.Status.Ready || obj.Status.ReadyReplicas != *obj.Spec.Replicas {
+					return false, nil
+				}
+			}
+		case "deployment":
+			list := &infrav1.MicrovmDeploymentList{}
+			if err := c.List(ctx, list, inNamespace); err != nil {
+				return false, err
+			}
+
+			for _, obj := range list.Items {
+				if !obj.Status.Ready || obj.Status.ReadyReplicas != *obj.Spec.Replicas*int32(len(obj.Spec.Hosts)) {
+					return false, nil
+				}
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// allGone reports whether every object of every kind has been removed.
+func allGone(c client.Client, namespace string) func(context.Context) (bool, error) {
+	return func(ctx context.Context) (bool, error) {
+		for _, list := range []client.ObjectList{
+			&infrav1.MicrovmDeploymentList{},
+			&infrav1.MicrovmReplicaSetList{},
+			&infrav1.MicrovmList{},
+		} {
+			if err := c.List(ctx, list, client.InNamespace(namespace)); err != nil {
+				return false, err
+			}
+
+			if items, _ := meta.ExtractList(list); len(items) > 0 {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	}
+}
+
+func printResults(results []phaseResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint: gomnd // padding
+
+	fmt.Fprintln(w, "PHASE\tDURATION\tRECONCILES\tERRORS\tRECONCILES/S\tP50\tP99\tFLINTLOCK\tAPI REQUESTS")
+
+	for _, r := range results {
+		requests := ""
+		for _, verb := range sortedVerbs(r.APIRequests) {
+			requests += fmt.Sprintf("%s=%d ", verb, r.APIRequests[verb])
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.1f\t%s\t%s\t%d\t%s\n",
+			r.Phase, r.Duration.Round(time.Millisecond), r.Reconciles, r.ReconcileErrors, r.ReconcilesPerSec,
+			r.P50Latency.Round(time.Microsecond), r.P99Latency.Round(time.Microsecond), r.FlintlockRequests, requests)
+	}
+
+	w.Flush()
+}
@@ -10,5 +10,12 @@ var (
 	errClientFactoryFuncRequired = errors.New("factory function required to create grpc client")
 	errMicrovmFailed             = errors.New("microvm is in a failed state")
 	errMicrovmUnknownState       = errors.New("microvm is in an unknown/unsupported state")
+	errMetricSourceRequired      = errors.New("metric source configuration is required")
+	errMetricSourceFuncRequired  = errors.New("factory function required to create metric source")
+	errHealthRecorderRequired    = errors.New("health recorder required to summarise host error budgets")
+	errFetcherFuncRequired       = errors.New("factory function required to fetch templates from a registry")
+	errImageResolverRequired     = errors.New("image resolver required to resolve image tags to digests")
+	errSelectorMismatch          = errors.New("selector does not match template labels")
+	errTLSSecretNamespace        = errors.New("default tls secret must set a namespace to be used for microvmhosts")
 	// errNoPlacement                  = errors.New("no placement specified")
 )
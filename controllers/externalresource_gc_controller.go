@@ -0,0 +1,141 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+// ExternalResourceGCReconciler deletes Services labelled with the UID of a
+// Microvm which no longer exists. It catches anything which the Microvm delete
+// path did not release, such as resources created but never tracked because
+// the status patch failed.
+type ExternalResourceGCReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch
+
+func (r *ExternalResourceGCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, req.NamespacedName, svc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "error getting service")
+
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
+	}
+
+	uid, ok := svc.Labels[infrav1.MicrovmUIDLabel]
+	if !ok || !svc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	owned, err := r.microvmExists(ctx, svc.Namespace, uid)
+	if err != nil || owned {
+		return ctrl.Result{}, err
+	}
+
+	log.Info("deleting service of deleted microvm", "microvmUID", uid)
+
+	if err := r.Delete(ctx, svc); err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, fmt.Errorf("deleting service: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// microvmExists returns true if a Microvm with the UID exists in the namespace.
+func (r *ExternalResourceGCReconciler) microvmExists(ctx context.Context, namespace, uid string) (bool, error) {
+	mvms := &infrav1.MicrovmList{}
+	if err := r.List(ctx, mvms, client.InNamespace(namespace)); err != nil {
+		return false, fmt.Errorf("listing microvms: %w", err)
+	}
+
+	for _, mvm := range mvms.Items {
+		if string(mvm.UID) == uid {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// microvmToServices maps a Microvm to the Services labelled with its UID, so
+// that they are collected as soon as it is gone.
+func (r *ExternalResourceGCReconciler) microvmToServices(obj client.Object) []reconcile.Request {
+	svcs := &corev1.ServiceList{}
+	if err := r.List(context.Background(), svcs,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingLabels{infrav1.MicrovmUIDLabel: string(obj.GetUID())},
+	); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, svc := range svcs.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name},
+		})
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ExternalResourceGCReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	labelled := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := obj.GetLabels()[infrav1.MicrovmUIDLabel]
+
+		return ok
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("externalresourcegc").
+		For(&corev1.Service{}, builder.WithPredicates(labelled)).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "externalresourcegc", logging.ServiceKey)).
+		Watches(
+			&source.Kind{Type: &infrav1.Microvm{}},
+			handler.EnqueueRequestsFromMapFunc(r.microvmToServices),
+		).
+		Complete(tracing.Reconciler("externalresourcegc", r))
+}
@@ -0,0 +1,53 @@
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestExternalResourceGC_Reconcile(t *testing.T) {
+	tt := []struct {
+		name      string
+		uid       string
+		collected bool
+	}{
+		{
+			name:      "service of an existing microvm is kept",
+			uid:       "mvm-uid",
+			collected: false,
+		},
+		{
+			name:      "service of a deleted microvm is collected",
+			uid:       "deleted-uid",
+			collected: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.UID = "mvm-uid"
+
+			objects := []runtime.Object{mvm, createMicrovmService("svc1", tc.uid)}
+			c := createFakeClient(g, objects)
+
+			_, err := reconcileExternalResourceGC(c, "svc1")
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a labelled service should not error")
+
+			err = c.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: "svc1"}, &corev1.Service{})
+			if tc.collected {
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the service to be collected")
+			} else {
+				g.Expect(err).NotTo(HaveOccurred(), "Expected the service to be kept")
+			}
+		})
+	}
+}
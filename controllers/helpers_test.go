@@ -7,11 +7,13 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"time"
 
 	. "github.com/onsi/gomega"
 	"gopkg.in/yaml.v2"
 
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -21,6 +23,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
 
 	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
@@ -31,13 +34,32 @@ import (
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
 	"github.com/weaveworks-liquidmetal/microvm-operator/controllers"
 	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply/applytest"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/autoscaler"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestagent"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/health"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/heartbeat"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/oci"
 )
 
 const (
 	testNamespace             = "ns1"
 	testMicrovmName           = "mvm1"
 	testMicrovmReplicaSetName = "rs1"
+	testMicrovmReplicaSetUID  = "rs1-uid"
 	testMicrovmDeploymentName = "d1"
+	testMicrovmDeploymentUID  = "d1-uid"
+	testMicrovmAutoscalerName = "as1"
+	testMicrovmHostName       = "host1"
+	testMicrovmTemplateName   = "t1"
+	testMicrovmHostGroupName  = "hg1"
+	testMicrovmSnapshotName   = "snap1"
+	testMicrovmQuotaName      = "quota1"
+	testMicrovmMACPoolName    = "macs1"
+	testMicrovmIPPoolName     = "ips1"
+	testHostEndpoint          = "127.0.0.1:9090"
 	testMicrovmUID            = "ABCDEF123456"
 	testBootstrapData         = "somesamplebootstrapsdata"
 )
@@ -53,11 +75,19 @@ func asRuntimeObject(microvm *infrav1.Microvm) []runtime.Object {
 }
 
 func reconcileMicrovm(client client.Client, mockAPIClient flclient.Client) (ctrl.Result, error) {
-	mvmController := &controllers.MicrovmReconciler{
-		Client: client,
-		MvmClientFunc: func(address string, opts ...flclient.Options) (flclient.Client, error) {
-			return mockAPIClient, nil
-		},
+	return reconcileMicrovmWith(client, mockAPIClient, &controllers.MicrovmReconciler{})
+}
+
+// reconcileMicrovmWith reconciles the test microvm with mvmController, after
+// pointing it at client and at a flintlock host served by mockAPIClient.
+func reconcileMicrovmWith(
+	client client.Client,
+	mockAPIClient flclient.Client,
+	mvmController *controllers.MicrovmReconciler,
+) (ctrl.Result, error) {
+	mvmController.Client = client
+	mvmController.MvmClientFunc = func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		return mockAPIClient, nil
 	}
 
 	request := ctrl.Request{
@@ -70,12 +100,64 @@ func reconcileMicrovm(client client.Client, mockAPIClient flclient.Client) (ctrl
 	return mvmController.Reconcile(context.TODO(), request)
 }
 
-func reconcileMicrovmReplicaSet(client client.Client) (ctrl.Result, error) {
-	mvmRSController := &controllers.MicrovmReplicaSetReconciler{
+func reconcileExternalResourceGC(client client.Client, serviceName string) (ctrl.Result, error) {
+	gcController := &controllers.ExternalResourceGCReconciler{
 		Client: client,
 		Scheme: client.Scheme(),
 	}
 
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      serviceName,
+			Namespace: testNamespace,
+		},
+	}
+
+	return gcController.Reconcile(context.TODO(), request)
+}
+
+func reconcileOrphanedMicrovmGC(client client.Client, mockAPIClient flclient.Client) (ctrl.Result, error) {
+	return reconcileOrphanedMicrovmGCWithConfig(client, mockAPIClient, nil)
+}
+
+func reconcileOrphanedMicrovmGCWithConfig(
+	client client.Client,
+	mockAPIClient flclient.Client,
+	cfg *config.Store,
+) (ctrl.Result, error) {
+	gcController := &controllers.OrphanedMicrovmGCReconciler{
+		Client: client,
+		Scheme: client.Scheme(),
+		MvmClientFunc: func(address string, opts ...flclient.Options) (flclient.Client, error) {
+			return mockAPIClient, nil
+		},
+		Config: cfg,
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name: testMicrovmHostName,
+		},
+	}
+
+	return gcController.Reconcile(context.TODO(), request)
+}
+
+func reconcileMicrovmReplicaSet(client client.Client) (ctrl.Result, error) {
+	return reconcileMicrovmReplicaSetWithConfig(client, nil)
+}
+
+func reconcileMicrovmReplicaSetWithConfig(client client.Client, cfg *config.Store) (ctrl.Result, error) {
+	return reconcileMicrovmReplicaSetWith(&controllers.MicrovmReplicaSetReconciler{
+		Client:   client,
+		Scheme:   client.Scheme(),
+		Config:   cfg,
+		Failover: true,
+	})
+}
+
+func reconcileMicrovmReplicaSetWith(mvmRSController *controllers.MicrovmReplicaSetReconciler) (ctrl.Result, error) {
+
 	request := ctrl.Request{
 		NamespacedName: types.NamespacedName{
 			Name:      testMicrovmReplicaSetName,
@@ -99,9 +181,37 @@ func reconcileMicrovmReplicaSetNTimes(g *WithT, client client.Client, count int3
 }
 
 func reconcileMicrovmDeployment(client client.Client) (ctrl.Result, error) {
+	return reconcileMicrovmDeploymentNamed(client, testMicrovmDeploymentName)
+}
+
+func reconcileMicrovmDeploymentNamed(client client.Client, name string) (ctrl.Result, error) {
 	mvmDepController := &controllers.MicrovmDeploymentReconciler{
-		Client: client,
-		Scheme: client.Scheme(),
+		Client:   client,
+		Scheme:   client.Scheme(),
+		Failover: true,
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      name,
+			Namespace: testNamespace,
+		},
+	}
+
+	return mvmDepController.Reconcile(context.TODO(), request)
+}
+
+func reconcileMicrovmDeploymentWithHeartbeats(
+	client client.Client,
+	cfg *config.Store,
+	heartbeats *heartbeat.Registry,
+) (ctrl.Result, error) {
+	mvmDepController := &controllers.MicrovmDeploymentReconciler{
+		Client:     client,
+		Scheme:     client.Scheme(),
+		Config:     cfg,
+		Heartbeats: heartbeats,
+		Failover:   true,
 	}
 
 	request := ctrl.Request{
@@ -126,6 +236,141 @@ func reconcileMicrovmDeploymentNTimes(g *WithT, client client.Client, count int,
 	return nil
 }
 
+func reconcileMicrovmAutoscaler(client client.Client, sourceFunc autoscaler.SourceFunc) (ctrl.Result, error) {
+	mvmAutoscalerController := &controllers.MicrovmAutoscalerReconciler{
+		Client:     client,
+		Scheme:     client.Scheme(),
+		SourceFunc: sourceFunc,
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      testMicrovmAutoscalerName,
+			Namespace: testNamespace,
+		},
+	}
+
+	return mvmAutoscalerController.Reconcile(context.TODO(), request)
+}
+
+func reconcileMicrovmHost(client client.Client, recorder *health.Recorder) (ctrl.Result, error) {
+	return reconcileMicrovmHostWithProber(client, recorder, nil)
+}
+
+func reconcileMicrovmHostWithProber(client client.Client, recorder *health.Recorder, prober identity.Prober) (ctrl.Result, error) {
+	mvmHostController := &controllers.MicrovmHostReconciler{
+		Client:         client,
+		Scheme:         client.Scheme(),
+		Recorder:       recorder,
+		IdentityProber: prober,
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name: testMicrovmHostName,
+		},
+	}
+
+	return mvmHostController.Reconcile(context.TODO(), request)
+}
+
+func reconcileMicrovmHostGroup(client client.Client) (ctrl.Result, error) {
+	mvmHostGroupController := &controllers.MicrovmHostGroupReconciler{
+		Client: client,
+		Scheme: client.Scheme(),
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      testMicrovmHostGroupName,
+			Namespace: testNamespace,
+		},
+	}
+
+	return mvmHostGroupController.Reconcile(context.TODO(), request)
+}
+
+func reconcileMicrovmSnapshot(client client.Client) (ctrl.Result, error) {
+	mvmSnapshotController := &controllers.MicrovmSnapshotReconciler{
+		Client: client,
+		Scheme: client.Scheme(),
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      testMicrovmSnapshotName,
+			Namespace: testNamespace,
+		},
+	}
+
+	return mvmSnapshotController.Reconcile(context.TODO(), request)
+}
+
+func reconcileMicrovmMACPool(client client.Client) (ctrl.Result, error) {
+	mvmMACPoolController := &controllers.MicrovmMACPoolReconciler{
+		Client: client,
+		Scheme: client.Scheme(),
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      testMicrovmMACPoolName,
+			Namespace: testNamespace,
+		},
+	}
+
+	return mvmMACPoolController.Reconcile(context.TODO(), request)
+}
+
+func reconcileMicrovmIPPool(client client.Client) (ctrl.Result, error) {
+	mvmIPPoolController := &controllers.MicrovmIPPoolReconciler{
+		Client: client,
+		Scheme: client.Scheme(),
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      testMicrovmIPPoolName,
+			Namespace: testNamespace,
+		},
+	}
+
+	return mvmIPPoolController.Reconcile(context.TODO(), request)
+}
+
+func reconcileMicrovmQuota(client client.Client) (ctrl.Result, error) {
+	mvmQuotaController := &controllers.MicrovmQuotaReconciler{
+		Client: client,
+		Scheme: client.Scheme(),
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      testMicrovmQuotaName,
+			Namespace: testNamespace,
+		},
+	}
+
+	return mvmQuotaController.Reconcile(context.TODO(), request)
+}
+
+func reconcileMicrovmTemplate(client client.Client, fetcherFunc oci.FetcherFunc) (ctrl.Result, error) {
+	mvmTemplateController := &controllers.MicrovmTemplateReconciler{
+		Client:      client,
+		Scheme:      client.Scheme(),
+		FetcherFunc: fetcherFunc,
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      testMicrovmTemplateName,
+			Namespace: testNamespace,
+		},
+	}
+
+	return mvmTemplateController.Reconcile(context.TODO(), request)
+}
+
 func getMicrovm(c client.Client, name, namespace string) (*infrav1.Microvm, error) {
 	key := client.ObjectKey{
 		Name:      name,
@@ -171,13 +416,102 @@ func getMicrovmDeployment(c client.Client, name, namespace string) (*infrav1.Mic
 	return mvmD, err
 }
 
+func getMicrovmAutoscaler(c client.Client, name, namespace string) (*infrav1.MicrovmAutoscaler, error) {
+	key := client.ObjectKey{
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	mvmA := &infrav1.MicrovmAutoscaler{}
+	err := c.Get(context.TODO(), key, mvmA)
+	return mvmA, err
+}
+
+func getMicrovmHost(c client.Client, name string) (*infrav1.MicrovmHost, error) {
+	key := client.ObjectKey{
+		Name: name,
+	}
+
+	mvmH := &infrav1.MicrovmHost{}
+	err := c.Get(context.TODO(), key, mvmH)
+	return mvmH, err
+}
+
+func getMicrovmHostGroup(c client.Client, name, namespace string) (*infrav1.MicrovmHostGroup, error) {
+	key := client.ObjectKey{
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	mvmHG := &infrav1.MicrovmHostGroup{}
+	err := c.Get(context.TODO(), key, mvmHG)
+	return mvmHG, err
+}
+
+func getMicrovmSnapshot(c client.Client, name, namespace string) (*infrav1.MicrovmSnapshot, error) {
+	key := client.ObjectKey{
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	mvmSnap := &infrav1.MicrovmSnapshot{}
+	err := c.Get(context.TODO(), key, mvmSnap)
+	return mvmSnap, err
+}
+
+func getMicrovmMACPool(c client.Client, name, namespace string) (*infrav1.MicrovmMACPool, error) {
+	key := client.ObjectKey{
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	pool := &infrav1.MicrovmMACPool{}
+	err := c.Get(context.TODO(), key, pool)
+	return pool, err
+}
+
+func getMicrovmIPPool(c client.Client, name, namespace string) (*infrav1.MicrovmIPPool, error) {
+	key := client.ObjectKey{
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	pool := &infrav1.MicrovmIPPool{}
+	err := c.Get(context.TODO(), key, pool)
+	return pool, err
+}
+
+func getMicrovmQuota(c client.Client, name, namespace string) (*infrav1.MicrovmQuota, error) {
+	key := client.ObjectKey{
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	mvmQ := &infrav1.MicrovmQuota{}
+	err := c.Get(context.TODO(), key, mvmQ)
+	return mvmQ, err
+}
+
+func getMicrovmTemplate(c client.Client, name, namespace string) (*infrav1.MicrovmTemplate, error) {
+	key := client.ObjectKey{
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	mvmT := &infrav1.MicrovmTemplate{}
+	err := c.Get(context.TODO(), key, mvmT)
+	return mvmT, err
+}
+
 func createFakeClient(g *WithT, objects []runtime.Object) client.Client {
 	scheme := runtime.NewScheme()
 
 	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
 	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(discoveryv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(expv1.AddToScheme(scheme)).To(Succeed())
 
-	return fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+	return applytest.NewClient(fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build())
 }
 
 func createMicrovm() *infrav1.Microvm {
@@ -188,7 +522,7 @@ func createMicrovm() *infrav1.Microvm {
 		},
 		Spec: infrav1.MicrovmSpec{
 			Host: microvm.Host{
-				Endpoint: "127.0.0.1:9090",
+				Endpoint: testHostEndpoint,
 			},
 			ProviderID: pointer.String(testMicrovmUID),
 			VMSpec: microvm.VMSpec{
@@ -219,6 +553,94 @@ func createMicrovm() *infrav1.Microvm {
 	}
 }
 
+func createMicrovmService(name, uid string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels:    map[string]string{infrav1.MicrovmUIDLabel: uid},
+		},
+	}
+}
+
+func createMicrovmHost() *infrav1.MicrovmHost {
+	return &infrav1.MicrovmHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: testMicrovmHostName,
+		},
+		Spec: infrav1.MicrovmHostSpec{
+			Endpoint: testHostEndpoint,
+			ErrorBudget: infrav1.ErrorBudget{
+				MinAttempts: 4,
+			},
+		},
+	}
+}
+
+func createMicrovmHostGroup(hosts ...microvm.Host) *infrav1.MicrovmHostGroup {
+	return &infrav1.MicrovmHostGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testMicrovmHostGroupName,
+			Namespace: testNamespace,
+		},
+		Spec: infrav1.MicrovmHostGroupSpec{
+			Hosts: hosts,
+		},
+	}
+}
+
+func createMicrovmSnapshot(vmSpec *microvm.VMSpec) *infrav1.MicrovmSnapshot {
+	return &infrav1.MicrovmSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testMicrovmSnapshotName,
+			Namespace: testNamespace,
+		},
+		Spec: infrav1.MicrovmSnapshotSpec{
+			SourceRef: corev1.LocalObjectReference{Name: testMicrovmName},
+		},
+		Status: infrav1.MicrovmSnapshotStatus{
+			Ready:  vmSpec != nil,
+			VMSpec: vmSpec,
+		},
+	}
+}
+
+func createMicrovmMACPool(addresses ...string) *infrav1.MicrovmMACPool {
+	return &infrav1.MicrovmMACPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testMicrovmMACPoolName,
+			Namespace: testNamespace,
+		},
+		Spec: infrav1.MicrovmMACPoolSpec{
+			Addresses: addresses,
+		},
+	}
+}
+
+func createMicrovmIPPool(subnet string) *infrav1.MicrovmIPPool {
+	return &infrav1.MicrovmIPPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testMicrovmIPPoolName,
+			Namespace: testNamespace,
+		},
+		Spec: infrav1.MicrovmIPPoolSpec{
+			Subnet: subnet,
+		},
+	}
+}
+
+func createMicrovmQuota(hard infrav1.QuotaLimits) *infrav1.MicrovmQuota {
+	return &infrav1.MicrovmQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testMicrovmQuotaName,
+			Namespace: testNamespace,
+		},
+		Spec: infrav1.MicrovmQuotaSpec{
+			Hard: hard,
+		},
+	}
+}
+
 func createMicrovmReplicaSet(reps int32) *infrav1.MicrovmReplicaSet {
 	mvm := createMicrovm()
 	mvm.Spec.Host = microvm.Host{}
@@ -227,6 +649,7 @@ func createMicrovmReplicaSet(reps int32) *infrav1.MicrovmReplicaSet {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      testMicrovmReplicaSetName,
 			Namespace: testNamespace,
+			UID:       testMicrovmReplicaSetUID,
 		},
 		Spec: infrav1.MicrovmReplicaSetSpec{
 			Host: microvm.Host{
@@ -256,6 +679,7 @@ func createMicrovmDeployment(reps int32, hostCount int) *infrav1.MicrovmDeployme
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      testMicrovmDeploymentName,
 			Namespace: testNamespace,
+			UID:       testMicrovmDeploymentUID,
 		},
 		Spec: infrav1.MicrovmDeploymentSpec{
 			Hosts:    hosts,
@@ -267,17 +691,200 @@ func createMicrovmDeployment(reps int32, hostCount int) *infrav1.MicrovmDeployme
 	}
 }
 
+func createMicrovmAutoscaler(min, max int32, metric infrav1.MetricSource) *infrav1.MicrovmAutoscaler {
+	return &infrav1.MicrovmAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testMicrovmAutoscalerName,
+			Namespace: testNamespace,
+		},
+		Spec: infrav1.MicrovmAutoscalerSpec{
+			ScaleTargetRef: corev1.LocalObjectReference{Name: testMicrovmDeploymentName},
+			MinReplicas:    pointer.Int32(min),
+			MaxReplicas:    max,
+			Metric:         metric,
+		},
+	}
+}
+
+type fakeSource struct {
+	value float64
+	err   error
+}
+
+func (f fakeSource) Value(_ context.Context) (float64, error) {
+	return f.value, f.err
+}
+
+func withMetricValue(value float64, err error) autoscaler.SourceFunc {
+	return func(_, _ string) autoscaler.Source {
+		return fakeSource{value: value, err: err}
+	}
+}
+
+type fakeFetcher struct {
+	content   []byte
+	err       error
+	plainHTTP bool
+	refs      []oci.Reference
+	creds     []*oci.Credentials
+}
+
+func (f *fakeFetcher) Fetch(_ context.Context, ref oci.Reference, creds *oci.Credentials) ([]byte, error) {
+	f.refs = append(f.refs, ref)
+	f.creds = append(f.creds, creds)
+
+	return f.content, f.err
+}
+
+func (f *fakeFetcher) fetcherFunc() oci.FetcherFunc {
+	return func(plainHTTP bool) oci.Fetcher {
+		f.plainHTTP = plainHTTP
+
+		return f
+	}
+}
+
+type fakeImageResolver struct {
+	digest string
+	err    error
+	images []string
+}
+
+func (f *fakeImageResolver) Resolve(_ context.Context, image string) (string, error) {
+	f.images = append(f.images, image)
+
+	if f.err != nil {
+		return "", f.err
+	}
+
+	return image + "@" + f.digest, nil
+}
+
+type fakeProber struct {
+	identity identity.Identity
+	err      error
+}
+
+func (f *fakeProber) Probe(_ context.Context, _ string) (identity.Identity, error) {
+	return f.identity, f.err
+}
+
+type fakeLivenessProber struct {
+	err   error
+	calls int
+}
+
+func (f *fakeLivenessProber) Probe(_ context.Context, _ *infrav1.LivenessProbe) error {
+	f.calls++
+
+	return f.err
+}
+
+type fakeGuestAgent struct {
+	err   error
+	info  guestagent.Info
+	calls int
+}
+
+func (f *fakeGuestAgent) Info(_ context.Context, _ string) (*guestagent.Info, error) {
+	f.calls++
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &f.info, nil
+}
+
+func (f *fakeGuestAgent) Exec(_ context.Context, _ string, _ []string, _ time.Duration) (*guestagent.ExecResult, error) {
+	return &guestagent.ExecResult{}, nil
+}
+
+func createMicrovmTemplate(reference string) *infrav1.MicrovmTemplate {
+	return &infrav1.MicrovmTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testMicrovmTemplateName,
+			Namespace: testNamespace,
+		},
+		Source: &infrav1.TemplateSource{
+			OCI: &infrav1.OCITemplateSource{
+				Reference: reference,
+			},
+		},
+	}
+}
+
+type fakeShutdownClient struct {
+	endpoints []string
+	err       error
+}
+
+func (f *fakeShutdownClient) Shutdown(_ context.Context, endpoint string) error {
+	f.endpoints = append(f.endpoints, endpoint)
+
+	return f.err
+}
+
+// withExistingMicrovm returns a VM from flintlock which matches the spec of
+// createMicrovm.
 func withExistingMicrovm(fc *fakes.FakeClient, mvmState flintlocktypes.MicroVMStatus_MicroVMState) {
 	fc.GetMicroVMReturns(&flintlockv1.GetMicroVMResponse{
-		Microvm: &flintlocktypes.MicroVM{
-			Spec: &flintlocktypes.MicroVMSpec{
-				Uid: pointer.String(testMicrovmUID),
+		Microvm: existingMicrovm(mvmState),
+	}, nil)
+}
+
+// withExistingMicrovmSSHKeys returns a VM from flintlock which matches the spec
+// of createMicrovm and was created with the SSH keys.
+func withExistingMicrovmSSHKeys(
+	g *WithT,
+	fc *fakes.FakeClient,
+	mvmState flintlocktypes.MicroVMStatus_MicroVMState,
+	keys []microvm.SSHPublicKey,
+) {
+	vendorData := &userdata.UserData{}
+	for _, key := range keys {
+		vendorData.Users = append(vendorData.Users, userdata.User{Name: key.User, SSHAuthorizedKeys: key.AuthorizedKeys})
+	}
+
+	data, err := yaml.Marshal(vendorData)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	existing := existingMicrovm(mvmState)
+	existing.Spec.Metadata = map[string]string{
+		"vendor-data": base64.StdEncoding.EncodeToString(append([]byte("#cloud-config\n"), data...)),
+	}
+
+	fc.GetMicroVMReturns(&flintlockv1.GetMicroVMResponse{Microvm: existing}, nil)
+}
+
+func existingMicrovm(mvmState flintlocktypes.MicroVMStatus_MicroVMState) *flintlocktypes.MicroVM {
+	return &flintlocktypes.MicroVM{
+		Spec: &flintlocktypes.MicroVMSpec{
+			Uid:        pointer.String(testMicrovmUID),
+			Vcpu:       2,
+			MemoryInMb: 2048,
+			Kernel: &flintlocktypes.Kernel{
+				Image:    "docker.io/richardcase/ubuntu-bionic-kernel:0.0.11",
+				Filename: pointer.String("vmlinuz"),
 			},
-			Status: &flintlocktypes.MicroVMStatus{
-				State: mvmState,
+			Initrd: &flintlocktypes.Initrd{
+				Image:    "docker.io/richardcase/ubuntu-bionic-kernel:0.0.11",
+				Filename: pointer.String("initrd-generic"),
+			},
+			RootVolume: &flintlocktypes.Volume{
+				Id: "root",
+				Source: &flintlocktypes.VolumeSource{
+					ContainerSource: pointer.String("docker.io/richardcase/ubuntu-bionic-test:cloudimage_v0.0.1"),
+				},
+			},
+			Interfaces: []*flintlocktypes.NetworkInterface{
+				{DeviceId: "eth0", Type: flintlocktypes.NetworkInterface_MACVTAP},
 			},
 		},
-	}, nil)
+		Status: &flintlocktypes.MicroVMStatus{
+			State: mvmState,
+		},
+	}
 }
 
 func withMissingMicrovm(fc *fakes.FakeClient) {
@@ -323,6 +930,7 @@ func assertMicrovmReconciled(g *WithT, reconciled *infrav1.Microvm) {
 	expectedProviderID := fmt.Sprintf("microvm://127.0.0.1:9090/%s", testMicrovmUID)
 	g.Expect(*reconciled.Spec.ProviderID).To(Equal(expectedProviderID))
 	g.Expect(reconciled.Status.Ready).To(BeTrue(), "The Ready property must be true when the mvm has been reconciled")
+	g.Expect(reconciled.Status.Phase).To(Equal(infrav1.PhaseRunning))
 }
 
 func assertOneSetPerHost(g *WithT, reconciled *infrav1.MicrovmDeployment, c client.Client) {
@@ -347,6 +955,21 @@ func microvmsCreated(g *WithT, c client.Client) int32 {
 	return int32(len(mvmList.Items))
 }
 
+func microvmsDeleting(g *WithT, c client.Client) int {
+	mvmList, err := listMicrovm(c)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	deleting := 0
+
+	for _, mvm := range mvmList.Items {
+		if !mvm.DeletionTimestamp.IsZero() {
+			deleting++
+		}
+	}
+
+	return deleting
+}
+
 func microvmReplicaSetsCreated(g *WithT, c client.Client) int {
 	mvmList, err := listMicrovmReplicaSet(c)
 	g.Expect(err).NotTo(HaveOccurred())
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+const (
+	// controllerUIDField indexes objects by the UID of their controller.
+	controllerUIDField = ".metadata.controllerUID"
+	// hostEndpointField indexes Microvms, MicrovmHosts and MicrovmReplicaSets
+	// by the flintlock endpoint of their host.
+	hostEndpointField = ".spec.host.endpoint"
+)
+
+// indexByControllerUID registers the controller UID index for obj with the
+// manager's cache.
+func indexByControllerUID(mgr ctrl.Manager, obj client.Object) error {
+	return mgr.GetFieldIndexer().IndexField(context.Background(), obj, controllerUIDField, func(o client.Object) []string {
+		ref := metav1.GetControllerOf(o)
+		if ref == nil {
+			return nil
+		}
+
+		return []string{string(ref.UID)}
+	})
+}
+
+// indexByHostEndpoint registers the host endpoint index for Microvms and
+// MicrovmHosts with the manager's cache.
+func indexByHostEndpoint(mgr ctrl.Manager) error {
+	indexer := mgr.GetFieldIndexer()
+
+	if err := indexer.IndexField(context.Background(), &infrav1.Microvm{}, hostEndpointField, func(o client.Object) []string {
+		mvm, ok := o.(*infrav1.Microvm)
+		if !ok || mvm.Spec.Host.Endpoint == "" {
+			return nil
+		}
+
+		return []string{mvm.Spec.Host.Endpoint}
+	}); err != nil {
+		return err
+	}
+
+	return indexer.IndexField(context.Background(), &infrav1.MicrovmHost{}, hostEndpointField, func(o client.Object) []string {
+		host, ok := o.(*infrav1.MicrovmHost)
+		if !ok || host.Spec.Endpoint == "" {
+			return nil
+		}
+
+		return []string{host.Spec.Endpoint}
+	})
+}
+
+// indexReplicaSetsByHostEndpoint registers the host endpoint index for
+// MicrovmReplicaSets with the manager's cache.
+func indexReplicaSetsByHostEndpoint(mgr ctrl.Manager) error {
+	return mgr.GetFieldIndexer().IndexField(context.Background(), &infrav1.MicrovmReplicaSet{}, hostEndpointField, func(o client.Object) []string {
+		rs, ok := o.(*infrav1.MicrovmReplicaSet)
+		if !ok || rs.Spec.Host.Endpoint == "" {
+			return nil
+		}
+
+		return []string{rs.Spec.Host.Endpoint}
+	})
+}
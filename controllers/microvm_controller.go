@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -26,20 +27,55 @@ import (
 	flservice "github.com/weaveworks-liquidmetal/controller-pkg/services/microvm"
 	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
 	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/callmeta"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/cloudinit"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/dryrun"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/external"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/failure"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestaddr"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestagent"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/health"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/hostaddr"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/hostvm"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/imagepin"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/instanceidentity"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/ipam"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/macpool"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/oci"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/phonehome"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/probe"
+	flretry "github.com/weaveworks-liquidmetal/microvm-operator/internal/retry"
 	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/shutdown"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/sshservice"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
 )
 
 const (
 	requeuePeriod = 30 * time.Second
+
+	defaultPendingDeleteGrace = 2 * time.Minute
+	defaultStuckDeleteTimeout = 10 * time.Minute
 )
 
 // MicrovmReconciler reconciles a Microvm object
@@ -48,11 +84,84 @@ type MicrovmReconciler struct {
 	Scheme *runtime.Scheme
 
 	MvmClientFunc flclient.FactoryFunc
+	// PinnedClientFunc creates the client for a host whose identity is
+	// enforced, so that every connection to it fails unless the host presents
+	// the certificate it is trusted with. MvmClientFunc is used for every host
+	// when it is nil.
+	PinnedClientFunc func(identity.ClientConfig) flclient.FactoryFunc
+	// ShutdownClient asks guests to shut down before deletion. Microvms which
+	// configure a graceful shutdown are deleted immediately when it is nil.
+	ShutdownClient shutdown.Client
+	// HealthRecorder receives the outcome of each attempt to provision a
+	// Microvm, which feeds the error budget of its host. Outcomes are not
+	// recorded when it is nil.
+	HealthRecorder *health.Recorder
+	// Prober runs the liveness probes of guests. Liveness probes are ignored
+	// when it is nil.
+	Prober probe.Prober
+	// SSHServices creates a Service for the SSH port of the guests of Microvms
+	// which ask for one. The SSHService of Microvms is ignored when it is false.
+	SSHServices bool
+	// GuestAgent asks the agents in guests about them. The GuestAgent of
+	// Microvms is ignored when it is nil.
+	GuestAgent guestagent.Client
+	// PhoneHomeURL is the base URL of the phone-home server which guests call
+	// back to once cloud-init has finished. Guests are not asked to call back
+	// when it is empty.
+	PhoneHomeURL string
+	// PendingDeleteGrace is how long a Microvm which is being deleted while
+	// flintlock is still creating it is given for the create to settle, so that
+	// the delete does not race it and leave a half created VM on the host. It
+	// is measured from the deletion timestamp. Defaults to 2m when zero.
+	PendingDeleteGrace time.Duration
+	// StuckDeleteTimeout is how long a VM may be deleting on its host before
+	// the Microvm is marked stuck. Defaults to 10m when zero.
+	StuckDeleteTimeout time.Duration
+	// ForceDeleteStuck asks the host to delete a VM which is stuck deleting
+	// again on every reconcile, rather than only waiting for it to go.
+	ForceDeleteStuck bool
+	// InPlaceUpdates recreates the VM of a Microvm whose spec has changed when
+	// its UpdateStrategy says so. Changes are only reported when it is false.
+	InPlaceUpdates bool
+	// ExternalResources releases the resources outside flintlock which were
+	// created for a Microvm before its finalizer is removed. Tracked resources
+	// are left to the garbage collector when it is nil.
+	ExternalResources *external.Registry
+	// ImageResolver resolves the images given by tag of Microvms whose
+	// ImagePolicy is Resolve to digests. Those Microvms are not created when
+	// it is nil.
+	ImageResolver oci.Resolver
+	// DryRun reconciles every Microvm in dry-run mode, as the dry-run
+	// annotation does for one.
+	DryRun bool
+	// Recorder receives events about Microvms which cannot be reconciled as
+	// they are, such as those with an invalid host endpoint. Events are not
+	// emitted when it is nil.
+	Recorder record.EventRecorder
+
+	// Config holds the settings which can be changed while running, such as
+	// the requeue period. The defaults are used when it is nil.
+	Config *config.Store
+
+	// MaxConcurrentReconciles is how many Microvms are reconciled at once.
+	// Defaults to 1 when zero.
+	MaxConcurrentReconciles int
+
+	// indexed is true once the host endpoint index has been registered, which
+	// only happens when the reconciler is set up with a manager.
+	indexed bool
 }
 
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms/finalizers,verbs=update
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmsnapshots,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmmacpools,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmippools,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch;create;update
 
 func (r *MicrovmReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
@@ -63,22 +172,26 @@ func (r *MicrovmReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			return ctrl.Result{}, nil
 		}
 
-		log.Error(err, "error getting microvm", "id", req.NamespacedName)
+		log.Error(err, "error getting microvm")
 
 		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
 	}
 
-	if isNotSet(mvm.Spec.Host.Endpoint) {
-		log.Info("host endpoint not set for microvm, skipping", "id", req.NamespacedName)
+	// the calls made to flintlock say which microvm and reconcile made them
+	ctx, reconcileUID := callmeta.ForReconcile(ctx, "microvm", mvm)
 
-		return ctrl.Result{}, nil
-	}
+	// everything logged from here on, including the calls made to flintlock,
+	// carries the host and uid of the microvm
+	log = logging.ForMicrovm(log, mvm).WithValues(logging.ReconcileUIDKey, reconcileUID)
+	ctx = ctrl.LoggerInto(ctx, log)
 
 	mvmScope, err := scope.NewMicrovmScope(scope.MicrovmScopeParams{
 		MicroVM: mvm,
 		Client:  r.Client,
 		Context: ctx,
 		Logger:  log,
+
+		DefaultTLSSecretRef: r.Config.DefaultTLSSecretRef(),
 	})
 	if err != nil {
 		log.Error(err, "failed to create mvm scope")
@@ -92,22 +205,154 @@ func (r *MicrovmReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 	}()
 
+	// an endpoint which cannot be connected to is a mistake in the spec rather
+	// than a failure of the host, so it is reported rather than retried until
+	// the spec changes
+	if errs := hostaddr.ValidateEndpoint(mvm.Spec.Host.Endpoint, field.NewPath("spec", "host", "endpoint")); len(errs) > 0 {
+		message := errs.ToAggregate().Error()
+
+		log.Info("invalid host endpoint for microvm, skipping", "reason", message)
+		mvmScope.SetNotReady(infrav1.MicrovmInvalidSpecReason, "Error", "%s", message)
+
+		if r.Recorder != nil {
+			r.Recorder.Event(mvm, corev1.EventTypeWarning, infrav1.MicrovmInvalidSpecReason, message)
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	mvmScope.SetDeleteProtection()
+
+	if dryrun.Enabled(mvm, r.DryRun) {
+		return r.reconcileDryRun(ctx, mvmScope)
+	}
+
+	mvmScope.ClearDryRun()
+
 	if !mvm.ObjectMeta.DeletionTimestamp.IsZero() {
 		log.Info("Deleting microvm")
 
 		return r.reconcileDelete(ctx, mvmScope)
 	}
 
-	return r.reconcileNormal(ctx, mvmScope)
+	result, err := r.reconcileNormal(ctx, mvmScope)
+	if err == nil {
+		mvmScope.SetObservedGeneration()
+	}
+
+	return result, err
+}
+
+// reconcileDryRun works out the calls to flintlock a reconcile of the Microvm
+// would make, and records them on its status rather than making them. Only
+// the VM is read from its host.
+func (r *MicrovmReconciler) reconcileDryRun(
+	ctx context.Context,
+	mvmScope *scope.MicrovmScope,
+) (reconcile.Result, error) {
+	if untrusted, err := r.checkHostTrusted(ctx, mvmScope); err != nil || untrusted {
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, err
+	}
+
+	mvmSvc, err := r.getMicrovmService(ctx, mvmScope)
+	if err != nil {
+		mvmScope.Error(err, "failed to get microvm service")
+
+		return ctrl.Result{}, err
+	}
+	defer mvmSvc.Close()
+
+	var microvm *flintlocktypes.MicroVM
+
+	if mvmScope.GetProviderID() != "" {
+		microvm, err = mvmSvc.Get(ctx)
+		recordHostReachable(mvmScope, err)
+
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			mvmScope.Error(err, "failed checking if microvm exists")
+
+			return ctrl.Result{}, err
+		}
+	}
+
+	actions := planMicrovm(mvmScope, microvm)
+	if len(actions) > 0 {
+		mvmScope.Info("dry run: not calling flintlock", "actions", actions)
+	}
+
+	mvmScope.SetDryRun(actions)
+
+	return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+}
+
+// planMicrovm returns the calls to flintlock a reconcile of the Microvm would
+// make, given the VM on its host, or nil if there is none.
+func planMicrovm(mvmScope *scope.MicrovmScope, microvm *flintlocktypes.MicroVM) []infrav1.PlannedAction {
+	created := "VM on " + mvmScope.MicroVM.Spec.Host.Endpoint
+
+	switch {
+	case microvm == nil && !mvmScope.MicroVM.DeletionTimestamp.IsZero():
+		return nil
+	case microvm == nil && mvmScope.HostEndpointChanged():
+		return nil
+	case microvm == nil:
+		return []infrav1.PlannedAction{{Verb: "CreateMicroVM", Target: created, Reason: "the vm does not exist on its host"}}
+	case mvmScope.DeleteProtected():
+		return nil
+	case !mvmScope.MicroVM.DeletionTimestamp.IsZero():
+		if microvm.Status.State == flintlocktypes.MicroVMStatus_DELETING {
+			return nil
+		}
+
+		return []infrav1.PlannedAction{{Verb: "DeleteMicroVM", Target: mvmScope.GetProviderID(), Reason: "the microvm is being deleted"}}
+	case microvm.Status.State != flintlocktypes.MicroVMStatus_CREATED:
+		return nil
+	}
+
+	reason := ""
+
+	if mvmScope.RestartRequested() {
+		reason = "a restart was requested"
+	} else {
+		drifted := mvmScope.SpecDrift(microvm.Spec)
+		if users, _ := mvmScope.SSHKeysDrift(microvm.Spec); len(users) > 0 {
+			drifted = append(drifted, "sshPublicKeys")
+		}
+
+		if len(drifted) > 0 && mvmScope.RecreateOnSpecChange(drifted) {
+			reason = "to apply changes to " + strings.Join(drifted, ", ")
+		}
+	}
+
+	if reason == "" {
+		return nil
+	}
+
+	return []infrav1.PlannedAction{
+		{Verb: "DeleteMicroVM", Target: mvmScope.GetProviderID(), Reason: reason},
+		{Verb: "CreateMicroVM", Target: created, Reason: reason},
+	}
 }
 
 func (r *MicrovmReconciler) reconcileDelete(
 	ctx context.Context,
 	mvmScope *scope.MicrovmScope,
 ) (reconcile.Result, error) {
-	mvmScope.Info("Reconciling Microvm delete")
+	mvmScope.V(logging.DebugLevel).Info("Reconciling Microvm delete")
+
+	if untrusted, err := r.checkHostTrusted(ctx, mvmScope); err != nil || untrusted {
+		// the host may never be trusted again, so the Microvm can be let go of
+		// without deleting its VM
+		if untrusted && mvmScope.ForceDeleted() {
+			mvmScope.Info("host is untrusted, leaving the microvm on it")
+
+			return r.removeFinalizer(ctx, mvmScope)
+		}
+
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, err
+	}
 
-	mvmSvc, err := r.getMicrovmService(mvmScope)
+	mvmSvc, err := r.getMicrovmService(ctx, mvmScope)
 	if err != nil {
 		mvmScope.Error(err, "failed to get microvm service")
 
@@ -115,16 +360,31 @@ func (r *MicrovmReconciler) reconcileDelete(
 	}
 	defer mvmSvc.Close()
 
-	mvmScope.Info("getting microvm", "name", mvmScope.Name())
+	mvmScope.V(logging.DebugLevel).Info("getting microvm")
 	microvm, err := mvmSvc.Get(ctx)
+	recordHostReachable(mvmScope, err)
+
 	if err != nil && !strings.Contains(err.Error(), "not found") {
 		mvmScope.Error(err, "failed getting microvm")
 
 		return ctrl.Result{}, fmt.Errorf("failed getting microvm: %w", err)
 	}
 
+	if microvm != nil && !verifyIdentity(mvmScope, microvm) {
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+	}
+
+	// a protected VM is left running, and the Microvm waits for the annotation
+	// to be removed
+	if microvm != nil && mvmScope.DeleteProtected() {
+		mvmScope.Info("microvm is protected from deletion")
+		mvmScope.SetDeleteRefused("for the deletion of the microvm")
+
+		return ctrl.Result{}, nil
+	}
+
 	if microvm != nil {
-		mvmScope.Info("deleting microvm", "name", mvmScope.Name())
+		mvmScope.Info("deleting microvm")
 
 		// Mark the mvm as no longer ready before we delete.
 		mvmScope.SetNotReady(infrav1.MicrovmDeletingReason, "Info", "")
@@ -135,30 +395,146 @@ func (r *MicrovmReconciler) reconcileDelete(
 			}
 		}()
 
+		if microvm.Status.State == flintlocktypes.MicroVMStatus_PENDING {
+			if wait := r.pendingCreateWait(mvmScope); wait > 0 {
+				mvmScope.V(logging.DebugLevel).Info("waiting for pending create to settle before deleting")
+				mvmScope.SetNotReady(infrav1.MicrovmWaitingForCreateReason, "Info", "")
+
+				if poll := r.deletingPoll(mvmScope); wait > poll {
+					wait = poll
+				}
+
+				return ctrl.Result{RequeueAfter: wait}, nil
+			}
+		}
+
 		if microvm.Status.State != flintlocktypes.MicroVMStatus_DELETING {
-			if _, err := mvmSvc.Delete(ctx); err != nil {
-				mvmScope.SetNotReady(infrav1.MicrovmDeleteFailedReason, "Error", "")
+			if wait := r.shutdownGuest(ctx, mvmScope); wait > 0 {
+				return ctrl.Result{RequeueAfter: wait}, nil
+			}
+
+			_, err := mvmSvc.Delete(ctx)
+			recordHostReachable(mvmScope, err)
+
+			if err != nil {
+				if !flretry.Unreachable(err) {
+					mvmScope.SetNotReady(infrav1.MicrovmDeleteFailedReason, "Error", "")
+				}
 
 				return ctrl.Result{}, err
 			}
+
+			return ctrl.Result{RequeueAfter: r.deletingPoll(mvmScope)}, nil
 		}
 
-		return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+		return r.checkDeleting(ctx, mvmScope, mvmSvc)
+	}
+
+	// By this point Flintlock has no record of the MvM, so once everything else
+	// created for it has been released we are good to clear the finalizer
+	return r.removeFinalizer(ctx, mvmScope)
+}
+
+// removeFinalizer releases everything outside flintlock which was created for
+// the Microvm, then removes its finalizer.
+func (r *MicrovmReconciler) removeFinalizer(ctx context.Context, mvmScope *scope.MicrovmScope) (reconcile.Result, error) {
+	if held := r.releaseExternalResources(ctx, mvmScope); held {
+		return ctrl.Result{RequeueAfter: r.deletingPoll(mvmScope)}, nil
 	}
 
-	// By this point Flintlock has no record of the MvM, so we are good to clear
-	// the finalizer
 	controllerutil.RemoveFinalizer(mvmScope.MicroVM, infrav1.MvmFinalizer)
-	mvmScope.Info("microvm deleted", "name", mvmScope.Name())
+	mvmScope.Info("microvm deleted")
 
 	return ctrl.Result{}, nil
 }
 
+// releaseExternalResources releases the resources outside flintlock which were
+// created for the Microvm, and returns true if any of them are still held.
+func (r *MicrovmReconciler) releaseExternalResources(ctx context.Context, mvmScope *scope.MicrovmScope) bool {
+	if r.ExternalResources == nil || len(mvmScope.ExternalResources()) == 0 {
+		return false
+	}
+
+	held, err := r.ExternalResources.Release(ctx, mvmScope.MicroVM)
+	mvmScope.SetExternalResources(held)
+
+	if err != nil {
+		mvmScope.Error(err, "failed releasing external resources", "held", len(held))
+		mvmScope.SetNotReady(infrav1.MicrovmReleaseFailedReason, "Warning", "%s", err.Error())
+
+		return true
+	}
+
+	return false
+}
+
+// pendingCreateWait returns how much longer to wait for a pending create to
+// settle before deleting the Microvm anyway.
+func (r *MicrovmReconciler) pendingCreateWait(mvmScope *scope.MicrovmScope) time.Duration {
+	grace := r.PendingDeleteGrace
+	if grace == 0 {
+		grace = defaultPendingDeleteGrace
+	}
+
+	return time.Until(mvmScope.MicroVM.DeletionTimestamp.Add(grace))
+}
+
+// shutdownGuest asks the guest to shut down if the Microvm is configured for a
+// graceful shutdown, and returns how much longer to wait before deleting it.
+// Any failure to reach the guest falls back to deleting straight away.
+func (r *MicrovmReconciler) shutdownGuest(ctx context.Context, mvmScope *scope.MicrovmScope) time.Duration {
+	graceful := mvmScope.GracefulShutdown()
+	if graceful == nil || r.ShutdownClient == nil {
+		return 0
+	}
+
+	if mvmScope.ShutdownRequestedAt() == nil {
+		if err := guestaddr.CheckURL(graceful.AgentEndpoint, mvmScope.GuestAddresses()); err != nil {
+			mvmScope.Error(err, "refusing to request guest shutdown, deleting microvm")
+
+			return 0
+		}
+
+		mvmScope.Info("requesting guest shutdown")
+
+		if err := r.ShutdownClient.Shutdown(ctx, graceful.AgentEndpoint); err != nil {
+			mvmScope.Error(err, "failed requesting guest shutdown, deleting microvm")
+
+			return 0
+		}
+
+		mvmScope.SetShutdownRequested(time.Now())
+	}
+
+	wait := time.Until(mvmScope.ShutdownDeadline())
+	if wait > 0 {
+		mvmScope.SetNotReady(infrav1.MicrovmShuttingDownReason, "Info", "")
+	}
+
+	return wait
+}
+
 func (r *MicrovmReconciler) reconcileNormal(
 	ctx context.Context,
 	mvmScope *scope.MicrovmScope,
 ) (reconcile.Result, error) {
-	mvmSvc, err := r.getMicrovmService(mvmScope)
+	recordPhase(mvmScope, scope.PhaseAccepted)
+
+	// persist the finalizer before talking to the host at all, so that a delete
+	// which arrives from here on is guaranteed to clean up on flintlock
+	if controllerutil.AddFinalizer(mvmScope.MicroVM, infrav1.MvmFinalizer) {
+		if err := mvmScope.Patch(); err != nil {
+			mvmScope.Error(err, "unable to patch microvm")
+
+			return ctrl.Result{}, err
+		}
+	}
+
+	if untrusted, err := r.checkHostTrusted(ctx, mvmScope); err != nil || untrusted {
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, err
+	}
+
+	mvmSvc, err := r.getMicrovmService(ctx, mvmScope)
 	if err != nil {
 		mvmScope.Error(err, "failed to get microvm service")
 
@@ -173,30 +549,97 @@ func (r *MicrovmReconciler) reconcileNormal(
 		var err error
 
 		microvm, err = mvmSvc.Get(ctx)
+		recordHostReachable(mvmScope, err)
+
 		if err != nil && !strings.Contains(err.Error(), "not found") {
 			mvmScope.Error(err, "failed checking if microvm exists")
 
 			return ctrl.Result{}, err
 		}
-	}
 
-	controllerutil.AddFinalizer(mvmScope.MicroVM, infrav1.MvmFinalizer)
+		if microvm != nil && !verifyIdentity(mvmScope, microvm) {
+			return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+		}
+	}
 
-	if err := mvmScope.Patch(); err != nil {
-		mvmScope.Error(err, "unable to patch microvm")
+	if microvm == nil && mvmScope.HostEndpointChanged() {
+		// the VM was created at the endpoint in the provider ID. creating it
+		// again at the new endpoint would leave the original behind, so wait
+		// for it to be found there instead
+		mvmScope.Info("microvm not found at new host endpoint",
+			"previousHost", mvmScope.ProviderEndpoint(), logging.HostKey, mvmScope.MicroVM.Spec.Host.Endpoint)
+		mvmScope.SetNotReady(infrav1.MicrovmHostMigrationFailedReason, "Error",
+			"microvm %s was not found at %s, remove spec.providerID to create it there",
+			mvmScope.GetInstanceID(), mvmScope.MicroVM.Spec.Host.Endpoint)
+
+		return ctrl.Result{RequeueAfter: r.pendingPoll(mvmScope)}, nil
+	}
 
-		return ctrl.Result{}, err
+	if microvm != nil && mvmScope.HostEndpointChanged() {
+		mvmScope.Info("microvm found at new host endpoint, migrating provider id",
+			"previousHost", mvmScope.ProviderEndpoint(), logging.HostKey, mvmScope.MicroVM.Spec.Host.Endpoint)
 	}
 
 	if microvm == nil {
-		mvmScope.Info("creating microvm", "name", mvmScope.Name())
+		if providerID == "" {
+			if restored, err := r.restoreFromSnapshot(ctx, mvmScope); err != nil || !restored {
+				return ctrl.Result{RequeueAfter: r.pendingPoll(mvmScope)}, err
+			}
+		}
+
+		quarantined, err := hostQuarantined(ctx, r.Client, mvmScope.MicroVM.Spec.Host.Endpoint, r.hostListOptions(mvmScope)...)
+		if err != nil {
+			mvmScope.Error(err, "failed checking if host is quarantined")
+
+			return ctrl.Result{}, err
+		}
+
+		if quarantined {
+			mvmScope.V(logging.DebugLevel).Info("host is quarantined, waiting to create microvm")
+			mvmScope.SetNotReady(infrav1.MicrovmHostQuarantinedReason, "Warning", "")
+
+			return ctrl.Result{RequeueAfter: r.pendingPoll(mvmScope)}, nil
+		}
+
+		if allocated, err := r.allocateMACs(ctx, mvmScope); err != nil || !allocated {
+			return ctrl.Result{RequeueAfter: r.pendingPoll(mvmScope)}, err
+		}
+
+		if allocated, err := r.allocateIPs(ctx, mvmScope); err != nil || !allocated {
+			return ctrl.Result{RequeueAfter: r.pendingPoll(mvmScope)}, err
+		}
+
+		if pinned, err := r.pinImages(ctx, mvmScope); err != nil || !pinned {
+			return ctrl.Result{RequeueAfter: r.pendingPoll(mvmScope)}, err
+		}
+
+		if err := r.issuePhoneHomeToken(mvmScope); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		mvmScope.SetRestarted()
+
+		mvmScope.Info("creating microvm")
 
 		microvm, err = mvmSvc.Create(ctx)
+		recordHostReachable(mvmScope, err)
+
+		if errors.Is(err, hostvm.ErrNameConflict) {
+			mvmScope.Info("microvm name is taken on host", "reason", err.Error())
+			mvmScope.SetNotReady(infrav1.MicrovmHostVMNameConflictReason, "Error", "%s", err.Error())
+
+			return ctrl.Result{RequeueAfter: r.pendingPoll(mvmScope)}, nil
+		}
+
 		if err != nil {
+			r.recordOutcome(mvmScope, false)
+
 			return ctrl.Result{}, err
 		}
 
-		mvmScope.Info("microvm created", "name", mvmScope.Name())
+		recordPhase(mvmScope, scope.PhaseCreateSent)
+		mvmScope.SetGuestInfo(nil)
+		mvmScope.Info("microvm create sent", logging.UIDKey, *microvm.Spec.Uid)
 	}
 
 	mvmScope.SetProviderID(*microvm.Spec.Uid)
@@ -207,94 +650,994 @@ func (r *MicrovmReconciler) reconcileNormal(
 		return ctrl.Result{}, err
 	}
 
-	return r.parseMicroVMState(mvmScope, microvm.Status.State)
-}
+	result, err := r.parseMicroVMState(ctx, mvmScope, mvmSvc, microvm)
+	if err != nil || microvm.Status.State != flintlocktypes.MicroVMStatus_CREATED {
+		return result, err
+	}
 
-func (r *MicrovmReconciler) getMicrovmService(
-	mvmScope *scope.MicrovmScope,
-) (*flservice.Service, error) {
-	if r.MvmClientFunc == nil {
-		return nil, errClientFactoryFuncRequired
+	if restarting, err := r.checkRestart(ctx, mvmScope, mvmSvc); err != nil || restarting {
+		return ctrl.Result{RequeueAfter: r.deletingPoll(mvmScope)}, err
 	}
 
-	token, err := mvmScope.GetBasicAuthToken()
-	if err != nil {
-		return nil, fmt.Errorf("getting basic auth token: %w", err)
+	if recreating, err := r.checkSpecDrift(ctx, mvmScope, mvmSvc, microvm.Spec); err != nil || recreating {
+		return ctrl.Result{RequeueAfter: r.deletingPoll(mvmScope)}, err
 	}
 
-	tls, err := mvmScope.GetTLSConfig()
-	if err != nil {
-		return nil, fmt.Errorf("getting tls config: %w", err)
+	if err := r.reconcileSSHService(ctx, mvmScope); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	clientOpts := []flclient.Options{
-		flclient.WithProxy(mvmScope.MicroVM.Spec.MicrovmProxy),
-		flclient.WithBasicAuth(token),
-		flclient.WithTLS(tls),
+	// a guest still held back by its readiness gate is not probed, as its
+	// workload may not have been set up yet
+	if !r.probesLiveness(mvmScope) || !mvmScope.MicroVM.Status.Ready {
+		return result, nil
 	}
 
-	client, err := r.MvmClientFunc(mvmScope.MicroVM.Spec.Host.Endpoint, clientOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("creating microvm client: %w", err)
+	liveness, err := r.checkLiveness(ctx, mvmScope, mvmSvc)
+	if result.RequeueAfter > 0 && result.RequeueAfter < liveness.RequeueAfter {
+		liveness.RequeueAfter = result.RequeueAfter
 	}
 
-	return flservice.New(mvmScope, client, mvmScope.MicroVM.Spec.Host.Endpoint), nil
+	return liveness, err
 }
 
-func (r *MicrovmReconciler) parseMicroVMState(
-	mvmScope *scope.MicrovmScope,
-	state flintlocktypes.MicroVMStatus_MicroVMState,
-) (ctrl.Result, error) {
-	switch state {
-	// ALL DONE \o/
-	case flintlocktypes.MicroVMStatus_CREATED:
-		mvmScope.MicroVM.Status.VMState = &microvm.VMStateRunning
-		mvmScope.V(2).Info("microvm is in created state")
-		mvmScope.Info("microvm created", "name", mvmScope.Name(), "UID", mvmScope.GetInstanceID())
-		mvmScope.SetReady()
+// reconcileSSHService points the Service for the SSH port of the guest at its
+// address once the VM has been created, and deletes the Service once the
+// Microvm no longer asks for one.
+func (r *MicrovmReconciler) reconcileSSHService(ctx context.Context, mvmScope *scope.MicrovmScope) error {
+	if !r.SSHServices {
+		return nil
+	}
 
-		return reconcile.Result{}, nil
-	// MVM IS PENDING
-	case flintlocktypes.MicroVMStatus_PENDING:
-		mvmScope.MicroVM.Status.VMState = &microvm.VMStatePending
-		mvmScope.SetNotReady(infrav1.MicrovmPendingReason, "Info", "")
+	sshService := mvmScope.SSHService()
+	if sshService == nil {
+		return r.deleteSSHService(ctx, mvmScope)
+	}
 
-		return ctrl.Result{RequeueAfter: requeuePeriod}, nil
-	// MVM IS FAILING
-	case flintlocktypes.MicroVMStatus_FAILED:
-		// TODO: we need a failure reason from flintlock: Flintlock #299
-		mvmScope.MicroVM.Status.VMState = &microvm.VMStateFailed
-		mvmScope.SetNotReady(infrav1.MicrovmProvisionFailedReason,
-			"Error",
-			errMicrovmFailed.Error(),
-		)
+	address, ok := sshservice.Address(mvmScope.MicroVM.Spec.NetworkInterfaces, sshService.Interface)
+	if !ok {
+		mvmScope.V(logging.DebugLevel).Info("no known guest address for ssh service", "interface", sshService.Interface)
 
-		return ctrl.Result{}, errMicrovmFailed
-	// MVM RECEIVED A DELETE CALL IN A PREVIOUS RESYNC
-	case flintlocktypes.MicroVMStatus_DELETING:
-		mvmScope.V(2).Info("microvm is deleting")
+		return nil
+	}
 
-		return ctrl.Result{RequeueAfter: requeuePeriod}, nil
-	// NO IDEA WHAT IS GOING ON WITH THIS MVM
-	default:
-		mvmScope.MicroVM.Status.VMState = &microvm.VMStateUnknown
-		mvmScope.SetNotReady(
-			infrav1.MicrovmUnknownStateReason,
-			"Error",
-			errMicrovmUnknownState.Error(),
-		)
+	name := sshservice.Name(mvmScope.MicroVM)
+	mvmScope.TrackExternalResource(infrav1.ExternalResourceRef{Kind: external.KindService, Name: name})
+
+	if err := sshservice.Apply(ctx, r.Client, mvmScope.MicroVM, name, address, sshservice.Port(sshService)); err != nil {
+		mvmScope.Error(err, "failed applying ssh service", "service", name)
+
+		return err
+	}
 
-		return ctrl.Result{RequeueAfter: requeuePeriod}, errMicrovmUnknownState
+	if mvmScope.SSHServiceName() != name {
+		mvmScope.Info("created ssh service", "service", name, "address", address.String())
 	}
+
+	mvmScope.SetSSHServiceName(name)
+
+	return nil
 }
 
-func isNotSet(value string) bool {
-	return value == ""
+// deleteSSHService deletes the Service for the SSH port of the guest which the
+// Microvm no longer asks for.
+func (r *MicrovmReconciler) deleteSSHService(ctx context.Context, mvmScope *scope.MicrovmScope) error {
+	name := mvmScope.SSHServiceName()
+	if name == "" {
+		return nil
+	}
+
+	ref := infrav1.ExternalResourceRef{Kind: external.KindService, Name: name}
+
+	services := &external.Services{Client: r.Client}
+	if err := services.Release(ctx, mvmScope.MicroVM, ref); err != nil {
+		mvmScope.Error(err, "failed deleting ssh service", "service", name)
+
+		return err
+	}
+
+	mvmScope.UntrackExternalResource(ref)
+	mvmScope.SetSSHServiceName("")
+	mvmScope.Info("deleted ssh service", "service", name)
+
+	return nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *MicrovmReconciler) SetupWithManager(mgr ctrl.Manager) error {
+// restoreFromSnapshot applies the MicrovmSnapshot the Microvm is cloned from
+// to its spec before the VM is first created, and returns false if the
+// snapshot cannot be used yet.
+func (r *MicrovmReconciler) restoreFromSnapshot(ctx context.Context, mvmScope *scope.MicrovmScope) (bool, error) {
+	name := mvmScope.RestoreFrom()
+	if name == "" {
+		return true, nil
+	}
+
+	snapshot := &infrav1.MicrovmSnapshot{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: mvmScope.Namespace(), Name: name}, snapshot); err != nil {
+		if !apierrors.IsNotFound(err) {
+			mvmScope.Error(err, "failed getting microvmsnapshot", logging.SnapshotKey, name)
+
+			return false, err
+		}
+
+		// the snapshot may yet be created, but a wrong name would otherwise
+		// look the same as one which is still being captured
+		mvmScope.V(logging.DebugLevel).Info("snapshot to restore from does not exist", logging.SnapshotKey, name)
+		mvmScope.SetNotReady(infrav1.MicrovmSnapshotNotFoundReason, "Warning",
+			"microvmsnapshot %s/%s not found", mvmScope.Namespace(), name)
+
+		return false, nil
+	}
+
+	if !snapshot.Status.Ready || snapshot.Status.VMSpec == nil {
+		mvmScope.V(logging.DebugLevel).Info("waiting for snapshot to restore from", logging.SnapshotKey, name)
+		mvmScope.SetNotReady(infrav1.MicrovmSnapshotNotReadyReason, "Info", "")
+
+		return false, nil
+	}
+
+	mvmScope.RestoreSnapshot(snapshot.Status.VMSpec)
+
+	return true, nil
+}
+
+// allocateMACs gives each network interface of the Microvm without a MAC an
+// address from its MicrovmMACPool, and returns false if they cannot all be
+// given one yet. The pool is updated with optimistic concurrency, so that no
+// address is ever given to two interfaces, and is tracked as an external
+// resource of the Microvm so that the addresses are returned when it is
+// deleted.
+func (r *MicrovmReconciler) allocateMACs(ctx context.Context, mvmScope *scope.MicrovmScope) (bool, error) {
+	name := mvmScope.MACPool()
+	devices := mvmScope.InterfacesWithoutMAC()
+
+	if name == "" || len(devices) == 0 {
+		return true, nil
+	}
+
+	key := types.NamespacedName{Namespace: mvmScope.Namespace(), Name: name}
+	addresses := map[string]string{}
+
+	var unavailable error
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pool := &infrav1.MicrovmMACPool{}
+		if err := r.Get(ctx, key, pool); err != nil {
+			return err
+		}
+
+		allocated := len(pool.Spec.Allocations)
+
+		for _, device := range devices {
+			address, err := macpool.Allocate(pool, mvmScope.MicroVM, device)
+			if err != nil {
+				unavailable = err
+
+				return nil
+			}
+
+			addresses[device] = address
+		}
+
+		if len(pool.Spec.Allocations) == allocated {
+			return nil
+		}
+
+		return r.Update(ctx, pool)
+	})
+
+	switch {
+	case apierrors.IsNotFound(err):
+		unavailable = fmt.Errorf("microvmmacpool %s not found", name)
+	case err != nil:
+		mvmScope.Error(err, "failed allocating mac addresses", logging.MACPoolKey, name)
+
+		return false, err
+	}
+
+	if unavailable != nil {
+		mvmScope.V(logging.DebugLevel).Info("waiting for mac addresses", logging.MACPoolKey, name, "reason", unavailable.Error())
+		mvmScope.SetNotReady(infrav1.MicrovmMACPoolUnavailableReason, "Warning", "%s", unavailable.Error())
+
+		return false, nil
+	}
+
+	mvmScope.TrackExternalResource(infrav1.ExternalResourceRef{Kind: external.KindMACPool, Name: name})
+
+	for device, address := range addresses {
+		mvmScope.SetGuestMAC(device, address)
+	}
+
+	mvmScope.Info("allocated mac addresses", logging.MACPoolKey, name, "interfaces", devices)
+
+	return true, nil
+}
+
+// pinImages makes sure the VM is created from the images its ImagePolicy
+// allows, resolving those given by tag to digests if it asks for that, and
+// returns false if the VM cannot be created from its images.
+func (r *MicrovmReconciler) pinImages(ctx context.Context, mvmScope *scope.MicrovmScope) (bool, error) {
+	spec := &mvmScope.MicroVM.Spec.VMSpec
+
+	switch mvmScope.ImagePolicy() {
+	case infrav1.ImagePolicyDigestOnly:
+		unpinned := imagepin.Unpinned(spec)
+		if len(unpinned) == 0 {
+			return true, nil
+		}
+
+		mvmScope.Info("waiting for images to be given by digest", "images", unpinned)
+		mvmScope.SetNotReady(infrav1.MicrovmImageNotPinnedReason, "Error",
+			"%s must be given by digest", strings.Join(unpinned, ", "))
+
+		return false, nil
+	case infrav1.ImagePolicyResolve:
+		if r.ImageResolver == nil {
+			return false, errImageResolverRequired
+		}
+
+		resolved, err := imagepin.Resolve(ctx, r.ImageResolver, spec, mvmScope.ResolvedImages())
+		mvmScope.SetResolvedImages(resolved)
+
+		if err != nil {
+			mvmScope.Error(err, "failed resolving images")
+			mvmScope.SetNotReady(infrav1.MicrovmImageResolveFailedReason, "Warning", "%s", err.Error())
+
+			return false, err
+		}
+
+		return true, nil
+	default:
+		return true, nil
+	}
+}
+
+// allocateIPs gives each network interface of the Microvm which names a
+// MicrovmIPPool an address from it, and returns false if they cannot all be
+// given one yet. An interface whose address was not allocated from its pool
+// keeps it. Addresses allocated in an earlier reconcile are looked up again,
+// so that the VM is created with the gateway and nameservers of their pool.
+// Each pool is tracked as an external resource of the Microvm, so that its
+// addresses are returned when the Microvm is deleted.
+func (r *MicrovmReconciler) allocateIPs(ctx context.Context, mvmScope *scope.MicrovmScope) (bool, error) {
+	for _, ref := range mvmScope.MicroVM.Spec.IPPools {
+		address, ok := mvmScope.InterfaceAddress(ref.Interface)
+		if !ok {
+			mvmScope.SetNotReady(infrav1.MicrovmIPPoolUnavailableReason, "Error",
+				"network interface %s of microvmippool %s not found", ref.Interface, ref.PoolRef.Name)
+
+			return false, nil
+		}
+
+		key := types.NamespacedName{Namespace: mvmScope.Namespace(), Name: ref.PoolRef.Name}
+
+		var (
+			lease       ipam.Lease
+			explicit    bool
+			unavailable error
+		)
+
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			pool := &infrav1.MicrovmIPPool{}
+			if err := r.Get(ctx, key, pool); err != nil {
+				return err
+			}
+
+			if _, allocated := ipam.Lookup(pool, mvmScope.MicroVM.UID, ref.Interface); address != "" && !allocated {
+				explicit = true
+
+				return nil
+			}
+
+			allocated := len(pool.Spec.Allocations)
+
+			lease, unavailable = ipam.Allocate(pool, mvmScope.MicroVM, ref.Interface)
+			if unavailable != nil || len(pool.Spec.Allocations) == allocated {
+				return nil
+			}
+
+			return r.Update(ctx, pool)
+		})
+
+		switch {
+		case apierrors.IsNotFound(err):
+			unavailable = fmt.Errorf("microvmippool %s not found", ref.PoolRef.Name)
+		case err != nil:
+			mvmScope.Error(err, "failed allocating ip address", logging.IPPoolKey, ref.PoolRef.Name)
+
+			return false, err
+		}
+
+		if unavailable != nil {
+			mvmScope.V(logging.DebugLevel).Info("waiting for ip address",
+				logging.IPPoolKey, ref.PoolRef.Name, "interface", ref.Interface, "reason", unavailable.Error())
+			mvmScope.SetNotReady(infrav1.MicrovmIPPoolUnavailableReason, "Warning", "%s", unavailable.Error())
+
+			return false, nil
+		}
+
+		if explicit {
+			continue
+		}
+
+		if address != lease.Address {
+			mvmScope.Info("allocated ip address", logging.IPPoolKey, ref.PoolRef.Name,
+				"interface", ref.Interface, "address", lease.Address)
+		}
+
+		mvmScope.TrackExternalResource(infrav1.ExternalResourceRef{Kind: external.KindIPPool, Name: ref.PoolRef.Name})
+		mvmScope.SetIPLease(ref.Interface, lease)
+	}
+
+	return true, nil
+}
+
+// checkSpecDrift compares the spec and SSH keys of a created Microvm with the VM
+// on its host. Flintlock has no API to update a VM or its metadata, so if they
+// differ and in-place updates and the update strategy say so, the guest is
+// shut down and the VM is deleted from the host to be created again with the
+// new spec, and true is returned. Otherwise the difference is only reported.
+func (r *MicrovmReconciler) checkSpecDrift(
+	ctx context.Context,
+	mvmScope *scope.MicrovmScope,
+	mvmSvc *flservice.Service,
+	actual *flintlocktypes.MicroVMSpec,
+) (bool, error) {
+	drifted := mvmScope.SpecDrift(actual)
+
+	// keys are only compared when the vendor data of the VM can be read
+	users, known := mvmScope.SSHKeysDrift(actual)
+	if known && len(users) == 0 {
+		mvmScope.SetSSHKeysSynced()
+	}
+
+	if len(users) > 0 {
+		drifted = append(drifted, "sshPublicKeys")
+	}
+
+	if len(drifted) == 0 {
+		mvmScope.SetSpecSynced()
+
+		return false, nil
+	}
+
+	mvmScope.SetSpecDrifted(drifted)
+
+	recreate := r.InPlaceUpdates && mvmScope.RecreateOnSpecChange(drifted)
+	if recreate && mvmScope.DeleteProtected() {
+		mvmScope.SetDeleteRefused("to apply spec changes")
+
+		recreate = false
+	}
+
+	if !recreate {
+		if len(users) > 0 {
+			mvmScope.SetSSHKeysOutdated(users)
+		}
+
+		return false, nil
+	}
+
+	// the guest is given the chance to drain its workload first
+	if wait := r.shutdownGuest(ctx, mvmScope); wait > 0 {
+		return true, nil
+	}
+
+	mvmScope.Info("recreating microvm to apply spec changes", "fields", drifted)
+
+	if _, err := mvmSvc.Delete(ctx); err != nil {
+		mvmScope.Error(err, "failed deleting microvm to recreate it")
+
+		return false, err
+	}
+
+	// the host gives the new VM a new UID, so consumers of the provider ID are
+	// told which VM it replaces
+	mvmScope.SetPreviousProviderID(mvmScope.GetProviderID())
+	mvmScope.ClearShutdownRequested()
+
+	if len(users) > 0 {
+		mvmScope.SetSSHKeysRotating(users)
+	}
+
+	if mvmScope.MicroVM.Spec.UpdateStrategy == infrav1.UpdateStrategyResize {
+		mvmScope.SetNotReady(infrav1.MicrovmResizingReason, "Info", "")
+	} else {
+		mvmScope.SetNotReady(infrav1.MicrovmRecreatingReason, "Info", "")
+	}
+
+	return true, nil
+}
+
+// checkRestart recreates the VM of a created Microvm when a restart has been
+// asked for with the restartedAt annotation, and returns true while it does.
+// The guest is shut down first, as it is for a spec change.
+func (r *MicrovmReconciler) checkRestart(
+	ctx context.Context,
+	mvmScope *scope.MicrovmScope,
+	mvmSvc *flservice.Service,
+) (bool, error) {
+	if !mvmScope.RestartRequested() {
+		return false, nil
+	}
+
+	if mvmScope.DeleteProtected() {
+		mvmScope.SetDeleteRefused("to restart it")
+
+		return false, nil
+	}
+
+	if wait := r.shutdownGuest(ctx, mvmScope); wait > 0 {
+		return true, nil
+	}
+
+	mvmScope.Info("restarting microvm as requested",
+		"restartedAt", mvmScope.MicroVM.Annotations[infrav1.RestartedAtAnnotation])
+
+	if _, err := mvmSvc.Delete(ctx); err != nil {
+		mvmScope.Error(err, "failed deleting microvm to restart it")
+
+		return false, err
+	}
+
+	mvmScope.SetPreviousProviderID(mvmScope.GetProviderID())
+	mvmScope.ClearShutdownRequested()
+	mvmScope.SetNotReady(infrav1.MicrovmRestartingReason, "Info", "")
+
+	return true, nil
+}
+
+// readinessGatePassed returns true once the guest has confirmed what the
+// readiness gate of the Microvm waits for, and otherwise marks it not ready.
+func (r *MicrovmReconciler) readinessGatePassed(mvmScope *scope.MicrovmScope) bool {
+	if mvmScope.ReadinessGate() != infrav1.ReadinessGateCloudInit {
+		return true
+	}
+
+	switch state := mvmScope.CloudInitState(); state {
+	case infrav1.CloudInitDone:
+		return true
+	case infrav1.CloudInitError, infrav1.CloudInitDisabled:
+		mvmScope.SetNotReady(infrav1.MicrovmCloudInitFailedReason, "Error", "cloud-init reported %s", state)
+
+		return false
+	}
+
+	if mvmScope.GuestAgent() == nil && !mvmScope.WaitsForPhoneHome() {
+		mvmScope.SetNotReady(infrav1.MicrovmWaitingForCloudInitReason, "Warning",
+			"no guest agent or phone-home server is set to confirm cloud-init has finished")
+
+		return false
+	}
+
+	mvmScope.SetNotReady(infrav1.MicrovmWaitingForCloudInitReason, "Info", "")
+
+	return false
+}
+
+// issuePhoneHomeToken gives the VM about to be created a one-time token to call
+// back to the phone-home server with, replacing that of any earlier VM.
+func (r *MicrovmReconciler) issuePhoneHomeToken(mvmScope *scope.MicrovmScope) error {
+	if r.PhoneHomeURL == "" {
+		mvmScope.SetPhoneHomeToken("", "")
+
+		return nil
+	}
+
+	token, hash, err := phonehome.NewToken()
+	if err != nil {
+		mvmScope.Error(err, "failed issuing phone home token")
+
+		return err
+	}
+
+	mvmScope.SetPhoneHomeToken(hash, phonehome.URL(r.PhoneHomeURL, mvmScope.MicroVM, token))
+
+	return nil
+}
+
+// consumePhoneHome records the callback the phone-home server annotated the
+// Microvm with, if there is one.
+func (r *MicrovmReconciler) consumePhoneHome(mvmScope *scope.MicrovmScope) {
+	callback, err := phonehome.ParseCallback(mvmScope.MicroVM)
+	if err != nil {
+		mvmScope.Info("discarding phone home callback", "reason", err.Error())
+		delete(mvmScope.MicroVM.Annotations, phonehome.CallbackAnnotation)
+
+		return
+	}
+
+	if callback == nil {
+		return
+	}
+
+	if !mvmScope.RecordPhoneHome(callback) {
+		mvmScope.V(logging.DebugLevel).Info("discarding phone home callback of an earlier vm")
+
+		return
+	}
+
+	mvmScope.Info("guest phoned home", "instanceID", callback.InstanceID)
+}
+
+// collectsGuestInfo returns true if the guest of the Microvm has an agent which
+// the reconciler can ask about it.
+func (r *MicrovmReconciler) collectsGuestInfo(mvmScope *scope.MicrovmScope) bool {
+	return r.GuestAgent != nil && mvmScope.GuestAgent() != nil
+}
+
+// collectGuestInfo asks the agent in the guest of a created Microvm about it
+// when it is due, and returns how long until it is due again. An agent which
+// does not answer is only reported, as the guest may still be booting.
+func (r *MicrovmReconciler) collectGuestInfo(ctx context.Context, mvmScope *scope.MicrovmScope) time.Duration {
+	if wait := mvmScope.NextGuestInfo(time.Now()); wait > 0 {
+		return wait
+	}
+
+	info, err := guestagent.Collect(ctx, r.GuestAgent, mvmScope.GuestAgent(), time.Now())
+	if err != nil {
+		mvmScope.V(logging.DebugLevel).Info("guest agent did not answer", "reason", err.Error())
+		mvmScope.SetGuestAgentUnreachable(err.Error())
+
+		return mvmScope.GuestInfoPeriod()
+	}
+
+	mvmScope.SetGuestInfo(info)
+
+	return mvmScope.GuestInfoPeriod()
+}
+
+// probesLiveness returns true if the guest of the Microvm has a liveness probe
+// which is run.
+func (r *MicrovmReconciler) probesLiveness(mvmScope *scope.MicrovmScope) bool {
+	return r.Prober != nil && mvmScope.LivenessProbe() != nil
+}
+
+// checkLiveness probes the guest of a created Microvm when it is due. Once it
+// has failed too many probes in a row it is marked unhealthy and, if its
+// restart policy says so, deleted from the host so that it is created again.
+func (r *MicrovmReconciler) checkLiveness(
+	ctx context.Context,
+	mvmScope *scope.MicrovmScope,
+	mvmSvc *flservice.Service,
+) (ctrl.Result, error) {
+	if wait := mvmScope.NextProbe(time.Now()); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	// the probe comes from the spec, so it is only run against the guest, and
+	// the guest is not restarted as that would not change where it points
+	if err := probe.CheckTarget(mvmScope.LivenessProbe(), mvmScope.GuestAddresses()); err != nil {
+		mvmScope.Info("refusing to probe outside the guest", "reason", err.Error())
+		mvmScope.SetGuestUnhealthy(err.Error())
+
+		return ctrl.Result{RequeueAfter: mvmScope.ProbePeriod()}, nil
+	}
+
+	probeErr := r.Prober.Probe(ctx, mvmScope.LivenessProbe())
+	if probeErr == nil {
+		mvmScope.RecordProbe(time.Now(), true)
+		mvmScope.SetGuestHealthy()
+		recordPhase(mvmScope, scope.PhaseGuestReady)
+
+		return ctrl.Result{RequeueAfter: mvmScope.ProbePeriod()}, nil
+	}
+
+	mvmScope.V(logging.DebugLevel).Info("liveness probe failed", "reason", probeErr.Error())
+
+	if !mvmScope.RecordProbe(time.Now(), false) {
+		return ctrl.Result{RequeueAfter: mvmScope.ProbePeriod()}, nil
+	}
+
+	mvmScope.SetGuestUnhealthy(probeErr.Error())
+
+	if !mvmScope.RestartOnFailure() {
+		return ctrl.Result{RequeueAfter: mvmScope.ProbePeriod()}, nil
+	}
+
+	if mvmScope.DeleteProtected() {
+		mvmScope.SetDeleteRefused("to restart it after failed liveness probes")
+
+		return ctrl.Result{RequeueAfter: mvmScope.ProbePeriod()}, nil
+	}
+
+	mvmScope.Info("restarting microvm after failed liveness probes")
+
+	if _, err := mvmSvc.Delete(ctx); err != nil {
+		mvmScope.Error(err, "failed deleting microvm to restart it")
+
+		return ctrl.Result{}, err
+	}
+
+	mvmScope.RecordRestart()
+	mvmScope.SetNotReady(infrav1.MicrovmRestartingReason, "Warning", "")
+
+	return ctrl.Result{RequeueAfter: r.deletingPoll(mvmScope)}, nil
+}
+
+// checkHostTrusted returns true if the host presented an unexpected identity,
+// in which case no credentials or requests should be sent to it.
+func (r *MicrovmReconciler) checkHostTrusted(ctx context.Context, mvmScope *scope.MicrovmScope) (bool, error) {
+	untrusted, err := hostUntrusted(ctx, r.Client, mvmScope.MicroVM.Spec.Host.Endpoint, r.hostListOptions(mvmScope)...)
+	if err != nil {
+		mvmScope.Error(err, "failed checking if host is trusted")
+
+		return false, err
+	}
+
+	if untrusted {
+		mvmScope.Info("host identity changed, refusing to connect")
+		mvmScope.SetNotReady(infrav1.MicrovmHostUntrustedReason, "Error", "")
+	}
+
+	return untrusted, nil
+}
+
+func (r *MicrovmReconciler) getMicrovmService(
+	ctx context.Context,
+	mvmScope *scope.MicrovmScope,
+) (*flservice.Service, error) {
+	if r.MvmClientFunc == nil {
+		return nil, errClientFactoryFuncRequired
+	}
+
+	token, err := mvmScope.GetBasicAuthToken()
+	if err != nil {
+		return nil, fmt.Errorf("getting basic auth token: %w", err)
+	}
+
+	tls, err := mvmScope.GetTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("getting tls config: %w", err)
+	}
+
+	clientOpts := []flclient.Options{
+		flclient.WithProxy(mvmScope.MicroVM.Spec.MicrovmProxy),
+		flclient.WithBasicAuth(token),
+		flclient.WithTLS(tls),
+	}
+
+	clientFunc := r.MvmClientFunc
+
+	// the identity of the host is checked on every handshake, not only when
+	// the host is probed, so that a hijacked endpoint is never sent the token
+	if r.PinnedClientFunc != nil {
+		fingerprint, err := pinnedFingerprint(ctx, r.Client, mvmScope.MicroVM.Spec.Host.Endpoint,
+			r.hostListOptions(mvmScope)...)
+		if err != nil {
+			return nil, fmt.Errorf("getting host fingerprint: %w", err)
+		}
+
+		if fingerprint != "" {
+			clientFunc = r.PinnedClientFunc(identity.ClientConfig{
+				Fingerprint:    fingerprint,
+				TLS:            tls,
+				BasicAuthToken: token,
+				Proxy:          mvmScope.MicroVM.Spec.MicrovmProxy,
+			})
+		}
+	}
+
+	client, err := clientFunc(mvmScope.MicroVM.Spec.Host.Endpoint, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating microvm client: %w", err)
+	}
+
+	// the endpoint may resolve to any of several addresses, so the one which
+	// answered is recorded
+	client = hostaddr.Client(client, mvmScope.SetHostAddress)
+
+	// the cloud-init metadata is generated by the service, so the settings of
+	// the spec are merged into it on the way to the host
+	client = cloudinit.Client(client, cloudinit.ForSpec(&mvmScope.MicroVM.Spec))
+
+	// guest workloads read where they were placed from the metadata service
+	client = instanceidentity.Client(client, instanceidentity.ForMicrovm(mvmScope.MicroVM))
+
+	// the gateway and nameservers of addresses from a pool are only known once
+	// they have been allocated
+	if len(mvmScope.MicroVM.Spec.IPPools) > 0 {
+		client = ipam.Client(client, mvmScope.IPLeases)
+	}
+
+	// the one-time token the guest calls back with is only issued as the VM
+	// is created
+	if r.PhoneHomeURL != "" {
+		client = cloudinit.PhoneHomeClient(client, mvmScope.PhoneHomeURL)
+	}
+
+	// clusters sharing a host may name their VMs apart, and must not create
+	// one over another's
+	client = hostvm.Client(client, hostvm.ForMicrovm(mvmScope.MicroVM), mvmScope.MicroVM.UID)
+
+	return flservice.New(mvmScope, client, mvmScope.MicroVM.Spec.Host.Endpoint), nil
+}
+
+func (r *MicrovmReconciler) parseMicroVMState(
+	ctx context.Context,
+	mvmScope *scope.MicrovmScope,
+	mvmSvc *flservice.Service,
+	vm *flintlocktypes.MicroVM,
+) (ctrl.Result, error) {
+	if vm.Status.State != flintlocktypes.MicroVMStatus_DELETING {
+		mvmScope.ClearDeletionStarted()
+	}
+
+	switch vm.Status.State {
+	// ALL DONE \o/
+	case flintlocktypes.MicroVMStatus_CREATED:
+		if !hasVMState(mvmScope, microvm.VMStateRunning) {
+			r.recordOutcome(mvmScope, true)
+			mvmScope.Info("microvm created")
+		}
+
+		mvmScope.MicroVM.Status.VMState = &microvm.VMStateRunning
+		mvmScope.ClearFailure()
+		mvmScope.V(logging.DebugLevel).Info("microvm is in created state")
+		recordPhase(mvmScope, scope.PhaseCreated)
+
+		r.consumePhoneHome(mvmScope)
+
+		result := reconcile.Result{}
+
+		if r.collectsGuestInfo(mvmScope) {
+			result.RequeueAfter = r.collectGuestInfo(ctx, mvmScope)
+		}
+
+		if !r.readinessGatePassed(mvmScope) {
+			result.RequeueAfter = soonest(result.RequeueAfter, r.pendingPoll(mvmScope))
+
+			return result, nil
+		}
+
+		mvmScope.SetReady()
+
+		if !r.probesLiveness(mvmScope) {
+			recordPhase(mvmScope, scope.PhaseGuestReady)
+		}
+
+		result.RequeueAfter = soonest(result.RequeueAfter, r.runningResync(mvmScope))
+
+		return result, nil
+	// MVM IS PENDING
+	case flintlocktypes.MicroVMStatus_PENDING:
+		mvmScope.MicroVM.Status.VMState = &microvm.VMStatePending
+		recordPhase(mvmScope, scope.PhasePending)
+		mvmScope.SetNotReady(infrav1.MicrovmPendingReason, "Info", "")
+
+		return ctrl.Result{RequeueAfter: r.pendingPoll(mvmScope)}, nil
+	// MVM IS FAILING
+	case flintlocktypes.MicroVMStatus_FAILED:
+		reason, message := failure.Classify(vm)
+
+		if !hasVMState(mvmScope, microvm.VMStateFailed) {
+			r.recordOutcome(mvmScope, false)
+			health.ObserveFailure(mvmScope.MicroVM.Spec.Host.Endpoint, string(reason))
+		}
+
+		mvmScope.MicroVM.Status.VMState = &microvm.VMStateFailed
+		mvmScope.SetFailure(string(reason), message)
+		mvmScope.SetNotReady(infrav1.MicrovmProvisionFailedReason,
+			"Error",
+			"%s: %s", reason, message,
+		)
+
+		return ctrl.Result{}, fmt.Errorf("%w: %s", errMicrovmFailed, message)
+	// MVM RECEIVED A DELETE CALL IN A PREVIOUS RESYNC
+	case flintlocktypes.MicroVMStatus_DELETING:
+		return r.checkDeleting(ctx, mvmScope, mvmSvc)
+	// NO IDEA WHAT IS GOING ON WITH THIS MVM
+	default:
+		mvmScope.MicroVM.Status.VMState = &microvm.VMStateUnknown
+		mvmScope.SetNotReady(
+			infrav1.MicrovmUnknownStateReason,
+			"Error",
+			errMicrovmUnknownState.Error(),
+		)
+
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, errMicrovmUnknownState
+	}
+}
+
+// checkDeleting records that the VM is deleting on its host, and marks the
+// Microvm stuck once the delete has taken longer than the StuckDeleteTimeout,
+// asking the host to delete it again when ForceDeleteStuck is set.
+func (r *MicrovmReconciler) checkDeleting(
+	ctx context.Context,
+	mvmScope *scope.MicrovmScope,
+	mvmSvc *flservice.Service,
+) (ctrl.Result, error) {
+	mvmScope.SetDeletionStarted(time.Now())
+
+	deleting := time.Since(mvmScope.DeletionStartedAt().Time)
+	if deleting < r.stuckDeleteTimeout() {
+		mvmScope.V(logging.DebugLevel).Info("microvm is deleting")
+
+		return ctrl.Result{RequeueAfter: r.deletingPoll(mvmScope)}, nil
+	}
+
+	mvmScope.Info("microvm is stuck deleting", "deletingFor", deleting.Round(time.Second).String())
+	mvmScope.SetNotReady(infrav1.MicrovmStuckDeletingReason, "Warning",
+		"microvm has been deleting on its host for %s", deleting.Round(time.Second))
+
+	if r.ForceDeleteStuck && mvmScope.DeleteProtected() {
+		mvmScope.SetDeleteRefused("again while it is stuck deleting")
+	} else if r.ForceDeleteStuck {
+		mvmScope.Info("asking host to delete stuck microvm again")
+
+		_, err := mvmSvc.Delete(ctx)
+		recordHostReachable(mvmScope, err)
+
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: r.deletingPoll(mvmScope)}, nil
+}
+
+func (r *MicrovmReconciler) stuckDeleteTimeout() time.Duration {
+	if r.StuckDeleteTimeout == 0 {
+		return defaultStuckDeleteTimeout
+	}
+
+	return r.StuckDeleteTimeout
+}
+
+// recordPhase timestamps the first time the Microvm reaches phase and counts
+// how long it took in the provisioning latency metrics.
+func recordPhase(mvmScope *scope.MicrovmScope, phase scope.ProvisioningPhase) {
+	took, recorded := mvmScope.RecordProvisioningPhase(phase, time.Now())
+	if !recorded {
+		return
+	}
+
+	health.ObserveProvisioning(mvmScope.MicroVM.Spec.Host.Endpoint, string(phase), took)
+
+	if phase == scope.PhaseGuestReady {
+		health.ObserveProvisioned(mvmScope.MicroVM.Spec.Host.Endpoint, mvmScope.MicroVM.Spec.RootVolume.Image, took)
+	}
+}
+
+// verifyIdentity returns false, after marking the Microvm, if the host
+// returned a VM other than the one in its provider ID. Such a VM may belong to
+// someone else, so nothing is done to it until the provider ID is corrected.
+// A provider ID which cannot be parsed has no UID to check against.
+func verifyIdentity(mvmScope *scope.MicrovmScope, microvm *flintlocktypes.MicroVM) bool {
+	uid, expected := microvm.Spec.GetUid(), mvmScope.GetInstanceID()
+	if expected == "" || uid == expected {
+		mvmScope.SetIdentityVerified()
+
+		return true
+	}
+
+	mvmScope.Info("host returned a different microvm to the provider id, refusing to act on it",
+		logging.UIDKey, uid, "providerID", mvmScope.GetProviderID())
+	mvmScope.SetIdentityMismatch(fmt.Sprintf("host returned microvm %s for provider id %s, correct spec.providerID to resume",
+		uid, mvmScope.GetProviderID()))
+
+	return false
+}
+
+// recordHostReachable records on the Microvm whether its host answered a call
+// which returned err.
+func recordHostReachable(mvmScope *scope.MicrovmScope, err error) {
+	if flretry.Unreachable(err) {
+		mvmScope.SetHostUnreachable(err.Error())
+
+		return
+	}
+
+	mvmScope.SetHostReachable()
+}
+
+// recordOutcome reports a provisioning attempt against the host of the Microvm.
+func (r *MicrovmReconciler) recordOutcome(mvmScope *scope.MicrovmScope, succeeded bool) {
+	if r.HealthRecorder == nil {
+		return
+	}
+
+	r.HealthRecorder.Record(mvmScope.MicroVM.Spec.Host.Endpoint, succeeded, time.Now())
+}
+
+func hasVMState(mvmScope *scope.MicrovmScope, state microvm.VMState) bool {
+	current := mvmScope.MicroVM.Status.VMState
+
+	return current != nil && *current == state
+}
+
+// hostListOptions narrows the MicrovmHosts listed for a Microvm to those for
+// its host, when the index is available.
+func (r *MicrovmReconciler) hostListOptions(mvmScope *scope.MicrovmScope) []client.ListOption {
+	if !r.indexed {
+		return nil
+	}
+
+	return []client.ListOption{client.MatchingFields{hostEndpointField: mvmScope.MicroVM.Spec.Host.Endpoint}}
+}
+
+// hostToMicrovms maps a MicrovmHost to the Microvms on it, so that they are
+// reconciled as soon as the host leaves quarantine or is trusted again.
+func (r *MicrovmReconciler) hostToMicrovms(obj client.Object) []reconcile.Request {
+	host, ok := obj.(*infrav1.MicrovmHost)
+	if !ok {
+		return nil
+	}
+
+	mvms := &infrav1.MicrovmList{}
+	if err := r.List(context.Background(), mvms, client.MatchingFields{hostEndpointField: host.Spec.Endpoint}); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(mvms.Items))
+
+	for _, mvm := range mvms.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: mvm.Namespace, Name: mvm.Name},
+		})
+	}
+
+	return requests
+}
+
+// requeuePeriod returns how long to wait before checking on an object which
+// is still converging.
+func (r *MicrovmReconciler) requeuePeriod() time.Duration {
+	return r.Config.RequeuePeriod(config.Microvm)
+}
+
+// pendingPoll returns how long to wait before checking on a VM which is yet to
+// be created or become ready.
+func (r *MicrovmReconciler) pendingPoll(mvmScope *scope.MicrovmScope) time.Duration {
+	return r.clampInterval(mvmScope.PendingPoll(r.requeuePeriod()))
+}
+
+// runningResync returns how long to wait before checking on a VM which has
+// been created, or 0 if it is not checked again.
+func (r *MicrovmReconciler) runningResync(mvmScope *scope.MicrovmScope) time.Duration {
+	return r.clampInterval(mvmScope.RunningResync())
+}
+
+// deletingPoll returns how long to wait before checking on a VM which is being
+// deleted.
+func (r *MicrovmReconciler) deletingPoll(mvmScope *scope.MicrovmScope) time.Duration {
+	return r.clampInterval(mvmScope.DeletingPoll(r.requeuePeriod()))
+}
+
+// clampInterval raises an interval a Microvm asked for to the shortest the
+// operator allows. 0 is left as it is, as it is no requeue at all.
+func (r *MicrovmReconciler) clampInterval(interval time.Duration) time.Duration {
+	if floor := r.Config.MinReconcileInterval(config.Microvm); interval > 0 && interval < floor {
+		return floor
+	}
+
+	return interval
+}
+
+// soonest returns the shorter of two requeue periods, where 0 is no requeue.
+func soonest(a, b time.Duration) time.Duration {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+
+	return a
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MicrovmReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := indexByHostEndpoint(mgr); err != nil {
+		return fmt.Errorf("indexing by host endpoint: %w", err)
+	}
+
+	r.indexed = true
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&infrav1.Microvm{}).
-		Complete(r)
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvm", logging.MicrovmKey)).
+		Watches(
+			&source.Kind{Type: &infrav1.MicrovmHost{}},
+			handler.EnqueueRequestsFromMapFunc(r.hostToMicrovms),
+		).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(tracing.Reconciler("microvm", r))
 }
@@ -1,19 +1,44 @@
 package controllers_test
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
 	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
 	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers"
 	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/external"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/failure"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestagent"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/health"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/instanceidentity"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/phonehome"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func TestMicrovm_Reconcile_MissingObject(t *testing.T) {
@@ -37,6 +62,32 @@ func TestMicrovm_Reconcile_MissingHostEndpoint(t *testing.T) {
 	result, err := reconcileMicrovm(client, nil)
 	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when microvm does not have an endpoint set should not error")
 	g.Expect(result.IsZero()).To(BeTrue(), "Expect no requeue to be requested")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmInvalidSpecReason)
+	g.Expect(conditions.GetMessage(reconciled, infrav1.MicrovmReadyCondition)).To(ContainSubstring("spec.host.endpoint: Required value"))
+	g.Expect(reconciled.Finalizers).To(BeEmpty(), "Expected nothing to be done for the microvm")
+}
+
+func TestMicrovm_Reconcile_InvalidHostEndpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Spec.Host = microvm.Host{Endpoint: "http://127.0.0.1:9090"}
+
+	recorder := record.NewFakeRecorder(1)
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	result, err := reconcileMicrovmWith(client, nil, &controllers.MicrovmReconciler{Recorder: recorder})
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when microvm has an invalid endpoint should not error")
+	g.Expect(result.IsZero()).To(BeTrue(), "Expect no requeue to be requested")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmInvalidSpecReason)
+
+	g.Expect(recorder.Events).To(Receive(And(HavePrefix("Warning "+infrav1.MicrovmInvalidSpecReason), ContainSubstring("without a scheme"))))
 }
 
 func TestMicrovm_ReconcileNormal_ServiceGetError(t *testing.T) {
@@ -50,6 +101,30 @@ func TestMicrovm_ReconcileNormal_ServiceGetError(t *testing.T) {
 	client := createFakeClient(g, asRuntimeObject(mvm))
 	_, err := reconcileMicrovm(client, &fakeAPIClient)
 	g.Expect(err).To(HaveOccurred(), "Reconciling when microvm service 'Get' errors should return error")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	assertFinalizer(g, reconciled)
+}
+
+func TestMicrovm_ReconcileNormal_FinalizerPersistedBeforeCreate(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Spec.ProviderID = nil
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	fakeAPIClient.CreateMicroVMReturns(nil, errors.New("something terrible happened"))
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).To(HaveOccurred(), "Reconciling when microvm service 'Create' errors should return error")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(1))
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	assertFinalizer(g, reconciled)
 }
 
 func TestMicrovm_ReconcileNormal_VMExistsAndRunning(t *testing.T) {
@@ -109,6 +184,23 @@ func TestMicrovm_ReconcileNormal_VMExistsButFailed(t *testing.T) {
 	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmProvisionFailedReason)
 	assertVMState(g, reconciled, microvm.VMStateFailed)
 	assertFinalizer(g, reconciled)
+
+	// flintlock had not mounted anything, so the root volume is the first failure
+	g.Expect(reconciled.Status.FailureReason).To(Equal(pointer.String(string(failure.VolumeMountFailed))))
+	g.Expect(reconciled.Status.FailureMessage).NotTo(BeNil())
+	g.Expect(*reconciled.Status.FailureMessage).To(ContainSubstring("root"))
+	g.Expect(conditions.GetMessage(reconciled, infrav1.MicrovmReadyCondition)).To(HavePrefix(string(failure.VolumeMountFailed)))
+
+	// the failure is cleared once the microvm recovers
+	withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+	_, err = reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when microvm service exists should not error")
+
+	reconciled, err = getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(reconciled.Status.FailureReason).To(BeNil())
+	g.Expect(reconciled.Status.FailureMessage).To(BeNil())
 }
 
 func TestMicrovm_ReconcileNormal_VMExistsButUnknownState(t *testing.T) {
@@ -180,7 +272,8 @@ func TestMicrovm_ReconcileNormal_NoVmCreateWithUserdataSucceeds(t *testing.T) {
 
 	_, createReq, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
 	g.Expect(createReq.Microvm).ToNot(BeNil())
-	g.Expect(createReq.Microvm.Metadata).To(HaveLen(3))
+	g.Expect(createReq.Microvm.Metadata).To(HaveLen(4))
+	g.Expect(createReq.Microvm.Metadata).To(HaveKey(instanceidentity.MetadataKey))
 	g.Expect(createReq.Microvm.Metadata).To(HaveKeyWithValue("user-data", testBootstrapData))
 }
 
@@ -211,6 +304,123 @@ func TestMicrovm_ReconcileNormal_NoVmCreateWithLabelsSucceeds(t *testing.T) {
 	g.Expect(createReq.Microvm.Labels).To(Equal(expectedLabels))
 }
 
+func TestMicrovm_ReconcileNormal_NoVmCreateWithKernelArgsSucceeds(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	expectedArgs := map[string]string{
+		"console": "ttyS0",
+		"quiet":   "",
+	}
+
+	mvm := createMicrovm()
+	mvm.Spec.ProviderID = nil
+	mvm.Spec.KernelCmdLine = expectedArgs
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when creating microvm should not return error")
+
+	_, createReq, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
+	g.Expect(createReq.Microvm.Kernel).ToNot(BeNil())
+	g.Expect(createReq.Microvm.Kernel.Cmdline).To(Equal(expectedArgs))
+}
+
+func TestMicrovm_ReconcileNormal_ImagePolicy(t *testing.T) {
+	t.Parallel()
+
+	digest := "sha256:" + strings.Repeat("c", 64)
+	kernel := "docker.io/richardcase/ubuntu-bionic-kernel:0.0.11"
+	root := "docker.io/richardcase/ubuntu-bionic-test:cloudimage_v0.0.1"
+
+	tt := []struct {
+		name     string
+		policy   infrav1.ImagePolicy
+		resolver *fakeImageResolver
+		expected func(*WithT, *fakes.FakeClient, *infrav1.Microvm, error)
+	}{
+		{
+			name:     "tag",
+			policy:   infrav1.ImagePolicyTag,
+			resolver: &fakeImageResolver{digest: digest},
+			expected: func(g *WithT, fakeAPIClient *fakes.FakeClient, reconciled *infrav1.Microvm, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+
+				_, createReq, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
+				g.Expect(createReq.Microvm.Kernel.Image).To(Equal(kernel))
+				g.Expect(reconciled.Status.ResolvedImages).To(BeEmpty())
+			},
+		},
+		{
+			name:     "resolve",
+			policy:   infrav1.ImagePolicyResolve,
+			resolver: &fakeImageResolver{digest: digest},
+			expected: func(g *WithT, fakeAPIClient *fakes.FakeClient, reconciled *infrav1.Microvm, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+
+				_, createReq, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
+				g.Expect(createReq.Microvm.Kernel.Image).To(Equal(kernel + "@" + digest))
+				g.Expect(createReq.Microvm.Initrd.Image).To(Equal(kernel + "@" + digest))
+				g.Expect(createReq.Microvm.RootVolume.Source.ContainerSource).To(Equal(pointer.String(root + "@" + digest)))
+				g.Expect(reconciled.Spec.Kernel.Image).To(Equal(kernel), "Expected the spec to keep the tag")
+				g.Expect(reconciled.Status.ResolvedImages).To(Equal(map[string]string{
+					kernel: kernel + "@" + digest,
+					root:   root + "@" + digest,
+				}))
+			},
+		},
+		{
+			name:     "resolve fails",
+			policy:   infrav1.ImagePolicyResolve,
+			resolver: &fakeImageResolver{err: errors.New("registry down")},
+			expected: func(g *WithT, fakeAPIClient *fakes.FakeClient, reconciled *infrav1.Microvm, err error) {
+				g.Expect(err).To(MatchError("registry down"))
+				g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(0))
+				assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmImageResolveFailedReason)
+			},
+		},
+		{
+			name:     "digest only",
+			policy:   infrav1.ImagePolicyDigestOnly,
+			resolver: &fakeImageResolver{digest: digest},
+			expected: func(g *WithT, fakeAPIClient *fakes.FakeClient, reconciled *infrav1.Microvm, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(0))
+				assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmImageNotPinnedReason)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Spec.ProviderID = nil
+			mvm.Spec.ImagePolicy = tc.policy
+
+			fakeAPIClient := fakes.FakeClient{}
+			withMissingMicrovm(&fakeAPIClient)
+			withCreateMicrovmSuccess(&fakeAPIClient)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{ImageResolver: tc.resolver})
+
+			reconciled, getErr := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(getErr).NotTo(HaveOccurred())
+
+			tc.expected(g, &fakeAPIClient, reconciled, err)
+		})
+	}
+}
+
 func TestMicrovm_ReconcileNormal_NoVmCreateWithSSHSucceeds(t *testing.T) {
 	t.Parallel()
 	g := NewWithT(t)
@@ -239,7 +449,8 @@ func TestMicrovm_ReconcileNormal_NoVmCreateWithSSHSucceeds(t *testing.T) {
 	_, createReq, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
 	g.Expect(createReq.Microvm).ToNot(BeNil())
 	// g.Expect(createReq.Microvm.Labels).To(HaveLen(1))
-	g.Expect(createReq.Microvm.Metadata).To(HaveLen(3))
+	g.Expect(createReq.Microvm.Metadata).To(HaveLen(4))
+	g.Expect(createReq.Microvm.Metadata).To(HaveKey(instanceidentity.MetadataKey))
 
 	// expectedBootstrapData := base64.StdEncoding.EncodeToString([]byte(testbootStrapData))
 	// g.Expect(createReq.Microvm.Metadata).To(HaveKeyWithValue("user-data", expectedBootstrapData))
@@ -329,6 +540,69 @@ func TestMicrovm_ReconcileDelete_GetReturnsNil(t *testing.T) {
 	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
 }
 
+func TestMicrovm_ReconcileDelete_ReleasesExternalResources(t *testing.T) {
+	tt := []struct {
+		name     string
+		tracked  []infrav1.ExternalResourceRef
+		expected func(*WithT, client.Client)
+	}{
+		{
+			name: "finalizer is removed once everything is released",
+			tracked: []infrav1.ExternalResourceRef{
+				{Kind: external.KindService, Name: "svc1"},
+			},
+			expected: func(g *WithT, c client.Client) {
+				_, err := getMicrovm(c, testMicrovmName, testNamespace)
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the microvm to be deleted")
+
+				err = c.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: "svc1"}, &corev1.Service{})
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the service to be released")
+			},
+		},
+		{
+			name: "finalizer is kept while anything is held",
+			tracked: []infrav1.ExternalResourceRef{
+				{Kind: external.KindService, Name: "svc1"},
+				{Kind: "DNSRecord", Name: "mvm1.example.com"},
+			},
+			expected: func(g *WithT, c client.Client) {
+				reconciled, err := getMicrovm(c, testMicrovmName, testNamespace)
+				g.Expect(err).NotTo(HaveOccurred(), "Expected the microvm to be kept")
+				g.Expect(reconciled.Finalizers).To(ContainElement(infrav1.MvmFinalizer))
+				g.Expect(reconciled.Status.ExternalResources).To(ConsistOf(
+					infrav1.ExternalResourceRef{Kind: "DNSRecord", Name: "mvm1.example.com"},
+				), "Expected only the held resource to still be tracked")
+				assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmReleaseFailedReason)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.UID = "mvm-uid"
+			mvm.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			mvm.Finalizers = []string{infrav1.MvmFinalizer}
+			mvm.Status.ExternalResources = tc.tracked
+
+			fakeAPIClient := fakes.FakeClient{}
+			withMissingMicrovm(&fakeAPIClient)
+
+			client := createFakeClient(g, append(asRuntimeObject(mvm), createMicrovmService("svc1", "mvm-uid")))
+
+			registry := external.NewRegistry()
+			registry.Register(external.KindService, &external.Services{Client: client})
+
+			_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{ExternalResources: registry})
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling when deleting microvm should not return error")
+
+			tc.expected(g, client)
+		})
+	}
+}
+
 func TestMicrovm_ReconcileDelete_GetErrors(t *testing.T) {
 	g := NewWithT(t)
 
@@ -370,3 +644,2119 @@ func TestMicrovm_ReconcileDelete_DeleteErrors(t *testing.T) {
 	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmDeleteFailedReason)
 	assertMicrovmNotReady(g, reconciled)
 }
+
+func TestMicrovm_ReconcileDelete_GracefulShutdown(t *testing.T) {
+	tt := []struct {
+		name        string
+		endpoint    string
+		requestedAt *metav1.Time
+		shutdownErr error
+		expected    func(*WithT, *fakes.FakeClient, *fakeShutdownClient, *infrav1.Microvm, ctrl.Result)
+	}{
+		{
+			name: "guest is asked to shut down and given the grace period",
+			expected: func(g *WithT, fc *fakes.FakeClient, sc *fakeShutdownClient, reconciled *infrav1.Microvm, result ctrl.Result) {
+				g.Expect(sc.endpoints).To(ConsistOf("http://10.0.0.10:8080"))
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0), "Expected delete to wait for the grace period")
+				g.Expect(result.RequeueAfter).To(BeNumerically("~", time.Minute, time.Second))
+				g.Expect(reconciled.Status.ShutdownRequestedAt).NotTo(BeNil())
+				assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmShuttingDownReason)
+			},
+		},
+		{
+			name:        "microvm is deleted once the grace period has passed",
+			requestedAt: &metav1.Time{Time: time.Now().Add(-2 * time.Minute)},
+			expected: func(g *WithT, fc *fakes.FakeClient, sc *fakeShutdownClient, reconciled *infrav1.Microvm, result ctrl.Result) {
+				g.Expect(sc.endpoints).To(BeEmpty(), "Expected shutdown not to be requested again")
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+			},
+		},
+		{
+			name:        "microvm is deleted straight away when the guest can't be reached",
+			shutdownErr: errors.New("connection refused"),
+			expected: func(g *WithT, fc *fakes.FakeClient, sc *fakeShutdownClient, reconciled *infrav1.Microvm, result ctrl.Result) {
+				g.Expect(sc.endpoints).To(HaveLen(1))
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+				g.Expect(reconciled.Status.ShutdownRequestedAt).To(BeNil())
+			},
+		},
+		{
+			name:     "shutdown is not requested from an address outside the guest",
+			endpoint: "http://169.254.169.254",
+			expected: func(g *WithT, fc *fakes.FakeClient, sc *fakeShutdownClient, reconciled *infrav1.Microvm, result ctrl.Result) {
+				g.Expect(sc.endpoints).To(BeEmpty())
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+				g.Expect(reconciled.Status.ShutdownRequestedAt).To(BeNil())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.DeletionTimestamp = &metav1.Time{
+				Time: time.Now(),
+			}
+			mvm.Spec.ProviderID = pointer.String(fmt.Sprintf("microvm://127.0.0.1:9090/%s", testMicrovmUID))
+			mvm.Finalizers = []string{infrav1.MvmFinalizer}
+			mvm.Spec.NetworkInterfaces[0].Address = "10.0.0.10/24"
+			mvm.Spec.GracefulShutdown = &infrav1.GracefulShutdown{
+				AgentEndpoint:      "http://10.0.0.10:8080",
+				GracePeriodSeconds: 60,
+			}
+			mvm.Status.ShutdownRequestedAt = tc.requestedAt
+
+			if tc.endpoint != "" {
+				mvm.Spec.GracefulShutdown.AgentEndpoint = tc.endpoint
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+			shutdownClient := &fakeShutdownClient{err: tc.shutdownErr}
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+
+			result, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{ShutdownClient: shutdownClient})
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling when deleting microvm should not return error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+			tc.expected(g, &fakeAPIClient, shutdownClient, reconciled, result)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileDelete_PendingCreate(t *testing.T) {
+	tt := []struct {
+		name      string
+		deletedAt time.Time
+		expected  func(*WithT, *fakes.FakeClient, *infrav1.Microvm, ctrl.Result)
+	}{
+		{
+			name:      "delete waits for a pending create to settle",
+			deletedAt: time.Now(),
+			expected: func(g *WithT, fc *fakes.FakeClient, reconciled *infrav1.Microvm, result ctrl.Result) {
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0), "Expected delete to wait for the create")
+				g.Expect(result.RequeueAfter).To(BeNumerically("~", 30*time.Second, time.Second))
+				assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmWaitingForCreateReason)
+			},
+		},
+		{
+			name:      "microvm is deleted once the grace has passed",
+			deletedAt: time.Now().Add(-3 * time.Minute),
+			expected: func(g *WithT, fc *fakes.FakeClient, reconciled *infrav1.Microvm, result ctrl.Result) {
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.DeletionTimestamp = &metav1.Time{Time: tc.deletedAt}
+			mvm.Spec.ProviderID = pointer.String(fmt.Sprintf("microvm://127.0.0.1:9090/%s", testMicrovmUID))
+			mvm.Finalizers = []string{infrav1.MvmFinalizer}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_PENDING)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+
+			result, err := reconcileMicrovm(client, &fakeAPIClient)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling when deleting microvm should not return error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+			tc.expected(g, &fakeAPIClient, reconciled, result)
+		})
+	}
+}
+
+func TestMicrovm_Reconcile_Deleting(t *testing.T) {
+	tt := []struct {
+		name      string
+		deleting  bool
+		startedAt *metav1.Time
+		force     bool
+		expected  func(*WithT, *infrav1.Microvm, *fakes.FakeClient)
+	}{
+		{
+			name: "first seen deleting records when",
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				g.Expect(mvm.Status.DeletionStartedAt).NotTo(BeNil())
+				g.Expect(conditions.GetReason(mvm, infrav1.MicrovmReadyCondition)).NotTo(Equal(infrav1.MicrovmStuckDeletingReason))
+			},
+		},
+		{
+			name:      "deleting within the timeout is waited for",
+			deleting:  true,
+			startedAt: &metav1.Time{Time: time.Now().Add(-time.Minute)},
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmDeletingReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(BeZero())
+			},
+		},
+		{
+			name:      "deleting past the timeout is marked stuck",
+			startedAt: &metav1.Time{Time: time.Now().Add(-time.Hour)},
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmStuckDeletingReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(BeZero(), "Expected no delete without force delete")
+			},
+		},
+		{
+			name:      "stuck delete is sent again with force delete",
+			deleting:  true,
+			startedAt: &metav1.Time{Time: time.Now().Add(-time.Hour)},
+			force:     true,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmStuckDeletingReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Finalizers = []string{infrav1.MvmFinalizer}
+			mvm.Status.DeletionStartedAt = tc.startedAt
+			if tc.deleting {
+				mvm.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_DELETING)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			result, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{ForceDeleteStuck: tc.force})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected the delete to be checked again")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+			tc.expected(g, reconciled, &fakeAPIClient)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_ClearsDeletionStarted(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Status.DeletionStartedAt = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(reconciled.Status.DeletionStartedAt).To(BeNil(), "Expected a microvm which is no longer deleting to be cleared")
+}
+
+func TestMicrovm_ReconcileNormal_HostQuarantined(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Spec.ProviderID = nil
+
+	mvmH := createMicrovmHost()
+	mvmH.Status.Quarantined = true
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	client := createFakeClient(g, []runtime.Object{mvm, mvmH})
+	result, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when the host is quarantined should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect requeue to be requested")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(0), "Expect no microvm to be created on a quarantined host")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmHostQuarantinedReason)
+}
+
+func TestMicrovm_ReconcileNormal_RestoreFromMissingSnapshot(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Spec.ProviderID = nil
+	mvm.Spec.RestoreFrom = &corev1.LocalObjectReference{Name: testMicrovmSnapshotName}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	client := createFakeClient(g, []runtime.Object{mvm})
+	result, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling while the snapshot does not exist should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect requeue to be requested in case the snapshot is created")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(0), "Expect no microvm to be created without the snapshot")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmSnapshotNotFoundReason)
+
+	condition := conditions.Get(reconciled, infrav1.MicrovmReadyCondition)
+	g.Expect(condition.Severity).To(Equal(clusterv1.ConditionSeverityWarning))
+	g.Expect(condition.Message).To(ContainSubstring(testMicrovmSnapshotName), "Expected the message to name the snapshot")
+}
+
+func TestMicrovm_ReconcileNormal_RestoreFromSnapshot(t *testing.T) {
+	g := NewWithT(t)
+
+	snapshotSpec := &microvm.VMSpec{
+		VCPU:     4,
+		MemoryMb: 4096,
+		RootVolume: microvm.Volume{
+			Image: "docker.io/org/golden-root:v1",
+		},
+		Kernel: microvm.ContainerFileSource{
+			Image:    "docker.io/org/golden-kernel:v1",
+			Filename: "vmlinuz",
+		},
+		NetworkInterfaces: []microvm.NetworkInterface{
+			{GuestDeviceName: "eth9", GuestMAC: "AA:BB:CC:DD:EE:FF"},
+		},
+	}
+
+	mvm := createMicrovm()
+	mvm.Spec.ProviderID = nil
+	mvm.Spec.RestoreFrom = &corev1.LocalObjectReference{Name: testMicrovmSnapshotName}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	snapshot := createMicrovmSnapshot(nil)
+
+	client := createFakeClient(g, []runtime.Object{mvm, snapshot})
+	result, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling while the snapshot is not ready should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect requeue to be requested")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(0), "Expect no microvm to be created before the snapshot is ready")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmSnapshotNotReadyReason)
+
+	snapshot, err = getMicrovmSnapshot(client, testMicrovmSnapshotName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmsnapshot should not fail")
+	snapshot.Status.Ready = true
+	snapshot.Status.VMSpec = snapshotSpec
+	g.Expect(client.Status().Update(context.TODO(), snapshot)).To(Succeed())
+
+	_, err = reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling once the snapshot is ready should not error")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(1), "Expect the microvm to be created from the snapshot")
+
+	_, createReq, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
+	g.Expect(createReq.Microvm.Vcpu).To(Equal(int32(4)))
+	g.Expect(createReq.Microvm.MemoryInMb).To(Equal(int32(4096)))
+	g.Expect(*createReq.Microvm.RootVolume.Source.ContainerSource).To(Equal("docker.io/org/golden-root:v1"))
+	g.Expect(createReq.Microvm.Kernel.Image).To(Equal("docker.io/org/golden-kernel:v1"))
+	g.Expect(createReq.Microvm.Initrd).To(BeNil())
+	g.Expect(createReq.Microvm.Interfaces).To(HaveLen(1))
+	g.Expect(createReq.Microvm.Interfaces[0].DeviceId).To(Equal("eth0"), "Expect the network interfaces of the microvm to be kept")
+
+	reconciled, err = getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(reconciled.Spec.VCPU).To(Equal(int64(4)), "Expect the restored spec to be persisted")
+}
+
+func TestMicrovm_ReconcileNormal_MACPool(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.UID = testMicrovmUID
+	mvm.Spec.ProviderID = nil
+	mvm.Spec.MACPoolRef = &corev1.LocalObjectReference{Name: testMicrovmMACPoolName}
+	mvm.Spec.NetworkInterfaces = append(mvm.Spec.NetworkInterfaces, microvm.NetworkInterface{
+		GuestDeviceName: "eth1",
+		GuestMAC:        "02:aa:00:00:00:01",
+		Type:            microvm.IfaceTypeTap,
+	})
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	client := createFakeClient(g, []runtime.Object{mvm})
+	result, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling while the pool is missing should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect requeue to be requested")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(0), "Expect no microvm to be created without a mac address")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmMACPoolUnavailableReason)
+
+	pool := createMicrovmMACPool("02:00:00:00:00:01", "02:00:00:00:00:02")
+	pool.Spec.Allocations = []infrav1.MACAllocation{
+		{Address: "02:00:00:00:00:01", Microvm: "other", UID: "OTHER", Interface: "eth0"},
+	}
+	g.Expect(client.Create(context.TODO(), pool)).To(Succeed())
+
+	_, err = reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling once the pool exists should not error")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(1), "Expect the microvm to be created")
+
+	_, createReq, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
+	g.Expect(createReq.Microvm.Interfaces).To(HaveLen(2))
+	g.Expect(*createReq.Microvm.Interfaces[0].GuestMac).To(Equal("02:00:00:00:00:02"), "Expect the next free address of the pool")
+	g.Expect(*createReq.Microvm.Interfaces[1].GuestMac).To(Equal("02:aa:00:00:00:01"), "Expect an explicit mac address to be kept")
+
+	reconciled, err = getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(reconciled.Spec.NetworkInterfaces[0].GuestMAC).To(Equal("02:00:00:00:00:02"), "Expect the allocated address to be persisted")
+
+	pool, err = getMicrovmMACPool(client, testMicrovmMACPoolName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmmacpool should not fail")
+	g.Expect(pool.Spec.Allocations).To(ContainElement(infrav1.MACAllocation{
+		Address:   "02:00:00:00:00:02",
+		Microvm:   testMicrovmName,
+		UID:       testMicrovmUID,
+		Interface: "eth0",
+	}))
+	g.Expect(reconciled.Status.ExternalResources).To(ContainElement(infrav1.ExternalResourceRef{
+		Kind: external.KindMACPool,
+		Name: testMicrovmMACPoolName,
+	}), "Expect the pool to be tracked so that the address is released")
+}
+
+func TestMicrovm_ReconcileNormal_MACPoolExhausted(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.UID = testMicrovmUID
+	mvm.Spec.ProviderID = nil
+	mvm.Spec.MACPoolRef = &corev1.LocalObjectReference{Name: testMicrovmMACPoolName}
+
+	pool := createMicrovmMACPool("02:00:00:00:00:01")
+	pool.Spec.Allocations = []infrav1.MACAllocation{
+		{Address: "02:00:00:00:00:01", Microvm: "other", UID: "OTHER", Interface: "eth0"},
+	}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	client := createFakeClient(g, []runtime.Object{mvm, pool})
+	result, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling with an exhausted pool should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect requeue to be requested")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(0), "Expect no microvm to be created without a mac address")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmMACPoolUnavailableReason)
+	g.Expect(reconciled.Spec.NetworkInterfaces[0].GuestMAC).To(BeEmpty())
+}
+
+func TestMicrovm_ReconcileDelete_ReleasesMACs(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.UID = testMicrovmUID
+	mvm.DeletionTimestamp = &metav1.Time{
+		Time: time.Now(),
+	}
+	mvm.Finalizers = []string{infrav1.MvmFinalizer}
+	mvm.Spec.MACPoolRef = &corev1.LocalObjectReference{Name: testMicrovmMACPoolName}
+	mvm.Spec.NetworkInterfaces[0].GuestMAC = "02:00:00:00:00:02"
+
+	pool := createMicrovmMACPool("02:00:00:00:00:01", "02:00:00:00:00:02")
+	pool.Spec.Allocations = []infrav1.MACAllocation{
+		{Address: "02:00:00:00:00:01", Microvm: "other", UID: "OTHER", Interface: "eth0"},
+		{Address: "02:00:00:00:00:02", Microvm: testMicrovmName, UID: testMicrovmUID, Interface: "eth0"},
+	}
+	mvm.Status.ExternalResources = []infrav1.ExternalResourceRef{
+		{Kind: external.KindMACPool, Name: testMicrovmMACPoolName},
+	}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+
+	client := createFakeClient(g, []runtime.Object{mvm, pool})
+	_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{
+		ExternalResources: external.NewDefaultRegistry(client),
+	})
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when deleting microvm should not return error")
+
+	_, err = getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	pool, err = getMicrovmMACPool(client, testMicrovmMACPoolName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmmacpool should not fail")
+	g.Expect(pool.Spec.Allocations).To(HaveLen(1), "Expect the address of the microvm to be released")
+	g.Expect(pool.Spec.Allocations[0].Microvm).To(Equal("other"))
+}
+
+func TestMicrovm_ReconcileNormal_IPPool(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.UID = testMicrovmUID
+	mvm.Spec.ProviderID = nil
+	mvm.Spec.NetworkInterfaces = append(mvm.Spec.NetworkInterfaces, microvm.NetworkInterface{
+		GuestDeviceName: "eth1",
+		Type:            microvm.IfaceTypeTap,
+		Address:         "172.16.0.10/16",
+	})
+	mvm.Spec.IPPools = []infrav1.InterfaceIPPool{
+		{Interface: "eth0", PoolRef: corev1.LocalObjectReference{Name: testMicrovmIPPoolName}},
+		{Interface: "eth1", PoolRef: corev1.LocalObjectReference{Name: testMicrovmIPPoolName}},
+	}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	client := createFakeClient(g, []runtime.Object{mvm})
+	result, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling while the pool is missing should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect requeue to be requested")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(0), "Expect no microvm to be created without an address")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmIPPoolUnavailableReason)
+
+	pool := createMicrovmIPPool("10.0.0.0/24")
+	pool.Spec.Gateway = "10.0.0.1"
+	pool.Spec.Nameservers = []string{"10.0.0.53"}
+	g.Expect(client.Create(context.TODO(), pool)).To(Succeed())
+
+	_, err = reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling once the pool exists should not error")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(1), "Expect the microvm to be created")
+
+	_, createReq, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
+	g.Expect(createReq.Microvm.Interfaces).To(HaveLen(2))
+	address := createReq.Microvm.Interfaces[0].Address
+	g.Expect(address.Address).To(Equal("10.0.0.2/24"), "Expect the first free address of the pool")
+	g.Expect(*address.Gateway).To(Equal("10.0.0.1"))
+	g.Expect(address.Nameservers).To(Equal([]string{"10.0.0.53"}))
+	g.Expect(createReq.Microvm.Interfaces[1].Address.Address).To(Equal("172.16.0.10/16"), "Expect an explicit address to be kept")
+	g.Expect(createReq.Microvm.Interfaces[1].Address.Gateway).To(BeNil())
+
+	reconciled, err = getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(reconciled.Spec.NetworkInterfaces[0].Address).To(Equal("10.0.0.2/24"), "Expect the allocated address to be persisted")
+
+	pool, err = getMicrovmIPPool(client, testMicrovmIPPoolName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmippool should not fail")
+	g.Expect(pool.Spec.Allocations).To(ConsistOf(infrav1.IPAllocation{
+		Address:   "10.0.0.2",
+		Microvm:   testMicrovmName,
+		UID:       testMicrovmUID,
+		Interface: "eth0",
+	}))
+	g.Expect(reconciled.Status.ExternalResources).To(ConsistOf(infrav1.ExternalResourceRef{
+		Kind: external.KindIPPool,
+		Name: testMicrovmIPPoolName,
+	}), "Expect only the pool the address was allocated from to be tracked")
+}
+
+func TestMicrovm_ReconcileNormal_IPPoolLeaseReusedOnRetry(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.UID = testMicrovmUID
+	mvm.Spec.ProviderID = nil
+	mvm.Spec.NetworkInterfaces[0].Address = "10.0.0.7/24"
+	mvm.Spec.IPPools = []infrav1.InterfaceIPPool{
+		{Interface: "eth0", PoolRef: corev1.LocalObjectReference{Name: testMicrovmIPPoolName}},
+	}
+
+	pool := createMicrovmIPPool("10.0.0.0/24")
+	pool.Spec.Gateway = "10.0.0.1"
+	pool.Spec.Allocations = []infrav1.IPAllocation{
+		{Address: "10.0.0.7", Microvm: testMicrovmName, UID: testMicrovmUID, Interface: "eth0"},
+	}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	client := createFakeClient(g, []runtime.Object{mvm, pool})
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling a microvm should not error")
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(1), "Expect the microvm to be created")
+
+	_, createReq, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
+	g.Expect(createReq.Microvm.Interfaces[0].Address.Address).To(Equal("10.0.0.7/24"))
+	g.Expect(*createReq.Microvm.Interfaces[0].Address.Gateway).To(Equal("10.0.0.1"), "Expect the gateway of an earlier allocation")
+
+	pool, err = getMicrovmIPPool(client, testMicrovmIPPoolName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmippool should not fail")
+	g.Expect(pool.Spec.Allocations).To(HaveLen(1), "Expect no further address to be allocated")
+}
+
+func TestMicrovm_ReconcileDelete_ReleasesIPs(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.UID = testMicrovmUID
+	mvm.DeletionTimestamp = &metav1.Time{
+		Time: time.Now(),
+	}
+	mvm.Finalizers = []string{infrav1.MvmFinalizer}
+	mvm.Spec.NetworkInterfaces[0].Address = "10.0.0.2/24"
+	mvm.Spec.IPPools = []infrav1.InterfaceIPPool{
+		{Interface: "eth0", PoolRef: corev1.LocalObjectReference{Name: testMicrovmIPPoolName}},
+	}
+
+	pool := createMicrovmIPPool("10.0.0.0/24")
+	pool.Spec.Allocations = []infrav1.IPAllocation{
+		{Address: "10.0.0.1", Microvm: "other", UID: "OTHER", Interface: "eth0"},
+		{Address: "10.0.0.2", Microvm: testMicrovmName, UID: testMicrovmUID, Interface: "eth0"},
+	}
+	mvm.Status.ExternalResources = []infrav1.ExternalResourceRef{
+		{Kind: external.KindIPPool, Name: testMicrovmIPPoolName},
+	}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+
+	client := createFakeClient(g, []runtime.Object{mvm, pool})
+	_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{
+		ExternalResources: external.NewDefaultRegistry(client),
+	})
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when deleting microvm should not return error")
+
+	_, err = getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	pool, err = getMicrovmIPPool(client, testMicrovmIPPoolName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmippool should not fail")
+	g.Expect(pool.Spec.Allocations).To(HaveLen(1), "Expect the address of the microvm to be released")
+	g.Expect(pool.Spec.Allocations[0].Microvm).To(Equal("other"))
+}
+
+func TestMicrovm_Reconcile_HostUntrusted(t *testing.T) {
+	tt := []struct {
+		name     string
+		deleting bool
+	}{
+		{
+			name: "reconcile normal",
+		},
+		{
+			name:     "reconcile delete",
+			deleting: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Finalizers = []string{infrav1.MvmFinalizer}
+			if tc.deleting {
+				mvm.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			}
+
+			mvmH := createMicrovmHost()
+			mvmH.Status.Untrusted = true
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+			client := createFakeClient(g, []runtime.Object{mvm, mvmH})
+			result, err := reconcileMicrovm(client, &fakeAPIClient)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling when the host is untrusted should not error")
+			g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect requeue to be requested")
+			g.Expect(fakeAPIClient.GetMicroVMCallCount()).To(Equal(0), "Expect no requests to be sent to an untrusted host")
+			g.Expect(fakeAPIClient.DeleteMicroVMCallCount()).To(Equal(0), "Expect no requests to be sent to an untrusted host")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+			assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmHostUntrustedReason)
+		})
+	}
+}
+
+func TestMicrovm_Reconcile_PinnedHost(t *testing.T) {
+	tt := []struct {
+		name         string
+		verification infrav1.IdentityVerification
+		expected     string
+	}{
+		{
+			name:     "an enforced identity is pinned",
+			expected: "sha256:recorded",
+		},
+		{
+			name:         "a reported identity is not pinned",
+			verification: infrav1.WarnIdentityVerification,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+
+			mvmH := createMicrovmHost()
+			mvmH.Spec.Identity.Verification = tc.verification
+			mvmH.Status.Identity = &infrav1.HostIdentity{Fingerprint: "sha256:recorded"}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+			var pinned string
+
+			client := createFakeClient(g, []runtime.Object{mvm, mvmH})
+			_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{
+				PinnedClientFunc: func(cfg identity.ClientConfig) flclient.FactoryFunc {
+					pinned = cfg.Fingerprint
+
+					return func(string, ...flclient.Options) (flclient.Client, error) {
+						return &fakeAPIClient, nil
+					}
+				},
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pinned).To(Equal(tc.expected))
+			g.Expect(fakeAPIClient.GetMicroVMCallCount()).To(Equal(1))
+		})
+	}
+}
+
+func TestMicrovm_ReconcileDelete_HostUntrustedForceDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Finalizers = []string{infrav1.MvmFinalizer}
+	mvm.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	mvm.Annotations = map[string]string{infrav1.ForceDeleteAnnotation: "true"}
+
+	mvmH := createMicrovmHost()
+	mvmH.Status.Untrusted = true
+
+	fakeAPIClient := fakes.FakeClient{}
+	withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+	client := createFakeClient(g, []runtime.Object{mvm, mvmH})
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when force deleting from an untrusted host should not error")
+	g.Expect(fakeAPIClient.GetMicroVMCallCount()).To(Equal(0), "Expect no requests to be sent to an untrusted host")
+	g.Expect(fakeAPIClient.DeleteMicroVMCallCount()).To(Equal(0), "Expect no requests to be sent to an untrusted host")
+
+	_, err = getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expect the finalizer to be removed")
+}
+
+func TestMicrovm_Reconcile_HostUnreachable(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Status.Ready = true
+	conditions.MarkTrue(mvm, infrav1.MicrovmReadyCondition)
+
+	fakeAPIClient := fakes.FakeClient{}
+	fakeAPIClient.GetMicroVMReturns(nil, status.Error(codes.Unavailable, "connection refused"))
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).To(HaveOccurred(), "Reconciling when the host is unreachable should return error")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+	assertConditionFalse(g, reconciled, infrav1.MicrovmHostReachableCondition, infrav1.MicrovmHostUnreachableReason)
+	g.Expect(conditions.GetMessage(reconciled, infrav1.MicrovmHostReachableCondition)).To(ContainSubstring("connection refused"))
+	assertConditionTrue(g, reconciled, infrav1.MicrovmReadyCondition)
+	g.Expect(reconciled.Status.Ready).To(BeTrue(), "Expected an unreachable host to leave the microvm ready")
+	g.Expect(reconciled.Status.FailureReason).To(BeNil())
+	g.Expect(reconciled.Status.Phase).To(Equal(infrav1.PhaseRunning))
+
+	withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+	_, err = reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	reconciled, err = getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+	assertConditionTrue(g, reconciled, infrav1.MicrovmHostReachableCondition)
+	assertMicrovmReconciled(g, reconciled)
+}
+
+func TestMicrovm_ReconcileNormal_CreateHostUnreachable(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Spec.ProviderID = nil
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	fakeAPIClient.CreateMicroVMReturns(nil, status.Error(codes.DeadlineExceeded, "context deadline exceeded"))
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).To(HaveOccurred(), "Reconciling when the host is unreachable should return error")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+	assertConditionFalse(g, reconciled, infrav1.MicrovmHostReachableCondition, infrav1.MicrovmHostUnreachableReason)
+	g.Expect(reconciled.Status.FailureReason).To(BeNil())
+	g.Expect(reconciled.Status.Phase).To(Equal(infrav1.PhaseProvisioning))
+}
+
+func TestMicrovm_ReconcileDelete_HostUnreachable(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.DeletionTimestamp = &metav1.Time{
+		Time: time.Now(),
+	}
+	mvm.Finalizers = []string{infrav1.MvmFinalizer}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+	fakeAPIClient.DeleteMicroVMReturns(nil, status.Error(codes.Unavailable, "connection refused"))
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).To(HaveOccurred(), "Reconciling when the host is unreachable should return error")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+	assertConditionFalse(g, reconciled, infrav1.MicrovmHostReachableCondition, infrav1.MicrovmHostUnreachableReason)
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmDeletingReason)
+}
+
+func TestMicrovm_ReconcileDelete_DeleteProtected(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.DeletionTimestamp = &metav1.Time{
+		Time: time.Now(),
+	}
+	mvm.Finalizers = []string{infrav1.MvmFinalizer}
+	mvm.Annotations = map[string]string{infrav1.DeleteProtectionAnnotation: "true"}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling a protected microvm should not error")
+	g.Expect(fakeAPIClient.DeleteMicroVMCallCount()).To(Equal(0), "Expected a protected microvm not to be deleted from its host")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(reconciled.Finalizers).To(ContainElement(infrav1.MvmFinalizer), "Expected the finalizer to be kept")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeleteAllowedCondition, infrav1.MicrovmDeleteProtectedReason)
+	g.Expect(conditions.Get(reconciled, infrav1.MicrovmDeleteAllowedCondition).Severity).To(Equal(clusterv1.ConditionSeverityWarning))
+
+	// the delete goes ahead once the annotation is removed
+	reconciled.Annotations = nil
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvm should not error")
+	g.Expect(fakeAPIClient.DeleteMicroVMCallCount()).To(Equal(1), "Expected the microvm to be deleted from its host")
+
+	reconciled, err = getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(conditions.Has(reconciled, infrav1.MicrovmDeleteAllowedCondition)).To(BeFalse())
+}
+
+func TestMicrovm_Reconcile_IdentityMismatch(t *testing.T) {
+	tt := []struct {
+		name     string
+		deleting bool
+	}{
+		{name: "reconcile normal"},
+		{name: "reconcile delete", deleting: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Finalizers = []string{infrav1.MvmFinalizer}
+			if tc.deleting {
+				mvm.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			}
+
+			mvm.Spec.ProviderID = pointer.String(fmt.Sprintf("microvm://%s/%s", testHostEndpoint, testMicrovmUID))
+
+			other := existingMicrovm(flintlocktypes.MicroVMStatus_CREATED)
+			other.Spec.Uid = pointer.String("FEDCBA654321")
+
+			fakeAPIClient := fakes.FakeClient{}
+			fakeAPIClient.GetMicroVMReturns(&flintlockv1.GetMicroVMResponse{Microvm: other}, nil)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			result, err := reconcileMicrovm(client, &fakeAPIClient)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected the microvm to be checked again")
+			g.Expect(fakeAPIClient.DeleteMicroVMCallCount()).To(BeZero(), "Expected a different microvm not to be deleted")
+			g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(BeZero())
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+			assertConditionFalse(g, reconciled, infrav1.MicrovmIdentityVerifiedCondition, infrav1.MicrovmIdentityMismatchReason)
+			assertConditionFalse(g, reconciled, infrav1.MicrovmReadyCondition, infrav1.MicrovmIdentityMismatchReason)
+			assertFinalizer(g, reconciled)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_RecordsProvisioningOutcome(t *testing.T) {
+	tt := []struct {
+		name     string
+		state    flintlocktypes.MicroVMStatus_MicroVMState
+		previous *microvm.VMState
+		expected []bool
+	}{
+		{
+			name:     "first time running is a success",
+			state:    flintlocktypes.MicroVMStatus_CREATED,
+			expected: []bool{true},
+		},
+		{
+			name:     "already running is not recorded again",
+			state:    flintlocktypes.MicroVMStatus_CREATED,
+			previous: &microvm.VMStateRunning,
+		},
+		{
+			name:     "first time failed is a failure",
+			state:    flintlocktypes.MicroVMStatus_FAILED,
+			previous: &microvm.VMStatePending,
+			expected: []bool{false},
+		},
+		{
+			name:  "pending is not recorded",
+			state: flintlocktypes.MicroVMStatus_PENDING,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Status.VMState = tc.previous
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, tc.state)
+
+			recorder := health.NewRecorder()
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			_, _ = reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{HealthRecorder: recorder})
+
+			recorded := []bool{}
+			for _, outcome := range recorder.Drain(testHostEndpoint) {
+				recorded = append(recorded, outcome.Succeeded)
+			}
+
+			g.Expect(recorded).To(ConsistOf(tc.expected))
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_ObservedGeneration(t *testing.T) {
+	tt := []struct {
+		name     string
+		state    flintlocktypes.MicroVMStatus_MicroVMState
+		expected int64
+	}{
+		{
+			name:     "set after a successful reconcile",
+			state:    flintlocktypes.MicroVMStatus_CREATED,
+			expected: 2,
+		},
+		{
+			name:     "left alone when reconcile fails",
+			state:    flintlocktypes.MicroVMStatus_FAILED,
+			expected: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Generation = 2
+			mvm.Status.ObservedGeneration = 1
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, tc.state)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			_, _ = reconcileMicrovm(client, &fakeAPIClient)
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+			g.Expect(reconciled.Status.ObservedGeneration).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_RecordsProvisioningPhases(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Spec.ProviderID = nil
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when creating a microvm should not error")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+	provisioning := reconciled.Status.Provisioning
+	g.Expect(provisioning).NotTo(BeNil())
+	g.Expect(provisioning.AcceptedAt).NotTo(BeNil())
+	g.Expect(provisioning.CreateSentAt).NotTo(BeNil())
+	g.Expect(provisioning.PendingAt).NotTo(BeNil())
+	g.Expect(provisioning.CreatedAt).To(BeNil())
+	g.Expect(provisioning.GuestReadyAt).To(BeNil())
+
+	accepted := *provisioning.AcceptedAt
+
+	withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+	_, err = reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when microvm is created should not error")
+
+	reconciled, err = getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+	provisioning = reconciled.Status.Provisioning
+	g.Expect(provisioning.AcceptedAt.Equal(&accepted)).To(BeTrue(), "Phases are only recorded once")
+	g.Expect(provisioning.CreatedAt).NotTo(BeNil())
+	g.Expect(provisioning.GuestReadyAt).NotTo(BeNil())
+}
+
+func TestMicrovm_ReconcileNormal_SpecDrift(t *testing.T) {
+	tt := []struct {
+		name           string
+		vcpu           int64
+		updateStrategy infrav1.UpdateStrategy
+		protected      bool
+		gateOff        bool
+		expected       func(*WithT, *infrav1.Microvm, *fakes.FakeClient)
+	}{
+		{
+			name: "unchanged spec is synced",
+			vcpu: 2,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionTrue(g, mvm, infrav1.MicrovmSpecSyncedCondition)
+				assertConditionTrue(g, mvm, infrav1.MicrovmReadyCondition)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+			},
+		},
+		{
+			name: "changed spec is only reported by default",
+			vcpu: 4,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmSpecSyncedCondition, infrav1.MicrovmSpecDriftedReason)
+				assertConditionTrue(g, mvm, infrav1.MicrovmReadyCondition)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+			},
+		},
+		{
+			name:           "changed spec is recreated with update strategy recreate",
+			vcpu:           4,
+			updateStrategy: infrav1.UpdateStrategyRecreate,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmSpecSyncedCondition, infrav1.MicrovmSpecDriftedReason)
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmRecreatingReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+			},
+		},
+		{
+			name:           "changed spec is only reported with in-place updates off",
+			vcpu:           4,
+			updateStrategy: infrav1.UpdateStrategyRecreate,
+			gateOff:        true,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmSpecSyncedCondition, infrav1.MicrovmSpecDriftedReason)
+				assertConditionTrue(g, mvm, infrav1.MicrovmReadyCondition)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+			},
+		},
+		{
+			name:           "changed spec is only reported when protected from deletion",
+			vcpu:           4,
+			updateStrategy: infrav1.UpdateStrategyRecreate,
+			protected:      true,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmSpecSyncedCondition, infrav1.MicrovmSpecDriftedReason)
+				assertConditionFalse(g, mvm, infrav1.MicrovmDeleteAllowedCondition, infrav1.MicrovmDeleteProtectedReason)
+				assertConditionTrue(g, mvm, infrav1.MicrovmReadyCondition)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Spec.VCPU = tc.vcpu
+			mvm.Spec.UpdateStrategy = tc.updateStrategy
+			if tc.protected {
+				mvm.Annotations = map[string]string{infrav1.DeleteProtectionAnnotation: "true"}
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{InPlaceUpdates: !tc.gateOff})
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a created microvm should not error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+			tc.expected(g, reconciled, &fakeAPIClient)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_Resize(t *testing.T) {
+	providerID := fmt.Sprintf("microvm://127.0.0.1:9090/%s", testMicrovmUID)
+
+	tt := []struct {
+		name        string
+		vcpu        int64
+		kernel      string
+		graceful    bool
+		requestedAt *metav1.Time
+		expected    func(*WithT, *infrav1.Microvm, *fakes.FakeClient, *fakeShutdownClient)
+	}{
+		{
+			name: "changed vcpu is recreated",
+			vcpu: 4,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient, _ *fakeShutdownClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmResizingReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+				g.Expect(mvm.Status.PreviousProviderID).To(Equal(providerID))
+			},
+		},
+		{
+			name:   "other changes are only reported",
+			vcpu:   2,
+			kernel: "docker.io/richardcase/ubuntu-bionic-kernel:0.0.12",
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient, _ *fakeShutdownClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmSpecSyncedCondition, infrav1.MicrovmSpecDriftedReason)
+				assertConditionTrue(g, mvm, infrav1.MicrovmReadyCondition)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+				g.Expect(mvm.Status.PreviousProviderID).To(BeEmpty())
+			},
+		},
+		{
+			name:     "guest is shut down before the vm is recreated",
+			vcpu:     4,
+			graceful: true,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient, sc *fakeShutdownClient) {
+				g.Expect(sc.endpoints).To(ConsistOf("http://10.0.0.10:8080"))
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmShuttingDownReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0), "Expected delete to wait for the grace period")
+			},
+		},
+		{
+			name:        "vm is recreated once the guest has had the grace period",
+			vcpu:        4,
+			graceful:    true,
+			requestedAt: &metav1.Time{Time: time.Now().Add(-2 * time.Minute)},
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient, sc *fakeShutdownClient) {
+				g.Expect(sc.endpoints).To(BeEmpty())
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+				g.Expect(mvm.Status.ShutdownRequestedAt).To(BeNil(), "Expected the next recreate to shut the guest down again")
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmResizingReason)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Spec.ProviderID = pointer.String(providerID)
+			mvm.Spec.VCPU = tc.vcpu
+			mvm.Spec.UpdateStrategy = infrav1.UpdateStrategyResize
+			mvm.Status.ShutdownRequestedAt = tc.requestedAt
+
+			if tc.kernel != "" {
+				mvm.Spec.Kernel.Image = tc.kernel
+			}
+
+			if tc.graceful {
+				mvm.Spec.NetworkInterfaces[0].Address = "10.0.0.10/24"
+				mvm.Spec.GracefulShutdown = &infrav1.GracefulShutdown{
+					AgentEndpoint:      "http://10.0.0.10:8080",
+					GracePeriodSeconds: 60,
+				}
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+			shutdownClient := &fakeShutdownClient{}
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{ShutdownClient: shutdownClient, InPlaceUpdates: true})
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a created microvm should not error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+			tc.expected(g, reconciled, &fakeAPIClient, shutdownClient)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_SSHKeyRotation(t *testing.T) {
+	applied := []microvm.SSHPublicKey{
+		{User: "root", AuthorizedKeys: []string{"ssh-ed25519 old"}},
+	}
+
+	tt := []struct {
+		name           string
+		keys           []microvm.SSHPublicKey
+		updateStrategy infrav1.UpdateStrategy
+		expected       func(*WithT, *infrav1.Microvm, *fakes.FakeClient)
+	}{
+		{
+			name: "unchanged keys are synced",
+			keys: applied,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionTrue(g, mvm, infrav1.MicrovmSSHKeysSyncedCondition)
+				assertConditionTrue(g, mvm, infrav1.MicrovmSpecSyncedCondition)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+			},
+		},
+		{
+			name: "changed keys are only reported by default",
+			keys: []microvm.SSHPublicKey{
+				{User: "root", AuthorizedKeys: []string{"ssh-ed25519 new"}},
+			},
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmSSHKeysSyncedCondition, infrav1.MicrovmSSHKeysOutdatedReason)
+				assertConditionFalse(g, mvm, infrav1.MicrovmSpecSyncedCondition, infrav1.MicrovmSpecDriftedReason)
+				g.Expect(conditions.GetMessage(mvm, infrav1.MicrovmSSHKeysSyncedCondition)).To(ContainSubstring("root"))
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+			},
+		},
+		{
+			name: "added users are rotated with update strategy recreate",
+			keys: append([]microvm.SSHPublicKey{
+				{User: "ubuntu", AuthorizedKeys: []string{"ssh-ed25519 new"}},
+			}, applied...),
+			updateStrategy: infrav1.UpdateStrategyRecreate,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmSSHKeysSyncedCondition, infrav1.MicrovmSSHKeysRotatingReason)
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmRecreatingReason)
+				g.Expect(conditions.GetMessage(mvm, infrav1.MicrovmSSHKeysSyncedCondition)).To(ContainSubstring("ubuntu"))
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+			},
+		},
+		{
+			name:           "removed keys are rotated with update strategy recreate",
+			updateStrategy: infrav1.UpdateStrategyRecreate,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmSSHKeysSyncedCondition, infrav1.MicrovmSSHKeysRotatingReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Spec.SSHPublicKeys = tc.keys
+			mvm.Spec.UpdateStrategy = tc.updateStrategy
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovmSSHKeys(g, &fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED, applied)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{InPlaceUpdates: true})
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a created microvm should not error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+			tc.expected(g, reconciled, &fakeAPIClient)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_LivenessProbe(t *testing.T) {
+	tt := []struct {
+		name          string
+		probeHost     string
+		probeErr      error
+		failures      int32
+		lastProbe     *metav1.Time
+		restartPolicy infrav1.RestartPolicy
+		expected      func(*WithT, *infrav1.Microvm, *fakeLivenessProber, *fakes.FakeClient)
+	}{
+		{
+			name: "passing probe marks the guest healthy and ready",
+			expected: func(g *WithT, mvm *infrav1.Microvm, prober *fakeLivenessProber, _ *fakes.FakeClient) {
+				g.Expect(prober.calls).To(Equal(1))
+				assertConditionTrue(g, mvm, infrav1.MicrovmGuestHealthyCondition)
+				g.Expect(mvm.Status.Provisioning.GuestReadyAt).NotTo(BeNil())
+			},
+		},
+		{
+			name:     "failing probe below the threshold is only counted",
+			probeErr: errors.New("connection refused"),
+			expected: func(g *WithT, mvm *infrav1.Microvm, _ *fakeLivenessProber, _ *fakes.FakeClient) {
+				g.Expect(mvm.Status.GuestHealth.ConsecutiveFailures).To(Equal(int32(1)))
+				g.Expect(conditions.Get(mvm, infrav1.MicrovmGuestHealthyCondition)).To(BeNil())
+				g.Expect(mvm.Status.Provisioning.GuestReadyAt).To(BeNil())
+			},
+		},
+		{
+			name:     "failing probe at the threshold marks the guest unhealthy",
+			probeErr: errors.New("connection refused"),
+			failures: 1,
+			expected: func(g *WithT, mvm *infrav1.Microvm, _ *fakeLivenessProber, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmGuestHealthyCondition, infrav1.MicrovmGuestUnhealthyReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+			},
+		},
+		{
+			name:          "unhealthy guest is recreated with restart policy always",
+			probeErr:      errors.New("connection refused"),
+			failures:      1,
+			restartPolicy: infrav1.RestartPolicyAlways,
+			expected: func(g *WithT, mvm *infrav1.Microvm, _ *fakeLivenessProber, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmGuestHealthyCondition, infrav1.MicrovmGuestUnhealthyReason)
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmRestartingReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+				g.Expect(mvm.Status.GuestHealth.Restarts).To(Equal(int32(1)))
+				g.Expect(mvm.Status.GuestHealth.ConsecutiveFailures).To(BeZero())
+			},
+		},
+		{
+			name:          "probe outside the guest marks it unhealthy without a restart",
+			probeHost:     "169.254.169.254",
+			restartPolicy: infrav1.RestartPolicyAlways,
+			expected: func(g *WithT, mvm *infrav1.Microvm, prober *fakeLivenessProber, fc *fakes.FakeClient) {
+				g.Expect(prober.calls).To(BeZero())
+				assertConditionFalse(g, mvm, infrav1.MicrovmGuestHealthyCondition, infrav1.MicrovmGuestUnhealthyReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+			},
+		},
+		{
+			name:      "guest is not probed again before the period is up",
+			lastProbe: &metav1.Time{Time: time.Now()},
+			expected: func(g *WithT, _ *infrav1.Microvm, prober *fakeLivenessProber, _ *fakes.FakeClient) {
+				g.Expect(prober.calls).To(BeZero())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			probeHost := tc.probeHost
+			if probeHost == "" {
+				probeHost = "10.0.0.10"
+			}
+
+			mvm := createMicrovm()
+			mvm.Spec.NetworkInterfaces[0].Address = "10.0.0.10/24"
+			mvm.Spec.RestartPolicy = tc.restartPolicy
+			mvm.Spec.LivenessProbe = &infrav1.LivenessProbe{
+				TCPSocket:        &infrav1.TCPSocketAction{Host: probeHost, Port: 22},
+				PeriodSeconds:    10,
+				FailureThreshold: 2,
+			}
+			mvm.Status.GuestHealth = &infrav1.GuestHealthStatus{
+				ConsecutiveFailures: tc.failures,
+				LastProbeTime:       tc.lastProbe,
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+			prober := &fakeLivenessProber{err: tc.probeErr}
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			result, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{Prober: prober})
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a probed microvm should not error")
+			g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect the guest to be probed again")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+			tc.expected(g, reconciled, prober, &fakeAPIClient)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_HostEndpointChanged(t *testing.T) {
+	tt := []struct {
+		name     string
+		existing bool
+		expected func(*WithT, *infrav1.Microvm, *fakes.FakeClient)
+	}{
+		{
+			name:     "microvm found at the new endpoint migrates the provider id",
+			existing: true,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				expectedProviderID := fmt.Sprintf("microvm://%s/%s", testHostEndpoint, testMicrovmUID)
+				g.Expect(mvm.Spec.ProviderID).To(Equal(pointer.String(expectedProviderID)))
+				g.Expect(fc.CreateMicroVMCallCount()).To(BeZero())
+				assertConditionTrue(g, mvm, infrav1.MicrovmReadyCondition)
+			},
+		},
+		{
+			name: "microvm missing at the new endpoint is not recreated",
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				expectedProviderID := fmt.Sprintf("microvm://10.0.0.1:9090/%s", testMicrovmUID)
+				g.Expect(mvm.Spec.ProviderID).To(Equal(pointer.String(expectedProviderID)))
+				g.Expect(fc.CreateMicroVMCallCount()).To(BeZero())
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmHostMigrationFailedReason)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Spec.ProviderID = pointer.String(fmt.Sprintf("microvm://10.0.0.1:9090/%s", testMicrovmUID))
+
+			fakeAPIClient := fakes.FakeClient{}
+			if tc.existing {
+				withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+			} else {
+				withMissingMicrovm(&fakeAPIClient)
+			}
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			_, err := reconcileMicrovm(client, &fakeAPIClient)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+			tc.expected(g, reconciled, &fakeAPIClient)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_HostVMIdentity(t *testing.T) {
+	tt := []struct {
+		name     string
+		owner    string
+		expected func(*WithT, *infrav1.Microvm, *fakes.FakeClient)
+	}{
+		{
+			name: "microvm is created with the identity from its spec",
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				g.Expect(fc.CreateMicroVMCallCount()).To(Equal(1))
+				_, createReq, _ := fc.CreateMicroVMArgsForCall(0)
+				g.Expect(createReq.Microvm.Namespace).To(Equal("cluster-a"))
+				g.Expect(createReq.Microvm.Id).To(Equal("web-0"))
+				g.Expect(createReq.Microvm.Labels).To(HaveKeyWithValue(infrav1.MicrovmNamespaceLabel, testNamespace))
+
+				_, listReq, _ := fc.ListMicroVMsArgsForCall(0)
+				g.Expect(listReq.Namespace).To(Equal("cluster-a"))
+				g.Expect(listReq.GetName()).To(Equal("web-0"))
+			},
+		},
+		{
+			name:  "microvm is not created over the vm of another microvm",
+			owner: "other-uid",
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				g.Expect(fc.CreateMicroVMCallCount()).To(BeZero())
+				g.Expect(mvm.Spec.ProviderID).To(BeNil())
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmHostVMNameConflictReason)
+			},
+		},
+		{
+			name:  "microvm adopts the vm it created before",
+			owner: "mvm-uid",
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				g.Expect(fc.CreateMicroVMCallCount()).To(BeZero())
+				expectedProviderID := fmt.Sprintf("microvm://%s/%s", testHostEndpoint, "existing-vm")
+				g.Expect(mvm.Spec.ProviderID).To(Equal(pointer.String(expectedProviderID)))
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.UID = "mvm-uid"
+			mvm.Spec.ProviderID = nil
+			mvm.Spec.HostVMNamespace = "cluster-a"
+			mvm.Spec.HostVMName = "web-0"
+
+			fakeAPIClient := fakes.FakeClient{}
+			withMissingMicrovm(&fakeAPIClient)
+			withCreateMicrovmSuccess(&fakeAPIClient)
+
+			if tc.owner != "" {
+				existing := existingMicrovm(flintlocktypes.MicroVMStatus_CREATED)
+				existing.Spec.Uid = pointer.String("existing-vm")
+				existing.Spec.Namespace = "cluster-a"
+				existing.Spec.Id = "web-0"
+				existing.Spec.Labels = map[string]string{infrav1.MicrovmUIDLabel: tc.owner}
+
+				fakeAPIClient.ListMicroVMsReturns(&flintlockv1.ListMicroVMsResponse{
+					Microvm: []*flintlocktypes.MicroVM{existing},
+				}, nil)
+			}
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			_, err := reconcileMicrovm(client, &fakeAPIClient)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+			tc.expected(g, reconciled, &fakeAPIClient)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_SSHService(t *testing.T) {
+	tt := []struct {
+		name       string
+		enabled    bool
+		sshService *infrav1.SSHService
+		address    string
+		existing   string
+		expected   func(*WithT, client.Client, *infrav1.Microvm)
+	}{
+		{
+			name:       "service points at the guest address once it is created",
+			enabled:    true,
+			sshService: &infrav1.SSHService{},
+			address:    "10.0.0.5/24",
+			expected: func(g *WithT, c client.Client, mvm *infrav1.Microvm) {
+				name := testMicrovmName + "-ssh"
+				g.Expect(mvm.Status.SSHServiceName).To(Equal(name))
+				g.Expect(mvm.Status.ExternalResources).To(ConsistOf(
+					infrav1.ExternalResourceRef{Kind: external.KindService, Name: name},
+				), "Expected the service to be released with the microvm")
+
+				svc := &corev1.Service{}
+				g.Expect(c.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: name}, svc)).To(Succeed())
+				g.Expect(svc.Spec.Ports[0].Port).To(BeEquivalentTo(22), "Expected the port to default to 22")
+
+				slice := &discoveryv1.EndpointSlice{}
+				g.Expect(c.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: name}, slice)).To(Succeed())
+				g.Expect(slice.Endpoints[0].Addresses).To(ConsistOf("10.0.0.5"))
+			},
+		},
+		{
+			name:       "service is not created while the feature gate is disabled",
+			sshService: &infrav1.SSHService{},
+			address:    "10.0.0.5/24",
+			expected: func(g *WithT, c client.Client, mvm *infrav1.Microvm) {
+				g.Expect(mvm.Status.SSHServiceName).To(BeEmpty())
+
+				err := c.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: testMicrovmName + "-ssh"}, &corev1.Service{})
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected no service")
+			},
+		},
+		{
+			name:       "service is not created for a guest using dhcp",
+			enabled:    true,
+			sshService: &infrav1.SSHService{},
+			expected: func(g *WithT, c client.Client, mvm *infrav1.Microvm) {
+				g.Expect(mvm.Status.SSHServiceName).To(BeEmpty())
+				g.Expect(mvm.Status.ExternalResources).To(BeEmpty())
+			},
+		},
+		{
+			name:     "service is deleted once it is no longer asked for",
+			enabled:  true,
+			address:  "10.0.0.5/24",
+			existing: "existing-ssh",
+			expected: func(g *WithT, c client.Client, mvm *infrav1.Microvm) {
+				g.Expect(mvm.Status.SSHServiceName).To(BeEmpty())
+				g.Expect(mvm.Status.ExternalResources).To(BeEmpty(), "Expected the service to no longer be tracked")
+
+				err := c.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: "existing-ssh"}, &corev1.Service{})
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the service to be deleted")
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.UID = "mvm-uid"
+			mvm.Spec.SSHService = tc.sshService
+			mvm.Spec.NetworkInterfaces[0].Address = tc.address
+
+			objects := asRuntimeObject(mvm)
+
+			if tc.existing != "" {
+				mvm.Status.SSHServiceName = tc.existing
+				mvm.Status.ExternalResources = []infrav1.ExternalResourceRef{{Kind: external.KindService, Name: tc.existing}}
+				objects = append(objects, createMicrovmService(tc.existing, "mvm-uid"))
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+			client := createFakeClient(g, objects)
+
+			_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{SSHServices: tc.enabled})
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a created microvm should not return error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+			tc.expected(g, client, reconciled)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_GuestAgent(t *testing.T) {
+	tt := []struct {
+		name     string
+		agent    *fakeGuestAgent
+		updated  *metav1.Time
+		expected func(*WithT, *infrav1.Microvm, *fakeGuestAgent, ctrl.Result)
+	}{
+		{
+			name:  "guest info is recorded",
+			agent: &fakeGuestAgent{info: guestagent.Info{Hostname: "mvm1", CloudInit: guestagent.CloudInit{Status: "running"}}},
+			expected: func(g *WithT, mvm *infrav1.Microvm, agent *fakeGuestAgent, result ctrl.Result) {
+				g.Expect(agent.calls).To(Equal(1))
+				g.Expect(mvm.Status.GuestInfo.Hostname).To(Equal("mvm1"))
+				g.Expect(mvm.Status.GuestInfo.CloudInit).To(Equal(infrav1.CloudInitRunning))
+				assertConditionTrue(g, mvm, infrav1.MicrovmGuestAgentReachableCondition)
+				g.Expect(result.RequeueAfter).To(Equal(time.Minute), "Expected a requeue when the guest is next due")
+			},
+		},
+		{
+			name:    "guest is not asked about before it is due",
+			agent:   &fakeGuestAgent{},
+			updated: &metav1.Time{Time: time.Now()},
+			expected: func(g *WithT, mvm *infrav1.Microvm, agent *fakeGuestAgent, result ctrl.Result) {
+				g.Expect(agent.calls).To(Equal(0))
+				g.Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+			},
+		},
+		{
+			name:    "unreachable agent is reported and last guest info is kept",
+			agent:   &fakeGuestAgent{err: errors.New("connection refused")},
+			updated: &metav1.Time{Time: time.Now().Add(-time.Hour)},
+			expected: func(g *WithT, mvm *infrav1.Microvm, agent *fakeGuestAgent, _ ctrl.Result) {
+				g.Expect(agent.calls).To(Equal(1))
+				g.Expect(mvm.Status.GuestInfo).NotTo(BeNil())
+				assertConditionFalse(g, mvm, infrav1.MicrovmGuestAgentReachableCondition, infrav1.MicrovmGuestAgentUnreachableReason)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Spec.GuestAgent = &infrav1.GuestAgent{Endpoint: "http://10.0.0.10:8080"}
+
+			if tc.updated != nil {
+				mvm.Status.GuestInfo = &infrav1.GuestInfo{LastUpdateTime: tc.updated}
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+
+			result, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{GuestAgent: tc.agent})
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a created microvm should not return error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+			tc.expected(g, reconciled, tc.agent, result)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_ReadinessGate(t *testing.T) {
+	tt := []struct {
+		name      string
+		gate      infrav1.ReadinessGate
+		agent     *fakeGuestAgent
+		cloudInit string
+		expected  func(*WithT, *infrav1.Microvm, ctrl.Result)
+	}{
+		{
+			name:      "without a gate the microvm is ready once created",
+			agent:     &fakeGuestAgent{},
+			cloudInit: "running",
+			expected: func(g *WithT, mvm *infrav1.Microvm, _ ctrl.Result) {
+				assertMicrovmReconciled(g, mvm)
+			},
+		},
+		{
+			name:      "microvm waits while cloud-init is running",
+			gate:      infrav1.ReadinessGateCloudInit,
+			agent:     &fakeGuestAgent{},
+			cloudInit: "running",
+			expected: func(g *WithT, mvm *infrav1.Microvm, result ctrl.Result) {
+				g.Expect(mvm.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmWaitingForCloudInitReason)
+				g.Expect(mvm.Status.Provisioning.CreatedAt).NotTo(BeNil(), "Expected the vm to be recorded as created")
+				g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected a requeue to ask the guest again")
+			},
+		},
+		{
+			name:      "microvm is ready once cloud-init is done",
+			gate:      infrav1.ReadinessGateCloudInit,
+			agent:     &fakeGuestAgent{},
+			cloudInit: "done",
+			expected: func(g *WithT, mvm *infrav1.Microvm, _ ctrl.Result) {
+				assertMicrovmReconciled(g, mvm)
+			},
+		},
+		{
+			name:      "microvm is not ready when cloud-init failed",
+			gate:      infrav1.ReadinessGateCloudInit,
+			agent:     &fakeGuestAgent{},
+			cloudInit: "error",
+			expected: func(g *WithT, mvm *infrav1.Microvm, _ ctrl.Result) {
+				g.Expect(mvm.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmCloudInitFailedReason)
+			},
+		},
+		{
+			name: "microvm waits without a guest agent to confirm cloud-init",
+			gate: infrav1.ReadinessGateCloudInit,
+			expected: func(g *WithT, mvm *infrav1.Microvm, _ ctrl.Result) {
+				g.Expect(mvm.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmWaitingForCloudInitReason)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Spec.ReadinessGate = tc.gate
+
+			var agent guestagent.Client
+
+			if tc.agent != nil {
+				mvm.Spec.GuestAgent = &infrav1.GuestAgent{Endpoint: "http://10.0.0.10:8080"}
+				tc.agent.info.CloudInit.Status = tc.cloudInit
+				agent = tc.agent
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+
+			result, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{GuestAgent: agent})
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a created microvm should not return error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+
+			tc.expected(g, reconciled, result)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_PhoneHomeTokenIssued(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Spec.ProviderID = nil
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+
+	_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{PhoneHomeURL: "https://10.0.0.1:9445"})
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when creating microvm should not return error")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(reconciled.Status.PhoneHome).NotTo(BeNil())
+	g.Expect(reconciled.Status.PhoneHome.TokenHash).NotTo(BeEmpty(), "Expected the hash of the token to be recorded")
+
+	_, createReq, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
+	vendorData, err := base64.StdEncoding.DecodeString(createReq.Microvm.Metadata["vendor-data"])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(vendorData)).To(ContainSubstring("https://10.0.0.1:9445/phone-home/%s/%s/", testNamespace, testMicrovmName))
+	g.Expect(string(vendorData)).NotTo(ContainSubstring(reconciled.Status.PhoneHome.TokenHash),
+		"Expected the guest to be given the token rather than its hash")
+}
+
+func TestMicrovm_ReconcileNormal_PhoneHomeConsumed(t *testing.T) {
+	tt := []struct {
+		name      string
+		tokenHash string
+		expected  func(*WithT, *infrav1.Microvm)
+	}{
+		{
+			name:      "callback with the token of the vm makes the microvm ready",
+			tokenHash: "current",
+			expected: func(g *WithT, mvm *infrav1.Microvm) {
+				assertMicrovmReconciled(g, mvm)
+				g.Expect(mvm.Status.PhoneHome.CalledAt).NotTo(BeNil())
+				g.Expect(mvm.Status.PhoneHome.Hostname).To(Equal("mvm1"))
+				g.Expect(mvm.Status.PhoneHome.TokenHash).To(BeEmpty(), "Expected the token to be used up")
+			},
+		},
+		{
+			name:      "callback of an earlier vm is discarded",
+			tokenHash: "earlier",
+			expected: func(g *WithT, mvm *infrav1.Microvm) {
+				g.Expect(mvm.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmWaitingForCloudInitReason)
+				g.Expect(mvm.Status.PhoneHome.CalledAt).To(BeNil())
+				g.Expect(mvm.Status.PhoneHome.TokenHash).To(Equal("current"), "Expected the vm to still be waited for")
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Spec.ReadinessGate = infrav1.ReadinessGateCloudInit
+			mvm.Status.PhoneHome = &infrav1.PhoneHomeStatus{TokenHash: "current"}
+			mvm.Annotations = map[string]string{
+				phonehome.CallbackAnnotation: fmt.Sprintf(`{"tokenHash":%q,"calledAt":"2022-10-18T10:00:00Z","hostname":"mvm1"}`, tc.tokenHash),
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+
+			_, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{PhoneHomeURL: "https://10.0.0.1:9445"})
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a created microvm should not return error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+			g.Expect(reconciled.Annotations).NotTo(HaveKey(phonehome.CallbackAnnotation), "Expected the callback to be consumed")
+
+			tc.expected(g, reconciled)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_RestartedAt(t *testing.T) {
+	tt := []struct {
+		name        string
+		restartedAt string
+		protected   bool
+		expected    func(*WithT, *infrav1.Microvm, *fakes.FakeClient)
+	}{
+		{
+			name:        "microvm is left alone when it was created after the restart",
+			restartedAt: "t1",
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionTrue(g, mvm, infrav1.MicrovmReadyCondition)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+			},
+		},
+		{
+			name:        "microvm is recreated when a restart is requested",
+			restartedAt: "t2",
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmReadyCondition, infrav1.MicrovmRestartingReason)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(1))
+				g.Expect(mvm.Status.PreviousProviderID).NotTo(BeEmpty(), "Expected the replaced vm to be recorded")
+				g.Expect(mvm.Status.RestartedAt).To(Equal("t1"), "Expected the restart to be recorded only once the vm is created again")
+			},
+		},
+		{
+			name:        "microvm protected from deletion refuses the restart",
+			restartedAt: "t2",
+			protected:   true,
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				assertConditionFalse(g, mvm, infrav1.MicrovmDeleteAllowedCondition, infrav1.MicrovmDeleteProtectedReason)
+				assertConditionTrue(g, mvm, infrav1.MicrovmReadyCondition)
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0))
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Annotations = map[string]string{infrav1.RestartedAtAnnotation: tc.restartedAt}
+			mvm.Status.RestartedAt = "t1"
+			if tc.protected {
+				mvm.Annotations[infrav1.DeleteProtectionAnnotation] = "true"
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			_, err := reconcileMicrovm(client, &fakeAPIClient)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a created microvm should not error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+			tc.expected(g, reconciled, &fakeAPIClient)
+		})
+	}
+}
+
+func TestMicrovm_ReconcileNormal_CreateRecordsRestartedAt(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Spec.ProviderID = nil
+	mvm.Annotations = map[string]string{infrav1.RestartedAtAnnotation: "t1"}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withMissingMicrovm(&fakeAPIClient)
+	withCreateMicrovmSuccess(&fakeAPIClient)
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when creating microvm should not return error")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(reconciled.Status.RestartedAt).To(Equal("t1"), "Expected a new vm not to be restarted again")
+}
+
+func TestMicrovm_Reconcile_DryRun(t *testing.T) {
+	tt := []struct {
+		name     string
+		existing bool
+		setup    func(*infrav1.Microvm)
+		expected func(*WithT, *infrav1.Microvm, *fakes.FakeClient)
+	}{
+		{
+			name: "missing vm is planned to be created",
+			setup: func(mvm *infrav1.Microvm) {
+				mvm.Spec.ProviderID = nil
+			},
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				g.Expect(fc.CreateMicroVMCallCount()).To(Equal(0), "Expected no vm to be created in dry-run mode")
+				g.Expect(mvm.Status.DryRun).NotTo(BeNil())
+				g.Expect(mvm.Status.DryRun.Actions).To(ConsistOf(
+					HaveField("Verb", "CreateMicroVM"),
+				))
+				g.Expect(mvm.Spec.ProviderID).To(BeNil())
+			},
+		},
+		{
+			name:     "restart is planned as a delete and create",
+			existing: true,
+			setup: func(mvm *infrav1.Microvm) {
+				mvm.Annotations[infrav1.RestartedAtAnnotation] = "t1"
+			},
+			expected: func(g *WithT, mvm *infrav1.Microvm, fc *fakes.FakeClient) {
+				g.Expect(fc.DeleteMicroVMCallCount()).To(Equal(0), "Expected no vm to be deleted in dry-run mode")
+				g.Expect(mvm.Status.DryRun.Actions).To(Equal([]infrav1.PlannedAction{
+					{Verb: "DeleteMicroVM", Target: *mvm.Spec.ProviderID, Reason: "a restart was requested"},
+					{Verb: "CreateMicroVM", Target: "VM on " + mvm.Spec.Host.Endpoint, Reason: "a restart was requested"},
+				}))
+				g.Expect(mvm.Status.RestartedAt).To(BeEmpty())
+			},
+		},
+		{
+			name:     "vm which is up to date has nothing planned",
+			existing: true,
+			expected: func(g *WithT, mvm *infrav1.Microvm, _ *fakes.FakeClient) {
+				g.Expect(mvm.Status.DryRun).NotTo(BeNil(), "Expected the dry run to be recorded")
+				g.Expect(mvm.Status.DryRun.Actions).To(BeEmpty())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Annotations = map[string]string{infrav1.DryRunAnnotation: "true"}
+			if tc.setup != nil {
+				tc.setup(mvm)
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			if tc.existing {
+				withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+			} else {
+				withMissingMicrovm(&fakeAPIClient)
+			}
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			_, err := reconcileMicrovm(client, &fakeAPIClient)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a microvm in dry-run mode should not error")
+
+			reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+			tc.expected(g, reconciled, &fakeAPIClient)
+		})
+	}
+}
+
+func TestMicrovm_Reconcile_DryRunCleared(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.Status.DryRun = &infrav1.DryRunStatus{Actions: []infrav1.PlannedAction{{Verb: "CreateMicroVM", Target: "VM on 127.0.0.1:9090"}}}
+
+	fakeAPIClient := fakes.FakeClient{}
+	withExistingMicrovm(&fakeAPIClient, flintlocktypes.MicroVMStatus_CREATED)
+
+	client := createFakeClient(g, asRuntimeObject(mvm))
+	_, err := reconcileMicrovm(client, &fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling a created microvm should not error")
+
+	reconciled, err := getMicrovm(client, testMicrovmName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(reconciled.Status.DryRun).To(BeNil(), "Expected the dry run to be cleared once it is over")
+}
+
+func TestMicrovm_Reconcile_ReconcileIntervals(t *testing.T) {
+	tt := []struct {
+		name        string
+		state       flintlocktypes.MicroVMStatus_MicroVMState
+		deleting    bool
+		intervals   *infrav1.ReconcileIntervals
+		minInterval time.Duration
+		expected    time.Duration
+	}{
+		{
+			name:     "pending polls at the requeue period by default",
+			state:    flintlocktypes.MicroVMStatus_PENDING,
+			expected: 30 * time.Second,
+		},
+		{
+			name:        "pending polls at the pending poll",
+			state:       flintlocktypes.MicroVMStatus_PENDING,
+			intervals:   &infrav1.ReconcileIntervals{PendingPollSeconds: 5, DeletingPollSeconds: 2},
+			minInterval: time.Second,
+			expected:    5 * time.Second,
+		},
+		{
+			name:      "pending poll is raised to the requeue period by default",
+			state:     flintlocktypes.MicroVMStatus_PENDING,
+			intervals: &infrav1.ReconcileIntervals{PendingPollSeconds: 1},
+			expected:  30 * time.Second,
+		},
+		{
+			name:     "created is not resynced by default",
+			state:    flintlocktypes.MicroVMStatus_CREATED,
+			expected: 0,
+		},
+		{
+			name:      "created is resynced at the running resync",
+			state:     flintlocktypes.MicroVMStatus_CREATED,
+			intervals: &infrav1.ReconcileIntervals{PendingPollSeconds: 5, RunningResyncSeconds: 600},
+			expected:  10 * time.Minute,
+		},
+		{
+			name:        "running resync is raised to the minimum interval",
+			state:       flintlocktypes.MicroVMStatus_CREATED,
+			intervals:   &infrav1.ReconcileIntervals{RunningResyncSeconds: 1},
+			minInterval: 10 * time.Second,
+			expected:    10 * time.Second,
+		},
+		{
+			name:        "deleting on the host polls at the deleting poll",
+			state:       flintlocktypes.MicroVMStatus_DELETING,
+			intervals:   &infrav1.ReconcileIntervals{PendingPollSeconds: 5, DeletingPollSeconds: 2},
+			minInterval: time.Second,
+			expected:    2 * time.Second,
+		},
+		{
+			name:        "deleted microvm polls at the deleting poll",
+			state:       flintlocktypes.MicroVMStatus_CREATED,
+			deleting:    true,
+			intervals:   &infrav1.ReconcileIntervals{RunningResyncSeconds: 600, DeletingPollSeconds: 2},
+			minInterval: time.Second,
+			expected:    2 * time.Second,
+		},
+		{
+			name:      "deleting poll is raised to the requeue period by default",
+			state:     flintlocktypes.MicroVMStatus_DELETING,
+			intervals: &infrav1.ReconcileIntervals{DeletingPollSeconds: 2},
+			expected:  30 * time.Second,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Spec.ReconcileIntervals = tc.intervals
+			if tc.deleting {
+				mvm.Finalizers = []string{infrav1.MvmFinalizer}
+				mvm.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			}
+
+			fakeAPIClient := fakes.FakeClient{}
+			withExistingMicrovm(&fakeAPIClient, tc.state)
+
+			cfg := config.NewStore(&configv1.OperatorConfiguration{
+				Controllers: configv1.ControllersConfiguration{
+					Microvm: configv1.ControllerConfiguration{
+						MinReconcileInterval: metav1.Duration{Duration: tc.minInterval},
+					},
+				},
+			})
+
+			client := createFakeClient(g, asRuntimeObject(mvm))
+			result, err := reconcileMicrovmWith(client, &fakeAPIClient, &controllers.MicrovmReconciler{Config: cfg})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.RequeueAfter).To(Equal(tc.expected))
+		})
+	}
+}
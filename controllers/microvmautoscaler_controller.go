@@ -0,0 +1,367 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/autoscaler"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+// MicrovmAutoscalerReconciler reconciles a MicrovmAutoscaler object
+type MicrovmAutoscalerReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// SourceFunc builds the client used to read Prometheus metrics.
+	SourceFunc autoscaler.SourceFunc
+
+	recommender *autoscaler.Recommender
+}
+
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmautoscalers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmautoscalers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmdeployments,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhostgroups,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhosts,verbs=get;list;watch
+
+func (r *MicrovmAutoscalerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	mvmA := &infrav1.MicrovmAutoscaler{}
+	if err := r.Get(ctx, req.NamespacedName, mvmA); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.getRecommender().Forget(req.NamespacedName)
+
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "error getting microvmautoscaler")
+
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
+	}
+
+	mvmAutoscalerScope, err := scope.NewMicrovmAutoscalerScope(scope.MicrovmAutoscalerScopeParams{
+		MicrovmAutoscaler: mvmA,
+		Client:            r.Client,
+		Context:           ctx,
+		Logger:            log,
+	})
+	if err != nil {
+		log.Error(err, "failed to create mvm-autoscaler scope")
+
+		return ctrl.Result{}, fmt.Errorf("failed to create mvm-autoscaler scope: %w", err)
+	}
+
+	defer func() {
+		if err := mvmAutoscalerScope.Patch(); err != nil {
+			log.Error(err, "failed to patch microvmautoscaler")
+		}
+	}()
+
+	if !mvmA.ObjectMeta.DeletionTimestamp.IsZero() {
+		// nothing is owned by the autoscaler, so there is nothing to clean up
+		r.getRecommender().Forget(req.NamespacedName)
+
+		return ctrl.Result{}, nil
+	}
+
+	return r.reconcileNormal(ctx, mvmAutoscalerScope)
+}
+
+func (r *MicrovmAutoscalerReconciler) reconcileNormal(
+	ctx context.Context,
+	mvmAutoscalerScope *scope.MicrovmAutoscalerScope,
+) (reconcile.Result, error) {
+	mvmAutoscalerScope.V(logging.DebugLevel).Info("Reconciling MicrovmAutoscaler update")
+
+	mvmA := mvmAutoscalerScope.MicrovmAutoscaler
+
+	target := &infrav1.MicrovmDeployment{}
+	key := client.ObjectKey{Name: mvmAutoscalerScope.TargetName(), Namespace: mvmAutoscalerScope.Namespace()}
+
+	if err := r.Get(ctx, key, target); err != nil {
+		if apierrors.IsNotFound(err) {
+			mvmAutoscalerScope.SetNotActive(
+				infrav1.MicrovmAutoscalerTargetNotFoundReason,
+				clusterv1.ConditionSeverityWarning,
+				"microvmdeployment %s not found", key.Name,
+			)
+
+			return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+		}
+
+		mvmAutoscalerScope.Error(err, "failed getting scale target")
+
+		return ctrl.Result{}, fmt.Errorf("failed to get microvmdeployment: %w", err)
+	}
+
+	current := int32(1)
+	if target.Spec.Replicas != nil {
+		current = *target.Spec.Replicas
+	}
+
+	value, recommended, err := r.recommend(ctx, mvmA, target, current)
+	if err != nil {
+		mvmAutoscalerScope.Error(err, "failed reading metric")
+		mvmAutoscalerScope.SetNotActive(
+			infrav1.MicrovmAutoscalerMetricUnavailableReason,
+			clusterv1.ConditionSeverityWarning,
+			err.Error(),
+		)
+
+		return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+	}
+
+	now := time.Now()
+	keep := autoscaler.MaxPeriod(mvmA.Spec.Behavior)
+	history := r.getRecommender().Record(
+		types.NamespacedName{Name: mvmA.Name, Namespace: mvmA.Namespace},
+		autoscaler.Recommendation{Time: now, Replicas: recommended},
+		keep,
+	)
+
+	desired := autoscaler.Normalize(
+		mvmA.Spec.Behavior,
+		current,
+		mvmAutoscalerScope.MinReplicas(),
+		mvmAutoscalerScope.MaxReplicas(),
+		history,
+		mvmA.Status.ScaleEvents,
+		now,
+	)
+
+	mvmA.Status.CurrentReplicas = current
+	mvmA.Status.DesiredReplicas = desired
+	mvmA.Status.CurrentMetricValue = resource.NewMilliQuantity(int64(value*1000), resource.DecimalSI) //nolint: gomnd // milli
+	mvmA.Status.ScaleEvents = pruneScaleEvents(mvmA.Status.ScaleEvents, now.Add(-keep))
+
+	if desired != current {
+		mvmAutoscalerScope.Info("scaling microvmdeployment", "target", target.Name, "from", current, "to", desired)
+
+		base := target.DeepCopy()
+		target.Spec.Replicas = &desired
+
+		if err := r.Patch(ctx, target, client.MergeFrom(base), apply.FieldOwner); err != nil {
+			mvmAutoscalerScope.Error(err, "failed scaling microvmdeployment")
+			mvmAutoscalerScope.SetNotActive(
+				infrav1.MicrovmAutoscalerScaleFailedReason,
+				clusterv1.ConditionSeverityError,
+				"",
+			)
+
+			return ctrl.Result{}, fmt.Errorf("failed to scale microvmdeployment: %w", err)
+		}
+
+		scaleTime := metav1.NewTime(now)
+		mvmA.Status.LastScaleTime = &scaleTime
+		mvmA.Status.CurrentReplicas = desired
+		mvmA.Status.ScaleEvents = append(mvmA.Status.ScaleEvents, infrav1.ScaleEvent{
+			Time: scaleTime,
+			From: current,
+			To:   desired,
+		})
+	}
+
+	mvmAutoscalerScope.SetActive()
+
+	return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+}
+
+// recommend reads the configured metric and returns its value along with the
+// unbounded number of replicas it suggests.
+func (r *MicrovmAutoscalerReconciler) recommend(
+	ctx context.Context,
+	mvmA *infrav1.MicrovmAutoscaler,
+	target *infrav1.MicrovmDeployment,
+	current int32,
+) (float64, int32, error) {
+	metric := mvmA.Spec.Metric
+
+	switch metric.Type {
+	case infrav1.PrometheusMetricSourceType:
+		if metric.Prometheus == nil {
+			return 0, 0, errMetricSourceRequired
+		}
+
+		if r.SourceFunc == nil {
+			return 0, 0, errMetricSourceFuncRequired
+		}
+
+		value, err := r.SourceFunc(metric.Prometheus.Address, metric.Prometheus.Query).Value(ctx)
+		if err != nil {
+			return 0, 0, fmt.Errorf("reading prometheus metric: %w", err)
+		}
+
+		return value, autoscaler.ProportionalReplicas(current, value, metric.Prometheus.TargetValue.AsApproximateFloat64()), nil
+	case infrav1.DensityMetricSourceType:
+		if metric.Density == nil {
+			return 0, 0, errMetricSourceRequired
+		}
+
+		targetHosts, err := r.targetHosts(ctx, target)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		density, err := r.hostDensity(ctx, target, targetHosts)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		// a spread deployment shares its replicas between hosts, so each host
+		// needs as many new replicas as an unspread deployment would
+		hosts := int32(1)
+		if target.Spec.SpreadConstraints != nil {
+			hosts = int32(len(targetHosts))
+		}
+
+		return density, autoscaler.DensityReplicas(current, density, metric.Density.TargetPerHost, hosts), nil
+	default:
+		return 0, 0, fmt.Errorf("%w: %s", errMetricSourceRequired, metric.Type)
+	}
+}
+
+// targetHosts returns the hosts of the target: the members of its host group
+// when it uses one, the MicrovmHosts matched by its host selector when it has
+// one, otherwise the hosts on its spec.
+func (r *MicrovmAutoscalerReconciler) targetHosts(
+	ctx context.Context,
+	target *infrav1.MicrovmDeployment,
+) ([]microvm.Host, error) {
+	ref := target.Spec.HostGroupRef
+	if ref == nil && target.Spec.HostSelector != nil {
+		return r.selectedHosts(ctx, target.Spec.HostSelector)
+	}
+
+	if ref == nil {
+		return target.Spec.Hosts, nil
+	}
+
+	group := &infrav1.MicrovmHostGroup{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: target.Namespace, Name: ref.Name}, group); err != nil {
+		return nil, fmt.Errorf("getting microvmhostgroup %s: %w", ref.Name, err)
+	}
+
+	return group.Status.Hosts, nil
+}
+
+// selectedHosts returns the MicrovmHosts matched by selector.
+func (r *MicrovmAutoscalerReconciler) selectedHosts(
+	ctx context.Context,
+	hostSelector *metav1.LabelSelector,
+) ([]microvm.Host, error) {
+	selector, err := metav1.LabelSelectorAsSelector(hostSelector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing host selector: %w", err)
+	}
+
+	list := &infrav1.MicrovmHostList{}
+	if err := r.List(ctx, list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("listing microvmhosts: %w", err)
+	}
+
+	hosts := make([]microvm.Host, 0, len(list.Items))
+	for _, host := range list.Items {
+		hosts = append(hosts, microvm.Host{Name: host.Name, Endpoint: host.Spec.Endpoint})
+	}
+
+	return hosts, nil
+}
+
+// hostDensity returns the average number of microvms, from any owner, on each of
+// the given hosts of the target.
+func (r *MicrovmAutoscalerReconciler) hostDensity(
+	ctx context.Context,
+	target *infrav1.MicrovmDeployment,
+	targetHosts []microvm.Host,
+) (float64, error) {
+	if len(targetHosts) == 0 {
+		return 0, nil
+	}
+
+	hosts := map[string]bool{}
+	for _, host := range targetHosts {
+		hosts[host.Endpoint] = true
+	}
+
+	mvmList := &infrav1.MicrovmList{}
+	if err := r.List(ctx, mvmList, client.InNamespace(target.Namespace)); err != nil {
+		return 0, fmt.Errorf("failed to list microvms: %w", err)
+	}
+
+	count := 0
+
+	for _, mvm := range mvmList.Items {
+		if hosts[mvm.Spec.Host.Endpoint] {
+			count++
+		}
+	}
+
+	return float64(count) / float64(len(hosts)), nil
+}
+
+func (r *MicrovmAutoscalerReconciler) getRecommender() *autoscaler.Recommender {
+	if r.recommender == nil {
+		r.recommender = autoscaler.NewRecommender()
+	}
+
+	return r.recommender
+}
+
+func pruneScaleEvents(events []infrav1.ScaleEvent, since time.Time) []infrav1.ScaleEvent {
+	retained := []infrav1.ScaleEvent{}
+
+	for _, event := range events {
+		if !event.Time.Time.Before(since) {
+			retained = append(retained, event)
+		}
+	}
+
+	return retained
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MicrovmAutoscalerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recommender = autoscaler.NewRecommender()
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.MicrovmAutoscaler{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvmautoscaler", logging.AutoscalerKey)).
+		Complete(tracing.Reconciler("microvmautoscaler", r))
+}
@@ -0,0 +1,143 @@
+package controllers_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+func TestMicrovmAutoscaler_ReconcileNormal_TargetNotFound(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmA := createMicrovmAutoscaler(1, 5, infrav1.MetricSource{
+		Type:    infrav1.DensityMetricSourceType,
+		Density: &infrav1.DensityMetricSource{TargetPerHost: 4},
+	})
+	objects := []runtime.Object{mvmA}
+	client := createFakeClient(g, objects)
+
+	result, err := reconcileMicrovmAutoscaler(client, nil)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when the target doesn't exist should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect a requeue to be requested")
+
+	reconciled, err := getMicrovmAutoscaler(client, testMicrovmAutoscalerName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmAutoscalerScalingActiveCondition, infrav1.MicrovmAutoscalerTargetNotFoundReason)
+}
+
+func TestMicrovmAutoscaler_ReconcileNormal_PrometheusScalesUp(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(2, 1)
+	mvmA := createMicrovmAutoscaler(1, 5, infrav1.MetricSource{
+		Type: infrav1.PrometheusMetricSourceType,
+		Prometheus: &infrav1.PrometheusMetricSource{
+			Address:     "http://prometheus:9090",
+			Query:       "load",
+			TargetValue: resource.MustParse("1"),
+		},
+	})
+	objects := []runtime.Object{mvmD, mvmA}
+	client := createFakeClient(g, objects)
+
+	// a value of twice the target should double the replicas
+	_, err := reconcileMicrovmAutoscaler(client, withMetricValue(2, nil))
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling autoscaler should not error")
+
+	reconciledD, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*reconciledD.Spec.Replicas).To(Equal(int32(4)))
+
+	reconciled, err := getMicrovmAutoscaler(client, testMicrovmAutoscalerName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionTrue(g, reconciled, infrav1.MicrovmAutoscalerScalingActiveCondition)
+	g.Expect(reconciled.Status.DesiredReplicas).To(Equal(int32(4)))
+	g.Expect(reconciled.Status.LastScaleTime).NotTo(BeNil())
+	g.Expect(reconciled.Status.ScaleEvents).To(HaveLen(1))
+}
+
+func TestMicrovmAutoscaler_ReconcileNormal_RespectsMaxReplicas(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(2, 1)
+	mvmA := createMicrovmAutoscaler(1, 3, infrav1.MetricSource{
+		Type: infrav1.PrometheusMetricSourceType,
+		Prometheus: &infrav1.PrometheusMetricSource{
+			Address:     "http://prometheus:9090",
+			Query:       "load",
+			TargetValue: resource.MustParse("1"),
+		},
+	})
+	objects := []runtime.Object{mvmD, mvmA}
+	client := createFakeClient(g, objects)
+
+	_, err := reconcileMicrovmAutoscaler(client, withMetricValue(10, nil))
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling autoscaler should not error")
+
+	reconciledD, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*reconciledD.Spec.Replicas).To(Equal(int32(3)))
+}
+
+func TestMicrovmAutoscaler_ReconcileNormal_MetricUnavailable(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(2, 1)
+	mvmA := createMicrovmAutoscaler(1, 5, infrav1.MetricSource{
+		Type: infrav1.PrometheusMetricSourceType,
+		Prometheus: &infrav1.PrometheusMetricSource{
+			Address:     "http://prometheus:9090",
+			Query:       "load",
+			TargetValue: resource.MustParse("1"),
+		},
+	})
+	objects := []runtime.Object{mvmD, mvmA}
+	client := createFakeClient(g, objects)
+
+	_, err := reconcileMicrovmAutoscaler(client, withMetricValue(0, errors.New("boom")))
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling autoscaler should not error when the metric is unavailable")
+
+	reconciledD, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*reconciledD.Spec.Replicas).To(Equal(int32(2)), "Expect the target not to be scaled")
+
+	reconciled, err := getMicrovmAutoscaler(client, testMicrovmAutoscalerName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmAutoscalerScalingActiveCondition, infrav1.MicrovmAutoscalerMetricUnavailableReason)
+}
+
+func TestMicrovmAutoscaler_ReconcileNormal_DensityScalesUp(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 2)
+	mvmA := createMicrovmAutoscaler(1, 5, infrav1.MetricSource{
+		Type:    infrav1.DensityMetricSourceType,
+		Density: &infrav1.DensityMetricSource{TargetPerHost: 3},
+	})
+	objects := []runtime.Object{mvmD, mvmA}
+
+	// one microvm on each of the deployment's hosts
+	for i, host := range mvmD.Spec.Hosts {
+		mvm := createMicrovm()
+		mvm.ObjectMeta = metav1.ObjectMeta{Name: fmt.Sprintf("mvm-%d", i), Namespace: testNamespace}
+		mvm.Spec.Host = microvm.Host{Endpoint: host.Endpoint}
+		objects = append(objects, mvm)
+	}
+
+	client := createFakeClient(g, objects)
+
+	_, err := reconcileMicrovmAutoscaler(client, nil)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling autoscaler should not error")
+
+	reconciledD, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*reconciledD.Spec.Replicas).To(Equal(int32(3)))
+}
@@ -19,34 +19,90 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
 	"github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
 	infrastructurev1alpha1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/dryrun"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/heartbeat"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/replica"
 	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
 )
 
+// maxConcurrentReplicaSetCreates is how many replicasets a MicrovmDeployment
+// creates at once when it has several new hosts.
+const maxConcurrentReplicaSetCreates = 10
+
+// maxConcurrentReplicaSetDeletes is how many replicasets a MicrovmDeployment
+// which is being deleted removes at once.
+const maxConcurrentReplicaSetDeletes = 10
+
 // MicrovmDeploymentReconciler reconciles a MicrovmDeployment object
 type MicrovmDeploymentReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Config holds the settings which can be changed while running, such as
+	// the requeue period. The defaults are used when it is nil.
+	Config *config.Store
+
+	// MaxConcurrentReconciles is how many MicrovmDeployments are reconciled at once.
+	// Defaults to 1 when zero.
+	MaxConcurrentReconciles int
+
+	// DryRun reconciles every MicrovmDeployment in dry-run mode, as the
+	// dry-run annotation does for one.
+	DryRun bool
+
+	// Heartbeats holds when each host last answered a flintlock call. Hosts
+	// which have not been reachable for longer than the contact timeout are
+	// not given new replicasets. Contact is not tracked when it is nil.
+	Heartbeats *heartbeat.Registry
+
+	// Failover replaces the replicasets on a host which has been unreachable
+	// for longer than the FailoverPolicy of their deployment with ones on
+	// other hosts. Unreachable hosts are only reported when it is false.
+	Failover bool
+
+	// indexed is true once the MicrovmReplicaSet controller and host endpoint
+	// indexes have been registered, which only happens when the reconciler is
+	// set up with a manager.
+	indexed bool
 }
 
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmdeployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmdeployments/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmdeployments/finalizers,verbs=update
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmreplicasets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmtemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhostgroups,verbs=get;list;watch
 
 func (r *MicrovmDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
@@ -57,7 +113,7 @@ func (r *MicrovmDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Re
 			return ctrl.Result{}, nil
 		}
 
-		log.Error(err, "error getting microvmdeployment", "id", req.NamespacedName)
+		log.Error(err, "error getting microvmdeployment")
 
 		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
 	}
@@ -76,27 +132,77 @@ func (r *MicrovmDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Re
 
 	defer func() {
 		if err := mvmDeploymentScope.Patch(); err != nil {
-			log.Error(err, "failed to patch microvmreplicaset")
+			log.Error(err, "failed to patch microvmdeployment")
 		}
 	}()
 
+	if dryrun.Enabled(mvmD, r.DryRun) {
+		return r.reconcileDryRun(ctx, mvmDeploymentScope)
+	}
+
+	mvmDeploymentScope.ClearDryRun()
+
 	if !mvmD.ObjectMeta.DeletionTimestamp.IsZero() {
 		log.Info("Deleting microvmdeployment")
 
 		return r.reconcileDelete(ctx, mvmDeploymentScope)
 	}
 
-	return r.reconcileNormal(ctx, mvmDeploymentScope)
+	result, err := r.reconcileNormal(ctx, mvmDeploymentScope)
+	if err == nil {
+		mvmDeploymentScope.SetObservedGeneration()
+	}
+
+	return result, err
+}
+
+// reconcileDryRun reconciles the microvmdeployment as usual, except that the
+// changes to its microvmreplicasets are recorded on its status rather than made.
+func (r *MicrovmDeploymentReconciler) reconcileDryRun(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+) (reconcile.Result, error) {
+	recorder := dryrun.NewClient(r.Client)
+
+	planner := *r
+	planner.Client = recorder
+
+	var (
+		result reconcile.Result
+		err    error
+	)
+
+	if !mvmDeploymentScope.MicrovmDeployment.DeletionTimestamp.IsZero() {
+		result, err = planner.reconcileDelete(ctx, mvmDeploymentScope)
+	} else {
+		result, err = planner.reconcileNormal(ctx, mvmDeploymentScope)
+	}
+
+	actions := recorder.Actions()
+	if len(actions) > 0 {
+		mvmDeploymentScope.Info("dry run: not changing microvmreplicasets", "actions", actions)
+	}
+
+	mvmDeploymentScope.SetDryRun(actions)
+
+	return result, err
 }
 
 func (r *MicrovmDeploymentReconciler) reconcileDelete(
 	ctx context.Context,
 	mvmDeploymentScope *scope.MicrovmDeploymentScope,
 ) (reconcile.Result, error) {
-	mvmDeploymentScope.Info("Reconciling MicrovmReplicaSet delete")
+	mvmDeploymentScope.V(logging.DebugLevel).Info("Reconciling MicrovmDeployment delete")
+
+	// an invalid selector falls back to listing the whole namespace, so that
+	// it can never block the deletion
+	selector, err := mvmDeploymentScope.Selector()
+	if err != nil {
+		selector = nil
+	}
 
 	// get all owned microvmreplicasets
-	rsList, err := r.getOwnedReplicaSets(ctx, mvmDeploymentScope)
+	rsList, err := r.getOwnedReplicaSets(ctx, mvmDeploymentScope, selector)
 	if err != nil {
 		mvmDeploymentScope.Error(err, "failed getting owned microvms")
 		return ctrl.Result{}, fmt.Errorf("failed to list microvms: %w", err)
@@ -105,7 +211,7 @@ func (r *MicrovmDeploymentReconciler) reconcileDelete(
 	// if there are no owned sets left we are done, we can leave now
 	if len(rsList) == 0 {
 		controllerutil.RemoveFinalizer(mvmDeploymentScope.MicrovmDeployment, infrav1.MvmDeploymentFinalizer)
-		mvmDeploymentScope.Info("microvmreplicaset deleted", "name", mvmDeploymentScope.Name())
+		mvmDeploymentScope.Info("microvmdeployment deleted")
 
 		return ctrl.Result{}, nil
 	}
@@ -123,40 +229,47 @@ func (r *MicrovmDeploymentReconciler) reconcileDelete(
 		}
 	}()
 
-	var created int32 = 0
-
-	for _, rs := range rsList {
-		created += rs.Status.Replicas
-
-		// if the object is already being deleted, skip this
-		if !rs.DeletionTimestamp.IsZero() {
-			continue
-		}
-
-		// otherwise send a delete call
-		go func(rs infrav1.MicrovmReplicaSet) {
-			if err := r.Delete(ctx, &rs); err != nil {
-				mvmDeploymentScope.Error(err, "failed deleting microvmreplicaset", "set", rs.Name)
-				mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentDeleteFailedReason, "Error", "")
-			}
-		}(rs)
+	// the status is only patched once every replicaset has settled
+	created, err := r.deleteReplicaSets(ctx, mvmDeploymentScope, rsList)
+	if err != nil {
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentDeleteFailedReason, "Error", "%s", err.Error())
 	}
 
 	// reset the number of still existing replicas, just so we know what is still there.
 	// we'll come back around to ensure they are really gone.
 	mvmDeploymentScope.SetCreatedReplicas(created)
 
-	return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+	return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
 }
 
 func (r *MicrovmDeploymentReconciler) reconcileNormal(
 	ctx context.Context,
 	mvmDeploymentScope *scope.MicrovmDeploymentScope,
 ) (reconcile.Result, error) {
-	mvmDeploymentScope.Info("Reconciling MicrovmDeployment update")
+	mvmDeploymentScope.V(logging.DebugLevel).Info("Reconciling MicrovmDeployment update")
+
+	// persist the finalizer before any replicasets are created, so that a delete
+	// which arrives from here on is guaranteed to clean them up
+	if controllerutil.AddFinalizer(mvmDeploymentScope.MicrovmDeployment, infrav1.MvmDeploymentFinalizer) {
+		if err := mvmDeploymentScope.Patch(); err != nil {
+			mvmDeploymentScope.Error(err, "unable to patch microvmdeployment")
+
+			return ctrl.Result{}, err
+		}
+	}
+
+	selector, err := mvmDeploymentScope.Selector()
+	if err != nil {
+		mvmDeploymentScope.Error(err, "invalid selector")
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentInvalidSelectorReason, "Error", err.Error())
+
+		return ctrl.Result{}, nil
+	}
 
-	// fetch all existing replicasets in this namespace
-	rsList, err := r.getOwnedReplicaSets(ctx, mvmDeploymentScope)
+	mvmDeploymentScope.SetSelector(selector)
+
+	// fetch all existing replicasets selected by the deployment
+	rsList, err := r.getOwnedReplicaSets(ctx, mvmDeploymentScope, selector)
 	if err != nil {
 		mvmDeploymentScope.Error(err, "failed getting owned microvms")
 
@@ -169,151 +282,1285 @@ func (r *MicrovmDeploymentReconciler) reconcileNormal(
 		}
 	}()
 
-	// record the microvms per set which have been created and are ready
-	// and create a map to record which host already has a replicaset
+	templateReady, err := r.resolveTemplate(ctx, mvmDeploymentScope)
+	if err != nil {
+		mvmDeploymentScope.Error(err, "failed getting microvmtemplate")
+
+		return ctrl.Result{}, err
+	}
+
+	if !templateReady {
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+	}
+
+	groupReady, err := r.resolveHostGroup(ctx, mvmDeploymentScope)
+	if err != nil {
+		mvmDeploymentScope.Error(err, "failed getting microvmhostgroup")
+
+		return ctrl.Result{}, err
+	}
+
+	if !groupReady {
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+	}
+
+	selectorValid, err := r.resolveHostSelector(ctx, mvmDeploymentScope)
+	if err != nil {
+		mvmDeploymentScope.Error(err, "failed listing selected microvmhosts")
+
+		return ctrl.Result{}, err
+	}
+
+	if !selectorValid {
+		return ctrl.Result{}, nil
+	}
+
+	// the replicasets are given the template labels which do not vary between
+	// replicas, and must be found by the selector again
+	templateLabels := replica.StaticLabels(mvmDeploymentScope.MicrovmTemplate().Labels)
+	if selector != nil && !selector.Matches(labels.Set(templateLabels)) {
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentInvalidSelectorReason, "Error", errSelectorMismatch.Error())
+
+		return ctrl.Result{}, nil
+	}
+
+	// replicasets created by a rollout are left to it until it completes
+	serving, updated := mvmDeploymentScope.SplitRollout(rsList)
 
+	// record the microvms per set which have been created and are ready.
 	// we always get a fresh count rather than rely on the status in case
 	// something was removed
 	var (
 		ready   int32 = 0
 		created int32 = 0
-
-		activeHosts = v1alpha1.HostMap{}
-		deadHosts   = v1alpha1.HostMap{}
 	)
 
-	for _, rs := range rsList {
+	for _, rs := range serving {
 		created += rs.Status.Replicas
 		ready += rs.Status.ReadyReplicas
-
-		activeHosts[rs.Spec.Host.Endpoint] = struct{}{}
-		deadHosts[rs.Spec.Host.Endpoint] = struct{}{}
 	}
 
 	mvmDeploymentScope.SetCreatedReplicas(created)
 	mvmDeploymentScope.SetReadyReplicas(ready)
 
-	// get a count of the replicasets created
-	createdSets := len(activeHosts)
-	// check whether any hosts have been removed
-	deadHosts = mvmDeploymentScope.ExpiredHosts(deadHosts)
+	// progress counts the microvms of a rollout too, as a deployment whose
+	// updated replicas are becoming ready is not stuck
+	var (
+		progressed int32 = 0
+		complete         = false
+	)
 
-	switch {
-	// if all desired microvms are ready, mark the deployment ready.
-	// we are done here
-	case mvmDeploymentScope.ReadyReplicas() == mvmDeploymentScope.DesiredTotalReplicas():
-		mvmDeploymentScope.Info("MicrovmDeployment created: ready")
-		mvmDeploymentScope.SetReady()
+	for _, rs := range rsList {
+		progressed += rs.Status.ReadyReplicas
+	}
 
-		return reconcile.Result{}, nil
-	// if we are here then a host has been removed.
-	// we delete the set associated with that host.
-	case len(deadHosts) > 0:
-		mvmDeploymentScope.Info("MicrovmDeployment updating: delete microvmreplicaset")
-		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentUpdatingReason, "Info", "")
+	defer func() {
+		mvmDeploymentScope.SetProgress(progressed, complete, time.Now())
+	}()
 
-		for _, rs := range rsList {
-			if _, ok := deadHosts[rs.Spec.Host.Endpoint]; !ok {
-				continue
-			}
+	nextFailover, err := r.loadHosts(ctx, mvmDeploymentScope, serving)
+	if err != nil {
+		mvmDeploymentScope.Error(err, "failed getting microvmhosts")
 
-			if !rs.DeletionTimestamp.IsZero() {
-				return ctrl.Result{}, nil
-			}
+		return ctrl.Result{}, err
+	}
 
-			if err := r.Delete(ctx, &rs); err != nil {
-				mvmDeploymentScope.Error(err, "failed deleting microvmreplicaset")
-				mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentUpdateFailedReason, "Error", "")
+	// report the replicasets, hosts and template once any rollout has moved on,
+	// so that they are summarised into the Ready condition on patch
+	defer func() {
+		mvmDeploymentScope.SetReplicasReady(serving)
+		mvmDeploymentScope.SetHostsHealthy()
+		mvmDeploymentScope.SetTemplateUpToDate(serving)
+	}()
 
-				return ctrl.Result{}, err
-			}
-		}
-	// if we are in this branch then not all desired replicasets have been created.
-	// create a new one and set the ownerref to this controller.
-	case createdSets < mvmDeploymentScope.RequiredSets():
-		mvmDeploymentScope.Info("MicrovmDeployment creating: create new microvmreplicaset")
+	reportSpread(mvmDeploymentScope, serving)
+
+	// work out everything which needs to change across all hosts so that
+	// large edits to the host list converge in one pass
+	plan := mvmDeploymentScope.PlanHosts(serving)
 
-		host, err := mvmDeploymentScope.DetermineHost(activeHosts)
+	// report every replicaset, including those of a rollout, against its host
+	mvmDeploymentScope.SetHostSummaries(rsList)
+	mvmDeploymentScope.SetNextHost(plan)
+
+	// a changed template is only rolled out once the hosts are settled
+	if plan.IsEmpty() {
+		rolling, requeue, err := r.rollOut(ctx, mvmDeploymentScope, serving, updated)
 		if err != nil {
-			mvmDeploymentScope.Error(err, "failed creating owned microvmreplicaset")
-			mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentProvisionFailedReason, "Error", "")
+			return ctrl.Result{}, err
+		}
 
-			return reconcile.Result{}, fmt.Errorf("failed to create new replicaset for deployment: %w", err)
+		if rolling {
+			return ctrl.Result{RequeueAfter: requeue}, nil
 		}
+	}
 
-		if err := r.createReplicaSet(ctx, mvmDeploymentScope, host); err != nil {
-			mvmDeploymentScope.Error(err, "failed creating owned microvmreplicaset")
-			mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentProvisionFailedReason, "Error", "")
+	// if nothing needs to change and all desired microvms are ready, mark the
+	// deployment ready. we are done here
+	if plan.IsEmpty() && mvmDeploymentScope.ReadyReplicas() == mvmDeploymentScope.DesiredTotalReplicas() {
+		mvmDeploymentScope.V(logging.DebugLevel).Info("MicrovmDeployment created: ready")
+		mvmDeploymentScope.SetReady()
+		complete = true
 
-			return reconcile.Result{}, fmt.Errorf("failed to create new replicaset for deployment: %w", err)
-		}
+		// come back when an unreachable host is due to fail over
+		return reconcile.Result{RequeueAfter: nextFailover}, nil
+	}
 
+	if plan.IsEmpty() {
+		// all desired objects have been created, but are not quite ready yet,
+		// set the condition and requeue
+		mvmDeploymentScope.V(logging.DebugLevel).Info("MicrovmDeployment creating: waiting for microvms to become ready")
 		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentIncompleteReason, "Info", "")
-	// if all desired objects have been created, but are not quite ready yet,
-	// set the condition and requeue
-	default:
-		mvmDeploymentScope.Info("MicrovmReplicaSet creating: waiting for microvms to become ready")
-		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentIncompleteReason, "Info", "")
+
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+	}
+
+	if err := r.applyHostPlan(ctx, mvmDeploymentScope, plan); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	controllerutil.AddFinalizer(mvmDeploymentScope.MicrovmDeployment, infrav1.MvmDeploymentFinalizer)
+	reportDrain(mvmDeploymentScope, plan)
 
-	return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+	return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
 }
 
-func (r *MicrovmDeploymentReconciler) createReplicaSet(
+// resolveTemplate loads the referenced MicrovmTemplate, if any, into the scope.
+// It returns false if the template cannot be used yet.
+func (r *MicrovmDeploymentReconciler) resolveTemplate(
 	ctx context.Context,
 	mvmDeploymentScope *scope.MicrovmDeploymentScope,
-	host microvm.Host,
-) error {
-	newRs := &infrav1.MicrovmReplicaSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace:    mvmDeploymentScope.Namespace(),
-			GenerateName: "microvmreplicaset-",
-		},
-		Spec: infrav1.MicrovmReplicaSetSpec{
-			Host:     host,
-			Replicas: pointer.Int32(mvmDeploymentScope.DesiredReplicas()),
-			Template: infrav1.MicrovmTemplateSpec{
-				Spec: mvmDeploymentScope.MicrovmSpec(),
-			},
-		},
+) (bool, error) {
+	ref := mvmDeploymentScope.TemplateRef()
+	if ref == nil {
+		mvmDeploymentScope.SetTemplate(nil)
+
+		return true, nil
 	}
 
-	if err := controllerutil.SetControllerReference(mvmDeploymentScope.MicrovmDeployment, newRs, r.Scheme); err != nil {
-		return err
+	mvmT := &infrav1.MicrovmTemplate{}
+	key := client.ObjectKey{Name: ref.Name, Namespace: mvmDeploymentScope.Namespace()}
+
+	if err := r.Get(ctx, key, mvmT); err != nil {
+		if apierrors.IsNotFound(err) {
+			mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentTemplateNotReadyReason,
+				clusterv1.ConditionSeverityWarning, "microvmtemplate %s not found", ref.Name)
+
+			return false, nil
+		}
+
+		return false, fmt.Errorf("getting microvmtemplate %s: %w", ref.Name, err)
+	}
+
+	if !mvmT.IsResolved() {
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentTemplateNotReadyReason,
+			clusterv1.ConditionSeverityWarning, "microvmtemplate %s has not been resolved", ref.Name)
+
+		return false, nil
 	}
 
-	return r.Create(ctx, newRs)
+	mvmDeploymentScope.SetTemplate(mvmT)
+
+	return true, nil
 }
 
-func (r *MicrovmDeploymentReconciler) getOwnedReplicaSets(
+// resolveHostGroup loads the referenced MicrovmHostGroup into the scope. It
+// returns false, having set the deployment not ready, if the group cannot be
+// used yet. The hosts on the spec are left untouched meanwhile, so that a
+// missing group never removes the replicasets.
+func (r *MicrovmDeploymentReconciler) resolveHostGroup(
 	ctx context.Context,
 	mvmDeploymentScope *scope.MicrovmDeploymentScope,
-) ([]infrav1.MicrovmReplicaSet, error) {
-	rsList := &infrav1.MicrovmReplicaSetList{}
-	opts := []client.ListOption{
-		client.InNamespace(mvmDeploymentScope.Namespace()),
+) (bool, error) {
+	ref := mvmDeploymentScope.HostGroupRef()
+	if ref == nil {
+		return true, nil
 	}
-	if err := r.List(ctx, rsList, opts...); err != nil {
-		return nil, err
+
+	group := &infrav1.MicrovmHostGroup{}
+	key := client.ObjectKey{Name: ref.Name, Namespace: mvmDeploymentScope.Namespace()}
+
+	if err := r.Get(ctx, key, group); err != nil {
+		if apierrors.IsNotFound(err) {
+			mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentHostGroupNotReadyReason,
+				clusterv1.ConditionSeverityWarning, "microvmhostgroup %s not found", ref.Name)
+
+			return false, nil
+		}
+
+		return false, fmt.Errorf("getting microvmhostgroup %s: %w", ref.Name, err)
 	}
 
-	owned := []v1alpha1.MicrovmReplicaSet{}
+	if !group.Status.Ready {
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentHostGroupNotReadyReason,
+			clusterv1.ConditionSeverityWarning, "microvmhostgroup %s has not been resolved", ref.Name)
 
-	for _, rs := range rsList.Items {
-		if metav1.IsControlledBy(&rs, mvmDeploymentScope.MicrovmDeployment) {
-			owned = append(owned, rs)
+		return false, nil
+	}
+
+	mvmDeploymentScope.SetHostGroup(group)
+
+	return true, nil
+}
+
+// resolveHostSelector loads the MicrovmHosts matched by the host selector, if
+// any, into the scope. It returns false, having set the deployment not ready,
+// if the selector is invalid.
+func (r *MicrovmDeploymentReconciler) resolveHostSelector(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+) (bool, error) {
+	selector, err := mvmDeploymentScope.HostSelector()
+	if err != nil {
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentInvalidHostSelectorReason,
+			clusterv1.ConditionSeverityError, "%s", err.Error())
+
+		return false, nil
+	}
+
+	if selector == nil {
+		return true, nil
+	}
+
+	hosts := &infrav1.MicrovmHostList{}
+	if err := r.List(ctx, hosts, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return false, fmt.Errorf("listing microvmhosts: %w", err)
+	}
+
+	mvmDeploymentScope.SetSelectedHosts(hosts.Items)
+
+	return true, nil
+}
+
+// loadHosts records in the scope which hosts are not given new replicasets,
+// which have failed, been preempted or fallen out of contact, and the failure
+// domain of each host when the deployment is spread across them. It returns
+// how long it will be until the next unreachable host fails over or falls out
+// of contact, or 0 if none will.
+func (r *MicrovmDeploymentReconciler) loadHosts(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	sets []infrav1.MicrovmReplicaSet,
+) (time.Duration, error) {
+	hosts := &infrav1.MicrovmHostList{}
+	if err := r.List(ctx, hosts); err != nil {
+		return 0, fmt.Errorf("listing microvmhosts: %w", err)
+	}
+
+	running := runningHosts(sets)
+	unschedulable := cordonedHosts(hosts.Items)
+	failed, untilFailover := r.failedHosts(mvmDeploymentScope, hosts.Items)
+
+	// full hosts of a group keep the replicasets they already run, but are not
+	// given new ones until a lower priority deployment has made way
+	full, err := r.fullHosts(ctx, mvmDeploymentScope, running)
+	if err != nil {
+		return 0, err
+	}
+
+	// hosts which cannot be reached keep the replicasets they already run,
+	// which fail over if the deployment has a policy, but are not given new
+	// ones until they answer again
+	outOfContact, untilOutOfContact := r.loadContacts(mvmDeploymentScope)
+
+	for _, excluded := range []infrav1.HostMap{full, withoutHosts(outOfContact, running)} {
+		for endpoint := range excluded {
+			unschedulable[endpoint] = struct{}{}
 		}
 	}
 
-	return owned, nil
+	if mvmDeploymentScope.TopologyKey() == infrav1.FailureDomainSpreadTopology {
+		mvmDeploymentScope.SetFailureDomains(hostFailureDomains(hosts.Items))
+	}
+
+	mvmDeploymentScope.SetUnschedulable(unschedulable)
+	mvmDeploymentScope.SetFailed(failed)
+	mvmDeploymentScope.SetPreempted(preemptedHosts(sets))
+	mvmDeploymentScope.SetOutOfContact(outOfContact)
+
+	return soonest(untilFailover, untilOutOfContact), nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *MicrovmDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+// runningHosts returns the hosts the replicasets run on.
+func runningHosts(sets []infrav1.MicrovmReplicaSet) infrav1.HostMap {
+	running := infrav1.HostMap{}
+	for _, rs := range sets {
+		running[rs.Spec.Host.Endpoint] = struct{}{}
+	}
+
+	return running
+}
+
+// cordonedHosts returns the hosts which are marked unschedulable.
+func cordonedHosts(hosts []infrav1.MicrovmHost) infrav1.HostMap {
+	cordoned := infrav1.HostMap{}
+
+	for _, host := range hosts {
+		if host.Spec.Unschedulable {
+			cordoned[host.Spec.Endpoint] = struct{}{}
+		}
+	}
+
+	return cordoned
+}
+
+// failedHosts returns the hosts which have been unreachable for long enough
+// to fail over from when failover is enabled and the deployment has a
+// failover policy, and how long it will be until the next one has, or 0 if
+// none will.
+func (r *MicrovmDeploymentReconciler) failedHosts(
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	hosts []infrav1.MicrovmHost,
+) (infrav1.HostMap, time.Duration) {
+	var next time.Duration
+
+	failed := infrav1.HostMap{}
+
+	failoverAfter, failover := mvmDeploymentScope.FailoverAfter()
+	if !failover || !r.Failover {
+		return failed, 0
+	}
+
+	for _, host := range hosts {
+		since := host.Status.UnreachableSince
+		if since == nil {
+			continue
+		}
+
+		remaining := failoverAfter - time.Since(since.Time)
+		if remaining <= 0 {
+			failed[host.Spec.Endpoint] = struct{}{}
+		} else {
+			next = soonest(next, remaining)
+		}
+	}
+
+	return failed, next
+}
+
+// fullHosts returns the full hosts of the host group of the deployment which
+// do not run one of its replicasets yet, preempting a lower priority
+// replicaset on each.
+func (r *MicrovmDeploymentReconciler) fullHosts(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	running infrav1.HostMap,
+) (infrav1.HostMap, error) {
+	full := infrav1.HostMap{}
+
+	group := mvmDeploymentScope.HostGroup()
+	if group == nil {
+		return full, nil
+	}
+
+	for _, endpoint := range group.Status.FullHosts {
+		if _, ok := running[endpoint]; ok {
+			continue
+		}
+
+		full[endpoint] = struct{}{}
+
+		if err := r.preemptHost(ctx, mvmDeploymentScope, endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	return full, nil
+}
+
+// preemptedHosts returns the hosts of the replicasets which a higher priority
+// deployment has preempted, so that they are recreated on the other hosts.
+func preemptedHosts(sets []infrav1.MicrovmReplicaSet) infrav1.HostMap {
+	preempted := infrav1.HostMap{}
+
+	for _, rs := range sets {
+		if _, ok := rs.Annotations[infrav1.PreemptedByAnnotation]; ok {
+			preempted[rs.Spec.Host.Endpoint] = struct{}{}
+		}
+	}
+
+	return preempted
+}
+
+// withoutHosts returns the hosts in hosts which are not in excluded.
+func withoutHosts(hosts, excluded infrav1.HostMap) infrav1.HostMap {
+	remaining := infrav1.HostMap{}
+
+	for endpoint := range hosts {
+		if _, ok := excluded[endpoint]; !ok {
+			remaining[endpoint] = struct{}{}
+		}
+	}
+
+	return remaining
+}
+
+// hostFailureDomains returns the failure domain of each host which has one.
+func hostFailureDomains(hosts []infrav1.MicrovmHost) map[string]string {
+	failureDomains := map[string]string{}
+
+	for _, host := range hosts {
+		if host.Spec.FailureDomain != "" {
+			failureDomains[host.Spec.Endpoint] = host.Spec.FailureDomain
+		}
+	}
+
+	return failureDomains
+}
+
+// loadContacts records when each host of the deployment last answered a
+// flintlock call, and returns the hosts which calls have failed to reach for
+// longer than the contact timeout, along with how long it will be until the
+// next host whose calls are failing falls out of contact, or 0 if none will.
+func (r *MicrovmDeploymentReconciler) loadContacts(
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+) (infrav1.HostMap, time.Duration) {
+	var next time.Duration
+
+	now := time.Now()
+	timeout := r.Config.ContactTimeout()
+	lastContacts := map[string]time.Time{}
+	outOfContact := infrav1.HostMap{}
+
+	for _, host := range mvmDeploymentScope.Hosts() {
+		contact, ok := r.Heartbeats.Contact(host.Endpoint)
+		if !ok {
+			continue
+		}
+
+		if !contact.LastSuccess.IsZero() {
+			lastContacts[host.Endpoint] = contact.LastSuccess
+		}
+
+		if timeout <= 0 {
+			continue
+		}
+
+		if contact.Unreachable(timeout, now) {
+			outOfContact[host.Endpoint] = struct{}{}
+
+			continue
+		}
+
+		at := contact.UnreachableAt(timeout)
+		if remaining := at.Sub(now); !at.IsZero() && (next == 0 || remaining < next) {
+			next = remaining
+		}
+	}
+
+	mvmDeploymentScope.SetLastContacts(lastContacts)
+
+	return outOfContact, next
+}
+
+// preemptHost marks the lowest priority replicaset of another deployment on
+// the full host at endpoint as preempted, if its priority is lower than the
+// deployment's, so that its replicas make way. Only replicasets in the
+// deployment's own namespace are candidates, so that one tenant can never
+// evict another's. Nothing more is preempted while an earlier preemption on
+// the host is still in progress.
+func (r *MicrovmDeploymentReconciler) preemptHost(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	endpoint string,
+) error {
+	rsList := &infrav1.MicrovmReplicaSetList{}
+	opts := []client.ListOption{
+		client.InNamespace(mvmDeploymentScope.Namespace()),
+	}
+	if r.indexed {
+		opts = append(opts, client.MatchingFields{hostEndpointField: endpoint})
+	}
+	if err := r.List(ctx, rsList, opts...); err != nil {
+		return fmt.Errorf("listing microvmreplicasets: %w", err)
+	}
+
+	var victim *infrav1.MicrovmReplicaSet
+
+	for i := range rsList.Items {
+		rs := &rsList.Items[i]
+		if rs.Spec.Host.Endpoint != endpoint {
+			continue
+		}
+
+		if _, ok := rs.Annotations[infrav1.PreemptedByAnnotation]; ok {
+			return nil
+		}
+
+		if !preemptible(rs, mvmDeploymentScope.Priority()) {
+			continue
+		}
+
+		if victim == nil || rs.Spec.Template.Spec.Priority < victim.Spec.Template.Spec.Priority {
+			victim = rs
+		}
+	}
+
+	if victim == nil {
+		return nil
+	}
+
+	patch := client.MergeFromWithOptions(victim.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	if victim.Annotations == nil {
+		victim.Annotations = map[string]string{}
+	}
+
+	victim.Annotations[infrav1.PreemptedByAnnotation] = mvmDeploymentScope.Namespace() + "/" + mvmDeploymentScope.Name()
+
+	if err := r.Patch(ctx, victim, patch, apply.FieldOwner); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("preempting microvmreplicaset %s/%s: %w", victim.Namespace, victim.Name, err)
+	}
+
+	mvmDeploymentScope.Info("preempted microvmreplicaset", logging.HostKey, endpoint,
+		logging.ReplicaSetKey, victim.Namespace+"/"+victim.Name, "priority", victim.Spec.Template.Spec.Priority)
+
+	return nil
+}
+
+// preemptible returns true if rs can make way for a deployment with the given
+// priority: it has a lower priority and is controlled by a deployment which
+// can recreate its replicas elsewhere.
+func preemptible(rs *infrav1.MicrovmReplicaSet, priority int32) bool {
+	ref := metav1.GetControllerOf(rs)
+	if ref == nil || ref.Kind != "MicrovmDeployment" {
+		return false
+	}
+
+	return rs.Spec.Template.Spec.Priority < priority && rs.DeletionTimestamp.IsZero() && !replica.IsExternal(rs)
+}
+
+// reportSpread records whether the ready replicas are spread within the
+// spread constraints, and whether the placement overrides are honoured, so
+// that failures which leave them uneven are visible.
+func reportSpread(mvmDeploymentScope *scope.MicrovmDeploymentScope, sets []infrav1.MicrovmReplicaSet) {
+	if !mvmDeploymentScope.IsSpread() {
+		mvmDeploymentScope.ClearSpread()
+
+		return
+	}
+
+	skew := mvmDeploymentScope.SpreadSkew(sets)
+	if skew > mvmDeploymentScope.MaxSkew() {
+		mvmDeploymentScope.SetSpreadViolated(
+			infrav1.MicrovmDeploymentSpreadSkewExceededReason,
+			clusterv1.ConditionSeverityWarning,
+			"ready replicas skew of %d exceeds maxSkew %d across %s domains",
+			skew, mvmDeploymentScope.MaxSkew(), mvmDeploymentScope.TopologyKey(),
+		)
+
+		return
+	}
+
+	if unplaced := mvmDeploymentScope.UnplacedOverrides(); len(unplaced) > 0 {
+		pins := make([]string, 0, len(unplaced))
+		for _, override := range unplaced {
+			pins = append(pins, fmt.Sprintf("%d on %s", override.Replica, override.Host))
+		}
+
+		mvmDeploymentScope.SetSpreadViolated(
+			infrav1.MicrovmDeploymentPlacementOverrideUnschedulableReason,
+			clusterv1.ConditionSeverityWarning,
+			"replicas pinned to unschedulable hosts are spread instead: %s",
+			strings.Join(pins, ", "),
+		)
+
+		return
+	}
+
+	mvmDeploymentScope.SetSpreadSatisfied()
+}
+
+// applyHostPlan removes the replicasets of hosts which have been dropped from
+// the spec, rescales those whose replica count is out of date, and creates
+// replicasets for any new hosts.
+func (r *MicrovmDeploymentReconciler) applyHostPlan(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	plan scope.HostPlan,
+) error {
+	if len(plan.Delete) > 0 || len(plan.Scale) > 0 {
+		mvmDeploymentScope.Info("MicrovmDeployment updating: delete or scale microvmreplicasets",
+			"delete", len(plan.Delete), "scale", len(plan.Scale))
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentUpdatingReason, "Info", "")
+	}
+
+	for i := range plan.Delete {
+		rs := plan.Delete[i]
+
+		// if the object is already being deleted or is externally managed, skip this
+		if !rs.DeletionTimestamp.IsZero() || replica.IsExternal(&rs) {
+			continue
+		}
+
+		if err := r.Delete(ctx, &rs); err != nil && !apierrors.IsNotFound(err) {
+			mvmDeploymentScope.Error(err, "failed deleting microvmreplicaset", logging.ReplicaSetKey, rs.Name)
+			mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentUpdateFailedReason, "Error", "")
+
+			return fmt.Errorf("failed to delete replicaset %s: %w", rs.Name, err)
+		}
+	}
+
+	for i := range plan.Scale {
+		rs := plan.Scale[i]
+		if replica.IsExternal(&rs) {
+			continue
+		}
+
+		base := rs.DeepCopy()
+
+		rs.Spec.Replicas = pointer.Int32(plan.Replicas[rs.Spec.Host.Endpoint])
+
+		if err := r.Patch(ctx, &rs, client.MergeFrom(base), apply.FieldOwner); err != nil {
+			mvmDeploymentScope.Error(err, "failed scaling microvmreplicaset", logging.ReplicaSetKey, rs.Name)
+			mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentUpdateFailedReason, "Error", "")
+
+			return fmt.Errorf("failed to scale replicaset %s: %w", rs.Name, err)
+		}
+	}
+
+	if len(plan.Create) == 0 {
+		return nil
+	}
+
+	mvmDeploymentScope.Info("MicrovmDeployment creating: create new microvmreplicasets", "count", len(plan.Create))
+
+	if err := r.createReplicaSets(ctx, mvmDeploymentScope, plan); err != nil {
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentProvisionFailedReason, "Error", "")
+
+		return fmt.Errorf("failed to create new replicasets for deployment: %w", err)
+	}
+
+	mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentIncompleteReason, "Info", "")
+
+	return nil
+}
+
+// rollOut rolls a changed template out to the hosts in serving which run an
+// outdated replicaset, first to the canary host and then, once it has soaked,
+// to the rest. Each host gets a replicaset with the template alongside its
+// outdated one, and the outdated ones are only deleted once every host has
+// been updated, so a failure rolls back by deleting the updated ones. It
+// returns true while the deployment is held by the rollout, along with when to
+// check on it again.
+func (r *MicrovmDeploymentReconciler) rollOut(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	serving, updated []infrav1.MicrovmReplicaSet,
+) (bool, time.Duration, error) {
+	canary := mvmDeploymentScope.Canary()
+	if canary == nil {
+		return false, 0, nil
+	}
+
+	hash := mvmDeploymentScope.TemplateHash()
+	status := mvmDeploymentScope.Rollout()
+
+	outdated := map[string]*infrav1.MicrovmReplicaSet{}
+
+	for _, host := range mvmDeploymentScope.SchedulableHosts() {
+		for i := range serving {
+			if serving[i].Spec.Host.Endpoint == host.Endpoint && mvmDeploymentScope.IsOutdated(&serving[i], hash) {
+				outdated[host.Endpoint] = &serving[i]
+			}
+		}
+	}
+
+	if len(outdated) == 0 {
+		if status != nil && status.TemplateHash == hash && status.Phase != infrav1.RolloutPhaseRolledBack {
+			status.Phase = infrav1.RolloutPhaseComplete
+		}
+
+		return false, 0, nil
+	}
+
+	if status != nil && status.TemplateHash == hash && status.Phase == infrav1.RolloutPhaseRolledBack {
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentRolledBackReason, clusterv1.ConditionSeverityWarning,
+			"rollout of template %s was rolled back: %s", hash, status.Message)
+
+		return true, 0, nil
+	}
+
+	now := metav1.Now()
+
+	if status == nil || status.TemplateHash != hash || status.Phase == infrav1.RolloutPhaseComplete {
+		status = &infrav1.RolloutStatus{
+			TemplateHash:   hash,
+			Phase:          infrav1.RolloutPhaseCanary,
+			PhaseStartedAt: &now,
+		}
+		mvmDeploymentScope.SetRollout(status)
+		mvmDeploymentScope.Info("MicrovmDeployment rolling out template", "hash", hash, "canary", canary.Host)
+	}
+
+	if status.Phase == infrav1.RolloutPhaseCanary {
+		target, ok := outdated[canary.Host]
+		if !ok && !hasHost(mvmDeploymentScope.SchedulableHosts(), canary.Host) {
+			mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentInvalidCanaryReason, clusterv1.ConditionSeverityError,
+				"canary host %s is not a schedulable host of the deployment", canary.Host)
+
+			return true, 0, nil
+		}
+
+		// a canary host which is already up to date has nothing to soak
+		if ok {
+			targets := map[string]*infrav1.MicrovmReplicaSet{canary.Host: target}
+
+			ready, err := r.rollOutStep(ctx, mvmDeploymentScope, status, targets, updated)
+			if err != nil || !ready {
+				status.CanaryReadyAt = nil
+
+				return true, r.requeuePeriod(), err
+			}
+
+			if status.CanaryReadyAt == nil {
+				status.CanaryReadyAt = &now
+			}
+
+			soak := mvmDeploymentScope.CanarySoak() - now.Sub(status.CanaryReadyAt.Time)
+			if soak > 0 {
+				mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentRollingOutReason, "Info",
+					"soaking template %s on canary host %s", hash, canary.Host)
+
+				return true, soak, nil
+			}
+		}
+
+		status.Phase = infrav1.RolloutPhaseProgressing
+		status.PhaseStartedAt = &now
+		mvmDeploymentScope.Info("MicrovmDeployment canary succeeded: rolling out to the remaining hosts", "hash", hash)
+	}
+
+	ready, err := r.rollOutStep(ctx, mvmDeploymentScope, status, outdated, updated)
+	if err != nil || !ready {
+		return true, r.requeuePeriod(), err
+	}
+
+	for _, rs := range outdated {
+		if err := r.Delete(ctx, rs); err != nil && !apierrors.IsNotFound(err) {
+			return true, 0, fmt.Errorf("deleting outdated microvmreplicaset %s: %w", rs.Name, err)
+		}
+	}
+
+	status.Phase = infrav1.RolloutPhaseComplete
+	status.PhaseStartedAt = &now
+	mvmDeploymentScope.Info("MicrovmDeployment rolled out template", "hash", hash)
+	mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentUpdatingReason, "Info", "")
+
+	return true, r.requeuePeriod(), nil
+}
+
+// rollOutStep makes sure each target host has a replicaset with the template
+// alongside its outdated one, and returns true once they are all ready. The
+// rollout is rolled back if any of them fail, or are not ready within the
+// deadline.
+func (r *MicrovmDeploymentReconciler) rollOutStep(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	status *infrav1.RolloutStatus,
+	targets map[string]*infrav1.MicrovmReplicaSet,
+	updated []infrav1.MicrovmReplicaSet,
+) (bool, error) {
+	waiting := []string{}
+	failed := []string{}
+
+	for endpoint, outdated := range targets {
+		var rs *infrav1.MicrovmReplicaSet
+
+		for i := range updated {
+			if updated[i].Spec.Host.Endpoint == endpoint {
+				rs = &updated[i]
+			}
+		}
+
+		if rs == nil {
+			if err := r.createReplicaSet(ctx, mvmDeploymentScope, outdated.Spec.Host, pointer.Int32Deref(outdated.Spec.Replicas, 0)); err != nil {
+				return false, fmt.Errorf("creating updated replicaset on %s: %w", endpoint, err)
+			}
+
+			waiting = append(waiting, endpoint)
+
+			continue
+		}
+
+		if reason := infrav1.GetFailureReason(rs); reason != "" {
+			failed = append(failed, fmt.Sprintf("%s on %s", reason, endpoint))
+		} else if rs.Status.ReadyReplicas < pointer.Int32Deref(rs.Spec.Replicas, 0) {
+			waiting = append(waiting, endpoint)
+		}
+	}
+
+	sort.Strings(waiting)
+	sort.Strings(failed)
+
+	deadline := mvmDeploymentScope.ProgressDeadline()
+	overdue := status.PhaseStartedAt != nil && time.Since(status.PhaseStartedAt.Time) > deadline
+
+	if len(failed) == 0 && len(waiting) > 0 && overdue {
+		failed = append(failed, fmt.Sprintf("not ready within %s on %s", deadline, strings.Join(waiting, ", ")))
+	}
+
+	if len(failed) > 0 {
+		return false, r.rollBack(ctx, mvmDeploymentScope, status, updated, strings.Join(failed, ", "))
+	}
+
+	if len(waiting) > 0 {
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentRollingOutReason, "Info",
+			"rolling out template %s: waiting for hosts %s", status.TemplateHash, strings.Join(waiting, ", "))
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// rollBack deletes the replicasets created by the rollout, leaving the outdated
+// ones to serve, and records why.
+func (r *MicrovmDeploymentReconciler) rollBack(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	status *infrav1.RolloutStatus,
+	updated []infrav1.MicrovmReplicaSet,
+	message string,
+) error {
+	for i := range updated {
+		if err := r.Delete(ctx, &updated[i]); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting updated microvmreplicaset %s: %w", updated[i].Name, err)
+		}
+	}
+
+	now := metav1.Now()
+	status.Phase = infrav1.RolloutPhaseRolledBack
+	status.PhaseStartedAt = &now
+	status.Message = message
+
+	mvmDeploymentScope.Info("MicrovmDeployment rolled back template", "hash", status.TemplateHash, "reason", message)
+	mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentRolledBackReason, clusterv1.ConditionSeverityWarning,
+		"rollout of template %s was rolled back: %s", status.TemplateHash, message)
+
+	return nil
+}
+
+// hasHost returns true if one of hosts has the endpoint.
+func hasHost(hosts []microvm.Host, endpoint string) bool {
+	for _, host := range hosts {
+		if host.Endpoint == endpoint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reportDrain records that replicasets on unschedulable, failed or preempted
+// hosts are being kept until their replicas have been recreated on the other hosts.
+func reportDrain(mvmDeploymentScope *scope.MicrovmDeploymentScope, plan scope.HostPlan) {
+	if len(plan.Drain) == 0 {
+		return
+	}
+
+	failed := []string{}
+	preempted := []string{}
+
+	for _, rs := range plan.Drain {
+		switch endpoint := rs.Spec.Host.Endpoint; {
+		case mvmDeploymentScope.IsPreempted(endpoint):
+			preempted = append(preempted, endpoint)
+		case mvmDeploymentScope.IsFailed(endpoint):
+			failed = append(failed, endpoint)
+		}
+	}
+
+	if len(preempted) > 0 {
+		mvmDeploymentScope.Info("MicrovmDeployment preempted: recreating replicas of hosts needed by a higher priority deployment",
+			"hosts", preempted)
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentPreemptedReason, clusterv1.ConditionSeverityWarning,
+			"recreating the replicas of preempted hosts %s on the other hosts", strings.Join(preempted, ", "))
+
+		return
+	}
+
+	if len(failed) > 0 {
+		mvmDeploymentScope.Info("MicrovmDeployment failing over: recreating replicas of unreachable hosts",
+			"hosts", failed)
+		mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentFailingOverReason, clusterv1.ConditionSeverityWarning,
+			"recreating the replicas of unreachable hosts %s on the other hosts", strings.Join(failed, ", "))
+
+		return
+	}
+
+	mvmDeploymentScope.Info("MicrovmDeployment draining: waiting for replicas on schedulable hosts",
+		"draining", len(plan.Drain))
+	mvmDeploymentScope.SetNotReady(infrav1.MicrovmDeploymentDrainingReason, "Info",
+		"waiting for replicas to be ready on schedulable hosts before removing %d microvmreplicasets", len(plan.Drain))
+}
+
+// releaseReplicaSet removes the deployment as the controller of a replicaset.
+func (r *MicrovmDeploymentReconciler) releaseReplicaSet(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	rs *infrav1.MicrovmReplicaSet,
+) error {
+	if err := release(ctx, r.Client, rs, mvmDeploymentScope.MicrovmDeployment); err != nil {
+		return fmt.Errorf("releasing microvmreplicaset %s: %w", rs.Name, err)
+	}
+
+	mvmDeploymentScope.Info("released microvmreplicaset", logging.ReplicaSetKey, rs.Name)
+
+	return nil
+}
+
+// orphanReplicaSet sets the delete policy of the replicaset to Orphan, so that
+// its microvms are released rather than deleted along with it.
+func (r *MicrovmDeploymentReconciler) orphanReplicaSet(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	rs *infrav1.MicrovmReplicaSet,
+) error {
+	if rs.Spec.DeletePolicy == infrav1.DeletePolicyOrphan {
+		return nil
+	}
+
+	patch := client.MergeFromWithOptions(rs.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	rs.Spec.DeletePolicy = infrav1.DeletePolicyOrphan
+
+	if err := r.Patch(ctx, rs, patch, apply.FieldOwner); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("orphaning microvmreplicaset %s: %w", rs.Name, err)
+	}
+
+	mvmDeploymentScope.Info("orphaning microvms of microvmreplicaset", logging.ReplicaSetKey, rs.Name)
+
+	return nil
+}
+
+// deleteReplicaSets removes each of the replicasets from the deployment, up to
+// maxConcurrentReplicaSetDeletes at a time, and waits for every one to settle.
+// It returns how many microvms the replicasets still hold, along with the
+// errors of those which could not be removed, each naming its replicaset.
+func (r *MicrovmDeploymentReconciler) deleteReplicaSets(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	sets []infrav1.MicrovmReplicaSet,
+) (int32, error) {
+	var remaining int32
+
+	errs := parallel(len(sets), maxConcurrentReplicaSetDeletes, func(i int) error {
+		rs := sets[i]
+
+		held, err := r.deleteReplicaSet(ctx, mvmDeploymentScope, &rs)
+		atomic.AddInt32(&remaining, held)
+
+		return err
+	})
+
+	// the deletes finish in any order, and the message should only change
+	// when the failures do
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+
+	return remaining, kerrors.NewAggregate(errs)
+}
+
+// deleteReplicaSet deletes the replicaset, first telling it to release its
+// microvms when the deployment orphans them. An externally managed replicaset
+// is released instead. It returns how many microvms the replicaset still
+// holds.
+func (r *MicrovmDeploymentReconciler) deleteReplicaSet(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	rs *infrav1.MicrovmReplicaSet,
+) (int32, error) {
+	// externally managed replicasets are left behind rather than deleted
+	if replica.IsExternal(rs) {
+		if err := r.releaseReplicaSet(ctx, mvmDeploymentScope, rs); err != nil {
+			mvmDeploymentScope.Error(err, "failed releasing microvmreplicaset", logging.ReplicaSetKey, rs.Name)
+
+			return rs.Status.Replicas, err
+		}
+
+		return 0, nil
+	}
+
+	// the replicaset releases its microvms when it is deleted, so that they
+	// outlive the deployment
+	if mvmDeploymentScope.OrphanOnDelete() {
+		if err := r.orphanReplicaSet(ctx, mvmDeploymentScope, rs); err != nil {
+			mvmDeploymentScope.Error(err, "failed orphaning microvmreplicaset", logging.ReplicaSetKey, rs.Name)
+
+			return rs.Status.Replicas, err
+		}
+	}
+
+	// if the object is already being deleted, skip this
+	if !rs.DeletionTimestamp.IsZero() {
+		return rs.Status.Replicas, nil
+	}
+
+	if err := r.Delete(ctx, rs); err != nil && !apierrors.IsNotFound(err) {
+		mvmDeploymentScope.Error(err, "failed deleting microvmreplicaset", logging.ReplicaSetKey, rs.Name)
+
+		return rs.Status.Replicas, fmt.Errorf("deleting microvmreplicaset %s: %w", rs.Name, err)
+	}
+
+	return rs.Status.Replicas, nil
+}
+
+// createReplicaSets creates the replicasets for every host of the plan which
+// does not yet have one, up to maxConcurrentReplicaSetCreates at a time, and
+// returns the errors from every create which failed.
+func (r *MicrovmDeploymentReconciler) createReplicaSets(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	plan scope.HostPlan,
+) error {
+	errs := parallel(len(plan.Create), maxConcurrentReplicaSetCreates, func(i int) error {
+		host := plan.Create[i]
+
+		if err := r.createReplicaSet(ctx, mvmDeploymentScope, host, plan.Replicas[host.Endpoint]); err != nil {
+			mvmDeploymentScope.Error(err, "failed creating owned microvmreplicaset", logging.HostKey, host.Endpoint)
+
+			return fmt.Errorf("creating replicaset for host %s: %w", host.Endpoint, err)
+		}
+
+		return nil
+	})
+
+	return kerrors.NewAggregate(errs)
+}
+
+func (r *MicrovmDeploymentReconciler) createReplicaSet(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	host microvm.Host,
+	replicas int32,
+) error {
+	tmpl := mvmDeploymentScope.MicrovmTemplate()
+
+	// the replicaset carries the template metadata which does not vary
+	// between replicas, along with where it came from
+	rsLabels := replica.StaticLabels(tmpl.Labels)
+	rsLabels[infrav1.MicrovmDeploymentNameLabel] = mvmDeploymentScope.Name()
+	rsLabels[infrav1.MicrovmReplicaSetHashLabel] = replica.TemplateHash(tmpl)
+	rsLabels[infrav1.HostEndpointLabel] = replica.LabelValue(host.Endpoint)
+
+	newRs := &infrav1.MicrovmReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:    mvmDeploymentScope.Namespace(),
+			GenerateName: "microvmreplicaset-",
+			Labels:       rsLabels,
+			Annotations:  replica.StaticLabels(tmpl.Annotations),
+		},
+		Spec: infrav1.MicrovmReplicaSetSpec{
+			Host:     host,
+			Replicas: pointer.Int32(replicas),
+			Selector: mvmDeploymentScope.MicrovmDeployment.Spec.Selector.DeepCopy(),
+			Template: tmpl,
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(mvmDeploymentScope.MicrovmDeployment, newRs, r.Scheme); err != nil {
+		return err
+	}
+
+	return r.Create(ctx, newRs, apply.FieldOwner)
+}
+
+func (r *MicrovmDeploymentReconciler) getOwnedReplicaSets(
+	ctx context.Context,
+	mvmDeploymentScope *scope.MicrovmDeploymentScope,
+	selector labels.Selector,
+) ([]infrav1.MicrovmReplicaSet, error) {
+	rsList := &infrav1.MicrovmReplicaSetList{}
+	opts := []client.ListOption{
+		client.InNamespace(mvmDeploymentScope.Namespace()),
+	}
+	if selector != nil {
+		opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
+	}
+	if r.indexed {
+		opts = append(opts, client.MatchingFields{controllerUIDField: string(mvmDeploymentScope.MicrovmDeployment.UID)})
+	}
+	if err := r.List(ctx, rsList, opts...); err != nil {
+		return nil, err
+	}
+
+	owned := []v1alpha1.MicrovmReplicaSet{}
+
+	for _, rs := range rsList.Items {
+		if isControlledBy(&rs, mvmDeploymentScope.MicrovmDeployment, "MicrovmDeployment") {
+			owned = append(owned, rs)
+		}
+	}
+
+	return owned, nil
+}
+
+// microvmToDeployment maps a Microvm to the MicrovmDeployment which controls
+// its MicrovmReplicaSet, so that deployments see microvm status changes
+// without waiting for the replicaset to be reconciled first.
+func (r *MicrovmDeploymentReconciler) microvmToDeployment(obj client.Object) []reconcile.Request {
+	rsRef := metav1.GetControllerOf(obj)
+	if rsRef == nil || rsRef.Kind != "MicrovmReplicaSet" {
+		return nil
+	}
+
+	rs := &infrav1.MicrovmReplicaSet{}
+	if err := r.Get(context.Background(), client.ObjectKey{Namespace: obj.GetNamespace(), Name: rsRef.Name}, rs); err != nil {
+		return nil
+	}
+
+	// a replicaset which has since been replaced by another of the same name
+	// no longer has anything to do with the microvm
+	if rs.UID != rsRef.UID {
+		return nil
+	}
+
+	deploymentRef := metav1.GetControllerOf(rs)
+	if deploymentRef == nil || deploymentRef.Kind != "MicrovmDeployment" {
+		return nil
+	}
+
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: rs.Namespace, Name: deploymentRef.Name},
+	}}
+}
+
+// hostToDeployments maps a MicrovmHost to the MicrovmDeployments which place
+// replicas on it, so that cordoning a host takes effect immediately, and to
+// every MicrovmDeployment with a host selector.
+func (r *MicrovmDeploymentReconciler) hostToDeployments(obj client.Object) []reconcile.Request {
+	host, ok := obj.(*infrav1.MicrovmHost)
+	if !ok {
+		return nil
+	}
+
+	deployments := &infrastructurev1alpha1.MicrovmDeploymentList{}
+	if err := r.List(context.Background(), deployments); err != nil {
+		return nil
+	}
+
+	groups := &infrav1.MicrovmHostGroupList{}
+	if err := r.List(context.Background(), groups); err != nil {
+		return nil
+	}
+
+	groupHosts := map[types.NamespacedName][]microvm.Host{}
+	for _, group := range groups.Items {
+		groupHosts[types.NamespacedName{Namespace: group.Namespace, Name: group.Name}] = group.Status.Hosts
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, md := range deployments.Items {
+		// a deployment with a host selector may gain or lose the host when its
+		// labels change
+		if md.Spec.HostGroupRef == nil && md.Spec.HostSelector != nil {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: md.Namespace, Name: md.Name},
+			})
+
+			continue
+		}
+
+		hosts := md.Spec.Hosts
+		if ref := md.Spec.HostGroupRef; ref != nil {
+			hosts = groupHosts[types.NamespacedName{Namespace: md.Namespace, Name: ref.Name}]
+		}
+
+		for _, h := range hosts {
+			if h.Endpoint == host.Spec.Endpoint {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: md.Namespace, Name: md.Name},
+				})
+
+				break
+			}
+		}
+	}
+
+	return requests
+}
+
+// hostGroupToDeployments maps a MicrovmHostGroup to the MicrovmDeployments
+// which use it, so that changes to its members are picked up straight away.
+func (r *MicrovmDeploymentReconciler) hostGroupToDeployments(obj client.Object) []reconcile.Request {
+	deployments := &infrastructurev1alpha1.MicrovmDeploymentList{}
+	if err := r.List(context.Background(), deployments, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, md := range deployments.Items {
+		if ref := md.Spec.HostGroupRef; ref != nil && ref.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: md.Namespace, Name: md.Name},
+			})
+		}
+	}
+
+	return requests
+}
+
+// templateToDeployments maps a MicrovmTemplate to the MicrovmDeployments which
+// reference it, so that new revisions are rolled out straight away.
+func (r *MicrovmDeploymentReconciler) templateToDeployments(obj client.Object) []reconcile.Request {
+	deployments := &infrastructurev1alpha1.MicrovmDeploymentList{}
+	if err := r.List(context.Background(), deployments, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, md := range deployments.Items {
+		if ref := md.Spec.TemplateRef; ref != nil && ref.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: md.Namespace, Name: md.Name},
+			})
+		}
+	}
+
+	return requests
+}
+
+// requeuePeriod returns how long to wait before checking on an object which
+// is still converging.
+func (r *MicrovmDeploymentReconciler) requeuePeriod() time.Duration {
+	return r.Config.RequeuePeriod(config.MicrovmDeployment)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MicrovmDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := indexByControllerUID(mgr, &infrav1.MicrovmReplicaSet{}); err != nil {
+		return fmt.Errorf("indexing microvmreplicasets by controller: %w", err)
+	}
+
+	if err := indexReplicaSetsByHostEndpoint(mgr); err != nil {
+		return fmt.Errorf("indexing microvmreplicasets by host: %w", err)
+	}
+
+	r.indexed = true
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&infrastructurev1alpha1.MicrovmDeployment{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvmdeployment", logging.DeploymentKey)).
 		Owns(&infrav1.MicrovmReplicaSet{}).
-		Complete(r)
+		Watches(
+			&source.Kind{Type: &infrav1.Microvm{}},
+			handler.EnqueueRequestsFromMapFunc(r.microvmToDeployment),
+		).
+		Watches(
+			&source.Kind{Type: &infrav1.MicrovmHost{}},
+			handler.EnqueueRequestsFromMapFunc(r.hostToDeployments),
+		).
+		Watches(
+			&source.Kind{Type: &infrav1.MicrovmHostGroup{}},
+			handler.EnqueueRequestsFromMapFunc(r.hostGroupToDeployments),
+		).
+		Watches(
+			&source.Kind{Type: &infrav1.MicrovmTemplate{}},
+			handler.EnqueueRequestsFromMapFunc(r.templateToDeployments),
+		).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(tracing.Reconciler("microvmdeployment", r))
 }
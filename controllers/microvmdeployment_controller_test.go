@@ -2,12 +2,26 @@ package controllers_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/heartbeat"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func TestMicrovmDep_Reconcile_MissingObject(t *testing.T) {
@@ -48,22 +62,21 @@ func TestMicrovmDep_ReconcileNormal_CreateSucceeds(t *testing.T) {
 	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentIncompleteReason)
 	g.Expect(reconciled.Status.Ready).To(BeFalse(), "MicrovmDeployment should not be ready yet")
 	g.Expect(reconciled.Status.Replicas).To(Equal(int32(0)), "Expected the record to not have been updated yet")
-	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(expectedReplicaSets-1), "Expected only one replicaset to have been created after one reconciliation")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(expectedReplicaSets), "Expected all replicasets to have been created after one reconciliation")
 
 	// second reconciliation
 	ensureMicrovmReplicaSetState(g, client, expectedReplicas, expectedReplicas-1)
-	g.Expect(err).NotTo(HaveOccurred(), "reconciling microvmReplicaSet should not error")
 	result, err = reconcileMicrovmDeployment(client)
 	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment the second time should not error")
-	g.Expect(result.IsZero()).To(BeFalse(), "Expect requeue to be requested after create")
+	g.Expect(result.IsZero()).To(BeFalse(), "Expect requeue to be requested while microvms are not ready")
 
 	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
 	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
 
 	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentIncompleteReason)
 	g.Expect(reconciled.Status.Ready).To(BeFalse(), "MicrovmDeployment should not be ready yet")
-	g.Expect(reconciled.Status.Replicas).To(Equal(expectedTotalMicrovms-2), "Expected the record to contain 2 replicas")
-	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(expectedReplicaSets), "Expected all Microvms to have been created after two reconciliations")
+	g.Expect(reconciled.Status.Replicas).To(Equal(expectedTotalMicrovms), "Expected the record to contain 4 replicas")
+	g.Expect(reconciled.Status.ReadyReplicas).To(Equal(expectedTotalMicrovms-2), "Expected the record to contain 2 ready replicas")
 
 	// final reconciliation
 	ensureMicrovmReplicaSetState(g, client, expectedReplicas, expectedReplicas)
@@ -78,10 +91,72 @@ func TestMicrovmDep_ReconcileNormal_CreateSucceeds(t *testing.T) {
 	g.Expect(reconciled.Status.Ready).To(BeTrue(), "MicrovmDeployment should be ready now")
 	g.Expect(reconciled.Status.Replicas).To(Equal(expectedTotalMicrovms), "Expected the record to contain 4 replicas")
 	g.Expect(reconciled.Status.ReadyReplicas).To(Equal(expectedTotalMicrovms), "Expected all replicas to be ready")
-	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(expectedReplicaSets), "Expected all Microvms to have been created after two reconciliations")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(expectedReplicaSets), "Expected no further replicasets to have been created")
 	assertOneSetPerHost(g, reconciled, client)
 }
 
+func TestMicrovmDep_ReconcileNormal_SummarisesConditions(t *testing.T) {
+	g := NewWithT(t)
+
+	var (
+		expectedReplicas    int32 = 2
+		expectedReplicaSets int   = 2
+	)
+
+	mvmD := createMicrovmDeployment(expectedReplicas, expectedReplicaSets)
+	objects := []runtime.Object{mvmD}
+	client := createFakeClient(g, objects)
+
+	g.Expect(reconcileMicrovmDeploymentNTimes(g, client, expectedReplicaSets+1, expectedReplicas, expectedReplicas)).To(Succeed())
+
+	// one of the replicasets reports why it is not ready
+	rsList, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for i := range rsList.Items {
+		rs := &rsList.Items[i]
+		if i == 0 {
+			conditions.MarkFalse(rs, infrav1.MicrovmReplicaSetReadyCondition, infrav1.MicrovmReplicaSetProvisionFailedReason,
+				clusterv1.ConditionSeverityError, "microvm failed")
+		} else {
+			conditions.MarkTrue(rs, infrav1.MicrovmReplicaSetReadyCondition)
+		}
+		g.Expect(client.Update(context.TODO(), rs)).To(Succeed())
+	}
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReplicasReadyCondition, infrav1.MicrovmReplicaSetProvisionFailedReason)
+	assertConditionTrue(g, reconciled, infrav1.MicrovmDeploymentHostsHealthyCondition)
+	assertConditionTrue(g, reconciled, infrav1.MicrovmDeploymentTemplateUpToDateCondition)
+	assertConditionFalse(g, reconciled, clusterv1.ReadyCondition, infrav1.MicrovmReplicaSetProvisionFailedReason)
+	g.Expect(conditions.GetMessage(reconciled, infrav1.MicrovmDeploymentReplicasReadyCondition)).To(Equal("1 of 2 completed"))
+
+	// and the summary is ready once it recovers
+	ensureMicrovmReplicaSetState(g, client, expectedReplicas, expectedReplicas)
+
+	rsList, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for i := range rsList.Items {
+		conditions.MarkTrue(&rsList.Items[i], infrav1.MicrovmReplicaSetReadyCondition)
+		g.Expect(client.Update(context.TODO(), &rsList.Items[i])).To(Succeed())
+	}
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+
+	assertConditionTrue(g, reconciled, infrav1.MicrovmDeploymentReplicasReadyCondition)
+	assertConditionTrue(g, reconciled, clusterv1.ReadyCondition)
+}
+
 func TestMicrovmDep_ReconcileNormal_UpdateSucceeds(t *testing.T) {
 	g := NewWithT(t)
 
@@ -143,6 +218,77 @@ func TestMicrovmDep_ReconcileNormal_UpdateSucceeds(t *testing.T) {
 	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(int(scaledReplicaSetCount)), "Expected replicasets to have been scaled down after two reconciliations")
 }
 
+func TestMicrovmDep_ReconcileNormal_CreatesReplicaSetsForAllHosts(t *testing.T) {
+	g := NewWithT(t)
+
+	// more hosts than are created at once
+	var (
+		replicas  int32 = 1
+		hostCount int   = 20
+	)
+
+	mvmD := createMicrovmDeployment(replicas, hostCount)
+	objects := []runtime.Object{mvmD}
+	client := createFakeClient(g, objects)
+
+	result, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment the first time should not error")
+	g.Expect(result.IsZero()).To(BeFalse(), "Expect requeue to be requested after create")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(hostCount), "Expected a replicaset for every host after one reconciliation")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentIncompleteReason)
+	assertOneSetPerHost(g, reconciled, client)
+}
+
+func TestMicrovmDep_ReconcileNormal_HostListChangeConvergesInOnePass(t *testing.T) {
+	g := NewWithT(t)
+
+	var (
+		initialHostCount int   = 3
+		replicas         int32 = 2
+	)
+
+	mvmD := createMicrovmDeployment(replicas, initialHostCount)
+	objects := []runtime.Object{mvmD}
+	client := createFakeClient(g, objects)
+
+	// create
+	g.Expect(reconcileMicrovmDeploymentNTimes(g, client, 2, replicas, replicas)).To(Succeed())
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(initialHostCount), "Expected 3 replicasets to exist")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+
+	// drop two hosts, add three more and change the per-host replica count
+	reconciled.Spec.Hosts = []microvm.Host{
+		{Endpoint: "1.2.3.4:9090"},
+		{Endpoint: "5.6.7.8:9090"},
+		{Endpoint: "5.6.7.8:9091"},
+		{Endpoint: "5.6.7.8:9092"},
+	}
+	reconciled.Spec.Replicas = pointer.Int32(replicas + 1)
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	result, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment after a host list edit should not error")
+	g.Expect(result.IsZero()).To(BeFalse(), "Expect requeue to be requested after update")
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+
+	assertOneSetPerHost(g, reconciled, client)
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for _, rs := range sets.Items {
+		g.Expect(*rs.Spec.Replicas).To(Equal(replicas+1), "Expected every replicaset to have been rescaled")
+	}
+}
+
 func TestMicrovmDep_ReconcileDelete_DeleteSucceeds(t *testing.T) {
 	g := NewWithT(t)
 
@@ -195,3 +341,1112 @@ func TestMicrovmDep_ReconcileDelete_DeleteSucceeds(t *testing.T) {
 	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
 	g.Expect(err).To(HaveOccurred(), "Getting microvmdeployment should fail")
 }
+
+func TestMicrovmDep_ReconcileDelete_OrphanPropagatesToReplicaSets(t *testing.T) {
+	g := NewWithT(t)
+
+	var (
+		initialReplicaSetCount int   = 2
+		expectedReplicas       int32 = 2
+	)
+
+	mvmD := createMicrovmDeployment(expectedReplicas, initialReplicaSetCount)
+	mvmD.Spec.DeletePolicy = infrav1.DeletePolicyOrphan
+	client := createFakeClient(g, []runtime.Object{mvmD})
+
+	// create
+	g.Expect(reconcileMicrovmDeploymentNTimes(g, client, initialReplicaSetCount+1, expectedReplicas, expectedReplicas)).To(Succeed())
+
+	// hold the replicasets as their controller would while releasing microvms
+	rsList, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for _, rs := range rsList.Items {
+		rs.Finalizers = []string{infrav1.MvmRSFinalizer}
+		g.Expect(client.Update(context.TODO(), &rs)).To(Succeed())
+	}
+
+	// delete
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.Delete(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	rsList, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rsList.Items).To(HaveLen(initialReplicaSetCount))
+
+	for _, rs := range rsList.Items {
+		g.Expect(rs.DeletionTimestamp.IsZero()).To(BeFalse(), "Expected the replicaset to be deleted")
+		g.Expect(rs.Spec.DeletePolicy).To(Equal(infrav1.DeletePolicyOrphan), "Expected the replicaset to orphan its microvms")
+	}
+}
+
+func TestMicrovmDep_ReconcileNormal_SpreadRebalancesOnHostAdd(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(6, 2)
+	mvmD.Spec.SpreadConstraints = &infrav1.SpreadConstraints{MaxSkew: 1}
+	objects := []runtime.Object{mvmD}
+	client := createFakeClient(g, objects)
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(2))
+
+	for _, rs := range sets.Items {
+		g.Expect(*rs.Spec.Replicas).To(Equal(int32(3)), "Expected the total replicas to be split across hosts")
+	}
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+
+	reconciled.Spec.Hosts = append(reconciled.Spec.Hosts, microvm.Host{Endpoint: "5.6.7.8:9090"})
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment after adding a host should not error")
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(3))
+
+	for _, rs := range sets.Items {
+		g.Expect(*rs.Spec.Replicas).To(Equal(int32(2)), "Expected replicas to be rebalanced onto the new host")
+	}
+}
+
+func TestMicrovmDep_ReconcileNormal_PlacementOverrides(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(4, 2)
+	mvmD.Spec.SpreadConstraints = &infrav1.SpreadConstraints{MaxSkew: 1}
+	mvmD.Spec.PlacementOverrides = []infrav1.PlacementOverride{
+		{Replica: 0, Host: mvmD.Spec.Hosts[1].Endpoint},
+		{Replica: 1, Host: mvmD.Spec.Hosts[1].Endpoint},
+	}
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	perHost := map[string]int32{}
+	for _, rs := range sets.Items {
+		perHost[rs.Spec.Host.Endpoint] = *rs.Spec.Replicas
+	}
+
+	g.Expect(perHost).To(Equal(map[string]int32{
+		mvmD.Spec.Hosts[0].Endpoint: 1,
+		mvmD.Spec.Hosts[1].Endpoint: 3,
+	}), "Expected the pinned replicas to be placed on their host and the rest spread")
+
+	// pin a replica to a host the deployment does not use
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+
+	reconciled.Spec.PlacementOverrides[1].Host = "9.9.9.9:9090"
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentSpreadCondition,
+		infrav1.MicrovmDeploymentPlacementOverrideUnschedulableReason)
+}
+
+func TestMicrovmDep_ReconcileNormal_ObservedGeneration(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 1)
+	mvmD.Generation = 3
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+	g.Expect(reconciled.Status.ObservedGeneration).To(Equal(int64(3)))
+}
+
+func TestMicrovmDep_ReconcileNormal_TemplateRef(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 1)
+	mvmD.Spec.TemplateRef = &corev1.LocalObjectReference{Name: testMicrovmTemplateName}
+
+	mvmT := createMicrovmTemplate(testTemplateReference)
+
+	client := createFakeClient(g, []runtime.Object{mvmD, mvmT})
+	result, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling with an unresolved template should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect requeue to be requested")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(0), "Expected no replicasets before the template is resolved")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentTemplateNotReadyReason)
+
+	fetcher := &fakeFetcher{content: []byte(testTemplateContent)}
+	_, err = reconcileMicrovmTemplate(client, fetcher.fetcherFunc())
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmtemplate should not error")
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling with a resolved template should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(1))
+	g.Expect(sets.Items[0].Spec.Template.Spec.VCPU).To(Equal(int64(4)), "Expected the replicaset to use the resolved template")
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+	g.Expect(reconciled.Status.TemplateRevision).To(Equal(int64(1)), "Expected the revision of the template to be recorded")
+}
+
+func TestMicrovmDep_ReconcileNormal_Selector(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 1)
+	mvmD.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	mvmD.Spec.Template.Labels = map[string]string{
+		"app":     "web",
+		"replica": "{{ .ReplicaIndex }}",
+	}
+
+	unselected := createMicrovmReplicaSet(1)
+	unselected.Name = "unselected"
+	unselected.Labels = map[string]string{"app": "db"}
+	unselected.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(mvmD, infrav1.GroupVersion.WithKind("MicrovmDeployment")),
+	}
+
+	client := createFakeClient(g, []runtime.Object{mvmD, unselected})
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(2), "Expected a replicaset to be created as the existing one is not selected")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.Selector).To(Equal("app=web"), "Expected the selector to be recorded for scaling")
+
+	for _, rs := range sets.Items {
+		if rs.Name == unselected.Name {
+			continue
+		}
+
+		g.Expect(rs.Labels).To(HaveKeyWithValue("app", "web"), "Expected the static template labels to be copied")
+		g.Expect(rs.Labels).NotTo(HaveKey("replica"), "Expected the templated labels not to be copied")
+		g.Expect(rs.Spec.Selector).To(Equal(mvmD.Spec.Selector))
+		g.Expect(rs.Spec.Template.Labels).To(HaveKeyWithValue("replica", "{{ .ReplicaIndex }}"))
+	}
+}
+
+func TestMicrovmDep_ReconcileNormal_PropagatesTemplateMetadata(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 1)
+	mvmD.Spec.Template.Labels = map[string]string{"app": "web"}
+	mvmD.Spec.Template.Annotations = map[string]string{
+		"team":    "platform",
+		"replica": "{{ .ReplicaIndex }}",
+	}
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(1))
+
+	rs := sets.Items[0]
+	g.Expect(rs.Labels).To(HaveKeyWithValue("app", "web"))
+	g.Expect(rs.Labels).To(HaveKeyWithValue(infrav1.MicrovmDeploymentNameLabel, testMicrovmDeploymentName))
+	g.Expect(rs.Labels).To(HaveKeyWithValue(infrav1.HostEndpointLabel, "1.2.3.4-9090"))
+	g.Expect(rs.Labels).To(HaveKey(infrav1.MicrovmReplicaSetHashLabel))
+	g.Expect(rs.Annotations).To(Equal(map[string]string{"team": "platform"}), "Expected the static template annotations to be copied")
+}
+
+func TestMicrovmDep_ReconcileNormal_SelectorMustMatchTemplate(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 1)
+	mvmD.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	mvmD.Spec.Template.Labels = map[string]string{"app": "{{ .HostName }}"}
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentInvalidSelectorReason)
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(0), "Expected no replicasets to be created")
+}
+
+func TestMicrovmDep_ReconcileNormal_SpreadAcrossFailureDomains(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(4, 3)
+	mvmD.Spec.SpreadConstraints = &infrav1.SpreadConstraints{
+		MaxSkew:     1,
+		TopologyKey: infrav1.FailureDomainSpreadTopology,
+	}
+
+	objects := []runtime.Object{mvmD}
+
+	for i, domain := range []string{"a", "a", "b"} {
+		mvmH := createMicrovmHost()
+		mvmH.Name = mvmD.Spec.Hosts[i].Endpoint
+		mvmH.Spec.Endpoint = mvmD.Spec.Hosts[i].Endpoint
+		mvmH.Spec.FailureDomain = domain
+		objects = append(objects, mvmH)
+	}
+
+	client := createFakeClient(g, objects)
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	perHost := map[string]int32{}
+	for _, rs := range sets.Items {
+		perHost[rs.Spec.Host.Endpoint] = *rs.Spec.Replicas
+	}
+
+	g.Expect(perHost).To(Equal(map[string]int32{
+		mvmD.Spec.Hosts[0].Endpoint: 1,
+		mvmD.Spec.Hosts[1].Endpoint: 1,
+		mvmD.Spec.Hosts[2].Endpoint: 2,
+	}), "Expected the replicas to be split evenly between the failure domains")
+
+	// the replicas in failure domain b fail, leaving it empty
+	for i := range sets.Items {
+		rs := &sets.Items[i]
+		if rs.Spec.Host.Endpoint != mvmD.Spec.Hosts[2].Endpoint {
+			rs.Status.ReadyReplicas = *rs.Spec.Replicas
+		}
+
+		g.Expect(client.Status().Update(context.TODO(), rs)).To(Succeed())
+	}
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmdeployment should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentSpreadCondition, infrav1.MicrovmDeploymentSpreadSkewExceededReason)
+}
+
+func TestMicrovmDep_ReconcileNormal_DrainUnschedulableHost(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(2, 2)
+	mvmD.Spec.SpreadConstraints = &infrav1.SpreadConstraints{MaxSkew: 1}
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(2))
+
+	// cordon the second host
+	mvmH := createMicrovmHost()
+	mvmH.Spec.Endpoint = mvmD.Spec.Hosts[1].Endpoint
+	mvmH.Spec.Unschedulable = true
+	g.Expect(client.Create(context.TODO(), mvmH)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(2), "Expected the cordoned host's replicaset to be kept while draining")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentDrainingReason)
+
+	// the replicas are recreated on the first host and become ready
+	for i := range sets.Items {
+		rs := &sets.Items[i]
+		if rs.Spec.Host.Endpoint == mvmD.Spec.Hosts[0].Endpoint {
+			g.Expect(*rs.Spec.Replicas).To(Equal(int32(2)), "Expected the first host to take on every replica")
+		}
+
+		rs.Status.ReadyReplicas = *rs.Spec.Replicas
+		g.Expect(client.Status().Update(context.TODO(), rs)).To(Succeed())
+	}
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(1), "Expected the cordoned host's replicaset to be removed once drained")
+	g.Expect(sets.Items[0].Spec.Host.Endpoint).To(Equal(mvmD.Spec.Hosts[0].Endpoint))
+}
+
+func TestMicrovmDep_ReconcileNormal_FailoverUnreachableHost(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(2, 2)
+	mvmD.Spec.FailoverPolicy = &infrav1.FailoverPolicy{UnreachableSeconds: 60}
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(2))
+
+	// the second host stops answering, but not for long enough to fail over
+	since := metav1.NewTime(time.Now().Add(-30 * time.Second))
+	mvmH := createMicrovmHost()
+	mvmH.Spec.Endpoint = mvmD.Spec.Hosts[1].Endpoint
+	g.Expect(client.Create(context.TODO(), mvmH)).To(Succeed())
+	mvmH.Status.UnreachableSince = &since
+	g.Expect(client.Status().Update(context.TODO(), mvmH)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for _, rs := range sets.Items {
+		g.Expect(*rs.Spec.Replicas).To(Equal(int32(2)), "Expected no failover before the threshold")
+	}
+
+	// once it has been unreachable for long enough its replicas move to the first host
+	since = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	mvmH.Status.UnreachableSince = &since
+	g.Expect(client.Status().Update(context.TODO(), mvmH)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentFailingOverReason)
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(2), "Expected the unreachable host's replicaset to be kept until its replicas are recreated")
+
+	for i := range sets.Items {
+		rs := &sets.Items[i]
+		if rs.Spec.Host.Endpoint == mvmD.Spec.Hosts[0].Endpoint {
+			g.Expect(*rs.Spec.Replicas).To(Equal(int32(4)), "Expected the first host to take on the failed host's replicas")
+			rs.Status.ReadyReplicas = *rs.Spec.Replicas
+			g.Expect(client.Status().Update(context.TODO(), rs)).To(Succeed())
+		}
+	}
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(1), "Expected the unreachable host's replicaset to be removed")
+	g.Expect(sets.Items[0].Spec.Host.Endpoint).To(Equal(mvmD.Spec.Hosts[0].Endpoint))
+}
+
+func TestMicrovmDep_ReconcileNormal_HostGroup(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 0)
+	mvmD.Spec.HostGroupRef = &corev1.LocalObjectReference{Name: testMicrovmHostGroupName}
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(BeZero(), "Expected nothing to be created without the group")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentHostGroupNotReadyReason)
+
+	group := createMicrovmHostGroup()
+	group.Spec.TLSSecretRef = "group-tls"
+	group.Spec.Labels = map[string]string{"pool": "edge"}
+	group.Status.Ready = true
+	group.Status.Hosts = []microvm.Host{{Endpoint: "1.1.1.1:9090"}, {Endpoint: "2.2.2.2:9090"}}
+	group.Status.FullHosts = []string{"2.2.2.2:9090"}
+	g.Expect(client.Create(context.TODO(), group)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(1), "Expected no replicaset on the full host")
+
+	rs := sets.Items[0]
+	g.Expect(rs.Spec.Host.Endpoint).To(Equal("1.1.1.1:9090"))
+	g.Expect(rs.Spec.Template.Spec.TLSSecretRef).To(Equal("group-tls"))
+	g.Expect(rs.Spec.Template.Labels).To(HaveKeyWithValue("pool", "edge"))
+}
+
+func TestMicrovmDep_ReconcileNormal_HostSelector(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 0)
+	mvmD.Spec.Hosts = []microvm.Host{{Endpoint: "9.9.9.9:9090"}}
+	mvmD.Spec.HostSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "edge"}}
+
+	edge1 := createMicrovmHost()
+	edge1.Name = "edge1"
+	edge1.Labels = map[string]string{"pool": "edge"}
+	edge1.Spec.Endpoint = "1.1.1.1:9090"
+
+	edge2 := createMicrovmHost()
+	edge2.Name = "edge2"
+	edge2.Labels = map[string]string{"pool": "edge"}
+	edge2.Spec.Endpoint = "2.2.2.2:9090"
+
+	core := createMicrovmHost()
+	core.Name = "core"
+	core.Labels = map[string]string{"pool": "core"}
+	core.Spec.Endpoint = "3.3.3.3:9090"
+
+	client := createFakeClient(g, []runtime.Object{mvmD, edge1, edge2, core})
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(setHosts(sets.Items)).To(ConsistOf("1.1.1.1:9090", "2.2.2.2:9090"),
+		"Expected a replicaset on each selected host and none on the listed one")
+
+	// a host which no longer matches loses its replicaset
+	edge2.Labels = map[string]string{"pool": "core"}
+	g.Expect(client.Update(context.TODO(), edge2)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(setHosts(sets.Items)).To(ConsistOf("1.1.1.1:9090"))
+
+	// and a host which comes to match gains one
+	core.Labels = map[string]string{"pool": "edge"}
+	g.Expect(client.Update(context.TODO(), core)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(setHosts(sets.Items)).To(ConsistOf("1.1.1.1:9090", "3.3.3.3:9090"))
+}
+
+func TestMicrovmDep_ReconcileNormal_InvalidHostSelector(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 1)
+	mvmD.Spec.HostSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "pool", Operator: "Bogus"}},
+	}
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(BeZero(), "Expected nothing to be created with an invalid host selector")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentInvalidHostSelectorReason)
+}
+
+func TestMicrovmDep_ReconcileNormal_PreemptsLowerPriority(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 0)
+	mvmD.Spec.HostGroupRef = &corev1.LocalObjectReference{Name: testMicrovmHostGroupName}
+	mvmD.Spec.Template.Spec.Priority = 10
+
+	group := createMicrovmHostGroup()
+	group.Status.Ready = true
+	group.Status.Hosts = []microvm.Host{{Endpoint: "1.1.1.1:9090"}, {Endpoint: "2.2.2.2:9090"}}
+	group.Status.FullHosts = []string{"2.2.2.2:9090"}
+
+	// the full host runs the replicasets of two lower priority deployments
+	low := createMicrovmDeployment(1, 0)
+	low.Name = "low"
+	low.UID = "low-uid"
+
+	lowest := createMicrovmReplicaSet(1)
+	lowest.Name = "lowest-set"
+	lowest.Spec.Host.Endpoint = "2.2.2.2:9090"
+	lowest.Spec.Template.Spec.Priority = 1
+	lowest.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(low, infrav1.GroupVersion.WithKind("MicrovmDeployment")),
+	}
+
+	lower := lowest.DeepCopy()
+	lower.Name = "lower-set"
+	lower.Spec.Template.Spec.Priority = 5
+
+	client := createFakeClient(g, []runtime.Object{mvmD, group, lowest, lower})
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	preempted := &infrav1.MicrovmReplicaSet{}
+	g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "lowest-set", Namespace: testNamespace}, preempted)).To(Succeed())
+	g.Expect(preempted.Annotations).To(HaveKeyWithValue(infrav1.PreemptedByAnnotation, testNamespace+"/"+testMicrovmDeploymentName))
+
+	kept := &infrav1.MicrovmReplicaSet{}
+	g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "lower-set", Namespace: testNamespace}, kept)).To(Succeed())
+	g.Expect(kept.Annotations).NotTo(HaveKey(infrav1.PreemptedByAnnotation), "Expected one replicaset to be preempted at a time")
+
+	// nothing of equal or higher priority is preempted
+	preempted.Annotations = nil
+	g.Expect(client.Update(context.TODO(), preempted)).To(Succeed())
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	reconciled.Spec.Template.Spec.Priority = 1
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "lowest-set", Namespace: testNamespace}, preempted)).To(Succeed())
+	g.Expect(preempted.Annotations).NotTo(HaveKey(infrav1.PreemptedByAnnotation))
+}
+
+func TestMicrovmDep_ReconcileNormal_PreemptsOnlyWithinNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 0)
+	mvmD.Spec.HostGroupRef = &corev1.LocalObjectReference{Name: testMicrovmHostGroupName}
+	mvmD.Spec.Template.Spec.Priority = 10
+
+	group := createMicrovmHostGroup()
+	group.Status.Ready = true
+	group.Status.Hosts = []microvm.Host{{Endpoint: "1.1.1.1:9090"}, {Endpoint: "2.2.2.2:9090"}}
+	group.Status.FullHosts = []string{"2.2.2.2:9090"}
+
+	// the full host runs the lowest priority replicaset in another tenant's
+	// namespace, and a higher one in the deployment's own
+	tenant := createMicrovmDeployment(1, 0)
+	tenant.Name = "tenant"
+	tenant.Namespace = "tenant-ns"
+	tenant.UID = "tenant-uid"
+
+	foreign := createMicrovmReplicaSet(1)
+	foreign.Name = "foreign-set"
+	foreign.Namespace = "tenant-ns"
+	foreign.Spec.Host.Endpoint = "2.2.2.2:9090"
+	foreign.Spec.Template.Spec.Priority = 1
+	foreign.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(tenant, infrav1.GroupVersion.WithKind("MicrovmDeployment")),
+	}
+
+	low := createMicrovmDeployment(1, 0)
+	low.Name = "low"
+	low.UID = "low-uid"
+
+	local := foreign.DeepCopy()
+	local.Name = "local-set"
+	local.Namespace = testNamespace
+	local.Spec.Template.Spec.Priority = 5
+	local.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(low, infrav1.GroupVersion.WithKind("MicrovmDeployment")),
+	}
+
+	client := createFakeClient(g, []runtime.Object{mvmD, group, foreign, local})
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	kept := &infrav1.MicrovmReplicaSet{}
+	g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "foreign-set", Namespace: "tenant-ns"}, kept)).To(Succeed())
+	g.Expect(kept.Annotations).NotTo(HaveKey(infrav1.PreemptedByAnnotation),
+		"Expected a replicaset in another namespace never to be preempted")
+
+	preempted := &infrav1.MicrovmReplicaSet{}
+	g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "local-set", Namespace: testNamespace}, preempted)).To(Succeed())
+	g.Expect(preempted.Annotations).To(HaveKeyWithValue(infrav1.PreemptedByAnnotation, testNamespace+"/"+testMicrovmDeploymentName))
+}
+
+func TestMicrovmDep_ReconcileNormal_RecreatesPreemptedReplicas(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(2, 2)
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(2))
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// a higher priority deployment preempts the second host's replicaset
+	for i := range sets.Items {
+		rs := &sets.Items[i]
+		if rs.Spec.Host.Endpoint == mvmD.Spec.Hosts[1].Endpoint {
+			rs.Annotations = map[string]string{infrav1.PreemptedByAnnotation: testNamespace + "/critical"}
+			g.Expect(client.Update(context.TODO(), rs)).To(Succeed())
+		}
+	}
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentPreemptedReason)
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(2), "Expected the preempted replicaset to be kept until its replicas are recreated")
+
+	for i := range sets.Items {
+		rs := &sets.Items[i]
+		if rs.Spec.Host.Endpoint == mvmD.Spec.Hosts[0].Endpoint {
+			g.Expect(*rs.Spec.Replicas).To(Equal(int32(4)), "Expected the first host to take on the preempted replicas")
+			rs.Status.ReadyReplicas = *rs.Spec.Replicas
+			g.Expect(client.Status().Update(context.TODO(), rs)).To(Succeed())
+		}
+	}
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(1), "Expected the preempted replicaset to be removed")
+	g.Expect(sets.Items[0].Spec.Host.Endpoint).To(Equal(mvmD.Spec.Hosts[0].Endpoint))
+}
+
+func TestMicrovmDep_ReconcileNormal_CanaryRollout(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 3)
+	canary := mvmD.Spec.Hosts[1].Endpoint
+	mvmD.Spec.Rollout = &infrav1.RolloutStrategy{
+		Canary: &infrav1.CanaryRollout{Host: canary},
+	}
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+	g.Expect(reconcileMicrovmDeploymentNTimes(g, client, 2, 1, 1)).To(Succeed())
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionTrue(g, reconciled, infrav1.MicrovmDeploymentReadyCondition)
+
+	// change the template
+	reconciled.Spec.Template.Spec.VCPU = 4
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(4), "Expected only the canary host to be updated first")
+	g.Expect(updatedHosts(sets.Items, 4)).To(ConsistOf(canary))
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentRollingOutReason)
+	g.Expect(reconciled.Status.Rollout.Phase).To(Equal(infrav1.RolloutPhaseCanary))
+
+	// once the canary is ready, the remaining hosts are updated
+	ensureMicrovmReplicaSetState(g, client, 1, 1)
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(6), "Expected the outdated replicasets to be kept until every host is updated")
+	g.Expect(updatedHosts(sets.Items, 4)).To(ConsistOf(mvmD.Spec.Hosts[0].Endpoint, canary, mvmD.Spec.Hosts[2].Endpoint))
+
+	// once every host is updated, the outdated replicasets are removed
+	ensureMicrovmReplicaSetState(g, client, 1, 1)
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(3))
+	g.Expect(updatedHosts(sets.Items, 4)).To(HaveLen(3))
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionTrue(g, reconciled, infrav1.MicrovmDeploymentReadyCondition)
+	g.Expect(reconciled.Status.Rollout.Phase).To(Equal(infrav1.RolloutPhaseComplete))
+}
+
+func TestMicrovmDep_ReconcileNormal_CanaryRollback(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 2)
+	mvmD.Spec.Rollout = &infrav1.RolloutStrategy{
+		Canary: &infrav1.CanaryRollout{Host: mvmD.Spec.Hosts[0].Endpoint, SoakSeconds: 300},
+	}
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+	g.Expect(reconcileMicrovmDeploymentNTimes(g, client, 2, 1, 1)).To(Succeed())
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	reconciled.Spec.Template.Spec.VCPU = 4
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	// the canary is ready, so is soaked before the other host is updated
+	ensureMicrovmReplicaSetState(g, client, 1, 1)
+
+	result, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically("~", 5*time.Minute, time.Second), "Expected to come back after the soak")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(3))
+
+	// the canary fails while soaking and the rollout is rolled back
+	for i := range sets.Items {
+		rs := &sets.Items[i]
+		if rs.Spec.Template.Spec.VCPU == 4 {
+			rs.Status.ReadyReplicas = 0
+			g.Expect(client.Update(context.TODO(), rs)).To(Succeed())
+		}
+	}
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	started := metav1.NewTime(time.Now().Add(-time.Hour))
+	reconciled.Status.Rollout.PhaseStartedAt = &started
+	g.Expect(client.Status().Update(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err = listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(2), "Expected the updated replicaset to be removed")
+	g.Expect(updatedHosts(sets.Items, 4)).To(BeEmpty())
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentRolledBackReason)
+	g.Expect(reconciled.Status.Rollout.Phase).To(Equal(infrav1.RolloutPhaseRolledBack))
+
+	// the template is not rolled out again until it changes
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(2))
+}
+
+func TestMicrovmDep_ReconcileNormal_ProgressDeadline(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(2, 2)
+	mvmD.Spec.ProgressDeadlineSeconds = pointer.Int32(60)
+	objects := []runtime.Object{mvmD}
+	client := createFakeClient(g, objects)
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionTrue(g, reconciled, infrav1.MicrovmDeploymentProgressingCondition)
+	g.Expect(reconciled.Status.Progress).NotTo(BeNil(), "Expected the progress to be recorded")
+
+	// no microvms become ready before the deadline
+	reconciled.Status.Progress.LastProgressTime = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	g.Expect(client.Status().Update(context.TODO(), reconciled)).To(Succeed())
+
+	ensureMicrovmReplicaSetState(g, client, 2, 0)
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentProgressingCondition, infrav1.MicrovmDeploymentProgressDeadlineExceededReason)
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentIncompleteReason)
+
+	// a newly ready microvm is progress
+	ensureMicrovmReplicaSetState(g, client, 2, 1)
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionTrue(g, reconciled, infrav1.MicrovmDeploymentProgressingCondition)
+	g.Expect(reconciled.Status.Progress.ReadyReplicas).To(Equal(int32(2)))
+
+	// a complete deployment stops tracking its progress
+	ensureMicrovmReplicaSetState(g, client, 2, 2)
+	_, err = reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err = getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionTrue(g, reconciled, infrav1.MicrovmDeploymentProgressingCondition)
+	g.Expect(reconciled.Status.Progress).To(BeNil())
+}
+
+func TestMicrovmDep_ReconcileNormal_NoProgressDeadline(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(2, 2)
+	objects := []runtime.Object{mvmD}
+	client := createFakeClient(g, objects)
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions.Has(reconciled, infrav1.MicrovmDeploymentProgressingCondition)).To(BeFalse(),
+		"Expected progress not to be tracked without a deadline")
+	g.Expect(reconciled.Status.Progress).To(BeNil())
+}
+
+// setHosts returns the endpoints of the replicasets.
+func setHosts(sets []infrav1.MicrovmReplicaSet) []string {
+	hosts := []string{}
+
+	for _, rs := range sets {
+		hosts = append(hosts, rs.Spec.Host.Endpoint)
+	}
+
+	return hosts
+}
+
+// updatedHosts returns the endpoints of the replicasets whose template has vcpu.
+func updatedHosts(sets []infrav1.MicrovmReplicaSet, vcpu int64) []string {
+	hosts := []string{}
+
+	for _, rs := range sets {
+		if rs.Spec.Template.Spec.VCPU == vcpu {
+			hosts = append(hosts, rs.Spec.Host.Endpoint)
+		}
+	}
+
+	return hosts
+}
+
+func TestMicrovmDep_Reconcile_DryRun(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(2, 2)
+	mvmD.Annotations = map[string]string{infrav1.DryRunAnnotation: "true"}
+
+	client := createFakeClient(g, []runtime.Object{mvmD})
+
+	_, err := reconcileMicrovmDeployment(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment in dry-run mode should not error")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(0), "Expected no replicasets to be created in dry-run mode")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.DryRun).NotTo(BeNil())
+	g.Expect(reconciled.Status.DryRun.Actions).To(ConsistOf(
+		infrav1.PlannedAction{Verb: "Create", Target: "MicrovmReplicaSet/microvmreplicaset-*"},
+		infrav1.PlannedAction{Verb: "Create", Target: "MicrovmReplicaSet/microvmreplicaset-*"},
+	))
+}
+
+func TestMicrovmDep_ReconcileNormal_OutOfContactHost(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 2)
+	client := createFakeClient(g, []runtime.Object{mvmD})
+
+	cfg := config.NewStore(&configv1.OperatorConfiguration{
+		Flintlock: configv1.FlintlockConfiguration{ContactTimeout: metav1.Duration{Duration: 5 * time.Minute}},
+	})
+
+	now := time.Now()
+	heartbeats := heartbeat.NewRegistry()
+	heartbeats.Record("1.2.3.4:9090", true, now.Add(-time.Minute))
+	heartbeats.Record("1.2.3.4:9091", true, now.Add(-10*time.Minute))
+	heartbeats.Record("1.2.3.4:9091", false, now.Add(-9*time.Minute))
+
+	_, err := reconcileMicrovmDeploymentWithHeartbeats(client, cfg, heartbeats)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(1), "Expected no replicaset to be created on the host out of contact")
+	g.Expect(sets.Items[0].Spec.Host.Endpoint).To(Equal("1.2.3.4:9090"))
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.HostSummaries).To(HaveLen(2))
+	g.Expect(reconciled.Status.HostSummaries[0].LastSuccessfulContact.Time).To(BeTemporally("==", now.Add(-time.Minute).Truncate(time.Minute)))
+	g.Expect(reconciled.Status.HostSummaries[1].LastSuccessfulContact.Time).To(BeTemporally("==", now.Add(-10*time.Minute).Truncate(time.Minute)))
+
+	condition := conditions.Get(reconciled, infrav1.MicrovmDeploymentHostsHealthyCondition)
+	g.Expect(condition).NotTo(BeNil())
+	g.Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(condition.Message).To(Equal("out of contact: 1.2.3.4:9091"))
+}
+
+func TestMicrovmDep_ReconcileNormal_HostFailingWithinTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 2)
+	client := createFakeClient(g, []runtime.Object{mvmD})
+
+	cfg := config.NewStore(&configv1.OperatorConfiguration{
+		Flintlock: configv1.FlintlockConfiguration{ContactTimeout: metav1.Duration{Duration: 5 * time.Minute}},
+	})
+
+	heartbeats := heartbeat.NewRegistry()
+	heartbeats.Record("1.2.3.4:9091", false, time.Now().Add(-time.Minute))
+
+	_, err := reconcileMicrovmDeploymentWithHeartbeats(client, cfg, heartbeats)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error")
+	g.Expect(microvmReplicaSetsCreated(g, client)).To(Equal(2), "Expected a host to be used until it has been out of contact for the timeout")
+
+	reconciled, err := getMicrovmDeployment(client, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.HostSummaries[1].LastSuccessfulContact).To(BeNil(), "Expected no contact to be reported for a host which never answered")
+}
+
+// failingDeleteClient fails to delete the named objects.
+type failingDeleteClient struct {
+	client.Client
+
+	failing map[string]bool
+}
+
+func (c *failingDeleteClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if c.failing[obj.GetName()] {
+		return errors.New("connection reset")
+	}
+
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func TestMicrovmDep_ReconcileDelete_DeleteFailuresAggregated(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmD := createMicrovmDeployment(1, 3)
+	fakeClient := createFakeClient(g, []runtime.Object{mvmD})
+
+	g.Expect(reconcileMicrovmDeploymentNTimes(g, fakeClient, 4, 1, 1)).To(Succeed())
+
+	sets, err := listMicrovmReplicaSet(fakeClient)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(3))
+
+	names := []string{sets.Items[0].Name, sets.Items[1].Name, sets.Items[2].Name}
+	sort.Strings(names)
+
+	reconciled, err := getMicrovmDeployment(fakeClient, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fakeClient.Delete(context.TODO(), reconciled)).To(Succeed())
+
+	failing := &failingDeleteClient{Client: fakeClient, failing: map[string]bool{names[0]: true, names[2]: true}}
+
+	result, err := reconcileMicrovmDeployment(failing)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmdeployment should not error when replicasets fail to delete")
+	g.Expect(result.RequeueAfter).NotTo(BeZero(), "Expected the delete to be retried")
+
+	remaining, err := listMicrovmReplicaSet(fakeClient)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(remaining.Items).To(ConsistOf(
+		HaveField("Name", names[0]),
+		HaveField("Name", names[2]),
+	), "Expected every other replicaset to be deleted")
+
+	reconciled, err = getMicrovmDeployment(fakeClient, testMicrovmDeploymentName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmDeploymentReadyCondition, infrav1.MicrovmDeploymentDeleteFailedReason)
+
+	condition := conditions.Get(reconciled, infrav1.MicrovmDeploymentReadyCondition)
+	g.Expect(condition.Message).To(Equal(fmt.Sprintf(
+		"[deleting microvmreplicaset %s: connection reset, deleting microvmreplicaset %s: connection reset]", names[0], names[2])))
+}
+
+func TestMicrovmDep_ReconcileNormal_SharedNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	first := createMicrovmDeployment(1, 2)
+
+	second := createMicrovmDeployment(1, 2)
+	second.Name = "d2"
+	second.UID = "d2-uid"
+
+	// left behind by an earlier deployment with the same name as the first
+	stale := createMicrovmReplicaSet(1)
+	stale.Name = "stale"
+	stale.Spec.Host.Endpoint = "1.2.3.4:9090"
+	stale.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: infrav1.GroupVersion.String(),
+		Kind:       "MicrovmDeployment",
+		Name:       testMicrovmDeploymentName,
+		UID:        "replaced-uid",
+		Controller: pointer.Bool(true),
+	}}
+
+	fakeClient := createFakeClient(g, []runtime.Object{first, second, stale})
+
+	_, err := reconcileMicrovmDeployment(fakeClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling the first microvmdeployment should not error")
+
+	_, err = reconcileMicrovmDeploymentNamed(fakeClient, "d2")
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling the second microvmdeployment should not error")
+
+	sets, err := listMicrovmReplicaSet(fakeClient)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(5), "Expected each deployment to create a replicaset on every host")
+
+	owners := map[types.UID]int{}
+	for i := range sets.Items {
+		owners[metav1.GetControllerOf(&sets.Items[i]).UID]++
+	}
+
+	g.Expect(owners).To(Equal(map[types.UID]int{testMicrovmDeploymentUID: 2, "d2-uid": 2, "replaced-uid": 1}))
+
+	// deleting one deployment leaves the replicasets of the other alone
+	reconciled, err := getMicrovmDeployment(fakeClient, "d2", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fakeClient.Delete(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmDeploymentNamed(fakeClient, "d2")
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling the deleted microvmdeployment should not error")
+
+	sets, err = listMicrovmReplicaSet(fakeClient)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sets.Items).To(HaveLen(3), "Expected only the deleted deployment's replicasets to be removed")
+}
@@ -0,0 +1,324 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/health"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+// MicrovmHostReconciler reconciles a MicrovmHost object
+type MicrovmHostReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Recorder holds the provisioning outcomes reported by the Microvm
+	// controller. It must be shared with the MicrovmReconciler.
+	Recorder *health.Recorder
+	// IdentityProber reads the TLS identity of each host so that it can be
+	// trusted on first use. Identities are not verified when it is nil.
+	IdentityProber identity.Prober
+
+	mu sync.Mutex
+	// reported maps each MicrovmHost name to the endpoint its metrics are
+	// published under, so they can be removed once the host is deleted.
+	reported map[string]string
+}
+
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhosts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhosts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhosts/finalizers,verbs=update
+
+func (r *MicrovmHostReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	mvmH := &infrav1.MicrovmHost{}
+	if err := r.Get(ctx, req.NamespacedName, mvmH); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.forget(req.Name)
+
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "error getting microvmhost")
+
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
+	}
+
+	log = log.WithValues(logging.HostKey, mvmH.Spec.Endpoint)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	mvmHostScope, err := scope.NewMicrovmHostScope(scope.MicrovmHostScopeParams{
+		MicrovmHost: mvmH,
+		Client:      r.Client,
+		Context:     ctx,
+		Logger:      log,
+	})
+	if err != nil {
+		log.Error(err, "failed to create mvm-host scope")
+
+		return ctrl.Result{}, fmt.Errorf("failed to create mvm-host scope: %w", err)
+	}
+
+	defer func() {
+		if err := mvmHostScope.Patch(); err != nil {
+			log.Error(err, "failed to patch microvmhost")
+		}
+	}()
+
+	if !mvmH.ObjectMeta.DeletionTimestamp.IsZero() {
+		r.forget(req.Name)
+
+		return ctrl.Result{}, nil
+	}
+
+	return r.reconcileNormal(ctx, mvmHostScope)
+}
+
+func (r *MicrovmHostReconciler) reconcileNormal(
+	ctx context.Context,
+	mvmHostScope *scope.MicrovmHostScope,
+) (reconcile.Result, error) {
+	mvmHostScope.V(logging.DebugLevel).Info("Reconciling MicrovmHost update")
+
+	if r.Recorder == nil {
+		return ctrl.Result{}, errHealthRecorderRequired
+	}
+
+	now := time.Now()
+	window := mvmHostScope.Window()
+
+	buckets := health.AddOutcomes(mvmHostScope.Buckets(), r.Recorder.Drain(mvmHostScope.Endpoint()), window)
+	buckets = health.Prune(buckets, now, window)
+	mvmHostScope.SetBuckets(buckets)
+
+	summary := health.Summarize(buckets, mvmHostScope.Objective())
+	mvmHostScope.SetSummary(summary)
+
+	exhausted := summary.Exhausted(mvmHostScope.MinAttempts())
+	if exhausted {
+		mvmHostScope.SetBudgetExhausted(
+			infrav1.MicrovmHostErrorBudgetExhaustedReason,
+			clusterv1.ConditionSeverityWarning,
+			"%d of %d provisioning attempts failed in the last %s",
+			summary.Failures, summary.Attempts(), window,
+		)
+	} else {
+		mvmHostScope.SetBudgetAvailable()
+	}
+
+	quarantined := exhausted && mvmHostScope.AutoQuarantine()
+	if quarantined != mvmHostScope.MicrovmHost.Status.Quarantined {
+		mvmHostScope.Info("MicrovmHost quarantine changed", "quarantined", quarantined)
+	}
+
+	mvmHostScope.SetQuarantined(quarantined)
+
+	r.report(mvmHostScope.Name(), mvmHostScope.Endpoint(), summary, quarantined)
+
+	r.probe(ctx, mvmHostScope, now)
+
+	return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+}
+
+// probe connects to the host to record whether it is reachable and to verify
+// its identity.
+func (r *MicrovmHostReconciler) probe(ctx context.Context, mvmHostScope *scope.MicrovmHostScope, now time.Time) {
+	if r.IdentityProber == nil {
+		mvmHostScope.ClearIdentityVerification()
+		health.ReportIdentity(mvmHostScope.Endpoint(), false)
+
+		return
+	}
+
+	observed, err := r.IdentityProber.Probe(ctx, mvmHostScope.Endpoint())
+
+	if errors.Is(err, identity.ErrUnreachable) {
+		if mvmHostScope.MicrovmHost.Status.UnreachableSince == nil {
+			mvmHostScope.Info("MicrovmHost unreachable")
+		}
+
+		mvmHostScope.SetUnreachable(now, err.Error())
+	} else {
+		mvmHostScope.SetReachable()
+	}
+
+	r.verifyIdentity(mvmHostScope, observed, err, now)
+}
+
+// verifyIdentity compares the identity the host presented with the one it is
+// trusted with, recording it if the host has not been seen before. A host
+// which stops serving TLS after an identity was recorded is treated as having
+// changed identity. Failing to reach the host leaves its trust unchanged.
+func (r *MicrovmHostReconciler) verifyIdentity(
+	mvmHostScope *scope.MicrovmHostScope,
+	observed identity.Identity,
+	err error,
+	now time.Time,
+) {
+	if mvmHostScope.IdentityVerification() == infrav1.DisabledIdentityVerification {
+		mvmHostScope.ClearIdentityVerification()
+		health.ReportIdentity(mvmHostScope.Endpoint(), false)
+
+		return
+	}
+
+	trusted := mvmHostScope.TrustedFingerprint()
+
+	switch {
+	case errors.Is(err, identity.ErrPlaintext) && trusted != "":
+		r.identityChanged(mvmHostScope, "host no longer serves tls, expected %s", trusted)
+	case err != nil:
+		mvmHostScope.V(logging.DebugLevel).Info("unable to read host identity", "error", err.Error())
+		mvmHostScope.SetIdentityNotVerified(
+			infrav1.MicrovmHostIdentityUnknownReason,
+			clusterv1.ConditionSeverityInfo,
+			err.Error(),
+		)
+	case trusted == "" || observed.Fingerprint == trusted:
+		if trusted == "" {
+			mvmHostScope.Info("trusting host identity on first use", "fingerprint", observed.Fingerprint)
+		}
+
+		mvmHostScope.SetIdentity(observed, now)
+		mvmHostScope.SetIdentityVerified()
+		mvmHostScope.SetUntrusted(false)
+		health.ReportIdentity(mvmHostScope.Endpoint(), false)
+	default:
+		r.identityChanged(mvmHostScope, "host presented %s, expected %s", observed.Fingerprint, trusted)
+	}
+}
+
+func (r *MicrovmHostReconciler) identityChanged(mvmHostScope *scope.MicrovmHostScope, message string, messageArgs ...interface{}) {
+	untrusted := mvmHostScope.IdentityVerification() == infrav1.EnforceIdentityVerification
+
+	mvmHostScope.Info("MicrovmHost identity changed", "untrusted", untrusted)
+	mvmHostScope.SetIdentityNotVerified(
+		infrav1.MicrovmHostIdentityChangedReason,
+		clusterv1.ConditionSeverityError,
+		message,
+		messageArgs...,
+	)
+	mvmHostScope.SetUntrusted(untrusted)
+	health.ReportIdentity(mvmHostScope.Endpoint(), true)
+}
+
+func (r *MicrovmHostReconciler) report(name, endpoint string, summary health.Summary, quarantined bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reported == nil {
+		r.reported = map[string]string{}
+	}
+
+	if old, ok := r.reported[name]; ok && old != endpoint {
+		health.Forget(old)
+	}
+
+	r.reported[name] = endpoint
+	health.Report(endpoint, summary, quarantined)
+}
+
+func (r *MicrovmHostReconciler) forget(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if endpoint, ok := r.reported[name]; ok {
+		health.Forget(endpoint)
+		delete(r.reported, name)
+	}
+}
+
+// hostQuarantined returns true if a MicrovmHost for endpoint is quarantined.
+func hostQuarantined(ctx context.Context, c client.Reader, endpoint string, opts ...client.ListOption) (bool, error) {
+	return anyHost(ctx, c, endpoint, opts, func(host *infrav1.MicrovmHost) bool {
+		return host.Status.Quarantined
+	})
+}
+
+// hostUntrusted returns true if a MicrovmHost for endpoint presented an
+// unexpected identity and must not be connected to.
+func hostUntrusted(ctx context.Context, c client.Reader, endpoint string, opts ...client.ListOption) (bool, error) {
+	return anyHost(ctx, c, endpoint, opts, func(host *infrav1.MicrovmHost) bool {
+		return host.Status.Untrusted
+	})
+}
+
+// pinnedFingerprint returns the fingerprint the host at endpoint must present
+// when its identity is enforced, or "" when it is not, or it has not been seen
+// yet.
+func pinnedFingerprint(ctx context.Context, c client.Reader, endpoint string, opts ...client.ListOption) (string, error) {
+	var fingerprint string
+
+	_, err := anyHost(ctx, c, endpoint, opts, func(host *infrav1.MicrovmHost) bool {
+		hostScope := &scope.MicrovmHostScope{MicrovmHost: host}
+		if hostScope.IdentityVerification() == infrav1.EnforceIdentityVerification {
+			fingerprint = hostScope.TrustedFingerprint()
+		}
+
+		return fingerprint != ""
+	})
+
+	return fingerprint, err
+}
+
+func anyHost(
+	ctx context.Context,
+	c client.Reader,
+	endpoint string,
+	opts []client.ListOption,
+	match func(*infrav1.MicrovmHost) bool,
+) (bool, error) {
+	hosts := &infrav1.MicrovmHostList{}
+	if err := c.List(ctx, hosts, opts...); err != nil {
+		return false, fmt.Errorf("listing microvmhosts: %w", err)
+	}
+
+	for i := range hosts.Items {
+		if hosts.Items[i].Spec.Endpoint == endpoint && match(&hosts.Items[i]) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MicrovmHostReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.MicrovmHost{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvmhost", logging.MicrovmHostKey)).
+		Complete(tracing.Reconciler("microvmhost", r))
+}
@@ -0,0 +1,300 @@
+package controllers_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/health"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+)
+
+func TestMicrovmHost_Reconcile_MissingObject(t *testing.T) {
+	g := NewWithT(t)
+
+	client := createFakeClient(g, nil)
+	result, err := reconcileMicrovmHost(client, health.NewRecorder())
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling when microvmhost doesn't exist should not error")
+	g.Expect(result.IsZero()).To(BeTrue(), "Expect no requeue to be requested")
+}
+
+func TestMicrovmHost_Reconcile_MissingRecorder(t *testing.T) {
+	g := NewWithT(t)
+
+	client := createFakeClient(g, []runtime.Object{createMicrovmHost()})
+	_, err := reconcileMicrovmHost(client, nil)
+	g.Expect(err).To(HaveOccurred(), "Reconciling without a health recorder should error")
+}
+
+func TestMicrovmHost_ReconcileNormal_ErrorBudget(t *testing.T) {
+	tt := []struct {
+		name      string
+		policy    infrav1.QuarantinePolicy
+		successes int
+		failures  int
+		expected  func(*WithT, *infrav1.MicrovmHost)
+	}{
+		{
+			name: "no attempts leaves the whole budget",
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.ErrorBudgetRemaining.Cmp(resource.MustParse("1"))).To(Equal(0))
+				g.Expect(mvmH.Status.Quarantined).To(BeFalse())
+				assertConditionTrue(g, mvmH, infrav1.MicrovmHostErrorBudgetCondition)
+			},
+		},
+		{
+			name:      "successes are summarised",
+			successes: 3,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.Successes).To(Equal(int32(3)))
+				g.Expect(mvmH.Status.Failures).To(Equal(int32(0)))
+				g.Expect(mvmH.Status.SuccessRatio.Cmp(resource.MustParse("1"))).To(Equal(0))
+				g.Expect(mvmH.Status.Buckets).To(HaveLen(1))
+				assertConditionTrue(g, mvmH, infrav1.MicrovmHostErrorBudgetCondition)
+			},
+		},
+		{
+			name:     "failures below the minimum attempts do not exhaust the budget",
+			failures: 3,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.ErrorBudgetRemaining.Cmp(resource.MustParse("0"))).To(Equal(0))
+				g.Expect(mvmH.Status.Quarantined).To(BeFalse())
+				assertConditionTrue(g, mvmH, infrav1.MicrovmHostErrorBudgetCondition)
+			},
+		},
+		{
+			name:      "exhausted budget quarantines the host",
+			successes: 2,
+			failures:  2,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.SuccessRatio.Cmp(resource.MustParse("0.5"))).To(Equal(0))
+				g.Expect(mvmH.Status.Quarantined).To(BeTrue())
+				assertConditionFalse(g, mvmH, infrav1.MicrovmHostErrorBudgetCondition, infrav1.MicrovmHostErrorBudgetExhaustedReason)
+			},
+		},
+		{
+			name:      "exhausted budget is only reported when quarantine is disabled",
+			policy:    infrav1.NeverQuarantinePolicy,
+			successes: 2,
+			failures:  2,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.Quarantined).To(BeFalse())
+				assertConditionFalse(g, mvmH, infrav1.MicrovmHostErrorBudgetCondition, infrav1.MicrovmHostErrorBudgetExhaustedReason)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvmH := createMicrovmHost()
+			mvmH.Spec.Quarantine = tc.policy
+
+			recorder := health.NewRecorder()
+			for i := 0; i < tc.successes; i++ {
+				recorder.Record(testHostEndpoint, true, time.Now())
+			}
+			for i := 0; i < tc.failures; i++ {
+				recorder.Record(testHostEndpoint, false, time.Now())
+			}
+
+			client := createFakeClient(g, []runtime.Object{mvmH})
+			result, err := reconcileMicrovmHost(client, recorder)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmhost should not error")
+			g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect the summary to be refreshed periodically")
+
+			reconciled, err := getMicrovmHost(client, testMicrovmHostName)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvmhost should not fail")
+
+			tc.expected(g, reconciled)
+		})
+	}
+}
+
+func TestMicrovmHost_ReconcileNormal_FailuresLeaveTheWindow(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmH := createMicrovmHost()
+	mvmH.Spec.ErrorBudget.WindowSeconds = 60
+	mvmH.Status.Quarantined = true
+	mvmH.Status.Buckets = health.AddOutcomes(nil, []health.Outcome{
+		{Time: time.Now().Add(-2 * time.Minute), Succeeded: false},
+		{Time: time.Now().Add(-2 * time.Minute), Succeeded: false},
+		{Time: time.Now().Add(-2 * time.Minute), Succeeded: false},
+		{Time: time.Now().Add(-2 * time.Minute), Succeeded: false},
+	}, time.Minute)
+
+	client := createFakeClient(g, []runtime.Object{mvmH})
+	_, err := reconcileMicrovmHost(client, health.NewRecorder())
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmhost should not error")
+
+	reconciled, err := getMicrovmHost(client, testMicrovmHostName)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmhost should not fail")
+	g.Expect(reconciled.Status.Buckets).To(BeEmpty())
+	g.Expect(reconciled.Status.Failures).To(Equal(int32(0)))
+	g.Expect(reconciled.Status.Quarantined).To(BeFalse(), "Expect the host to be released once the failures expire")
+}
+
+func TestMicrovmHost_ReconcileNormal_Identity(t *testing.T) {
+	trusted := "sha256:" + strings.Repeat("a", 64)
+	other := "sha256:" + strings.Repeat("b", 64)
+
+	tt := []struct {
+		name         string
+		verification infrav1.IdentityVerification
+		pinned       string
+		recorded     string
+		observed     string
+		probeErr     error
+		expected     func(*WithT, *infrav1.MicrovmHost)
+	}{
+		{
+			name:     "identity is trusted on first use",
+			observed: trusted,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.Identity).NotTo(BeNil())
+				g.Expect(mvmH.Status.Identity.Fingerprint).To(Equal(trusted))
+				g.Expect(mvmH.Status.Identity.SPIFFEID).To(Equal("spiffe://liquid-metal.io/host1"))
+				g.Expect(mvmH.Status.Untrusted).To(BeFalse())
+				assertConditionTrue(g, mvmH, infrav1.MicrovmHostIdentityVerifiedCondition)
+			},
+		},
+		{
+			name:     "same identity is verified",
+			recorded: trusted,
+			observed: trusted,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.Untrusted).To(BeFalse())
+				assertConditionTrue(g, mvmH, infrav1.MicrovmHostIdentityVerifiedCondition)
+			},
+		},
+		{
+			name:     "changed identity is refused",
+			recorded: trusted,
+			observed: other,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.Identity.Fingerprint).To(Equal(trusted), "Expect the trusted identity to be kept")
+				g.Expect(mvmH.Status.Untrusted).To(BeTrue())
+				assertConditionFalse(g, mvmH, infrav1.MicrovmHostIdentityVerifiedCondition, infrav1.MicrovmHostIdentityChangedReason)
+			},
+		},
+		{
+			name:         "changed identity is only reported when warning",
+			verification: infrav1.WarnIdentityVerification,
+			recorded:     trusted,
+			observed:     other,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.Untrusted).To(BeFalse())
+				assertConditionFalse(g, mvmH, infrav1.MicrovmHostIdentityVerifiedCondition, infrav1.MicrovmHostIdentityChangedReason)
+			},
+		},
+		{
+			name:     "pinned fingerprint replaces the recorded identity",
+			pinned:   other,
+			recorded: trusted,
+			observed: other,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.Identity.Fingerprint).To(Equal(other))
+				g.Expect(mvmH.Status.Untrusted).To(BeFalse())
+				assertConditionTrue(g, mvmH, infrav1.MicrovmHostIdentityVerifiedCondition)
+			},
+		},
+		{
+			name:     "host which stops serving tls is refused",
+			recorded: trusted,
+			probeErr: identity.ErrPlaintext,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.Untrusted).To(BeTrue())
+				assertConditionFalse(g, mvmH, infrav1.MicrovmHostIdentityVerifiedCondition, infrav1.MicrovmHostIdentityChangedReason)
+			},
+		},
+		{
+			name:     "unreachable host is not refused",
+			recorded: trusted,
+			probeErr: fmt.Errorf("connection refused: %w", identity.ErrUnreachable),
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.Untrusted).To(BeFalse())
+				assertConditionFalse(g, mvmH, infrav1.MicrovmHostIdentityVerifiedCondition, infrav1.MicrovmHostIdentityUnknownReason)
+				assertConditionFalse(g, mvmH, infrav1.MicrovmHostReachableCondition, infrav1.MicrovmHostUnreachableReason)
+			},
+		},
+		{
+			name:         "verification can be disabled",
+			verification: infrav1.DisabledIdentityVerification,
+			recorded:     trusted,
+			observed:     other,
+			expected: func(g *WithT, mvmH *infrav1.MicrovmHost) {
+				g.Expect(mvmH.Status.Untrusted).To(BeFalse())
+				g.Expect(conditions.Has(mvmH, infrav1.MicrovmHostIdentityVerifiedCondition)).To(BeFalse())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvmH := createMicrovmHost()
+			mvmH.Spec.Identity.Verification = tc.verification
+			mvmH.Spec.Identity.Fingerprint = tc.pinned
+			if tc.recorded != "" {
+				mvmH.Status.Identity = &infrav1.HostIdentity{Fingerprint: tc.recorded}
+			}
+
+			prober := &fakeProber{
+				identity: identity.Identity{Fingerprint: tc.observed, SPIFFEID: "spiffe://liquid-metal.io/host1"},
+				err:      tc.probeErr,
+			}
+
+			client := createFakeClient(g, []runtime.Object{mvmH})
+			_, err := reconcileMicrovmHostWithProber(client, health.NewRecorder(), prober)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmhost should not error")
+
+			reconciled, err := getMicrovmHost(client, testMicrovmHostName)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvmhost should not fail")
+
+			tc.expected(g, reconciled)
+		})
+	}
+}
+
+func TestMicrovmHost_ReconcileNormal_Reachability(t *testing.T) {
+	g := NewWithT(t)
+
+	since := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	mvmH := createMicrovmHost()
+	mvmH.Status.UnreachableSince = &since
+
+	client := createFakeClient(g, []runtime.Object{mvmH})
+
+	// a host which is still unreachable keeps the time it was first seen to be
+	prober := &fakeProber{err: fmt.Errorf("timed out: %w", identity.ErrUnreachable)}
+	_, err := reconcileMicrovmHostWithProber(client, health.NewRecorder(), prober)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmhost should not error")
+
+	reconciled, err := getMicrovmHost(client, testMicrovmHostName)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.UnreachableSince).NotTo(BeNil())
+	g.Expect(reconciled.Status.UnreachableSince.Time).To(BeTemporally("~", since.Time, time.Second))
+	assertConditionFalse(g, reconciled, infrav1.MicrovmHostReachableCondition, infrav1.MicrovmHostUnreachableReason)
+
+	// and answering again clears it, even if the identity cannot be read
+	prober.err = identity.ErrPlaintext
+	_, err = reconcileMicrovmHostWithProber(client, health.NewRecorder(), prober)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmhost should not error")
+
+	reconciled, err = getMicrovmHost(client, testMicrovmHostName)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.UnreachableSince).To(BeNil())
+	assertConditionTrue(g, reconciled, infrav1.MicrovmHostReachableCondition)
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+// MicrovmHostGroupReconciler reconciles a MicrovmHostGroup object
+type MicrovmHostGroupReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhostgroups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhostgroups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhostgroups/finalizers,verbs=update
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch
+
+func (r *MicrovmHostGroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	mvmHG := &infrav1.MicrovmHostGroup{}
+	if err := r.Get(ctx, req.NamespacedName, mvmHG); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "error getting microvmhostgroup")
+
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
+	}
+
+	if !mvmHG.ObjectMeta.DeletionTimestamp.IsZero() {
+		// nothing is owned by a host group, so there is nothing to clean up
+		return ctrl.Result{}, nil
+	}
+
+	mvmHostGroupScope, err := scope.NewMicrovmHostGroupScope(scope.MicrovmHostGroupScopeParams{
+		MicrovmHostGroup: mvmHG,
+		Client:           r.Client,
+		Context:          ctx,
+		Logger:           log,
+	})
+	if err != nil {
+		log.Error(err, "failed to create mvm-host-group scope")
+
+		return ctrl.Result{}, fmt.Errorf("failed to create mvm-host-group scope: %w", err)
+	}
+
+	defer func() {
+		if err := mvmHostGroupScope.Patch(); err != nil {
+			log.Error(err, "failed to patch microvmhostgroup")
+		}
+	}()
+
+	return r.reconcileNormal(ctx, mvmHostGroupScope)
+}
+
+func (r *MicrovmHostGroupReconciler) reconcileNormal(
+	ctx context.Context,
+	mvmHostGroupScope *scope.MicrovmHostGroupScope,
+) (reconcile.Result, error) {
+	mvmHostGroupScope.V(logging.DebugLevel).Info("Reconciling MicrovmHostGroup update")
+
+	selector, err := mvmHostGroupScope.Selector()
+	if err != nil {
+		mvmHostGroupScope.SetNotReady(infrav1.MicrovmHostGroupInvalidSelectorReason,
+			clusterv1.ConditionSeverityError, "%s", err.Error())
+
+		return ctrl.Result{}, nil
+	}
+
+	selected := []infrav1.MicrovmHost{}
+
+	if selector != nil {
+		hosts := &infrav1.MicrovmHostList{}
+		if err := r.List(ctx, hosts, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return ctrl.Result{}, fmt.Errorf("listing microvmhosts: %w", err)
+		}
+
+		selected = hosts.Items
+	}
+
+	members := mvmHostGroupScope.Members(selected)
+	full := []string{}
+
+	if max, ok := mvmHostGroupScope.MaxMicrovmsPerHost(); ok {
+		counts, err := r.countMicrovms(ctx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		for _, host := range members {
+			if counts[host.Endpoint] >= max {
+				full = append(full, host.Endpoint)
+			}
+		}
+	}
+
+	mvmHostGroupScope.SetMembers(members, full)
+	mvmHostGroupScope.SetReady()
+	mvmHostGroupScope.SetObservedGeneration()
+
+	return ctrl.Result{}, nil
+}
+
+// countMicrovms returns the number of Microvms, in any namespace, placed on
+// each host, keyed by endpoint.
+func (r *MicrovmHostGroupReconciler) countMicrovms(ctx context.Context) (map[string]int32, error) {
+	mvms := &infrav1.MicrovmList{}
+	if err := r.List(ctx, mvms); err != nil {
+		return nil, fmt.Errorf("listing microvms: %w", err)
+	}
+
+	counts := map[string]int32{}
+
+	for _, mvm := range mvms.Items {
+		counts[mvm.Spec.Host.Endpoint]++
+	}
+
+	return counts, nil
+}
+
+// hostToGroups maps a MicrovmHost to every MicrovmHostGroup with a selector,
+// as any of them may gain or lose it as a member when its labels change.
+func (r *MicrovmHostGroupReconciler) hostToGroups(_ client.Object) []reconcile.Request {
+	groups := &infrav1.MicrovmHostGroupList{}
+	if err := r.List(context.Background(), groups); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, group := range groups.Items {
+		if group.Spec.Selector != nil {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: group.Namespace, Name: group.Name},
+			})
+		}
+	}
+
+	return requests
+}
+
+// microvmToGroups maps a Microvm to the MicrovmHostGroups with a capacity
+// which have its host as a member, so that hosts filling up or freeing space
+// are seen straight away.
+func (r *MicrovmHostGroupReconciler) microvmToGroups(obj client.Object) []reconcile.Request {
+	mvm, ok := obj.(*infrav1.Microvm)
+	if !ok {
+		return nil
+	}
+
+	groups := &infrav1.MicrovmHostGroupList{}
+	if err := r.List(context.Background(), groups); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, group := range groups.Items {
+		if group.Spec.Capacity.MaxMicrovmsPerHost == nil {
+			continue
+		}
+
+		for _, host := range group.Status.Hosts {
+			if host.Endpoint == mvm.Spec.Host.Endpoint {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: group.Namespace, Name: group.Name},
+				})
+
+				break
+			}
+		}
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MicrovmHostGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.MicrovmHostGroup{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvmhostgroup", logging.HostGroupKey)).
+		Watches(
+			&source.Kind{Type: &infrav1.MicrovmHost{}},
+			handler.EnqueueRequestsFromMapFunc(r.hostToGroups),
+		).
+		Watches(
+			&source.Kind{Type: &infrav1.Microvm{}},
+			handler.EnqueueRequestsFromMapFunc(r.microvmToGroups),
+		).
+		Complete(tracing.Reconciler("microvmhostgroup", r))
+}
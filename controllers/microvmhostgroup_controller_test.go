@@ -0,0 +1,101 @@
+package controllers_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+func TestMicrovmHostGroup_ReconcileNormal(t *testing.T) {
+	tt := []struct {
+		name     string
+		group    func() *infrav1.MicrovmHostGroup
+		expected func(*WithT, *infrav1.MicrovmHostGroup)
+	}{
+		{
+			name: "listed and selected hosts are members, sorted and without duplicates",
+			group: func() *infrav1.MicrovmHostGroup {
+				group := createMicrovmHostGroup(
+					microvm.Host{Name: "listed", Endpoint: "2.2.2.2:9090"},
+					microvm.Host{Name: "listed-again", Endpoint: testHostEndpoint},
+				)
+				group.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "edge"}}
+
+				return group
+			},
+			expected: func(g *WithT, group *infrav1.MicrovmHostGroup) {
+				g.Expect(group.Status.Ready).To(BeTrue())
+				assertConditionTrue(g, group, infrav1.MicrovmHostGroupReadyCondition)
+				g.Expect(group.Status.Hosts).To(Equal([]microvm.Host{
+					{Name: "listed-again", Endpoint: testHostEndpoint},
+					{Name: "listed", Endpoint: "2.2.2.2:9090"},
+					{Name: "selected", Endpoint: "3.3.3.3:9090"},
+				}))
+				g.Expect(group.Status.FullHosts).To(BeEmpty())
+			},
+		},
+		{
+			name: "hosts at capacity are full",
+			group: func() *infrav1.MicrovmHostGroup {
+				group := createMicrovmHostGroup(
+					microvm.Host{Endpoint: testHostEndpoint},
+					microvm.Host{Endpoint: "2.2.2.2:9090"},
+				)
+				group.Spec.Capacity.MaxMicrovmsPerHost = pointer.Int32(1)
+
+				return group
+			},
+			expected: func(g *WithT, group *infrav1.MicrovmHostGroup) {
+				g.Expect(group.Status.Hosts).To(HaveLen(2))
+				g.Expect(group.Status.FullHosts).To(Equal([]string{testHostEndpoint}))
+			},
+		},
+		{
+			name: "invalid selector is not ready",
+			group: func() *infrav1.MicrovmHostGroup {
+				group := createMicrovmHostGroup()
+				group.Spec.Selector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "pool",
+					Operator: "Sideways",
+				}}}
+
+				return group
+			},
+			expected: func(g *WithT, group *infrav1.MicrovmHostGroup) {
+				g.Expect(group.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, group, infrav1.MicrovmHostGroupReadyCondition, infrav1.MicrovmHostGroupInvalidSelectorReason)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			selected := createMicrovmHost()
+			selected.Name = "selected"
+			selected.Labels = map[string]string{"pool": "edge"}
+			selected.Spec.Endpoint = "3.3.3.3:9090"
+
+			unselected := createMicrovmHost()
+			unselected.Name = "unselected"
+			unselected.Spec.Endpoint = "4.4.4.4:9090"
+
+			mvm := createMicrovm()
+
+			client := createFakeClient(g, []runtime.Object{tc.group(), selected, unselected, mvm})
+			_, err := reconcileMicrovmHostGroup(client)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a microvmhostgroup should not error")
+
+			reconciled, err := getMicrovmHostGroup(client, testMicrovmHostGroupName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvmhostgroup should not fail")
+			tc.expected(g, reconciled)
+		})
+	}
+}
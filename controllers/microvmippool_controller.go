@@ -0,0 +1,182 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/ipam"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+// MicrovmIPPoolReconciler reconciles a MicrovmIPPool object. The Microvm
+// controller allocates and releases the addresses of a pool, and this one
+// reports how full it is and returns the addresses of Microvms which are gone.
+//
+// Unlike the other controllers it does not patch the pool through a scope, as
+// the allocations in its spec are shared with the Microvm controller. Every
+// write is an update, which fails rather than overwrite an allocation made
+// since the pool was read.
+type MicrovmIPPoolReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmippools,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmippools/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmippools/finalizers,verbs=update
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch
+
+func (r *MicrovmIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	pool := &infrav1.MicrovmIPPool{}
+	if err := r.Get(ctx, req.NamespacedName, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "error getting microvmippool")
+
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
+	}
+
+	if !pool.ObjectMeta.DeletionTimestamp.IsZero() {
+		// the addresses only exist in the pool, so there is nothing to clean up
+		return ctrl.Result{}, nil
+	}
+
+	log.V(logging.DebugLevel).Info("Reconciling MicrovmIPPool update")
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, req.NamespacedName, pool); err != nil {
+			return err
+		}
+
+		// the Microvms are listed after the pool is read, so that every
+		// allocation read was made for a Microvm which is already listed
+		live, err := microvmUIDs(ctx, r.Client, pool.Namespace)
+		if err != nil {
+			return err
+		}
+
+		if released := ipam.Prune(pool, func(uid types.UID) bool { return live[uid] }); released > 0 {
+			if err := r.Update(ctx, pool); err != nil {
+				return err
+			}
+
+			log.Info("released ip addresses of deleted microvms", "count", released)
+		}
+
+		before := pool.Status.DeepCopy()
+
+		setIPPoolStatus(pool)
+
+		if equality.Semantic.DeepEqual(before, &pool.Status) {
+			return nil
+		}
+
+		return r.Status().Update(ctx, pool)
+	})
+	if err != nil {
+		log.Error(err, "failed to update microvmippool status")
+
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// setIPPoolStatus records the size of the pool and whether it has addresses
+// left to allocate.
+func setIPPoolStatus(pool *infrav1.MicrovmIPPool) {
+	parsed, err := ipam.Parse(pool.Spec)
+	if err != nil {
+		conditions.MarkFalse(pool, infrav1.MicrovmIPPoolReadyCondition, infrav1.MicrovmIPPoolInvalidReason,
+			clusterv1.ConditionSeverityError, "%s", err.Error())
+		pool.Status.Ready = false
+		pool.Status.Size = 0
+
+		return
+	}
+
+	pool.Status.Size = parsed.Size()
+	pool.Status.Allocated = int32(len(pool.Spec.Allocations))
+
+	if int64(pool.Status.Allocated) >= pool.Status.Size {
+		conditions.MarkFalse(pool, infrav1.MicrovmIPPoolReadyCondition, infrav1.MicrovmIPPoolExhaustedReason,
+			clusterv1.ConditionSeverityWarning, "all %d addresses are allocated", pool.Status.Size)
+		pool.Status.Ready = false
+
+		return
+	}
+
+	conditions.MarkTrue(pool, infrav1.MicrovmIPPoolReadyCondition)
+	pool.Status.Ready = true
+}
+
+// microvmToIPPools maps a Microvm to the MicrovmIPPools it allocates from, so
+// that the addresses of a deleted Microvm are returned straight away.
+func (r *MicrovmIPPoolReconciler) microvmToIPPools(obj client.Object) []reconcile.Request {
+	mvm, ok := obj.(*infrav1.Microvm)
+	if !ok {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	seen := map[string]bool{}
+
+	for _, ref := range mvm.Spec.IPPools {
+		if !seen[ref.PoolRef.Name] {
+			seen[ref.PoolRef.Name] = true
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: mvm.Namespace, Name: ref.PoolRef.Name},
+			})
+		}
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MicrovmIPPoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.MicrovmIPPool{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvmippool", logging.IPPoolKey)).
+		Watches(
+			&source.Kind{Type: &infrav1.Microvm{}},
+			handler.EnqueueRequestsFromMapFunc(r.microvmToIPPools),
+		).
+		Complete(tracing.Reconciler("microvmippool", r))
+}
@@ -0,0 +1,103 @@
+package controllers_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+func TestMicrovmIPPool_ReconcileNormal(t *testing.T) {
+	tt := []struct {
+		name     string
+		pool     func() *infrav1.MicrovmIPPool
+		expected func(*WithT, *infrav1.MicrovmIPPool)
+	}{
+		{
+			name: "a pool with addresses left is ready",
+			pool: func() *infrav1.MicrovmIPPool {
+				pool := createMicrovmIPPool("10.0.0.0/24")
+				pool.Spec.Gateway = "10.0.0.1"
+				pool.Spec.Allocations = []infrav1.IPAllocation{
+					{Address: "10.0.0.2", Microvm: testMicrovmName, UID: testMicrovmUID, Interface: "eth0"},
+				}
+
+				return pool
+			},
+			expected: func(g *WithT, pool *infrav1.MicrovmIPPool) {
+				g.Expect(pool.Status.Ready).To(BeTrue())
+				assertConditionTrue(g, pool, infrav1.MicrovmIPPoolReadyCondition)
+				g.Expect(pool.Status.Size).To(Equal(int64(253)))
+				g.Expect(pool.Status.Allocated).To(Equal(int32(1)))
+			},
+		},
+		{
+			name: "a pool with every address allocated is exhausted",
+			pool: func() *infrav1.MicrovmIPPool {
+				pool := createMicrovmIPPool("10.0.0.0/24")
+				pool.Spec.Range = &infrav1.IPRange{Start: "10.0.0.2", End: "10.0.0.2"}
+				pool.Spec.Allocations = []infrav1.IPAllocation{
+					{Address: "10.0.0.2", Microvm: testMicrovmName, UID: testMicrovmUID, Interface: "eth0"},
+				}
+
+				return pool
+			},
+			expected: func(g *WithT, pool *infrav1.MicrovmIPPool) {
+				g.Expect(pool.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, pool, infrav1.MicrovmIPPoolReadyCondition, infrav1.MicrovmIPPoolExhaustedReason)
+			},
+		},
+		{
+			name: "the addresses of deleted microvms are released",
+			pool: func() *infrav1.MicrovmIPPool {
+				pool := createMicrovmIPPool("10.0.0.0/24")
+				pool.Spec.Allocations = []infrav1.IPAllocation{
+					{Address: "10.0.0.1", Microvm: testMicrovmName, UID: testMicrovmUID, Interface: "eth0"},
+					{Address: "10.0.0.2", Microvm: "gone", UID: "GONE", Interface: "eth0"},
+				}
+				pool.Status.Allocated = 2
+
+				return pool
+			},
+			expected: func(g *WithT, pool *infrav1.MicrovmIPPool) {
+				g.Expect(pool.Status.Ready).To(BeTrue())
+				g.Expect(pool.Status.Allocated).To(Equal(int32(1)))
+				g.Expect(pool.Spec.Allocations).To(HaveLen(1))
+				g.Expect(pool.Spec.Allocations[0].Microvm).To(Equal(testMicrovmName))
+			},
+		},
+		{
+			name: "an invalid pool is not ready",
+			pool: func() *infrav1.MicrovmIPPool {
+				pool := createMicrovmIPPool("10.0.0.0/24")
+				pool.Spec.Gateway = "10.1.0.1"
+
+				return pool
+			},
+			expected: func(g *WithT, pool *infrav1.MicrovmIPPool) {
+				g.Expect(pool.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, pool, infrav1.MicrovmIPPoolReadyCondition, infrav1.MicrovmIPPoolInvalidReason)
+				g.Expect(pool.Status.Size).To(BeZero())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.UID = testMicrovmUID
+
+			client := createFakeClient(g, []runtime.Object{tc.pool(), mvm})
+			_, err := reconcileMicrovmIPPool(client)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a microvmippool should not error")
+
+			reconciled, err := getMicrovmIPPool(client, testMicrovmIPPoolName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvmippool should not fail")
+			tc.expected(g, reconciled)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/macpool"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+// MicrovmMACPoolReconciler reconciles a MicrovmMACPool object. The Microvm
+// controller allocates and releases the addresses of a pool, and this one
+// reports how full it is and returns the addresses of Microvms which are gone.
+//
+// Unlike the other controllers it does not patch the pool through a scope, as
+// the allocations in its spec are shared with the Microvm controller. Every
+// write is an update, which fails rather than overwrite an allocation made
+// since the pool was read.
+type MicrovmMACPoolReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmmacpools,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmmacpools/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmmacpools/finalizers,verbs=update
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch
+
+func (r *MicrovmMACPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	pool := &infrav1.MicrovmMACPool{}
+	if err := r.Get(ctx, req.NamespacedName, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "error getting microvmmacpool")
+
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
+	}
+
+	if !pool.ObjectMeta.DeletionTimestamp.IsZero() {
+		// the addresses only exist in the pool, so there is nothing to clean up
+		return ctrl.Result{}, nil
+	}
+
+	log.V(logging.DebugLevel).Info("Reconciling MicrovmMACPool update")
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, req.NamespacedName, pool); err != nil {
+			return err
+		}
+
+		// the Microvms are listed after the pool is read, so that every
+		// allocation read was made for a Microvm which is already listed
+		live, err := microvmUIDs(ctx, r.Client, pool.Namespace)
+		if err != nil {
+			return err
+		}
+
+		if released := macpool.Prune(pool, func(uid types.UID) bool { return live[uid] }); released > 0 {
+			if err := r.Update(ctx, pool); err != nil {
+				return err
+			}
+
+			log.Info("released mac addresses of deleted microvms", "count", released)
+		}
+
+		before := pool.Status.DeepCopy()
+
+		setMACPoolStatus(pool)
+
+		if equality.Semantic.DeepEqual(before, &pool.Status) {
+			return nil
+		}
+
+		return r.Status().Update(ctx, pool)
+	})
+	if err != nil {
+		log.Error(err, "failed to update microvmmacpool status")
+
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// microvmUIDs returns the UIDs of the Microvms in the namespace, which the
+// pools keep the allocations of.
+func microvmUIDs(ctx context.Context, c client.Client, namespace string) (map[types.UID]bool, error) {
+	mvms := &infrav1.MicrovmList{}
+	if err := c.List(ctx, mvms, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("listing microvms: %w", err)
+	}
+
+	uids := map[types.UID]bool{}
+	for _, mvm := range mvms.Items {
+		uids[mvm.UID] = true
+	}
+
+	return uids, nil
+}
+
+// setMACPoolStatus records the size of the pool and whether it has addresses
+// left to allocate.
+func setMACPoolStatus(pool *infrav1.MicrovmMACPool) {
+	parsed, err := macpool.Parse(pool.Spec)
+	if err != nil {
+		conditions.MarkFalse(pool, infrav1.MicrovmMACPoolReadyCondition, infrav1.MicrovmMACPoolInvalidReason,
+			clusterv1.ConditionSeverityError, "%s", err.Error())
+		pool.Status.Ready = false
+		pool.Status.Size = 0
+
+		return
+	}
+
+	pool.Status.Size = parsed.Size()
+	pool.Status.Allocated = int32(len(pool.Spec.Allocations))
+
+	if int64(pool.Status.Allocated) >= pool.Status.Size {
+		conditions.MarkFalse(pool, infrav1.MicrovmMACPoolReadyCondition, infrav1.MicrovmMACPoolExhaustedReason,
+			clusterv1.ConditionSeverityWarning, "all %d addresses are allocated", pool.Status.Size)
+		pool.Status.Ready = false
+
+		return
+	}
+
+	conditions.MarkTrue(pool, infrav1.MicrovmMACPoolReadyCondition)
+	pool.Status.Ready = true
+}
+
+// microvmToMACPool maps a Microvm to the MicrovmMACPool it allocates from, so
+// that the addresses of a deleted Microvm are returned straight away.
+func (r *MicrovmMACPoolReconciler) microvmToMACPool(obj client.Object) []reconcile.Request {
+	mvm, ok := obj.(*infrav1.Microvm)
+	if !ok || mvm.Spec.MACPoolRef == nil {
+		return nil
+	}
+
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: mvm.Namespace, Name: mvm.Spec.MACPoolRef.Name},
+	}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MicrovmMACPoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.MicrovmMACPool{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvmmacpool", logging.MACPoolKey)).
+		Watches(
+			&source.Kind{Type: &infrav1.Microvm{}},
+			handler.EnqueueRequestsFromMapFunc(r.microvmToMACPool),
+		).
+		Complete(tracing.Reconciler("microvmmacpool", r))
+}
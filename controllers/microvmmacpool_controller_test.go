@@ -0,0 +1,101 @@
+package controllers_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+func TestMicrovmMACPool_ReconcileNormal(t *testing.T) {
+	tt := []struct {
+		name     string
+		pool     func() *infrav1.MicrovmMACPool
+		expected func(*WithT, *infrav1.MicrovmMACPool)
+	}{
+		{
+			name: "a pool with addresses left is ready",
+			pool: func() *infrav1.MicrovmMACPool {
+				pool := createMicrovmMACPool("02:00:00:00:00:01", "02:00:00:00:00:02")
+				pool.Spec.Allocations = []infrav1.MACAllocation{
+					{Address: "02:00:00:00:00:01", Microvm: testMicrovmName, UID: testMicrovmUID, Interface: "eth0"},
+				}
+
+				return pool
+			},
+			expected: func(g *WithT, pool *infrav1.MicrovmMACPool) {
+				g.Expect(pool.Status.Ready).To(BeTrue())
+				assertConditionTrue(g, pool, infrav1.MicrovmMACPoolReadyCondition)
+				g.Expect(pool.Status.Size).To(Equal(int64(2)))
+				g.Expect(pool.Status.Allocated).To(Equal(int32(1)))
+			},
+		},
+		{
+			name: "a pool with every address allocated is exhausted",
+			pool: func() *infrav1.MicrovmMACPool {
+				pool := createMicrovmMACPool("02:00:00:00:00:01")
+				pool.Spec.Allocations = []infrav1.MACAllocation{
+					{Address: "02:00:00:00:00:01", Microvm: testMicrovmName, UID: testMicrovmUID, Interface: "eth0"},
+				}
+
+				return pool
+			},
+			expected: func(g *WithT, pool *infrav1.MicrovmMACPool) {
+				g.Expect(pool.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, pool, infrav1.MicrovmMACPoolReadyCondition, infrav1.MicrovmMACPoolExhaustedReason)
+			},
+		},
+		{
+			name: "the addresses of deleted microvms are released",
+			pool: func() *infrav1.MicrovmMACPool {
+				pool := createMicrovmMACPool("02:00:00:00:00:01", "02:00:00:00:00:02")
+				pool.Spec.Allocations = []infrav1.MACAllocation{
+					{Address: "02:00:00:00:00:01", Microvm: testMicrovmName, UID: testMicrovmUID, Interface: "eth0"},
+					{Address: "02:00:00:00:00:02", Microvm: "gone", UID: "GONE", Interface: "eth0"},
+				}
+				pool.Status.Allocated = 2
+
+				return pool
+			},
+			expected: func(g *WithT, pool *infrav1.MicrovmMACPool) {
+				g.Expect(pool.Status.Ready).To(BeTrue())
+				g.Expect(pool.Status.Allocated).To(Equal(int32(1)))
+				g.Expect(pool.Spec.Allocations).To(HaveLen(1))
+				g.Expect(pool.Spec.Allocations[0].Microvm).To(Equal(testMicrovmName))
+			},
+		},
+		{
+			name: "an invalid pool is not ready",
+			pool: func() *infrav1.MicrovmMACPool {
+				pool := createMicrovmMACPool("01:00:00:00:00:01")
+				pool.Spec.Range = &infrav1.MACRange{Start: "02:00:00:00:00:00", End: "02:00:00:00:00:ff"}
+
+				return pool
+			},
+			expected: func(g *WithT, pool *infrav1.MicrovmMACPool) {
+				g.Expect(pool.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, pool, infrav1.MicrovmMACPoolReadyCondition, infrav1.MicrovmMACPoolInvalidReason)
+				g.Expect(pool.Status.Size).To(BeZero())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.UID = testMicrovmUID
+
+			client := createFakeClient(g, []runtime.Object{tc.pool(), mvm})
+			_, err := reconcileMicrovmMACPool(client)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a microvmmacpool should not error")
+
+			reconciled, err := getMicrovmMACPool(client, testMicrovmMACPoolName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvmmacpool should not fail")
+			tc.expected(g, reconciled)
+		})
+	}
+}
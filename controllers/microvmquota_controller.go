@@ -0,0 +1,151 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/quota"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+// MicrovmQuotaReconciler reconciles a MicrovmQuota object
+type MicrovmQuotaReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmquotas,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmquotas/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmquotas/finalizers,verbs=update
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch
+
+func (r *MicrovmQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	mvmQ := &infrav1.MicrovmQuota{}
+	if err := r.Get(ctx, req.NamespacedName, mvmQ); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "error getting microvmquota")
+
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
+	}
+
+	if !mvmQ.ObjectMeta.DeletionTimestamp.IsZero() {
+		// nothing is owned by a quota, so there is nothing to clean up
+		return ctrl.Result{}, nil
+	}
+
+	mvmQuotaScope, err := scope.NewMicrovmQuotaScope(scope.MicrovmQuotaScopeParams{
+		MicrovmQuota: mvmQ,
+		Client:       r.Client,
+		Context:      ctx,
+		Logger:       log,
+	})
+	if err != nil {
+		log.Error(err, "failed to create mvm-quota scope")
+
+		return ctrl.Result{}, fmt.Errorf("failed to create mvm-quota scope: %w", err)
+	}
+
+	defer func() {
+		if err := mvmQuotaScope.Patch(); err != nil {
+			log.Error(err, "failed to patch microvmquota")
+		}
+	}()
+
+	return r.reconcileNormal(ctx, mvmQuotaScope)
+}
+
+func (r *MicrovmQuotaReconciler) reconcileNormal(
+	ctx context.Context,
+	mvmQuotaScope *scope.MicrovmQuotaScope,
+) (reconcile.Result, error) {
+	mvmQuotaScope.V(logging.DebugLevel).Info("Reconciling MicrovmQuota update")
+
+	mvms := &infrav1.MicrovmList{}
+	if err := r.List(ctx, mvms, client.InNamespace(mvmQuotaScope.Namespace())); err != nil {
+		return ctrl.Result{}, fmt.Errorf("listing microvms: %w", err)
+	}
+
+	used := quota.Used(mvms.Items)
+	mvmQuotaScope.SetUsed(used)
+	mvmQuotaScope.SetObservedGeneration()
+
+	// Microvms admitted together, or before the quota was lowered, can take
+	// the namespace over it
+	if exceeded := quota.Exceeded(mvmQuotaScope.Hard(), used); len(exceeded) > 0 {
+		mvmQuotaScope.SetNotReady(infrav1.MicrovmQuotaExceededReason, clusterv1.ConditionSeverityWarning,
+			"microvms of the namespace exceed the quota: %s", strings.Join(exceeded, ", "))
+
+		return ctrl.Result{}, nil
+	}
+
+	mvmQuotaScope.SetReady()
+
+	return ctrl.Result{}, nil
+}
+
+// microvmToQuotas maps a Microvm to the MicrovmQuotas of its namespace, so
+// that their usage is kept up to date.
+func (r *MicrovmQuotaReconciler) microvmToQuotas(obj client.Object) []reconcile.Request {
+	quotas := &infrav1.MicrovmQuotaList{}
+	if err := r.List(context.Background(), quotas, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, q := range quotas.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: q.Namespace, Name: q.Name},
+		})
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MicrovmQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.MicrovmQuota{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvmquota", logging.QuotaKey)).
+		Watches(
+			&source.Kind{Type: &infrav1.Microvm{}},
+			handler.EnqueueRequestsFromMapFunc(r.microvmToQuotas),
+		).
+		Complete(tracing.Reconciler("microvmquota", r))
+}
@@ -0,0 +1,58 @@
+package controllers_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+func TestMicrovmQuota_ReconcileNormal(t *testing.T) {
+	tt := []struct {
+		name     string
+		hard     infrav1.QuotaLimits
+		expected func(*WithT, *infrav1.MicrovmQuota)
+	}{
+		{
+			name: "microvms within the quota are ready",
+			hard: infrav1.QuotaLimits{VCPU: pointer.Int64(8), Microvms: pointer.Int32(2)},
+			expected: func(g *WithT, mvmQ *infrav1.MicrovmQuota) {
+				g.Expect(mvmQ.Status.Ready).To(BeTrue())
+				assertConditionTrue(g, mvmQ, infrav1.MicrovmQuotaReadyCondition)
+			},
+		},
+		{
+			name: "microvms over the quota are reported",
+			hard: infrav1.QuotaLimits{MemoryMb: pointer.Int64(1024)},
+			expected: func(g *WithT, mvmQ *infrav1.MicrovmQuota) {
+				g.Expect(mvmQ.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, mvmQ, infrav1.MicrovmQuotaReadyCondition, infrav1.MicrovmQuotaExceededReason)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := createMicrovm()
+			mvm.Spec.VCPU = 2
+			mvm.Spec.MemoryMb = 2048
+
+			client := createFakeClient(g, []runtime.Object{createMicrovmQuota(tc.hard), mvm})
+
+			_, err := reconcileMicrovmQuota(client)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmquota should not error")
+
+			reconciled, err := getMicrovmQuota(client, testMicrovmQuotaName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvmquota should not fail")
+			g.Expect(reconciled.Status.Used).To(Equal(infrav1.QuotaUsage{VCPU: 2, MemoryMb: 2048, Microvms: 1}))
+			g.Expect(reconciled.Status.ObservedGeneration).To(Equal(reconciled.Generation))
+
+			tc.expected(g, reconciled)
+		})
+	}
+}
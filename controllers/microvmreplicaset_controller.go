@@ -18,33 +18,87 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"time"
 
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	exputil "sigs.k8s.io/cluster-api/exp/util"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
 	infrastructurev1alpha1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/dryrun"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/imagepin"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/oci"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/replica"
 	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
 )
 
 // MicrovmReplicaSetReconciler reconciles a MicrovmReplicaSet object
 type MicrovmReplicaSetReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Config holds the settings which can be changed while running, such as
+	// the requeue period. The defaults are used when it is nil.
+	Config *config.Store
+
+	// MaxConcurrentReconciles is how many MicrovmReplicaSets are reconciled at once.
+	// Defaults to 1 when zero.
+	MaxConcurrentReconciles int
+
+	// MachinePools is true when a replicaset owned by a Cluster API
+	// MachinePool takes its replicas from it, and reports the provider IDs of
+	// its microvms back, as the MachinePool infrastructure contract requires.
+	MachinePools bool
+
+	// Failover recreates the microvms on a host which has been unreachable for
+	// longer than the FailoverPolicy of their replicaset on its other hosts.
+	// Unreachable hosts are only reported when it is false.
+	Failover bool
+
+	// ImageResolver resolves the images given by tag of templates whose
+	// ImagePolicy is Resolve to digests. Microvms are not created from those
+	// templates when it is nil.
+	ImageResolver oci.Resolver
+
+	// DryRun reconciles every MicrovmReplicaSet in dry-run mode, as the
+	// dry-run annotation does for one.
+	DryRun bool
+
+	// indexed is true once the Microvm controller index has been registered,
+	// which only happens when the reconciler is set up with a manager.
+	indexed bool
 }
 
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmreplicasets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmreplicasets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmreplicasets/finalizers,verbs=update
 //+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinepools,verbs=get;list;watch
 
 func (r *MicrovmReplicaSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
@@ -55,11 +109,16 @@ func (r *MicrovmReplicaSetReconciler) Reconcile(ctx context.Context, req ctrl.Re
 			return ctrl.Result{}, nil
 		}
 
-		log.Error(err, "error getting microvmreplicaset", "id", req.NamespacedName)
+		log.Error(err, "error getting microvmreplicaset")
 
 		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
 	}
 
+	if hosts := replica.Hosts(mvmRS); len(hosts) == 1 {
+		log = log.WithValues(logging.HostKey, hosts[0].Endpoint)
+	}
+	ctx = ctrl.LoggerInto(ctx, log)
+
 	mvmReplicaSetScope, err := scope.NewMicrovmReplicaSetScope(scope.MicrovmReplicaSetScopeParams{
 		MicrovmReplicaSet: mvmRS,
 		Client:            r.Client,
@@ -78,25 +137,68 @@ func (r *MicrovmReplicaSetReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		}
 	}()
 
+	if dryrun.Enabled(mvmRS, r.DryRun) {
+		return r.reconcileDryRun(ctx, mvmReplicaSetScope)
+	}
+
+	mvmReplicaSetScope.ClearDryRun()
+
 	if !mvmRS.ObjectMeta.DeletionTimestamp.IsZero() {
 		log.Info("Deleting microvmreplicaset")
 
 		return r.reconcileDelete(ctx, mvmReplicaSetScope)
 	}
 
-	return r.reconcileNormal(ctx, mvmReplicaSetScope)
+	result, err := r.reconcileNormal(ctx, mvmReplicaSetScope)
+	if err == nil {
+		mvmReplicaSetScope.SetObservedGeneration()
+	}
+
+	return result, err
+}
+
+// reconcileDryRun reconciles the microvmreplicaset as usual, except that the
+// changes to its microvms are recorded on its status rather than made.
+func (r *MicrovmReplicaSetReconciler) reconcileDryRun(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+) (reconcile.Result, error) {
+	recorder := dryrun.NewClient(r.Client)
+
+	planner := *r
+	planner.Client = recorder
+
+	var (
+		result reconcile.Result
+		err    error
+	)
+
+	if !mvmReplicaSetScope.MicrovmReplicaSet.DeletionTimestamp.IsZero() {
+		result, err = planner.reconcileDelete(ctx, mvmReplicaSetScope)
+	} else {
+		result, err = planner.reconcileNormal(ctx, mvmReplicaSetScope)
+	}
+
+	actions := recorder.Actions()
+	if len(actions) > 0 {
+		mvmReplicaSetScope.Info("dry run: not changing microvms", "actions", actions)
+	}
+
+	mvmReplicaSetScope.SetDryRun(actions)
+
+	return result, err
 }
 
 func (r *MicrovmReplicaSetReconciler) reconcileDelete(
 	ctx context.Context,
 	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
 ) (reconcile.Result, error) {
-	mvmReplicaSetScope.Info("Reconciling MicrovmReplicaSet delete")
+	mvmReplicaSetScope.V(logging.DebugLevel).Info("Reconciling MicrovmReplicaSet delete")
 
 	// check the count of existing microvms and bail out early. we are done here.
 	if mvmReplicaSetScope.CreatedReplicas() == 0 {
 		controllerutil.RemoveFinalizer(mvmReplicaSetScope.MicrovmReplicaSet, infrav1.MvmRSFinalizer)
-		mvmReplicaSetScope.Info("microvmreplicaset deleted", "name", mvmReplicaSetScope.Name())
+		mvmReplicaSetScope.Info("microvmreplicaset deleted")
 
 		return ctrl.Result{}, nil
 	}
@@ -121,40 +223,188 @@ func (r *MicrovmReplicaSetReconciler) reconcileDelete(
 		return ctrl.Result{}, fmt.Errorf("failed to list microvms: %w", err)
 	}
 
-	for _, mvm := range mvmList {
-		// if the object is already being deleted, skip this
-		if !mvm.DeletionTimestamp.IsZero() {
+	var (
+		remaining int32 = 0
+		errs      []error
+		deleting  []infrav1.Microvm
+	)
+
+	for i := range mvmList {
+		mvm := mvmList[i]
+
+		// externally managed microvms, and every microvm when orphaning, are
+		// left behind rather than deleted
+		if replica.IsExternal(&mvm) || mvmReplicaSetScope.OrphanOnDelete() {
+			if err := r.releaseMicrovm(ctx, mvmReplicaSetScope, &mvm); err != nil {
+				mvmReplicaSetScope.Error(err, "failed releasing microvm", logging.MicrovmKey, mvm.Name)
+				errs = append(errs, err)
+
+				remaining++
+			}
+
 			continue
 		}
 
-		// otherwise send a delete call
-		go func(m infrav1.Microvm) {
-			if err := r.Delete(ctx, &m); err != nil {
-				mvmReplicaSetScope.Error(err, "failed deleting microvm", "microvm", m.Name)
-				mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetDeleteFailedReason, "Error", "")
-			}
-		}(mvm)
+		remaining++
+
+		deleting = append(deleting, mvm)
+	}
+
+	errs = append(errs, r.deleteMicrovms(ctx, mvmReplicaSetScope, deleting)...)
+	if len(errs) > 0 {
+		mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetDeleteFailedReason, "Error", "%s",
+			kerrors.NewAggregate(errs).Error())
 	}
 
 	// reset the number of created replicas.
 	// we'll come back around to ensure they are really gone.
-	mvmReplicaSetScope.SetCreatedReplicas(int32(len(mvmList)))
+	mvmReplicaSetScope.SetCreatedReplicas(remaining)
+	mvmReplicaSetScope.SetMicrovmSummaries(deleting)
+
+	return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+}
+
+// deleteMicrovms deletes the microvms in batches per host, so that at most the
+// configured number are being removed from each host at once. The batches of
+// every host are sent together. It returns the errors from every delete which
+// failed.
+func (r *MicrovmReplicaSetReconciler) deleteMicrovms(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+	mvms []infrav1.Microvm,
+) []error {
+	limit := r.Config.MaxConcurrentDeletes()
+
+	endpoints := []string{}
+	byHost := map[string][]infrav1.Microvm{}
+
+	for i := range mvms {
+		endpoint := mvms[i].Spec.Host.Endpoint
+		if _, ok := byHost[endpoint]; !ok {
+			endpoints = append(endpoints, endpoint)
+		}
+
+		byHost[endpoint] = append(byHost[endpoint], mvms[i])
+	}
+
+	sort.Strings(endpoints)
+
+	// each host writes only its own errors, so they need no lock
+	hostErrs := make([][]error, len(endpoints))
 
-	return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+	parallel(len(endpoints), 0, func(h int) error {
+		batch := deleteBatch(byHost[endpoints[h]], limit)
+		if len(batch) == 0 {
+			mvmReplicaSetScope.V(logging.DebugLevel).Info("waiting for microvms to be deleted", logging.HostKey, endpoints[h])
+
+			return nil
+		}
+
+		hostErrs[h] = parallel(len(batch), 0, func(i int) error {
+			mvm := batch[i]
+
+			if err := r.Delete(ctx, &mvm); err != nil && !apierrors.IsNotFound(err) {
+				mvmReplicaSetScope.Error(err, "failed deleting microvm", logging.MicrovmKey, mvm.Name)
+
+				return fmt.Errorf("deleting microvm %s: %w", mvm.Name, err)
+			}
+
+			return nil
+		})
+
+		return nil
+	})
+
+	var errs []error
+
+	for _, hostErr := range hostErrs {
+		errs = append(errs, hostErr...)
+	}
+
+	// the deletes finish in any order, and the message should only change
+	// when the failures do
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+
+	return errs
+}
+
+// deleteBatch returns the microvms of a host which are deleted next, leaving
+// out those already being deleted, so that no more than limit are being
+// removed from the host at once. Every microvm is deleted when limit is 0.
+func deleteBatch(mvms []infrav1.Microvm, limit int) []infrav1.Microvm {
+	inFlight := 0
+
+	for i := range mvms {
+		if !mvms[i].DeletionTimestamp.IsZero() {
+			inFlight++
+		}
+	}
+
+	batch := []infrav1.Microvm{}
+
+	for i := range mvms {
+		// the rest are deleted once flintlock has removed earlier ones
+		if limit > 0 && inFlight+len(batch) >= limit {
+			break
+		}
+
+		if mvms[i].DeletionTimestamp.IsZero() {
+			batch = append(batch, mvms[i])
+		}
+	}
+
+	return batch
 }
 
 func (r *MicrovmReplicaSetReconciler) reconcileNormal(
 	ctx context.Context,
 	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
 ) (reconcile.Result, error) {
-	mvmReplicaSetScope.Info("Reconciling MicrovmReplicaSet update")
+	mvmReplicaSetScope.V(logging.DebugLevel).Info("Reconciling MicrovmReplicaSet update")
 
-	// fetch all existing microvms in this rs namespace
-	mvmList, err := r.getOwnedMicrovms(ctx, mvmReplicaSetScope)
+	// persist the finalizer before any microvms are created, so that a delete
+	// which arrives from here on is guaranteed to clean them up
+	if controllerutil.AddFinalizer(mvmReplicaSetScope.MicrovmReplicaSet, infrav1.MvmRSFinalizer) {
+		if err := mvmReplicaSetScope.Patch(); err != nil {
+			mvmReplicaSetScope.Error(err, "unable to patch microvmreplicaset")
+
+			return ctrl.Result{}, err
+		}
+	}
+
+	pooled, err := r.reconcileMachinePool(ctx, mvmReplicaSetScope)
 	if err != nil {
-		mvmReplicaSetScope.Error(err, "failed getting owned microvms")
+		mvmReplicaSetScope.Error(err, "failed getting owner machinepool")
 
-		return ctrl.Result{}, fmt.Errorf("failed to list microvms: %w", err)
+		return ctrl.Result{}, err
+	}
+
+	selector, err := mvmReplicaSetScope.Selector()
+	if err != nil {
+		mvmReplicaSetScope.Error(err, "invalid selector")
+		mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetInvalidSelectorReason, "Error", err.Error())
+
+		return ctrl.Result{}, nil
+	}
+
+	mvmReplicaSetScope.SetSelector(selector)
+
+	hosts := mvmReplicaSetScope.Hosts()
+	if len(hosts) == 0 {
+		mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetNoHostsReason, "Error", "one of host or hosts must be set")
+
+		return ctrl.Result{}, nil
+	}
+
+	// fetch all existing microvms in this rs namespace, adopting and releasing
+	// any which have moved in or out of the selector
+	mvmList, err := r.claimMicrovms(ctx, mvmReplicaSetScope, selector)
+	if err != nil {
+		mvmReplicaSetScope.Error(err, "failed claiming owned microvms")
+
+		return ctrl.Result{}, fmt.Errorf("failed to claim microvms: %w", err)
 	}
 
 	defer func() {
@@ -177,22 +427,134 @@ func (r *MicrovmReplicaSetReconciler) reconcileNormal(
 
 	// record which owned replicas are ready
 	mvmReplicaSetScope.SetReadyReplicas(ready)
+	untilAvailable := mvmReplicaSetScope.SetAvailableReplicas(mvmList, time.Now())
+	mvmReplicaSetScope.SetMicrovmSummaries(mvmList)
+
+	if pooled {
+		mvmReplicaSetScope.SetProviderIDList(mvmList)
+	}
+
+	templateReady, err := r.resolveTemplate(ctx, mvmReplicaSetScope)
+	if err != nil {
+		mvmReplicaSetScope.Error(err, "failed getting microvmtemplate")
+
+		return ctrl.Result{}, err
+	}
+
+	if !templateReady {
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+	}
+
+	unreachable, failed, nextFailover, err := r.checkHosts(ctx, mvmReplicaSetScope)
+	if err != nil {
+		mvmReplicaSetScope.Error(err, "failed getting microvmhosts")
+
+		return ctrl.Result{}, err
+	}
+
+	if len(unreachable) > 0 {
+		mvmReplicaSetScope.SetHostUnreachable(unreachable)
+	} else {
+		mvmReplicaSetScope.SetHostReachable()
+	}
+
+	mvmReplicaSetScope.SetHostSummaries(mvmList, failed)
+
+	// the microvms on failed hosts are recreated on the others, and are only
+	// removed once their host answers again and the replicaset has more than
+	// it needs
+	serving := []infrav1.Microvm{}
+
+	for _, mvm := range mvmList {
+		if !failed[mvm.Spec.Host.Endpoint] {
+			serving = append(serving, mvm)
+		}
+	}
+
+	placed := int32(len(serving))
+
+	// delete the failed microvms the restart policy replaces. they are still
+	// counted until they are gone, after which new ones are created in their
+	// place
+	replacing, err := r.replaceFailed(ctx, mvmReplicaSetScope, mvmList)
+	if err != nil {
+		mvmReplicaSetScope.Error(err, "failed deleting failed microvms")
+		mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetDeleteFailedReason, "Error", "")
+
+		return ctrl.Result{}, err
+	}
+
+	if replacing > 0 {
+		mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetReplacingFailedReason, "Warning",
+			"replacing %d failed microvms", replacing)
+
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+	}
 
 	switch {
 	// if all desired microvms are ready, mark the replicaset ready.
 	// we are done here
 	case mvmReplicaSetScope.ReadyReplicas() == mvmReplicaSetScope.DesiredReplicas():
-		mvmReplicaSetScope.Info("MicrovmReplicaSet created: ready")
+		// a restart goes through the microvms one at a time, so that the
+		// others keep serving
+		if restarting, err := r.restartMicrovms(ctx, mvmReplicaSetScope, serving); err != nil || restarting {
+			if err != nil {
+				mvmReplicaSetScope.Error(err, "failed restarting microvm")
+			}
+
+			mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetRestartingReason, "Info", "")
+
+			return ctrl.Result{RequeueAfter: r.requeuePeriod()}, err
+		}
+
+		mvmReplicaSetScope.V(logging.DebugLevel).Info("MicrovmReplicaSet created: ready")
 		mvmReplicaSetScope.SetReady()
 
-		return reconcile.Result{}, nil
+		// come back to count the replicas which are not available yet, and to
+		// fail over any host which stays unreachable
+		if nextFailover > 0 && (untilAvailable == 0 || nextFailover < untilAvailable) {
+			untilAvailable = nextFailover
+		}
+
+		return reconcile.Result{RequeueAfter: untilAvailable}, nil
 	// if we are in this branch then not all desired microvms have been created.
-	// create a new one and set the ownerref to this controller.
-	case mvmReplicaSetScope.CreatedReplicas() < mvmReplicaSetScope.DesiredReplicas():
-		mvmReplicaSetScope.Info("MicrovmReplicaSet creating: create new microvm")
+	// create up to the limit of new ones and set the ownerref to this controller.
+	case placed < mvmReplicaSetScope.DesiredReplicas():
+		count := mvmReplicaSetScope.DesiredReplicas() - placed
+		if limit := mvmReplicaSetScope.MaxCreatePerReconcile(); count > limit {
+			count = limit
+		}
+
+		// every microvm is created from the digests the template resolved to
+		// first, so that replicas created later boot the same images
+		if err := r.resolveImages(ctx, mvmReplicaSetScope); err != nil {
+			mvmReplicaSetScope.Error(err, "failed resolving template images")
+			mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetImageResolveFailedReason, "Warning", "%s", err.Error())
+
+			return reconcile.Result{}, err
+		}
+
+		mvmReplicaSetScope.Info("MicrovmReplicaSet creating: create new microvms", "count", count)
+
+		// new microvms go to the hosts which are answering, unless none are
+		excluded := map[string]bool{}
+		if len(unreachable) < len(hosts) {
+			for _, endpoint := range unreachable {
+				excluded[endpoint] = true
+			}
+		}
+
+		placements := replica.Place(hosts, serving, excluded, int(count))
+
+		if err := r.createMicrovms(ctx, mvmReplicaSetScope, selector, replica.NextIndices(mvmList, int(count)), placements); err != nil {
+			mvmReplicaSetScope.Error(err, "failed creating owned microvms")
+
+			if errors.Is(err, errSelectorMismatch) {
+				mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetInvalidSelectorReason, "Error", err.Error())
+
+				return reconcile.Result{}, nil
+			}
 
-		if err := r.createMicrovm(ctx, mvmReplicaSetScope); err != nil {
-			mvmReplicaSetScope.Error(err, "failed creating owned microvm")
 			mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetProvisionFailedReason, "Error", "")
 
 			return reconcile.Result{}, fmt.Errorf("failed to create new microvm for replicaset: %w", err)
@@ -200,14 +562,23 @@ func (r *MicrovmReplicaSetReconciler) reconcileNormal(
 
 		mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetIncompleteReason, "Info", "")
 	// if we are here then a scale down has been requested.
-	// we delete the first found until the numbers balance out.
+	// we delete the first found until the numbers balance out, skipping any
+	// which are externally managed and leaving those protected from deletion
+	// until last.
 	// TODO the way this works is very naive and often ends up deleting everything
 	// if the timing is wrong/right, find a better way https://github.com/weaveworks-liquidmetal/microvm-operator/issues/17
-	case mvmReplicaSetScope.CreatedReplicas() > mvmReplicaSetScope.DesiredReplicas():
+	case placed > mvmReplicaSetScope.DesiredReplicas():
 		mvmReplicaSetScope.Info("MicrovmReplicaSet updating: delete microvm")
 		mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetUpdatingReason, "Info", "")
 
-		mvm := mvmList[0]
+		mvm, ok := replica.FirstManaged(replica.ScaleInOrder(hosts, serving))
+		if !ok {
+			mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetExternalReplicasReason, "Warning",
+				"only externally managed microvms are left to scale down")
+
+			return ctrl.Result{}, nil
+		}
+
 		if !mvm.DeletionTimestamp.IsZero() {
 			return ctrl.Result{}, nil
 		}
@@ -221,33 +592,356 @@ func (r *MicrovmReplicaSetReconciler) reconcileNormal(
 	// if all desired microvms have been created, but are not quite ready yet,
 	// set the condition and requeue
 	default:
-		mvmReplicaSetScope.Info("MicrovmReplicaSet creating: waiting for microvms to become ready")
+		mvmReplicaSetScope.V(logging.DebugLevel).Info("MicrovmReplicaSet creating: waiting for microvms to become ready")
 		mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetIncompleteReason, "Info", "")
 	}
 
-	controllerutil.AddFinalizer(mvmReplicaSetScope.MicrovmReplicaSet, infrav1.MvmRSFinalizer)
+	return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+}
+
+// restartMicrovms passes the restart asked for on the replicaset on to the
+// next of its managed microvms which has not been restarted, once the one
+// before it has been recreated. It returns true while the restart is under
+// way. Microvms protected from deletion refuse the restart themselves, so
+// they are not waited for.
+func (r *MicrovmReplicaSetReconciler) restartMicrovms(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+	mvms []infrav1.Microvm,
+) (bool, error) {
+	restartedAt := mvmReplicaSetScope.RestartedAt()
+	if restartedAt == "" {
+		return false, nil
+	}
+
+	var next *infrav1.Microvm
+
+	for i := range mvms {
+		mvm := &mvms[i]
+		if replica.IsExternal(mvm) || !mvm.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		switch {
+		case mvm.Annotations[infrav1.RestartedAtAnnotation] != restartedAt:
+			if next == nil {
+				next = mvm
+			}
+		case mvm.Status.RestartedAt != restartedAt && mvm.Annotations[infrav1.DeleteProtectionAnnotation] != "true":
+			return true, nil
+		}
+	}
+
+	if next == nil {
+		return false, nil
+	}
+
+	patch := client.MergeFromWithOptions(next.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	if next.Annotations == nil {
+		next.Annotations = map[string]string{}
+	}
+
+	next.Annotations[infrav1.RestartedAtAnnotation] = restartedAt
+
+	if err := r.Patch(ctx, next, patch, apply.FieldOwner); err != nil {
+		return false, fmt.Errorf("restarting microvm %s: %w", next.Name, err)
+	}
+
+	mvmReplicaSetScope.Info("restarting microvm", logging.MicrovmKey, next.Name, "restartedAt", restartedAt)
+
+	return true, nil
+}
+
+// replaceFailed deletes the managed microvms which the restart policy of the
+// replicaset replaces. It returns how many are being deleted.
+func (r *MicrovmReplicaSetReconciler) replaceFailed(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+	mvms []infrav1.Microvm,
+) (int, error) {
+	replacing := 0
+
+	for i := range mvms {
+		mvm := &mvms[i]
+		if replica.IsExternal(mvm) || !mvmReplicaSetScope.ReplacesFailed(mvm) {
+			continue
+		}
+
+		replacing++
+
+		if !mvm.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		mvmReplicaSetScope.Info("MicrovmReplicaSet updating: replace failed microvm",
+			"microvm", mvm.Name, "vmState", *mvm.Status.VMState)
+
+		if err := r.Delete(ctx, mvm); client.IgnoreNotFound(err) != nil {
+			return replacing, fmt.Errorf("deleting failed microvm %s: %w", mvm.Name, err)
+		}
+	}
+
+	return replacing, nil
+}
+
+// reconcileMachinePool takes the replicas of the Cluster API MachinePool which
+// owns the replicaset, if there is one. It returns true if there is.
+func (r *MicrovmReplicaSetReconciler) reconcileMachinePool(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+) (bool, error) {
+	if !r.MachinePools {
+		return false, nil
+	}
+
+	machinePool, err := exputil.GetOwnerMachinePool(ctx, r.Client, mvmReplicaSetScope.MicrovmReplicaSet.ObjectMeta)
+	if err != nil {
+		// the replicaset is garbage collected along with a deleted owner
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("getting owner machinepool: %w", err)
+	}
+
+	if machinePool == nil {
+		return false, nil
+	}
+
+	if replicas := machinePool.Spec.Replicas; replicas != nil && *replicas != mvmReplicaSetScope.DesiredReplicas() {
+		mvmReplicaSetScope.Info("scaling to machinepool replicas", "machinePool", machinePool.Name, "replicas", *replicas)
+		mvmReplicaSetScope.SetDesiredReplicas(*replicas)
+	}
+
+	return true, nil
+}
+
+// resolveTemplate loads the referenced MicrovmTemplate, if any, into the scope.
+// It returns false if the template cannot be used yet.
+func (r *MicrovmReplicaSetReconciler) resolveTemplate(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+) (bool, error) {
+	ref := mvmReplicaSetScope.TemplateRef()
+	if ref == nil {
+		mvmReplicaSetScope.SetTemplate(nil)
+
+		return true, nil
+	}
+
+	mvmT := &infrav1.MicrovmTemplate{}
+	key := client.ObjectKey{Name: ref.Name, Namespace: mvmReplicaSetScope.Namespace()}
+
+	if err := r.Get(ctx, key, mvmT); err != nil {
+		if apierrors.IsNotFound(err) {
+			mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetTemplateNotReadyReason,
+				"Warning", "microvmtemplate %s not found", ref.Name)
+
+			return false, nil
+		}
+
+		return false, fmt.Errorf("getting microvmtemplate %s: %w", ref.Name, err)
+	}
+
+	if !mvmT.IsResolved() {
+		mvmReplicaSetScope.SetNotReady(infrav1.MicrovmReplicaSetTemplateNotReadyReason,
+			"Warning", "microvmtemplate %s has not been resolved", ref.Name)
+
+		return false, nil
+	}
+
+	mvmReplicaSetScope.SetTemplate(mvmT)
+
+	return true, nil
+}
+
+// resolveImages resolves the images of the template given by tag to digests,
+// if its ImagePolicy asks for that. Images resolved before keep their digest.
+func (r *MicrovmReplicaSetReconciler) resolveImages(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+) error {
+	if !mvmReplicaSetScope.ResolvesImages() {
+		mvmReplicaSetScope.SetResolvedImages(nil)
+
+		return nil
+	}
+
+	if r.ImageResolver == nil {
+		return errImageResolverRequired
+	}
+
+	spec := mvmReplicaSetScope.MicrovmSpec()
+
+	resolved, err := imagepin.Resolve(ctx, r.ImageResolver, &spec.VMSpec, mvmReplicaSetScope.ResolvedImages())
+	mvmReplicaSetScope.SetResolvedImages(resolved)
+
+	return err
+}
+
+// checkHosts returns the endpoints of the hosts of the replicaset which are
+// unreachable, and those which have been unreachable for longer than its
+// FailoverPolicy allows, along with how long until the next one will have been.
+// No host is failed if they all would be, as there would be nowhere to
+// recreate their microvms.
+func (r *MicrovmReplicaSetReconciler) checkHosts(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+) ([]string, map[string]bool, time.Duration, error) {
+	mvmHosts := &infrav1.MicrovmHostList{}
+	if err := r.List(ctx, mvmHosts); err != nil {
+		return nil, nil, 0, fmt.Errorf("listing microvmhosts: %w", err)
+	}
+
+	unreachableSince := map[string]time.Time{}
+
+	for _, host := range mvmHosts.Items {
+		if host.Status.UnreachableSince != nil {
+			unreachableSince[host.Spec.Endpoint] = host.Status.UnreachableSince.Time
+		}
+	}
+
+	failoverAfter, failover := mvmReplicaSetScope.FailoverAfter()
+	failover = failover && r.Failover
+	hosts := mvmReplicaSetScope.Hosts()
+	unreachable := []string{}
+	failed := map[string]bool{}
+
+	var next time.Duration
+
+	for _, host := range hosts {
+		since, ok := unreachableSince[host.Endpoint]
+		if !ok {
+			continue
+		}
+
+		unreachable = append(unreachable, host.Endpoint)
+
+		if !failover {
+			continue
+		}
+
+		remaining := failoverAfter - time.Since(since)
+		if remaining <= 0 {
+			failed[host.Endpoint] = true
+		} else if next == 0 || remaining < next {
+			next = remaining
+		}
+	}
+
+	if len(failed) == len(hosts) {
+		failed = map[string]bool{}
+	}
+
+	return unreachable, failed, next, nil
+}
+
+// createMicrovms creates a microvm for each of the replica indices at once,
+// on the host placed at the same position, and returns the errors from every
+// create which failed.
+func (r *MicrovmReplicaSetReconciler) createMicrovms(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+	selector labels.Selector,
+	indices []int,
+	placements []microvm.Host,
+) error {
+	if len(placements) < len(indices) {
+		indices = indices[:len(placements)]
+	}
+
+	errs := parallel(len(indices), 0, func(i int) error {
+		if err := r.createMicrovm(ctx, mvmReplicaSetScope, selector, indices[i], placements[i]); err != nil {
+			return fmt.Errorf("creating replica %d: %w", indices[i], err)
+		}
 
-	return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+		return nil
+	})
+
+	return kerrors.NewAggregate(errs)
 }
 
 func (r *MicrovmReplicaSetReconciler) createMicrovm(
 	ctx context.Context,
 	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+	selector labels.Selector,
+	index int,
+	host microvm.Host,
 ) error {
+	tmpl, err := replica.Render(mvmReplicaSetScope.MicrovmTemplate(), replica.Data{
+		ReplicaIndex:   index,
+		ReplicaSetName: mvmReplicaSetScope.Name(),
+		HostName:       host.Name,
+		HostEndpoint:   host.Endpoint,
+	})
+	if err != nil {
+		return fmt.Errorf("rendering microvm template: %w", err)
+	}
+
+	// a microvm the selector does not match would be released as soon as it
+	// was created, and replaced over and over
+	if selector != nil && !selector.Matches(labels.Set(tmpl.Labels)) {
+		return errSelectorMismatch
+	}
+
 	newMvm := &infrav1.Microvm{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace:    mvmReplicaSetScope.Namespace(),
-			GenerateName: "microvm-",
+			Name:         tmpl.Name,
+			GenerateName: tmpl.GenerateName,
+			Labels:       tmpl.Labels,
+			Annotations:  tmpl.Annotations,
 		},
-		Spec: mvmReplicaSetScope.MicrovmSpec(),
+		Spec: tmpl.Spec,
+	}
+	newMvm.Spec.Host = host
+	imagepin.Pin(&newMvm.Spec.VMSpec, mvmReplicaSetScope.ResolvedImages())
+
+	if newMvm.Name == "" && newMvm.GenerateName == "" {
+		newMvm.GenerateName = "microvm-"
+	}
+
+	if newMvm.Annotations == nil {
+		newMvm.Annotations = map[string]string{}
+	}
+
+	newMvm.Annotations[infrav1.ReplicaIndexAnnotation] = strconv.Itoa(index)
+
+	// a new microvm already comes after any restart asked for
+	if restartedAt := mvmReplicaSetScope.RestartedAt(); restartedAt != "" {
+		newMvm.Annotations[infrav1.RestartedAtAnnotation] = restartedAt
+	}
+
+	if newMvm.Labels == nil {
+		newMvm.Labels = map[string]string{}
+	}
+
+	for k, v := range replica.Provenance(mvmReplicaSetScope.MicrovmReplicaSet) {
+		newMvm.Labels[k] = v
+	}
+
+	newMvm.Labels[infrav1.MicrovmReplicaSetHashLabel] = mvmReplicaSetScope.TemplateHash()
+
+	newMvm.Labels[infrav1.HostEndpointLabel] = replica.LabelValue(host.Endpoint)
+
+	// give every interface without an explicit MAC one which is unique to this
+	// replica, so that replicas are individually addressable, unless a pool is
+	// to allocate them
+	seed := string(mvmReplicaSetScope.MicrovmReplicaSet.UID) + "/" + mvmReplicaSetScope.Name()
+
+	for i := range newMvm.Spec.NetworkInterfaces {
+		iface := &newMvm.Spec.NetworkInterfaces[i]
+		if iface.GuestMAC == "" && newMvm.Spec.MACPoolRef == nil {
+			iface.GuestMAC = replica.MAC(seed, index, iface.GuestDeviceName)
+		}
 	}
-	newMvm.Spec.Host = mvmReplicaSetScope.MicrovmHost()
 
 	if err := controllerutil.SetControllerReference(mvmReplicaSetScope.MicrovmReplicaSet, newMvm, r.Scheme); err != nil {
 		return err
 	}
 
-	return r.Create(ctx, newMvm)
+	return r.Create(ctx, newMvm, apply.FieldOwner)
 }
 
 func (r *MicrovmReplicaSetReconciler) getOwnedMicrovms(
@@ -258,6 +952,9 @@ func (r *MicrovmReplicaSetReconciler) getOwnedMicrovms(
 	opts := []client.ListOption{
 		client.InNamespace(mvmReplicaSetScope.Namespace()),
 	}
+	if r.indexed {
+		opts = append(opts, client.MatchingFields{controllerUIDField: string(mvmReplicaSetScope.MicrovmReplicaSet.UID)})
+	}
 	if err := r.List(ctx, mvmList, opts...); err != nil {
 		return nil, err
 	}
@@ -265,7 +962,7 @@ func (r *MicrovmReplicaSetReconciler) getOwnedMicrovms(
 	owned := []v1alpha1.Microvm{}
 
 	for _, mvm := range mvmList.Items {
-		if metav1.IsControlledBy(&mvm, mvmReplicaSetScope.MicrovmReplicaSet) {
+		if isControlledBy(&mvm, mvmReplicaSetScope.MicrovmReplicaSet, "MicrovmReplicaSet") {
 			owned = append(owned, mvm)
 		}
 	}
@@ -273,10 +970,277 @@ func (r *MicrovmReplicaSetReconciler) getOwnedMicrovms(
 	return owned, nil
 }
 
+// claimMicrovms returns the microvms controlled by the replicaset. When the
+// replicaset has a selector, orphaned microvms on its hosts which match it are
+// adopted and controlled microvms which no longer match it are released, in
+// the same way as the Pod ReplicaSet controller. Microvms which are being
+// deleted are left alone, as are orphans which still belong to another
+// replicaset or were made for another deployment.
+func (r *MicrovmReplicaSetReconciler) claimMicrovms(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+	selector labels.Selector,
+) ([]infrav1.Microvm, error) {
+	if selector == nil {
+		return r.getOwnedMicrovms(ctx, mvmReplicaSetScope)
+	}
+
+	mvms, err := r.listSelectedMicrovms(ctx, mvmReplicaSetScope, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	mvmRS := mvmReplicaSetScope.MicrovmReplicaSet
+	owned := []infrav1.Microvm{}
+
+	for i := range mvms {
+		mvm := &mvms[i]
+		matches := selector.Matches(labels.Set(mvm.Labels))
+		sameHost := replica.HasHost(mvmReplicaSetScope.MicrovmReplicaSet, mvm.Spec.Host.Endpoint)
+
+		switch {
+		case isControlledBy(mvm, mvmReplicaSetScope.MicrovmReplicaSet, "MicrovmReplicaSet"):
+			if matches {
+				owned = append(owned, *mvm)
+
+				continue
+			}
+
+			if mvm.DeletionTimestamp.IsZero() {
+				if err := r.releaseMicrovm(ctx, mvmReplicaSetScope, mvm); err != nil {
+					return nil, err
+				}
+			}
+		case matches && sameHost && metav1.GetControllerOf(mvm) == nil && mvm.DeletionTimestamp.IsZero() &&
+			!isClaimedElsewhere(mvm, mvmRS, "MicrovmReplicaSet", mvmRS.Labels[infrav1.MicrovmDeploymentNameLabel]):
+			if err := r.adoptMicrovm(ctx, mvmReplicaSetScope, mvm); err != nil {
+				return nil, err
+			}
+
+			owned = append(owned, *mvm)
+		}
+	}
+
+	return owned, nil
+}
+
+// listSelectedMicrovms returns the microvms which match the selector, along
+// with those labelled as created by the replicaset so that any which no longer
+// match can be released.
+func (r *MicrovmReplicaSetReconciler) listSelectedMicrovms(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+	selector labels.Selector,
+) ([]infrav1.Microvm, error) {
+	selected := &infrav1.MicrovmList{}
+	if err := r.List(ctx, selected,
+		client.InNamespace(mvmReplicaSetScope.Namespace()),
+		client.MatchingLabelsSelector{Selector: selector},
+	); err != nil {
+		return nil, err
+	}
+
+	named := &infrav1.MicrovmList{}
+	if err := r.List(ctx, named,
+		client.InNamespace(mvmReplicaSetScope.Namespace()),
+		client.MatchingLabels{infrav1.MicrovmReplicaSetNameLabel: mvmReplicaSetScope.Name()},
+	); err != nil {
+		return nil, err
+	}
+
+	mvms := selected.Items
+	seen := map[string]bool{}
+
+	for _, mvm := range selected.Items {
+		seen[mvm.Name] = true
+	}
+
+	for _, mvm := range named.Items {
+		if !seen[mvm.Name] {
+			mvms = append(mvms, mvm)
+		}
+	}
+
+	return mvms, nil
+}
+
+// adoptMicrovm makes the replicaset the controller of an orphaned microvm. It
+// is only done while the replicaset finalizer is in place, so that an adopted
+// microvm is always cleaned up when the replicaset is deleted.
+func (r *MicrovmReplicaSetReconciler) adoptMicrovm(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+	mvm *infrav1.Microvm,
+) error {
+	mvmRS := mvmReplicaSetScope.MicrovmReplicaSet
+	if !mvmRS.DeletionTimestamp.IsZero() || !controllerutil.ContainsFinalizer(mvmRS, infrav1.MvmRSFinalizer) {
+		return nil
+	}
+
+	patch := client.MergeFromWithOptions(mvm.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	if err := controllerutil.SetControllerReference(mvmRS, mvm, r.Scheme); err != nil {
+		return err
+	}
+
+	if mvm.Labels == nil {
+		mvm.Labels = map[string]string{}
+	}
+
+	mvm.Labels[infrav1.MicrovmReplicaSetNameLabel] = mvmReplicaSetScope.Name()
+
+	if name, ok := mvmRS.Labels[infrav1.MicrovmDeploymentNameLabel]; ok {
+		mvm.Labels[infrav1.MicrovmDeploymentNameLabel] = name
+	}
+
+	if err := r.Patch(ctx, mvm, patch, apply.FieldOwner); err != nil {
+		return fmt.Errorf("adopting microvm %s: %w", mvm.Name, err)
+	}
+
+	mvmReplicaSetScope.Info("adopted microvm", logging.MicrovmKey, mvm.Name)
+
+	return nil
+}
+
+// releaseMicrovm removes the replicaset as the controller of a microvm. The
+// labels naming the replicaset and its deployment are removed with it, as
+// otherwise only a replicaset of the same deployment could adopt the orphan.
+func (r *MicrovmReplicaSetReconciler) releaseMicrovm(
+	ctx context.Context,
+	mvmReplicaSetScope *scope.MicrovmReplicaSetScope,
+	mvm *infrav1.Microvm,
+) error {
+	err := release(ctx, r.Client, mvm, mvmReplicaSetScope.MicrovmReplicaSet,
+		infrav1.MicrovmReplicaSetNameLabel, infrav1.MicrovmDeploymentNameLabel)
+	if err != nil {
+		return fmt.Errorf("releasing microvm %s: %w", mvm.Name, err)
+	}
+
+	mvmReplicaSetScope.Info("released microvm", logging.MicrovmKey, mvm.Name)
+
+	return nil
+}
+
+// orphanToReplicaSets maps a microvm without a controller to the replicasets
+// in its namespace whose selector matches it, so that it can be adopted.
+func (r *MicrovmReplicaSetReconciler) orphanToReplicaSets(obj client.Object) []reconcile.Request {
+	if metav1.GetControllerOf(obj) != nil {
+		return nil
+	}
+
+	mvmRSList := &infrav1.MicrovmReplicaSetList{}
+	if err := r.List(context.Background(), mvmRSList, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, mvmRS := range mvmRSList.Items {
+		if mvmRS.Spec.Selector == nil {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(mvmRS.Spec.Selector)
+		if err != nil || !selector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: mvmRS.Namespace, Name: mvmRS.Name},
+		})
+	}
+
+	return requests
+}
+
+// hostToReplicaSets maps a MicrovmHost to the replicasets on it, so that they
+// report as soon as it becomes unreachable or answers again.
+func (r *MicrovmReplicaSetReconciler) hostToReplicaSets(obj client.Object) []reconcile.Request {
+	host, ok := obj.(*infrav1.MicrovmHost)
+	if !ok {
+		return nil
+	}
+
+	mvmRSList := &infrav1.MicrovmReplicaSetList{}
+	if err := r.List(context.Background(), mvmRSList); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, mvmRS := range mvmRSList.Items {
+		if replica.HasHost(&mvmRS, host.Spec.Endpoint) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: mvmRS.Namespace, Name: mvmRS.Name},
+			})
+		}
+	}
+
+	return requests
+}
+
+// templateToReplicaSets maps a MicrovmTemplate to the MicrovmReplicaSets which
+// reference it, so that new revisions are picked up straight away.
+func (r *MicrovmReplicaSetReconciler) templateToReplicaSets(obj client.Object) []reconcile.Request {
+	mvmRSList := &infrav1.MicrovmReplicaSetList{}
+	if err := r.List(context.Background(), mvmRSList, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, mvmRS := range mvmRSList.Items {
+		if ref := mvmRS.Spec.TemplateRef; ref != nil && ref.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: mvmRS.Namespace, Name: mvmRS.Name},
+			})
+		}
+	}
+
+	return requests
+}
+
+// requeuePeriod returns how long to wait before checking on an object which
+// is still converging.
+func (r *MicrovmReplicaSetReconciler) requeuePeriod() time.Duration {
+	return r.Config.RequeuePeriod(config.MicrovmReplicaSet)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *MicrovmReplicaSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := indexByControllerUID(mgr, &infrav1.Microvm{}); err != nil {
+		return fmt.Errorf("indexing microvms by controller: %w", err)
+	}
+
+	r.indexed = true
+
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&infrastructurev1alpha1.MicrovmReplicaSet{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvmreplicaset", logging.ReplicaSetKey)).
 		Owns(&infrastructurev1alpha1.Microvm{}).
-		Complete(r)
+		Watches(
+			&source.Kind{Type: &infrastructurev1alpha1.Microvm{}},
+			handler.EnqueueRequestsFromMapFunc(r.orphanToReplicaSets),
+		).
+		Watches(
+			&source.Kind{Type: &infrastructurev1alpha1.MicrovmHost{}},
+			handler.EnqueueRequestsFromMapFunc(r.hostToReplicaSets),
+		).
+		Watches(
+			&source.Kind{Type: &infrastructurev1alpha1.MicrovmTemplate{}},
+			handler.EnqueueRequestsFromMapFunc(r.templateToReplicaSets),
+		)
+
+	// the MachinePool CRD is only installed alongside Cluster API
+	if r.MachinePools {
+		builder = builder.Watches(
+			&source.Kind{Type: &expv1.MachinePool{}},
+			handler.EnqueueRequestsFromMapFunc(exputil.MachinePoolToInfrastructureMapFunc(
+				infrav1.GroupVersion.WithKind("MicrovmReplicaSet"), mgr.GetLogger())),
+		)
+	}
+
+	return builder.
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(tracing.Reconciler("microvmreplicaset", r))
 }
@@ -2,12 +2,24 @@ package controllers_test
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/replica"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 func TestMicrovmRS_Reconcile_MissingObject(t *testing.T) {
@@ -25,9 +37,10 @@ func TestMicrovmRS_Reconcile_MissingObject(t *testing.T) {
 func TestMicrovmRS_ReconcileNormal_CreateSucceeds(t *testing.T) {
 	g := NewWithT(t)
 
-	// creating a replicaset with 2 replicas
+	// creating a replicaset with 2 replicas, one at a time
 	var expectedReplicas int32 = 2
 	mvmRS := createMicrovmReplicaSet(expectedReplicas)
+	mvmRS.Spec.MaxCreatePerReconcile = pointer.Int32(1)
 	objects := []runtime.Object{mvmRS}
 	client := createFakeClient(g, objects)
 
@@ -73,6 +86,49 @@ func TestMicrovmRS_ReconcileNormal_CreateSucceeds(t *testing.T) {
 	g.Expect(reconciled.Status.ReadyReplicas).To(Equal(expectedReplicas), "Expected all replicas to be ready")
 }
 
+func TestMicrovmRS_ReconcileNormal_CreatesInBatches(t *testing.T) {
+	g := NewWithT(t)
+
+	var expectedReplicas int32 = 7
+	mvmRS := createMicrovmReplicaSet(expectedReplicas)
+	mvmRS.Spec.MaxCreatePerReconcile = pointer.Int32(5)
+	objects := []runtime.Object{mvmRS}
+	client := createFakeClient(g, objects)
+
+	// first reconciliation
+	result, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset the first time should not error")
+	g.Expect(result.IsZero()).To(BeFalse(), "Expect requeue to be requested after create")
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(5)), "Expected a full batch of Microvms to have been created")
+
+	// second reconciliation
+	result, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset the second time should not error")
+	g.Expect(result.IsZero()).To(BeFalse(), "Expect requeue to be requested after create")
+	g.Expect(microvmsCreated(g, client)).To(Equal(expectedReplicas), "Expected only the missing Microvms to have been created")
+
+	mvmList := &infrav1.MicrovmList{}
+	g.Expect(client.List(context.TODO(), mvmList)).To(Succeed())
+
+	indices := []int{}
+	for i := range mvmList.Items {
+		index, ok := replica.Index(&mvmList.Items[i])
+		g.Expect(ok).To(BeTrue(), "Expected each Microvm to have a replica index")
+		indices = append(indices, index)
+	}
+	g.Expect(indices).To(ConsistOf(0, 1, 2, 3, 4, 5, 6), "Expected each Microvm to have its own replica index")
+
+	// final reconciliation
+	ensureMicrovmState(g, client)
+	result, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset the third time should not error")
+	g.Expect(result.IsZero()).To(BeTrue(), "Expect requeue to be not requested after create")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvm should not fail")
+	g.Expect(reconciled.Status.ReadyReplicas).To(Equal(expectedReplicas), "Expected all replicas to be ready")
+}
+
 func TestMicrovmRS_ReconcileNormal_UpdateSucceeds(t *testing.T) {
 	g := NewWithT(t)
 
@@ -190,3 +246,1068 @@ func TestMicrovmRS_ReconcileDelete_DeleteSucceeds(t *testing.T) {
 	reconciled, err = getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
 	g.Expect(err).To(HaveOccurred(), "Getting microvmreplicaset should fail")
 }
+
+func TestMicrovmRS_ReconcileDelete_DeletesInBatches(t *testing.T) {
+	g := NewWithT(t)
+
+	var initialReplicaCount int32 = 3
+
+	mvmRS := createMicrovmReplicaSet(initialReplicaCount)
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+
+	// create
+	g.Expect(reconcileMicrovmReplicaSetNTimes(g, client, initialReplicaCount+1)).To(Succeed())
+
+	// hold the microvms as their controller would until flintlock has removed them
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(int(initialReplicaCount)))
+
+	for _, mvm := range mvmList.Items {
+		mvm.Finalizers = []string{infrav1.MvmFinalizer}
+		g.Expect(client.Update(context.TODO(), &mvm)).To(Succeed())
+	}
+
+	// delete
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.Delete(context.TODO(), reconciled)).To(Succeed())
+
+	cfg := config.NewStore(&configv1.OperatorConfiguration{
+		Flintlock: configv1.FlintlockConfiguration{MaxConcurrentDeletes: 2},
+	})
+
+	_, err = reconcileMicrovmReplicaSetWithConfig(client, cfg)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(microvmsDeleting(g, client)).To(Equal(2), "Expected only a batch of microvms to be deleted")
+
+	_, err = reconcileMicrovmReplicaSetWithConfig(client, cfg)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(microvmsDeleting(g, client)).To(Equal(2), "Expected the next batch to wait for the first")
+
+	// flintlock confirms one of the microvms is gone
+	mvmList, err = listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for _, mvm := range mvmList.Items {
+		if !mvm.DeletionTimestamp.IsZero() {
+			mvm.Finalizers = nil
+			g.Expect(client.Update(context.TODO(), &mvm)).To(Succeed())
+
+			break
+		}
+	}
+
+	_, err = reconcileMicrovmReplicaSetWithConfig(client, cfg)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(microvmsDeleting(g, client)).To(Equal(2), "Expected the last microvm to be deleted")
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(2)))
+}
+
+func TestMicrovmRS_ReconcileDelete_DeletesInBatchesPerHost(t *testing.T) {
+	g := NewWithT(t)
+
+	var initialReplicaCount int32 = 4
+
+	mvmRS := createMicrovmReplicaSet(initialReplicaCount)
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+
+	// create
+	g.Expect(reconcileMicrovmReplicaSetNTimes(g, client, initialReplicaCount+1)).To(Succeed())
+
+	// move half of the microvms to a second host, and hold them all as their
+	// controller would until flintlock has removed them
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(int(initialReplicaCount)))
+
+	for i, mvm := range mvmList.Items {
+		if i%2 == 1 {
+			mvm.Spec.Host.Endpoint = "127.0.0.2:9090"
+		}
+
+		mvm.Finalizers = []string{infrav1.MvmFinalizer}
+		g.Expect(client.Update(context.TODO(), &mvm)).To(Succeed())
+	}
+
+	deletingByHost := func() map[string]int {
+		mvmList, err := listMicrovm(client)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		deleting := map[string]int{}
+
+		for _, mvm := range mvmList.Items {
+			if !mvm.DeletionTimestamp.IsZero() {
+				deleting[mvm.Spec.Host.Endpoint]++
+			}
+		}
+
+		return deleting
+	}
+
+	// delete
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.Delete(context.TODO(), reconciled)).To(Succeed())
+
+	cfg := config.NewStore(&configv1.OperatorConfiguration{
+		Flintlock: configv1.FlintlockConfiguration{MaxConcurrentDeletes: 1},
+	})
+
+	_, err = reconcileMicrovmReplicaSetWithConfig(client, cfg)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(deletingByHost()).To(Equal(map[string]int{testHostEndpoint: 1, "127.0.0.2:9090": 1}),
+		"Expected a batch to be deleted from each host")
+
+	// flintlock confirms the microvm on the second host is gone
+	mvmList, err = listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for _, mvm := range mvmList.Items {
+		if !mvm.DeletionTimestamp.IsZero() && mvm.Spec.Host.Endpoint == "127.0.0.2:9090" {
+			mvm.Finalizers = nil
+			g.Expect(client.Update(context.TODO(), &mvm)).To(Succeed())
+		}
+	}
+
+	_, err = reconcileMicrovmReplicaSetWithConfig(client, cfg)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(deletingByHost()).To(Equal(map[string]int{testHostEndpoint: 1, "127.0.0.2:9090": 1}),
+		"Expected the second host not to wait for the first")
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(3)))
+}
+
+func TestMicrovmRS_ReconcileDelete_OrphanReleasesMicrovms(t *testing.T) {
+	g := NewWithT(t)
+
+	var initialReplicaCount int32 = 2
+
+	mvmRS := createMicrovmReplicaSet(initialReplicaCount)
+	mvmRS.Labels = map[string]string{infrav1.MicrovmDeploymentNameLabel: testMicrovmDeploymentName}
+	mvmRS.Spec.DeletePolicy = infrav1.DeletePolicyOrphan
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+
+	// create
+	g.Expect(reconcileMicrovmReplicaSetNTimes(g, client, initialReplicaCount+1)).To(Succeed())
+	g.Expect(microvmsCreated(g, client)).To(Equal(initialReplicaCount), "Expected 2 microvms to exist")
+
+	// delete
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.Delete(context.TODO(), reconciled)).To(Succeed())
+	g.Expect(reconcileMicrovmReplicaSetNTimes(g, client, 2)).To(Succeed())
+
+	_, err = getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).To(HaveOccurred(), "Expected the microvmreplicaset to be gone")
+
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(int(initialReplicaCount)), "Expected the microvms to survive")
+
+	for _, mvm := range mvmList.Items {
+		g.Expect(mvm.OwnerReferences).To(BeEmpty(), "Expected the microvm to be released")
+		g.Expect(mvm.Labels).NotTo(HaveKey(infrav1.MicrovmReplicaSetNameLabel))
+		g.Expect(mvm.Labels).NotTo(HaveKey(infrav1.MicrovmDeploymentNameLabel), "Expected any replicaset to be able to adopt it")
+	}
+}
+
+func TestMicrovmRS_ReconcileNormal_ProvenanceLabels(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.Labels = map[string]string{
+		infrav1.MicrovmDeploymentNameLabel: testMicrovmDeploymentName,
+		infrav1.MicrovmReplicaSetHashLabel: "abc123",
+	}
+	mvmRS.Spec.Template.Labels = map[string]string{"app": "web"}
+	mvmRS.Spec.Template.Annotations = map[string]string{"team": "platform"}
+
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+	g.Expect(reconcileMicrovmReplicaSetNTimes(g, client, 2)).To(Succeed())
+
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(1))
+
+	mvm := mvmList.Items[0]
+	g.Expect(mvm.Labels).To(Equal(map[string]string{
+		"app":                              "web",
+		infrav1.MicrovmReplicaSetNameLabel: testMicrovmReplicaSetName,
+		infrav1.MicrovmDeploymentNameLabel: testMicrovmDeploymentName,
+		infrav1.MicrovmReplicaSetHashLabel: "abc123",
+		infrav1.HostEndpointLabel:          replica.LabelValue(mvmRS.Spec.Host.Endpoint),
+	}))
+	g.Expect(mvm.Annotations).To(HaveKeyWithValue("team", "platform"))
+}
+
+func TestMicrovmRS_ReconcileNormal_ReplicasAreIndividuallyAddressable(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(2)
+	mvmRS.Spec.Template.Labels = map[string]string{"replica": "{{ .ReplicaSetName }}-{{ .ReplicaIndex }}"}
+	objects := []runtime.Object{mvmRS}
+	client := createFakeClient(g, objects)
+
+	// one microvm is created per reconcile
+	for i := 0; i < 2; i++ {
+		_, err := reconcileMicrovmReplicaSet(client)
+		g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	}
+
+	mvms, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvms.Items).To(HaveLen(2))
+
+	labels := []string{}
+	indexes := []string{}
+	macs := map[string]bool{}
+
+	for _, mvm := range mvms.Items {
+		labels = append(labels, mvm.Labels["replica"])
+		indexes = append(indexes, mvm.Annotations[infrav1.ReplicaIndexAnnotation])
+		macs[mvm.Spec.NetworkInterfaces[0].GuestMAC] = true
+	}
+
+	g.Expect(labels).To(ConsistOf(testMicrovmReplicaSetName+"-0", testMicrovmReplicaSetName+"-1"))
+	g.Expect(indexes).To(ConsistOf("0", "1"))
+	g.Expect(macs).To(HaveLen(2), "Expected each replica to have its own MAC")
+	g.Expect(macs).NotTo(HaveKey(""))
+}
+
+func TestMicrovmRS_ReconcileNormal_FixedTemplateName(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(2)
+	mvmRS.Spec.Template.Name = "web"
+	objects := []runtime.Object{mvmRS}
+	client := createFakeClient(g, objects)
+
+	for i := 0; i < 2; i++ {
+		_, err := reconcileMicrovmReplicaSet(client)
+		g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	}
+
+	mvms, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvms.Items).To(HaveLen(2), "Expected every replica to be created under a name of its own")
+
+	for _, mvm := range mvms.Items {
+		g.Expect(mvm.Name).To(HavePrefix("web-"))
+	}
+}
+
+func TestMicrovmRS_ReconcileNormal_MACPoolLeavesMACsUnset(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.Spec.Template.Spec.MACPoolRef = &corev1.LocalObjectReference{Name: testMicrovmMACPoolName}
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	mvms, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvms.Items).To(HaveLen(1))
+	g.Expect(mvms.Items[0].Spec.NetworkInterfaces[0].GuestMAC).To(BeEmpty(), "Expected the pool to be left to allocate the MAC")
+}
+
+func TestMicrovmRS_ReconcileNormal_ObservedGeneration(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.Generation = 3
+
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmreplicaset should not fail")
+	g.Expect(reconciled.Status.ObservedGeneration).To(Equal(int64(3)))
+}
+
+func TestMicrovmRS_ReconcileNormal_AdoptsAndReleases(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(2)
+	mvmRS.Finalizers = []string{infrav1.MvmRSFinalizer}
+	mvmRS.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	mvmRS.Spec.Template.Labels = map[string]string{"app": "web"}
+
+	orphan := createMicrovm()
+	orphan.Name = "orphan"
+	orphan.Labels = map[string]string{"app": "web"}
+
+	stale := createMicrovm()
+	stale.Name = "stale"
+	stale.Labels = map[string]string{"app": "db", infrav1.MicrovmReplicaSetNameLabel: testMicrovmReplicaSetName}
+	stale.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(mvmRS, infrav1.GroupVersion.WithKind("MicrovmReplicaSet")),
+	}
+
+	foreign := createMicrovm()
+	foreign.Name = "foreign"
+	foreign.Labels = map[string]string{"app": "web"}
+	foreign.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: infrav1.GroupVersion.String(),
+		Kind:       "MicrovmReplicaSet",
+		Name:       "other",
+		UID:        "other",
+		Controller: pointer.Bool(true),
+	}}
+
+	elsewhere := createMicrovm()
+	elsewhere.Name = "elsewhere"
+	elsewhere.Labels = map[string]string{"app": "web"}
+	elsewhere.Spec.Host.Endpoint = "127.0.0.2:9090"
+
+	client := createFakeClient(g, []runtime.Object{mvmRS, orphan, stale, foreign, elsewhere})
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	reconciledRS, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmreplicaset should not fail")
+	g.Expect(reconciledRS.Status.Replicas).To(Equal(int32(1)), "Expected the orphan to be counted")
+	g.Expect(reconciledRS.Status.Selector).To(Equal("app=web"), "Expected the selector to be recorded for scaling")
+
+	adopted, err := getMicrovm(client, "orphan", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(metav1.IsControlledBy(adopted, reconciledRS)).To(BeTrue(), "Expected the orphan to be adopted")
+	g.Expect(adopted.Labels).To(HaveKeyWithValue(infrav1.MicrovmReplicaSetNameLabel, testMicrovmReplicaSetName))
+
+	released, err := getMicrovm(client, "stale", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(released.OwnerReferences).To(BeEmpty(), "Expected the microvm which no longer matches to be released")
+	g.Expect(released.Labels).NotTo(HaveKey(infrav1.MicrovmReplicaSetNameLabel))
+
+	untouched, err := getMicrovm(client, "foreign", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(metav1.GetControllerOf(untouched).Name).To(Equal("other"), "Expected a microvm with another controller to be left alone")
+
+	orphaned, err := getMicrovm(client, "elsewhere", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(orphaned.OwnerReferences).To(BeEmpty(), "Expected an orphan on another host to be left alone")
+
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(5)), "Expected one microvm to be created to make up the replicas")
+}
+
+func TestMicrovmRS_ReconcileNormal_SelectorMustMatchTemplate(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	mvmRS.Spec.Template.Labels = map[string]string{"app": "db"}
+
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmreplicaset should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReplicaSetReadyCondition, infrav1.MicrovmReplicaSetInvalidSelectorReason)
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(0)), "Expected no microvms to be created")
+}
+
+func TestMicrovmRS_ReconcileNormal_ExternalReplicas(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.Finalizers = []string{infrav1.MvmRSFinalizer}
+	controllerRef := *metav1.NewControllerRef(mvmRS, infrav1.GroupVersion.WithKind("MicrovmReplicaSet"))
+
+	canary := createMicrovm()
+	canary.Name = "canary"
+	canary.Annotations = map[string]string{infrav1.OwnershipAnnotation: infrav1.OwnershipExternal}
+	canary.OwnerReferences = []metav1.OwnerReference{controllerRef}
+	canary.Status.Ready = true
+
+	managed := createMicrovm()
+	managed.Name = "managed"
+	managed.OwnerReferences = []metav1.OwnerReference{controllerRef}
+	managed.Status.Ready = true
+
+	client := createFakeClient(g, []runtime.Object{mvmRS, canary, managed})
+
+	// scaling down removes the managed microvm, never the external one
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	_, err = getMicrovm(client, "managed", testNamespace)
+	g.Expect(err).To(HaveOccurred(), "Expected the managed microvm to be deleted")
+	_, err = getMicrovm(client, "canary", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Expected the external microvm to be kept")
+
+	// scaling down past the external microvm is refused
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	reconciled.Spec.Replicas = pointer.Int32(0)
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	reconciled, err = getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReplicaSetReadyCondition, infrav1.MicrovmReplicaSetExternalReplicasReason)
+	g.Expect(reconciled.Status.Replicas).To(Equal(int32(1)), "Expected the external microvm to be counted")
+
+	// deleting the replicaset releases the external microvm
+	g.Expect(client.Delete(context.TODO(), reconciled)).To(Succeed())
+	g.Expect(reconcileMicrovmReplicaSetNTimes(g, client, 3)).To(Succeed())
+
+	_, err = getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).To(HaveOccurred(), "Expected the microvmreplicaset to be gone")
+
+	released, err := getMicrovm(client, "canary", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Expected the external microvm to survive")
+	g.Expect(released.OwnerReferences).To(BeEmpty(), "Expected the external microvm to be released")
+}
+
+func TestMicrovmRS_ReconcileNormal_ResolvesImagesOnce(t *testing.T) {
+	g := NewWithT(t)
+
+	digest := "sha256:" + strings.Repeat("c", 64)
+	kernel := "docker.io/richardcase/ubuntu-bionic-kernel:0.0.11"
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.Spec.Template.Spec.ImagePolicy = infrav1.ImagePolicyResolve
+
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+	resolver := &fakeImageResolver{digest: digest}
+	reconciler := &controllers.MicrovmReplicaSetReconciler{Client: client, Scheme: client.Scheme(), ImageResolver: resolver}
+
+	_, err := reconcileMicrovmReplicaSetWith(reconciler)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolver.images).To(HaveLen(2), "Expected the kernel and root volume tags to be resolved")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.ResolvedImages).To(HaveKeyWithValue(kernel, kernel+"@"+digest))
+
+	// the tag moves, but replicas created later must boot the same image
+	resolver.digest = "sha256:" + strings.Repeat("d", 64)
+	reconciled.Spec.Replicas = pointer.Int32(2)
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmReplicaSetWith(reconciler)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolver.images).To(HaveLen(2), "Expected resolved tags not to be resolved again")
+
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(2))
+
+	for _, mvm := range mvmList.Items {
+		g.Expect(mvm.Spec.Kernel.Image).To(Equal(kernel + "@" + digest))
+		g.Expect(mvm.Spec.Initrd.Image).To(Equal(kernel + "@" + digest))
+	}
+}
+
+func TestMicrovmRS_ReconcileNormal_RestartPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        infrav1.RestartPolicy
+		expectReplace map[string]bool
+	}{
+		{name: "never", policy: infrav1.RestartPolicyNever},
+		{name: "on failure", policy: infrav1.RestartPolicyOnFailure, expectReplace: map[string]bool{"failed": true}},
+		{name: "always", policy: infrav1.RestartPolicyAlways, expectReplace: map[string]bool{"failed": true, "unknown": true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvmRS := createMicrovmReplicaSet(4)
+			mvmRS.Finalizers = []string{infrav1.MvmRSFinalizer}
+			mvmRS.Spec.RestartPolicy = tc.policy
+			controllerRef := *metav1.NewControllerRef(mvmRS, infrav1.GroupVersion.WithKind("MicrovmReplicaSet"))
+
+			objects := []runtime.Object{mvmRS}
+			states := map[string]microvm.VMState{
+				"running":   microvm.VMStateRunning,
+				"failed":    microvm.VMStateFailed,
+				"unknown":   microvm.VMStateUnknown,
+				"protected": microvm.VMStateFailed,
+			}
+
+			for name, state := range states {
+				state := state
+				mvm := createMicrovm()
+				mvm.Name = name
+				mvm.OwnerReferences = []metav1.OwnerReference{controllerRef}
+				mvm.Status.VMState = &state
+
+				if name == "protected" {
+					mvm.Annotations = map[string]string{infrav1.DeleteProtectionAnnotation: "true"}
+				}
+
+				objects = append(objects, mvm)
+			}
+
+			client := createFakeClient(g, objects)
+
+			_, err := reconcileMicrovmReplicaSet(client)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+			for name := range states {
+				_, err := getMicrovm(client, name, testNamespace)
+				if tc.expectReplace[name] {
+					g.Expect(err).To(HaveOccurred(), "Expected microvm %s to be deleted", name)
+				} else {
+					g.Expect(err).NotTo(HaveOccurred(), "Expected microvm %s to be kept", name)
+				}
+			}
+
+			reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			if len(tc.expectReplace) == 0 {
+				g.Expect(conditions.GetReason(reconciled, infrav1.MicrovmReplicaSetReadyCondition)).
+					NotTo(Equal(infrav1.MicrovmReplicaSetReplacingFailedReason))
+
+				return
+			}
+
+			assertConditionFalse(g, reconciled, infrav1.MicrovmReplicaSetReadyCondition, infrav1.MicrovmReplicaSetReplacingFailedReason)
+
+			// the replacements are created once the failed microvms are gone
+			_, err = reconcileMicrovmReplicaSet(client)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+			mvmList, err := listMicrovm(client)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mvmList.Items).To(HaveLen(4), "Expected the failed microvms to be replaced")
+		})
+	}
+}
+
+func TestMicrovmRS_ReconcileNormal_HostUnreachable(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.Finalizers = []string{infrav1.MvmRSFinalizer}
+
+	since := metav1.Now()
+	mvmH := createMicrovmHost()
+	mvmH.Spec.Endpoint = mvmRS.Spec.Host.Endpoint
+	mvmH.Status.UnreachableSince = &since
+
+	client := createFakeClient(g, []runtime.Object{mvmRS, mvmH})
+
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReplicaSetHostReachableCondition, infrav1.MicrovmReplicaSetHostUnreachableReason)
+
+	// the host answers again
+	mvmH.Status.UnreachableSince = nil
+	g.Expect(client.Status().Update(context.TODO(), mvmH)).To(Succeed())
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	reconciled, err = getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionTrue(g, reconciled, infrav1.MicrovmReplicaSetHostReachableCondition)
+}
+
+func TestMicrovmRS_ReconcileNormal_MultipleHosts(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(4)
+	mvmRS.Spec.Host = microvm.Host{}
+	mvmRS.Spec.Hosts = []microvm.Host{{Endpoint: "127.0.0.1:9090"}, {Endpoint: "127.0.0.2:9090"}}
+
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(4))
+
+	perHost := map[string]int{}
+	for _, mvm := range mvmList.Items {
+		perHost[mvm.Spec.Host.Endpoint]++
+		g.Expect(mvm.Labels).To(HaveKeyWithValue(infrav1.HostEndpointLabel, replica.LabelValue(mvm.Spec.Host.Endpoint)))
+	}
+
+	g.Expect(perHost).To(Equal(map[string]int{"127.0.0.1:9090": 2, "127.0.0.2:9090": 2}),
+		"Expected the microvms to be spread across the hosts")
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.HostSummaries).To(Equal([]infrav1.ReplicaSetHostSummary{
+		{Host: "127.0.0.1:9090", Replicas: 2},
+		{Host: "127.0.0.2:9090", Replicas: 2},
+	}))
+
+	for _, summary := range reconciled.Status.MicrovmSummaries {
+		g.Expect(summary.ReplicaIndex).NotTo(BeNil(), "Expected the replica index of each microvm to be recorded")
+	}
+
+	// a third host is added and the replicaset is scaled in: the remaining
+	// microvms stay spread
+	reconciled.Spec.Hosts = append(reconciled.Spec.Hosts, microvm.Host{Endpoint: "127.0.0.3:9090"})
+	reconciled.Spec.Replicas = pointer.Int32(2)
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	g.Expect(reconcileMicrovmReplicaSetNTimes(g, client, 2)).To(Succeed())
+
+	mvmList, err = listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(2))
+	g.Expect(mvmList.Items[0].Spec.Host.Endpoint).NotTo(Equal(mvmList.Items[1].Spec.Host.Endpoint))
+}
+
+func TestMicrovmRS_ReconcileNormal_NoHosts(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.Spec.Host = microvm.Host{}
+
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReplicaSetReadyCondition, infrav1.MicrovmReplicaSetNoHostsReason)
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(0)))
+}
+
+func TestMicrovmRS_ReconcileNormal_HostFailover(t *testing.T) {
+	g := NewWithT(t)
+
+	healthy, lost := "127.0.0.1:9090", "127.0.0.2:9090"
+
+	mvmRS := createMicrovmReplicaSet(2)
+	mvmRS.Spec.Host = microvm.Host{}
+	mvmRS.Spec.Hosts = []microvm.Host{{Endpoint: healthy}, {Endpoint: lost}}
+	mvmRS.Spec.FailoverPolicy = &infrav1.FailoverPolicy{UnreachableSeconds: 60}
+
+	mvmH := createMicrovmHost()
+	mvmH.Spec.Endpoint = lost
+
+	client := createFakeClient(g, []runtime.Object{mvmRS, mvmH})
+
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(2)))
+
+	// the host has only just stopped answering, so its microvm is kept
+	since := metav1.NewTime(time.Now().Add(-30 * time.Second))
+	mvmH.Status.UnreachableSince = &since
+	g.Expect(client.Status().Update(context.TODO(), mvmH)).To(Succeed())
+
+	result, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically("<=", 30*time.Second))
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(2)))
+
+	// the host has been unreachable for too long, so its microvm is
+	// recreated on the other
+	since = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	mvmH.Status.UnreachableSince = &since
+	g.Expect(client.Status().Update(context.TODO(), mvmH)).To(Succeed())
+
+	_, err = reconcileMicrovmReplicaSetWith(&controllers.MicrovmReplicaSetReconciler{Client: client, Scheme: client.Scheme()})
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(2)), "Expected no failover with the feature gate off")
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(3))
+
+	onHealthy := 0
+	for _, mvm := range mvmList.Items {
+		if mvm.Spec.Host.Endpoint == healthy {
+			onHealthy++
+		}
+	}
+
+	g.Expect(onHealthy).To(Equal(2), "Expected the replacement to be created on the healthy host")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.HostSummaries).To(ContainElement(infrav1.ReplicaSetHostSummary{Host: lost, Replicas: 1, Failed: true}))
+
+	// the host answers again, and the replicaset has one microvm too many
+	mvmH.Status.UnreachableSince = nil
+	g.Expect(client.Status().Update(context.TODO(), mvmH)).To(Succeed())
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	mvmList, err = listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(2))
+	g.Expect(mvmList.Items[0].Spec.Host.Endpoint).NotTo(Equal(mvmList.Items[1].Spec.Host.Endpoint))
+}
+
+func TestMicrovmRS_ReconcileNormal_MicrovmSummaries(t *testing.T) {
+	g := NewWithT(t)
+
+	var replicas int32 = 2
+
+	mvmRS := createMicrovmReplicaSet(replicas)
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+
+	g.Expect(reconcileMicrovmReplicaSetNTimes(g, client, replicas+1)).To(Succeed())
+
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(int(replicas)))
+
+	// one of the replicas fails on its host
+	failed := mvmList.Items[0]
+	failed.Status.Ready = false
+	failed.Status.VMState = &microvm.VMStateFailed
+	failed.Status.FailureReason = pointer.String("BootFailed")
+	g.Expect(client.Update(context.TODO(), &failed)).To(Succeed())
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.MicrovmSummaries).To(HaveLen(int(replicas)))
+
+	for i, summary := range reconciled.Status.MicrovmSummaries {
+		if i > 0 {
+			g.Expect(summary.Name > reconciled.Status.MicrovmSummaries[i-1].Name).To(BeTrue(), "Expected summaries to be ordered by name")
+		}
+
+		g.Expect(summary.Host).To(Equal(mvmRS.Spec.Host.Endpoint))
+
+		if summary.Name == failed.Name {
+			g.Expect(summary.Ready).To(BeFalse())
+			g.Expect(summary.VMState).NotTo(BeNil())
+			g.Expect(*summary.VMState).To(Equal(microvm.VMStateFailed))
+			g.Expect(summary.FailureReason).To(Equal("BootFailed"))
+
+			continue
+		}
+
+		g.Expect(summary.Ready).To(BeTrue())
+		g.Expect(summary.FailureReason).To(BeEmpty())
+	}
+}
+
+func TestMicrovmRS_ReconcileNormal_AvailableReplicas(t *testing.T) {
+	g := NewWithT(t)
+
+	var replicas int32 = 2
+
+	mvmRS := createMicrovmReplicaSet(replicas)
+	mvmRS.Spec.MinReadySeconds = 60
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+
+	g.Expect(reconcileMicrovmReplicaSetNTimes(g, client, replicas)).To(Succeed())
+
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(int(replicas)))
+
+	// one replica has been ready for long enough, the other only just became ready
+	for i, readyFor := range []time.Duration{time.Hour, 10 * time.Second} {
+		mvm := mvmList.Items[i]
+		mvm.Status.Ready = true
+		mvm.Status.Conditions = clusterv1.Conditions{{
+			Type:               infrav1.MicrovmReadyCondition,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-readyFor)),
+		}}
+		g.Expect(client.Update(context.TODO(), &mvm)).To(Succeed())
+	}
+
+	result, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically("~", 50*time.Second, 5*time.Second),
+		"Expected the replicaset to come back once the other replica is available")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.ReadyReplicas).To(Equal(replicas))
+	g.Expect(reconciled.Status.AvailableReplicas).To(Equal(int32(1)))
+	g.Expect(reconciled.Status.Ready).To(BeTrue())
+}
+
+func TestMicrovmRS_ReconcileNormal_MachinePool(t *testing.T) {
+	g := NewWithT(t)
+
+	machinePool := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Name: "pool1", Namespace: testNamespace, UID: "pool1-uid"},
+		Spec:       expv1.MachinePoolSpec{Replicas: pointer.Int32(2)},
+	}
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: expv1.GroupVersion.String(),
+		Kind:       "MachinePool",
+		Name:       machinePool.Name,
+		UID:        machinePool.UID,
+	}}
+
+	client := createFakeClient(g, []runtime.Object{machinePool, mvmRS})
+	reconciler := &controllers.MicrovmReplicaSetReconciler{
+		Client:       client,
+		Scheme:       client.Scheme(),
+		MachinePools: true,
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := reconcileMicrovmReplicaSetWith(reconciler)
+		g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	}
+
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(HaveLen(2), "Expected the replicas of the machinepool to be created")
+
+	for i, id := range []string{"microvm://127.0.0.1:9090/vm2", "microvm://127.0.0.1:9090/vm1"} {
+		mvm := mvmList.Items[i]
+		mvm.Spec.ProviderID = pointer.String(id)
+		g.Expect(client.Update(context.TODO(), &mvm)).To(Succeed())
+	}
+
+	_, err = reconcileMicrovmReplicaSetWith(reconciler)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Spec.Replicas).To(Equal(pointer.Int32(2)))
+	g.Expect(reconciled.Spec.ProviderIDList).To(Equal([]string{
+		"microvm://127.0.0.1:9090/vm1",
+		"microvm://127.0.0.1:9090/vm2",
+	}))
+	g.Expect(reconciled.Status.Replicas).To(Equal(int32(2)))
+
+	// without the feature the owner is ignored
+	mvmRS = createMicrovmReplicaSet(1)
+	mvmRS.OwnerReferences = reconciled.OwnerReferences
+	client = createFakeClient(g, []runtime.Object{machinePool, mvmRS})
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	reconciled, err = getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Spec.Replicas).To(Equal(pointer.Int32(1)))
+	g.Expect(reconciled.Spec.ProviderIDList).To(BeEmpty())
+}
+
+func TestMicrovmRS_ReconcileNormal_RestartedAt(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(2)
+	mvmRS.Finalizers = []string{infrav1.MvmRSFinalizer}
+	mvmRS.Annotations = map[string]string{infrav1.RestartedAtAnnotation: "t1"}
+	controllerRef := *metav1.NewControllerRef(mvmRS, infrav1.GroupVersion.WithKind("MicrovmReplicaSet"))
+
+	objects := []runtime.Object{mvmRS}
+
+	for _, name := range []string{"mvm-a", "mvm-b"} {
+		mvm := createMicrovm()
+		mvm.Name = name
+		mvm.OwnerReferences = []metav1.OwnerReference{controllerRef}
+		mvm.Status.Ready = true
+
+		objects = append(objects, mvm)
+	}
+
+	client := createFakeClient(g, objects)
+
+	restarted := func() []string {
+		mvmList, err := listMicrovm(client)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		names := []string{}
+
+		for _, mvm := range mvmList.Items {
+			if mvm.Annotations[infrav1.RestartedAtAnnotation] == "t1" {
+				names = append(names, mvm.Name)
+			}
+		}
+
+		return names
+	}
+
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(restarted()).To(ConsistOf("mvm-a"), "Expected one microvm to be restarted at a time")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReplicaSetReadyCondition, infrav1.MicrovmReplicaSetRestartingReason)
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(restarted()).To(ConsistOf("mvm-a"), "Expected the next microvm to wait until the first is recreated")
+
+	recreated, err := getMicrovm(client, "mvm-a", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	recreated.Status.RestartedAt = "t1"
+	g.Expect(client.Update(context.TODO(), recreated)).To(Succeed())
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+	g.Expect(restarted()).To(ConsistOf("mvm-a", "mvm-b"))
+
+	recreated, err = getMicrovm(client, "mvm-b", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	recreated.Status.RestartedAt = "t1"
+	g.Expect(client.Update(context.TODO(), recreated)).To(Succeed())
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	reconciled, err = getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	assertConditionTrue(g, reconciled, infrav1.MicrovmReplicaSetReadyCondition)
+}
+
+func TestMicrovmRS_Reconcile_DryRun(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(2)
+	mvmRS.Annotations = map[string]string{infrav1.DryRunAnnotation: "true"}
+
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset in dry-run mode should not error")
+
+	mvmList, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvmList.Items).To(BeEmpty(), "Expected no microvms to be created in dry-run mode")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciled.Status.DryRun).NotTo(BeNil())
+	g.Expect(reconciled.Status.DryRun.Actions).To(HaveLen(2))
+	g.Expect(reconciled.Status.DryRun.Actions).To(HaveEach(And(
+		HaveField("Verb", "Create"),
+		HaveField("Target", HavePrefix("Microvm/")),
+	)))
+}
+
+func TestMicrovmRS_ReconcileNormal_AdoptsOnlyUnclaimedOrphans(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.Finalizers = []string{infrav1.MvmRSFinalizer}
+	mvmRS.Labels = map[string]string{infrav1.MicrovmDeploymentNameLabel: testMicrovmDeploymentName}
+	mvmRS.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	mvmRS.Spec.Template.Labels = map[string]string{"app": "web"}
+
+	otherDeployment := createMicrovm()
+	otherDeployment.Name = "other-deployment"
+	otherDeployment.Labels = map[string]string{"app": "web", infrav1.MicrovmDeploymentNameLabel: "d2"}
+
+	otherReplicaSet := createMicrovm()
+	otherReplicaSet.Name = "other-replicaset"
+	otherReplicaSet.Labels = map[string]string{"app": "web"}
+	otherReplicaSet.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: infrav1.GroupVersion.String(),
+		Kind:       "MicrovmReplicaSet",
+		Name:       "rs2",
+		UID:        "rs2-uid",
+	}}
+
+	unclaimed := createMicrovm()
+	unclaimed.Name = "unclaimed"
+	unclaimed.Labels = map[string]string{"app": "web"}
+
+	sameName := createMicrovm()
+	sameName.Name = "same-name"
+	sameName.Labels = map[string]string{"app": "web", infrav1.MicrovmReplicaSetNameLabel: testMicrovmReplicaSetName}
+	sameName.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: infrav1.GroupVersion.String(),
+		Kind:       "MicrovmReplicaSet",
+		Name:       testMicrovmReplicaSetName,
+		UID:        "replaced-uid",
+		Controller: pointer.Bool(true),
+	}}
+
+	client := createFakeClient(g, []runtime.Object{mvmRS, otherDeployment, otherReplicaSet, unclaimed, sameName})
+	_, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmreplicaset should not error")
+
+	adopted, err := getMicrovm(client, "unclaimed", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(metav1.GetControllerOf(adopted)).NotTo(BeNil(), "Expected an orphan claimed by no deployment to be adopted")
+	g.Expect(adopted.Labels).To(HaveKeyWithValue(infrav1.MicrovmDeploymentNameLabel, testMicrovmDeploymentName),
+		"Expected the orphan to be labelled with the deployment of its replicaset")
+
+	for _, name := range []string{"other-deployment", "other-replicaset"} {
+		mvm, err := getMicrovm(client, name, testNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(metav1.GetControllerOf(mvm)).To(BeNil(), "Expected %s, which is claimed elsewhere, not to be adopted", name)
+	}
+
+	replaced, err := getMicrovm(client, "same-name", testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(metav1.GetControllerOf(replaced).UID).To(BeEquivalentTo("replaced-uid"),
+		"Expected a microvm of an earlier replicaset with the same name to be left alone")
+
+	reconciledRS, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reconciledRS.Status.Replicas).To(Equal(int32(1)), "Expected only the unclaimed orphan to be counted")
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(4)), "Expected no microvm to be created alongside the adopted one")
+}
+
+func TestMicrovmRS_ReconcileNormal_TemplateRef(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmRS := createMicrovmReplicaSet(1)
+	mvmRS.Finalizers = []string{infrav1.MvmRSFinalizer}
+	mvmRS.Spec.TemplateRef = &corev1.LocalObjectReference{Name: testMicrovmTemplateName}
+
+	client := createFakeClient(g, []runtime.Object{mvmRS})
+	result, err := reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling with a missing template should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expect requeue to be requested")
+	g.Expect(microvmsCreated(g, client)).To(Equal(int32(0)), "Expected no microvms before the template exists")
+
+	reconciled, err := getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmreplicaset should not fail")
+	assertConditionFalse(g, reconciled, infrav1.MicrovmReplicaSetReadyCondition, infrav1.MicrovmReplicaSetTemplateNotReadyReason)
+
+	mvmT := createMicrovmTemplate("")
+	mvmT.Source = nil
+	mvmT.Template.Labels = map[string]string{"os": "ubuntu"}
+	mvmT.Template.Spec = mvmRS.Spec.Template.Spec
+	mvmT.Template.Spec.VCPU = 4
+	g.Expect(client.Create(context.TODO(), mvmT)).To(Succeed())
+
+	_, err = reconcileMicrovmTemplate(client, nil)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmtemplate should not error")
+
+	_, err = reconcileMicrovmReplicaSet(client)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling with a ready template should not error")
+
+	mvms, err := listMicrovm(client)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mvms.Items).To(HaveLen(1))
+	g.Expect(mvms.Items[0].Spec.VCPU).To(Equal(int64(4)), "Expected the microvm to be created from the referenced template")
+	g.Expect(mvms.Items[0].Labels).To(HaveKeyWithValue("os", "ubuntu"))
+	g.Expect(mvms.Items[0].Labels).To(HaveKeyWithValue(infrav1.MicrovmReplicaSetHashLabel, replica.TemplateHash(mvmT.Template)),
+		"Expected the hash of the referenced template to be recorded")
+
+	reconciled, err = getMicrovmReplicaSet(client, testMicrovmReplicaSetName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmreplicaset should not fail")
+	g.Expect(reconciled.Status.TemplateRevision).To(Equal(int64(1)), "Expected the revision of the template to be recorded")
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+// MicrovmSnapshotReconciler reconciles a MicrovmSnapshot object
+type MicrovmSnapshotReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmsnapshots,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmsnapshots/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmsnapshots/finalizers,verbs=update
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch
+
+func (r *MicrovmSnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	mvmSnap := &infrav1.MicrovmSnapshot{}
+	if err := r.Get(ctx, req.NamespacedName, mvmSnap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "error getting microvmsnapshot")
+
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
+	}
+
+	if !mvmSnap.ObjectMeta.DeletionTimestamp.IsZero() {
+		// the snapshot only refers to images, so there is nothing to clean up
+		return ctrl.Result{}, nil
+	}
+
+	mvmSnapshotScope, err := scope.NewMicrovmSnapshotScope(scope.MicrovmSnapshotScopeParams{
+		MicrovmSnapshot: mvmSnap,
+		Client:          r.Client,
+		Context:         ctx,
+		Logger:          log,
+	})
+	if err != nil {
+		log.Error(err, "failed to create mvm-snapshot scope")
+
+		return ctrl.Result{}, fmt.Errorf("failed to create mvm-snapshot scope: %w", err)
+	}
+
+	defer func() {
+		if err := mvmSnapshotScope.Patch(); err != nil {
+			log.Error(err, "failed to patch microvmsnapshot")
+		}
+	}()
+
+	return r.reconcileNormal(ctx, mvmSnapshotScope)
+}
+
+func (r *MicrovmSnapshotReconciler) reconcileNormal(
+	ctx context.Context,
+	mvmSnapshotScope *scope.MicrovmSnapshotScope,
+) (reconcile.Result, error) {
+	mvmSnapshotScope.V(logging.DebugLevel).Info("Reconciling MicrovmSnapshot update")
+
+	if mvmSnapshotScope.Taken() {
+		mvmSnapshotScope.SetReady()
+
+		return ctrl.Result{}, nil
+	}
+
+	source := &infrav1.Microvm{}
+
+	key := types.NamespacedName{Namespace: mvmSnapshotScope.Namespace(), Name: mvmSnapshotScope.SourceName()}
+	if err := r.Get(ctx, key, source); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("getting source microvm: %w", err)
+		}
+
+		mvmSnapshotScope.SetNotReady(infrav1.MicrovmSnapshotSourceNotFoundReason,
+			clusterv1.ConditionSeverityWarning, "microvm %s not found", key.Name)
+
+		return ctrl.Result{}, nil
+	}
+
+	if !source.Status.Ready {
+		mvmSnapshotScope.SetNotReady(infrav1.MicrovmSnapshotSourceNotReadyReason, clusterv1.ConditionSeverityInfo, "")
+
+		return ctrl.Result{}, nil
+	}
+
+	mvmSnapshotScope.Info("taking snapshot", logging.MicrovmKey, source.Name)
+	mvmSnapshotScope.Take(source, time.Now())
+	mvmSnapshotScope.SetReady()
+
+	return ctrl.Result{}, nil
+}
+
+// microvmToSnapshots maps a Microvm to the MicrovmSnapshots of it which have
+// not been taken yet, so that they are taken as soon as it is ready.
+func (r *MicrovmSnapshotReconciler) microvmToSnapshots(obj client.Object) []reconcile.Request {
+	mvm, ok := obj.(*infrav1.Microvm)
+	if !ok || !mvm.Status.Ready {
+		return nil
+	}
+
+	snapshots := &infrav1.MicrovmSnapshotList{}
+	if err := r.List(context.Background(), snapshots, client.InNamespace(mvm.Namespace)); err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, snapshot := range snapshots.Items {
+		if snapshot.Spec.SourceRef.Name == mvm.Name && snapshot.Status.VMSpec == nil {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: snapshot.Namespace, Name: snapshot.Name},
+			})
+		}
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MicrovmSnapshotReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.MicrovmSnapshot{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvmsnapshot", logging.SnapshotKey)).
+		Watches(
+			&source.Kind{Type: &infrav1.Microvm{}},
+			handler.EnqueueRequestsFromMapFunc(r.microvmToSnapshots),
+		).
+		Complete(tracing.Reconciler("microvmsnapshot", r))
+}
@@ -0,0 +1,93 @@
+package controllers_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+func TestMicrovmSnapshot_ReconcileNormal(t *testing.T) {
+	tt := []struct {
+		name     string
+		snapshot func() *infrav1.MicrovmSnapshot
+		source   func() *infrav1.Microvm
+		expected func(*WithT, *infrav1.MicrovmSnapshot)
+	}{
+		{
+			name:     "a ready source is snapshotted",
+			snapshot: func() *infrav1.MicrovmSnapshot { return createMicrovmSnapshot(nil) },
+			source: func() *infrav1.Microvm {
+				mvm := createMicrovm()
+				mvm.UID = testMicrovmUID
+				mvm.Status.Ready = true
+
+				return mvm
+			},
+			expected: func(g *WithT, snapshot *infrav1.MicrovmSnapshot) {
+				g.Expect(snapshot.Status.Ready).To(BeTrue())
+				assertConditionTrue(g, snapshot, infrav1.MicrovmSnapshotReadyCondition)
+				g.Expect(string(snapshot.Status.SourceUID)).To(Equal(testMicrovmUID))
+				g.Expect(snapshot.Status.TakenAt).ToNot(BeNil())
+				g.Expect(snapshot.Status.VMSpec).To(Equal(&createMicrovm().Spec.VMSpec))
+			},
+		},
+		{
+			name:     "a source which is not ready is waited for",
+			snapshot: func() *infrav1.MicrovmSnapshot { return createMicrovmSnapshot(nil) },
+			source:   createMicrovm,
+			expected: func(g *WithT, snapshot *infrav1.MicrovmSnapshot) {
+				g.Expect(snapshot.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, snapshot, infrav1.MicrovmSnapshotReadyCondition, infrav1.MicrovmSnapshotSourceNotReadyReason)
+				g.Expect(snapshot.Status.VMSpec).To(BeNil())
+			},
+		},
+		{
+			name:     "a missing source is not ready",
+			snapshot: func() *infrav1.MicrovmSnapshot { return createMicrovmSnapshot(nil) },
+			source:   func() *infrav1.Microvm { return nil },
+			expected: func(g *WithT, snapshot *infrav1.MicrovmSnapshot) {
+				g.Expect(snapshot.Status.Ready).To(BeFalse())
+				assertConditionFalse(g, snapshot, infrav1.MicrovmSnapshotReadyCondition, infrav1.MicrovmSnapshotSourceNotFoundReason)
+			},
+		},
+		{
+			name: "a snapshot which has been taken is not changed",
+			snapshot: func() *infrav1.MicrovmSnapshot {
+				return createMicrovmSnapshot(&microvm.VMSpec{VCPU: 1, MemoryMb: 1024})
+			},
+			source: func() *infrav1.Microvm {
+				mvm := createMicrovm()
+				mvm.Status.Ready = true
+
+				return mvm
+			},
+			expected: func(g *WithT, snapshot *infrav1.MicrovmSnapshot) {
+				g.Expect(snapshot.Status.Ready).To(BeTrue())
+				g.Expect(snapshot.Status.VMSpec.VCPU).To(Equal(int64(1)), "Expected the snapshot not to follow its source")
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			objects := []runtime.Object{tc.snapshot()}
+			if source := tc.source(); source != nil {
+				objects = append(objects, source)
+			}
+
+			client := createFakeClient(g, objects)
+			_, err := reconcileMicrovmSnapshot(client)
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling a microvmsnapshot should not error")
+
+			reconciled, err := getMicrovmSnapshot(client, testMicrovmSnapshotName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvmsnapshot should not fail")
+			tc.expected(g, reconciled)
+		})
+	}
+}
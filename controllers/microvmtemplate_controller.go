@@ -0,0 +1,185 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/oci"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+// MicrovmTemplateReconciler reconciles a MicrovmTemplate object
+type MicrovmTemplateReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// FetcherFunc builds the client used to fetch templates from OCI registries.
+	FetcherFunc oci.FetcherFunc
+}
+
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmtemplates,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmtemplates/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmtemplates/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+func (r *MicrovmTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	mvmT := &infrav1.MicrovmTemplate{}
+	if err := r.Get(ctx, req.NamespacedName, mvmT); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "error getting microvmtemplate")
+
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
+	}
+
+	if !mvmT.ObjectMeta.DeletionTimestamp.IsZero() {
+		// nothing is owned by a template, so there is nothing to clean up
+		return ctrl.Result{}, nil
+	}
+
+	mvmTemplateScope, err := scope.NewMicrovmTemplateScope(scope.MicrovmTemplateScopeParams{
+		MicrovmTemplate: mvmT,
+		Client:          r.Client,
+		Context:         ctx,
+		Logger:          log,
+	})
+	if err != nil {
+		log.Error(err, "failed to create mvm-template scope")
+
+		return ctrl.Result{}, fmt.Errorf("failed to create mvm-template scope: %w", err)
+	}
+
+	defer func() {
+		if err := mvmTemplateScope.Patch(); err != nil {
+			log.Error(err, "failed to patch microvmtemplate")
+		}
+	}()
+
+	return r.reconcileNormal(ctx, mvmTemplateScope)
+}
+
+func (r *MicrovmTemplateReconciler) reconcileNormal(
+	ctx context.Context,
+	mvmTemplateScope *scope.MicrovmTemplateScope,
+) (reconcile.Result, error) {
+	source := mvmTemplateScope.OCISource()
+	if source == nil {
+		mvmTemplateScope.SetRevision()
+		mvmTemplateScope.SetReady()
+
+		return ctrl.Result{}, nil
+	}
+
+	// content is pinned by digest, so once resolved it never needs fetching again
+	if mvmTemplateScope.IsResolved() {
+		mvmTemplateScope.SetRevision()
+
+		return ctrl.Result{}, nil
+	}
+
+	if r.FetcherFunc == nil {
+		return ctrl.Result{}, errFetcherFuncRequired
+	}
+
+	ref, err := oci.ParseReference(source.Reference)
+	if err != nil {
+		mvmTemplateScope.SetNotReady(infrav1.MicrovmTemplateInvalidReason, clusterv1.ConditionSeverityError, err.Error())
+
+		return ctrl.Result{}, nil
+	}
+
+	creds, err := r.pullCredentials(ctx, mvmTemplateScope, source, ref)
+	if err != nil {
+		mvmTemplateScope.Error(err, "failed getting registry credentials")
+		mvmTemplateScope.SetNotReady(infrav1.MicrovmTemplateResolveFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+
+		return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+	}
+
+	mvmTemplateScope.Info("resolving microvmtemplate", "reference", ref.String())
+
+	content, err := r.FetcherFunc(source.Insecure).Fetch(ctx, ref, creds)
+	if err != nil {
+		mvmTemplateScope.Error(err, "failed fetching microvmtemplate")
+		mvmTemplateScope.SetNotReady(infrav1.MicrovmTemplateResolveFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+
+		return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+	}
+
+	template := infrav1.MicrovmTemplateSpec{}
+	if err := yaml.UnmarshalStrict(content, &template); err != nil {
+		mvmTemplateScope.SetNotReady(infrav1.MicrovmTemplateInvalidReason, clusterv1.ConditionSeverityError,
+			"decoding template: %s", err.Error())
+
+		return ctrl.Result{}, nil
+	}
+
+	mvmTemplateScope.SetResolved(template, source.Reference)
+	mvmTemplateScope.SetRevision()
+	mvmTemplateScope.SetReady()
+
+	return ctrl.Result{}, nil
+}
+
+// pullCredentials reads the registry credentials from the pull secret, if one
+// is set.
+func (r *MicrovmTemplateReconciler) pullCredentials(
+	ctx context.Context,
+	mvmTemplateScope *scope.MicrovmTemplateScope,
+	source *infrav1.OCITemplateSource,
+	ref oci.Reference,
+) (*oci.Credentials, error) {
+	if source.PullSecretRef == nil {
+		return nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Name: source.PullSecretRef.Name, Namespace: mvmTemplateScope.Namespace()}
+
+	if err := r.Get(ctx, key, secret); err != nil {
+		return nil, fmt.Errorf("getting pull secret %s: %w", key.Name, err)
+	}
+
+	return oci.CredentialsFromDockerConfig(secret.Data[corev1.DockerConfigJsonKey], ref.Registry)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MicrovmTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.MicrovmTemplate{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "microvmtemplate", logging.TemplateKey)).
+		Complete(tracing.Reconciler("microvmtemplate", r))
+}
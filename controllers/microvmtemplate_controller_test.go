@@ -0,0 +1,197 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+var testTemplateReference = "ghcr.io/org/templates/ubuntu:22.04@sha256:" + strings.Repeat("a", 64)
+
+const testTemplateContent = `
+metadata:
+  labels:
+    os: ubuntu
+spec:
+  vcpu: 4
+  memoryMb: 4096
+`
+
+func TestMicrovmTemplate_ReconcileNormal_InlineTemplateIsReady(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmT := createMicrovmTemplate("")
+	mvmT.Source = nil
+
+	client := createFakeClient(g, []runtime.Object{mvmT})
+	_, err := reconcileMicrovmTemplate(client, nil)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling an inline microvmtemplate should not error")
+
+	reconciled, err := getMicrovmTemplate(client, testMicrovmTemplateName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmtemplate should not fail")
+	g.Expect(reconciled.Status.Ready).To(BeTrue())
+	assertConditionTrue(g, reconciled, infrav1.MicrovmTemplateReadyCondition)
+}
+
+func TestMicrovmTemplate_ReconcileNormal_Revision(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmT := createMicrovmTemplate("")
+	mvmT.Source = nil
+	mvmT.Template.Spec.VCPU = 2
+
+	client := createFakeClient(g, []runtime.Object{mvmT})
+	_, err := reconcileMicrovmTemplate(client, nil)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmtemplate should not error")
+
+	_, err = reconcileMicrovmTemplate(client, nil)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmtemplate again should not error")
+
+	reconciled, err := getMicrovmTemplate(client, testMicrovmTemplateName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmtemplate should not fail")
+	g.Expect(reconciled.Status.Revision).To(Equal(int64(1)), "Expected the first content to be revision 1")
+
+	reconciled.Template.Spec.VCPU = 4
+	g.Expect(client.Update(context.TODO(), reconciled)).To(Succeed())
+
+	_, err = reconcileMicrovmTemplate(client, nil)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling changed microvmtemplate should not error")
+
+	reconciled, err = getMicrovmTemplate(client, testMicrovmTemplateName, testNamespace)
+	g.Expect(err).NotTo(HaveOccurred(), "Getting microvmtemplate should not fail")
+	g.Expect(reconciled.Status.Revision).To(Equal(int64(2)), "Expected a change to the content to be a new revision")
+}
+
+func TestMicrovmTemplate_ReconcileNormal_ResolvesFromOCI(t *testing.T) {
+	tt := []struct {
+		name     string
+		template func() *infrav1.MicrovmTemplate
+		fetcher  *fakeFetcher
+		expected func(*WithT, *infrav1.MicrovmTemplate, *fakeFetcher)
+	}{
+		{
+			name:     "template content is resolved",
+			template: func() *infrav1.MicrovmTemplate { return createMicrovmTemplate(testTemplateReference) },
+			fetcher:  &fakeFetcher{content: []byte(testTemplateContent)},
+			expected: func(g *WithT, mvmT *infrav1.MicrovmTemplate, f *fakeFetcher) {
+				g.Expect(f.refs).To(HaveLen(1))
+				g.Expect(f.refs[0].Registry).To(Equal("ghcr.io"))
+				g.Expect(f.plainHTTP).To(BeFalse())
+				g.Expect(mvmT.EffectiveTemplate().Spec.VCPU).To(Equal(int64(4)))
+				g.Expect(mvmT.EffectiveTemplate().Labels).To(HaveKeyWithValue("os", "ubuntu"))
+				g.Expect(mvmT.Status.ResolvedReference).To(Equal(testTemplateReference))
+				g.Expect(mvmT.IsResolved()).To(BeTrue())
+				assertConditionTrue(g, mvmT, infrav1.MicrovmTemplateReadyCondition)
+			},
+		},
+		{
+			name: "resolved template is not fetched again",
+			template: func() *infrav1.MicrovmTemplate {
+				mvmT := createMicrovmTemplate(testTemplateReference)
+				mvmT.Status.Ready = true
+				mvmT.Status.ResolvedReference = testTemplateReference
+				mvmT.Status.ResolvedTemplate = &infrav1.MicrovmTemplateSpec{}
+
+				return mvmT
+			},
+			fetcher: &fakeFetcher{},
+			expected: func(g *WithT, _ *infrav1.MicrovmTemplate, f *fakeFetcher) {
+				g.Expect(f.refs).To(BeEmpty())
+			},
+		},
+		{
+			name: "changed reference is fetched again",
+			template: func() *infrav1.MicrovmTemplate {
+				mvmT := createMicrovmTemplate(testTemplateReference)
+				mvmT.Status.Ready = true
+				mvmT.Status.ResolvedReference = "ghcr.io/org/templates/ubuntu@sha256:" + strings.Repeat("b", 64)
+				mvmT.Status.ResolvedTemplate = &infrav1.MicrovmTemplateSpec{}
+
+				return mvmT
+			},
+			fetcher: &fakeFetcher{content: []byte(testTemplateContent)},
+			expected: func(g *WithT, mvmT *infrav1.MicrovmTemplate, f *fakeFetcher) {
+				g.Expect(f.refs).To(HaveLen(1))
+				g.Expect(mvmT.Status.ResolvedReference).To(Equal(testTemplateReference))
+			},
+		},
+		{
+			name:     "fetch failure",
+			template: func() *infrav1.MicrovmTemplate { return createMicrovmTemplate(testTemplateReference) },
+			fetcher:  &fakeFetcher{err: errors.New("registry unavailable")},
+			expected: func(g *WithT, mvmT *infrav1.MicrovmTemplate, _ *fakeFetcher) {
+				g.Expect(mvmT.IsResolved()).To(BeFalse())
+				assertConditionFalse(g, mvmT, infrav1.MicrovmTemplateReadyCondition, infrav1.MicrovmTemplateResolveFailedReason)
+			},
+		},
+		{
+			name:     "invalid content",
+			template: func() *infrav1.MicrovmTemplate { return createMicrovmTemplate(testTemplateReference) },
+			fetcher:  &fakeFetcher{content: []byte("spec:\n  cores: 4\n")},
+			expected: func(g *WithT, mvmT *infrav1.MicrovmTemplate, _ *fakeFetcher) {
+				assertConditionFalse(g, mvmT, infrav1.MicrovmTemplateReadyCondition, infrav1.MicrovmTemplateInvalidReason)
+			},
+		},
+		{
+			name: "insecure registry",
+			template: func() *infrav1.MicrovmTemplate {
+				mvmT := createMicrovmTemplate(testTemplateReference)
+				mvmT.Source.OCI.Insecure = true
+
+				return mvmT
+			},
+			fetcher: &fakeFetcher{content: []byte(testTemplateContent)},
+			expected: func(g *WithT, _ *infrav1.MicrovmTemplate, f *fakeFetcher) {
+				g.Expect(f.plainHTTP).To(BeTrue())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			client := createFakeClient(g, []runtime.Object{tc.template()})
+			_, err := reconcileMicrovmTemplate(client, tc.fetcher.fetcherFunc())
+			g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmtemplate should not error")
+
+			reconciled, err := getMicrovmTemplate(client, testMicrovmTemplateName, testNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Getting microvmtemplate should not fail")
+
+			tc.expected(g, reconciled, tc.fetcher)
+		})
+	}
+}
+
+func TestMicrovmTemplate_ReconcileNormal_PullSecret(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmT := createMicrovmTemplate(testTemplateReference)
+	mvmT.Source.OCI.PullSecretRef = &corev1.LocalObjectReference{Name: "pull"}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pull", Namespace: testNamespace},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"ghcr.io":{"username":"bot","password":"token"}}}`),
+		},
+	}
+
+	fetcher := &fakeFetcher{content: []byte(testTemplateContent)}
+
+	client := createFakeClient(g, []runtime.Object{mvmT, secret})
+	_, err := reconcileMicrovmTemplate(client, fetcher.fetcherFunc())
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling microvmtemplate should not error")
+
+	g.Expect(fetcher.creds).To(HaveLen(1))
+	g.Expect(fetcher.creds[0]).NotTo(BeNil())
+	g.Expect(fetcher.creds[0].Username).To(Equal("bot"))
+}
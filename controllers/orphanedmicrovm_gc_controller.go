@@ -0,0 +1,223 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/callmeta"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+const defaultOrphanedMicrovmGCInterval = 10 * time.Minute
+
+// OrphanedMicrovmGCReconciler periodically lists the VMs on each MicrovmHost
+// and deletes those labelled as owned by a Microvm which no longer exists. It
+// catches VMs leaked when a Microvm finalizer is removed while its host is
+// down, or the VM is created after the Microvm is gone.
+type OrphanedMicrovmGCReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	MvmClientFunc flclient.FactoryFunc
+
+	// Config holds the settings which can be changed while the operator is
+	// running. The default TLS secret is used to connect to each host, and
+	// only the VMs in the watched namespaces are swept.
+	Config *config.Store
+	// Interval is how often each host is swept. It defaults to 10 minutes.
+	Interval time.Duration
+}
+
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmhosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+func (r *OrphanedMicrovmGCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	mvmH := &infrav1.MicrovmHost{}
+	if err := r.Get(ctx, req.NamespacedName, mvmH); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "error getting microvmhost")
+
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile: %w", err)
+	}
+
+	if !mvmH.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	ctx, reconcileUID := callmeta.ForReconcile(ctx, "orphanedmicrovmgc", mvmH)
+
+	log = log.WithValues(logging.HostKey, mvmH.Spec.Endpoint, logging.ReconcileUIDKey, reconcileUID)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	// An unreachable host is swept again once it answers, and an untrusted one
+	// must not be connected to at all.
+	if mvmH.Status.UnreachableSince != nil || mvmH.Status.Untrusted {
+		return ctrl.Result{RequeueAfter: r.interval()}, nil
+	}
+
+	if err := r.sweep(ctx, mvmH.Spec.Endpoint); err != nil {
+		log.Error(err, "failed sweeping host for orphaned microvms")
+
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: r.interval()}, nil
+}
+
+// sweep deletes the VMs on the host at endpoint whose Microvm is gone.
+func (r *OrphanedMicrovmGCReconciler) sweep(ctx context.Context, endpoint string) error {
+	log := log.FromContext(ctx)
+
+	mvmClient, err := r.hostClient(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	defer mvmClient.Close()
+
+	resp, err := mvmClient.ListMicroVMs(ctx, &flintlockv1.ListMicroVMsRequest{})
+	if err != nil {
+		return fmt.Errorf("listing microvms: %w", err)
+	}
+
+	for _, vm := range resp.Microvm {
+		uid, ok := vm.Spec.Labels[infrav1.MicrovmUIDLabel]
+		if !ok || vm.Spec.Uid == nil {
+			continue
+		}
+
+		// the VM may have been created in another namespace on the host than
+		// that of its Microvm
+		namespace := vm.Spec.Namespace
+		if ns, ok := vm.Spec.Labels[infrav1.MicrovmNamespaceLabel]; ok {
+			namespace = ns
+		}
+
+		// a host can be shared by operators restricted to other namespaces,
+		// whose microvms this one cannot see and must leave alone
+		if !r.Config.WatchesNamespace(namespace) {
+			continue
+		}
+
+		owned, err := r.microvmExists(ctx, namespace, uid)
+		if err != nil {
+			return err
+		}
+
+		if owned {
+			continue
+		}
+
+		log.Info("deleting orphaned microvm", logging.MicrovmKey, vm.Spec.Id,
+			"microvmNamespace", vm.Spec.Namespace, logging.UIDKey, *vm.Spec.Uid)
+
+		if _, err := mvmClient.DeleteMicroVM(ctx, &flintlockv1.DeleteMicroVMRequest{Uid: *vm.Spec.Uid}); err != nil {
+			return fmt.Errorf("deleting microvm %s: %w", *vm.Spec.Uid, err)
+		}
+	}
+
+	return nil
+}
+
+// hostClient connects to the host at endpoint with the default TLS secret, or
+// without credentials if there is none.
+func (r *OrphanedMicrovmGCReconciler) hostClient(ctx context.Context, endpoint string) (flclient.Client, error) {
+	if r.MvmClientFunc == nil {
+		return nil, errClientFactoryFuncRequired
+	}
+
+	var tls *flclient.TLSConfig
+
+	if ref := r.Config.DefaultTLSSecretRef(); ref != nil && ref.Name != "" {
+		// MicrovmHosts are cluster scoped, so there is no namespace to fall
+		// back to as there is for Microvms.
+		if ref.Namespace == "" {
+			return nil, errTLSSecretNamespace
+		}
+
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("getting tls secret: %w", err)
+		}
+
+		var err error
+		if tls, err = scope.TLSConfigFromSecret(secret); err != nil {
+			return nil, fmt.Errorf("getting tls config: %w", err)
+		}
+	}
+
+	mvmClient, err := r.MvmClientFunc(endpoint, flclient.WithTLS(tls))
+	if err != nil {
+		return nil, fmt.Errorf("creating microvm client: %w", err)
+	}
+
+	return mvmClient, nil
+}
+
+// microvmExists returns true if a Microvm with the UID exists in the namespace.
+func (r *OrphanedMicrovmGCReconciler) microvmExists(ctx context.Context, namespace, uid string) (bool, error) {
+	mvms := &infrav1.MicrovmList{}
+	if err := r.List(ctx, mvms, client.InNamespace(namespace)); err != nil {
+		return false, fmt.Errorf("listing microvms: %w", err)
+	}
+
+	for _, mvm := range mvms.Items {
+		if string(mvm.UID) == uid {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *OrphanedMicrovmGCReconciler) interval() time.Duration {
+	if r.Interval > 0 {
+		return r.Interval
+	}
+
+	return defaultOrphanedMicrovmGCInterval
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OrphanedMicrovmGCReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("orphanedmicrovmgc").
+		For(&infrav1.MicrovmHost{}).
+		WithLogConstructor(logging.Constructor(mgr.GetLogger(), "orphanedmicrovmgc", logging.MicrovmHostKey)).
+		Complete(tracing.Reconciler("orphanedmicrovmgc", r))
+}
@@ -0,0 +1,103 @@
+package controllers_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+)
+
+func TestOrphanedMicrovmGC_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := createMicrovm()
+	mvm.UID = "mvm-uid"
+
+	objects := []runtime.Object{createMicrovmHost(), mvm}
+	c := createFakeClient(g, objects)
+
+	// a VM created in another namespace on the host is still owned
+	renamed := flintlockVM("renamed", "vm-renamed", map[string]string{
+		infrav1.MicrovmUIDLabel:       "mvm-uid",
+		infrav1.MicrovmNamespaceLabel: testNamespace,
+	})
+	renamed.Spec.Namespace = "cluster-a"
+
+	fakeAPIClient := &fakes.FakeClient{}
+	fakeAPIClient.ListMicroVMsReturns(&flintlockv1.ListMicroVMsResponse{
+		Microvm: []*flintlocktypes.MicroVM{
+			flintlockVM("owned", "vm-owned", map[string]string{infrav1.MicrovmUIDLabel: "mvm-uid"}),
+			renamed,
+			flintlockVM("orphaned", "vm-orphaned", map[string]string{infrav1.MicrovmUIDLabel: "deleted-uid"}),
+			flintlockVM("unmanaged", "vm-unmanaged", nil),
+		},
+	}, nil)
+
+	result, err := reconcileOrphanedMicrovmGC(c, fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling a microvmhost should not error")
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected the host to be swept again")
+
+	g.Expect(fakeAPIClient.DeleteMicroVMCallCount()).To(Equal(1), "Expected only the orphaned vm to be deleted")
+	_, deleteReq, _ := fakeAPIClient.DeleteMicroVMArgsForCall(0)
+	g.Expect(deleteReq.Uid).To(Equal("vm-orphaned"))
+}
+
+func TestOrphanedMicrovmGC_ReconcileUntrustedHost(t *testing.T) {
+	g := NewWithT(t)
+
+	mvmH := createMicrovmHost()
+	mvmH.Status.Untrusted = true
+
+	c := createFakeClient(g, []runtime.Object{mvmH})
+
+	fakeAPIClient := &fakes.FakeClient{}
+
+	_, err := reconcileOrphanedMicrovmGC(c, fakeAPIClient)
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling an untrusted microvmhost should not error")
+	g.Expect(fakeAPIClient.ListMicroVMsCallCount()).To(Equal(0), "Expected an untrusted host not to be contacted")
+}
+
+func TestOrphanedMicrovmGC_ReconcileUnwatchedNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	c := createFakeClient(g, []runtime.Object{createMicrovmHost()})
+
+	unwatched := flintlockVM("other-tenant", "vm-other-tenant", map[string]string{infrav1.MicrovmUIDLabel: "other-uid"})
+	unwatched.Spec.Namespace = "other-tenant"
+
+	fakeAPIClient := &fakes.FakeClient{}
+	fakeAPIClient.ListMicroVMsReturns(&flintlockv1.ListMicroVMsResponse{
+		Microvm: []*flintlocktypes.MicroVM{
+			flintlockVM("orphaned", "vm-orphaned", map[string]string{infrav1.MicrovmUIDLabel: "deleted-uid"}),
+			unwatched,
+		},
+	}, nil)
+
+	cfg := &configv1.OperatorConfiguration{WatchNamespaces: []string{testNamespace}}
+
+	_, err := reconcileOrphanedMicrovmGCWithConfig(c, fakeAPIClient, config.NewStore(cfg))
+	g.Expect(err).NotTo(HaveOccurred(), "Reconciling a microvmhost should not error")
+
+	g.Expect(fakeAPIClient.DeleteMicroVMCallCount()).To(Equal(1), "Expected the vm of another tenant to be left alone")
+	_, deleteReq, _ := fakeAPIClient.DeleteMicroVMArgsForCall(0)
+	g.Expect(deleteReq.Uid).To(Equal("vm-orphaned"))
+}
+
+func flintlockVM(name, uid string, labels map[string]string) *flintlocktypes.MicroVM {
+	return &flintlocktypes.MicroVM{
+		Spec: &flintlocktypes.MicroVMSpec{
+			Id:        name,
+			Namespace: testNamespace,
+			Uid:       pointer.String(uid),
+			Labels:    labels,
+		},
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 Weaveworks.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+)
+
+// isControlledBy returns true if owner, of the given kind, is the controller
+// of obj. Unlike metav1.IsControlledBy it never matches an owner without a
+// UID, as an owner which has not been stored cannot control anything, and it
+// checks the name and kind the reference records as well as its UID. Owner
+// references cannot cross namespaces, so an object in another namespace is
+// never controlled by owner.
+func isControlledBy(obj, owner metav1.Object, kind string) bool {
+	if owner.GetUID() == "" || obj.GetNamespace() != owner.GetNamespace() {
+		return false
+	}
+
+	ref := metav1.GetControllerOf(obj)
+	if ref == nil {
+		return false
+	}
+
+	return ref.UID == owner.GetUID() && ref.Name == owner.GetName() && ref.Kind == kind
+}
+
+// isClaimedElsewhere returns true if obj, which has no controller, still
+// belongs to another owner of the given kind, or was made for another
+// MicrovmDeployment than deployment, so that it is not adopted out from
+// under them. An empty deployment is only matched by objects which were not
+// made for one.
+func isClaimedElsewhere(obj, owner metav1.Object, kind, deployment string) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == kind && ref.UID != owner.GetUID() {
+			return true
+		}
+	}
+
+	made, ok := obj.GetLabels()[infrav1.MicrovmDeploymentNameLabel]
+
+	return ok && made != deployment
+}
+
+// release removes the references to owner from obj, along with the given
+// labels, so that obj is left as an orphan which can be adopted. The patch is
+// guarded by the resource version of obj, and an obj which has already gone
+// counts as released.
+func release(ctx context.Context, c client.Client, obj client.Object, owner metav1.Object, labels ...string) error {
+	patch := client.MergeFromWithOptions(obj.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+
+	refs := []metav1.OwnerReference{}
+
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != owner.GetUID() {
+			refs = append(refs, ref)
+		}
+	}
+
+	obj.SetOwnerReferences(refs)
+
+	objLabels := obj.GetLabels()
+	for _, label := range labels {
+		delete(objLabels, label)
+	}
+
+	obj.SetLabels(objLabels)
+
+	if err := c.Patch(ctx, obj, patch, apply.FieldOwner); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
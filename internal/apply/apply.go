@@ -0,0 +1,141 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package apply persists the objects the operator writes under its own field
+// manager, with their status server-side applied so that it never conflicts
+// with other writers of the same object.
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+)
+
+// FieldOwner is the field manager of every field written by the operator.
+const FieldOwner = client.FieldOwner(defaults.ManagerName)
+
+const statusField = "status"
+
+// Helper persists the changes made to an object since it was read.
+type Helper struct {
+	client client.Client
+	gvk    schema.GroupVersionKind
+	before map[string]interface{}
+}
+
+// NewHelper returns a Helper which persists the changes made to obj from now on.
+func NewHelper(obj client.Object, c client.Client) (*Helper, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return nil, fmt.Errorf("getting kind of object: %w", err)
+	}
+
+	before, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("converting object: %w", err)
+	}
+
+	return &Helper{
+		client: c,
+		gvk:    gvk,
+		before: before,
+	}, nil
+}
+
+// Patch persists the changes made to obj since the last Patch, or since the
+// Helper was created. The status is server-side applied, forcing ownership
+// of every field in it, and the metadata and spec are merge patched.
+func (h *Helper) Patch(ctx context.Context, obj client.Object) error {
+	after, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return fmt.Errorf("converting object: %w", err)
+	}
+
+	// the status goes first, as the merge patch refreshes obj from the server
+	if !equality.Semantic.DeepEqual(h.before[statusField], after[statusField]) {
+		if err := h.applyStatus(ctx, obj, after[statusField]); err != nil {
+			return err
+		}
+	}
+
+	original, modified := withoutStatus(h.before), withoutStatus(after)
+	if !equality.Semantic.DeepEqual(original, modified) {
+		if err := h.mergePatch(ctx, obj, original, modified); err != nil {
+			return err
+		}
+	}
+
+	h.before, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return fmt.Errorf("converting object: %w", err)
+	}
+
+	return nil
+}
+
+// applyStatus server-side applies status as the status of obj.
+func (h *Helper) applyStatus(ctx context.Context, obj client.Object, status interface{}) error {
+	if status == nil {
+		status = map[string]interface{}{}
+	}
+
+	applied := &unstructured.Unstructured{Object: map[string]interface{}{statusField: status}}
+	applied.SetGroupVersionKind(h.gvk)
+	applied.SetNamespace(obj.GetNamespace())
+	applied.SetName(obj.GetName())
+
+	if err := h.client.Status().Patch(ctx, applied, client.Apply, FieldOwner, client.ForceOwnership); err != nil {
+		return fmt.Errorf("applying status: %w", err)
+	}
+
+	return nil
+}
+
+// mergePatch patches obj with the difference between original and modified.
+func (h *Helper) mergePatch(ctx context.Context, obj client.Object, original, modified map[string]interface{}) error {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return fmt.Errorf("marshalling object: %w", err)
+	}
+
+	modifiedJSON, err := json.Marshal(modified)
+	if err != nil {
+		return fmt.Errorf("marshalling object: %w", err)
+	}
+
+	data, err := jsonpatch.CreateMergePatch(originalJSON, modifiedJSON)
+	if err != nil {
+		return fmt.Errorf("creating merge patch: %w", err)
+	}
+
+	if err := h.client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, data), FieldOwner); err != nil {
+		return fmt.Errorf("patching object: %w", err)
+	}
+
+	return nil
+}
+
+// withoutStatus returns a shallow copy of obj without its status.
+func withoutStatus(obj map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(obj))
+
+	for k, v := range obj {
+		if k != statusField {
+			out[k] = v
+		}
+	}
+
+	return out
+}
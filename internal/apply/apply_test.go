@@ -0,0 +1,59 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package apply_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply/applytest"
+)
+
+func TestHelper_Patch(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	mvmRS := &infrav1.MicrovmReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rs1", Namespace: "ns1"},
+		Status: infrav1.MicrovmReplicaSetStatus{
+			MicrovmSummaries: []infrav1.MicrovmSummary{{Name: "mvm1"}},
+		},
+	}
+
+	c := applytest.NewClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmRS).Build())
+
+	helper, err := apply.NewHelper(mvmRS, c)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmRS.Finalizers = []string{infrav1.MvmRSFinalizer}
+	mvmRS.Status.Ready = true
+	mvmRS.Status.ReadyReplicas = 1
+	mvmRS.Status.MicrovmSummaries = nil
+	g.Expect(helper.Patch(context.TODO(), mvmRS)).To(Succeed())
+
+	stored := &infrav1.MicrovmReplicaSet{}
+	g.Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(mvmRS), stored)).To(Succeed())
+	g.Expect(stored.Finalizers).To(ConsistOf(infrav1.MvmRSFinalizer))
+	g.Expect(stored.Status.Ready).To(BeTrue())
+	g.Expect(stored.Status.ReadyReplicas).To(Equal(int32(1)))
+	g.Expect(stored.Status.MicrovmSummaries).To(BeEmpty(), "Expected a field removed from the status to be removed")
+
+	// a later patch only persists what has changed since
+	mvmRS.Status.ReadyReplicas = 0
+	g.Expect(helper.Patch(context.TODO(), mvmRS)).To(Succeed())
+
+	g.Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(mvmRS), stored)).To(Succeed())
+	g.Expect(stored.Finalizers).To(ConsistOf(infrav1.MvmRSFinalizer))
+	g.Expect(stored.Status.ReadyReplicas).To(BeZero())
+}
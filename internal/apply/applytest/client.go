@@ -0,0 +1,84 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package applytest lets the fake client stand in for the API server in tests
+// of code which server-side applies status, or which relies on the objects it
+// creates being given a UID, neither of which the fake client does.
+package applytest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// NewClient wraps c so that a server-side apply of a status replaces the
+// stored status with the one applied, and objects are given a UID when they
+// are created.
+func NewClient(c client.Client) client.Client {
+	return &applyClient{Client: c}
+}
+
+type applyClient struct {
+	client.Client
+}
+
+// Create gives obj a UID, as the API server does, unless it already has one.
+func (c *applyClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if obj.GetUID() == "" {
+		obj.SetUID(uuid.NewUUID())
+	}
+
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// Status returns a writer which handles server-side applies itself.
+func (c *applyClient) Status() client.StatusWriter {
+	return &statusWriter{StatusWriter: c.Client.Status(), client: c.Client}
+}
+
+type statusWriter struct {
+	client.StatusWriter
+
+	client client.Client
+}
+
+// Patch replaces the status of the stored object for a server-side apply, and
+// passes every other patch through.
+func (w *statusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+	}
+
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+
+	applied := map[string]interface{}{}
+	if err := json.Unmarshal(data, &applied); err != nil {
+		return fmt.Errorf("unmarshalling applied object: %w", err)
+	}
+
+	gvk, err := apiutil.GVKForObject(obj, w.client.Scheme())
+	if err != nil {
+		return err
+	}
+
+	stored := &unstructured.Unstructured{}
+	stored.SetGroupVersionKind(gvk)
+
+	if err := w.client.Get(ctx, client.ObjectKeyFromObject(obj), stored); err != nil {
+		return err
+	}
+
+	stored.Object["status"] = applied["status"]
+
+	return w.client.Update(ctx, stored)
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package audit records every Create and Delete sent to a flintlock host as a
+// MicrovmAction, so that the changes made to the VMs of each host, and who
+// made them, can be reconstructed afterwards.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/callmeta"
+)
+
+// recordTimeout bounds the creation of a MicrovmAction. It does not use the
+// context of the call, which may already have expired when the call failed.
+const recordTimeout = 10 * time.Second
+
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmactions,verbs=create
+
+// FactoryFunc wraps factory so that the Create and Delete calls made by the
+// clients it returns are recorded with k8sClient.
+func FactoryFunc(factory flclient.FactoryFunc, k8sClient client.Client) flclient.FactoryFunc {
+	return func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		flClient, err := factory(address, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &auditedClient{Client: flClient, address: address, k8sClient: k8sClient}, nil
+	}
+}
+
+// auditedClient is a flintlock client which records the calls which change
+// the VMs of its host.
+type auditedClient struct {
+	flclient.Client
+
+	address   string
+	k8sClient client.Client
+}
+
+func (c *auditedClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	requested := time.Now()
+
+	resp, err := c.Client.CreateMicroVM(ctx, in, opts...)
+
+	c.record(ctx, infrav1.MicrovmActionCreate, in, resp.GetMicrovm().GetSpec().GetUid(), requested, err)
+
+	return resp, err
+}
+
+func (c *auditedClient) DeleteMicroVM(
+	ctx context.Context,
+	in *flintlockv1.DeleteMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*emptypb.Empty, error) {
+	requested := time.Now()
+
+	resp, err := c.Client.DeleteMicroVM(ctx, in, opts...)
+
+	c.record(ctx, infrav1.MicrovmActionDelete, in, in.GetUid(), requested, err)
+
+	return resp, err
+}
+
+// record creates the MicrovmAction for a call. The call has already been made,
+// so a failure to record it is logged rather than returned.
+func (c *auditedClient) record(
+	ctx context.Context,
+	operation infrav1.MicrovmActionOperation,
+	request proto.Message,
+	vmUID string,
+	requested time.Time,
+	callErr error,
+) {
+	action := newAction(ctx, c.address, operation, request, requested, time.Now(), callErr)
+	action.Spec.VMUID = vmUID
+
+	recordCtx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	if err := c.k8sClient.Create(recordCtx, action); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed recording microvm action",
+			"operation", operation, "host", c.address, "requestDigest", action.Spec.RequestDigest)
+	}
+}
+
+// newAction returns the MicrovmAction recording a call of operation to the
+// host at address, made by the reconcile of ctx.
+func newAction(
+	ctx context.Context,
+	address string,
+	operation infrav1.MicrovmActionOperation,
+	request proto.Message,
+	requested, completed time.Time,
+	callErr error,
+) *infrav1.MicrovmAction {
+	headers := callmeta.Headers(ctx)
+
+	action := &infrav1.MicrovmAction{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: strings.ToLower(string(operation)) + "-",
+		},
+		Spec: infrav1.MicrovmActionSpec{
+			Host:            address,
+			Operation:       operation,
+			RequestDigest:   digest(request),
+			Controller:      headers[callmeta.ControllerHeader],
+			ReconcileUID:    types.UID(headers[callmeta.ReconcileUIDHeader]),
+			OperatorVersion: headers[callmeta.OperatorVersionHeader],
+			RequestedAt:     metav1.NewTime(requested),
+			CompletedAt:     metav1.NewTime(completed),
+			Outcome:         infrav1.MicrovmActionSucceeded,
+		},
+	}
+
+	if name := headers[callmeta.NameHeader]; name != "" {
+		action.Spec.Object = &infrav1.MicrovmActionObject{
+			Namespace: headers[callmeta.NamespaceHeader],
+			Name:      name,
+			UID:       types.UID(headers[callmeta.UIDHeader]),
+		}
+	}
+
+	if callErr != nil {
+		action.Spec.Outcome = infrav1.MicrovmActionFailed
+		action.Spec.Error = callErr.Error()
+	}
+
+	return action
+}
+
+// digest returns the sha256 digest of the deterministic encoding of request.
+func digest(request proto.Message) string {
+	encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(request)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(encoded))
+}
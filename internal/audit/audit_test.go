@@ -0,0 +1,124 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package audit_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/audit"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/callmeta"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/testing/fakeflintlock"
+)
+
+func newClients(g *WithT) (*fakeflintlock.Server, flclient.Client, client.Client) {
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	server, err := fakeflintlock.Start()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	flClient, err := audit.FactoryFunc(flclient.NewFlintlockClient, k8sClient)(server.Address())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return server, flClient, k8sClient
+}
+
+func listActions(g *WithT, k8sClient client.Client) []infrav1.MicrovmAction {
+	actions := &infrav1.MicrovmActionList{}
+	g.Expect(k8sClient.List(context.TODO(), actions)).To(Succeed())
+
+	return actions.Items
+}
+
+func createRequest() *flintlockv1.CreateMicroVMRequest {
+	return &flintlockv1.CreateMicroVMRequest{
+		Microvm: &flintlocktypes.MicroVMSpec{Id: "mvm1", Namespace: "ns1", Vcpu: 2, MemoryInMb: 2048},
+	}
+}
+
+func TestFactoryFunc_RecordsMutations(t *testing.T) {
+	g := NewWithT(t)
+
+	server, flClient, k8sClient := newClients(g)
+	defer server.Stop()
+	defer flClient.Close()
+
+	mvm := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1", UID: "abc"}}
+	ctx, reconcileUID := callmeta.ForReconcile(context.TODO(), "microvm", mvm)
+
+	created, err := flClient.CreateMicroVM(ctx, createRequest())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	uid := created.GetMicrovm().GetSpec().GetUid()
+
+	_, err = flClient.GetMicroVM(ctx, &flintlockv1.GetMicroVMRequest{Uid: uid})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = flClient.DeleteMicroVM(ctx, &flintlockv1.DeleteMicroVMRequest{Uid: uid})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	actions := listActions(g, k8sClient)
+	g.Expect(actions).To(HaveLen(2), "Expected only the create and delete to be recorded")
+
+	operations := []infrav1.MicrovmActionOperation{}
+
+	for _, action := range actions {
+		operations = append(operations, action.Spec.Operation)
+
+		g.Expect(action.Spec.Host).To(Equal(server.Address()))
+		g.Expect(action.Spec.VMUID).To(Equal(uid))
+		g.Expect(action.Spec.Outcome).To(Equal(infrav1.MicrovmActionSucceeded))
+		g.Expect(action.Spec.Controller).To(Equal("microvm"))
+		g.Expect(action.Spec.ReconcileUID).To(Equal(reconcileUID))
+		g.Expect(action.Spec.Object).To(Equal(&infrav1.MicrovmActionObject{Namespace: "ns1", Name: "mvm1", UID: "abc"}))
+		g.Expect(action.Spec.RequestDigest).To(HavePrefix("sha256:"))
+		g.Expect(action.Spec.CompletedAt.Before(&action.Spec.RequestedAt)).To(BeFalse())
+	}
+
+	g.Expect(operations).To(ConsistOf(infrav1.MicrovmActionCreate, infrav1.MicrovmActionDelete))
+}
+
+func TestFactoryFunc_RecordsFailures(t *testing.T) {
+	g := NewWithT(t)
+
+	server, flClient, k8sClient := newClients(g)
+	defer server.Stop()
+	defer flClient.Close()
+
+	server.SetError(fakeflintlock.CreateMicroVM, status.Error(codes.ResourceExhausted, "host is full"))
+
+	_, err := flClient.CreateMicroVM(context.TODO(), createRequest())
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = flClient.CreateMicroVM(context.TODO(), createRequest())
+	g.Expect(err).To(HaveOccurred())
+
+	actions := listActions(g, k8sClient)
+	g.Expect(actions).To(HaveLen(2), "Expected every attempt to be recorded")
+
+	for _, action := range actions {
+		g.Expect(action.Spec.Outcome).To(Equal(infrav1.MicrovmActionFailed))
+		g.Expect(action.Spec.Error).To(ContainSubstring("host is full"))
+		g.Expect(action.Spec.VMUID).To(BeEmpty())
+		g.Expect(action.Spec.Object).To(BeNil(), "Expected no object outside a reconcile")
+	}
+
+	g.Expect(actions[0].Spec.RequestDigest).To(Equal(actions[1].Spec.RequestDigest),
+		"Expected the same request to have the same digest")
+}
@@ -0,0 +1,11 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package autoscaler
+
+import "errors"
+
+var (
+	errQueryFailed      = errors.New("prometheus query failed")
+	errUnexpectedResult = errors.New("unexpected prometheus result")
+)
@@ -0,0 +1,133 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package autoscaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultQueryTimeout = 10 * time.Second
+
+// Source returns the current value of the metric used for scaling.
+type Source interface {
+	Value(ctx context.Context) (float64, error)
+}
+
+// SourceFunc builds a Source for a Prometheus server address and query.
+type SourceFunc func(address, query string) Source
+
+// PrometheusSource reads a metric with an instant query against the Prometheus HTTP API.
+type PrometheusSource struct {
+	Address string
+	Query   string
+	Client  *http.Client
+}
+
+// NewPrometheusSource returns a Source which runs query against the server at address.
+func NewPrometheusSource(address, query string) Source {
+	return &PrometheusSource{
+		Address: address,
+		Query:   query,
+		Client:  &http.Client{Timeout: defaultQueryTimeout},
+	}
+}
+
+type promResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+type promSample struct {
+	Value []interface{} `json:"value"`
+}
+
+// Value runs the query and returns its result. The query must return a scalar or
+// a vector containing exactly one sample.
+func (p *PrometheusSource) Value(ctx context.Context) (float64, error) {
+	endpoint := strings.TrimSuffix(p.Address, "/") + "/api/v1/query?" + url.Values{"query": {p.Query}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("building prometheus request: %w", err)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("querying prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body promResponse
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		// prometheus explains a failed query in the body, but a proxy in front
+		// of it may not
+		if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.Error != "" {
+			return 0, fmt.Errorf("%w: %s: %s", errQueryFailed, resp.Status, body.Error)
+		}
+
+		return 0, fmt.Errorf("%w: %s", errQueryFailed, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("decoding prometheus response: %w", err)
+	}
+
+	if body.Status != "success" {
+		return 0, fmt.Errorf("%w: %s", errQueryFailed, body.Error)
+	}
+
+	var pair []interface{}
+
+	switch body.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(body.Data.Result, &pair); err != nil {
+			return 0, fmt.Errorf("decoding prometheus scalar: %w", err)
+		}
+	case "vector":
+		var samples []promSample
+		if err := json.Unmarshal(body.Data.Result, &samples); err != nil {
+			return 0, fmt.Errorf("decoding prometheus vector: %w", err)
+		}
+
+		if len(samples) != 1 {
+			return 0, fmt.Errorf("%w: got %d samples", errUnexpectedResult, len(samples))
+		}
+
+		pair = samples[0].Value
+	default:
+		return 0, fmt.Errorf("%w: result type %q", errUnexpectedResult, body.Data.ResultType)
+	}
+
+	return parseSampleValue(pair)
+}
+
+func parseSampleValue(pair []interface{}) (float64, error) {
+	if len(pair) != 2 { //nolint: gomnd // [timestamp, value]
+		return 0, fmt.Errorf("%w: malformed sample", errUnexpectedResult)
+	}
+
+	raw, ok := pair[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("%w: sample value is not a string", errUnexpectedResult)
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing sample value: %w", err)
+	}
+
+	return value, nil
+}
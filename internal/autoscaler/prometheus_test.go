@@ -0,0 +1,90 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package autoscaler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/autoscaler"
+)
+
+func TestPrometheusSource(t *testing.T) {
+	tt := []struct {
+		name     string
+		status   int
+		body     string
+		expected func(*WithT, float64, error)
+	}{
+		{
+			name: "vector with a single sample",
+			body: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1666000000,"1.5"]}]}}`,
+			expected: func(g *WithT, value float64, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(value).To(Equal(1.5))
+			},
+		},
+		{
+			name: "scalar",
+			body: `{"status":"success","data":{"resultType":"scalar","result":[1666000000,"3"]}}`,
+			expected: func(g *WithT, value float64, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(value).To(Equal(float64(3)))
+			},
+		},
+		{
+			name: "vector with several samples",
+			body: `{"status":"success","data":{"resultType":"vector","result":[{"value":[1,"1"]},{"value":[1,"2"]}]}}`,
+			expected: func(g *WithT, _ float64, err error) {
+				g.Expect(err).To(HaveOccurred())
+			},
+		},
+		{
+			name: "failed query",
+			body: `{"status":"error","error":"bad query"}`,
+			expected: func(g *WithT, _ float64, err error) {
+				g.Expect(err).To(MatchError(ContainSubstring("bad query")))
+			},
+		},
+		{
+			name:   "rejected query",
+			status: http.StatusBadRequest,
+			body:   `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			expected: func(g *WithT, _ float64, err error) {
+				g.Expect(err).To(MatchError(ContainSubstring("parse error")))
+			},
+		},
+		{
+			name:   "error status from a proxy",
+			status: http.StatusBadGateway,
+			body:   `{"status":"success","data":{"resultType":"scalar","result":[1666000000,"3"]}}`,
+			expected: func(g *WithT, _ float64, err error) {
+				g.Expect(err).To(MatchError(ContainSubstring("502")))
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				g.Expect(r.URL.Path).To(Equal("/api/v1/query"))
+				g.Expect(r.URL.Query().Get("query")).To(Equal("up"))
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			value, err := autoscaler.NewPrometheusSource(server.URL, "up").Value(context.TODO())
+			tc.expected(g, value, err)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package autoscaler
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Recommender keeps the recent recommendations for each autoscaler in memory so
+// that stabilization windows can be applied across reconciles.
+type Recommender struct {
+	mu      sync.Mutex
+	history map[types.NamespacedName][]Recommendation
+}
+
+// NewRecommender returns an empty Recommender.
+func NewRecommender() *Recommender {
+	return &Recommender{history: map[types.NamespacedName][]Recommendation{}}
+}
+
+// Record adds rec to the history for key, dropping anything older than keep, and
+// returns the retained history.
+func (r *Recommender) Record(key types.NamespacedName, rec Recommendation, keep time.Duration) []Recommendation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	since := rec.Time.Add(-keep)
+	retained := []Recommendation{}
+
+	for _, old := range r.history[key] {
+		if !old.Time.Before(since) {
+			retained = append(retained, old)
+		}
+	}
+
+	retained = append(retained, rec)
+	r.history[key] = retained
+
+	return append([]Recommendation{}, retained...)
+}
+
+// Forget removes the history for key.
+func (r *Recommender) Forget(key types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.history, key)
+}
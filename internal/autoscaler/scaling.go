@@ -0,0 +1,304 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package autoscaler
+
+import (
+	"math"
+	"time"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+const (
+	// Tolerance is the relative difference between a metric and its target
+	// below which no scaling happens.
+	Tolerance = 0.1
+
+	defaultScaleDownWindowSeconds = 300
+	defaultPolicyPeriodSeconds    = 15
+	defaultPolicyPercent          = 100
+	defaultScaleUpPodsValue       = 4
+)
+
+// Recommendation is a desired replica count calculated at a point in time.
+type Recommendation struct {
+	Time     time.Time
+	Replicas int32
+}
+
+// ProportionalReplicas returns the replicas needed to bring value to target,
+// calculated as ceil(current * value / target). No change is recommended when the
+// ratio is within Tolerance of 1.
+func ProportionalReplicas(current int32, value, target float64) int32 {
+	if target <= 0 {
+		return current
+	}
+
+	ratio := value / target
+	if math.Abs(ratio-1) <= Tolerance {
+		return current
+	}
+
+	base := current
+	if base == 0 {
+		base = 1
+	}
+
+	return int32(math.Ceil(float64(base) * ratio))
+}
+
+// DensityReplicas returns the replicas needed to move the average number of
+// microvms per host from density towards targetPerHost. hosts is the number of
+// hosts each additional replica is created on, or 1 when replicas are spread
+// across hosts rather than created on each of them.
+func DensityReplicas(current int32, density float64, targetPerHost, hosts int32) int32 {
+	if hosts < 1 {
+		hosts = 1
+	}
+
+	desired := current + int32(math.Floor((float64(targetPerHost)-density)*float64(hosts)))
+	if desired < 0 {
+		return 0
+	}
+
+	return desired
+}
+
+// Normalize applies the stabilization windows and scaling policies of behavior to
+// the latest recommendation and clamps the result to [min, max]. history holds
+// previous recommendations (including the latest) and events the changes made so far.
+func Normalize(
+	behavior *infrav1.AutoscalerBehavior,
+	current, min, max int32,
+	history []Recommendation,
+	events []infrav1.ScaleEvent,
+	now time.Time,
+) int32 {
+	up, down := scaleUpRules(behavior), scaleDownRules(behavior)
+
+	desired := stabilize(current, up, down, history, now)
+
+	switch {
+	case desired > current:
+		desired = limitScaleUp(current, desired, up, events, now)
+	case desired < current:
+		desired = limitScaleDown(current, desired, down, events, now)
+	}
+
+	if desired < min {
+		desired = min
+	}
+
+	if desired > max {
+		desired = max
+	}
+
+	return desired
+}
+
+// stabilize uses the lowest recommendation in the scale up window as the upper
+// bound and the highest in the scale down window as the lower bound, so that
+// short-lived spikes do not cause flapping.
+func stabilize(current int32, up, down *infrav1.ScalingRules, history []Recommendation, now time.Time) int32 {
+	upBound, downBound := current, current
+	upSeen, downSeen := false, false
+	upSince := now.Add(-windowOf(up))
+	downSince := now.Add(-windowOf(down))
+
+	for _, rec := range history {
+		if !rec.Time.Before(upSince) {
+			if !upSeen || rec.Replicas < upBound {
+				upBound = rec.Replicas
+			}
+
+			upSeen = true
+		}
+
+		if !rec.Time.Before(downSince) {
+			if !downSeen || rec.Replicas > downBound {
+				downBound = rec.Replicas
+			}
+
+			downSeen = true
+		}
+	}
+
+	switch {
+	case upBound > current:
+		return upBound
+	case downBound < current:
+		return downBound
+	default:
+		return current
+	}
+}
+
+func limitScaleUp(current, desired int32, rules *infrav1.ScalingRules, events []infrav1.ScaleEvent, now time.Time) int32 {
+	if selectOf(rules) == infrav1.DisabledPolicySelect {
+		return current
+	}
+
+	var limit int32
+
+	for i, policy := range rules.Policies {
+		start := current - changeInPeriod(events, policy.PeriodSeconds, now, true)
+
+		var proposed int32
+		if policy.Type == infrav1.PercentScalingPolicy {
+			proposed = int32(math.Ceil(float64(start) * (1 + float64(policy.Value)/100))) //nolint: gomnd // percent
+		} else {
+			proposed = start + policy.Value
+		}
+
+		if i == 0 || (selectOf(rules) == infrav1.MaxPolicySelect) == (proposed > limit) {
+			limit = proposed
+		}
+	}
+
+	if len(rules.Policies) == 0 || desired < limit {
+		return desired
+	}
+
+	if limit < current {
+		return current
+	}
+
+	return limit
+}
+
+func limitScaleDown(current, desired int32, rules *infrav1.ScalingRules, events []infrav1.ScaleEvent, now time.Time) int32 {
+	if selectOf(rules) == infrav1.DisabledPolicySelect {
+		return current
+	}
+
+	var limit int32
+
+	for i, policy := range rules.Policies {
+		start := current + changeInPeriod(events, policy.PeriodSeconds, now, false)
+
+		var proposed int32
+		if policy.Type == infrav1.PercentScalingPolicy {
+			proposed = int32(math.Floor(float64(start) * (1 - float64(policy.Value)/100))) //nolint: gomnd // percent
+		} else {
+			proposed = start - policy.Value
+		}
+
+		if i == 0 || (selectOf(rules) == infrav1.MaxPolicySelect) == (proposed < limit) {
+			limit = proposed
+		}
+	}
+
+	if len(rules.Policies) == 0 || desired > limit {
+		return desired
+	}
+
+	if limit > current {
+		return current
+	}
+
+	return limit
+}
+
+// changeInPeriod sums the replicas added (or removed) by events inside the period.
+func changeInPeriod(events []infrav1.ScaleEvent, periodSeconds int32, now time.Time, up bool) int32 {
+	since := now.Add(-time.Duration(periodSeconds) * time.Second)
+
+	var total int32
+
+	for _, event := range events {
+		if event.Time.Time.Before(since) {
+			continue
+		}
+
+		change := event.To - event.From
+		if up && change > 0 {
+			total += change
+		}
+
+		if !up && change < 0 {
+			total -= change
+		}
+	}
+
+	return total
+}
+
+// MaxPeriod returns the longest period or window in behavior, beyond which
+// recommendations and events no longer need to be kept.
+func MaxPeriod(behavior *infrav1.AutoscalerBehavior) time.Duration {
+	longest := time.Duration(0)
+
+	for _, rules := range []*infrav1.ScalingRules{scaleUpRules(behavior), scaleDownRules(behavior)} {
+		if w := windowOf(rules); w > longest {
+			longest = w
+		}
+
+		for _, policy := range rules.Policies {
+			if p := time.Duration(policy.PeriodSeconds) * time.Second; p > longest {
+				longest = p
+			}
+		}
+	}
+
+	return longest
+}
+
+func windowOf(rules *infrav1.ScalingRules) time.Duration {
+	if rules.StabilizationWindowSeconds == nil {
+		return 0
+	}
+
+	return time.Duration(*rules.StabilizationWindowSeconds) * time.Second
+}
+
+func selectOf(rules *infrav1.ScalingRules) infrav1.ScalingPolicySelect {
+	if rules.SelectPolicy == nil {
+		return infrav1.MaxPolicySelect
+	}
+
+	return *rules.SelectPolicy
+}
+
+func scaleUpRules(behavior *infrav1.AutoscalerBehavior) *infrav1.ScalingRules {
+	rules := &infrav1.ScalingRules{}
+	if behavior != nil && behavior.ScaleUp != nil {
+		rules = behavior.ScaleUp.DeepCopy()
+	}
+
+	if rules.StabilizationWindowSeconds == nil {
+		rules.StabilizationWindowSeconds = int32Ptr(0)
+	}
+
+	if len(rules.Policies) == 0 {
+		rules.Policies = []infrav1.ScalingPolicy{
+			{Type: infrav1.PercentScalingPolicy, Value: defaultPolicyPercent, PeriodSeconds: defaultPolicyPeriodSeconds},
+			{Type: infrav1.ReplicasScalingPolicy, Value: defaultScaleUpPodsValue, PeriodSeconds: defaultPolicyPeriodSeconds},
+		}
+	}
+
+	return rules
+}
+
+func scaleDownRules(behavior *infrav1.AutoscalerBehavior) *infrav1.ScalingRules {
+	rules := &infrav1.ScalingRules{}
+	if behavior != nil && behavior.ScaleDown != nil {
+		rules = behavior.ScaleDown.DeepCopy()
+	}
+
+	if rules.StabilizationWindowSeconds == nil {
+		rules.StabilizationWindowSeconds = int32Ptr(defaultScaleDownWindowSeconds)
+	}
+
+	if len(rules.Policies) == 0 {
+		rules.Policies = []infrav1.ScalingPolicy{
+			{Type: infrav1.PercentScalingPolicy, Value: defaultPolicyPercent, PeriodSeconds: defaultPolicyPeriodSeconds},
+		}
+	}
+
+	return rules
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package autoscaler_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/autoscaler"
+)
+
+func TestProportionalReplicas(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(autoscaler.ProportionalReplicas(2, 2, 1)).To(Equal(int32(4)))
+	g.Expect(autoscaler.ProportionalReplicas(4, 1, 2)).To(Equal(int32(2)))
+	g.Expect(autoscaler.ProportionalReplicas(3, 1.05, 1)).To(Equal(int32(3)), "within tolerance")
+	g.Expect(autoscaler.ProportionalReplicas(0, 3, 1)).To(Equal(int32(3)), "scaling from zero")
+}
+
+func TestDensityReplicas(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(autoscaler.DensityReplicas(2, 2, 4, 1)).To(Equal(int32(4)))
+	g.Expect(autoscaler.DensityReplicas(4, 6, 4, 1)).To(Equal(int32(2)))
+	g.Expect(autoscaler.DensityReplicas(1, 6, 2, 1)).To(Equal(int32(0)))
+	g.Expect(autoscaler.DensityReplicas(4, 2, 3, 3)).To(Equal(int32(7)), "spread across hosts")
+}
+
+func TestNormalize(t *testing.T) {
+	now := time.Now()
+
+	tt := []struct {
+		name     string
+		behavior *infrav1.AutoscalerBehavior
+		current  int32
+		history  []autoscaler.Recommendation
+		events   []infrav1.ScaleEvent
+		expected int32
+	}{
+		{
+			name:     "default scale up is limited to doubling or adding four, whichever is more",
+			current:  2,
+			history:  []autoscaler.Recommendation{{Time: now, Replicas: 20}},
+			expected: 6,
+		},
+		{
+			name:    "default scale down waits for the stabilization window",
+			current: 6,
+			history: []autoscaler.Recommendation{
+				{Time: now.Add(-time.Minute), Replicas: 5},
+				{Time: now, Replicas: 2},
+			},
+			expected: 5,
+		},
+		{
+			name: "scale up uses the lowest recommendation in the window",
+			behavior: &infrav1.AutoscalerBehavior{
+				ScaleUp: &infrav1.ScalingRules{StabilizationWindowSeconds: pointer.Int32(60)},
+			},
+			current: 2,
+			history: []autoscaler.Recommendation{
+				{Time: now.Add(-30 * time.Second), Replicas: 3},
+				{Time: now, Replicas: 5},
+			},
+			expected: 3,
+		},
+		{
+			name: "replicas policy takes previous events in the period into account",
+			behavior: &infrav1.AutoscalerBehavior{
+				ScaleUp: &infrav1.ScalingRules{
+					Policies: []infrav1.ScalingPolicy{
+						{Type: infrav1.ReplicasScalingPolicy, Value: 2, PeriodSeconds: 60},
+					},
+				},
+			},
+			current:  4,
+			history:  []autoscaler.Recommendation{{Time: now, Replicas: 10}},
+			events:   []infrav1.ScaleEvent{{Time: metav1.NewTime(now.Add(-30 * time.Second)), From: 3, To: 4}},
+			expected: 5,
+		},
+		{
+			name: "disabled scale down keeps the current replicas",
+			behavior: &infrav1.AutoscalerBehavior{
+				ScaleDown: &infrav1.ScalingRules{
+					StabilizationWindowSeconds: pointer.Int32(0),
+					SelectPolicy:               selectPolicy(infrav1.DisabledPolicySelect),
+				},
+			},
+			current:  4,
+			history:  []autoscaler.Recommendation{{Time: now, Replicas: 1}},
+			expected: 4,
+		},
+		{
+			name:     "result is clamped to max",
+			current:  8,
+			history:  []autoscaler.Recommendation{{Time: now, Replicas: 12}},
+			expected: 10,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got := autoscaler.Normalize(tc.behavior, tc.current, 1, 10, tc.history, tc.events, now)
+			g.Expect(got).To(Equal(tc.expected))
+		})
+	}
+}
+
+func selectPolicy(s infrav1.ScalingPolicySelect) *infrav1.ScalingPolicySelect {
+	return &s
+}
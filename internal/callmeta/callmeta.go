@@ -0,0 +1,206 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package callmeta sends who made each call to a flintlock host as gRPC
+// metadata, so that the host can attribute the operations on its VMs to the
+// operator version, controller, object and reconcile which made them.
+package callmeta
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+)
+
+// The headers the operator sends.
+const (
+	// OperatorVersionHeader is the version of the operator.
+	OperatorVersionHeader = "x-liquidmetal-operator-version"
+	// ControllerHeader is the name of the controller which made the call.
+	ControllerHeader = "x-liquidmetal-controller"
+	// NamespaceHeader is the namespace of the object being reconciled.
+	NamespaceHeader = "x-liquidmetal-namespace"
+	// NameHeader is the name of the object being reconciled.
+	NameHeader = "x-liquidmetal-name"
+	// UIDHeader is the UID of the object being reconciled.
+	UIDHeader = "x-liquidmetal-uid"
+	// ReconcileUIDHeader is unique to each reconcile, so that the calls made
+	// by one attempt can be told apart from those of the next.
+	ReconcileUIDHeader = "x-liquidmetal-reconcile-uid"
+)
+
+type reconcileKey struct{}
+
+// reconcile is who is making the calls of a context.
+type reconcile struct {
+	controller string
+	namespace  string
+	name       string
+	uid        types.UID
+	attempt    types.UID
+}
+
+// ForReconcile returns ctx carrying the identity of a reconcile of obj by
+// controller, for the calls made with it, along with the UID it gave the
+// reconcile. obj is nil when the reconcile is not of a single object.
+func ForReconcile(ctx context.Context, controller string, obj client.Object) (context.Context, types.UID) {
+	r := reconcile{controller: controller, attempt: uuid.NewUUID()}
+
+	if obj != nil {
+		r.namespace = obj.GetNamespace()
+		r.name = obj.GetName()
+		r.uid = obj.GetUID()
+	}
+
+	return context.WithValue(ctx, reconcileKey{}, r), r.attempt
+}
+
+// Headers returns the headers of the calls made with ctx, before any are
+// excluded or added by the configuration.
+func Headers(ctx context.Context) map[string]string {
+	headers := map[string]string{
+		OperatorVersionHeader: defaults.Version,
+	}
+
+	r, ok := ctx.Value(reconcileKey{}).(reconcile)
+	if !ok {
+		return headers
+	}
+
+	for header, value := range map[string]string{
+		ControllerHeader:   r.controller,
+		NamespaceHeader:    r.namespace,
+		NameHeader:         r.name,
+		UIDHeader:          string(r.uid),
+		ReconcileUIDHeader: string(r.attempt),
+	} {
+		if value != "" {
+			headers[header] = value
+		}
+	}
+
+	return headers
+}
+
+// ValidateKey returns an error if key cannot be sent as an extra header.
+func ValidateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("metadata key is empty")
+	}
+
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return fmt.Errorf("metadata key %q must only hold lower case letters, digits, '-', '_' and '.'", key)
+		}
+	}
+
+	if strings.HasPrefix(key, "grpc-") || strings.HasSuffix(key, "-bin") {
+		return fmt.Errorf("metadata key %q is reserved", key)
+	}
+
+	return nil
+}
+
+// FactoryFunc wraps factory so that the clients it returns send the headers
+// of each call's context, as settings says.
+func FactoryFunc(factory flclient.FactoryFunc, settings func() configv1.MetadataConfiguration) flclient.FactoryFunc {
+	return func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		client, err := factory(address, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &headerClient{Client: client, settings: settings}, nil
+	}
+}
+
+// headerClient is a flintlock client which sends the headers of the context
+// of each call.
+type headerClient struct {
+	flclient.Client
+
+	settings func() configv1.MetadataConfiguration
+}
+
+// outgoing returns ctx with the headers to send appended to its metadata.
+func (c *headerClient) outgoing(ctx context.Context) context.Context {
+	cfg := c.settings()
+	headers := Headers(ctx)
+
+	for _, header := range cfg.Exclude {
+		delete(headers, header)
+	}
+
+	for key, value := range cfg.Extra {
+		if _, own := headers[key]; !own && ValidateKey(key) == nil {
+			headers[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key, headers[key])
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+func (c *headerClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	return c.Client.CreateMicroVM(c.outgoing(ctx), in, opts...)
+}
+
+func (c *headerClient) DeleteMicroVM(
+	ctx context.Context,
+	in *flintlockv1.DeleteMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*emptypb.Empty, error) {
+	return c.Client.DeleteMicroVM(c.outgoing(ctx), in, opts...)
+}
+
+func (c *headerClient) GetMicroVM(
+	ctx context.Context,
+	in *flintlockv1.GetMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.GetMicroVMResponse, error) {
+	return c.Client.GetMicroVM(c.outgoing(ctx), in, opts...)
+}
+
+func (c *headerClient) ListMicroVMs(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.ListMicroVMsResponse, error) {
+	return c.Client.ListMicroVMs(c.outgoing(ctx), in, opts...)
+}
+
+func (c *headerClient) ListMicroVMsStream(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (flintlockv1.MicroVM_ListMicroVMsStreamClient, error) {
+	return c.Client.ListMicroVMsStream(c.outgoing(ctx), in, opts...)
+}
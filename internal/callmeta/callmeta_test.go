@@ -0,0 +1,112 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package callmeta_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/callmeta"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+)
+
+// serve starts a MicroVM server which records the metadata of each call it
+// receives, and returns its address.
+func serve(g *WithT, received *metadata.MD) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		*received, _ = metadata.FromIncomingContext(ctx)
+
+		return handler(ctx, req)
+	}))
+	flintlockv1.RegisterMicroVMServer(server, flintlockv1.UnimplementedMicroVMServer{})
+
+	go func() { _ = server.Serve(listener) }()
+
+	return listener.Addr().String(), server.Stop
+}
+
+func TestFactoryFunc(t *testing.T) {
+	g := NewWithT(t)
+
+	var received metadata.MD
+
+	address, stop := serve(g, &received)
+	defer stop()
+
+	settings := configv1.MetadataConfiguration{}
+	factory := callmeta.FactoryFunc(flclient.NewFlintlockClient, func() configv1.MetadataConfiguration {
+		return settings
+	})
+
+	client, err := factory(address)
+	g.Expect(err).NotTo(HaveOccurred())
+	defer client.Close()
+
+	mvm := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1", UID: "abc-123"}}
+	ctx, reconcileUID := callmeta.ForReconcile(context.TODO(), "microvm", mvm)
+	g.Expect(reconcileUID).NotTo(BeEmpty())
+
+	_, _ = client.GetMicroVM(ctx, &flintlockv1.GetMicroVMRequest{Uid: "vm1"})
+	g.Expect(received.Get(callmeta.OperatorVersionHeader)).To(ConsistOf(defaults.Version))
+	g.Expect(received.Get(callmeta.ControllerHeader)).To(ConsistOf("microvm"))
+	g.Expect(received.Get(callmeta.NamespaceHeader)).To(ConsistOf("ns1"))
+	g.Expect(received.Get(callmeta.NameHeader)).To(ConsistOf("mvm1"))
+	g.Expect(received.Get(callmeta.UIDHeader)).To(ConsistOf("abc-123"))
+	g.Expect(received.Get(callmeta.ReconcileUIDHeader)).To(ConsistOf(string(reconcileUID)))
+
+	// the next reconcile is told apart from this one
+	next, nextUID := callmeta.ForReconcile(context.TODO(), "microvm", mvm)
+	g.Expect(nextUID).NotTo(Equal(reconcileUID))
+
+	settings = configv1.MetadataConfiguration{
+		Exclude: []string{callmeta.ReconcileUIDHeader, callmeta.NameHeader},
+		Extra: map[string]string{
+			"x-cluster":              "edge-1",
+			callmeta.NamespaceHeader: "spoofed",
+			"Not-Valid":              "skipped",
+		},
+	}
+
+	_, _ = client.DeleteMicroVM(next, &flintlockv1.DeleteMicroVMRequest{Uid: "vm1"})
+	g.Expect(received.Get(callmeta.ReconcileUIDHeader)).To(BeEmpty(), "Expected an excluded header not to be sent")
+	g.Expect(received.Get(callmeta.NameHeader)).To(BeEmpty(), "Expected an excluded header not to be sent")
+	g.Expect(received.Get("x-cluster")).To(ConsistOf("edge-1"))
+	g.Expect(received.Get(callmeta.NamespaceHeader)).To(ConsistOf("ns1"), "Expected an extra header not to replace the operator's")
+	g.Expect(received.Get("not-valid")).To(BeEmpty())
+
+	// calls made outside of a reconcile only say which operator made them
+	settings = configv1.MetadataConfiguration{}
+
+	_, _ = client.ListMicroVMs(context.TODO(), &flintlockv1.ListMicroVMsRequest{})
+	g.Expect(received.Get(callmeta.OperatorVersionHeader)).To(ConsistOf(defaults.Version))
+	g.Expect(received.Get(callmeta.ControllerHeader)).To(BeEmpty())
+}
+
+func TestValidateKey(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(callmeta.ValidateKey("x-cluster.name_1")).To(Succeed())
+	g.Expect(callmeta.ValidateKey("")).NotTo(Succeed())
+	g.Expect(callmeta.ValidateKey("X-Cluster")).NotTo(Succeed())
+	g.Expect(callmeta.ValidateKey("grpc-timeout")).NotTo(Succeed())
+	g.Expect(callmeta.ValidateKey("x-token-bin")).NotTo(Succeed())
+}
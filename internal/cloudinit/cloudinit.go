@@ -0,0 +1,310 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package cloudinit merges the cloud-init settings on a Microvm spec into the
+// metadata generated for its VM.
+package cloudinit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"github.com/weaveworks-liquidmetal/flintlock/client/cloudinit/instance"
+	"google.golang.org/grpc"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+const (
+	// vendorDataKey and metaDataKey are the metadata keys flintlock passes the
+	// vendor data and instance metadata to the guest under.
+	vendorDataKey = "vendor-data"
+	metaDataKey   = "meta-data"
+	header        = "#cloud-config\n"
+
+	// resolvedDropIn is the systemd-resolved configuration written for the
+	// DNS settings of a Microvm.
+	resolvedDropIn = "/etc/systemd/resolved.conf.d/microvm.conf"
+
+	// phoneHomeTries is how many times the guest tries to call back, as the
+	// operator may be restarting when it first does.
+	phoneHomeTries = 10
+)
+
+// Settings are the cloud-init settings of a Microvm spec which are merged into
+// the metadata generated for its VM.
+type Settings struct {
+	// Hostname replaces the hostname generated from the name of the Microvm.
+	Hostname string
+	// DNS configures the resolver of the guest.
+	DNS *infrav1.DNSConfig
+	// VendorData adds to the generated vendor data.
+	VendorData *infrav1.VendorData
+	// PhoneHomeURL is where the guest posts its instance ID, hostname and FQDN
+	// once cloud-init has finished.
+	PhoneHomeURL string
+}
+
+// ForSpec returns the settings of spec.
+func ForSpec(spec *infrav1.MicrovmSpec) Settings {
+	return Settings{
+		Hostname:   spec.Hostname,
+		DNS:        spec.DNS,
+		VendorData: spec.VendorData,
+	}
+}
+
+// IsEmpty returns true if the settings leave the generated metadata as it is.
+func (s Settings) IsEmpty() bool {
+	return s.Hostname == "" && s.DNS == nil && s.VendorData == nil && s.PhoneHomeURL == ""
+}
+
+// Client wraps client so that the metadata of each VM it creates has settings
+// merged into it. It returns client as is when there is nothing to merge.
+func Client(client flclient.Client, settings Settings) flclient.Client {
+	if settings.IsEmpty() {
+		return client
+	}
+
+	return &settingsClient{Client: client, settings: settings}
+}
+
+// settingsClient is a flintlock client which merges extra settings into the
+// metadata of the VMs it creates.
+type settingsClient struct {
+	flclient.Client
+
+	settings Settings
+}
+
+func (c *settingsClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	if spec := in.GetMicrovm(); spec != nil {
+		vendorData, err := MergeVendorData(spec.Metadata[vendorDataKey], c.settings)
+		if err != nil {
+			return nil, err
+		}
+
+		metaData, err := MergeMetaData(spec.Metadata[metaDataKey], c.settings)
+		if err != nil {
+			return nil, err
+		}
+
+		if spec.Metadata == nil {
+			spec.Metadata = map[string]string{}
+		}
+
+		spec.Metadata[vendorDataKey] = vendorData
+		spec.Metadata[metaDataKey] = metaData
+	}
+
+	return c.Client.CreateMicroVM(ctx, in, opts...)
+}
+
+// PhoneHomeClient wraps client so that each VM it creates calls back to the URL
+// returned by url once cloud-init has finished. The URL carries a one-time
+// token, so it is only asked for as the VM is created. Nothing is added when
+// it returns an empty URL.
+func PhoneHomeClient(client flclient.Client, url func() string) flclient.Client {
+	return &phoneHomeClient{Client: client, url: url}
+}
+
+// phoneHomeClient is a flintlock client which asks the VMs it creates to call
+// back once they have booted.
+type phoneHomeClient struct {
+	flclient.Client
+
+	url func() string
+}
+
+func (c *phoneHomeClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	url := c.url()
+
+	if spec := in.GetMicrovm(); spec != nil && url != "" {
+		vendorData, err := MergeVendorData(spec.Metadata[vendorDataKey], Settings{PhoneHomeURL: url})
+		if err != nil {
+			return nil, err
+		}
+
+		if spec.Metadata == nil {
+			spec.Metadata = map[string]string{}
+		}
+
+		spec.Metadata[vendorDataKey] = vendorData
+	}
+
+	return c.Client.CreateMicroVM(ctx, in, opts...)
+}
+
+// MergeVendorData adds settings to the base64 encoded cloud-config in
+// encoded. The packages and commands are appended to any already there, so
+// the users and SSH keys generated by the operator are kept.
+func MergeVendorData(encoded string, settings Settings) (string, error) {
+	config := yaml.MapSlice{}
+	if err := decode(encoded, &config); err != nil {
+		return "", fmt.Errorf("reading vendor data: %w", err)
+	}
+
+	if settings.Hostname != "" {
+		if errs := validation.IsDNS1123Label(settings.Hostname); len(errs) > 0 {
+			return "", fmt.Errorf("%w %q: %s", errInvalidHostname, settings.Hostname, strings.Join(errs, ", "))
+		}
+
+		config = set(config, "hostname", settings.Hostname)
+	}
+
+	if settings.DNS != nil {
+		commands, err := resolverCommands(settings.DNS)
+		if err != nil {
+			return "", err
+		}
+
+		config = appendList(config, "bootcmd", commands)
+	}
+
+	if vendorData := settings.VendorData; vendorData != nil {
+		config = appendList(config, "packages", vendorData.Packages)
+		config = appendList(config, "runcmd", vendorData.RunCommands)
+
+		if vendorData.Timezone != "" {
+			config = set(config, "timezone", vendorData.Timezone)
+		}
+
+		if len(vendorData.NTPServers) > 0 {
+			config = set(config, "ntp", yaml.MapSlice{
+				{Key: "enabled", Value: true},
+				{Key: "servers", Value: vendorData.NTPServers},
+			})
+		}
+	}
+
+	if settings.PhoneHomeURL != "" {
+		config = set(config, "phone_home", yaml.MapSlice{
+			{Key: "url", Value: settings.PhoneHomeURL},
+			{Key: "post", Value: []string{"instance_id", "hostname", "fqdn"}},
+			{Key: "tries", Value: phoneHomeTries},
+		})
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("marshalling vendor data: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(append([]byte(header), out...)), nil
+}
+
+// MergeMetaData sets the hostname of settings on the base64 encoded instance
+// metadata in encoded.
+func MergeMetaData(encoded string, settings Settings) (string, error) {
+	if settings.Hostname == "" {
+		return encoded, nil
+	}
+
+	metadata := instance.Metadata{}
+	if err := decode(encoded, &metadata); err != nil {
+		return "", fmt.Errorf("reading instance metadata: %w", err)
+	}
+
+	instance.WithLocalHostname(settings.Hostname)(metadata)
+
+	out, err := yaml.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("marshalling instance metadata: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// resolverCommands returns the boot commands which point systemd-resolved at
+// the servers and search domains of dns. They run on every boot, before the
+// packages are installed.
+func resolverCommands(dns *infrav1.DNSConfig) ([]string, error) {
+	for _, server := range dns.Nameservers {
+		if net.ParseIP(server) == nil {
+			return nil, fmt.Errorf("%w: %q", errInvalidNameserver, server)
+		}
+	}
+
+	for _, search := range dns.Searches {
+		if errs := validation.IsDNS1123Subdomain(search); len(errs) > 0 {
+			return nil, fmt.Errorf("%w %q: %s", errInvalidSearch, search, strings.Join(errs, ", "))
+		}
+	}
+
+	conf := "[Resolve]\\n"
+	if len(dns.Nameservers) > 0 {
+		conf += "DNS=" + strings.Join(dns.Nameservers, " ") + "\\n"
+	}
+
+	if len(dns.Searches) > 0 {
+		conf += "Domains=" + strings.Join(dns.Searches, " ") + "\\n"
+	}
+
+	return []string{
+		"mkdir -p " + path.Dir(resolvedDropIn),
+		fmt.Sprintf("printf '%s' > %s", conf, resolvedDropIn),
+		"systemctl restart systemd-resolved",
+	}, nil
+}
+
+func decode(encoded string, out interface{}) error {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(data, out)
+}
+
+// appendList appends items to the list under key, adding it if there is none.
+func appendList(config yaml.MapSlice, key string, items []string) yaml.MapSlice {
+	if len(items) == 0 {
+		return config
+	}
+
+	for i := range config {
+		if config[i].Key != key {
+			continue
+		}
+
+		existing, _ := config[i].Value.([]interface{})
+		for _, item := range items {
+			existing = append(existing, item)
+		}
+
+		config[i].Value = existing
+
+		return config
+	}
+
+	return append(config, yaml.MapItem{Key: key, Value: items})
+}
+
+// set sets the value under key, replacing any already there.
+func set(config yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i := range config {
+		if config[i].Key == key {
+			config[i].Value = value
+
+			return config
+		}
+	}
+
+	return append(config, yaml.MapItem{Key: key, Value: value})
+}
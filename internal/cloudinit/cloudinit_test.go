@@ -0,0 +1,181 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package cloudinit_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	"gopkg.in/yaml.v2"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/cloudinit"
+)
+
+const generated = `#cloud-config
+hostname: mvm1
+users:
+- name: ubuntu
+  ssh_authorized_keys:
+  - ssh-ed25519 AAAA
+runcmd:
+- echo generated
+`
+
+func decode(g *WithT, encoded string) (string, map[string]interface{}) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	parsed := map[string]interface{}{}
+	g.Expect(yaml.Unmarshal(data, &parsed)).To(Succeed())
+
+	return string(data), parsed
+}
+
+func TestMergeVendorData(t *testing.T) {
+	g := NewWithT(t)
+
+	merged, err := cloudinit.MergeVendorData(base64.StdEncoding.EncodeToString([]byte(generated)), cloudinit.Settings{
+		VendorData: &infrav1.VendorData{
+			Packages:    []string{"nvme-cli"},
+			RunCommands: []string{"systemctl start app"},
+			Timezone:    "Europe/London",
+			NTPServers:  []string{"ntp.example.com"},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	raw, parsed := decode(g, merged)
+	g.Expect(strings.HasPrefix(raw, "#cloud-config\n")).To(BeTrue(), "Expected the cloud-config header to be kept")
+	g.Expect(parsed).To(HaveKeyWithValue("hostname", "mvm1"))
+	g.Expect(parsed).To(HaveKey("users"), "Expected the generated users to be kept")
+	g.Expect(parsed).To(HaveKeyWithValue("packages", ConsistOf("nvme-cli")))
+	g.Expect(parsed).To(HaveKeyWithValue("runcmd", Equal([]interface{}{"echo generated", "systemctl start app"})),
+		"Expected the commands to follow the generated ones")
+	g.Expect(parsed).To(HaveKeyWithValue("timezone", "Europe/London"))
+	g.Expect(parsed).To(HaveKeyWithValue("ntp", HaveKeyWithValue("servers", ConsistOf("ntp.example.com"))))
+
+	_, err = cloudinit.MergeVendorData("not base64!", cloudinit.Settings{VendorData: &infrav1.VendorData{}})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestMergeVendorData_HostnameAndDNS(t *testing.T) {
+	g := NewWithT(t)
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(generated))
+
+	merged, err := cloudinit.MergeVendorData(encoded, cloudinit.Settings{
+		Hostname: "db-0",
+		DNS: &infrav1.DNSConfig{
+			Nameservers: []string{"10.0.0.2", "10.0.0.3"},
+			Searches:    []string{"example.com"},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	raw, parsed := decode(g, merged)
+	g.Expect(parsed).To(HaveKeyWithValue("hostname", "db-0"))
+	g.Expect(raw).To(ContainSubstring("DNS=10.0.0.2 10.0.0.3"))
+	g.Expect(raw).To(ContainSubstring("Domains=example.com"))
+	g.Expect(parsed).To(HaveKeyWithValue("bootcmd", ContainElement("systemctl restart systemd-resolved")))
+
+	_, err = cloudinit.MergeVendorData(encoded, cloudinit.Settings{Hostname: "Not_A_Hostname"})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid hostname")))
+
+	_, err = cloudinit.MergeVendorData(encoded, cloudinit.Settings{
+		DNS: &infrav1.DNSConfig{Nameservers: []string{"10.0.0.2' > /etc/passwd; echo '"}},
+	})
+	g.Expect(err).To(MatchError(ContainSubstring("not an IP address")), "Expected nameservers to be checked before being written")
+}
+
+func TestMergeMetaData(t *testing.T) {
+	g := NewWithT(t)
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("local_hostname: mvm1\nplatform: liquid_metal\n"))
+
+	unchanged, err := cloudinit.MergeMetaData(encoded, cloudinit.Settings{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(unchanged).To(Equal(encoded), "Expected the hostname to default to the name of the microvm")
+
+	merged, err := cloudinit.MergeMetaData(encoded, cloudinit.Settings{Hostname: "db-0"})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, parsed := decode(g, merged)
+	g.Expect(parsed).To(HaveKeyWithValue("local_hostname", "db-0"))
+	g.Expect(parsed).To(HaveKeyWithValue("platform", "liquid_metal"))
+}
+
+func TestClient(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeAPIClient := &fakes.FakeClient{}
+	g.Expect(cloudinit.Client(fakeAPIClient, cloudinit.Settings{})).To(BeIdenticalTo(fakeAPIClient),
+		"Expected no wrapper without settings")
+
+	client := cloudinit.Client(fakeAPIClient, cloudinit.ForSpec(&infrav1.MicrovmSpec{
+		Hostname:   "db-0",
+		VendorData: &infrav1.VendorData{Packages: []string{"nvme-cli"}},
+	}))
+
+	_, err := client.CreateMicroVM(context.TODO(), &flintlockv1.CreateMicroVMRequest{
+		Microvm: &flintlocktypes.MicroVMSpec{
+			Id: "mvm1",
+			Metadata: map[string]string{
+				"vendor-data": base64.StdEncoding.EncodeToString([]byte(generated)),
+				"meta-data":   base64.StdEncoding.EncodeToString([]byte("local_hostname: mvm1\n")),
+			},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(1))
+	_, req, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
+
+	_, parsed := decode(g, req.Microvm.Metadata["vendor-data"])
+	g.Expect(parsed).To(HaveKeyWithValue("packages", ConsistOf("nvme-cli")))
+	g.Expect(parsed).To(HaveKey("users"))
+
+	_, parsed = decode(g, req.Microvm.Metadata["meta-data"])
+	g.Expect(parsed).To(HaveKeyWithValue("local_hostname", "db-0"))
+}
+
+func TestPhoneHomeClient(t *testing.T) {
+	g := NewWithT(t)
+
+	url := ""
+	fakeAPIClient := &fakes.FakeClient{}
+	client := cloudinit.PhoneHomeClient(fakeAPIClient, func() string { return url })
+
+	create := func() map[string]interface{} {
+		_, err := client.CreateMicroVM(context.TODO(), &flintlockv1.CreateMicroVMRequest{
+			Microvm: &flintlocktypes.MicroVMSpec{
+				Id:       "mvm1",
+				Metadata: map[string]string{"vendor-data": base64.StdEncoding.EncodeToString([]byte(generated))},
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, req, _ := fakeAPIClient.CreateMicroVMArgsForCall(fakeAPIClient.CreateMicroVMCallCount() - 1)
+		_, parsed := decode(g, req.Microvm.Metadata["vendor-data"])
+
+		return parsed
+	}
+
+	g.Expect(create()).NotTo(HaveKey("phone_home"), "Expected no callback without a url")
+
+	url = "https://10.0.0.1:9445/phone-home/ns1/mvm1/token"
+
+	parsed := create()
+	g.Expect(parsed).To(HaveKey("users"), "Expected the generated vendor data to be kept")
+	g.Expect(parsed).To(HaveKeyWithValue("phone_home", And(
+		HaveKeyWithValue("url", url),
+		HaveKeyWithValue("post", ConsistOf("instance_id", "hostname", "fqdn")),
+	)))
+}
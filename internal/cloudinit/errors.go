@@ -0,0 +1,15 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package cloudinit
+
+import "errors"
+
+var (
+	errInvalidHostname   = errors.New("invalid hostname")
+	errInvalidNameserver = errors.New("nameserver is not an IP address")
+	errInvalidSearch     = errors.New("invalid search domain")
+
+	errInvalidCloudConfig = errors.New("invalid cloud-config")
+	errMissingShebang     = errors.New("a user data script must start with a shebang, such as #!/bin/bash")
+)
@@ -0,0 +1,190 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package cloudinit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+const (
+	// MaxMetadataSize is the most metadata, in bytes, a VM can be given.
+	// Flintlock serves it to the guest from the Firecracker MMDS, whose data
+	// store is limited to this by default.
+	MaxMetadataSize = 51200
+
+	// ValidatorPath is the path the Validator is served on. The Microvm path
+	// derived from its kind is already taken by the quota webhook.
+	ValidatorPath = "/validate-infrastructure-liquid-metal-io-v1alpha1-microvm-userdata"
+
+	shebang = "#!"
+)
+
+// otherFormats are the headers of the cloud-init user data formats which are
+// passed on without being checked.
+var otherFormats = []string{
+	"#include",
+	"#cloud-boothook",
+	"#cloud-config-archive",
+	"#part-handler",
+	"#upstart-job",
+	"## template: jinja",
+	"Content-Type: multipart/",
+}
+
+//+kubebuilder:webhook:path=/validate-infrastructure-liquid-metal-io-v1alpha1-microvm-userdata,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.liquid-metal.io,resources=microvms,verbs=create;update,versions=v1alpha1,name=vmicrovmuserdata.infrastructure.liquid-metal.io,admissionReviewVersions=v1
+
+// Validator refuses Microvms whose user data cloud-init would fail to run, or
+// whose metadata flintlock could not give to the VM, so that the mistake is
+// found when the Microvm is applied rather than once the VM has booted.
+type Validator struct{}
+
+var _ admission.CustomValidator = &Validator{}
+
+// SetupWebhookWithManager registers the validator as a webhook for Microvms.
+func (v *Validator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(ValidatorPath, admission.WithCustomValidator(&infrav1.Microvm{}, v))
+
+	return nil
+}
+
+// ValidateCreate refuses a new Microvm with invalid user data.
+func (v *Validator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	mvm, ok := obj.(*infrav1.Microvm)
+	if !ok {
+		return fmt.Errorf("expected a microvm but got %T", obj)
+	}
+
+	return validate(mvm)
+}
+
+// ValidateUpdate refuses a Microvm whose user data is changed to be invalid.
+// Other updates are always allowed, so that Microvms admitted before the
+// webhook was installed can still be managed and deleted.
+func (v *Validator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	oldMvm, ok := oldObj.(*infrav1.Microvm)
+	if !ok {
+		return fmt.Errorf("expected a microvm but got %T", oldObj)
+	}
+
+	mvm, ok := newObj.(*infrav1.Microvm)
+	if !ok {
+		return fmt.Errorf("expected a microvm but got %T", newObj)
+	}
+
+	if !mvm.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	if stringValue(oldMvm.Spec.UserData) == stringValue(mvm.Spec.UserData) &&
+		MetadataSize(&oldMvm.Spec) >= MetadataSize(&mvm.Spec) {
+		return nil
+	}
+
+	return validate(mvm)
+}
+
+// ValidateDelete always allows a Microvm to be deleted.
+func (v *Validator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+func validate(mvm *infrav1.Microvm) error {
+	path := field.NewPath("spec", "userdata")
+	errs := field.ErrorList{}
+
+	if mvm.Spec.UserData != nil {
+		if err := ValidateUserData(*mvm.Spec.UserData); err != nil {
+			errs = append(errs, field.Invalid(path, firstLine(*mvm.Spec.UserData), err.Error()))
+		}
+	}
+
+	if size := MetadataSize(&mvm.Spec); size > MaxMetadataSize {
+		errs = append(errs, field.Invalid(path, firstLine(stringValue(mvm.Spec.UserData)),
+			fmt.Sprintf("gives the VM %d bytes of metadata, more than the %d flintlock accepts", size, MaxMetadataSize)))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind("Microvm").GroupKind(), mvm.Name, errs)
+}
+
+// ValidateUserData returns an error if cloud-init would refuse userData. A
+// cloud-config must be a YAML mapping and anything which is not another
+// format cloud-init understands must be a script starting with a shebang.
+func ValidateUserData(userData string) error {
+	trimmed := strings.TrimLeft(userData, " \t\r\n")
+
+	switch {
+	case trimmed == "":
+		return nil
+	case strings.HasPrefix(trimmed, strings.TrimSuffix(header, "\n")) &&
+		!strings.HasPrefix(trimmed, "#cloud-config-archive"):
+		config := map[string]interface{}{}
+		if err := yaml.UnmarshalStrict([]byte(trimmed), &config); err != nil {
+			return fmt.Errorf("%w: %s", errInvalidCloudConfig, err)
+		}
+
+		return nil
+	case strings.HasPrefix(trimmed, shebang):
+		return nil
+	}
+
+	for _, format := range otherFormats {
+		if strings.HasPrefix(trimmed, format) {
+			return nil
+		}
+	}
+
+	return errMissingShebang
+}
+
+// MetadataSize returns how many bytes of metadata the settings of spec give
+// its VM. The metadata the operator generates for every VM adds a little more.
+func MetadataSize(spec *infrav1.MicrovmSpec) int {
+	size := len(stringValue(spec.UserData))
+
+	for _, key := range spec.SSHPublicKeys {
+		size += base64.StdEncoding.EncodedLen(len(key.User))
+
+		for _, authorizedKey := range key.AuthorizedKeys {
+			size += base64.StdEncoding.EncodedLen(len(authorizedKey))
+		}
+	}
+
+	if vendorData, err := MergeVendorData("", ForSpec(spec)); err == nil {
+		size += len(vendorData)
+	}
+
+	return size
+}
+
+// firstLine returns the first line of userData with anything in it, which is
+// enough to say which format it is in.
+func firstLine(userData string) string {
+	line, _, _ := strings.Cut(strings.TrimLeft(userData, " \t\r\n"), "\n")
+
+	return line
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package cloudinit_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/cloudinit"
+)
+
+func newMicrovm(userData string) *infrav1.Microvm {
+	return &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1"},
+		Spec: infrav1.MicrovmSpec{
+			UserData:      pointer.String(userData),
+			SSHPublicKeys: []microvm.SSHPublicKey{{User: "root", AuthorizedKeys: []string{"ssh-ed25519 AAAA"}}},
+		},
+	}
+}
+
+func TestValidateUserData(t *testing.T) {
+	tests := []struct {
+		name     string
+		userData string
+		expected string
+	}{
+		{name: "empty", userData: ""},
+		{name: "script", userData: "#!/bin/bash\necho hi\n"},
+		{name: "script after blank lines", userData: "\n\n#!/bin/sh\necho hi\n"},
+		{name: "cloud-config", userData: "#cloud-config\nruncmd:\n- echo hi\n"},
+		{name: "empty cloud-config", userData: "#cloud-config\n"},
+		{name: "include", userData: "#include\nhttps://example.com/user-data\n"},
+		{name: "multipart", userData: "Content-Type: multipart/mixed; boundary=\"b\"\n"},
+		{name: "invalid yaml", userData: "#cloud-config\nruncmd: [echo hi\n", expected: "invalid cloud-config"},
+		{name: "not a mapping", userData: "#cloud-config\n- echo hi\n", expected: "invalid cloud-config"},
+		{name: "duplicate keys", userData: "#cloud-config\nruncmd: []\nruncmd: []\n", expected: "invalid cloud-config"},
+		{name: "no shebang", userData: "echo hi\n", expected: "shebang"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := cloudinit.ValidateUserData(tc.userData)
+			if tc.expected == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expected)))
+			}
+		})
+	}
+}
+
+func TestValidator_ValidateCreate(t *testing.T) {
+	g := NewWithT(t)
+
+	v := &cloudinit.Validator{}
+
+	g.Expect(v.ValidateCreate(context.TODO(), newMicrovm("#!/bin/bash\necho hi\n"))).To(Succeed())
+	g.Expect(v.ValidateCreate(context.TODO(), &infrav1.Microvm{})).To(Succeed(), "Expected no user data to be allowed")
+
+	err := v.ValidateCreate(context.TODO(), newMicrovm("echo hi\n"))
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "Expected a script without a shebang to be refused")
+	g.Expect(err.Error()).To(ContainSubstring("spec.userdata"))
+
+	tooBig := "#!/bin/bash\n" + strings.Repeat("echo hi\n", cloudinit.MaxMetadataSize/8)
+	err = v.ValidateCreate(context.TODO(), newMicrovm(tooBig))
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "Expected metadata over the limit to be refused")
+	g.Expect(err.Error()).To(ContainSubstring("bytes of metadata"))
+}
+
+func TestValidator_ValidateUpdate(t *testing.T) {
+	g := NewWithT(t)
+
+	v := &cloudinit.Validator{}
+	invalid := newMicrovm("echo hi\n")
+
+	// a microvm admitted before the webhook can still be managed
+	labelled := invalid.DeepCopy()
+	labelled.Labels = map[string]string{"app": "web"}
+	g.Expect(v.ValidateUpdate(context.TODO(), invalid, labelled)).To(Succeed())
+
+	changed := invalid.DeepCopy()
+	changed.Spec.UserData = pointer.String("#cloud-config\n- echo hi\n")
+	g.Expect(apierrors.IsInvalid(v.ValidateUpdate(context.TODO(), invalid, changed))).To(BeTrue(),
+		"Expected user data changed to be invalid to be refused")
+
+	fixed := invalid.DeepCopy()
+	fixed.Spec.UserData = pointer.String("#!/bin/bash\necho hi\n")
+	g.Expect(v.ValidateUpdate(context.TODO(), invalid, fixed)).To(Succeed())
+}
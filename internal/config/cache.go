@@ -0,0 +1,29 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// NewCacheFunc returns the cache the manager builds to watch only the objects
+// in namespaces, and cluster scoped objects such as MicrovmHosts. It returns
+// nil, the default cache over every namespace, when namespaces is empty.
+func NewCacheFunc(namespaces []string) (cache.NewCacheFunc, error) {
+	if len(namespaces) == 0 {
+		return nil, nil
+	}
+
+	for _, namespace := range namespaces {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return nil, fmt.Errorf("%w %q: %s", errInvalidNamespace, namespace, strings.Join(errs, ", "))
+		}
+	}
+
+	return cache.MultiNamespacedCacheBuilder(namespaces), nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package config loads the operator configuration file and holds the settings
+// which can be changed while the operator is running.
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/callmeta"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/crdcheck"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/featuregates"
+)
+
+// Load reads the configuration file at path over cfg, so that settings which
+// the file leaves out keep their current value.
+func Load(path string, cfg *configv1.OperatorConfiguration) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading operator configuration: %w", err)
+	}
+
+	return Decode(data, cfg)
+}
+
+// Decode reads a configuration file over cfg, rejecting unknown fields,
+// feature gates and CRD check modes.
+func Decode(data []byte, cfg *configv1.OperatorConfiguration) error {
+	cfg.TypeMeta.APIVersion = ""
+	cfg.TypeMeta.Kind = ""
+
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("decoding operator configuration: %w", err)
+	}
+
+	if cfg.APIVersion != configv1.GroupVersion.String() || cfg.Kind != configv1.OperatorConfigurationKind {
+		return fmt.Errorf("%w: %s %s", errUnexpectedKind, cfg.APIVersion, cfg.Kind)
+	}
+
+	if err := featuregates.MutableGates.DeepCopy().SetFromMap(cfg.FeatureGates); err != nil {
+		return fmt.Errorf("decoding operator configuration: %w", err)
+	}
+
+	switch crdcheck.Mode(cfg.CRDCheck) {
+	case crdcheck.Enforce, crdcheck.Warn, crdcheck.Disabled:
+	default:
+		return fmt.Errorf("%w: %q", errUnknownCRDCheck, cfg.CRDCheck)
+	}
+
+	for key := range cfg.Flintlock.Metadata.Extra {
+		if err := callmeta.ValidateKey(key); err != nil {
+			return fmt.Errorf("decoding operator configuration: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/testr"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+)
+
+const testConfig = `apiVersion: config.liquid-metal.io/v1alpha1
+kind: OperatorConfiguration
+controllers:
+  microvm:
+    requeuePeriod: 10s
+    minReconcileInterval: 5s
+flintlock:
+  defaultTLSSecretRef:
+    name: flintlock-tls
+    namespace: flintlock-system
+  maxConcurrentDeletes: 5
+  contactTimeout: 2m
+  metadata:
+    extra:
+      x-cluster: edge-1
+logging:
+  traceFlintlock: true
+featureGates:
+  ExternalResourceGC: false
+`
+
+func TestDecode(t *testing.T) {
+	g := NewWithT(t)
+
+	cfg := flagConfig()
+	g.Expect(config.Decode([]byte(testConfig), cfg)).To(Succeed())
+
+	g.Expect(cfg.Controllers.Microvm.RequeuePeriod.Duration).To(Equal(10 * time.Second))
+	g.Expect(cfg.Controllers.Microvm.MaxConcurrentReconciles).To(Equal(10), "Expected settings left out to keep the flag value")
+	g.Expect(cfg.Metrics.BindAddress).To(Equal(":8080"))
+	g.Expect(cfg.Flintlock.DefaultTLSSecretRef.Name).To(Equal("flintlock-tls"))
+
+	cfg = flagConfig()
+	g.Expect(config.Decode([]byte("apiVersion: config.liquid-metal.io/v1alpha1\nkind: OperatorConfiguration\n"+
+		"deletion:\n  stuckTimeout: 20m\n  forceStuck: true\ncrdCheck: warn\n"), cfg)).To(Succeed())
+	g.Expect(cfg.Deletion.StuckTimeout.Duration).To(Equal(20 * time.Minute))
+	g.Expect(cfg.Deletion.PendingGrace.Duration).To(Equal(2*time.Minute), "Expected settings left out to keep the flag value")
+	g.Expect(cfg.Deletion.ForceStuck).To(BeTrue())
+	g.Expect(cfg.CRDCheck).To(Equal("warn"))
+
+	err := config.Decode([]byte("apiVersion: config.liquid-metal.io/v1alpha1\nkind: OperatorConfiguration\nfeatureGates:\n  Unknown: true\n"), flagConfig())
+	g.Expect(err).To(HaveOccurred(), "Expected an unknown feature gate to be rejected")
+
+	err = config.Decode([]byte("apiVersion: v1\nkind: ConfigMap\n"), flagConfig())
+	g.Expect(err).To(HaveOccurred(), "Expected another kind to be rejected")
+
+	err = config.Decode([]byte("apiVersion: config.liquid-metal.io/v1alpha1\nkind: OperatorConfiguration\ncrdCheck: skip\n"), flagConfig())
+	g.Expect(err).To(HaveOccurred(), "Expected an unknown crd check mode to be rejected")
+
+	err = config.Decode([]byte(testConfig+"unknown: true\n"), flagConfig())
+	g.Expect(err).To(HaveOccurred(), "Expected an unknown field to be rejected")
+
+	err = config.Decode([]byte("apiVersion: config.liquid-metal.io/v1alpha1\nkind: OperatorConfiguration\nflintlock:\n  metadata:\n    extra:\n      X-Cluster: edge-1\n"), flagConfig())
+	g.Expect(err).To(HaveOccurred(), "Expected an invalid metadata key to be rejected")
+
+	g.Expect(config.Load("../../config/samples/config_v1alpha1_operatorconfiguration.yaml", flagConfig())).To(Succeed(), "Expected the sample to load")
+}
+
+func TestStore(t *testing.T) {
+	g := NewWithT(t)
+
+	var nilStore *config.Store
+	g.Expect(nilStore.RequeuePeriod(config.Microvm)).To(Equal(30 * time.Second))
+	g.Expect(nilStore.MinReconcileInterval(config.Microvm)).To(Equal(30 * time.Second))
+	g.Expect(nilStore.DefaultTLSSecretRef()).To(BeNil())
+	g.Expect(nilStore.MaxConcurrentDeletes()).To(BeZero())
+	g.Expect(nilStore.TraceFlintlock()).To(BeFalse())
+	g.Expect(nilStore.FlintlockMetadata().Extra).To(BeEmpty())
+	g.Expect(nilStore.ContactTimeout()).To(BeZero())
+	g.Expect(nilStore.WatchesNamespace("ns1")).To(BeTrue())
+
+	store := config.NewStore(flagConfig())
+
+	next := flagConfig()
+	g.Expect(config.Decode([]byte(testConfig), next)).To(Succeed())
+	next.FeatureGates = nil
+	g.Expect(store.Reload(next)).To(BeFalse(), "Expected no restart to be needed for safe settings")
+	g.Expect(store.RequeuePeriod(config.Microvm)).To(Equal(10 * time.Second))
+	g.Expect(store.RequeuePeriod(config.MicrovmDeployment)).To(Equal(30*time.Second), "Expected unset periods to default")
+	g.Expect(store.MinReconcileInterval(config.Microvm)).To(Equal(5 * time.Second))
+	g.Expect(store.MinReconcileInterval(config.MicrovmDeployment)).To(Equal(30*time.Second), "Expected the minimum to default to the requeue period")
+	g.Expect(store.DefaultTLSSecretRef().Namespace).To(Equal("flintlock-system"))
+	g.Expect(store.MaxConcurrentDeletes()).To(Equal(5))
+	g.Expect(store.TraceFlintlock()).To(BeTrue())
+	g.Expect(store.FlintlockMetadata().Extra).To(HaveKeyWithValue("x-cluster", "edge-1"))
+	g.Expect(store.ContactTimeout()).To(Equal(2 * time.Minute))
+
+	next = next.DeepCopy()
+	next.FeatureGates = map[string]bool{"ExternalResourceGC": false}
+	g.Expect(store.Reload(next)).To(BeTrue(), "Expected a restart to be needed for feature gates")
+
+	next = next.DeepCopy()
+	next.Controllers.Microvm.MaxConcurrentReconciles = 20
+	g.Expect(store.Reload(next)).To(BeTrue(), "Expected a restart to be needed for concurrency")
+
+	next = next.DeepCopy()
+	next.Controllers.Microvm.MaxConcurrentReconciles = 10
+	next.Flintlock.DeleteQPS = 2
+	g.Expect(store.Reload(next)).To(BeTrue(), "Expected a restart to be needed for the delete rate")
+
+	next = next.DeepCopy()
+	next.Flintlock.DeleteQPS = 0
+	next.Deletion.ForceStuck = true
+	g.Expect(store.Reload(next)).To(BeTrue(), "Expected a restart to be needed for deletion settings")
+}
+
+func TestWatchNamespaces(t *testing.T) {
+	g := NewWithT(t)
+
+	newCache, err := config.NewCacheFunc(nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(newCache).To(BeNil(), "Expected every namespace to be watched by default")
+
+	newCache, err = config.NewCacheFunc([]string{"team-a", "team-b"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(newCache).NotTo(BeNil())
+
+	_, err = config.NewCacheFunc([]string{"team-a", "Team_B"})
+	g.Expect(err).To(MatchError(ContainSubstring(`"Team_B"`)))
+
+	cfg := flagConfig()
+	g.Expect(config.NewStore(cfg).WatchesNamespace("team-c")).To(BeTrue())
+
+	cfg.WatchNamespaces = []string{"team-a", "team-b"}
+	store := config.NewStore(cfg)
+	g.Expect(store.WatchesNamespace("team-b")).To(BeTrue())
+	g.Expect(store.WatchesNamespace("team-c")).To(BeFalse())
+
+	next := cfg.DeepCopy()
+	next.WatchNamespaces = nil
+	g.Expect(store.Reload(next)).To(BeTrue(), "Expected a restart to be needed for the watched namespaces")
+	g.Expect(store.WatchesNamespace("team-c")).To(BeFalse())
+}
+
+func TestWatcher(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	g.Expect(os.WriteFile(path, []byte("apiVersion: config.liquid-metal.io/v1alpha1\nkind: OperatorConfiguration\n"), 0o600)).To(Succeed())
+
+	store := config.NewStore(flagConfig())
+	watcher := &config.Watcher{
+		Path:     path,
+		Base:     flagConfig(),
+		Store:    store,
+		Interval: 10 * time.Millisecond,
+		Logger:   testr.New(t),
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		_ = watcher.Start(ctx)
+	}()
+
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	g.Expect(os.WriteFile(path, []byte(testConfig), 0o600)).To(Succeed())
+	g.Eventually(func() time.Duration {
+		return store.RequeuePeriod(config.Microvm)
+	}).Should(Equal(10*time.Second), "Expected the changed file to be reloaded")
+}
+
+func flagConfig() *configv1.OperatorConfiguration {
+	return &configv1.OperatorConfiguration{
+		Metrics: configv1.MetricsConfiguration{BindAddress: ":8080"},
+		Controllers: configv1.ControllersConfiguration{
+			Microvm: configv1.ControllerConfiguration{
+				MaxConcurrentReconciles: 10,
+				RequeuePeriod:           metav1.Duration{Duration: 30 * time.Second},
+			},
+		},
+		Deletion: configv1.DeletionConfiguration{
+			PendingGrace: metav1.Duration{Duration: 2 * time.Minute},
+			StuckTimeout: metav1.Duration{Duration: 10 * time.Minute},
+		},
+		CRDCheck: "enforce",
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import "errors"
+
+var (
+	errUnexpectedKind   = errors.New("not an OperatorConfiguration")
+	errInvalidNamespace = errors.New("invalid watch namespace")
+	errUnknownCRDCheck  = errors.New("unknown crd check mode")
+)
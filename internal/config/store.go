@@ -0,0 +1,203 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
+)
+
+const defaultRequeuePeriod = 30 * time.Second
+
+// Controller names a controller with its own settings.
+type Controller string
+
+const (
+	Microvm           Controller = "microvm"
+	MicrovmReplicaSet Controller = "microvmReplicaSet"
+	MicrovmDeployment Controller = "microvmDeployment"
+)
+
+// Store holds the configuration the operator is running with. Its methods are
+// safe to call on a nil Store, which returns the defaults.
+type Store struct {
+	mu  sync.RWMutex
+	cfg *configv1.OperatorConfiguration
+}
+
+// NewStore returns a Store holding cfg.
+func NewStore(cfg *configv1.OperatorConfiguration) *Store {
+	return &Store{cfg: cfg.DeepCopy()}
+}
+
+// RequeuePeriod returns how long the controller waits before checking on an
+// object which is still converging.
+func (s *Store) RequeuePeriod(controller Controller) time.Duration {
+	if s == nil {
+		return defaultRequeuePeriod
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return requeuePeriod(s.cfg, controller)
+}
+
+// MinReconcileInterval returns the shortest interval an object may ask the
+// controller to reconcile it at, which is the requeue period unless it is set.
+func (s *Store) MinReconcileInterval(controller Controller) time.Duration {
+	if s == nil {
+		return defaultRequeuePeriod
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if interval := controllerConfig(s.cfg, controller).MinReconcileInterval.Duration; interval > 0 {
+		return interval
+	}
+
+	return requeuePeriod(s.cfg, controller)
+}
+
+// DefaultTLSSecretRef returns the secret used for Microvms which do not set
+// their own, or nil if there is none.
+func (s *Store) DefaultTLSSecretRef() *corev1.SecretReference {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cfg.Flintlock.DefaultTLSSecretRef.DeepCopy()
+}
+
+// MaxConcurrentDeletes returns how many Microvms a MicrovmReplicaSet deletes
+// from each host at once, or 0 if there is no limit.
+func (s *Store) MaxConcurrentDeletes() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cfg.Flintlock.MaxConcurrentDeletes
+}
+
+// TraceFlintlock returns true if each call made to a flintlock host is logged
+// with its request and response.
+func (s *Store) TraceFlintlock() bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cfg.Logging.TraceFlintlock
+}
+
+// FlintlockMetadata returns the settings for the gRPC metadata sent with each
+// call to a flintlock host.
+func (s *Store) FlintlockMetadata() configv1.MetadataConfiguration {
+	if s == nil {
+		return configv1.MetadataConfiguration{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return *s.cfg.Flintlock.Metadata.DeepCopy()
+}
+
+// ContactTimeout returns how long calls to a host may fail to reach it before
+// new replicas are kept off it, or 0 if they never are.
+func (s *Store) ContactTimeout() time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cfg.Flintlock.ContactTimeout.Duration
+}
+
+// WatchesNamespace returns true if the operator watches the objects in
+// namespace, which it does for every namespace unless it is restricted.
+func (s *Store) WatchesNamespace(namespace string) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.cfg.WatchNamespaces) == 0 {
+		return true
+	}
+
+	for _, watched := range s.cfg.WatchNamespaces {
+		if watched == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reload applies the settings of cfg which are safe to change while the
+// operator is running: requeue periods and minimum reconcile intervals, the
+// default TLS secret, the number of concurrent deletes, flintlock tracing, the
+// metadata sent to flintlock and the host contact timeout.
+// It returns true if anything else differs, which only takes effect after a
+// restart.
+func (s *Store) Reload(cfg *configv1.OperatorConfiguration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.cfg.DeepCopy()
+	next.TypeMeta = cfg.TypeMeta
+
+	for _, controller := range []Controller{Microvm, MicrovmReplicaSet, MicrovmDeployment} {
+		controllerConfig(next, controller).RequeuePeriod = controllerConfig(cfg, controller).RequeuePeriod
+		controllerConfig(next, controller).MinReconcileInterval = controllerConfig(cfg, controller).MinReconcileInterval
+	}
+
+	next.Flintlock.DefaultTLSSecretRef = cfg.Flintlock.DefaultTLSSecretRef.DeepCopy()
+	next.Flintlock.MaxConcurrentDeletes = cfg.Flintlock.MaxConcurrentDeletes
+	next.Flintlock.Metadata = *cfg.Flintlock.Metadata.DeepCopy()
+	next.Flintlock.ContactTimeout = cfg.Flintlock.ContactTimeout
+	next.Logging.TraceFlintlock = cfg.Logging.TraceFlintlock
+
+	s.cfg = next
+
+	return !reflect.DeepEqual(next, cfg)
+}
+
+func requeuePeriod(cfg *configv1.OperatorConfiguration, controller Controller) time.Duration {
+	if period := controllerConfig(cfg, controller).RequeuePeriod.Duration; period > 0 {
+		return period
+	}
+
+	return defaultRequeuePeriod
+}
+
+func controllerConfig(cfg *configv1.OperatorConfiguration, controller Controller) *configv1.ControllerConfiguration {
+	switch controller {
+	case MicrovmReplicaSet:
+		return &cfg.Controllers.MicrovmReplicaSet
+	case MicrovmDeployment:
+		return &cfg.Controllers.MicrovmDeployment
+	default:
+		return &cfg.Controllers.Microvm
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
+)
+
+const defaultWatchInterval = 10 * time.Second
+
+// Watcher reloads the configuration file into a Store when it changes. The
+// file is polled rather than watched for events, as a mounted ConfigMap is
+// updated by swapping a symlink.
+type Watcher struct {
+	// Path is the configuration file.
+	Path string
+	// Base is the configuration from the command line which the file is read
+	// over, the same as at startup.
+	Base *configv1.OperatorConfiguration
+	// Store receives the settings which can be changed while running.
+	Store *Store
+	// Interval is how often the file is read. Defaults to 10s when zero.
+	Interval time.Duration
+	// Logger reports reloads and files which could not be read.
+	Logger logr.Logger
+
+	last []byte
+}
+
+// NeedLeaderElection returns false, as every replica needs the current
+// configuration.
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start polls the file until ctx is done.
+func (w *Watcher) Start(ctx context.Context) error {
+	interval := w.Interval
+	if interval == 0 {
+		interval = defaultWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+func (w *Watcher) poll() {
+	data, err := os.ReadFile(w.Path)
+	if err != nil {
+		w.Logger.Error(err, "failed reading operator configuration", "path", w.Path)
+
+		return
+	}
+
+	if bytes.Equal(data, w.last) {
+		return
+	}
+
+	// the first read only catches any change made since startup
+	initial := w.last == nil
+	w.last = data
+
+	cfg := w.Base.DeepCopy()
+	if err := Decode(data, cfg); err != nil {
+		w.Logger.Error(err, "ignoring invalid operator configuration", "path", w.Path)
+
+		return
+	}
+
+	restart := w.Store.Reload(cfg)
+
+	switch {
+	case initial:
+	case restart:
+		w.Logger.Info("reloaded operator configuration, some changes only take effect after a restart", "path", w.Path)
+	default:
+		w.Logger.Info("reloaded operator configuration", "path", w.Path)
+	}
+}
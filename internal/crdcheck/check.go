@@ -0,0 +1,290 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package crdcheck compares the CustomResourceDefinitions installed in the
+// cluster with the types compiled into the operator, so that a partial upgrade
+// is caught at startup rather than by the apiserver silently pruning fields.
+package crdcheck
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list
+
+// Mode is what the operator does when the installed CRDs are incompatible.
+type Mode string
+
+const (
+	// Enforce refuses to start.
+	Enforce Mode = "enforce"
+	// Warn logs the incompatibilities and starts anyway.
+	Warn Mode = "warn"
+	// Disabled skips the check.
+	Disabled Mode = "disabled"
+)
+
+// checkedFields are the top level fields of each object whose schema is
+// compared. Metadata is owned by the apiserver and is never pruned.
+var checkedFields = []string{"spec", "status"}
+
+var objectType = reflect.TypeOf((*client.Object)(nil)).Elem()
+
+// IncompatibleError lists every way in which the installed CRDs differ from
+// the types the operator expects.
+type IncompatibleError struct {
+	Problems []string
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("installed crds are incompatible with this operator: %s", strings.Join(e.Problems, "; "))
+}
+
+// Verify checks that a CRD is installed for every kind in group registered in
+// scheme, and that for every version the operator knows about:
+//   - the version is served,
+//   - the storage version is also known to the operator,
+//   - every spec and status field of the Go type is in the installed schema,
+//     so that it will not be pruned,
+//   - every field the installed schema requires is known to the operator.
+//
+// An *IncompatibleError is returned if any of these do not hold.
+func Verify(ctx context.Context, c client.Reader, scheme *runtime.Scheme, group string) error {
+	crds := &apiextensionsv1.CustomResourceDefinitionList{}
+	if err := c.List(ctx, crds); err != nil {
+		return fmt.Errorf("listing customresourcedefinitions: %w", err)
+	}
+
+	installed := map[string]*apiextensionsv1.CustomResourceDefinition{}
+
+	for i := range crds.Items {
+		crd := &crds.Items[i]
+		if crd.Spec.Group == group {
+			installed[crd.Spec.Names.Kind] = crd
+		}
+	}
+
+	problems := []string{}
+
+	for kind, versions := range expectedKinds(scheme, group) {
+		crd, ok := installed[kind]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("no crd installed for %s", kind))
+
+			continue
+		}
+
+		problems = append(problems, verifyCRD(crd, versions)...)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+
+	return &IncompatibleError{Problems: problems}
+}
+
+// expectedKinds returns the Go type of each version of each kind in group
+// registered in scheme, leaving out lists and the shared option types.
+func expectedKinds(scheme *runtime.Scheme, group string) map[string]map[string]reflect.Type {
+	kinds := map[string]map[string]reflect.Type{}
+
+	for _, gv := range scheme.PrioritizedVersionsForGroup(group) {
+		for kind, t := range scheme.KnownTypes(gv) {
+			if strings.HasSuffix(kind, "List") || !reflect.PointerTo(t).Implements(objectType) {
+				continue
+			}
+
+			if kinds[kind] == nil {
+				kinds[kind] = map[string]reflect.Type{}
+			}
+
+			kinds[kind][gv.Version] = t
+		}
+	}
+
+	return kinds
+}
+
+func verifyCRD(crd *apiextensionsv1.CustomResourceDefinition, versions map[string]reflect.Type) []string {
+	problems := []string{}
+	kind := crd.Spec.Names.Kind
+
+	for i := range crd.Spec.Versions {
+		v := crd.Spec.Versions[i]
+		if v.Storage {
+			if _, ok := versions[v.Name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s is stored as %s, which this operator does not know", kind, v.Name))
+			}
+		}
+	}
+
+	for version, t := range versions {
+		v := servedVersion(crd, version)
+		if v == nil {
+			problems = append(problems, fmt.Sprintf("%s %s is not served", kind, version))
+
+			continue
+		}
+
+		if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+
+		for _, field := range checkedFields {
+			sf, ok := jsonField(t, field)
+			if !ok {
+				continue
+			}
+
+			prop, ok := v.Schema.OpenAPIV3Schema.Properties[field]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s %s: field %s is missing", kind, version, field))
+
+				continue
+			}
+
+			problems = append(problems, compare(kind+" "+version, field, sf.Type, &prop)...)
+		}
+	}
+
+	return problems
+}
+
+func servedVersion(crd *apiextensionsv1.CustomResourceDefinition, version string) *apiextensionsv1.CustomResourceDefinitionVersion {
+	for i := range crd.Spec.Versions {
+		if v := &crd.Spec.Versions[i]; v.Name == version && v.Served {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// compare walks t alongside its installed schema. Schemas without properties,
+// such as those for times, quantities or free-form objects, are not descended
+// into.
+func compare(prefix, path string, t reflect.Type, props *apiextensionsv1.JSONSchemaProps) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if props.XPreserveUnknownFields != nil && *props.XPreserveUnknownFields {
+		return nil
+	}
+
+	switch t.Kind() { //nolint: exhaustive // only containers are walked
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 || props.Items == nil || props.Items.Schema == nil {
+			return nil
+		}
+
+		return compare(prefix, path+"[]", t.Elem(), props.Items.Schema)
+	case reflect.Map:
+		if props.AdditionalProperties == nil || props.AdditionalProperties.Schema == nil {
+			return nil
+		}
+
+		return compare(prefix, path+"{}", t.Elem(), props.AdditionalProperties.Schema)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	if len(props.Properties) == 0 {
+		return nil
+	}
+
+	problems := []string{}
+	known := map[string]bool{}
+
+	for _, sf := range jsonFields(t) {
+		name := jsonName(sf)
+		known[name] = true
+
+		child, ok := props.Properties[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: field %s.%s is missing and would be pruned", prefix, path, name))
+
+			continue
+		}
+
+		problems = append(problems, compare(prefix, path+"."+name, sf.Type, &child)...)
+	}
+
+	for _, required := range props.Required {
+		if !known[required] {
+			problems = append(problems, fmt.Sprintf("%s: required field %s.%s is unknown to this operator", prefix, path, required))
+		}
+	}
+
+	return problems
+}
+
+// jsonFields returns the serialised fields of t, flattening inlined and
+// embedded structs.
+func jsonFields(t reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		if strings.Contains(tag, ",inline") || (sf.Anonymous && tag == "") {
+			ft := sf.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(ft)...)
+
+				continue
+			}
+		}
+
+		fields = append(fields, sf)
+	}
+
+	return fields
+}
+
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	for _, sf := range jsonFields(t) {
+		if jsonName(sf) == name {
+			return sf, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+
+	return name
+}
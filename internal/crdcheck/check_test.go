@@ -0,0 +1,126 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package crdcheck_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	infrav1alpha2 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha2"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/crdcheck"
+)
+
+const crdDir = "../../config/crd/bases"
+
+func TestVerify(t *testing.T) {
+	tt := []struct {
+		name     string
+		mutate   func(crds map[string]*apiextensionsv1.CustomResourceDefinition)
+		problems []string
+	}{
+		{
+			name:   "generated crds are compatible",
+			mutate: func(map[string]*apiextensionsv1.CustomResourceDefinition) {},
+		},
+		{
+			name: "missing crd",
+			mutate: func(crds map[string]*apiextensionsv1.CustomResourceDefinition) {
+				delete(crds, "MicrovmTemplate")
+			},
+			problems: []string{"no crd installed for MicrovmTemplate"},
+		},
+		{
+			name: "field missing from an older crd",
+			mutate: func(crds map[string]*apiextensionsv1.CustomResourceDefinition) {
+				spec := crds["MicrovmHost"].Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+				delete(spec.Properties, "unschedulable")
+				crds["MicrovmHost"].Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"] = spec
+			},
+			problems: []string{"MicrovmHost v1alpha1: field spec.unschedulable is missing and would be pruned"},
+		},
+		{
+			name: "required field unknown to the operator",
+			mutate: func(crds map[string]*apiextensionsv1.CustomResourceDefinition) {
+				spec := crds["MicrovmHost"].Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+				spec.Properties["pool"] = apiextensionsv1.JSONSchemaProps{Type: "string"}
+				spec.Required = append(spec.Required, "pool")
+				crds["MicrovmHost"].Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"] = spec
+			},
+			problems: []string{"MicrovmHost v1alpha1: required field spec.pool is unknown to this operator"},
+		},
+		{
+			name: "version not served and stored in an unknown version",
+			mutate: func(crds map[string]*apiextensionsv1.CustomResourceDefinition) {
+				crds["MicrovmHost"].Spec.Versions[0].Storage = false
+				crds["MicrovmHost"].Spec.Versions[0].Served = false
+				crds["MicrovmHost"].Spec.Versions = append(crds["MicrovmHost"].Spec.Versions,
+					apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta1", Served: true, Storage: true})
+			},
+			problems: []string{
+				"MicrovmHost is stored as v1beta1, which this operator does not know",
+				"MicrovmHost v1alpha1 is not served",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			crds := loadCRDs(g)
+			tc.mutate(crds)
+
+			scheme := runtime.NewScheme()
+			g.Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(infrav1alpha2.AddToScheme(scheme)).To(Succeed())
+
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			for _, crd := range crds {
+				builder = builder.WithObjects(crd)
+			}
+
+			err := crdcheck.Verify(context.TODO(), builder.Build(), scheme, infrav1.GroupVersion.Group)
+			if tc.problems == nil {
+				g.Expect(err).NotTo(HaveOccurred())
+
+				return
+			}
+
+			incompatible := &crdcheck.IncompatibleError{}
+			g.Expect(errors.As(err, &incompatible)).To(BeTrue())
+			g.Expect(incompatible.Problems).To(ConsistOf(tc.problems))
+		})
+	}
+}
+
+func loadCRDs(g *WithT) map[string]*apiextensionsv1.CustomResourceDefinition {
+	files, err := filepath.Glob(filepath.Join(crdDir, "*.yaml"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(files).NotTo(BeEmpty())
+
+	crds := map[string]*apiextensionsv1.CustomResourceDefinition{}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		g.Expect(yaml.Unmarshal(data, crd)).To(Succeed())
+
+		crds[crd.Spec.Names.Kind] = crd
+	}
+
+	return crds
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package credentials gives the Microvms of a namespace the secrets they
+// connect to their host with when they do not name their own, so that the
+// credentials of the hosts are set once on the namespace rather than in every
+// Microvm and template.
+package credentials
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/mutate-infrastructure-liquid-metal-io-v1alpha1-microvm,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.liquid-metal.io,resources=microvms,verbs=create;update,versions=v1alpha1,name=mmicrovmcredentials.infrastructure.liquid-metal.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// Defaulter sets the TLSSecretRef and BasicAuthSecret of Microvms which leave
+// them empty to the secrets named by the DefaultTLSSecretAnnotation and
+// DefaultBasicAuthSecretAnnotation of their namespace. Microvms which name
+// their own secrets keep them.
+type Defaulter struct {
+	Client client.Client
+}
+
+var _ admission.CustomDefaulter = &Defaulter{}
+
+// SetupWebhookWithManager registers the defaulter as a webhook for Microvms.
+func (d *Defaulter) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.Microvm{}).
+		WithDefaulter(d).
+		Complete()
+}
+
+// Default fills in the secrets the Microvm leaves empty from its namespace.
+func (d *Defaulter) Default(ctx context.Context, obj runtime.Object) error {
+	mvm, ok := obj.(*infrav1.Microvm)
+	if !ok {
+		return fmt.Errorf("expected a microvm but got %T", obj)
+	}
+
+	if mvm.Spec.TLSSecretRef != "" && mvm.Spec.BasicAuthSecret != "" {
+		return nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := d.Client.Get(ctx, client.ObjectKey{Name: mvm.Namespace}, ns); err != nil {
+		return fmt.Errorf("getting namespace %s: %w", mvm.Namespace, err)
+	}
+
+	if mvm.Spec.TLSSecretRef == "" {
+		mvm.Spec.TLSSecretRef = ns.Annotations[infrav1.DefaultTLSSecretAnnotation]
+	}
+
+	if mvm.Spec.BasicAuthSecret == "" {
+		mvm.Spec.BasicAuthSecret = ns.Annotations[infrav1.DefaultBasicAuthSecretAnnotation]
+	}
+
+	return nil
+}
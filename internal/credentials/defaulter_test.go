@@ -0,0 +1,103 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package credentials_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/credentials"
+)
+
+func newDefaulter(g *WithT, objects ...runtime.Object) *credentials.Defaulter {
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	return &credentials.Defaulter{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build(),
+	}
+}
+
+func newNamespace(annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ns1", Annotations: annotations},
+	}
+}
+
+func newMicrovm(tlsSecret, basicAuthSecret string) *infrav1.Microvm {
+	return &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1"},
+		Spec: infrav1.MicrovmSpec{
+			TLSSecretRef:    tlsSecret,
+			BasicAuthSecret: basicAuthSecret,
+		},
+	}
+}
+
+func TestDefaulter_Default(t *testing.T) {
+	tt := []struct {
+		name              string
+		annotations       map[string]string
+		tlsSecret         string
+		basicAuthSecret   string
+		expectedTLS       string
+		expectedBasicAuth string
+	}{
+		{
+			name: "namespace defaults are set",
+			annotations: map[string]string{
+				infrav1.DefaultTLSSecretAnnotation:       "host-tls",
+				infrav1.DefaultBasicAuthSecretAnnotation: "host-auth",
+			},
+			expectedTLS:       "host-tls",
+			expectedBasicAuth: "host-auth",
+		},
+		{
+			name: "own secrets are kept",
+			annotations: map[string]string{
+				infrav1.DefaultTLSSecretAnnotation:       "host-tls",
+				infrav1.DefaultBasicAuthSecretAnnotation: "host-auth",
+			},
+			tlsSecret:         "my-tls",
+			expectedTLS:       "my-tls",
+			expectedBasicAuth: "host-auth",
+		},
+		{
+			name:              "namespace without defaults",
+			basicAuthSecret:   "my-auth",
+			expectedBasicAuth: "my-auth",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			defaulter := newDefaulter(g, newNamespace(tc.annotations))
+			mvm := newMicrovm(tc.tlsSecret, tc.basicAuthSecret)
+
+			g.Expect(defaulter.Default(context.TODO(), mvm)).To(Succeed())
+			g.Expect(mvm.Spec.TLSSecretRef).To(Equal(tc.expectedTLS))
+			g.Expect(mvm.Spec.BasicAuthSecret).To(Equal(tc.expectedBasicAuth))
+		})
+	}
+}
+
+func TestDefaulter_Default_MissingNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	defaulter := newDefaulter(g)
+
+	g.Expect(defaulter.Default(context.TODO(), newMicrovm("", ""))).NotTo(Succeed())
+	g.Expect(defaulter.Default(context.TODO(), newMicrovm("my-tls", "my-auth"))).To(Succeed(),
+		"Expected a microvm naming both secrets not to need its namespace")
+}
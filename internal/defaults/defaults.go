@@ -6,3 +6,6 @@ package defaults
 const (
 	ManagerName = "microvm-manager"
 )
+
+// Version is the version of the operator. It is set when the binary is built.
+var Version = "dev"
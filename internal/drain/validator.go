@@ -0,0 +1,135 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package drain refuses to remove a host from a MicrovmDeployment while its
+// microvms are still running there, as the replicaset on the host would be
+// deleted straight away rather than drained.
+package drain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/validate-infrastructure-liquid-metal-io-v1alpha1-microvmdeployment,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.liquid-metal.io,resources=microvmdeployments,verbs=update,versions=v1alpha1,name=vmicrovmdeploymentdrain.infrastructure.liquid-metal.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmreplicasets,verbs=get;list;watch
+
+// Validator refuses updates which remove a host from the Hosts of a
+// MicrovmDeployment while the replicaset there still has microvms. A host is
+// drained by cordoning its MicrovmHost, after which the deployment removes the
+// replicaset once the replicas are ready elsewhere. The check can be skipped
+// with the ForceHostRemovalAnnotation. Hosts leaving a MicrovmHostGroup are not
+// checked.
+type Validator struct {
+	Client client.Client
+}
+
+var _ admission.CustomValidator = &Validator{}
+
+// SetupWebhookWithManager registers the validator as a webhook for MicrovmDeployments.
+func (v *Validator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.MicrovmDeployment{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate always allows a MicrovmDeployment to be created.
+func (v *Validator) ValidateCreate(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+// ValidateUpdate refuses a MicrovmDeployment which no longer lists a host that
+// still has microvms of the deployment running on it.
+func (v *Validator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldMvmD, ok := oldObj.(*infrav1.MicrovmDeployment)
+	if !ok {
+		return fmt.Errorf("expected a microvmdeployment but got %T", oldObj)
+	}
+
+	mvmD, ok := newObj.(*infrav1.MicrovmDeployment)
+	if !ok {
+		return fmt.Errorf("expected a microvmdeployment but got %T", newObj)
+	}
+
+	if !mvmD.DeletionTimestamp.IsZero() || mvmD.Annotations[infrav1.ForceHostRemovalAnnotation] == "true" {
+		return nil
+	}
+
+	if !usesHostList(oldMvmD) || !usesHostList(mvmD) {
+		return nil
+	}
+
+	removed := removedHosts(oldMvmD, mvmD)
+	if len(removed) == 0 {
+		return nil
+	}
+
+	sets := &infrav1.MicrovmReplicaSetList{}
+	if err := v.Client.List(ctx, sets, client.InNamespace(mvmD.Namespace)); err != nil {
+		return fmt.Errorf("listing microvmreplicasets: %w", err)
+	}
+
+	running := []string{}
+
+	for i := range sets.Items {
+		rs := &sets.Items[i]
+
+		if !metav1.IsControlledBy(rs, mvmD) || !removed[rs.Spec.Host.Endpoint] || rs.Status.Replicas == 0 {
+			continue
+		}
+
+		running = append(running, fmt.Sprintf("%s has %d", rs.Spec.Host.Endpoint, rs.Status.Replicas))
+	}
+
+	if len(running) == 0 {
+		return nil
+	}
+
+	sort.Strings(running)
+
+	return apierrors.NewForbidden(
+		infrav1.GroupVersion.WithResource("microvmdeployments").GroupResource(),
+		mvmD.Name,
+		fmt.Errorf("removed hosts still have microvms (%s): cordon the microvmhost to drain it first, "+
+			"or set the %s annotation to \"true\"", strings.Join(running, ", "), infrav1.ForceHostRemovalAnnotation),
+	)
+}
+
+// ValidateDelete always allows a MicrovmDeployment to be deleted.
+func (v *Validator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+// usesHostList returns true if the hosts of the deployment are those listed on
+// its spec, rather than those of a host group or host selector.
+func usesHostList(mvmD *infrav1.MicrovmDeployment) bool {
+	return mvmD.Spec.HostGroupRef == nil && mvmD.Spec.HostSelector == nil
+}
+
+// removedHosts returns the endpoints of the hosts listed on the old deployment
+// but not the new one.
+func removedHosts(oldMvmD, mvmD *infrav1.MicrovmDeployment) map[string]bool {
+	removed := map[string]bool{}
+
+	for _, host := range oldMvmD.Spec.Hosts {
+		removed[host.Endpoint] = true
+	}
+
+	for _, host := range mvmD.Spec.Hosts {
+		delete(removed, host.Endpoint)
+	}
+
+	return removed
+}
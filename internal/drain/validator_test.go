@@ -0,0 +1,92 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package drain_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/drain"
+)
+
+func newValidator(g *WithT, objects ...runtime.Object) *drain.Validator {
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	return &drain.Validator{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build(),
+	}
+}
+
+func newDeployment(endpoints ...string) *infrav1.MicrovmDeployment {
+	mvmD := &infrav1.MicrovmDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvmd1", Namespace: "ns1", UID: "mvmd1-uid"},
+	}
+
+	for _, endpoint := range endpoints {
+		mvmD.Spec.Hosts = append(mvmD.Spec.Hosts, microvm.Host{Endpoint: endpoint})
+	}
+
+	return mvmD
+}
+
+func newReplicaSet(name, endpoint string, replicas int32) *infrav1.MicrovmReplicaSet {
+	return &infrav1.MicrovmReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "ns1",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: infrav1.GroupVersion.String(),
+				Kind:       "MicrovmDeployment",
+				Name:       "mvmd1",
+				UID:        "mvmd1-uid",
+				Controller: pointer.Bool(true),
+			}},
+		},
+		Spec:   infrav1.MicrovmReplicaSetSpec{Host: microvm.Host{Endpoint: endpoint}},
+		Status: infrav1.MicrovmReplicaSetStatus{Replicas: replicas},
+	}
+}
+
+func TestValidator_ValidateUpdate(t *testing.T) {
+	g := NewWithT(t)
+
+	existing := newDeployment("host1:9090", "host2:9090")
+	validator := newValidator(g, existing,
+		newReplicaSet("rs1", "host1:9090", 2),
+		newReplicaSet("rs2", "host2:9090", 0),
+	)
+
+	g.Expect(validator.ValidateUpdate(context.TODO(), existing, newDeployment("host1:9090", "host2:9090", "host3:9090"))).
+		To(Succeed(), "Expected adding a host to be allowed")
+	g.Expect(validator.ValidateUpdate(context.TODO(), existing, newDeployment("host1:9090"))).
+		To(Succeed(), "Expected removing a drained host to be allowed")
+
+	err := validator.ValidateUpdate(context.TODO(), existing, newDeployment("host2:9090"))
+	g.Expect(apierrors.IsForbidden(err)).To(BeTrue(), "Expected removing a host with microvms to be refused")
+	g.Expect(err.Error()).To(ContainSubstring("host1:9090 has 2"))
+
+	forced := newDeployment("host2:9090")
+	forced.Annotations = map[string]string{infrav1.ForceHostRemovalAnnotation: "true"}
+	g.Expect(validator.ValidateUpdate(context.TODO(), existing, forced)).To(Succeed())
+
+	// replicasets of other deployments on the host do not count
+	other := newDeployment("host1:9090", "host2:9090")
+	other.UID = "mvmd2-uid"
+	otherUpdated := newDeployment("host2:9090")
+	otherUpdated.UID = "mvmd2-uid"
+	g.Expect(validator.ValidateUpdate(context.TODO(), other, otherUpdated)).To(Succeed())
+
+	g.Expect(validator.ValidateCreate(context.TODO(), existing)).To(Succeed())
+	g.Expect(validator.ValidateDelete(context.TODO(), existing)).To(Succeed())
+}
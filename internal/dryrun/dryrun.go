@@ -0,0 +1,144 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package dryrun lets reconcilers work out what they would do to an object
+// without doing it, so that the effect of a change can be previewed. The
+// actions are recorded in the status of the object instead.
+package dryrun
+
+import (
+	"context"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// MaxActions is how many actions are kept in the status of an object.
+const MaxActions = 50
+
+// Enabled returns true if obj is to be reconciled in dry-run mode, either
+// because it asks for it or because the operator runs in it.
+func Enabled(obj metav1.Object, operator bool) bool {
+	return operator || obj.GetAnnotations()[infrav1.DryRunAnnotation] == "true"
+}
+
+// Status returns the status recording actions planned for generation, with
+// those beyond MaxActions counted rather than listed.
+func Status(generation int64, actions []infrav1.PlannedAction) *infrav1.DryRunStatus {
+	status := &infrav1.DryRunStatus{ObservedGeneration: generation}
+
+	if len(actions) > MaxActions {
+		status.Omitted = int32(len(actions) - MaxActions)
+		actions = actions[:MaxActions]
+	}
+
+	status.Actions = actions
+
+	return status
+}
+
+// Client reads through the client it wraps, and records every write instead
+// of making it. It is safe for concurrent use.
+type Client struct {
+	client.Client
+
+	mu      sync.Mutex
+	actions []infrav1.PlannedAction
+}
+
+// NewClient returns a Client which reads through c.
+func NewClient(c client.Client) *Client {
+	return &Client{Client: c}
+}
+
+// Actions returns the writes recorded so far, in the order they were made.
+func (c *Client) Actions() []infrav1.PlannedAction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]infrav1.PlannedAction(nil), c.actions...)
+}
+
+// Create records that obj would have been created.
+func (c *Client) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	c.record("Create", obj)
+
+	return nil
+}
+
+// Delete records that obj would have been deleted.
+func (c *Client) Delete(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	c.record("Delete", obj)
+
+	return nil
+}
+
+// Update records that obj would have been updated.
+func (c *Client) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	c.record("Update", obj)
+
+	return nil
+}
+
+// Patch records that obj would have been patched.
+func (c *Client) Patch(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+	c.record("Patch", obj)
+
+	return nil
+}
+
+// DeleteAllOf records that the objects of the kind of obj would have been
+// deleted.
+func (c *Client) DeleteAllOf(_ context.Context, obj client.Object, _ ...client.DeleteAllOfOption) error {
+	c.record("DeleteAllOf", obj)
+
+	return nil
+}
+
+// Status returns a writer which records the writes to the status of objects.
+func (c *Client) Status() client.StatusWriter {
+	return &statusWriter{client: c}
+}
+
+type statusWriter struct {
+	client *Client
+}
+
+func (w *statusWriter) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	w.client.record("UpdateStatus", obj)
+
+	return nil
+}
+
+func (w *statusWriter) Patch(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+	w.client.record("PatchStatus", obj)
+
+	return nil
+}
+
+func (c *Client) record(verb string, obj client.Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.actions = append(c.actions, infrav1.PlannedAction{Verb: verb, Target: c.target(obj)})
+}
+
+// target names obj as Kind/name, or Kind/generateName* for an object the API
+// server would have named.
+func (c *Client) target(obj client.Object) string {
+	kind := "Object"
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+
+	name := obj.GetName()
+	if name == "" {
+		name = obj.GetGenerateName() + "*"
+	}
+
+	return kind + "/" + name
+}
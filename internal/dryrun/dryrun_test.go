@@ -0,0 +1,68 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package dryrun_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/dryrun"
+)
+
+func TestEnabled(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := &infrav1.Microvm{}
+	g.Expect(dryrun.Enabled(mvm, false)).To(BeFalse())
+	g.Expect(dryrun.Enabled(mvm, true)).To(BeTrue(), "Expected every object to be in dry-run mode with the operator")
+
+	mvm.Annotations = map[string]string{infrav1.DryRunAnnotation: "true"}
+	g.Expect(dryrun.Enabled(mvm, false)).To(BeTrue())
+}
+
+func TestClient(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	existing := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "mvm1"}}
+	c := dryrun.NewClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build())
+
+	created := &infrav1.MicrovmReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", GenerateName: "microvmreplicaset-"}}
+	g.Expect(c.Create(context.TODO(), created)).To(Succeed())
+	g.Expect(c.Delete(context.TODO(), existing)).To(Succeed())
+	g.Expect(c.Status().Update(context.TODO(), existing)).To(Succeed())
+
+	g.Expect(c.Actions()).To(Equal([]infrav1.PlannedAction{
+		{Verb: "Create", Target: "MicrovmReplicaSet/microvmreplicaset-*"},
+		{Verb: "Delete", Target: "Microvm/mvm1"},
+		{Verb: "UpdateStatus", Target: "Microvm/mvm1"},
+	}))
+
+	read := &infrav1.Microvm{}
+	g.Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(existing), read)).To(Succeed(), "Expected the delete not to be made")
+
+	sets := &infrav1.MicrovmReplicaSetList{}
+	g.Expect(c.List(context.TODO(), sets)).To(Succeed())
+	g.Expect(sets.Items).To(BeEmpty(), "Expected the create not to be made")
+}
+
+func TestStatus(t *testing.T) {
+	g := NewWithT(t)
+
+	actions := make([]infrav1.PlannedAction, dryrun.MaxActions+5)
+
+	status := dryrun.Status(3, actions)
+	g.Expect(status.ObservedGeneration).To(BeEquivalentTo(3))
+	g.Expect(status.Actions).To(HaveLen(dryrun.MaxActions))
+	g.Expect(status.Omitted).To(BeEquivalentTo(5))
+}
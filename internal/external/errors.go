@@ -0,0 +1,11 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package external
+
+import "errors"
+
+var (
+	errUnknownKind = errors.New("no releaser registered for kind")
+	errNotOwned    = errors.New("resource belongs to a different microvm")
+)
@@ -0,0 +1,87 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package external releases the resources outside flintlock which were created
+// for a Microvm, such as Services, DNS records and IP allocations.
+package external
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// Releaser releases resources of one kind which were created for a Microvm.
+// Releasing a resource which no longer exists must succeed, as a release may be
+// retried after it has partly succeeded.
+type Releaser interface {
+	Release(ctx context.Context, mvm *infrav1.Microvm, ref infrav1.ExternalResourceRef) error
+}
+
+// Registry releases the external resources of Microvms with the Releaser
+// registered for their kind.
+type Registry struct {
+	releasers map[string]Releaser
+}
+
+// NewRegistry returns a Registry with no kinds registered.
+func NewRegistry() *Registry {
+	return &Registry{releasers: map[string]Releaser{}}
+}
+
+// NewDefaultRegistry returns a Registry with a Releaser registered for every
+// kind of resource the operator tracks for Microvms, so that none of them is
+// held forever for want of one.
+func NewDefaultRegistry(c client.Client) *Registry {
+	registry := NewRegistry()
+	registry.Register(KindService, &Services{Client: c})
+	registry.Register(KindMACPool, &MACPools{Client: c})
+	registry.Register(KindIPPool, &IPPools{Client: c})
+
+	return registry
+}
+
+// Register sets the Releaser for resources of kind.
+func (r *Registry) Register(kind string, releaser Releaser) {
+	r.releasers[kind] = releaser
+}
+
+// Release releases every external resource tracked by the Microvm. It returns
+// the resources which are still held, along with the first error, so that
+// those which were released are not tried again.
+func (r *Registry) Release(ctx context.Context, mvm *infrav1.Microvm) ([]infrav1.ExternalResourceRef, error) {
+	held := []infrav1.ExternalResourceRef{}
+
+	var firstErr error
+
+	for _, ref := range mvm.Status.ExternalResources {
+		err := r.release(ctx, mvm, ref)
+		if err == nil {
+			continue
+		}
+
+		held = append(held, ref)
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return held, firstErr
+}
+
+func (r *Registry) release(ctx context.Context, mvm *infrav1.Microvm, ref infrav1.ExternalResourceRef) error {
+	releaser, ok := r.releasers[ref.Kind]
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownKind, ref.Kind)
+	}
+
+	if err := releaser.Release(ctx, mvm, ref); err != nil {
+		return fmt.Errorf("releasing %s %s: %w", ref.Kind, ref.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package external_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/external"
+)
+
+func TestRegistry_Release(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	mvm := &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1", UID: "uid-1"},
+		Status: infrav1.MicrovmStatus{
+			ExternalResources: []infrav1.ExternalResourceRef{
+				{Kind: external.KindService, Name: "owned"},
+				{Kind: external.KindService, Name: "gone"},
+				{Kind: external.KindService, Name: "other"},
+				{Kind: "DNSRecord", Name: "mvm1.example.com"},
+			},
+		},
+	}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newService("owned", "uid-1"),
+		newService("other", "uid-2"),
+	).Build()
+
+	registry := external.NewRegistry()
+	registry.Register(external.KindService, &external.Services{Client: client})
+
+	held, err := registry.Release(context.TODO(), mvm)
+	g.Expect(err).To(HaveOccurred(), "Expected an error for the resources which were not released")
+	g.Expect(held).To(ConsistOf(
+		infrav1.ExternalResourceRef{Kind: external.KindService, Name: "other"},
+		infrav1.ExternalResourceRef{Kind: "DNSRecord", Name: "mvm1.example.com"},
+	), "Expected a service of another microvm and an unknown kind to be held")
+
+	err = client.Get(context.TODO(), clientKey("owned"), &corev1.Service{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected the owned service to be deleted")
+
+	err = client.Get(context.TODO(), clientKey("other"), &corev1.Service{})
+	g.Expect(err).NotTo(HaveOccurred(), "Expected the service of another microvm to be kept")
+}
+
+func TestNewDefaultRegistry(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	mvm := &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1", UID: "uid-1"},
+		Status: infrav1.MicrovmStatus{
+			ExternalResources: []infrav1.ExternalResourceRef{
+				{Kind: external.KindService, Name: "owned"},
+			},
+		},
+	}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newService("owned", "uid-1")).Build()
+
+	held, err := external.NewDefaultRegistry(client).Release(context.TODO(), mvm)
+	g.Expect(err).NotTo(HaveOccurred(), "Expected every tracked kind to have a releaser")
+	g.Expect(held).To(BeEmpty())
+}
+
+func TestMACPools_Release(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	mvm := &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1", UID: "uid-1"},
+	}
+
+	pool := &infrav1.MicrovmMACPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "pool1", Namespace: "ns1"},
+		Spec: infrav1.MicrovmMACPoolSpec{
+			Addresses: []string{"02:00:00:00:00:01", "02:00:00:00:00:02"},
+			Allocations: []infrav1.MACAllocation{
+				{Address: "02:00:00:00:00:01", Microvm: "mvm1", UID: "uid-1", Interface: "eth0"},
+				{Address: "02:00:00:00:00:02", Microvm: "mvm2", UID: "uid-2", Interface: "eth0"},
+			},
+		},
+	}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pool).Build()
+	releaser := &external.MACPools{Client: client}
+
+	g.Expect(releaser.Release(context.TODO(), mvm, infrav1.ExternalResourceRef{Kind: external.KindMACPool, Name: "pool1"})).To(Succeed())
+
+	released := &infrav1.MicrovmMACPool{}
+	g.Expect(client.Get(context.TODO(), clientKey("pool1"), released)).To(Succeed())
+	g.Expect(released.Spec.Allocations).To(HaveLen(1), "Expected only the address of the microvm to be released")
+	g.Expect(released.Spec.Allocations[0].Microvm).To(Equal("mvm2"))
+
+	g.Expect(releaser.Release(context.TODO(), mvm, infrav1.ExternalResourceRef{Kind: external.KindMACPool, Name: "pool1"})).
+		To(Succeed(), "Expected a release to be repeatable")
+	g.Expect(releaser.Release(context.TODO(), mvm, infrav1.ExternalResourceRef{Kind: external.KindMACPool, Name: "gone"})).
+		To(Succeed(), "Expected a missing pool to have nothing left to release")
+}
+
+func TestIPPools_Release(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	mvm := &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1", UID: "uid-1"},
+	}
+
+	pool := &infrav1.MicrovmIPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "pool1", Namespace: "ns1"},
+		Spec: infrav1.MicrovmIPPoolSpec{
+			Subnet: "10.0.0.0/24",
+			Allocations: []infrav1.IPAllocation{
+				{Address: "10.0.0.2", Microvm: "mvm1", UID: "uid-1", Interface: "eth0"},
+				{Address: "10.0.0.3", Microvm: "mvm1", UID: "uid-1", Interface: "eth1"},
+				{Address: "10.0.0.4", Microvm: "mvm2", UID: "uid-2", Interface: "eth0"},
+			},
+		},
+	}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pool).Build()
+	releaser := &external.IPPools{Client: client}
+
+	g.Expect(releaser.Release(context.TODO(), mvm, infrav1.ExternalResourceRef{Kind: external.KindIPPool, Name: "pool1"})).To(Succeed())
+
+	released := &infrav1.MicrovmIPPool{}
+	g.Expect(client.Get(context.TODO(), clientKey("pool1"), released)).To(Succeed())
+	g.Expect(released.Spec.Allocations).To(HaveLen(1), "Expected every address of the microvm to be released")
+	g.Expect(released.Spec.Allocations[0].Microvm).To(Equal("mvm2"))
+
+	g.Expect(releaser.Release(context.TODO(), mvm, infrav1.ExternalResourceRef{Kind: external.KindIPPool, Name: "gone"})).
+		To(Succeed(), "Expected a missing pool to have nothing left to release")
+}
+
+func newService(name, uid string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "ns1",
+			Labels:    map[string]string{infrav1.MicrovmUIDLabel: uid},
+		},
+	}
+}
+
+func clientKey(name string) client.ObjectKey {
+	return client.ObjectKey{Namespace: "ns1", Name: name}
+}
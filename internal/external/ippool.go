@@ -0,0 +1,51 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package external
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/ipam"
+)
+
+// KindIPPool is the kind of MicrovmIPPools a Microvm was given IP addresses
+// from.
+const KindIPPool = "MicrovmIPPool"
+
+// IPPools returns the IP addresses given to a Microvm to the MicrovmIPPool in
+// its namespace they were allocated from.
+type IPPools struct {
+	Client client.Client
+}
+
+// Release removes the allocations of the Microvm from the pool. The pool is
+// updated with optimistic concurrency, so that no allocation made since it
+// was read is lost.
+func (p *IPPools) Release(ctx context.Context, mvm *infrav1.Microvm, ref infrav1.ExternalResourceRef) error {
+	key := client.ObjectKey{Namespace: mvm.Namespace, Name: ref.Name}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pool := &infrav1.MicrovmIPPool{}
+		if err := p.Client.Get(ctx, key, pool); err != nil {
+			return err
+		}
+
+		if !ipam.Release(pool, mvm.UID) {
+			return nil
+		}
+
+		return p.Client.Update(ctx, pool)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("releasing ip addresses: %w", err)
+	}
+
+	return nil
+}
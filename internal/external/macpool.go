@@ -0,0 +1,51 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package external
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/macpool"
+)
+
+// KindMACPool is the kind of MicrovmMACPools a Microvm was given MAC
+// addresses from.
+const KindMACPool = "MicrovmMACPool"
+
+// MACPools returns the MAC addresses given to a Microvm to the MicrovmMACPool
+// in its namespace they were allocated from.
+type MACPools struct {
+	Client client.Client
+}
+
+// Release removes the allocations of the Microvm from the pool. The pool is
+// updated with optimistic concurrency, so that no allocation made since it
+// was read is lost.
+func (p *MACPools) Release(ctx context.Context, mvm *infrav1.Microvm, ref infrav1.ExternalResourceRef) error {
+	key := client.ObjectKey{Namespace: mvm.Namespace, Name: ref.Name}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pool := &infrav1.MicrovmMACPool{}
+		if err := p.Client.Get(ctx, key, pool); err != nil {
+			return err
+		}
+
+		if !macpool.Release(pool, mvm.UID) {
+			return nil
+		}
+
+		return p.Client.Update(ctx, pool)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("releasing mac addresses: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package external
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// KindService is the kind of Services created for a Microvm.
+const KindService = "Service"
+
+// Services releases Services created for a Microvm, which live in its namespace
+// and are labelled with its UID.
+type Services struct {
+	Client client.Client
+}
+
+// Release deletes the Service, unless it is labelled as belonging to another
+// Microvm.
+func (s *Services) Release(ctx context.Context, mvm *infrav1.Microvm, ref infrav1.ExternalResourceRef) error {
+	svc := &corev1.Service{}
+
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: mvm.Namespace, Name: ref.Name}, svc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("getting service: %w", err)
+	}
+
+	if svc.Labels[infrav1.MicrovmUIDLabel] != string(mvm.UID) {
+		return errNotOwned
+	}
+
+	if err := s.Client.Delete(ctx, svc); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("deleting service: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package failure classifies why flintlock failed a Microvm. Flintlock does not
+// report a failure reason itself (flintlock #299), so the reason is worked out
+// from which parts of the VM it had prepared before giving up.
+package failure
+
+import (
+	"fmt"
+	"strings"
+
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+)
+
+// Reason is a class of failure, suitable for alerts to key off.
+type Reason string
+
+const (
+	// VolumeMountFailed means a volume of the Microvm was not mounted.
+	VolumeMountFailed Reason = "VolumeMountFailed"
+	// KernelMountFailed means the kernel of the Microvm was not mounted.
+	KernelMountFailed Reason = "KernelMountFailed"
+	// InitrdMountFailed means the initrd of the Microvm was not mounted.
+	InitrdMountFailed Reason = "InitrdMountFailed"
+	// NetworkInterfaceFailed means a network interface of the Microvm was not
+	// created on the host.
+	NetworkInterfaceFailed Reason = "NetworkInterfaceFailed"
+	// StartFailed means everything the Microvm needs was prepared, but it did
+	// not start.
+	StartFailed Reason = "StartFailed"
+	// Unknown means flintlock reported nothing about the Microvm.
+	Unknown Reason = "Unknown"
+)
+
+// reasons is checked in the order flintlock prepares a Microvm, so the first
+// which matches is the step it failed at.
+var reasons = []struct {
+	reason      Reason
+	description string
+	failed      func(spec *flintlocktypes.MicroVMSpec, status *flintlocktypes.MicroVMStatus) []string
+}{
+	{VolumeMountFailed, "volumes were not mounted", unmountedVolumes},
+	{KernelMountFailed, "kernel was not mounted", unmountedKernel},
+	{InitrdMountFailed, "initrd was not mounted", unmountedInitrd},
+	{NetworkInterfaceFailed, "network interfaces were not created", missingInterfaces},
+}
+
+// Classify returns the reason flintlock failed the VM, along with a message
+// describing what failed.
+func Classify(vm *flintlocktypes.MicroVM) (Reason, string) {
+	if vm == nil || vm.Spec == nil || vm.Status == nil {
+		return Unknown, "flintlock reported no status for the microvm"
+	}
+
+	retries := ""
+	if vm.Status.Retry > 0 {
+		retries = fmt.Sprintf(" after %d retries", vm.Status.Retry)
+	}
+
+	for _, r := range reasons {
+		if failed := r.failed(vm.Spec, vm.Status); len(failed) > 0 {
+			return r.reason, fmt.Sprintf("%s%s: %s", r.description, retries, strings.Join(failed, ", "))
+		}
+	}
+
+	return StartFailed, "microvm was prepared but did not start" + retries
+}
+
+func unmountedVolumes(spec *flintlocktypes.MicroVMSpec, status *flintlocktypes.MicroVMStatus) []string {
+	volumes := spec.AdditionalVolumes
+	if spec.RootVolume != nil {
+		volumes = append([]*flintlocktypes.Volume{spec.RootVolume}, volumes...)
+	}
+
+	failed := []string{}
+
+	for _, vol := range volumes {
+		if st, ok := status.Volumes[vol.Id]; !ok || st.Mount == nil {
+			failed = append(failed, vol.Id)
+		}
+	}
+
+	return failed
+}
+
+func unmountedKernel(spec *flintlocktypes.MicroVMSpec, status *flintlocktypes.MicroVMStatus) []string {
+	if spec.Kernel != nil && status.KernelMount == nil {
+		return []string{spec.Kernel.Image}
+	}
+
+	return nil
+}
+
+func unmountedInitrd(spec *flintlocktypes.MicroVMSpec, status *flintlocktypes.MicroVMStatus) []string {
+	if spec.Initrd != nil && status.InitrdMount == nil {
+		return []string{spec.Initrd.Image}
+	}
+
+	return nil
+}
+
+func missingInterfaces(spec *flintlocktypes.MicroVMSpec, status *flintlocktypes.MicroVMStatus) []string {
+	failed := []string{}
+
+	for _, iface := range spec.Interfaces {
+		if st, ok := status.NetworkInterfaces[iface.DeviceId]; !ok || st.HostDeviceName == "" {
+			failed = append(failed, iface.DeviceId)
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package failure_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/failure"
+)
+
+func newVM() *flintlocktypes.MicroVM {
+	return &flintlocktypes.MicroVM{
+		Spec: &flintlocktypes.MicroVMSpec{
+			Kernel:            &flintlocktypes.Kernel{Image: "kernel:1"},
+			Initrd:            &flintlocktypes.Initrd{Image: "initrd:1"},
+			RootVolume:        &flintlocktypes.Volume{Id: "root"},
+			AdditionalVolumes: []*flintlocktypes.Volume{{Id: "data"}},
+			Interfaces:        []*flintlocktypes.NetworkInterface{{DeviceId: "eth0"}},
+		},
+		Status: &flintlocktypes.MicroVMStatus{
+			State: flintlocktypes.MicroVMStatus_FAILED,
+			Volumes: map[string]*flintlocktypes.VolumeStatus{
+				"root": {Mount: &flintlocktypes.Mount{Source: "/dev/root"}},
+				"data": {Mount: &flintlocktypes.Mount{Source: "/dev/data"}},
+			},
+			KernelMount: &flintlocktypes.Mount{Source: "/kernel"},
+			InitrdMount: &flintlocktypes.Mount{Source: "/initrd"},
+			NetworkInterfaces: map[string]*flintlocktypes.NetworkInterfaceStatus{
+				"eth0": {HostDeviceName: "mvm0tap"},
+			},
+		},
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tt := []struct {
+		name     string
+		vm       func() *flintlocktypes.MicroVM
+		reason   failure.Reason
+		contains string
+	}{
+		{
+			name:     "no status",
+			vm:       func() *flintlocktypes.MicroVM { return &flintlocktypes.MicroVM{} },
+			reason:   failure.Unknown,
+			contains: "no status",
+		},
+		{
+			name: "volume not mounted",
+			vm: func() *flintlocktypes.MicroVM {
+				vm := newVM()
+				delete(vm.Status.Volumes, "data")
+				vm.Status.KernelMount = nil
+				vm.Status.Retry = 3
+
+				return vm
+			},
+			reason:   failure.VolumeMountFailed,
+			contains: "volumes were not mounted after 3 retries: data",
+		},
+		{
+			name: "kernel not mounted",
+			vm: func() *flintlocktypes.MicroVM {
+				vm := newVM()
+				vm.Status.KernelMount = nil
+
+				return vm
+			},
+			reason:   failure.KernelMountFailed,
+			contains: "kernel:1",
+		},
+		{
+			name: "initrd not mounted",
+			vm: func() *flintlocktypes.MicroVM {
+				vm := newVM()
+				vm.Status.InitrdMount = nil
+
+				return vm
+			},
+			reason:   failure.InitrdMountFailed,
+			contains: "initrd:1",
+		},
+		{
+			name: "network interface not created",
+			vm: func() *flintlocktypes.MicroVM {
+				vm := newVM()
+				vm.Status.NetworkInterfaces["eth0"].HostDeviceName = ""
+
+				return vm
+			},
+			reason:   failure.NetworkInterfaceFailed,
+			contains: "eth0",
+		},
+		{
+			name:     "everything prepared",
+			vm:       newVM,
+			reason:   failure.StartFailed,
+			contains: "did not start",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			reason, message := failure.Classify(tc.vm())
+			g.Expect(reason).To(Equal(tc.reason))
+			g.Expect(message).To(ContainSubstring(tc.contains))
+		})
+	}
+}
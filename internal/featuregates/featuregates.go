@@ -0,0 +1,82 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package featuregates lists the feature gates which let new behaviour ship
+// turned off, to be enabled per deployment with --feature-gates or the
+// featureGates of the operator configuration file.
+package featuregates
+
+import (
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/component-base/featuregate"
+)
+
+const (
+	// ExternalResourceGC collects Services left behind by deleted Microvms.
+	//
+	// beta: v0.1
+	ExternalResourceGC featuregate.Feature = "ExternalResourceGC"
+
+	// OrphanedMicrovmGC periodically deletes VMs on each MicrovmHost which are
+	// labelled as owned by a Microvm that no longer exists.
+	//
+	// alpha: v0.1
+	OrphanedMicrovmGC featuregate.Feature = "OrphanedMicrovmGC"
+
+	// MachinePool lets a MicrovmReplicaSet be the infrastructure of a Cluster
+	// API MachinePool, which then drives its replicas.
+	//
+	// alpha: v0.1
+	MachinePool featuregate.Feature = "MachinePool"
+
+	// AuditLog records every Create and Delete sent to a flintlock host as a
+	// MicrovmAction.
+	//
+	// alpha: v0.1
+	AuditLog featuregate.Feature = "AuditLog"
+
+	// SSHService creates a Service for the SSH port of the guest of each
+	// Microvm which sets an sshService.
+	//
+	// alpha: v0.1
+	SSHService featuregate.Feature = "SSHService"
+
+	// InPlaceUpdates applies changes to the spec of a created Microvm whose
+	// updateStrategy is Recreate or Resize by recreating its VM. The changes
+	// are only reported when it is off.
+	//
+	// beta: v0.1
+	InPlaceUpdates featuregate.Feature = "InPlaceUpdates"
+
+	// Failover recreates the replicas on a host which has been unreachable
+	// for longer than the failoverPolicy of their MicrovmReplicaSet or
+	// MicrovmDeployment on the other hosts.
+	//
+	// beta: v0.1
+	Failover featuregate.Feature = "Failover"
+)
+
+var (
+	// MutableGates is set from the command line and configuration file at
+	// startup. Everything else reads Gates.
+	MutableGates featuregate.MutableFeatureGate = featuregate.NewFeatureGate()
+
+	// Gates reports which features are enabled.
+	Gates featuregate.FeatureGate = MutableGates
+)
+
+// defaultFeatureGates lists every feature gate with whether it is on when it
+// is not set.
+var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+	ExternalResourceGC: {Default: true, PreRelease: featuregate.Beta},
+	OrphanedMicrovmGC:  {Default: false, PreRelease: featuregate.Alpha},
+	MachinePool:        {Default: false, PreRelease: featuregate.Alpha},
+	AuditLog:           {Default: false, PreRelease: featuregate.Alpha},
+	SSHService:         {Default: false, PreRelease: featuregate.Alpha},
+	InPlaceUpdates:     {Default: true, PreRelease: featuregate.Beta},
+	Failover:           {Default: true, PreRelease: featuregate.Beta},
+}
+
+func init() {
+	runtime.Must(MutableGates.Add(defaultFeatureGates))
+}
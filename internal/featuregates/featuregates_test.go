@@ -0,0 +1,29 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package featuregates_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/featuregates"
+)
+
+func TestFeatureGates(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(featuregates.Gates.Enabled(featuregates.ExternalResourceGC)).To(BeTrue(), "Expected the gate to default to on")
+	g.Expect(featuregates.Gates.Enabled(featuregates.OrphanedMicrovmGC)).To(BeFalse(), "Expected the alpha gate to default to off")
+	g.Expect(featuregates.Gates.Enabled(featuregates.MachinePool)).To(BeFalse(), "Expected the alpha gate to default to off")
+	g.Expect(featuregates.Gates.Enabled(featuregates.InPlaceUpdates)).To(BeTrue(), "Expected the beta gate to default to on")
+	g.Expect(featuregates.Gates.Enabled(featuregates.Failover)).To(BeTrue(), "Expected the beta gate to default to on")
+
+	gates := featuregates.MutableGates.DeepCopy()
+	g.Expect(gates.Set("ExternalResourceGC=false")).To(Succeed())
+	g.Expect(gates.Enabled(featuregates.ExternalResourceGC)).To(BeFalse())
+	g.Expect(featuregates.Gates.Enabled(featuregates.ExternalResourceGC)).To(BeTrue(), "Expected the copy to be independent")
+
+	g.Expect(gates.SetFromMap(map[string]bool{"Unknown": true})).ToNot(Succeed(), "Expected an unknown gate to be rejected")
+}
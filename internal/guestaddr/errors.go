@@ -0,0 +1,12 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestaddr
+
+import "errors"
+
+var (
+	errNotAnAddress      = errors.New("not an IP address")
+	errNotGuestAddress   = errors.New("not an address of the guest")
+	errUnsupportedScheme = errors.New("scheme must be http or https")
+)
@@ -0,0 +1,87 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package guestaddr keeps the requests the operator makes to a Microvm guest
+// on the addresses of that guest. The endpoints come from the Microvm spec, so
+// without this anyone able to create a Microvm could have the operator make
+// requests from inside the cluster to any address it can reach.
+package guestaddr
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+)
+
+// Addresses returns the addresses of the network interfaces of a guest.
+func Addresses(ifaces []microvm.NetworkInterface) []netip.Addr {
+	addrs := []netip.Addr{}
+
+	for _, iface := range ifaces {
+		if addr, ok := Parse(iface.Address); ok {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return addrs
+}
+
+// Parse parses the address of a network interface, which flintlock takes in
+// CIDR notation.
+func Parse(address string) (netip.Addr, bool) {
+	if address == "" {
+		return netip.Addr{}, false
+	}
+
+	if prefix, err := netip.ParsePrefix(address); err == nil {
+		return prefix.Addr(), true
+	}
+
+	addr, err := netip.ParseAddr(address)
+
+	return addr, err == nil
+}
+
+// CheckHost returns an error unless host is an IP address in addrs. Names are
+// refused, as what they resolve to can change after they are checked.
+func CheckHost(host string, addrs []netip.Addr) error {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errNotAnAddress, host)
+	}
+
+	addr = addr.Unmap()
+
+	for _, allowed := range addrs {
+		if allowed.Unmap() == addr {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errNotGuestAddress, host)
+}
+
+// CheckURL returns an error unless endpoint is an http or https URL whose host
+// is an IP address in addrs.
+func CheckURL(endpoint string, addrs []netip.Addr) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", endpoint, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: %s", errUnsupportedScheme, endpoint)
+	}
+
+	return CheckHost(u.Hostname(), addrs)
+}
+
+// NoRedirects is the CheckRedirect of the HTTP clients which talk to guests.
+// A redirect is not followed, so that a guest cannot send a request on to an
+// address which was never checked, and its response is returned instead.
+func NoRedirects(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestaddr_test
+
+import (
+	"net/netip"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestaddr"
+)
+
+func TestAddresses(t *testing.T) {
+	g := NewWithT(t)
+
+	addrs := guestaddr.Addresses([]microvm.NetworkInterface{
+		{GuestDeviceName: "eth0"},
+		{GuestDeviceName: "eth1", Address: "10.0.0.10/24"},
+		{GuestDeviceName: "eth2", Address: "fd00::5"},
+		{GuestDeviceName: "eth3", Address: "not-an-address"},
+	})
+	g.Expect(addrs).To(ConsistOf(netip.MustParseAddr("10.0.0.10"), netip.MustParseAddr("fd00::5")))
+}
+
+func TestCheckURL(t *testing.T) {
+	addrs := []netip.Addr{netip.MustParseAddr("10.0.0.10"), netip.MustParseAddr("fd00::5")}
+
+	tests := []struct {
+		name     string
+		endpoint string
+		allowed  bool
+	}{
+		{name: "guest address", endpoint: "http://10.0.0.10:8080/healthz", allowed: true},
+		{name: "guest ipv6 address", endpoint: "https://[fd00::5]:8443", allowed: true},
+		{name: "other address", endpoint: "http://10.0.0.11:8080/healthz"},
+		{name: "metadata service", endpoint: "http://169.254.169.254/latest/meta-data"},
+		{name: "name", endpoint: "http://kubernetes.default.svc/api"},
+		{name: "other scheme", endpoint: "file://10.0.0.10/etc/passwd"},
+		{name: "unparseable", endpoint: "http://[10.0.0.10"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := guestaddr.CheckURL(tc.endpoint, addrs)
+			if tc.allowed {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	g := NewWithT(t)
+
+	addrs := []netip.Addr{netip.MustParseAddr("10.0.0.10")}
+
+	g.Expect(guestaddr.CheckHost("10.0.0.10", addrs)).To(Succeed())
+	g.Expect(guestaddr.CheckHost("::ffff:10.0.0.10", addrs)).To(Succeed(), "Expected a mapped address to match")
+	g.Expect(guestaddr.CheckHost("127.0.0.1", addrs)).NotTo(Succeed())
+	g.Expect(guestaddr.CheckHost("localhost", addrs)).NotTo(Succeed())
+	g.Expect(guestaddr.CheckHost("10.0.0.10", nil)).NotTo(Succeed(), "Expected a guest without addresses to allow nothing")
+}
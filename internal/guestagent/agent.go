@@ -0,0 +1,158 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package guestagent asks the agent in a Microvm guest about the guest, and has
+// it run commands there. The agent serves the HTTP API which graceful
+// shutdowns and exec probes also use.
+package guestagent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxOutput is how many bytes of the output of a command are kept.
+	MaxOutput = 1024
+
+	defaultRequestTimeout = 5 * time.Second
+)
+
+// Info is what the agent reports about the guest.
+type Info struct {
+	Hostname      string    `json:"hostname"`
+	KernelVersion string    `json:"kernelVersion"`
+	OSImage       string    `json:"osImage"`
+	CloudInit     CloudInit `json:"cloudInit"`
+}
+
+// CloudInit is what the agent reports about cloud-init, as given by
+// `cloud-init status`.
+type CloudInit struct {
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ExecResult is the outcome of a command the agent ran.
+type ExecResult struct {
+	ExitCode int    `json:"exitCode"`
+	Output   string `json:"output"`
+}
+
+// Client talks to the agent in a guest.
+type Client interface {
+	// Info asks the agent at endpoint about the guest.
+	Info(ctx context.Context, endpoint string) (*Info, error)
+	// Exec has the agent at endpoint run command, killing it after timeout.
+	Exec(ctx context.Context, endpoint string, command []string, timeout time.Duration) (*ExecResult, error)
+}
+
+// AgentClient talks to the agent in a guest over HTTP.
+type AgentClient struct {
+	HTTPClient *http.Client
+}
+
+// NewAgentClient returns a Client which GETs /info and POSTs to /exec on the
+// agent endpoint.
+func NewAgentClient() Client {
+	return &AgentClient{HTTPClient: &http.Client{}}
+}
+
+// Info GETs /info from the agent at endpoint.
+func (a *AgentClient) Info(ctx context.Context, endpoint string) (*Info, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url(endpoint, "info"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("building info request: %w", err)
+	}
+
+	info := &Info{}
+	if err := a.do(req, info); err != nil {
+		return nil, fmt.Errorf("requesting info: %w", err)
+	}
+
+	return info, nil
+}
+
+type execRequest struct {
+	Command        []string `json:"command"`
+	TimeoutSeconds int32    `json:"timeoutSeconds,omitempty"`
+	MaxOutput      int      `json:"maxOutput,omitempty"`
+}
+
+// Exec POSTs command to /exec on the agent at endpoint, which runs it in the
+// guest and replies with its exit code and output. The request is given a
+// little longer than the command, so that the agent can report the timeout.
+func (a *AgentClient) Exec(ctx context.Context, endpoint string, command []string, timeout time.Duration) (*ExecResult, error) {
+	body, err := json.Marshal(execRequest{
+		Command:        command,
+		TimeoutSeconds: int32(timeout / time.Second),
+		MaxOutput:      MaxOutput,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding exec request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout+defaultRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url(endpoint, "exec"), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("building exec request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	result := &ExecResult{}
+	if err := a.do(req, result); err != nil {
+		return nil, fmt.Errorf("requesting exec: %w", err)
+	}
+
+	result.Output = Truncate(result.Output)
+
+	return result, nil
+}
+
+func (a *AgentClient) do(req *http.Request, into interface{}) error {
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s", errRejected, resp.Status)
+	}
+
+	// the output of a command is bounded by the agent, but a misbehaving one
+	// must not be able to make the operator read without end
+	limited := io.LimitReader(resp.Body, 16*MaxOutput)
+
+	if err := json.NewDecoder(limited).Decode(into); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
+
+// Truncate cuts output down to MaxOutput bytes, without splitting a UTF-8
+// character.
+func Truncate(output string) string {
+	if len(output) <= MaxOutput {
+		return output
+	}
+
+	return strings.ToValidUTF8(output[:MaxOutput], "")
+}
+
+func url(endpoint, path string) string {
+	return strings.TrimSuffix(endpoint, "/") + "/" + path
+}
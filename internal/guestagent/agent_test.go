@@ -0,0 +1,134 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestagent_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestagent"
+)
+
+func TestAgentClient(t *testing.T) {
+	g := NewWithT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/info":
+			g.Expect(r.Method).To(Equal(http.MethodGet))
+			_, _ = w.Write([]byte(`{"hostname":"mvm1","kernelVersion":"5.10.77","cloudInit":{"status":"done"}}`))
+		case "/exec":
+			g.Expect(r.Method).To(Equal(http.MethodPost))
+
+			req := map[string]interface{}{}
+			g.Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+			g.Expect(req).To(HaveKeyWithValue("timeoutSeconds", BeEquivalentTo(3)))
+
+			_ = json.NewEncoder(w).Encode(guestagent.ExecResult{ExitCode: 1, Output: strings.Repeat("x", 2*guestagent.MaxOutput)})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := guestagent.NewAgentClient()
+
+	info, err := client.Info(context.TODO(), server.URL+"/")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(info.Hostname).To(Equal("mvm1"))
+	g.Expect(info.CloudInit.Status).To(Equal("done"))
+
+	result, err := client.Exec(context.TODO(), server.URL, []string{"false"}, 3*time.Second)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.ExitCode).To(Equal(1))
+	g.Expect(result.Output).To(HaveLen(guestagent.MaxOutput), "Expected the output to be truncated")
+
+	_, err = client.Info(context.TODO(), server.URL+"/missing")
+	g.Expect(err).To(HaveOccurred(), "Expected a rejected request to fail")
+}
+
+type fakeClient struct {
+	infoErr error
+	execErr error
+}
+
+func (f *fakeClient) Info(_ context.Context, _ string) (*guestagent.Info, error) {
+	if f.infoErr != nil {
+		return nil, f.infoErr
+	}
+
+	return &guestagent.Info{
+		Hostname:  "mvm1",
+		CloudInit: guestagent.CloudInit{Status: "degraded done", Errors: []string{"module failed"}},
+	}, nil
+}
+
+func (f *fakeClient) Exec(_ context.Context, _ string, command []string, _ time.Duration) (*guestagent.ExecResult, error) {
+	if f.execErr != nil {
+		return nil, f.execErr
+	}
+
+	return &guestagent.ExecResult{Output: strings.Join(command, " ")}, nil
+}
+
+func TestCollect(t *testing.T) {
+	agent := &infrav1.GuestAgent{
+		Endpoint: "http://10.0.0.10:8080",
+		Commands: []infrav1.GuestCommand{{Name: "uptime", Command: []string{"uptime", "-p"}}},
+	}
+	now := time.Now()
+
+	tt := []struct {
+		name     string
+		client   *fakeClient
+		expected func(*WithT, *infrav1.GuestInfo, error)
+	}{
+		{
+			name:   "info and command results are recorded",
+			client: &fakeClient{},
+			expected: func(g *WithT, info *infrav1.GuestInfo, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(info.LastUpdateTime.Time).To(BeTemporally("==", now))
+				g.Expect(info.Hostname).To(Equal("mvm1"))
+				g.Expect(info.CloudInit).To(Equal(infrav1.CloudInitDone))
+				g.Expect(info.CloudInitErrors).To(ConsistOf("module failed"))
+				g.Expect(info.Commands).To(ConsistOf(infrav1.GuestCommandResult{Name: "uptime", Output: "uptime -p"}))
+			},
+		},
+		{
+			name:   "command which cannot be run is recorded with why",
+			client: &fakeClient{execErr: errors.New("no such file")},
+			expected: func(g *WithT, info *infrav1.GuestInfo, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(info.Commands).To(ConsistOf(infrav1.GuestCommandResult{Name: "uptime", Error: "no such file"}))
+			},
+		},
+		{
+			name:   "agent which does not answer is an error",
+			client: &fakeClient{infoErr: errors.New("connection refused")},
+			expected: func(g *WithT, info *infrav1.GuestInfo, err error) {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(info).To(BeNil())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			info, err := guestagent.Collect(context.TODO(), tc.client, agent, now)
+			tc.expected(g, info, err)
+		})
+	}
+}
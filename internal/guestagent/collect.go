@@ -0,0 +1,79 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestagent
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+const defaultCommandTimeout = 10 * time.Second
+
+// Collect asks the agent of a guest about it and runs its commands one after
+// another. A command which cannot be run is recorded with why, but an agent
+// which cannot be asked about the guest is an error.
+func Collect(ctx context.Context, client Client, agent *infrav1.GuestAgent, now time.Time) (*infrav1.GuestInfo, error) {
+	info, err := client.Info(ctx, agent.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := metav1.NewTime(now)
+	guestInfo := &infrav1.GuestInfo{
+		LastUpdateTime:  &updated,
+		Hostname:        info.Hostname,
+		KernelVersion:   info.KernelVersion,
+		OSImage:         info.OSImage,
+		CloudInit:       CloudInitState(info.CloudInit.Status),
+		CloudInitErrors: info.CloudInit.Errors,
+	}
+
+	for _, command := range agent.Commands {
+		guestInfo.Commands = append(guestInfo.Commands, run(ctx, client, agent.Endpoint, command))
+	}
+
+	return guestInfo, nil
+}
+
+// CloudInitState returns the state of cloud-init given the status reported by
+// `cloud-init status`, or an empty state for one it does not know.
+func CloudInitState(status string) infrav1.CloudInitState {
+	switch status {
+	case "not run", "not started", "running":
+		return infrav1.CloudInitRunning
+	case "done", "degraded done":
+		return infrav1.CloudInitDone
+	case "error", "degraded error":
+		return infrav1.CloudInitError
+	case "disabled":
+		return infrav1.CloudInitDisabled
+	default:
+		return ""
+	}
+}
+
+func run(ctx context.Context, client Client, endpoint string, command infrav1.GuestCommand) infrav1.GuestCommandResult {
+	timeout := defaultCommandTimeout
+	if command.TimeoutSeconds > 0 {
+		timeout = time.Duration(command.TimeoutSeconds) * time.Second
+	}
+
+	result := infrav1.GuestCommandResult{Name: command.Name}
+
+	exec, err := client.Exec(ctx, endpoint, command.Command, timeout)
+	if err != nil {
+		result.Error = err.Error()
+
+		return result
+	}
+
+	result.ExitCode = int32(exec.ExitCode)
+	result.Output = Truncate(exec.Output)
+
+	return result
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package health
+
+import (
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+const (
+	// MaxWindow is the longest rolling window a MicrovmHost may use.
+	MaxWindow = 24 * time.Hour
+
+	// DefaultObjective is the fraction of attempts expected to succeed when a
+	// MicrovmHost does not set one.
+	DefaultObjective = 0.99
+
+	// bucketsPerWindow is how many buckets a window is divided into, which
+	// bounds the size of the MicrovmHost status.
+	bucketsPerWindow = 20
+)
+
+// Summary describes the provisioning outcomes within a window.
+type Summary struct {
+	Successes int32
+	Failures  int32
+	// SuccessRatio is the fraction of attempts which succeeded, or 1 when
+	// there were no attempts.
+	SuccessRatio float64
+	// BudgetRemaining is the fraction of the error budget left, between 0
+	// and 1.
+	BudgetRemaining float64
+}
+
+// Attempts returns the total number of attempts in the window.
+func (s Summary) Attempts() int32 {
+	return s.Successes + s.Failures
+}
+
+// Exhausted returns true when the budget is used up and at least minAttempts
+// were made, so that a single early failure does not exhaust it.
+func (s Summary) Exhausted(minAttempts int32) bool {
+	return s.Attempts() >= minAttempts && s.BudgetRemaining <= 0
+}
+
+// AddOutcomes counts outcomes into the buckets for a window of the given length,
+// returning the buckets ordered by start time.
+func AddOutcomes(buckets []infrav1.ProvisioningBucket, outcomes []Outcome, window time.Duration) []infrav1.ProvisioningBucket {
+	size := window / bucketsPerWindow
+
+	for _, outcome := range outcomes {
+		start := outcome.Time.Truncate(size)
+
+		i := sort.Search(len(buckets), func(i int) bool {
+			return !buckets[i].Start.Time.Before(start)
+		})
+
+		if i == len(buckets) || !buckets[i].Start.Time.Equal(start) {
+			buckets = append(buckets, infrav1.ProvisioningBucket{})
+			copy(buckets[i+1:], buckets[i:])
+			buckets[i] = infrav1.ProvisioningBucket{Start: metav1.NewTime(start)}
+		}
+
+		if outcome.Succeeded {
+			buckets[i].Successes++
+		} else {
+			buckets[i].Failures++
+		}
+	}
+
+	return buckets
+}
+
+// Prune drops the buckets which started before the window ending at now.
+func Prune(buckets []infrav1.ProvisioningBucket, now time.Time, window time.Duration) []infrav1.ProvisioningBucket {
+	since := now.Add(-window)
+	retained := []infrav1.ProvisioningBucket{}
+
+	for _, bucket := range buckets {
+		if !bucket.Start.Time.Before(since) {
+			retained = append(retained, bucket)
+		}
+	}
+
+	return retained
+}
+
+// Summarize totals the buckets and measures them against objective, the
+// fraction of attempts which should succeed.
+func Summarize(buckets []infrav1.ProvisioningBucket, objective float64) Summary {
+	summary := Summary{SuccessRatio: 1, BudgetRemaining: 1}
+
+	for _, bucket := range buckets {
+		summary.Successes += bucket.Successes
+		summary.Failures += bucket.Failures
+	}
+
+	attempts := summary.Attempts()
+	if attempts == 0 {
+		return summary
+	}
+
+	summary.SuccessRatio = float64(summary.Successes) / float64(attempts)
+
+	allowed := (1 - objective) * float64(attempts)
+
+	switch {
+	case summary.Failures == 0:
+		summary.BudgetRemaining = 1
+	case allowed <= 0:
+		summary.BudgetRemaining = 0
+	default:
+		summary.BudgetRemaining = 1 - float64(summary.Failures)/allowed
+	}
+
+	if summary.BudgetRemaining < 0 {
+		summary.BudgetRemaining = 0
+	}
+
+	return summary
+}
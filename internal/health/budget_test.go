@@ -0,0 +1,130 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package health_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/health"
+)
+
+func TestSummarize(t *testing.T) {
+	tt := []struct {
+		name      string
+		successes int32
+		failures  int32
+		objective float64
+		ratio     float64
+		remaining float64
+	}{
+		{
+			name:      "no attempts",
+			objective: 0.99,
+			ratio:     1,
+			remaining: 1,
+		},
+		{
+			name:      "no failures",
+			successes: 10,
+			objective: 0.99,
+			ratio:     1,
+			remaining: 1,
+		},
+		{
+			name:      "half the budget used",
+			successes: 95,
+			failures:  5,
+			objective: 0.9,
+			ratio:     0.95,
+			remaining: 0.5,
+		},
+		{
+			name:      "budget overspent",
+			successes: 5,
+			failures:  5,
+			objective: 0.9,
+			ratio:     0.5,
+			remaining: 0,
+		},
+		{
+			name:      "no failures allowed",
+			successes: 9,
+			failures:  1,
+			objective: 1,
+			ratio:     0.9,
+			remaining: 0,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			buckets := []infrav1.ProvisioningBucket{{Successes: tc.successes, Failures: tc.failures}}
+			summary := health.Summarize(buckets, tc.objective)
+
+			g.Expect(summary.Attempts()).To(Equal(tc.successes + tc.failures))
+			g.Expect(summary.SuccessRatio).To(BeNumerically("~", tc.ratio, 0.001))
+			g.Expect(summary.BudgetRemaining).To(BeNumerically("~", tc.remaining, 0.001))
+		})
+	}
+}
+
+func TestSummaryExhausted(t *testing.T) {
+	g := NewWithT(t)
+
+	exhausted := health.Summary{Successes: 1, Failures: 2, BudgetRemaining: 0}
+	g.Expect(exhausted.Exhausted(3)).To(BeTrue())
+	g.Expect(exhausted.Exhausted(4)).To(BeFalse(), "too few attempts")
+
+	available := health.Summary{Successes: 10, Failures: 1, BudgetRemaining: 0.1}
+	g.Expect(available.Exhausted(1)).To(BeFalse())
+}
+
+func TestAddOutcomesAndPrune(t *testing.T) {
+	g := NewWithT(t)
+
+	window := 20 * time.Minute
+	now := time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	buckets := health.AddOutcomes(nil, []health.Outcome{
+		{Time: now.Add(-30 * time.Second), Succeeded: true},
+		{Time: now.Add(-25 * time.Minute), Succeeded: false},
+		{Time: now.Add(-45 * time.Second), Succeeded: false},
+		{Time: now.Add(-5 * time.Minute), Succeeded: true},
+	}, window)
+
+	g.Expect(buckets).To(HaveLen(3))
+	g.Expect(buckets[0].Start.Time).To(Equal(now.Add(-25 * time.Minute)))
+	g.Expect(buckets[2].Start.Time).To(Equal(now.Add(-time.Minute)))
+	g.Expect(buckets[2].Successes).To(Equal(int32(1)))
+	g.Expect(buckets[2].Failures).To(Equal(int32(1)))
+
+	buckets = health.AddOutcomes(buckets, []health.Outcome{{Time: now, Succeeded: true}}, window)
+	g.Expect(buckets).To(HaveLen(4))
+
+	pruned := health.Prune(buckets, now, window)
+	g.Expect(pruned).To(HaveLen(3), "Expect the bucket outside the window to be dropped")
+	g.Expect(health.Summarize(pruned, 0.9).Attempts()).To(Equal(int32(4)))
+}
+
+func TestRecorder(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	recorder := health.NewRecorder()
+
+	recorder.Record("host-a:9090", false, now.Add(-2*health.MaxWindow))
+	recorder.Record("host-a:9090", true, now)
+	recorder.Record("host-b:9090", false, now)
+
+	g.Expect(recorder.Drain("host-a:9090")).To(Equal([]health.Outcome{{Time: now, Succeeded: true}}),
+		"Expect outcomes older than the longest window to be dropped")
+	g.Expect(recorder.Drain("host-a:9090")).To(BeEmpty(), "Expect outcomes to be drained once")
+	g.Expect(recorder.Drain("host-b:9090")).To(HaveLen(1))
+}
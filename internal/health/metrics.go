@@ -0,0 +1,120 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package health
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const hostLabel = "host"
+
+var (
+	provisioningTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "microvm_host_provisioning_total",
+		Help: "Number of attempts to provision a microvm on each host, by result.",
+	}, []string{hostLabel, "result"})
+
+	successRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "microvm_host_provisioning_success_ratio",
+		Help: "Fraction of provisioning attempts which succeeded within the host's window.",
+	}, []string{hostLabel})
+
+	budgetRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "microvm_host_error_budget_remaining",
+		Help: "Fraction of the host's provisioning error budget left within its window.",
+	}, []string{hostLabel})
+
+	quarantined = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "microvm_host_quarantined",
+		Help: "Whether new microvms are being kept off the host, 1 when they are.",
+	}, []string{hostLabel})
+
+	identityMismatch = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "microvm_host_identity_mismatch",
+		Help: "Whether the host presented an unexpected TLS identity, 1 when it did.",
+	}, []string{hostLabel})
+
+	provisioningSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "microvm_provisioning_phase_seconds",
+		Help:    "Time from a microvm being accepted until it reached each provisioning phase, or from creation until it was accepted.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 12),
+	}, []string{hostLabel, "phase"})
+
+	provisionedSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "microvm_provisioning_duration_seconds",
+		Help:    "Time from a microvm being accepted until it was ready, by host and root volume image.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 12),
+	}, []string{hostLabel, "image"})
+
+	failuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "microvm_provisioning_failures_total",
+		Help: "Number of microvms which flintlock failed on each host, by reason.",
+	}, []string{hostLabel, "reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(provisioningTotal, successRatio, budgetRemaining, quarantined, identityMismatch,
+		provisioningSeconds, provisionedSeconds, failuresTotal)
+}
+
+// Report publishes the summary for the host at endpoint.
+func Report(endpoint string, summary Summary, isQuarantined bool) {
+	successRatio.WithLabelValues(endpoint).Set(summary.SuccessRatio)
+	budgetRemaining.WithLabelValues(endpoint).Set(summary.BudgetRemaining)
+
+	value := 0.0
+	if isQuarantined {
+		value = 1
+	}
+
+	quarantined.WithLabelValues(endpoint).Set(value)
+}
+
+// ReportIdentity publishes whether the host at endpoint presented an
+// unexpected identity.
+func ReportIdentity(endpoint string, mismatch bool) {
+	value := 0.0
+	if mismatch {
+		value = 1
+	}
+
+	identityMismatch.WithLabelValues(endpoint).Set(value)
+}
+
+// ObserveProvisioning counts how long a microvm on the host at endpoint took to
+// reach phase.
+func ObserveProvisioning(endpoint, phase string, took time.Duration) {
+	provisioningSeconds.WithLabelValues(endpoint, phase).Observe(took.Seconds())
+}
+
+// ObserveProvisioned counts how long a microvm booted from image on the host
+// at endpoint took to become ready.
+func ObserveProvisioned(endpoint, image string, took time.Duration) {
+	provisionedSeconds.WithLabelValues(endpoint, image).Observe(took.Seconds())
+}
+
+// ObserveFailure counts a microvm which failed on the host at endpoint for
+// reason.
+func ObserveFailure(endpoint, reason string) {
+	failuresTotal.WithLabelValues(endpoint, reason).Inc()
+}
+
+// Forget stops publishing the summary for the host at endpoint.
+func Forget(endpoint string) {
+	successRatio.DeleteLabelValues(endpoint)
+	budgetRemaining.DeleteLabelValues(endpoint)
+	quarantined.DeleteLabelValues(endpoint)
+	identityMismatch.DeleteLabelValues(endpoint)
+}
+
+func resultLabel(succeeded bool) string {
+	if succeeded {
+		return "success"
+	}
+
+	return "failure"
+}
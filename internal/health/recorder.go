@@ -0,0 +1,60 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// Outcome is the result of a single attempt to provision a Microvm on a host.
+type Outcome struct {
+	Time      time.Time
+	Succeeded bool
+}
+
+// Recorder holds provisioning outcomes in memory until the MicrovmHost
+// controller moves them into the status of the host they happened on. Outcomes
+// for hosts without a MicrovmHost are dropped once they are older than
+// MaxWindow.
+type Recorder struct {
+	mu      sync.Mutex
+	pending map[string][]Outcome
+}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{pending: map[string][]Outcome{}}
+}
+
+// Record adds an outcome for the host at endpoint and counts it in the
+// provisioning metrics.
+func (r *Recorder) Record(endpoint string, succeeded bool, at time.Time) {
+	provisioningTotal.WithLabelValues(endpoint, resultLabel(succeeded)).Inc()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	since := at.Add(-MaxWindow)
+	retained := []Outcome{}
+
+	for _, old := range r.pending[endpoint] {
+		if !old.Time.Before(since) {
+			retained = append(retained, old)
+		}
+	}
+
+	r.pending[endpoint] = append(retained, Outcome{Time: at, Succeeded: succeeded})
+}
+
+// Drain removes and returns the outcomes recorded for the host at endpoint.
+func (r *Recorder) Drain(endpoint string) []Outcome {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	outcomes := r.pending[endpoint]
+	delete(r.pending, endpoint)
+
+	return outcomes
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package heartbeat
+
+import (
+	"context"
+	"time"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/retry"
+)
+
+// FactoryFunc wraps factory so that the outcome of each call made by the
+// clients it returns is recorded in registry against their host.
+func FactoryFunc(factory flclient.FactoryFunc, registry *Registry) flclient.FactoryFunc {
+	return func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		client, err := factory(address, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &recordingClient{Client: client, address: address, registry: registry}, nil
+	}
+}
+
+// recordingClient is a flintlock client which records whether each call
+// reached its host. A call the host answered with an error has still reached
+// it. Streams are passed through, as they outlive the call which opens them.
+type recordingClient struct {
+	flclient.Client
+
+	address  string
+	registry *Registry
+}
+
+func (c *recordingClient) done(err error) {
+	c.registry.Record(c.address, !retry.Unreachable(err), time.Now())
+}
+
+func (c *recordingClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	resp, err := c.Client.CreateMicroVM(ctx, in, opts...)
+	c.done(err)
+
+	return resp, err
+}
+
+func (c *recordingClient) DeleteMicroVM(
+	ctx context.Context,
+	in *flintlockv1.DeleteMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*emptypb.Empty, error) {
+	resp, err := c.Client.DeleteMicroVM(ctx, in, opts...)
+	c.done(err)
+
+	return resp, err
+}
+
+func (c *recordingClient) GetMicroVM(
+	ctx context.Context,
+	in *flintlockv1.GetMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.GetMicroVMResponse, error) {
+	resp, err := c.Client.GetMicroVM(ctx, in, opts...)
+	c.done(err)
+
+	return resp, err
+}
+
+func (c *recordingClient) ListMicroVMs(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.ListMicroVMsResponse, error) {
+	resp, err := c.Client.ListMicroVMs(ctx, in, opts...)
+	c.done(err)
+
+	return resp, err
+}
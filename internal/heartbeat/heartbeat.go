@@ -0,0 +1,106 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package heartbeat tracks when each flintlock host last answered a call, so
+// that new replicas can be kept off hosts the operator has not been able to
+// reach recently.
+//
+// Contact is only known for hosts the operator has called since it started. A
+// host which has not been called is not treated as unreachable, and neither is
+// one which has simply not been called for a while, as a host with no
+// Microvms on it is not called at all.
+package heartbeat
+
+import (
+	"sync"
+	"time"
+)
+
+// Contact is what is known about reaching a host.
+type Contact struct {
+	// LastSuccess is when the host last answered a call, or zero if it has
+	// not answered one since the operator started.
+	LastSuccess time.Time
+	// FailingSince is when a call first failed to reach the host after it
+	// last answered one, or zero if the latest call reached it.
+	FailingSince time.Time
+}
+
+// Unreachable returns true if calls have been failing to reach the host, and
+// it has not answered one for at least after.
+func (c Contact) Unreachable(after time.Duration, now time.Time) bool {
+	at := c.UnreachableAt(after)
+
+	return !at.IsZero() && !now.Before(at)
+}
+
+// UnreachableAt returns when the host will have gone after without answering
+// a call, or zero if the latest call reached it.
+func (c Contact) UnreachableAt(after time.Duration) time.Time {
+	if c.FailingSince.IsZero() {
+		return time.Time{}
+	}
+
+	since := c.LastSuccess
+	if since.IsZero() {
+		since = c.FailingSince
+	}
+
+	return since.Add(after)
+}
+
+// Registry holds the contact with each host, shared by every controller which
+// calls flintlock. Its methods are safe to call on a nil Registry, which knows
+// of no hosts.
+type Registry struct {
+	mu    sync.RWMutex
+	hosts map[string]Contact
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{hosts: map[string]Contact{}}
+}
+
+// Record notes a call to the host at endpoint at the given time, which reached
+// the host if it was answered, and publishes the contact in the metrics.
+func (r *Registry) Record(endpoint string, reached bool, at time.Time) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	contact := r.hosts[endpoint]
+
+	switch {
+	case reached:
+		if at.After(contact.LastSuccess) {
+			contact.LastSuccess = at
+		}
+
+		contact.FailingSince = time.Time{}
+	case contact.FailingSince.IsZero():
+		contact.FailingSince = at
+	}
+
+	r.hosts[endpoint] = contact
+
+	report(endpoint, contact, reached)
+}
+
+// Contact returns the contact with the host at endpoint, and false if it has
+// not been called.
+func (r *Registry) Contact(endpoint string) (Contact, bool) {
+	if r == nil {
+		return Contact{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	contact, ok := r.hosts[endpoint]
+
+	return contact, ok
+}
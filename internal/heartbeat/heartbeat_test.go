@@ -0,0 +1,89 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package heartbeat_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/heartbeat"
+)
+
+func TestRegistry(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	registry := heartbeat.NewRegistry()
+
+	_, ok := registry.Contact("host1:9090")
+	g.Expect(ok).To(BeFalse(), "Expected a host which has not been called to be unknown")
+
+	registry.Record("host1:9090", true, now.Add(-10*time.Minute))
+	registry.Record("host1:9090", false, now.Add(-8*time.Minute))
+	registry.Record("host1:9090", false, now.Add(-time.Minute))
+
+	contact, ok := registry.Contact("host1:9090")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(contact.LastSuccess).To(Equal(now.Add(-10 * time.Minute)))
+	g.Expect(contact.FailingSince).To(Equal(now.Add(-8*time.Minute)), "Expected the first failure to be kept")
+	g.Expect(contact.Unreachable(5*time.Minute, now)).To(BeTrue())
+	g.Expect(contact.Unreachable(15*time.Minute, now)).To(BeFalse())
+	g.Expect(contact.UnreachableAt(15 * time.Minute)).To(Equal(now.Add(5 * time.Minute)))
+
+	registry.Record("host1:9090", true, now)
+
+	contact, _ = registry.Contact("host1:9090")
+	g.Expect(contact.LastSuccess).To(Equal(now))
+	g.Expect(contact.FailingSince.IsZero()).To(BeTrue(), "Expected an answer to clear the failure")
+	g.Expect(contact.Unreachable(time.Nanosecond, now.Add(time.Hour))).To(BeFalse(),
+		"Expected a host which answered its latest call not to be unreachable however long ago it was")
+	g.Expect(contact.UnreachableAt(time.Minute).IsZero()).To(BeTrue())
+
+	registry.Record("host2:9090", false, now.Add(-time.Minute))
+
+	contact, _ = registry.Contact("host2:9090")
+	g.Expect(contact.LastSuccess.IsZero()).To(BeTrue())
+	g.Expect(contact.Unreachable(time.Minute, now)).To(BeTrue(), "Expected a host which never answered to be timed from its first failure")
+
+	var nilRegistry *heartbeat.Registry
+	nilRegistry.Record("host1:9090", true, now)
+	_, ok = nilRegistry.Contact("host1:9090")
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestFactoryFunc(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeAPIClient := &fakes.FakeClient{}
+	fakeAPIClient.GetMicroVMReturns(nil, status.Error(codes.NotFound, "microvm not found"))
+	fakeAPIClient.DeleteMicroVMReturns(nil, status.Error(codes.Unavailable, "connection refused"))
+
+	registry := heartbeat.NewRegistry()
+	client, err := heartbeat.FactoryFunc(func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		return fakeAPIClient, nil
+	}, registry)("host1:9090")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = client.GetMicroVM(context.TODO(), &flintlockv1.GetMicroVMRequest{})
+	g.Expect(status.Code(err)).To(Equal(codes.NotFound))
+
+	contact, ok := registry.Contact("host1:9090")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(contact.LastSuccess.IsZero()).To(BeFalse(), "Expected a call the host answered with an error to count as contact")
+	g.Expect(contact.FailingSince.IsZero()).To(BeTrue())
+
+	_, err = client.DeleteMicroVM(context.TODO(), &flintlockv1.DeleteMicroVMRequest{})
+	g.Expect(status.Code(err)).To(Equal(codes.Unavailable))
+
+	contact, _ = registry.Contact("host1:9090")
+	g.Expect(contact.FailingSince.IsZero()).To(BeFalse(), "Expected a call which did not reach the host to be recorded")
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package heartbeat
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const hostLabel = "host"
+
+var (
+	lastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "microvm_host_last_successful_contact_timestamp_seconds",
+		Help: "Unix time at which each host last answered a flintlock call.",
+	}, []string{hostLabel})
+
+	failing = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "microvm_host_contact_failing",
+		Help: "Whether the latest flintlock call to the host failed to reach it, 1 when it did.",
+	}, []string{hostLabel})
+
+	unreachedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "microvm_host_contact_failures_total",
+		Help: "Number of flintlock calls which failed to reach each host.",
+	}, []string{hostLabel})
+)
+
+func init() {
+	metrics.Registry.MustRegister(lastSuccess, failing, unreachedTotal)
+}
+
+// report publishes the contact with the host at endpoint after a call which
+// reached it or not.
+func report(endpoint string, contact Contact, reached bool) {
+	if !contact.LastSuccess.IsZero() {
+		lastSuccess.WithLabelValues(endpoint).Set(float64(contact.LastSuccess.UnixNano()) / 1e9)
+	}
+
+	if reached {
+		failing.WithLabelValues(endpoint).Set(0)
+
+		return
+	}
+
+	failing.WithLabelValues(endpoint).Set(1)
+	unreachedTotal.WithLabelValues(endpoint).Inc()
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package hostaddr
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidateEndpoint returns what is wrong with endpoint as the address of a
+// flintlock host, which must be a DNS name or IP address and a port, such as
+// host1.example.com:9090 or [fd00::1]:9090. A scheme is not allowed.
+func ValidateEndpoint(endpoint string, path *field.Path) field.ErrorList {
+	if endpoint == "" {
+		return field.ErrorList{field.Required(path, "must be the host:port of a flintlock host")}
+	}
+
+	if strings.Contains(endpoint, "://") {
+		return field.ErrorList{field.Invalid(path, endpoint, "must be host:port without a scheme")}
+	}
+
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, endpoint, "must be host:port: "+err.Error())}
+	}
+
+	errs := field.ErrorList{}
+
+	if net.ParseIP(host) == nil {
+		for _, msg := range validation.IsDNS1123Subdomain(strings.ToLower(host)) {
+			errs = append(errs, field.Invalid(path, endpoint, "host "+msg))
+		}
+	}
+
+	if number, err := strconv.Atoi(port); err != nil {
+		errs = append(errs, field.Invalid(path, endpoint, "port must be a number"))
+	} else {
+		for _, msg := range validation.IsValidPortNum(number) {
+			errs = append(errs, field.Invalid(path, endpoint, "port "+msg))
+		}
+	}
+
+	return errs
+}
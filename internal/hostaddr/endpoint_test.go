@@ -0,0 +1,49 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package hostaddr_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/hostaddr"
+)
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		expected string
+	}{
+		{endpoint: "127.0.0.1:9090"},
+		{endpoint: "[fd00::1]:9090"},
+		{endpoint: "Host1.example.com:9090"},
+		{endpoint: "", expected: "Required value"},
+		{endpoint: "http://127.0.0.1:9090", expected: "without a scheme"},
+		{endpoint: "127.0.0.1", expected: "missing port"},
+		{endpoint: "fd00::1:9090", expected: "too many colons"},
+		{endpoint: ":9090", expected: "host"},
+		{endpoint: "host_1:9090", expected: "host"},
+		{endpoint: "127.0.0.1:grpc", expected: "port must be a number"},
+		{endpoint: "127.0.0.1:70000", expected: "port must be between"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.endpoint, func(t *testing.T) {
+			g := NewWithT(t)
+
+			errs := hostaddr.ValidateEndpoint(tc.endpoint, field.NewPath("spec", "host", "endpoint"))
+			if tc.expected == "" {
+				g.Expect(errs).To(BeEmpty())
+
+				return
+			}
+
+			g.Expect(errs).NotTo(BeEmpty())
+			g.Expect(errs.ToAggregate().Error()).To(ContainSubstring("spec.host.endpoint"))
+			g.Expect(errs.ToAggregate().Error()).To(ContainSubstring(tc.expected))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package hostaddr records the address which answered the calls made to a
+// flintlock host.
+//
+// A host endpoint may be a DNS name with several records. It is resolved each
+// time a connection is made, and each of its addresses is tried in turn until
+// one accepts the connection, so an address which goes stale does not stop the
+// host being reached through the others.
+package hostaddr
+
+import (
+	"context"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Client wraps client so that record is called with the address of the host
+// after each call it answers.
+func Client(client flclient.Client, record func(address string)) flclient.Client {
+	return &recordingClient{Client: client, record: record}
+}
+
+// recordingClient is a flintlock client which records the address of the host
+// which answered each call. Streams are passed through, as the address is only
+// known once they have been read from.
+type recordingClient struct {
+	flclient.Client
+
+	record func(address string)
+}
+
+// withPeer returns opts with an option which captures the address of the host
+// into p.
+func withPeer(p *peer.Peer, opts []grpc.CallOption) []grpc.CallOption {
+	return append(append([]grpc.CallOption{}, opts...), grpc.Peer(p))
+}
+
+// done records the address captured into p, if the call reached the host. A
+// call which the host answered with an error has still reached it.
+func (c *recordingClient) done(p *peer.Peer) {
+	if p.Addr != nil {
+		c.record(p.Addr.String())
+	}
+}
+
+func (c *recordingClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	p := &peer.Peer{}
+	resp, err := c.Client.CreateMicroVM(ctx, in, withPeer(p, opts)...)
+	c.done(p)
+
+	return resp, err
+}
+
+func (c *recordingClient) DeleteMicroVM(
+	ctx context.Context,
+	in *flintlockv1.DeleteMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*emptypb.Empty, error) {
+	p := &peer.Peer{}
+	resp, err := c.Client.DeleteMicroVM(ctx, in, withPeer(p, opts)...)
+	c.done(p)
+
+	return resp, err
+}
+
+func (c *recordingClient) GetMicroVM(
+	ctx context.Context,
+	in *flintlockv1.GetMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.GetMicroVMResponse, error) {
+	p := &peer.Peer{}
+	resp, err := c.Client.GetMicroVM(ctx, in, withPeer(p, opts)...)
+	c.done(p)
+
+	return resp, err
+}
+
+func (c *recordingClient) ListMicroVMs(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.ListMicroVMsResponse, error) {
+	p := &peer.Peer{}
+	resp, err := c.Client.ListMicroVMs(ctx, in, withPeer(p, opts)...)
+	c.done(p)
+
+	return resp, err
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package hostaddr_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/hostaddr"
+)
+
+func TestClient(t *testing.T) {
+	g := NewWithT(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	server := grpc.NewServer()
+	flintlockv1.RegisterMicroVMServer(server, flintlockv1.UnimplementedMicroVMServer{})
+
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the endpoint is a name which is resolved when the client connects
+	client, err := flclient.NewFlintlockClient(net.JoinHostPort("localhost", port))
+	g.Expect(err).NotTo(HaveOccurred())
+	defer client.Close()
+
+	var recorded string
+
+	client = hostaddr.Client(client, func(address string) {
+		recorded = address
+	})
+
+	_, err = client.GetMicroVM(context.TODO(), &flintlockv1.GetMicroVMRequest{Uid: "abcdef"})
+	g.Expect(status.Code(err)).To(Equal(codes.Unimplemented))
+	g.Expect(recorded).To(Equal(listener.Addr().String()), "Expected the address which answered to be recorded")
+}
@@ -0,0 +1,15 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package hostvm
+
+import "errors"
+
+var (
+	// ErrNameConflict is returned when the host already has a VM with the
+	// namespace and name, which another Microvm created.
+	ErrNameConflict = errors.New("host vm name conflict")
+
+	errInvalidName   = errors.New("invalid host vm name")
+	errStillDeleting = errors.New("previous vm is still being deleted")
+)
@@ -0,0 +1,138 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package hostvm sets the namespace and name a Microvm's VM is created with on
+// its host, and refuses to create it when the host already has a VM of that
+// namespace and name which another Microvm created, so that clusters can share
+// a host without their VMs clashing.
+package hostvm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// Identity is the namespace and name of a VM on its host.
+type Identity struct {
+	Namespace string
+	Name      string
+}
+
+// ForMicrovm returns the identity the VM of mvm is created with, which is that
+// of mvm unless its spec overrides it.
+func ForMicrovm(mvm *infrav1.Microvm) Identity {
+	id := Identity{Namespace: mvm.Namespace, Name: mvm.Name}
+
+	if mvm.Spec.HostVMNamespace != "" {
+		id.Namespace = mvm.Spec.HostVMNamespace
+	}
+
+	if mvm.Spec.HostVMName != "" {
+		id.Name = mvm.Spec.HostVMName
+	}
+
+	return id
+}
+
+// String returns the identity as namespace/name.
+func (i Identity) String() string {
+	return i.Namespace + "/" + i.Name
+}
+
+// Validate returns an error if the namespace or name could not be those of a
+// Kubernetes object, which flintlock expects of them.
+func (i Identity) Validate() error {
+	if errs := validation.IsDNS1123Label(i.Namespace); len(errs) > 0 {
+		return fmt.Errorf("%w: namespace %q: %s", errInvalidName, i.Namespace, strings.Join(errs, ", "))
+	}
+
+	if errs := validation.IsDNS1123Subdomain(i.Name); len(errs) > 0 {
+		return fmt.Errorf("%w: name %q: %s", errInvalidName, i.Name, strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// Client wraps client so that the VM it creates has the identity id, and is
+// only created if no VM already has it. A VM with the identity which owner
+// created is returned instead of creating another, so that a create whose
+// result was lost does not leave a VM behind.
+func Client(client flclient.Client, id Identity, owner types.UID) flclient.Client {
+	return &identityClient{Client: client, id: id, owner: owner}
+}
+
+// identityClient is a flintlock client which creates its VM with an identity
+// which no other Microvm's VM has on the host.
+type identityClient struct {
+	flclient.Client
+
+	id    Identity
+	owner types.UID
+}
+
+func (c *identityClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	spec := in.GetMicrovm()
+	if spec == nil {
+		return c.Client.CreateMicroVM(ctx, in, opts...)
+	}
+
+	if err := c.id.Validate(); err != nil {
+		return nil, err
+	}
+
+	spec.Namespace = c.id.Namespace
+	spec.Id = c.id.Name
+
+	existing, err := c.find(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing == nil {
+		return c.Client.CreateMicroVM(ctx, in, opts...)
+	}
+
+	if owner := existing.Spec.Labels[infrav1.MicrovmUIDLabel]; c.owner == "" || owner != string(c.owner) {
+		return nil, fmt.Errorf("%w: %s is held by vm %s of microvm %q", ErrNameConflict, c.id, existing.Spec.GetUid(), owner)
+	}
+
+	if existing.Status.GetState() == flintlocktypes.MicroVMStatus_DELETING {
+		return nil, fmt.Errorf("%w: %s", errStillDeleting, existing.Spec.GetUid())
+	}
+
+	return &flintlockv1.CreateMicroVMResponse{Microvm: existing}, nil
+}
+
+// find returns the VM on the host with the identity, or nil if there is none.
+func (c *identityClient) find(ctx context.Context, opts ...grpc.CallOption) (*flintlocktypes.MicroVM, error) {
+	resp, err := c.Client.ListMicroVMs(ctx, &flintlockv1.ListMicroVMsRequest{
+		Namespace: c.id.Namespace,
+		Name:      &c.id.Name,
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("checking for an existing vm %s: %w", c.id, err)
+	}
+
+	for _, vm := range resp.GetMicrovm() {
+		// the filter is checked again, as not every host applies it
+		if vm.GetSpec().GetNamespace() == c.id.Namespace && vm.GetSpec().GetId() == c.id.Name {
+			return vm, nil
+		}
+	}
+
+	return nil, nil
+}
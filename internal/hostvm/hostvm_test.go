@@ -0,0 +1,138 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package hostvm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/hostvm"
+)
+
+func TestForMicrovm(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1"}}
+	g.Expect(hostvm.ForMicrovm(mvm)).To(Equal(hostvm.Identity{Namespace: "ns1", Name: "mvm1"}))
+
+	mvm.Spec.HostVMNamespace = "cluster-a"
+	g.Expect(hostvm.ForMicrovm(mvm)).To(Equal(hostvm.Identity{Namespace: "cluster-a", Name: "mvm1"}))
+
+	mvm.Spec.HostVMName = "web-0"
+	g.Expect(hostvm.ForMicrovm(mvm)).To(Equal(hostvm.Identity{Namespace: "cluster-a", Name: "web-0"}))
+}
+
+func TestIdentity_Validate(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(hostvm.Identity{Namespace: "ns1", Name: "web.example-0"}.Validate()).To(Succeed())
+	g.Expect(hostvm.Identity{Namespace: "ns.1", Name: "mvm1"}.Validate()).NotTo(Succeed())
+	g.Expect(hostvm.Identity{Namespace: "ns1", Name: "Web_0"}.Validate()).NotTo(Succeed())
+	g.Expect(hostvm.Identity{Namespace: "ns1"}.Validate()).NotTo(Succeed())
+}
+
+func existingVM(owner string, state flintlocktypes.MicroVMStatus_MicroVMState) *flintlocktypes.MicroVM {
+	return &flintlocktypes.MicroVM{
+		Spec: &flintlocktypes.MicroVMSpec{
+			Id:        "web-0",
+			Namespace: "cluster-a",
+			Uid:       pointer.String("existing-vm"),
+			Labels:    map[string]string{infrav1.MicrovmUIDLabel: owner},
+		},
+		Status: &flintlocktypes.MicroVMStatus{State: state},
+	}
+}
+
+func TestClient(t *testing.T) {
+	id := hostvm.Identity{Namespace: "cluster-a", Name: "web-0"}
+
+	tt := []struct {
+		name     string
+		existing []*flintlocktypes.MicroVM
+		listErr  error
+		expected func(*WithT, *flintlockv1.CreateMicroVMResponse, error, *fakes.FakeClient)
+	}{
+		{
+			name: "creates the vm with the identity",
+			expected: func(g *WithT, _ *flintlockv1.CreateMicroVMResponse, err error, fc *fakes.FakeClient) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fc.CreateMicroVMCallCount()).To(Equal(1))
+
+				_, req, _ := fc.CreateMicroVMArgsForCall(0)
+				g.Expect(req.Microvm.Namespace).To(Equal("cluster-a"))
+				g.Expect(req.Microvm.Id).To(Equal("web-0"))
+			},
+		},
+		{
+			name: "ignores vms which the host did not filter out",
+			existing: []*flintlocktypes.MicroVM{
+				{Spec: &flintlocktypes.MicroVMSpec{Id: "web-1", Namespace: "cluster-a"}},
+			},
+			expected: func(g *WithT, _ *flintlockv1.CreateMicroVMResponse, err error, fc *fakes.FakeClient) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fc.CreateMicroVMCallCount()).To(Equal(1))
+			},
+		},
+		{
+			name:     "refuses to create over the vm of another microvm",
+			existing: []*flintlocktypes.MicroVM{existingVM("other-uid", flintlocktypes.MicroVMStatus_CREATED)},
+			expected: func(g *WithT, _ *flintlockv1.CreateMicroVMResponse, err error, fc *fakes.FakeClient) {
+				g.Expect(errors.Is(err, hostvm.ErrNameConflict)).To(BeTrue())
+				g.Expect(err.Error()).To(ContainSubstring("cluster-a/web-0"))
+				g.Expect(fc.CreateMicroVMCallCount()).To(BeZero())
+			},
+		},
+		{
+			name:     "returns the vm the microvm created before",
+			existing: []*flintlocktypes.MicroVM{existingVM("mvm-uid", flintlocktypes.MicroVMStatus_PENDING)},
+			expected: func(g *WithT, resp *flintlockv1.CreateMicroVMResponse, err error, fc *fakes.FakeClient) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(resp.Microvm.Spec.GetUid()).To(Equal("existing-vm"))
+				g.Expect(fc.CreateMicroVMCallCount()).To(BeZero())
+			},
+		},
+		{
+			name:     "waits for the previous vm of the microvm to be deleted",
+			existing: []*flintlocktypes.MicroVM{existingVM("mvm-uid", flintlocktypes.MicroVMStatus_DELETING)},
+			expected: func(g *WithT, _ *flintlockv1.CreateMicroVMResponse, err error, fc *fakes.FakeClient) {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(errors.Is(err, hostvm.ErrNameConflict)).To(BeFalse())
+				g.Expect(fc.CreateMicroVMCallCount()).To(BeZero())
+			},
+		},
+		{
+			name:    "does not create the vm when the host cannot be checked",
+			listErr: errors.New("host is unavailable"),
+			expected: func(g *WithT, _ *flintlockv1.CreateMicroVMResponse, err error, fc *fakes.FakeClient) {
+				g.Expect(err).To(MatchError(ContainSubstring("host is unavailable")))
+				g.Expect(fc.CreateMicroVMCallCount()).To(BeZero())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			fakeAPIClient := &fakes.FakeClient{}
+			fakeAPIClient.ListMicroVMsReturns(&flintlockv1.ListMicroVMsResponse{Microvm: tc.existing}, tc.listErr)
+
+			client := hostvm.Client(fakeAPIClient, id, "mvm-uid")
+
+			resp, err := client.CreateMicroVM(context.TODO(), &flintlockv1.CreateMicroVMRequest{
+				Microvm: &flintlocktypes.MicroVMSpec{Id: "mvm1", Namespace: "ns1"},
+			})
+			tc.expected(g, resp, err, fakeAPIClient)
+		})
+	}
+}
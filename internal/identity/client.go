@@ -0,0 +1,112 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package identity
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flgrpc "github.com/weaveworks-liquidmetal/flintlock/client/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// ClientConfig is how to connect to a host whose identity is pinned.
+type ClientConfig struct {
+	// Fingerprint is the fingerprint the host must present.
+	Fingerprint string
+	// TLS holds the client certificate and the CA of the host.
+	TLS *flclient.TLSConfig
+	// BasicAuthToken authenticates every call when it is set.
+	BasicAuthToken string
+	// Proxy is the proxy the host is reached through, if any.
+	Proxy *flclient.Proxy
+}
+
+// PinnedFactoryFunc returns a flintlock client factory which connects as
+// flclient.NewFlintlockClient does, but which also fails every TLS handshake
+// in which the host does not present the certificate with the fingerprint of
+// cfg. The host is checked on each connection the client makes, rather than
+// only when it is probed. The options the factory is called with are ignored,
+// as cfg holds them.
+func PinnedFactoryFunc(cfg ClientConfig) flclient.FactoryFunc {
+	return func(address string, _ ...flclient.Options) (flclient.Client, error) {
+		if cfg.TLS == nil {
+			return nil, errPinnedPlaintext
+		}
+
+		certificate, err := tls.X509KeyPair(cfg.TLS.Cert, cfg.TLS.Key)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+
+		capool := x509.NewCertPool()
+		if !capool.AppendCertsFromPEM(cfg.TLS.CACert) {
+			return nil, errInvalidCACert
+		}
+
+		dialOpts := []grpc.DialOption{
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				MinVersion:            tls.VersionTLS13,
+				Certificates:          []tls.Certificate{certificate},
+				RootCAs:               capool,
+				VerifyPeerCertificate: VerifyFingerprint(cfg.Fingerprint),
+			})),
+		}
+
+		if cfg.BasicAuthToken != "" {
+			dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(flclient.Basic(cfg.BasicAuthToken, true)))
+		}
+
+		if cfg.Proxy != nil {
+			proxyURL, err := url.Parse(cfg.Proxy.Endpoint)
+			if err != nil {
+				return nil, fmt.Errorf("parsing proxy server url %s: %w", cfg.Proxy.Endpoint, err)
+			}
+
+			dialOpts = append(dialOpts, flgrpc.WithProxy(proxyURL))
+		}
+
+		conn, err := grpc.Dial(address, dialOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating grpc connection: %w", err)
+		}
+
+		return &pinnedClient{flintlockv1.NewMicroVMClient(conn), conn}, nil
+	}
+}
+
+// VerifyFingerprint returns a check for tls.Config.VerifyPeerCertificate which
+// fails unless the leaf certificate the host presents has fingerprint.
+func VerifyFingerprint(fingerprint string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errNoPeerCertificate
+		}
+
+		sum := sha256.Sum256(rawCerts[0])
+
+		if presented := fingerprintPrefix + hex.EncodeToString(sum[:]); presented != fingerprint {
+			return fmt.Errorf("%w: host presented %s, expected %s", ErrFingerprintMismatch, presented, fingerprint)
+		}
+
+		return nil
+	}
+}
+
+type pinnedClient struct {
+	flintlockv1.MicroVMClient
+
+	conn *grpc.ClientConn
+}
+
+func (c *pinnedClient) Close() {
+	c.conn.Close()
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package identity_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+)
+
+func TestVerifyFingerprint(t *testing.T) {
+	g := NewWithT(t)
+
+	cert := &x509.Certificate{Raw: []byte("certificate")}
+	fingerprint := identity.FromCertificate(cert).Fingerprint
+
+	g.Expect(identity.VerifyFingerprint(fingerprint)([][]byte{cert.Raw}, nil)).To(Succeed())
+	g.Expect(identity.VerifyFingerprint(fingerprint)([][]byte{[]byte("other")}, nil)).
+		To(MatchError(identity.ErrFingerprintMismatch))
+	g.Expect(identity.VerifyFingerprint(fingerprint)(nil, nil)).NotTo(Succeed())
+}
+
+func TestPinnedFactoryFunc(t *testing.T) {
+	g := NewWithT(t)
+
+	tlsConfig, cert := newTestCertificate(t)
+	address := serveFlintlock(t, cert)
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		expected    codes.Code
+	}{
+		{
+			name:        "the trusted host is called",
+			fingerprint: identity.FromCertificate(cert.Leaf).Fingerprint,
+			expected:    codes.Unimplemented,
+		},
+		{
+			name:        "another certificate fails the handshake",
+			fingerprint: identity.FromCertificate(&x509.Certificate{Raw: []byte("other")}).Fingerprint,
+			expected:    codes.Unavailable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			client, err := identity.PinnedFactoryFunc(identity.ClientConfig{
+				Fingerprint: tc.fingerprint,
+				TLS:         tlsConfig,
+			})(address)
+			g.Expect(err).NotTo(HaveOccurred())
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+			defer cancel()
+
+			_, err = client.GetMicroVM(ctx, &flintlockv1.GetMicroVMRequest{Uid: "uid"})
+			g.Expect(status.Code(err)).To(Equal(tc.expected))
+		})
+	}
+
+	_, err := identity.PinnedFactoryFunc(identity.ClientConfig{Fingerprint: "sha256:00"})(address)
+	g.Expect(err).To(HaveOccurred(), "a pinned host is never connected to without tls")
+}
+
+// newTestCertificate returns a self-signed certificate for 127.0.0.1, both as
+// the config a client is given and as what a server presents.
+func newTestCertificate(t *testing.T) (*flclient.TLSConfig, tls.Certificate) {
+	g := NewWithT(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "flintlock"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	cert.Leaf, err = x509.ParseCertificate(der)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return &flclient.TLSConfig{Cert: certPEM, Key: keyPEM, CACert: certPEM}, cert
+}
+
+// serveFlintlock serves a flintlock API which implements no calls over tls,
+// and returns its address.
+func serveFlintlock(t *testing.T, cert tls.Certificate) string {
+	g := NewWithT(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		MinVersion:   tls.VersionTLS13,
+		Certificates: []tls.Certificate{cert},
+	})))
+	flintlockv1.RegisterMicroVMServer(server, &flintlockv1.UnimplementedMicroVMServer{})
+
+	go server.Serve(listener) //nolint:errcheck // stopped with the test
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
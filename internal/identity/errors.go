@@ -0,0 +1,21 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package identity
+
+import "errors"
+
+var (
+	// ErrPlaintext is returned when the host does not serve TLS.
+	ErrPlaintext = errors.New("host does not serve tls")
+	// ErrUnreachable is returned when no connection could be made to the host,
+	// or it did not answer in time.
+	ErrUnreachable = errors.New("host is unreachable")
+	// ErrFingerprintMismatch is returned when a host whose identity is pinned
+	// presents another certificate.
+	ErrFingerprintMismatch = errors.New("host certificate does not match the trusted fingerprint")
+
+	errNoPeerCertificate = errors.New("host presented no certificate")
+	errPinnedPlaintext   = errors.New("a pinned host must be connected to with tls")
+	errInvalidCACert     = errors.New("could not add ca cert to pool")
+)
@@ -0,0 +1,118 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package identity reads the TLS identity a flintlock host presents, so that
+// it can be trusted on first use.
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	fingerprintPrefix = "sha256:"
+	spiffeScheme      = "spiffe"
+
+	defaultTimeout = 5 * time.Second
+)
+
+// Identity is what a host presents during the TLS handshake.
+type Identity struct {
+	// Fingerprint is the SHA-256 fingerprint of the leaf certificate.
+	Fingerprint string
+	// SPIFFEID is the first spiffe URI SAN of the leaf certificate, if any.
+	SPIFFEID string
+}
+
+// FromCertificate returns the identity of cert.
+func FromCertificate(cert *x509.Certificate) Identity {
+	sum := sha256.Sum256(cert.Raw)
+
+	id := Identity{
+		Fingerprint: fingerprintPrefix + hex.EncodeToString(sum[:]),
+	}
+
+	for _, uri := range cert.URIs {
+		if uri.Scheme == spiffeScheme {
+			id.SPIFFEID = uri.String()
+
+			break
+		}
+	}
+
+	return id
+}
+
+// Prober reads the identity of the host at an endpoint.
+type Prober interface {
+	Probe(ctx context.Context, endpoint string) (Identity, error)
+}
+
+// TLSProber completes a TLS handshake with the host and reads its leaf
+// certificate. The certificate is not verified: the handshake still proves
+// the host holds the matching private key, and the fingerprint is compared
+// against the trusted one by the caller.
+type TLSProber struct {
+	// Timeout bounds the dial and handshake. Defaults to 5s.
+	Timeout time.Duration
+}
+
+// NewProber returns a TLSProber with the default timeout.
+func NewProber() Prober {
+	return &TLSProber{Timeout: defaultTimeout}
+}
+
+// Probe returns the identity of the host at endpoint, ErrPlaintext if the
+// host answered without TLS, or ErrUnreachable if it did not answer at all.
+func (p *TLSProber) Probe(ctx context.Context, endpoint string) (Identity, error) {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	rawConn, err := (&net.Dialer{}).DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return Identity{}, fmt.Errorf("probing %s: %w: %s", endpoint, ErrUnreachable, err)
+	}
+
+	conn := tls.Client(rawConn, &tls.Config{
+		InsecureSkipVerify: true, //nolint:gosec // the fingerprint is verified by the caller
+		MinVersion:         tls.VersionTLS12,
+		NextProtos:         []string{"h2", "http/1.1"},
+	})
+	defer conn.Close()
+
+	if err := conn.HandshakeContext(ctx); err != nil {
+		var (
+			recordErr tls.RecordHeaderError
+			netErr    net.Error
+		)
+
+		switch {
+		case errors.As(err, &recordErr):
+			return Identity{}, fmt.Errorf("probing %s: %w", endpoint, ErrPlaintext)
+		case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+			return Identity{}, fmt.Errorf("probing %s: %w: %s", endpoint, ErrUnreachable, err)
+		default:
+			return Identity{}, fmt.Errorf("probing %s: %w", endpoint, err)
+		}
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return Identity{}, fmt.Errorf("probing %s: %w", endpoint, errNoPeerCertificate)
+	}
+
+	return FromCertificate(certs[0]), nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package identity_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+)
+
+func TestFromCertificate(t *testing.T) {
+	g := NewWithT(t)
+
+	spiffeID, _ := url.Parse("spiffe://liquid-metal.io/host/1")
+	otherURI, _ := url.Parse("https://example.com")
+
+	cert := &x509.Certificate{
+		Raw:  []byte("certificate"),
+		URIs: []*url.URL{otherURI, spiffeID},
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+
+	id := identity.FromCertificate(cert)
+	g.Expect(id.Fingerprint).To(Equal("sha256:" + hex.EncodeToString(sum[:])))
+	g.Expect(id.SPIFFEID).To(Equal("spiffe://liquid-metal.io/host/1"))
+}
+
+func TestTLSProber_Probe(t *testing.T) {
+	g := NewWithT(t)
+
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	id, err := identity.NewProber().Probe(context.TODO(), server.Listener.Addr().String())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(id).To(Equal(identity.FromCertificate(server.Certificate())))
+}
+
+func TestTLSProber_Probe_Plaintext(t *testing.T) {
+	g := NewWithT(t)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := identity.NewProber().Probe(context.TODO(), strings.TrimPrefix(server.URL, "http://"))
+	g.Expect(err).To(MatchError(identity.ErrPlaintext))
+}
+
+func TestTLSProber_Probe_Unreachable(t *testing.T) {
+	g := NewWithT(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).NotTo(HaveOccurred())
+	addr := listener.Addr().String()
+	g.Expect(listener.Close()).To(Succeed())
+
+	_, err = identity.NewProber().Probe(context.TODO(), addr)
+	g.Expect(err).To(MatchError(identity.ErrUnreachable))
+}
+
+func TestTLSProber_Probe_Silent(t *testing.T) {
+	g := NewWithT(t)
+
+	// accepts connections but never answers the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer listener.Close()
+
+	prober := &identity.TLSProber{Timeout: 100 * time.Millisecond}
+	_, err = prober.Probe(context.TODO(), listener.Addr().String())
+	g.Expect(err).To(MatchError(identity.ErrUnreachable))
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package imagepin pins the images of a Microvm spec to the digests their tags
+// point at, so that VMs created at different times boot the same images.
+package imagepin
+
+import (
+	"context"
+
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/oci"
+)
+
+// Images returns the kernel, initrd and volume images of spec, each only once.
+func Images(spec *microvm.VMSpec) []string {
+	images := []string{}
+	seen := map[string]bool{}
+
+	add := func(image string) {
+		if image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+
+	add(spec.Kernel.Image)
+
+	if spec.Initrd != nil {
+		add(spec.Initrd.Image)
+	}
+
+	add(spec.RootVolume.Image)
+
+	for _, volume := range spec.AdditionalVolumes {
+		add(volume.Image)
+	}
+
+	return images
+}
+
+// Unpinned returns the images of spec which are not given by digest.
+func Unpinned(spec *microvm.VMSpec) []string {
+	unpinned := []string{}
+
+	for _, image := range Images(spec) {
+		if !oci.IsPinned(image) {
+			unpinned = append(unpinned, image)
+		}
+	}
+
+	return unpinned
+}
+
+// Resolve returns the digests the images of spec which are given by tag
+// resolve to, as image@digest by image. Images already in resolved keep the
+// digest they were resolved to before, and images no longer in spec are
+// dropped. The images resolved before an error are returned along with it.
+func Resolve(
+	ctx context.Context,
+	resolver oci.Resolver,
+	spec *microvm.VMSpec,
+	resolved map[string]string,
+) (map[string]string, error) {
+	out := map[string]string{}
+
+	for _, image := range Unpinned(spec) {
+		if pinned, ok := resolved[image]; ok {
+			out[image] = pinned
+
+			continue
+		}
+
+		pinned, err := resolver.Resolve(ctx, image)
+		if err != nil {
+			return out, err
+		}
+
+		out[image] = pinned
+	}
+
+	return out, nil
+}
+
+// Pin replaces each image of spec which is in resolved with its digest.
+func Pin(spec *microvm.VMSpec, resolved map[string]string) {
+	pin := func(image *string) {
+		if pinned, ok := resolved[*image]; ok {
+			*image = pinned
+		}
+	}
+
+	pin(&spec.Kernel.Image)
+
+	if spec.Initrd != nil {
+		pin(&spec.Initrd.Image)
+	}
+
+	pin(&spec.RootVolume.Image)
+
+	for i := range spec.AdditionalVolumes {
+		pin(&spec.AdditionalVolumes[i].Image)
+	}
+}
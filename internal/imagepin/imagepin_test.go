@@ -0,0 +1,88 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package imagepin_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/imagepin"
+)
+
+var testDigest = "sha256:" + strings.Repeat("a", 64)
+
+type fakeResolver struct {
+	calls []string
+	err   error
+}
+
+func (r *fakeResolver) Resolve(_ context.Context, image string) (string, error) {
+	r.calls = append(r.calls, image)
+
+	if r.err != nil {
+		return "", r.err
+	}
+
+	return image + "@" + testDigest, nil
+}
+
+func newSpec() *microvm.VMSpec {
+	return &microvm.VMSpec{
+		Kernel:     microvm.ContainerFileSource{Image: "ghcr.io/org/kernel:5.10"},
+		Initrd:     &microvm.ContainerFileSource{Image: "ghcr.io/org/kernel:5.10"},
+		RootVolume: microvm.Volume{ID: "root", Image: "ghcr.io/org/ubuntu:22.04"},
+		AdditionalVolumes: []microvm.Volume{
+			{ID: "data", Image: "ghcr.io/org/data@" + testDigest},
+		},
+	}
+}
+
+func TestUnpinned(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(imagepin.Images(newSpec())).To(HaveLen(3), "Expected images to be listed once")
+	g.Expect(imagepin.Unpinned(newSpec())).To(Equal([]string{"ghcr.io/org/kernel:5.10", "ghcr.io/org/ubuntu:22.04"}))
+}
+
+func TestResolve(t *testing.T) {
+	g := NewWithT(t)
+
+	resolver := &fakeResolver{}
+	previous := map[string]string{
+		"ghcr.io/org/kernel:5.10": "ghcr.io/org/kernel:5.10@sha256:old",
+		"ghcr.io/org/removed:1":   "ghcr.io/org/removed:1@sha256:old",
+	}
+
+	resolved, err := imagepin.Resolve(context.TODO(), resolver, newSpec(), previous)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolver.calls).To(Equal([]string{"ghcr.io/org/ubuntu:22.04"}), "Expected only new tags to be resolved")
+	g.Expect(resolved).To(Equal(map[string]string{
+		"ghcr.io/org/kernel:5.10":  "ghcr.io/org/kernel:5.10@sha256:old",
+		"ghcr.io/org/ubuntu:22.04": "ghcr.io/org/ubuntu:22.04@" + testDigest,
+	}))
+
+	_, err = imagepin.Resolve(context.TODO(), &fakeResolver{err: errors.New("registry down")}, newSpec(), nil)
+	g.Expect(err).To(MatchError("registry down"))
+}
+
+func TestPin(t *testing.T) {
+	g := NewWithT(t)
+
+	spec := newSpec()
+	imagepin.Pin(spec, map[string]string{
+		"ghcr.io/org/kernel:5.10":  "ghcr.io/org/kernel:5.10@" + testDigest,
+		"ghcr.io/org/ubuntu:22.04": "ghcr.io/org/ubuntu:22.04@" + testDigest,
+	})
+
+	g.Expect(spec.Kernel.Image).To(Equal("ghcr.io/org/kernel:5.10@" + testDigest))
+	g.Expect(spec.Initrd.Image).To(Equal("ghcr.io/org/kernel:5.10@" + testDigest))
+	g.Expect(spec.RootVolume.Image).To(Equal("ghcr.io/org/ubuntu:22.04@" + testDigest))
+	g.Expect(spec.AdditionalVolumes[0].Image).To(Equal("ghcr.io/org/data@" + testDigest))
+	g.Expect(imagepin.Unpinned(spec)).To(BeEmpty())
+}
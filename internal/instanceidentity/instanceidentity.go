@@ -0,0 +1,110 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package instanceidentity adds an instance identity document to the metadata
+// of each VM, so that workloads in the guest can find out where they were
+// placed from the metadata service, as they would on a cloud instance.
+package instanceidentity
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+)
+
+// MetadataKey is the metadata item the document is served under.
+const MetadataKey = "instance-identity"
+
+// Document describes where a Microvm was placed and what created it.
+type Document struct {
+	// Namespace is the namespace of the Microvm.
+	Namespace string `json:"namespace"`
+	// Name is the name of the Microvm.
+	Name string `json:"name"`
+	// UID is the UID of the Microvm, which is also the instance ID.
+	UID string `json:"uid"`
+	// Host is the endpoint of the host the VM runs on.
+	Host string `json:"host"`
+	// Deployment is the MicrovmDeployment the Microvm was created for, if any.
+	Deployment string `json:"deployment,omitempty"`
+	// ReplicaSet is the MicrovmReplicaSet which created the Microvm, if any.
+	ReplicaSet string `json:"replicaSet,omitempty"`
+	// ReplicaIndex is the index of the Microvm within its MicrovmReplicaSet.
+	ReplicaIndex *int `json:"replicaIndex,omitempty"`
+	// OperatorVersion is the version of the operator which created the VM.
+	OperatorVersion string `json:"operatorVersion"`
+}
+
+// ForMicrovm returns the document of mvm, read from its provenance labels and
+// replica index annotation.
+func ForMicrovm(mvm *infrav1.Microvm) Document {
+	doc := Document{
+		Namespace:       mvm.Namespace,
+		Name:            mvm.Name,
+		UID:             string(mvm.UID),
+		Host:            mvm.Spec.Host.Endpoint,
+		Deployment:      mvm.Labels[infrav1.MicrovmDeploymentNameLabel],
+		ReplicaSet:      mvm.Labels[infrav1.MicrovmReplicaSetNameLabel],
+		OperatorVersion: defaults.Version,
+	}
+
+	if index, err := strconv.Atoi(mvm.Annotations[infrav1.ReplicaIndexAnnotation]); err == nil {
+		doc.ReplicaIndex = &index
+	}
+
+	return doc
+}
+
+// Encode returns the document as base64 encoded JSON, as metadata items are
+// passed to flintlock.
+func (d Document) Encode() (string, error) {
+	out, err := json.Marshal(d)
+	if err != nil {
+		return "", fmt.Errorf("marshalling instance identity: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Client wraps client so that each VM it creates has doc in its metadata.
+func Client(client flclient.Client, doc Document) flclient.Client {
+	return &documentClient{Client: client, doc: doc}
+}
+
+// documentClient is a flintlock client which adds the instance identity
+// document to the metadata of the VMs it creates.
+type documentClient struct {
+	flclient.Client
+
+	doc Document
+}
+
+func (c *documentClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	if spec := in.GetMicrovm(); spec != nil {
+		encoded, err := c.doc.Encode()
+		if err != nil {
+			return nil, err
+		}
+
+		if spec.Metadata == nil {
+			spec.Metadata = map[string]string{}
+		}
+
+		spec.Metadata[MetadataKey] = encoded
+	}
+
+	return c.Client.CreateMicroVM(ctx, in, opts...)
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package instanceidentity_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/instanceidentity"
+)
+
+func TestForMicrovm(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mvm1",
+			Namespace: "ns1",
+			UID:       "abcdef",
+			Labels: map[string]string{
+				infrav1.MicrovmDeploymentNameLabel: "mvmd1",
+				infrav1.MicrovmReplicaSetNameLabel: "rs1",
+			},
+			Annotations: map[string]string{infrav1.ReplicaIndexAnnotation: "2"},
+		},
+		Spec: infrav1.MicrovmSpec{Host: microvm.Host{Endpoint: "127.0.0.1:9090"}},
+	}
+
+	doc := instanceidentity.ForMicrovm(mvm)
+	g.Expect(doc.Namespace).To(Equal("ns1"))
+	g.Expect(doc.Name).To(Equal("mvm1"))
+	g.Expect(doc.UID).To(Equal("abcdef"))
+	g.Expect(doc.Host).To(Equal("127.0.0.1:9090"))
+	g.Expect(doc.Deployment).To(Equal("mvmd1"))
+	g.Expect(doc.ReplicaSet).To(Equal("rs1"))
+	g.Expect(doc.ReplicaIndex).NotTo(BeNil())
+	g.Expect(*doc.ReplicaIndex).To(Equal(2))
+	g.Expect(doc.OperatorVersion).To(Equal(defaults.Version))
+
+	// a microvm created on its own has no placement beyond its host
+	standalone := instanceidentity.ForMicrovm(&infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: "mvm2"}})
+	g.Expect(standalone.Deployment).To(BeEmpty())
+	g.Expect(standalone.ReplicaSet).To(BeEmpty())
+	g.Expect(standalone.ReplicaIndex).To(BeNil())
+}
+
+func TestClient(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeAPIClient := &fakes.FakeClient{}
+	index := 1
+	client := instanceidentity.Client(fakeAPIClient, instanceidentity.Document{
+		Namespace:    "ns1",
+		Name:         "mvm1",
+		ReplicaIndex: &index,
+	})
+
+	_, err := client.CreateMicroVM(context.TODO(), &flintlockv1.CreateMicroVMRequest{
+		Microvm: &flintlocktypes.MicroVMSpec{
+			Metadata: map[string]string{"meta-data": "existing"},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, req, _ := fakeAPIClient.CreateMicroVMArgsForCall(0)
+	g.Expect(req.Microvm.Metadata).To(HaveKeyWithValue("meta-data", "existing"))
+
+	data, err := base64.StdEncoding.DecodeString(req.Microvm.Metadata[instanceidentity.MetadataKey])
+	g.Expect(err).NotTo(HaveOccurred())
+
+	doc := instanceidentity.Document{}
+	g.Expect(json.Unmarshal(data, &doc)).To(Succeed())
+	g.Expect(doc.Name).To(Equal("mvm1"))
+	g.Expect(*doc.ReplicaIndex).To(Equal(1))
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package ipam
+
+import (
+	"context"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	"google.golang.org/grpc"
+)
+
+// Client wraps client so that the network interfaces of each VM it creates
+// are configured with the leases returned by leases, keyed by guest device
+// name. Flintlock renders them into the network config of the guest. The
+// leases are read as each VM is created, as they are only known once the
+// addresses have been allocated.
+func Client(client flclient.Client, leases func() map[string]Lease) flclient.Client {
+	return &leaseClient{Client: client, leases: leases}
+}
+
+// leaseClient is a flintlock client which configures the network interfaces
+// of the VMs it creates with their leases.
+type leaseClient struct {
+	flclient.Client
+
+	leases func() map[string]Lease
+}
+
+func (c *leaseClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	leases := c.leases()
+
+	for _, iface := range in.GetMicrovm().GetInterfaces() {
+		lease, ok := leases[iface.DeviceId]
+		if !ok {
+			continue
+		}
+
+		address := &flintlocktypes.StaticAddress{
+			Address:     lease.Address,
+			Nameservers: lease.Nameservers,
+		}
+
+		if lease.Gateway != "" {
+			gateway := lease.Gateway
+			address.Gateway = &gateway
+		}
+
+		iface.Address = address
+	}
+
+	return c.Client.CreateMicroVM(ctx, in, opts...)
+}
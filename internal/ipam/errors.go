@@ -0,0 +1,17 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package ipam
+
+import "errors"
+
+var (
+	// ErrExhausted is returned when every address of a pool has been allocated.
+	ErrExhausted = errors.New("every address of the pool has been allocated")
+
+	errInvalidSubnet     = errors.New("invalid subnet")
+	errInvalidAddress    = errors.New("invalid ip address")
+	errInvalidRange      = errors.New("invalid ip range")
+	errInvalidGateway    = errors.New("invalid gateway")
+	errInvalidNameserver = errors.New("invalid nameserver")
+)
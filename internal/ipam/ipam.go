@@ -0,0 +1,261 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package ipam allocates the IP addresses of Microvm network interfaces from
+// MicrovmIPPools, and configures them in the guest along with the gateway and
+// nameservers of their pool.
+package ipam
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// Lease is an address given to a network interface, along with how the guest
+// reaches the rest of the network through it.
+type Lease struct {
+	// Address is the address in CIDR notation, with the prefix length of the
+	// subnet of the pool.
+	Address string
+	// Gateway is the default gateway, if the pool has one.
+	Gateway string
+	// Nameservers are the DNS servers of the pool.
+	Nameservers []string
+}
+
+// Pool is the addresses a MicrovmIPPool holds.
+type Pool struct {
+	subnet      netip.Prefix
+	first       netip.Addr
+	last        netip.Addr
+	gateway     netip.Addr
+	nameservers []string
+}
+
+// Parse returns the addresses of spec, or an error if they are not valid.
+func Parse(spec infrav1.MicrovmIPPoolSpec) (*Pool, error) {
+	subnet, err := netip.ParsePrefix(spec.Subnet)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %s", errInvalidSubnet, spec.Subnet, err)
+	}
+
+	pool := &Pool{subnet: subnet.Masked()}
+
+	if spec.Range != nil {
+		if err := pool.parseRange(spec.Range); err != nil {
+			return nil, err
+		}
+	} else {
+		pool.first = pool.subnet.Addr().Next()
+		pool.last = lastAddr(pool.subnet)
+
+		if pool.subnet.Addr().Is4() {
+			pool.last = pool.last.Prev()
+		}
+
+		if !pool.subnet.Contains(pool.first) || pool.last.Less(pool.first) {
+			return nil, fmt.Errorf("%w: %s has no addresses to allocate", errInvalidSubnet, spec.Subnet)
+		}
+	}
+
+	if spec.Gateway != "" {
+		gateway, err := netip.ParseAddr(spec.Gateway)
+		if err != nil || !pool.subnet.Contains(gateway) {
+			return nil, fmt.Errorf("%w: %q is not in %s", errInvalidGateway, spec.Gateway, pool.subnet)
+		}
+
+		pool.gateway = gateway
+	}
+
+	for _, nameserver := range spec.Nameservers {
+		if _, err := netip.ParseAddr(nameserver); err != nil {
+			return nil, fmt.Errorf("%w: %q", errInvalidNameserver, nameserver)
+		}
+	}
+
+	pool.nameservers = spec.Nameservers
+
+	return pool, nil
+}
+
+func (p *Pool) parseRange(r *infrav1.IPRange) error {
+	first, err := p.parseAddr(r.Start)
+	if err != nil {
+		return err
+	}
+
+	last, err := p.parseAddr(r.End)
+	if err != nil {
+		return err
+	}
+
+	if last.Less(first) {
+		return fmt.Errorf("%w: %s is before %s", errInvalidRange, r.End, r.Start)
+	}
+
+	p.first, p.last = first, last
+
+	return nil
+}
+
+// parseAddr returns an address of the subnet of the pool.
+func (p *Pool) parseAddr(address string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("%w: %q", errInvalidAddress, address)
+	}
+
+	if !p.subnet.Contains(addr) {
+		return netip.Addr{}, fmt.Errorf("%w: %s is not in %s", errInvalidRange, address, p.subnet)
+	}
+
+	return addr, nil
+}
+
+// Size returns how many addresses the pool holds. A pool which holds more
+// than an int64 can count, as an IPv6 one may, is given the largest int64.
+func (p *Pool) Size() int64 {
+	size := new(big.Int).Sub(new(big.Int).SetBytes(p.last.AsSlice()), new(big.Int).SetBytes(p.first.AsSlice()))
+	size.Add(size, big.NewInt(1))
+
+	if p.gateway.IsValid() && !p.gateway.Less(p.first) && !p.last.Less(p.gateway) {
+		size.Sub(size, big.NewInt(1))
+	}
+
+	if !size.IsInt64() {
+		return math.MaxInt64
+	}
+
+	return size.Int64()
+}
+
+// next returns the first address of the pool which is not allocated.
+func (p *Pool) next(allocated map[netip.Addr]bool) (netip.Addr, bool) {
+	for addr := p.first; ; addr = addr.Next() {
+		if addr != p.gateway && !allocated[addr] {
+			return addr, true
+		}
+
+		if addr == p.last {
+			return netip.Addr{}, false
+		}
+	}
+}
+
+// lease returns the lease of an address of the pool.
+func (p *Pool) lease(addr netip.Addr) Lease {
+	lease := Lease{
+		Address:     netip.PrefixFrom(addr, p.subnet.Bits()).String(),
+		Nameservers: p.nameservers,
+	}
+
+	if p.gateway.IsValid() {
+		lease.Gateway = p.gateway.String()
+	}
+
+	return lease
+}
+
+// Lookup returns the address given to the network interface iface of the
+// Microvm with uid, and false if it has not been given one.
+func Lookup(pool *infrav1.MicrovmIPPool, uid types.UID, iface string) (string, bool) {
+	for _, allocation := range pool.Spec.Allocations {
+		if allocation.UID == uid && allocation.Interface == iface {
+			return allocation.Address, true
+		}
+	}
+
+	return "", false
+}
+
+// Allocate gives the network interface iface of mvm an address of pool, which
+// is recorded in the spec of pool, and returns its lease. An interface which
+// was already given an address is given the same one again. ErrExhausted is
+// returned when there are no addresses left.
+func Allocate(pool *infrav1.MicrovmIPPool, mvm *infrav1.Microvm, iface string) (Lease, error) {
+	parsed, err := Parse(pool.Spec)
+	if err != nil {
+		return Lease{}, err
+	}
+
+	if address, ok := Lookup(pool, mvm.UID, iface); ok {
+		addr, err := netip.ParseAddr(address)
+		if err != nil {
+			return Lease{}, fmt.Errorf("%w: %q", errInvalidAddress, address)
+		}
+
+		return parsed.lease(addr), nil
+	}
+
+	allocated := map[netip.Addr]bool{}
+
+	for _, allocation := range pool.Spec.Allocations {
+		if addr, err := netip.ParseAddr(allocation.Address); err == nil {
+			allocated[addr] = true
+		}
+	}
+
+	addr, ok := parsed.next(allocated)
+	if !ok {
+		return Lease{}, ErrExhausted
+	}
+
+	pool.Spec.Allocations = append(pool.Spec.Allocations, infrav1.IPAllocation{
+		Address:   addr.String(),
+		Microvm:   mvm.Name,
+		UID:       mvm.UID,
+		Interface: iface,
+	})
+
+	return parsed.lease(addr), nil
+}
+
+// Release removes the addresses given to the Microvm with uid from the spec
+// of pool, and returns true if there were any.
+func Release(pool *infrav1.MicrovmIPPool, uid types.UID) bool {
+	return Prune(pool, func(owner types.UID) bool { return owner != uid }) > 0
+}
+
+// Prune removes the addresses given to Microvms for which live returns false
+// from the spec of pool, and returns how many were removed.
+func Prune(pool *infrav1.MicrovmIPPool, live func(types.UID) bool) int {
+	kept := []infrav1.IPAllocation{}
+
+	for _, allocation := range pool.Spec.Allocations {
+		if live(allocation.UID) {
+			kept = append(kept, allocation)
+		}
+	}
+
+	removed := len(pool.Spec.Allocations) - len(kept)
+	if removed == 0 {
+		return 0
+	}
+
+	if len(kept) == 0 {
+		kept = nil
+	}
+
+	pool.Spec.Allocations = kept
+
+	return removed
+}
+
+// lastAddr returns the last address of prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 1 << (7 - bit%8)
+	}
+
+	addr, _ := netip.AddrFromSlice(bytes)
+
+	return addr
+}
@@ -0,0 +1,218 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package ipam_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/ipam"
+)
+
+func microvm(name string) *infrav1.Microvm {
+	return &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name + "-uid")}}
+}
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		name    string
+		spec    infrav1.MicrovmIPPoolSpec
+		size    int64
+		invalid bool
+	}{
+		{
+			name: "ipv4 subnet without the network and broadcast addresses",
+			spec: infrav1.MicrovmIPPoolSpec{Subnet: "192.168.10.0/24"},
+			size: 254,
+		},
+		{
+			name: "gateway is not counted",
+			spec: infrav1.MicrovmIPPoolSpec{Subnet: "192.168.10.0/24", Gateway: "192.168.10.1"},
+			size: 253,
+		},
+		{
+			name: "range",
+			spec: infrav1.MicrovmIPPoolSpec{
+				Subnet: "192.168.10.0/24",
+				Range:  &infrav1.IPRange{Start: "192.168.10.100", End: "192.168.10.199"},
+			},
+			size: 100,
+		},
+		{
+			name: "subnet is masked",
+			spec: infrav1.MicrovmIPPoolSpec{Subnet: "10.0.0.7/30"},
+			size: 2,
+		},
+		{
+			name: "ipv6",
+			spec: infrav1.MicrovmIPPoolSpec{Subnet: "fd00::/120"},
+			size: 255,
+		},
+		{
+			name: "ipv6 larger than an int64",
+			spec: infrav1.MicrovmIPPoolSpec{Subnet: "fd00::/64"},
+			size: math.MaxInt64,
+		},
+		{
+			name:    "malformed subnet",
+			spec:    infrav1.MicrovmIPPoolSpec{Subnet: "192.168.10.0"},
+			invalid: true,
+		},
+		{
+			name:    "subnet without addresses",
+			spec:    infrav1.MicrovmIPPoolSpec{Subnet: "192.168.10.1/32"},
+			invalid: true,
+		},
+		{
+			name: "range outside the subnet",
+			spec: infrav1.MicrovmIPPoolSpec{
+				Subnet: "192.168.10.0/24",
+				Range:  &infrav1.IPRange{Start: "192.168.10.100", End: "192.168.11.10"},
+			},
+			invalid: true,
+		},
+		{
+			name: "end before start",
+			spec: infrav1.MicrovmIPPoolSpec{
+				Subnet: "192.168.10.0/24",
+				Range:  &infrav1.IPRange{Start: "192.168.10.100", End: "192.168.10.10"},
+			},
+			invalid: true,
+		},
+		{
+			name:    "gateway outside the subnet",
+			spec:    infrav1.MicrovmIPPoolSpec{Subnet: "192.168.10.0/24", Gateway: "192.168.11.1"},
+			invalid: true,
+		},
+		{
+			name:    "malformed nameserver",
+			spec:    infrav1.MicrovmIPPoolSpec{Subnet: "192.168.10.0/24", Nameservers: []string{"dns.local"}},
+			invalid: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			pool, err := ipam.Parse(tc.spec)
+			if tc.invalid {
+				g.Expect(err).To(HaveOccurred())
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pool.Size()).To(Equal(tc.size))
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	g := NewWithT(t)
+
+	pool := &infrav1.MicrovmIPPool{
+		Spec: infrav1.MicrovmIPPoolSpec{
+			Subnet:      "10.0.0.0/29",
+			Gateway:     "10.0.0.2",
+			Nameservers: []string{"10.0.0.2"},
+		},
+	}
+
+	mvm1, mvm2 := microvm("mvm1"), microvm("mvm2")
+
+	lease, err := ipam.Allocate(pool, mvm1, "eth0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lease).To(Equal(ipam.Lease{Address: "10.0.0.1/29", Gateway: "10.0.0.2", Nameservers: []string{"10.0.0.2"}}))
+
+	lease, err = ipam.Allocate(pool, mvm1, "eth1")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lease.Address).To(Equal("10.0.0.3/29"), "Expected the gateway to be skipped")
+
+	lease, err = ipam.Allocate(pool, mvm1, "eth0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lease.Address).To(Equal("10.0.0.1/29"), "Expected an interface to be given the same address again")
+
+	address, ok := ipam.Lookup(pool, mvm1.UID, "eth1")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(address).To(Equal("10.0.0.3"))
+
+	for _, expected := range []string{"10.0.0.4/29", "10.0.0.5/29", "10.0.0.6/29"} {
+		lease, err = ipam.Allocate(pool, microvm(expected), "eth0")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(lease.Address).To(Equal(expected))
+	}
+
+	_, err = ipam.Allocate(pool, mvm2, "eth0")
+	g.Expect(errors.Is(err, ipam.ErrExhausted)).To(BeTrue(), "Expected the broadcast address not to be allocated")
+	g.Expect(pool.Spec.Allocations).To(HaveLen(5))
+
+	g.Expect(ipam.Release(pool, mvm1.UID)).To(BeTrue())
+	g.Expect(ipam.Release(pool, mvm1.UID)).To(BeFalse(), "Expected nothing to be left to release")
+	g.Expect(pool.Spec.Allocations).To(HaveLen(3))
+
+	_, ok = ipam.Lookup(pool, mvm1.UID, "eth1")
+	g.Expect(ok).To(BeFalse())
+
+	lease, err = ipam.Allocate(pool, mvm2, "eth0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lease.Address).To(Equal("10.0.0.1/29"), "Expected a released address to be allocated again")
+}
+
+func TestPrune(t *testing.T) {
+	g := NewWithT(t)
+
+	pool := &infrav1.MicrovmIPPool{Spec: infrav1.MicrovmIPPoolSpec{Subnet: "10.0.0.0/24"}}
+
+	for _, name := range []string{"mvm1", "mvm2", "mvm3"} {
+		_, err := ipam.Allocate(pool, microvm(name), "eth0")
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	live := func(uid types.UID) bool { return uid == "mvm2-uid" }
+
+	g.Expect(ipam.Prune(pool, live)).To(Equal(2))
+	g.Expect(pool.Spec.Allocations).To(HaveLen(1))
+	g.Expect(pool.Spec.Allocations[0].Address).To(Equal("10.0.0.2"))
+
+	g.Expect(ipam.Prune(pool, live)).To(BeZero())
+}
+
+func TestClient(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeClient := &fakes.FakeClient{}
+	leases := map[string]ipam.Lease{
+		"eth0": {Address: "10.0.0.1/24", Gateway: "10.0.0.254", Nameservers: []string{"10.0.0.53"}},
+	}
+
+	client := ipam.Client(fakeClient, func() map[string]ipam.Lease { return leases })
+
+	_, err := client.CreateMicroVM(context.TODO(), &flintlockv1.CreateMicroVMRequest{
+		Microvm: &flintlocktypes.MicroVMSpec{
+			Interfaces: []*flintlocktypes.NetworkInterface{
+				{DeviceId: "eth0"},
+				{DeviceId: "eth1", Address: &flintlocktypes.StaticAddress{Address: "172.16.0.1/16"}},
+			},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, req, _ := fakeClient.CreateMicroVMArgsForCall(0)
+	g.Expect(req.Microvm.Interfaces[0].Address.Address).To(Equal("10.0.0.1/24"))
+	g.Expect(*req.Microvm.Interfaces[0].Address.Gateway).To(Equal("10.0.0.254"))
+	g.Expect(req.Microvm.Interfaces[0].Address.Nameservers).To(Equal([]string{"10.0.0.53"}))
+	g.Expect(req.Microvm.Interfaces[1].Address.Address).To(Equal("172.16.0.1/16"), "Expected an interface without a lease to be left as it is")
+	g.Expect(req.Microvm.Interfaces[1].Address.Gateway).To(BeNil())
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package kernelargs checks the additional kernel command line arguments of a
+// Microvm, which flintlock appends to those it passes every VM.
+package kernelargs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+const (
+	// MaxLength is the longest the additional arguments may be once rendered.
+	// It is the size of the x86 kernel command line, so the arguments flintlock
+	// adds itself may still take a VM over it.
+	MaxLength = 2048
+
+	// ValidatorPath is the path the Validator is served on. The Microvm path
+	// derived from its kind is already taken by the quota webhook.
+	ValidatorPath = "/validate-infrastructure-liquid-metal-io-v1alpha1-microvm-kernelargs"
+)
+
+// reserved are the arguments flintlock generates for every VM, which would
+// stop the guest reading its metadata or network configuration if replaced.
+var reserved = map[string]string{
+	"ds":             "points cloud-init at the flintlock metadata service",
+	"network-config": "is generated by flintlock from the network interfaces",
+}
+
+//+kubebuilder:webhook:path=/validate-infrastructure-liquid-metal-io-v1alpha1-microvm-kernelargs,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.liquid-metal.io,resources=microvms,verbs=create;update,versions=v1alpha1,name=vmicrovmkernelargs.infrastructure.liquid-metal.io,admissionReviewVersions=v1
+
+// Validator refuses Microvms whose additional kernel arguments would be
+// mangled on the command line, or would replace those flintlock relies on.
+type Validator struct{}
+
+var _ admission.CustomValidator = &Validator{}
+
+// SetupWebhookWithManager registers the validator as a webhook for Microvms.
+func (v *Validator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(ValidatorPath, admission.WithCustomValidator(&infrav1.Microvm{}, v))
+
+	return nil
+}
+
+// ValidateCreate refuses a new Microvm with invalid kernel arguments.
+func (v *Validator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	mvm, ok := obj.(*infrav1.Microvm)
+	if !ok {
+		return fmt.Errorf("expected a microvm but got %T", obj)
+	}
+
+	return validate(mvm)
+}
+
+// ValidateUpdate refuses a Microvm whose kernel arguments are changed to be
+// invalid. Other updates are always allowed, so that Microvms admitted before
+// the webhook was installed can still be managed and deleted.
+func (v *Validator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	oldMvm, ok := oldObj.(*infrav1.Microvm)
+	if !ok {
+		return fmt.Errorf("expected a microvm but got %T", oldObj)
+	}
+
+	mvm, ok := newObj.(*infrav1.Microvm)
+	if !ok {
+		return fmt.Errorf("expected a microvm but got %T", newObj)
+	}
+
+	if !mvm.DeletionTimestamp.IsZero() || Render(oldMvm.Spec.KernelCmdLine) == Render(mvm.Spec.KernelCmdLine) {
+		return nil
+	}
+
+	return validate(mvm)
+}
+
+// ValidateDelete always allows a Microvm to be deleted.
+func (v *Validator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+func validate(mvm *infrav1.Microvm) error {
+	errs := Validate(mvm.Spec.KernelCmdLine, field.NewPath("spec", "kernelCmdline"))
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind("Microvm").GroupKind(), mvm.Name, errs)
+}
+
+// Validate returns the problems with args, which are rendered as key=value,
+// or as just the key when the value is empty.
+func Validate(args map[string]string, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	for _, key := range sortedKeys(args) {
+		value := args[key]
+		keyPath := path.Key(key)
+
+		switch {
+		case key == "" || key == "--":
+			errs = append(errs, field.Invalid(keyPath, key, "must be the name of a kernel argument"))
+		case strings.ContainsAny(key, `="`) || strings.IndexFunc(key, unicode.IsSpace) >= 0:
+			errs = append(errs, field.Invalid(keyPath, key, "must not contain spaces, quotes or ="))
+		case reserved[key] != "":
+			errs = append(errs, field.Forbidden(keyPath, fmt.Sprintf("%s %s", key, reserved[key])))
+		case strings.Contains(value, `"`) || strings.IndexFunc(value, unicode.IsSpace) >= 0:
+			errs = append(errs, field.Invalid(keyPath, value, "must not contain spaces or quotes"))
+		}
+	}
+
+	if rendered := Render(args); len(rendered) > MaxLength {
+		errs = append(errs, field.TooLong(path, rendered, MaxLength))
+	}
+
+	return errs
+}
+
+// Render returns args as they appear on the kernel command line, in the order
+// of their keys.
+func Render(args map[string]string) string {
+	rendered := make([]string, 0, len(args))
+
+	for _, key := range sortedKeys(args) {
+		if value := args[key]; value != "" {
+			rendered = append(rendered, key+"="+value)
+		} else {
+			rendered = append(rendered, key)
+		}
+	}
+
+	return strings.Join(rendered, " ")
+}
+
+func sortedKeys(args map[string]string) []string {
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package kernelargs_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/kernelargs"
+)
+
+func newMicrovm(args map[string]string) *infrav1.Microvm {
+	return &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1"},
+		Spec:       infrav1.MicrovmSpec{VMSpec: microvm.VMSpec{KernelCmdLine: args}},
+	}
+}
+
+func TestRender(t *testing.T) {
+	g := NewWithT(t)
+
+	rendered := kernelargs.Render(map[string]string{"quiet": "", "console": "ttyS0", "ip": "dhcp"})
+	g.Expect(rendered).To(Equal("console=ttyS0 ip=dhcp quiet"), "Expected flags without a value to be bare")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     map[string]string
+		expected field.ErrorType
+	}{
+		{name: "none"},
+		{name: "console and flags", args: map[string]string{"console": "ttyS0", "quiet": "", "ip": "10.0.0.2::10.0.0.1:255.255.255.0::eth0:off"}},
+		{name: "empty key", args: map[string]string{"": "1"}, expected: field.ErrorTypeInvalid},
+		{name: "init separator", args: map[string]string{"--": ""}, expected: field.ErrorTypeInvalid},
+		{name: "space in key", args: map[string]string{"panic 1": ""}, expected: field.ErrorTypeInvalid},
+		{name: "equals in key", args: map[string]string{"panic=1": ""}, expected: field.ErrorTypeInvalid},
+		{name: "space in value", args: map[string]string{"console": "ttyS0 quiet"}, expected: field.ErrorTypeInvalid},
+		{name: "network config", args: map[string]string{"network-config": "abc"}, expected: field.ErrorTypeForbidden},
+		{name: "datasource", args: map[string]string{"ds": "nocloud"}, expected: field.ErrorTypeForbidden},
+		{name: "too long", args: map[string]string{"custom": strings.Repeat("a", kernelargs.MaxLength)}, expected: field.ErrorTypeTooLong},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			errs := kernelargs.Validate(tc.args, field.NewPath("spec", "kernelCmdline"))
+			if tc.expected == "" {
+				g.Expect(errs).To(BeEmpty())
+
+				return
+			}
+
+			g.Expect(errs).To(HaveLen(1))
+			g.Expect(errs[0].Type).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	g := NewWithT(t)
+
+	v := &kernelargs.Validator{}
+	invalid := newMicrovm(map[string]string{"network-config": "abc"})
+
+	err := v.ValidateCreate(context.TODO(), invalid)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "Expected a reserved argument to be refused")
+	g.Expect(err.Error()).To(ContainSubstring("spec.kernelCmdline[network-config]"))
+
+	// a microvm admitted before the webhook can still be managed
+	labelled := invalid.DeepCopy()
+	labelled.Labels = map[string]string{"app": "web"}
+	g.Expect(v.ValidateUpdate(context.TODO(), invalid, labelled)).To(Succeed())
+
+	fixed := newMicrovm(map[string]string{"console": "ttyS0"})
+	g.Expect(v.ValidateUpdate(context.TODO(), invalid, fixed)).To(Succeed())
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package logging holds the keys and verbosity levels the reconcilers log
+// with, so that everything logged about one microvm or host can be found
+// across the controllers.
+package logging
+
+import (
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/cluster-api/controllers/noderefutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// The keys which identify the objects a message is about.
+const (
+	// ControllerKey is the name of the controller which logged the message.
+	ControllerKey = "controller"
+	// NamespaceKey is the namespace of the object being reconciled.
+	NamespaceKey = "namespace"
+	// MicrovmKey is the name of a Microvm.
+	MicrovmKey = "microvm"
+	// ReplicaSetKey is the name of a MicrovmReplicaSet.
+	ReplicaSetKey = "microvmreplicaset"
+	// DeploymentKey is the name of a MicrovmDeployment.
+	DeploymentKey = "microvmdeployment"
+	// AutoscalerKey is the name of a MicrovmAutoscaler.
+	AutoscalerKey = "microvmautoscaler"
+	// MicrovmHostKey is the name of a MicrovmHost.
+	MicrovmHostKey = "microvmhost"
+	// HostGroupKey is the name of a MicrovmHostGroup.
+	HostGroupKey = "microvmhostgroup"
+	// TemplateKey is the name of a MicrovmTemplate.
+	TemplateKey = "microvmtemplate"
+	// SnapshotKey is the name of a MicrovmSnapshot.
+	SnapshotKey = "microvmsnapshot"
+	// QuotaKey is the name of a MicrovmQuota.
+	QuotaKey = "microvmquota"
+	// MACPoolKey is the name of a MicrovmMACPool.
+	MACPoolKey = "microvmmacpool"
+	// IPPoolKey is the name of a MicrovmIPPool.
+	IPPoolKey = "microvmippool"
+	// ServiceKey is the name of a Service created for a Microvm.
+	ServiceKey = "service"
+	// HostKey is the endpoint of a flintlock host.
+	HostKey = "host"
+	// UIDKey is the id flintlock gave a microvm.
+	UIDKey = "uid"
+	// ReconcileUIDKey is the UID sent to flintlock with the calls of a
+	// reconcile.
+	ReconcileUIDKey = "reconcileUID"
+)
+
+// DebugLevel is the verbosity of messages logged on every reconcile or poll,
+// which are too noisy to log by default.
+const DebugLevel = 2
+
+// Constructor returns the function a controller builds the logger of each
+// reconcile with. The object being reconciled is logged under key, rather
+// than the kind and name keys controller-runtime uses by default.
+func Constructor(log logr.Logger, controller, key string) func(*reconcile.Request) logr.Logger {
+	log = log.WithValues(ControllerKey, controller)
+
+	return func(req *reconcile.Request) logr.Logger {
+		if req == nil {
+			return log
+		}
+
+		return log.WithValues(key, req.Name, NamespaceKey, req.Namespace)
+	}
+}
+
+// ForMicrovm returns log with the host of mvm and, once it has been created,
+// the uid flintlock gave it.
+func ForMicrovm(log logr.Logger, mvm *infrav1.Microvm) logr.Logger {
+	log = log.WithValues(HostKey, mvm.Spec.Host.Endpoint)
+
+	if mvm.Spec.ProviderID == nil {
+		return log
+	}
+
+	if parsed, err := noderefutil.NewProviderID(*mvm.Spec.ProviderID); err == nil {
+		log = log.WithValues(UIDKey, parsed.ID())
+	}
+
+	return log
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package logging_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+)
+
+// capture returns a logger which appends each line it logs to lines.
+func capture(lines *[]string) logr.Logger {
+	return funcr.New(func(prefix, args string) {
+		*lines = append(*lines, prefix+" "+args)
+	}, funcr.Options{})
+}
+
+func TestConstructor(t *testing.T) {
+	g := NewWithT(t)
+
+	var lines []string
+
+	constructor := logging.Constructor(capture(&lines), "microvm", logging.MicrovmKey)
+
+	constructor(nil).Info("starting")
+	constructor(&reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns1", Name: "mvm1"}}).Info("reconciling")
+
+	g.Expect(lines).To(HaveLen(2))
+	g.Expect(lines[0]).To(ContainSubstring(`"controller"="microvm"`))
+	g.Expect(lines[0]).NotTo(ContainSubstring(`"microvm"=`))
+	g.Expect(lines[1]).To(ContainSubstring(`"microvm"="mvm1" "namespace"="ns1"`))
+}
+
+func TestForMicrovm(t *testing.T) {
+	g := NewWithT(t)
+
+	var lines []string
+
+	mvm := &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1"},
+		Spec:       infrav1.MicrovmSpec{Host: microvm.Host{Endpoint: "127.0.0.1:9090"}},
+	}
+
+	logging.ForMicrovm(capture(&lines), mvm).Info("creating")
+
+	mvm.Spec.ProviderID = pointer.String("microvm://127.0.0.1:9090/abcdef")
+	logging.ForMicrovm(capture(&lines), mvm).Info("created")
+
+	g.Expect(lines).To(HaveLen(2))
+	g.Expect(lines[0]).To(ContainSubstring(`"host"="127.0.0.1:9090"`))
+	g.Expect(lines[0]).NotTo(ContainSubstring(`"uid"`), "Expected no uid before the microvm is created")
+	g.Expect(lines[1]).To(ContainSubstring(`"uid"="abcdef"`))
+}
+
+func TestTraceFactoryFunc(t *testing.T) {
+	g := NewWithT(t)
+
+	var lines []string
+
+	enabled := false
+	fakeAPIClient := &fakes.FakeClient{}
+	fakeAPIClient.CreateMicroVMReturns(&flintlockv1.CreateMicroVMResponse{
+		Microvm: &flintlocktypes.MicroVM{Spec: &flintlocktypes.MicroVMSpec{Uid: pointer.String("abcdef")}},
+	}, nil)
+	fakeAPIClient.GetMicroVMReturns(nil, errors.New("host unreachable"))
+
+	factory := logging.TraceFactoryFunc(
+		func(address string, opts ...flclient.Options) (flclient.Client, error) {
+			return fakeAPIClient, nil
+		},
+		func() bool { return enabled },
+	)
+
+	client, err := factory("127.0.0.1:9090")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ctx := logr.NewContext(context.TODO(), capture(&lines).WithValues(logging.MicrovmKey, "mvm1"))
+	create := &flintlockv1.CreateMicroVMRequest{
+		Microvm: &flintlocktypes.MicroVMSpec{
+			Id:       "mvm1",
+			Metadata: map[string]string{"user-data": "password: secret"},
+		},
+	}
+
+	_, err = client.CreateMicroVM(ctx, create)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lines).To(BeEmpty(), "Expected nothing to be logged while tracing is disabled")
+
+	enabled = true
+
+	_, err = client.CreateMicroVM(ctx, create)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = client.GetMicroVM(ctx, &flintlockv1.GetMicroVMRequest{Uid: "abcdef"})
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect(lines).To(HaveLen(2))
+	g.Expect(lines[0]).To(ContainSubstring(`"microvm"="mvm1"`), "Expected the keys of the context logger")
+	g.Expect(lines[0]).To(ContainSubstring(`"method"="CreateMicroVM"`))
+	g.Expect(lines[0]).To(ContainSubstring("abcdef"), "Expected the response to be logged")
+	g.Expect(lines[0]).To(ContainSubstring("<redacted>"))
+	g.Expect(strings.Contains(lines[0], "secret")).To(BeFalse(), "Expected the metadata to be redacted")
+	g.Expect(create.Microvm.Metadata["user-data"]).To(Equal("password: secret"), "Expected the request to be left alone")
+	g.Expect(lines[1]).To(ContainSubstring(`"error"="host unreachable"`))
+	g.Expect(fakeAPIClient.CreateMicroVMCallCount()).To(Equal(2))
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// redacted replaces the metadata values of a traced request, which carry the
+// cloud-init user data and any secrets in it.
+const redacted = "<redacted>"
+
+// TraceFactoryFunc wraps factory so that, while enabled returns true, the
+// clients it returns log every call with its request and response. They are
+// logged with the logger of the call's context, so that each call can be
+// matched to the object being reconciled.
+func TraceFactoryFunc(factory flclient.FactoryFunc, enabled func() bool) flclient.FactoryFunc {
+	return func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		client, err := factory(address, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tracedClient{Client: client, enabled: enabled}, nil
+	}
+}
+
+// tracedClient is a flintlock client which logs each call.
+type tracedClient struct {
+	flclient.Client
+
+	enabled func() bool
+}
+
+func (c *tracedClient) trace(
+	ctx context.Context,
+	method string,
+	start time.Time,
+	req, resp proto.Message,
+	err error,
+) {
+	if !c.enabled() {
+		return
+	}
+
+	log := logr.FromContextOrDiscard(ctx).WithName("flintlock").WithValues(
+		"method", method,
+		"duration", time.Since(start).String(),
+		"request", format(req),
+	)
+
+	if err != nil {
+		log.Info("flintlock call failed", "error", err.Error())
+
+		return
+	}
+
+	if resp != nil {
+		log = log.WithValues("response", format(resp))
+	}
+
+	log.Info("flintlock call")
+}
+
+// format renders msg as json, with the metadata of a create request redacted.
+func format(msg proto.Message) string {
+	if create, ok := msg.(*flintlockv1.CreateMicroVMRequest); ok && create.GetMicrovm() != nil {
+		create = proto.Clone(create).(*flintlockv1.CreateMicroVMRequest) //nolint: forcetypeassert // clone of the same type
+
+		for key := range create.Microvm.Metadata {
+			create.Microvm.Metadata[key] = redacted
+		}
+
+		msg = create
+	}
+
+	return protojson.Format(msg)
+}
+
+func (c *tracedClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	start := time.Now()
+	resp, err := c.Client.CreateMicroVM(ctx, in, opts...)
+	c.trace(ctx, "CreateMicroVM", start, in, resp, err)
+
+	return resp, err
+}
+
+func (c *tracedClient) DeleteMicroVM(
+	ctx context.Context,
+	in *flintlockv1.DeleteMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*emptypb.Empty, error) {
+	start := time.Now()
+	resp, err := c.Client.DeleteMicroVM(ctx, in, opts...)
+	c.trace(ctx, "DeleteMicroVM", start, in, resp, err)
+
+	return resp, err
+}
+
+func (c *tracedClient) GetMicroVM(
+	ctx context.Context,
+	in *flintlockv1.GetMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.GetMicroVMResponse, error) {
+	start := time.Now()
+	resp, err := c.Client.GetMicroVM(ctx, in, opts...)
+	c.trace(ctx, "GetMicroVM", start, in, resp, err)
+
+	return resp, err
+}
+
+func (c *tracedClient) ListMicroVMs(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.ListMicroVMsResponse, error) {
+	start := time.Now()
+	resp, err := c.Client.ListMicroVMs(ctx, in, opts...)
+	c.trace(ctx, "ListMicroVMs", start, in, resp, err)
+
+	return resp, err
+}
+
+// ListMicroVMsStream only logs the request, not the microvms streamed back.
+func (c *tracedClient) ListMicroVMsStream(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (flintlockv1.MicroVM_ListMicroVMsStreamClient, error) {
+	start := time.Now()
+	stream, err := c.Client.ListMicroVMsStream(ctx, in, opts...)
+	c.trace(ctx, "ListMicroVMsStream", start, in, nil, err)
+
+	return stream, err
+}
@@ -0,0 +1,18 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package macpool
+
+import "errors"
+
+var (
+	// ErrExhausted is returned when every address of a pool has been allocated.
+	ErrExhausted = errors.New("every address of the pool has been allocated")
+
+	errInvalidAddress    = errors.New("invalid mac address")
+	errMulticastAddress  = errors.New("mac address is multicast")
+	errDuplicateAddress  = errors.New("mac address is listed twice")
+	errInvalidRange      = errors.New("invalid mac range")
+	errRangeAndAddresses = errors.New("only one of range or addresses may be set")
+	errNoAddresses       = errors.New("one of range or addresses must be set")
+)
@@ -0,0 +1,217 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package macpool allocates the MAC addresses of Microvm network interfaces
+// from MicrovmMACPools, so that they never collide across replicas and hosts.
+package macpool
+
+import (
+	"fmt"
+	"net"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+const macLength = 6
+
+// span is a contiguous run of addresses, as 48 bit integers.
+type span struct {
+	first uint64
+	last  uint64
+}
+
+// Pool is the addresses a MicrovmMACPool holds.
+type Pool struct {
+	spans []span
+}
+
+// Parse returns the addresses of spec, or an error if they are not valid.
+func Parse(spec infrav1.MicrovmMACPoolSpec) (*Pool, error) {
+	switch {
+	case spec.Range != nil && len(spec.Addresses) > 0:
+		return nil, errRangeAndAddresses
+	case spec.Range != nil:
+		return parseRange(spec.Range)
+	case len(spec.Addresses) > 0:
+		return parseAddresses(spec.Addresses)
+	default:
+		return nil, errNoAddresses
+	}
+}
+
+func parseRange(r *infrav1.MACRange) (*Pool, error) {
+	first, err := parse(r.Start)
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := parse(r.End)
+	if err != nil {
+		return nil, err
+	}
+
+	if last < first {
+		return nil, fmt.Errorf("%w: %s is before %s", errInvalidRange, r.End, r.Start)
+	}
+
+	// a range which crosses into the next first octet holds multicast
+	// addresses, as consecutive first octets alternate the multicast bit
+	if first>>40 != last>>40 {
+		return nil, fmt.Errorf("%w: %s and %s must share their first octet", errInvalidRange, r.Start, r.End)
+	}
+
+	return &Pool{spans: []span{{first: first, last: last}}}, nil
+}
+
+func parseAddresses(addresses []string) (*Pool, error) {
+	pool := &Pool{}
+	seen := map[uint64]bool{}
+
+	for _, address := range addresses {
+		mac, err := parse(address)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[mac] {
+			return nil, fmt.Errorf("%w: %s", errDuplicateAddress, address)
+		}
+
+		seen[mac] = true
+		pool.spans = append(pool.spans, span{first: mac, last: mac})
+	}
+
+	return pool, nil
+}
+
+// Size returns how many addresses the pool holds.
+func (p *Pool) Size() int64 {
+	size := int64(0)
+
+	for _, s := range p.spans {
+		size += int64(s.last - s.first + 1)
+	}
+
+	return size
+}
+
+// next returns the first address of the pool which is not allocated.
+func (p *Pool) next(allocated map[uint64]bool) (uint64, bool) {
+	for _, s := range p.spans {
+		for mac := s.first; ; mac++ {
+			if !allocated[mac] {
+				return mac, true
+			}
+
+			if mac == s.last {
+				break
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// Allocate gives the network interface iface of mvm an address of pool, which
+// is recorded in the spec of pool, and returns it. An interface which was
+// already given an address is given the same one again. ErrExhausted is
+// returned when there are no addresses left.
+func Allocate(pool *infrav1.MicrovmMACPool, mvm *infrav1.Microvm, iface string) (string, error) {
+	for _, allocation := range pool.Spec.Allocations {
+		if allocation.UID == mvm.UID && allocation.Interface == iface {
+			return allocation.Address, nil
+		}
+	}
+
+	parsed, err := Parse(pool.Spec)
+	if err != nil {
+		return "", err
+	}
+
+	allocated := map[uint64]bool{}
+
+	for _, allocation := range pool.Spec.Allocations {
+		if mac, err := parse(allocation.Address); err == nil {
+			allocated[mac] = true
+		}
+	}
+
+	mac, ok := parsed.next(allocated)
+	if !ok {
+		return "", ErrExhausted
+	}
+
+	address := format(mac)
+
+	pool.Spec.Allocations = append(pool.Spec.Allocations, infrav1.MACAllocation{
+		Address:   address,
+		Microvm:   mvm.Name,
+		UID:       mvm.UID,
+		Interface: iface,
+	})
+
+	return address, nil
+}
+
+// Release removes the addresses given to the Microvm with uid from the spec
+// of pool, and returns true if there were any.
+func Release(pool *infrav1.MicrovmMACPool, uid types.UID) bool {
+	return Prune(pool, func(owner types.UID) bool { return owner != uid }) > 0
+}
+
+// Prune removes the addresses given to Microvms for which live returns false
+// from the spec of pool, and returns how many were removed.
+func Prune(pool *infrav1.MicrovmMACPool, live func(types.UID) bool) int {
+	kept := []infrav1.MACAllocation{}
+
+	for _, allocation := range pool.Spec.Allocations {
+		if live(allocation.UID) {
+			kept = append(kept, allocation)
+		}
+	}
+
+	removed := len(pool.Spec.Allocations) - len(kept)
+	if removed == 0 {
+		return 0
+	}
+
+	if len(kept) == 0 {
+		kept = nil
+	}
+
+	pool.Spec.Allocations = kept
+
+	return removed
+}
+
+// parse returns a unicast MAC address as a 48 bit integer.
+func parse(address string) (uint64, error) {
+	hw, err := net.ParseMAC(address)
+	if err != nil || len(hw) != macLength {
+		return 0, fmt.Errorf("%w: %q", errInvalidAddress, address)
+	}
+
+	if hw[0]&1 == 1 {
+		return 0, fmt.Errorf("%w: %s", errMulticastAddress, address)
+	}
+
+	mac := uint64(0)
+	for _, b := range hw {
+		mac = mac<<8 | uint64(b)
+	}
+
+	return mac, nil
+}
+
+func format(mac uint64) string {
+	hw := make(net.HardwareAddr, macLength)
+
+	for i := macLength - 1; i >= 0; i-- {
+		hw[i] = byte(mac)
+		mac >>= 8
+	}
+
+	return hw.String()
+}
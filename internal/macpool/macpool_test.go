@@ -0,0 +1,173 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package macpool_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/macpool"
+)
+
+func microvm(name string) *infrav1.Microvm {
+	return &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name + "-uid")}}
+}
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		name    string
+		spec    infrav1.MicrovmMACPoolSpec
+		size    int64
+		invalid bool
+	}{
+		{
+			name: "range",
+			spec: infrav1.MicrovmMACPoolSpec{Range: &infrav1.MACRange{Start: "02:00:00:00:00:00", End: "02:00:00:00:ff:ff"}},
+			size: 65536,
+		},
+		{
+			name: "single address range",
+			spec: infrav1.MicrovmMACPoolSpec{Range: &infrav1.MACRange{Start: "02:00:00:00:00:01", End: "02:00:00:00:00:01"}},
+			size: 1,
+		},
+		{
+			name: "addresses",
+			spec: infrav1.MicrovmMACPoolSpec{Addresses: []string{"02:00:00:00:00:01", "02-00-00-00-00-05"}},
+			size: 2,
+		},
+		{
+			name:    "empty",
+			invalid: true,
+		},
+		{
+			name: "range and addresses",
+			spec: infrav1.MicrovmMACPoolSpec{
+				Range:     &infrav1.MACRange{Start: "02:00:00:00:00:00", End: "02:00:00:00:00:ff"},
+				Addresses: []string{"02:00:00:00:01:01"},
+			},
+			invalid: true,
+		},
+		{
+			name:    "end before start",
+			spec:    infrav1.MicrovmMACPoolSpec{Range: &infrav1.MACRange{Start: "02:00:00:00:00:ff", End: "02:00:00:00:00:00"}},
+			invalid: true,
+		},
+		{
+			name:    "range crossing the first octet",
+			spec:    infrav1.MicrovmMACPoolSpec{Range: &infrav1.MACRange{Start: "02:ff:ff:ff:ff:ff", End: "04:00:00:00:00:00"}},
+			invalid: true,
+		},
+		{
+			name:    "multicast address",
+			spec:    infrav1.MicrovmMACPoolSpec{Addresses: []string{"01:00:5e:00:00:01"}},
+			invalid: true,
+		},
+		{
+			name:    "malformed address",
+			spec:    infrav1.MicrovmMACPoolSpec{Addresses: []string{"02:00:00"}},
+			invalid: true,
+		},
+		{
+			name:    "duplicate address",
+			spec:    infrav1.MicrovmMACPoolSpec{Addresses: []string{"02:00:00:00:00:01", "02:00:00:00:00:01"}},
+			invalid: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			pool, err := macpool.Parse(tc.spec)
+			if tc.invalid {
+				g.Expect(err).To(HaveOccurred())
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pool.Size()).To(Equal(tc.size))
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	g := NewWithT(t)
+
+	pool := &infrav1.MicrovmMACPool{
+		Spec: infrav1.MicrovmMACPoolSpec{
+			Range: &infrav1.MACRange{Start: "02:00:00:00:00:fe", End: "02:00:00:00:01:00"},
+		},
+	}
+
+	mvm1, mvm2 := microvm("mvm1"), microvm("mvm2")
+
+	address, err := macpool.Allocate(pool, mvm1, "eth0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(address).To(Equal("02:00:00:00:00:fe"))
+
+	address, err = macpool.Allocate(pool, mvm1, "eth1")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(address).To(Equal("02:00:00:00:00:ff"))
+
+	address, err = macpool.Allocate(pool, mvm1, "eth0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(address).To(Equal("02:00:00:00:00:fe"), "Expected an interface to be given the same address again")
+
+	address, err = macpool.Allocate(pool, mvm2, "eth0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(address).To(Equal("02:00:00:00:01:00"), "Expected the range to carry into the next octet")
+
+	_, err = macpool.Allocate(pool, mvm2, "eth1")
+	g.Expect(errors.Is(err, macpool.ErrExhausted)).To(BeTrue())
+
+	g.Expect(pool.Spec.Allocations).To(HaveLen(3))
+
+	g.Expect(macpool.Release(pool, mvm1.UID)).To(BeTrue())
+	g.Expect(macpool.Release(pool, mvm1.UID)).To(BeFalse(), "Expected nothing to be left to release")
+	g.Expect(pool.Spec.Allocations).To(HaveLen(1))
+
+	address, err = macpool.Allocate(pool, mvm2, "eth1")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(address).To(Equal("02:00:00:00:00:fe"), "Expected a released address to be allocated again")
+}
+
+func TestAllocate_InvalidPool(t *testing.T) {
+	g := NewWithT(t)
+
+	pool := &infrav1.MicrovmMACPool{}
+
+	_, err := macpool.Allocate(pool, microvm("mvm1"), "eth0")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(errors.Is(err, macpool.ErrExhausted)).To(BeFalse())
+	g.Expect(pool.Spec.Allocations).To(BeEmpty())
+}
+
+func TestPrune(t *testing.T) {
+	g := NewWithT(t)
+
+	pool := &infrav1.MicrovmMACPool{
+		Spec: infrav1.MicrovmMACPoolSpec{
+			Addresses: []string{"02:00:00:00:00:01", "02:00:00:00:00:02", "02:00:00:00:00:03"},
+		},
+	}
+
+	for _, name := range []string{"mvm1", "mvm2", "mvm3"} {
+		_, err := macpool.Allocate(pool, microvm(name), "eth0")
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	live := func(uid types.UID) bool { return uid == "mvm2-uid" }
+
+	g.Expect(macpool.Prune(pool, live)).To(Equal(2))
+	g.Expect(pool.Spec.Allocations).To(HaveLen(1))
+	g.Expect(pool.Spec.Allocations[0].Address).To(Equal("02:00:00:00:00:02"))
+
+	g.Expect(macpool.Prune(pool, live)).To(BeZero())
+}
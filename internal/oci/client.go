@@ -0,0 +1,290 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TemplateMediaType is the media type of the artifact layer which holds a
+	// MicrovmTemplate.
+	TemplateMediaType = "application/vnd.liquid-metal.microvm.template.v1+yaml"
+
+	manifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+
+	// maxContentSize bounds the manifests and templates read from a registry.
+	maxContentSize = 1 << 20
+
+	defaultFetchTimeout = 30 * time.Second
+)
+
+// Credentials authenticate against a registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Fetcher fetches the template held by an artifact.
+type Fetcher interface {
+	Fetch(ctx context.Context, ref Reference, creds *Credentials) ([]byte, error)
+}
+
+// FetcherFunc builds a Fetcher, which talks to registries over plain HTTP when
+// plainHTTP is set.
+type FetcherFunc func(plainHTTP bool) Fetcher
+
+// Client fetches artifacts over the OCI distribution API.
+type Client struct {
+	HTTPClient *http.Client
+	// PlainHTTP talks to registries over http rather than https.
+	PlainHTTP bool
+}
+
+// NewFetcher returns a Client which talks to registries over https, or plain
+// HTTP when plainHTTP is set.
+func NewFetcher(plainHTTP bool) Fetcher {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: defaultFetchTimeout},
+		PlainHTTP:  plainHTTP,
+	}
+}
+
+type manifest struct {
+	Layers []descriptor `json:"layers"`
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// Fetch returns the content of the template layer of the artifact at ref. Both
+// the manifest and the layer are checked against their digests.
+func (c *Client) Fetch(ctx context.Context, ref Reference, creds *Credentials) ([]byte, error) {
+	body, err := c.get(ctx, ref, "manifests", ref.Digest, manifestMediaType, creds)
+	if err != nil {
+		return nil, fmt.Errorf("fetching manifest for %s: %w", ref, err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, fmt.Errorf("decoding manifest for %s: %w", ref, err)
+	}
+
+	for _, layer := range m.Layers {
+		if layer.MediaType != TemplateMediaType {
+			continue
+		}
+
+		if !digestPattern.MatchString(layer.Digest) {
+			return nil, fmt.Errorf("%w: layer %s", errDigestRequired, layer.Digest)
+		}
+
+		content, err := c.get(ctx, ref, "blobs", layer.Digest, "", creds)
+		if err != nil {
+			return nil, fmt.Errorf("fetching template for %s: %w", ref, err)
+		}
+
+		return content, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errTemplateNotFound, ref)
+}
+
+// get fetches a manifest or blob, authenticating if the registry asks for it,
+// and checks the content against digest.
+func (c *Client) get(ctx context.Context, ref Reference, kind, digest, accept string, creds *Credentials) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s://%s/v2/%s/%s/%s", c.scheme(), ref.Registry, ref.Repository, kind, digest)
+
+	resp, err := c.do(ctx, endpoint, accept, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		authorization, err := c.authorize(ctx, challenge, creds)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp, err = c.do(ctx, endpoint, accept, authorization); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", errUnexpectedStatus, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxContentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if len(body) > maxContentSize {
+		return nil, errContentTooLarge
+	}
+
+	sum := sha256.Sum256(body)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("%w: %s", errDigestMismatch, digest)
+	}
+
+	return body, nil
+}
+
+func (c *Client) do(ctx context.Context, endpoint, accept, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building registry request: %w", err)
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling registry: %w", err)
+	}
+
+	return resp, nil
+}
+
+// authorize answers a WWW-Authenticate challenge, returning the value for the
+// Authorization header of the retried request.
+func (c *Client) authorize(ctx context.Context, challenge string, creds *Credentials) (string, error) {
+	scheme, params := parseChallenge(challenge)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds == nil {
+			return "", fmt.Errorf("%w: registry requires credentials", errUnexpectedStatus)
+		}
+
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)), nil
+	case "bearer":
+		token, err := c.token(ctx, params, creds)
+		if err != nil {
+			return "", err
+		}
+
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("%w: %q", errUnsupportedAuth, challenge)
+	}
+}
+
+// token fetches a bearer token from the realm named in the challenge.
+func (c *Client) token(ctx context.Context, params map[string]string, creds *Credentials) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("%w: bad realm %q", errUnsupportedAuth, params["realm"])
+	}
+
+	query := realm.Query()
+
+	for _, key := range []string{"service", "scope"} {
+		if value := params[key]; value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("building token request: %w", err)
+	}
+
+	if creds != nil {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting registry token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: token request returned %s", errUnexpectedStatus, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxContentSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding registry token: %w", err)
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+
+	return body.AccessToken, nil
+}
+
+func (c *Client) scheme() string {
+	if c.PlainHTTP {
+		return "http"
+	}
+
+	return "https"
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and
+// parameters, eg Bearer realm="https://auth",service="registry".
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+
+	for rest != "" {
+		var pair string
+
+		key, value, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+
+		key = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(key), ","))
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				break
+			}
+
+			pair, rest = value[1:end+1], value[end+2:]
+		} else {
+			pair, rest, _ = strings.Cut(value, ",")
+		}
+
+		params[strings.ToLower(key)] = pair
+	}
+
+	return scheme, params
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package oci_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/oci"
+)
+
+const testTemplate = "spec:\n  vcpu: 2\n"
+
+func digestOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// registry serves a single artifact holding testTemplate. When token is set,
+// requests must carry it as a bearer token issued by the /token endpoint.
+type registry struct {
+	manifest string
+	blobs    map[string]string
+	token    string
+}
+
+func newRegistry(mediaType, layer string) *registry {
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"layers":[{"mediaType":%q,"digest":%q,"size":%d}]}`,
+		mediaType, digestOf(layer), len(layer))
+
+	return &registry{
+		manifest: manifest,
+		blobs:    map[string]string{digestOf(layer): layer},
+	}
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if user, pass, _ := req.BasicAuth(); user != "bot" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		fmt.Fprintf(w, `{"token":%q}`, r.token)
+
+		return
+	}
+
+	if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+		w.Header().Set("WWW-Authenticate",
+			fmt.Sprintf(`Bearer realm="http://%s/token",service="registry",scope="repository:templates/ubuntu:pull"`, req.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	switch {
+	case req.URL.Path == "/v2/templates/ubuntu/manifests/"+digestOf(r.manifest):
+		fmt.Fprint(w, r.manifest)
+	case strings.HasPrefix(req.URL.Path, "/v2/templates/ubuntu/blobs/"):
+		blob, ok := r.blobs[strings.TrimPrefix(req.URL.Path, "/v2/templates/ubuntu/blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		fmt.Fprint(w, blob)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClientFetch(t *testing.T) {
+	tt := []struct {
+		name     string
+		registry func() *registry
+		creds    *oci.Credentials
+		digest   func(*registry) string
+		expected func(*WithT, []byte, error)
+	}{
+		{
+			name:     "anonymous",
+			registry: func() *registry { return newRegistry(oci.TemplateMediaType, testTemplate) },
+			expected: func(g *WithT, content []byte, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(string(content)).To(Equal(testTemplate))
+			},
+		},
+		{
+			name: "bearer token",
+			registry: func() *registry {
+				r := newRegistry(oci.TemplateMediaType, testTemplate)
+				r.token = "abc"
+
+				return r
+			},
+			creds: &oci.Credentials{Username: "bot", Password: "secret"},
+			expected: func(g *WithT, content []byte, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(string(content)).To(Equal(testTemplate))
+			},
+		},
+		{
+			name: "bearer token with bad credentials",
+			registry: func() *registry {
+				r := newRegistry(oci.TemplateMediaType, testTemplate)
+				r.token = "abc"
+
+				return r
+			},
+			creds: &oci.Credentials{Username: "bot", Password: "wrong"},
+			expected: func(g *WithT, _ []byte, err error) {
+				g.Expect(err).To(HaveOccurred())
+			},
+		},
+		{
+			name:     "manifest does not match the pinned digest",
+			registry: func() *registry { return newRegistry(oci.TemplateMediaType, testTemplate) },
+			digest:   func(*registry) string { return testDigest },
+			expected: func(g *WithT, _ []byte, err error) {
+				g.Expect(err).To(HaveOccurred())
+			},
+		},
+		{
+			name: "layer does not match its digest",
+			registry: func() *registry {
+				r := newRegistry(oci.TemplateMediaType, testTemplate)
+				r.blobs[digestOf(testTemplate)] = "spec:\n  vcpu: 64\n"
+
+				return r
+			},
+			expected: func(g *WithT, _ []byte, err error) {
+				g.Expect(err).To(MatchError(ContainSubstring("does not match its digest")))
+			},
+		},
+		{
+			name:     "no template layer",
+			registry: func() *registry { return newRegistry("application/vnd.oci.image.layer.v1.tar+gzip", testTemplate) },
+			expected: func(g *WithT, _ []byte, err error) {
+				g.Expect(err).To(MatchError(ContainSubstring("no microvm template layer")))
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := tc.registry()
+			server := httptest.NewServer(r)
+			defer server.Close()
+
+			address, err := url.Parse(server.URL)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			digest := digestOf(r.manifest)
+			if tc.digest != nil {
+				digest = tc.digest(r)
+			}
+
+			ref := oci.Reference{Registry: address.Host, Repository: "templates/ubuntu", Digest: digest}
+
+			content, err := oci.NewFetcher(true).Fetch(context.TODO(), ref, tc.creds)
+			tc.expected(g, content, err)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type dockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+type dockerAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+// CredentialsFromDockerConfig returns the credentials for registry from the
+// contents of a kubernetes.io/dockerconfigjson Secret.
+func CredentialsFromDockerConfig(data []byte, registry string) (*Credentials, error) {
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("decoding docker config: %w", err)
+	}
+
+	for server, auth := range config.Auths {
+		if registryHost(server) != registry {
+			continue
+		}
+
+		if auth.Auth == "" {
+			return &Credentials{Username: auth.Username, Password: auth.Password}, nil
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("decoding docker config auth for %s: %w", server, err)
+		}
+
+		username, password, _ := strings.Cut(string(decoded), ":")
+
+		return &Credentials{Username: username, Password: password}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errCredentialsNotFound, registry)
+}
+
+// registryHost strips any scheme and path from a docker config server entry.
+func registryHost(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	host, _, _ := strings.Cut(server, "/")
+
+	if host == dockerHubRegistry || host == "index."+dockerHubRegistry {
+		return defaultRegistry
+	}
+
+	return host
+}
@@ -0,0 +1,17 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package oci
+
+import "errors"
+
+var (
+	errInvalidReference    = errors.New("invalid oci reference")
+	errDigestRequired      = errors.New("oci reference must be pinned by a sha256 digest")
+	errDigestMismatch      = errors.New("content does not match its digest")
+	errTemplateNotFound    = errors.New("artifact has no microvm template layer")
+	errUnexpectedStatus    = errors.New("unexpected response from registry")
+	errUnsupportedAuth     = errors.New("unsupported registry authentication challenge")
+	errContentTooLarge     = errors.New("registry content exceeds size limit")
+	errCredentialsNotFound = errors.New("no credentials for registry in docker config")
+)
@@ -0,0 +1,103 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package oci
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultRegistry   = "registry-1.docker.io"
+	dockerHubRegistry = "docker.io"
+	defaultTag        = "latest"
+)
+
+var digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// Reference identifies an artifact in a registry. References are always pinned
+// to a digest so that the content they resolve to can never change.
+type Reference struct {
+	// Registry is the host, and optional port, of the registry.
+	Registry string
+	// Repository is the path of the repository within the registry.
+	Repository string
+	// Tag is informational only, the Digest is always used to fetch content.
+	Tag string
+	// Digest is the sha256 digest of the artifact manifest.
+	Digest string
+}
+
+// ParseReference parses a reference of the form
+// [registry/]repository[:tag]@sha256:digest. References without a registry
+// refer to Docker Hub.
+func ParseReference(ref string) (Reference, error) {
+	if !IsPinned(ref) {
+		return Reference{}, fmt.Errorf("%w: %s", errDigestRequired, ref)
+	}
+
+	return ParseImage(ref)
+}
+
+// ParseImage parses an image of the form [registry/]repository[:tag][@digest],
+// which need not be pinned to a digest. Images with neither a tag nor a digest
+// refer to the latest tag.
+func ParseImage(image string) (Reference, error) {
+	name, digest, found := strings.Cut(image, "@")
+	if found && !digestPattern.MatchString(digest) {
+		return Reference{}, fmt.Errorf("%w: %s", errDigestRequired, image)
+	}
+
+	parsed := Reference{Digest: digest, Registry: defaultRegistry}
+
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		parsed.Tag = name[i+1:]
+		name = name[:i]
+	}
+
+	if first, rest, found := strings.Cut(name, "/"); found && isRegistry(first) {
+		parsed.Registry = first
+		name = rest
+	} else if !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+
+	if parsed.Registry == dockerHubRegistry {
+		parsed.Registry = defaultRegistry
+	}
+
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return Reference{}, fmt.Errorf("%w: %s", errInvalidReference, image)
+	}
+
+	if parsed.Tag == "" && parsed.Digest == "" {
+		parsed.Tag = defaultTag
+	}
+
+	parsed.Repository = name
+
+	return parsed, nil
+}
+
+// IsPinned returns true if image is pinned to a sha256 digest.
+func IsPinned(image string) bool {
+	_, digest, found := strings.Cut(image, "@")
+
+	return found && digestPattern.MatchString(digest)
+}
+
+// String returns the reference in its canonical form.
+func (r Reference) String() string {
+	name := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		name += ":" + r.Tag
+	}
+
+	return name + "@" + r.Digest
+}
+
+func isRegistry(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost"
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package oci_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/oci"
+)
+
+var testDigest = "sha256:" + strings.Repeat("a", 64)
+
+func TestParseReference(t *testing.T) {
+	tt := []struct {
+		name     string
+		ref      string
+		expected func(*WithT, oci.Reference, error)
+	}{
+		{
+			name: "registry with port and tag",
+			ref:  "localhost:5000/templates/ubuntu:22.04@" + testDigest,
+			expected: func(g *WithT, ref oci.Reference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(ref).To(Equal(oci.Reference{
+					Registry:   "localhost:5000",
+					Repository: "templates/ubuntu",
+					Tag:        "22.04",
+					Digest:     testDigest,
+				}))
+			},
+		},
+		{
+			name: "docker hub library image",
+			ref:  "ubuntu@" + testDigest,
+			expected: func(g *WithT, ref oci.Reference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(ref.Registry).To(Equal("registry-1.docker.io"))
+				g.Expect(ref.Repository).To(Equal("library/ubuntu"))
+			},
+		},
+		{
+			name: "ghcr",
+			ref:  "ghcr.io/org/templates/ubuntu@" + testDigest,
+			expected: func(g *WithT, ref oci.Reference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(ref.String()).To(Equal("ghcr.io/org/templates/ubuntu@" + testDigest))
+			},
+		},
+		{
+			name: "tag without digest",
+			ref:  "ghcr.io/org/templates/ubuntu:latest",
+			expected: func(g *WithT, _ oci.Reference, err error) {
+				g.Expect(err).To(MatchError(ContainSubstring("pinned by a sha256 digest")))
+			},
+		},
+		{
+			name: "truncated digest",
+			ref:  "ghcr.io/org/templates/ubuntu@sha256:abc",
+			expected: func(g *WithT, _ oci.Reference, err error) {
+				g.Expect(err).To(HaveOccurred())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ref, err := oci.ParseReference(tc.ref)
+			tc.expected(g, ref, err)
+		})
+	}
+}
+
+func TestCredentialsFromDockerConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	config := []byte(`{"auths":{
+		"https://index.docker.io/v1/":{"auth":"aHViOnNlY3JldA=="},
+		"ghcr.io":{"username":"bot","password":"token"}
+	}}`)
+
+	creds, err := oci.CredentialsFromDockerConfig(config, "ghcr.io")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(creds).To(Equal(&oci.Credentials{Username: "bot", Password: "token"}))
+
+	creds, err = oci.CredentialsFromDockerConfig(config, "registry-1.docker.io")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(creds).To(Equal(&oci.Credentials{Username: "hub", Password: "secret"}))
+
+	_, err = oci.CredentialsFromDockerConfig(config, "quay.io")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestParseImage(t *testing.T) {
+	tt := []struct {
+		image    string
+		expected oci.Reference
+	}{
+		{
+			image:    "ghcr.io/org/kernel:5.10",
+			expected: oci.Reference{Registry: "ghcr.io", Repository: "org/kernel", Tag: "5.10"},
+		},
+		{
+			image:    "ghcr.io/org/kernel",
+			expected: oci.Reference{Registry: "ghcr.io", Repository: "org/kernel", Tag: "latest"},
+		},
+		{
+			image:    "ubuntu@" + testDigest,
+			expected: oci.Reference{Registry: "registry-1.docker.io", Repository: "library/ubuntu", Digest: testDigest},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.image, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ref, err := oci.ParseImage(tc.image)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(ref).To(Equal(tc.expected))
+			g.Expect(oci.IsPinned(tc.image)).To(Equal(tc.expected.Digest != ""))
+		})
+	}
+}
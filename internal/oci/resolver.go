@@ -0,0 +1,90 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// imageMediaTypes are the manifests a tag may point at, single and multi
+// platform, in both the OCI and Docker formats.
+var imageMediaTypes = strings.Join([]string{
+	"application/vnd.oci.image.index.v1+json",
+	manifestMediaType,
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}, ", ")
+
+// Resolver resolves the tag of an image to the digest it points at.
+type Resolver interface {
+	Resolve(ctx context.Context, image string) (string, error)
+}
+
+// NewResolver returns a Client which resolves images over https.
+func NewResolver() Resolver {
+	return NewFetcher(false).(*Client)
+}
+
+// Resolve returns image pinned to the digest of the manifest its tag points
+// at, as image@digest. Images which are already pinned are returned as they
+// are. Registries are accessed anonymously, as flintlock pulls the images.
+func (c *Client) Resolve(ctx context.Context, image string) (string, error) {
+	if IsPinned(image) {
+		return image, nil
+	}
+
+	ref, err := ParseImage(image)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", c.scheme(), ref.Registry, ref.Repository, ref.Tag)
+
+	resp, err := c.do(ctx, endpoint, imageMediaTypes, "")
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		authorization, err := c.authorize(ctx, challenge, nil)
+		if err != nil {
+			return "", fmt.Errorf("resolving %s: %w", image, err)
+		}
+
+		if resp, err = c.do(ctx, endpoint, imageMediaTypes, authorization); err != nil {
+			return "", err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolving %s: %w: %s", image, errUnexpectedStatus, resp.Status)
+	}
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); digestPattern.MatchString(digest) {
+		return image + "@" + digest, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxContentSize+1))
+	if err != nil {
+		return "", fmt.Errorf("reading manifest of %s: %w", image, err)
+	}
+
+	if len(body) > maxContentSize {
+		return "", errContentTooLarge
+	}
+
+	sum := sha256.Sum256(body)
+
+	return image + "@sha256:" + hex.EncodeToString(sum[:]), nil
+}
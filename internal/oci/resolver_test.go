@@ -0,0 +1,114 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package oci_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/oci"
+)
+
+const testManifest = `{"schemaVersion":2,"layers":[]}`
+
+func TestClientResolve(t *testing.T) {
+	tt := []struct {
+		name     string
+		handler  func(w http.ResponseWriter, req *http.Request)
+		image    string
+		expected func(*WithT, string, error)
+	}{
+		{
+			name: "digest header",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Docker-Content-Digest", testDigest)
+				fmt.Fprint(w, testManifest)
+			},
+			image: "images/kernel:5.10",
+			expected: func(g *WithT, pinned string, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(pinned).To(HaveSuffix("/images/kernel:5.10@" + testDigest))
+			},
+		},
+		{
+			name:    "digest of the manifest",
+			handler: func(w http.ResponseWriter, req *http.Request) { fmt.Fprint(w, testManifest) },
+			image:   "images/kernel:5.10",
+			expected: func(g *WithT, pinned string, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(pinned).To(HaveSuffix("/images/kernel:5.10@" + digestOf(testManifest)))
+			},
+		},
+		{
+			name: "anonymous token",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				switch {
+				case req.URL.Path == "/token":
+					fmt.Fprint(w, `{"token":"anon"}`)
+				case req.Header.Get("Authorization") != "Bearer anon":
+					w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token"`, req.Host))
+					w.WriteHeader(http.StatusUnauthorized)
+				default:
+					w.Header().Set("Docker-Content-Digest", testDigest)
+				}
+			},
+			image: "images/kernel",
+			expected: func(g *WithT, pinned string, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(pinned).To(HaveSuffix("/images/kernel@" + testDigest))
+			},
+		},
+		{
+			name:    "tag not found",
+			handler: func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			image:   "images/kernel:5.10",
+			expected: func(g *WithT, _ string, err error) {
+				g.Expect(err).To(MatchError(ContainSubstring("404")))
+			},
+		},
+		{
+			name: "already pinned",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				panic("expected a pinned image not to be resolved")
+			},
+			image: "images/kernel:5.10@" + testDigest,
+			expected: func(g *WithT, pinned string, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(pinned).To(HaveSuffix("/images/kernel:5.10@" + testDigest))
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var requested []string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				requested = append(requested, req.URL.Path)
+				tc.handler(w, req)
+			}))
+			defer server.Close()
+
+			address, err := url.Parse(server.URL)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			client := oci.NewFetcher(true).(*oci.Client)
+
+			pinned, err := client.Resolve(context.TODO(), address.Host+"/"+tc.image)
+			tc.expected(g, pinned, err)
+
+			for _, path := range requested {
+				g.Expect(path).To(Or(Equal("/token"), HavePrefix("/v2/images/kernel/manifests/")))
+			}
+		})
+	}
+}
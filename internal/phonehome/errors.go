@@ -0,0 +1,8 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package phonehome
+
+import "errors"
+
+var errInvalidCallback = errors.New("invalid phone home callback annotation")
@@ -0,0 +1,88 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package phonehome serves the callbacks cloud-init makes from Microvm guests
+// once they have booted. Each VM is given a one-time token in its vendor data.
+// A callback with the token is recorded on the Microvm in an annotation, which
+// its controller consumes, so that the server never writes the status the
+// controller owns.
+package phonehome
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+const (
+	// Path is the path callbacks are served under. It is followed by the
+	// namespace and name of the Microvm and the token of its VM.
+	Path = "/phone-home/"
+
+	// CallbackAnnotation records a callback on the Microvm until its
+	// controller consumes it.
+	CallbackAnnotation = "infrastructure.liquid-metal.io/phone-home"
+
+	tokenBytes = 32
+)
+
+// Callback is what a guest reported when it called back.
+type Callback struct {
+	// TokenHash is the hash of the token the guest called back with, which
+	// ties the callback to the VM the token was given to.
+	TokenHash  string      `json:"tokenHash"`
+	CalledAt   metav1.Time `json:"calledAt"`
+	InstanceID string      `json:"instanceID,omitempty"`
+	Hostname   string      `json:"hostname,omitempty"`
+	FQDN       string      `json:"fqdn,omitempty"`
+}
+
+// NewToken returns a one-time token for a VM to call back with, and the hash
+// of it which is recorded on its Microvm.
+func NewToken() (string, string, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("generating phone home token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	return token, Hash(token), nil
+}
+
+// Hash returns the hash of token which is recorded on the Microvm.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// URL returns the URL under base the guest of mvm calls back to with token.
+func URL(base string, mvm *infrav1.Microvm, token string) string {
+	return strings.TrimSuffix(base, "/") + Path +
+		url.PathEscape(mvm.Namespace) + "/" + url.PathEscape(mvm.Name) + "/" + token
+}
+
+// ParseCallback returns the callback recorded on mvm, or nil if there is none.
+func ParseCallback(mvm *infrav1.Microvm) (*Callback, error) {
+	value, ok := mvm.Annotations[CallbackAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	callback := &Callback{}
+	if err := json.Unmarshal([]byte(value), callback); err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidCallback, err)
+	}
+
+	return callback, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package phonehome_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/phonehome"
+)
+
+func TestNewToken(t *testing.T) {
+	g := NewWithT(t)
+
+	token, hash, err := phonehome.NewToken()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(hash).To(Equal(phonehome.Hash(token)))
+	g.Expect(hash).NotTo(ContainSubstring(token), "Expected only the hash of the token to be recorded")
+
+	other, _, err := phonehome.NewToken()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(other).NotTo(Equal(token), "Expected each vm to be given its own token")
+
+	mvm := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "mvm1"}}
+	g.Expect(phonehome.URL("https://10.0.0.1:9445/", mvm, token)).To(Equal("https://10.0.0.1:9445/phone-home/ns1/mvm1/" + token))
+}
+
+func TestParseCallback(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := &infrav1.Microvm{}
+
+	callback, err := phonehome.ParseCallback(mvm)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(callback).To(BeNil())
+
+	mvm.Annotations = map[string]string{phonehome.CallbackAnnotation: `{"tokenHash":"abc","calledAt":"2022-10-18T10:00:00Z","hostname":"mvm1"}`}
+
+	callback, err = phonehome.ParseCallback(mvm)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(callback.TokenHash).To(Equal("abc"))
+	g.Expect(callback.Hostname).To(Equal("mvm1"))
+
+	mvm.Annotations[phonehome.CallbackAnnotation] = "not json"
+
+	_, err = phonehome.ParseCallback(mvm)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestServer(t *testing.T) {
+	const token = "s3cr3t"
+
+	form := url.Values{"instance_id": {"i-1"}, "hostname": {"mvm1"}, "fqdn": {"mvm1.example.com"}}.Encode()
+
+	tt := []struct {
+		name       string
+		method     string
+		path       string
+		annotated  bool
+		expected   int
+		recordedAs string
+	}{
+		{
+			name:       "callback with the token is recorded",
+			method:     http.MethodPost,
+			path:       "/phone-home/ns1/mvm1/" + token,
+			expected:   http.StatusOK,
+			recordedAs: "mvm1",
+		},
+		{
+			name:     "callback with another token is refused",
+			method:   http.MethodPost,
+			path:     "/phone-home/ns1/mvm1/guessed",
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "callback for a missing microvm is refused the same way",
+			method:   http.MethodPost,
+			path:     "/phone-home/ns1/mvm2/" + token,
+			expected: http.StatusForbidden,
+		},
+		{
+			name:      "token cannot be used twice",
+			method:    http.MethodPost,
+			path:      "/phone-home/ns1/mvm1/" + token,
+			annotated: true,
+			expected:  http.StatusConflict,
+		},
+		{
+			name:     "only posts are served",
+			method:   http.MethodGet,
+			path:     "/phone-home/ns1/mvm1/" + token,
+			expected: http.StatusMethodNotAllowed,
+		},
+		{
+			name:     "other paths are not found",
+			method:   http.MethodPost,
+			path:     "/phone-home/ns1/" + token,
+			expected: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvm := &infrav1.Microvm{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "mvm1"},
+				Status: infrav1.MicrovmStatus{
+					PhoneHome: &infrav1.PhoneHomeStatus{TokenHash: phonehome.Hash(token)},
+				},
+			}
+			if tc.annotated {
+				mvm.Annotations = map[string]string{phonehome.CallbackAnnotation: "{}"}
+			}
+
+			scheme := runtime.NewScheme()
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvm).Build()
+			server := &phonehome.Server{Client: c}
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			g.Expect(rec.Code).To(Equal(tc.expected))
+
+			if tc.recordedAs == "" {
+				return
+			}
+
+			recorded := &infrav1.Microvm{}
+			g.Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(mvm), recorded)).To(Succeed())
+
+			callback, err := phonehome.ParseCallback(recorded)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(callback.TokenHash).To(Equal(phonehome.Hash(token)))
+			g.Expect(callback.Hostname).To(Equal(tc.recordedAs))
+			g.Expect(callback.InstanceID).To(Equal("i-1"))
+			g.Expect(callback.FQDN).To(Equal("mvm1.example.com"))
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package phonehome
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+)
+
+const (
+	certName = "tls.crt"
+	keyName  = "tls.key"
+
+	// maxBodyBytes bounds the form a guest posts, which holds its SSH host
+	// keys along with what is recorded.
+	maxBodyBytes      = 64 * 1024
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+// Server serves the callbacks of guests over HTTPS. It runs on every replica
+// of the operator, as it only records callbacks on the Microvms.
+type Server struct {
+	// Client reads Microvms and records callbacks on them.
+	Client client.Client
+	// BindAddress is the address the server listens on.
+	BindAddress string
+	// CertDir holds the tls.crt and tls.key the server presents, which are
+	// reloaded when they change.
+	CertDir string
+}
+
+// NeedLeaderElection returns false, so that whichever replica a guest reaches
+// records its callback.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves callbacks until ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("phone-home")
+
+	watcher, err := certwatcher.New(filepath.Join(s.CertDir, certName), filepath.Join(s.CertDir, keyName))
+	if err != nil {
+		return fmt.Errorf("loading phone home certificate: %w", err)
+	}
+
+	go func() {
+		if err := watcher.Start(ctx); err != nil {
+			logger.Error(err, "certificate watcher stopped")
+		}
+	}()
+
+	listener, err := tls.Listen("tcp", s.BindAddress, &tls.Config{
+		GetCertificate: watcher.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	})
+	if err != nil {
+		return fmt.Errorf("listening for phone home callbacks: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, "failed shutting down phone home server")
+		}
+	}()
+
+	logger.Info("serving phone home callbacks", "address", s.BindAddress)
+
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving phone home callbacks: %w", err)
+	}
+
+	return nil
+}
+
+// ServeHTTP records the callback of a guest on its Microvm if it carries the
+// token its VM was given, and the Microvm has not already recorded one. The
+// same answer is given for a Microvm which does not exist as for a wrong
+// token, so that callers cannot find out which Microvms there are.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, Path), "/")
+	if !strings.HasPrefix(r.URL.Path, Path) || len(parts) != 3 {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	namespace, name, token := parts[0], parts[1], parts[2]
+	logger := log.FromContext(r.Context()).WithValues("namespace", namespace, "name", name)
+
+	mvm := &infrav1.Microvm{}
+	if err := s.Client.Get(r.Context(), client.ObjectKey{Namespace: namespace, Name: name}, mvm); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "failed getting microvm for phone home")
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusForbidden)
+
+		return
+	}
+
+	if !tokenMatches(mvm, token) {
+		w.WriteHeader(http.StatusForbidden)
+
+		return
+	}
+
+	if _, ok := mvm.Annotations[CallbackAnnotation]; ok {
+		w.WriteHeader(http.StatusConflict)
+
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	if err := s.record(r.Context(), mvm, &Callback{
+		TokenHash:  Hash(token),
+		CalledAt:   metav1.Now(),
+		InstanceID: r.PostForm.Get("instance_id"),
+		Hostname:   r.PostForm.Get("hostname"),
+		FQDN:       r.PostForm.Get("fqdn"),
+	}); err != nil {
+		logger.Error(err, "failed recording phone home")
+		w.WriteHeader(http.StatusInternalServerError)
+
+		return
+	}
+
+	logger.Info("guest phoned home")
+	w.WriteHeader(http.StatusOK)
+}
+
+// record annotates mvm with callback. The patch only applies to the version of
+// the Microvm which was read, so that a token cannot be used twice.
+func (s *Server) record(ctx context.Context, mvm *infrav1.Microvm, callback *Callback) error {
+	value, err := json.Marshal(callback)
+	if err != nil {
+		return fmt.Errorf("encoding callback: %w", err)
+	}
+
+	patch := client.MergeFromWithOptions(mvm.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	if mvm.Annotations == nil {
+		mvm.Annotations = map[string]string{}
+	}
+
+	mvm.Annotations[CallbackAnnotation] = string(value)
+
+	return s.Client.Patch(ctx, mvm, patch, apply.FieldOwner)
+}
+
+func tokenMatches(mvm *infrav1.Microvm, token string) bool {
+	phoneHome := mvm.Status.PhoneHome
+	if phoneHome == nil || phoneHome.TokenHash == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(Hash(token)), []byte(phoneHome.TokenHash)) == 1
+}
@@ -0,0 +1,13 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package probe
+
+import "errors"
+
+var (
+	errNoAction        = errors.New("liveness probe has no action")
+	errUnhealthyStatus = errors.New("guest returned an unhealthy status")
+	errExecRejected    = errors.New("guest agent rejected exec")
+	errNonZeroExit     = errors.New("command exited non-zero")
+)
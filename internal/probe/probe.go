@@ -0,0 +1,159 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package probe checks the liveness of the workload in a Microvm guest.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestaddr"
+)
+
+const defaultTimeout = time.Second
+
+// Prober runs a liveness probe against a guest.
+type Prober interface {
+	Probe(ctx context.Context, probe *infrav1.LivenessProbe) error
+}
+
+// GuestProber probes guests directly over TCP and HTTP, and runs commands
+// through the agent in the guest. It does not check where a probe points, which
+// is left to CheckTarget.
+type GuestProber struct {
+	HTTPClient *http.Client
+	Dialer     *net.Dialer
+}
+
+// NewGuestProber returns a Prober for guests reachable from the operator.
+func NewGuestProber() Prober {
+	return &GuestProber{
+		HTTPClient: &http.Client{CheckRedirect: guestaddr.NoRedirects},
+		Dialer:     &net.Dialer{},
+	}
+}
+
+// CheckTarget returns an error unless the address probe connects to is one of
+// addrs, the addresses of the guest.
+func CheckTarget(probe *infrav1.LivenessProbe, addrs []netip.Addr) error {
+	switch {
+	case probe.TCPSocket != nil:
+		return guestaddr.CheckHost(probe.TCPSocket.Host, addrs)
+	case probe.HTTPGet != nil:
+		return guestaddr.CheckURL(probe.HTTPGet.URL, addrs)
+	case probe.AgentExec != nil:
+		return guestaddr.CheckURL(probe.AgentExec.AgentEndpoint, addrs)
+	default:
+		return errNoAction
+	}
+}
+
+// Probe returns nil if the guest passes probe, or an error describing why it
+// did not. Each probe is given TimeoutSeconds, or 1s when that is unset.
+func (p *GuestProber) Probe(ctx context.Context, probe *infrav1.LivenessProbe) error {
+	timeout := defaultTimeout
+	if probe.TimeoutSeconds > 0 {
+		timeout = time.Duration(probe.TimeoutSeconds) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch {
+	case probe.TCPSocket != nil:
+		return p.tcp(ctx, probe.TCPSocket)
+	case probe.HTTPGet != nil:
+		return p.httpGet(ctx, probe.HTTPGet)
+	case probe.AgentExec != nil:
+		return p.agentExec(ctx, probe.AgentExec)
+	default:
+		return errNoAction
+	}
+}
+
+func (p *GuestProber) tcp(ctx context.Context, action *infrav1.TCPSocketAction) error {
+	address := net.JoinHostPort(action.Host, strconv.Itoa(int(action.Port)))
+
+	conn, err := p.Dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", address, err)
+	}
+
+	return conn.Close()
+}
+
+func (p *GuestProber) httpGet(ctx context.Context, action *infrav1.HTTPGetAction) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, action.URL, nil)
+	if err != nil {
+		return fmt.Errorf("building probe request: %w", err)
+	}
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("requesting %s: %w", action.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s", errUnhealthyStatus, resp.Status)
+	}
+
+	return nil
+}
+
+type execRequest struct {
+	Command []string `json:"command"`
+}
+
+type execResponse struct {
+	ExitCode int `json:"exitCode"`
+}
+
+// agentExec POSTs the command to /exec on the agent, which runs it in the guest
+// and replies with its exit code.
+func (p *GuestProber) agentExec(ctx context.Context, action *infrav1.AgentExecAction) error {
+	body, err := json.Marshal(execRequest{Command: action.Command})
+	if err != nil {
+		return fmt.Errorf("encoding exec request: %w", err)
+	}
+
+	url := strings.TrimSuffix(action.AgentEndpoint, "/") + "/exec"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building exec request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("requesting exec: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s", errExecRejected, resp.Status)
+	}
+
+	result := execResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decoding exec response: %w", err)
+	}
+
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%w: %d", errNonZeroExit, result.ExitCode)
+	}
+
+	return nil
+}
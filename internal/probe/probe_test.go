@@ -0,0 +1,199 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package probe_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/probe"
+)
+
+func TestGuestProber_Probe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.WriteHeader(http.StatusOK)
+		case "/exec":
+			body := struct {
+				Command []string `json:"command"`
+			}{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+
+			exitCode := 0
+			if body.Command[0] == "false" {
+				exitCode = 1
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]int{"exitCode": exitCode})
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, portString, _ := net.SplitHostPort(serverURL.Host)
+	port, _ := strconv.Atoi(portString)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	tt := []struct {
+		name    string
+		probe   infrav1.LivenessProbe
+		healthy bool
+	}{
+		{
+			name:    "open tcp port passes",
+			probe:   infrav1.LivenessProbe{TCPSocket: &infrav1.TCPSocketAction{Host: host, Port: int32(port)}},
+			healthy: true,
+		},
+		{
+			name:  "closed tcp port fails",
+			probe: infrav1.LivenessProbe{TCPSocket: &infrav1.TCPSocketAction{Host: "127.0.0.1", Port: int32(closedPort)}},
+		},
+		{
+			name:    "ok http status passes",
+			probe:   infrav1.LivenessProbe{HTTPGet: &infrav1.HTTPGetAction{URL: server.URL + "/healthz"}},
+			healthy: true,
+		},
+		{
+			name:  "error http status fails",
+			probe: infrav1.LivenessProbe{HTTPGet: &infrav1.HTTPGetAction{URL: server.URL + "/broken"}},
+		},
+		{
+			name: "command exiting zero passes",
+			probe: infrav1.LivenessProbe{AgentExec: &infrav1.AgentExecAction{
+				AgentEndpoint: server.URL + "/",
+				Command:       []string{"true"},
+			}},
+			healthy: true,
+		},
+		{
+			name: "command exiting non-zero fails",
+			probe: infrav1.LivenessProbe{AgentExec: &infrav1.AgentExecAction{
+				AgentEndpoint: server.URL,
+				Command:       []string{"false"},
+			}},
+		},
+		{
+			name: "probe without an action fails",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := probe.NewGuestProber().Probe(context.TODO(), &tc.probe)
+			if tc.healthy {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestGuestProber_Probe_Redirect(t *testing.T) {
+	g := NewWithT(t)
+
+	elsewhere := false
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		elsewhere = true
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer server.Close()
+
+	err := probe.NewGuestProber().Probe(context.TODO(), &infrav1.LivenessProbe{HTTPGet: &infrav1.HTTPGetAction{URL: server.URL}})
+	g.Expect(err).NotTo(HaveOccurred(), "Expected a redirect to pass the probe")
+	g.Expect(elsewhere).To(BeFalse(), "Expected the redirect not to be followed")
+}
+
+func TestCheckTarget(t *testing.T) {
+	addrs := []netip.Addr{netip.MustParseAddr("10.0.0.10")}
+
+	tt := []struct {
+		name    string
+		probe   infrav1.LivenessProbe
+		allowed bool
+	}{
+		{
+			name:    "tcp port of the guest is allowed",
+			probe:   infrav1.LivenessProbe{TCPSocket: &infrav1.TCPSocketAction{Host: "10.0.0.10", Port: 22}},
+			allowed: true,
+		},
+		{
+			name:  "tcp port elsewhere is refused",
+			probe: infrav1.LivenessProbe{TCPSocket: &infrav1.TCPSocketAction{Host: "10.0.0.11", Port: 22}},
+		},
+		{
+			name:    "url of the guest is allowed",
+			probe:   infrav1.LivenessProbe{HTTPGet: &infrav1.HTTPGetAction{URL: "http://10.0.0.10:8080/healthz"}},
+			allowed: true,
+		},
+		{
+			name:  "url elsewhere is refused",
+			probe: infrav1.LivenessProbe{HTTPGet: &infrav1.HTTPGetAction{URL: "http://169.254.169.254/latest"}},
+		},
+		{
+			name:  "url by name is refused",
+			probe: infrav1.LivenessProbe{HTTPGet: &infrav1.HTTPGetAction{URL: "http://kubernetes.default/"}},
+		},
+		{
+			name: "agent of the guest is allowed",
+			probe: infrav1.LivenessProbe{AgentExec: &infrav1.AgentExecAction{
+				AgentEndpoint: "http://10.0.0.10:8080",
+				Command:       []string{"true"},
+			}},
+			allowed: true,
+		},
+		{
+			name: "agent elsewhere is refused",
+			probe: infrav1.LivenessProbe{AgentExec: &infrav1.AgentExecAction{
+				AgentEndpoint: "http://10.96.0.1:443",
+				Command:       []string{"true"},
+			}},
+		},
+		{
+			name: "probe without an action is refused",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := probe.CheckTarget(&tc.probe, addrs)
+			if tc.allowed {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(HaveOccurred())
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package quota accounts for the resources used by the Microvms of a namespace
+// against its MicrovmQuotas.
+package quota
+
+import (
+	"fmt"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// Used returns what the given Microvms use between them, not counting those
+// being deleted.
+func Used(mvms []infrav1.Microvm) infrav1.QuotaUsage {
+	used := infrav1.QuotaUsage{}
+
+	for i := range mvms {
+		if mvms[i].DeletionTimestamp.IsZero() {
+			used = Add(used, &mvms[i])
+		}
+	}
+
+	return used
+}
+
+// Add returns used together with what the Microvm uses.
+func Add(used infrav1.QuotaUsage, mvm *infrav1.Microvm) infrav1.QuotaUsage {
+	used.VCPU += mvm.Spec.VCPU
+	used.MemoryMb += mvm.Spec.MemoryMb
+	used.Microvms++
+
+	return used
+}
+
+// Exceeded returns a description of each limit of hard which used is over, or
+// nothing if used is within them all.
+func Exceeded(hard infrav1.QuotaLimits, used infrav1.QuotaUsage) []string {
+	exceeded := []string{}
+
+	if hard.VCPU != nil && used.VCPU > *hard.VCPU {
+		exceeded = append(exceeded, fmt.Sprintf("vcpu %d/%d", used.VCPU, *hard.VCPU))
+	}
+
+	if hard.MemoryMb != nil && used.MemoryMb > *hard.MemoryMb {
+		exceeded = append(exceeded, fmt.Sprintf("memoryMb %d/%d", used.MemoryMb, *hard.MemoryMb))
+	}
+
+	if hard.Microvms != nil && used.Microvms > *hard.Microvms {
+		exceeded = append(exceeded, fmt.Sprintf("microvms %d/%d", used.Microvms, *hard.Microvms))
+	}
+
+	return exceeded
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package quota_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/quota"
+)
+
+func newMicrovm(name string, vcpu, memoryMb int64) infrav1.Microvm {
+	mvm := infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1"},
+	}
+	mvm.Spec.VCPU = vcpu
+	mvm.Spec.MemoryMb = memoryMb
+
+	return mvm
+}
+
+func TestUsed(t *testing.T) {
+	g := NewWithT(t)
+
+	deleting := newMicrovm("mvm3", 8, 8192)
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+
+	used := quota.Used([]infrav1.Microvm{
+		newMicrovm("mvm1", 2, 1024),
+		newMicrovm("mvm2", 4, 2048),
+		deleting,
+	})
+	g.Expect(used).To(Equal(infrav1.QuotaUsage{VCPU: 6, MemoryMb: 3072, Microvms: 2}))
+}
+
+func TestExceeded(t *testing.T) {
+	g := NewWithT(t)
+
+	used := infrav1.QuotaUsage{VCPU: 6, MemoryMb: 3072, Microvms: 2}
+
+	g.Expect(quota.Exceeded(infrav1.QuotaLimits{}, used)).To(BeEmpty(), "Expected no limit when unset")
+	g.Expect(quota.Exceeded(infrav1.QuotaLimits{
+		VCPU:     pointer.Int64(6),
+		MemoryMb: pointer.Int64(4096),
+		Microvms: pointer.Int32(2),
+	}, used)).To(BeEmpty(), "Expected reaching a limit to be allowed")
+	g.Expect(quota.Exceeded(infrav1.QuotaLimits{
+		VCPU:     pointer.Int64(4),
+		MemoryMb: pointer.Int64(2048),
+		Microvms: pointer.Int32(2),
+	}, used)).To(ConsistOf("vcpu 6/4", "memoryMb 3072/2048"))
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/validate-infrastructure-liquid-metal-io-v1alpha1-microvm,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.liquid-metal.io,resources=microvms,verbs=create;update,versions=v1alpha1,name=vmicrovmquota.infrastructure.liquid-metal.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmquotas,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvms,verbs=get;list;watch
+
+// Validator refuses Microvms which would take their namespace over one of its
+// MicrovmQuotas. Microvms admitted at the same time may still go over a quota
+// together, which the MicrovmQuota reports once they exist.
+type Validator struct {
+	Client client.Client
+}
+
+var _ admission.CustomValidator = &Validator{}
+
+// SetupWebhookWithManager registers the validator as a webhook for Microvms.
+func (v *Validator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.Microvm{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate refuses a new Microvm which does not fit within the quotas.
+func (v *Validator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	mvm, ok := obj.(*infrav1.Microvm)
+	if !ok {
+		return fmt.Errorf("expected a microvm but got %T", obj)
+	}
+
+	return v.validate(ctx, mvm)
+}
+
+// ValidateUpdate refuses a Microvm which grows so that it no longer fits within
+// the quotas. Other updates are always allowed, so that Microvms admitted
+// before a quota was lowered can still be managed and deleted.
+func (v *Validator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldMvm, ok := oldObj.(*infrav1.Microvm)
+	if !ok {
+		return fmt.Errorf("expected a microvm but got %T", oldObj)
+	}
+
+	mvm, ok := newObj.(*infrav1.Microvm)
+	if !ok {
+		return fmt.Errorf("expected a microvm but got %T", newObj)
+	}
+
+	if !mvm.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	if mvm.Spec.VCPU <= oldMvm.Spec.VCPU && mvm.Spec.MemoryMb <= oldMvm.Spec.MemoryMb {
+		return nil
+	}
+
+	return v.validate(ctx, mvm)
+}
+
+// ValidateDelete always allows a Microvm to be deleted.
+func (v *Validator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+func (v *Validator) validate(ctx context.Context, mvm *infrav1.Microvm) error {
+	quotas := &infrav1.MicrovmQuotaList{}
+	if err := v.Client.List(ctx, quotas, client.InNamespace(mvm.Namespace)); err != nil {
+		return fmt.Errorf("listing microvmquotas: %w", err)
+	}
+
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+
+	mvms := &infrav1.MicrovmList{}
+	if err := v.Client.List(ctx, mvms, client.InNamespace(mvm.Namespace)); err != nil {
+		return fmt.Errorf("listing microvms: %w", err)
+	}
+
+	others := []infrav1.Microvm{}
+
+	for _, other := range mvms.Items {
+		if other.Name != mvm.Name {
+			others = append(others, other)
+		}
+	}
+
+	used := Add(Used(others), mvm)
+
+	for _, q := range quotas.Items {
+		if exceeded := Exceeded(q.Spec.Hard, used); len(exceeded) > 0 {
+			return apierrors.NewForbidden(
+				infrav1.GroupVersion.WithResource("microvms").GroupResource(),
+				mvm.Name,
+				fmt.Errorf("exceeds microvmquota %s: %s", q.Name, strings.Join(exceeded, ", ")),
+			)
+		}
+	}
+
+	return nil
+}
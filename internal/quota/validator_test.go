@@ -0,0 +1,78 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package quota_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/quota"
+)
+
+func newValidator(g *WithT, objects ...runtime.Object) *quota.Validator {
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	return &quota.Validator{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build(),
+	}
+}
+
+func newQuota(hard infrav1.QuotaLimits) *infrav1.MicrovmQuota {
+	return &infrav1.MicrovmQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "quota1", Namespace: "ns1"},
+		Spec:       infrav1.MicrovmQuotaSpec{Hard: hard},
+	}
+}
+
+func TestValidator_ValidateCreate(t *testing.T) {
+	g := NewWithT(t)
+
+	existing := newMicrovm("mvm1", 4, 4096)
+	fits := newMicrovm("mvm2", 4, 4096)
+	tooBig := newMicrovm("mvm2", 6, 4096)
+
+	validator := newValidator(g, &existing)
+	g.Expect(validator.ValidateCreate(context.TODO(), &tooBig)).To(Succeed(), "Expected no limit without a quota")
+
+	validator = newValidator(g, &existing, newQuota(infrav1.QuotaLimits{VCPU: pointer.Int64(8)}))
+	g.Expect(validator.ValidateCreate(context.TODO(), &fits)).To(Succeed())
+
+	err := validator.ValidateCreate(context.TODO(), &tooBig)
+	g.Expect(apierrors.IsForbidden(err)).To(BeTrue(), "Expected a microvm over the quota to be refused")
+	g.Expect(err.Error()).To(ContainSubstring("vcpu 10/8"))
+
+	// quotas in other namespaces do not apply
+	other := newQuota(infrav1.QuotaLimits{Microvms: pointer.Int32(0)})
+	other.Namespace = "ns2"
+	validator = newValidator(g, &existing, other)
+	g.Expect(validator.ValidateCreate(context.TODO(), &tooBig)).To(Succeed())
+}
+
+func TestValidator_ValidateUpdate(t *testing.T) {
+	g := NewWithT(t)
+
+	existing := newMicrovm("mvm1", 4, 4096)
+	validator := newValidator(g, &existing, newQuota(infrav1.QuotaLimits{VCPU: pointer.Int64(2)}))
+
+	// a microvm admitted before the quota was lowered can still be updated
+	updated := existing.DeepCopy()
+	updated.Finalizers = []string{"finalizer"}
+	g.Expect(validator.ValidateUpdate(context.TODO(), &existing, updated)).To(Succeed())
+
+	grown := existing.DeepCopy()
+	grown.Spec.VCPU = 6
+	g.Expect(apierrors.IsForbidden(validator.ValidateUpdate(context.TODO(), &existing, grown))).To(BeTrue(),
+		"Expected a microvm growing over the quota to be refused")
+
+	g.Expect(validator.ValidateDelete(context.TODO(), &existing)).To(Succeed())
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package ratelimit limits the rate of calls made to flintlock hosts, so that
+// reconciling a large fleet with many workers does not overwhelm them.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// Limiter holds a token bucket for each flintlock host, so that a busy host
+// does not hold up calls to the others.
+type Limiter struct {
+	qps       float32
+	burst     int
+	deleteQPS float32
+
+	mu      sync.Mutex
+	hosts   map[string]flowcontrol.RateLimiter
+	deletes map[string]flowcontrol.RateLimiter
+}
+
+// NewLimiter returns a Limiter which allows qps calls a second to each host,
+// with bursts of up to burst calls. A qps of 0 does not limit the calls.
+func NewLimiter(qps float32, burst int) *Limiter {
+	return &Limiter{
+		qps:     qps,
+		burst:   burst,
+		hosts:   map[string]flowcontrol.RateLimiter{},
+		deletes: map[string]flowcontrol.RateLimiter{},
+	}
+}
+
+// LimitDeletes also limits DeleteMicroVM calls to qps a second for each host,
+// without bursts, so that tearing down many VMs does not crowd out creates.
+func (l *Limiter) LimitDeletes(qps float32) *Limiter {
+	l.deleteQPS = qps
+
+	return l
+}
+
+// FactoryFunc wraps factory so that the clients it returns wait for a token
+// from the bucket of their host before each call.
+func (l *Limiter) FactoryFunc(factory flclient.FactoryFunc) flclient.FactoryFunc {
+	return func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		client, err := factory(address, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &limitedClient{
+			Client:        client,
+			limiter:       l.forHost(l.hosts, address, l.qps, l.burst),
+			deleteLimiter: l.forHost(l.deletes, address, l.deleteQPS, 1),
+		}, nil
+	}
+}
+
+// forHost returns the bucket in buckets for the host, or nil if qps is 0.
+func (l *Limiter) forHost(
+	buckets map[string]flowcontrol.RateLimiter,
+	address string,
+	qps float32,
+	burst int,
+) flowcontrol.RateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := buckets[address]
+	if !ok {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+		buckets[address] = limiter
+	}
+
+	return limiter
+}
+
+// limitedClient is a flintlock client which waits for a token before each call.
+type limitedClient struct {
+	flclient.Client
+
+	limiter       flowcontrol.RateLimiter
+	deleteLimiter flowcontrol.RateLimiter
+}
+
+func (c *limitedClient) wait(ctx context.Context) error {
+	return waitFor(ctx, c.limiter)
+}
+
+func waitFor(ctx context.Context, limiter flowcontrol.RateLimiter) error {
+	if limiter == nil {
+		return nil
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for flintlock rate limit: %w", err)
+	}
+
+	return nil
+}
+
+func (c *limitedClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return c.Client.CreateMicroVM(ctx, in, opts...)
+}
+
+func (c *limitedClient) DeleteMicroVM(
+	ctx context.Context,
+	in *flintlockv1.DeleteMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*emptypb.Empty, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := waitFor(ctx, c.deleteLimiter); err != nil {
+		return nil, err
+	}
+
+	return c.Client.DeleteMicroVM(ctx, in, opts...)
+}
+
+func (c *limitedClient) GetMicroVM(
+	ctx context.Context,
+	in *flintlockv1.GetMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.GetMicroVMResponse, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return c.Client.GetMicroVM(ctx, in, opts...)
+}
+
+func (c *limitedClient) ListMicroVMs(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.ListMicroVMsResponse, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return c.Client.ListMicroVMs(ctx, in, opts...)
+}
+
+func (c *limitedClient) ListMicroVMsStream(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (flintlockv1.MicroVM_ListMicroVMsStreamClient, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return c.Client.ListMicroVMsStream(ctx, in, opts...)
+}
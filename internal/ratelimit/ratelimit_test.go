@@ -0,0 +1,93 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/ratelimit"
+)
+
+func TestLimiter_FactoryFunc(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeAPIClient := &fakes.FakeClient{}
+	factory := ratelimit.NewLimiter(10, 1).FactoryFunc(
+		func(address string, opts ...flclient.Options) (flclient.Client, error) {
+			return fakeAPIClient, nil
+		},
+	)
+
+	busy, err := factory("host1:9090")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, err := busy.GetMicroVM(context.TODO(), &flintlockv1.GetMicroVMRequest{})
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	g.Expect(time.Since(start)).To(BeNumerically(">=", 150*time.Millisecond),
+		"Expected calls beyond the burst to wait for a token")
+	g.Expect(fakeAPIClient.GetMicroVMCallCount()).To(Equal(3))
+
+	other, err := factory("host2:9090")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	start = time.Now()
+	_, err = other.GetMicroVM(context.TODO(), &flintlockv1.GetMicroVMRequest{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond),
+		"Expected another host to have its own bucket")
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	_, err = busy.DeleteMicroVM(ctx, &flintlockv1.DeleteMicroVMRequest{})
+	g.Expect(err).To(HaveOccurred(), "Expected a cancelled call not to wait for a token")
+	g.Expect(fakeAPIClient.DeleteMicroVMCallCount()).To(BeZero())
+}
+
+func TestLimiter_LimitDeletes(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeAPIClient := &fakes.FakeClient{}
+	factory := ratelimit.NewLimiter(0, 0).LimitDeletes(10).FactoryFunc(
+		func(address string, opts ...flclient.Options) (flclient.Client, error) {
+			return fakeAPIClient, nil
+		},
+	)
+
+	client, err := factory("host1:9090")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetMicroVM(context.TODO(), &flintlockv1.GetMicroVMRequest{})
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	g.Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond),
+		"Expected other calls not to be limited without a qps")
+
+	start = time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, err := client.DeleteMicroVM(context.TODO(), &flintlockv1.DeleteMicroVMRequest{})
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	g.Expect(time.Since(start)).To(BeNumerically(">=", 150*time.Millisecond),
+		"Expected deletes to wait for a token")
+	g.Expect(fakeAPIClient.DeleteMicroVMCallCount()).To(Equal(3))
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package readiness checks whether the manager is able to do useful work, for
+// its /readyz endpoint.
+package readiness
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+)
+
+const defaultCacheSyncTimeout = time.Second
+
+// Syncer waits for informer caches to sync, as implemented by the manager's cache.
+type Syncer interface {
+	WaitForCacheSync(ctx context.Context) bool
+}
+
+// CacheSynced returns a check which passes once the informer caches have
+// synced. Until then the controllers would act on a partial view of the
+// cluster.
+func CacheSynced(syncer Syncer) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), defaultCacheSyncTimeout)
+		defer cancel()
+
+		if !syncer.WaitForCacheSync(ctx) {
+			return errCacheNotSynced
+		}
+
+		return nil
+	}
+}
+
+// HostReachable returns a check which passes when the flintlock host at
+// endpoint answers, with or without TLS. No credentials are sent to it.
+func HostReachable(prober identity.Prober, endpoint string) healthz.Checker {
+	return func(req *http.Request) error {
+		_, err := prober.Probe(req.Context(), endpoint)
+		if err != nil && !errors.Is(err, identity.ErrPlaintext) {
+			return err
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package readiness_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/readiness"
+)
+
+type fakeSyncer bool
+
+func (s fakeSyncer) WaitForCacheSync(_ context.Context) bool {
+	return bool(s)
+}
+
+type fakeProber struct {
+	err error
+}
+
+func (p fakeProber) Probe(_ context.Context, _ string) (identity.Identity, error) {
+	return identity.Identity{}, p.err
+}
+
+func TestCacheSynced(t *testing.T) {
+	g := NewWithT(t)
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+
+	g.Expect(readiness.CacheSynced(fakeSyncer(true))(req)).To(Succeed())
+	g.Expect(readiness.CacheSynced(fakeSyncer(false))(req)).NotTo(Succeed())
+}
+
+func TestHostReachable(t *testing.T) {
+	tt := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "tls host is reachable",
+			expected: true,
+		},
+		{
+			name:     "plaintext host is reachable",
+			err:      fmt.Errorf("probing: %w", identity.ErrPlaintext),
+			expected: true,
+		},
+		{
+			name: "unreachable host fails",
+			err:  fmt.Errorf("probing: %w", identity.ErrUnreachable),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := readiness.HostReachable(fakeProber{err: tc.err}, "127.0.0.1:9090")(httptest.NewRequest("GET", "/readyz", nil))
+			if tc.expected {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(HaveOccurred())
+			}
+		})
+	}
+}
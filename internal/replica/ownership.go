@@ -0,0 +1,41 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package replica
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// IsExternal returns true if obj is annotated as managed outside the operator.
+func IsExternal(obj metav1.Object) bool {
+	return obj.GetAnnotations()[infrav1.OwnershipAnnotation] == infrav1.OwnershipExternal
+}
+
+// FirstManaged returns the first of mvms which is managed by the operator,
+// preferring those which are not protected from deletion, and false if they
+// are all externally managed.
+func FirstManaged(mvms []infrav1.Microvm) (infrav1.Microvm, bool) {
+	var (
+		protected infrav1.Microvm
+		found     bool
+	)
+
+	for _, mvm := range mvms {
+		if IsExternal(&mvm) {
+			continue
+		}
+
+		if mvm.Annotations[infrav1.DeleteProtectionAnnotation] != "true" {
+			return mvm, true
+		}
+
+		if !found {
+			protected, found = mvm, true
+		}
+	}
+
+	return protected, found
+}
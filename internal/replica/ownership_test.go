@@ -0,0 +1,46 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package replica_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/replica"
+)
+
+func TestFirstManaged(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := func(name, ownership string) infrav1.Microvm {
+		return infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{infrav1.OwnershipAnnotation: ownership},
+		}}
+	}
+
+	_, ok := replica.FirstManaged(nil)
+	g.Expect(ok).To(BeFalse())
+
+	_, ok = replica.FirstManaged([]infrav1.Microvm{mvm("canary", infrav1.OwnershipExternal)})
+	g.Expect(ok).To(BeFalse())
+
+	first, ok := replica.FirstManaged([]infrav1.Microvm{mvm("canary", infrav1.OwnershipExternal), mvm("a", ""), mvm("b", "")})
+	g.Expect(ok).To(BeTrue())
+	g.Expect(first.Name).To(Equal("a"))
+
+	protected := mvm("a", "")
+	protected.Annotations[infrav1.DeleteProtectionAnnotation] = "true"
+
+	first, ok = replica.FirstManaged([]infrav1.Microvm{protected, mvm("b", "")})
+	g.Expect(ok).To(BeTrue())
+	g.Expect(first.Name).To(Equal("b"), "Expected an unprotected microvm to be preferred")
+
+	first, ok = replica.FirstManaged([]infrav1.Microvm{mvm("canary", infrav1.OwnershipExternal), protected})
+	g.Expect(ok).To(BeTrue())
+	g.Expect(first.Name).To(Equal("a"))
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package replica
+
+import (
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// Hosts returns the hosts the microvms of rs are created on.
+func Hosts(rs *infrav1.MicrovmReplicaSet) []microvm.Host {
+	if len(rs.Spec.Hosts) > 0 {
+		return rs.Spec.Hosts
+	}
+
+	if rs.Spec.Host.Endpoint == "" {
+		return nil
+	}
+
+	return []microvm.Host{rs.Spec.Host}
+}
+
+// HasHost returns true if the microvms of rs are created on endpoint.
+func HasHost(rs *infrav1.MicrovmReplicaSet, endpoint string) bool {
+	for _, host := range Hosts(rs) {
+		if host.Endpoint == endpoint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Place returns the host each of count new microvms is created on. Each goes
+// to the host with the fewest of mvms and of the microvms placed before it,
+// taking the first of hosts when tied. Hosts in excluded are skipped, and
+// nothing is placed if every host is excluded.
+func Place(hosts []microvm.Host, mvms []infrav1.Microvm, excluded map[string]bool, count int) []microvm.Host {
+	load := countByHost(mvms)
+	placed := []microvm.Host{}
+
+	for len(placed) < count {
+		best := -1
+
+		for i, host := range hosts {
+			if excluded[host.Endpoint] {
+				continue
+			}
+
+			if best < 0 || load[host.Endpoint] < load[hosts[best].Endpoint] {
+				best = i
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+
+		load[hosts[best].Endpoint]++
+		placed = append(placed, hosts[best])
+	}
+
+	return placed
+}
+
+// ScaleInOrder returns mvms in the order they are removed when scaling in:
+// those on a host which is not in hosts first, and then those on the hosts
+// with the most microvms, so that the remaining ones stay spread.
+func ScaleInOrder(hosts []microvm.Host, mvms []infrav1.Microvm) []infrav1.Microvm {
+	listed := map[string]bool{}
+	for _, host := range hosts {
+		listed[host.Endpoint] = true
+	}
+
+	load := countByHost(mvms)
+	ordered := []infrav1.Microvm{}
+
+	for _, mvm := range mvms {
+		if !listed[mvm.Spec.Host.Endpoint] {
+			ordered = append(ordered, mvm)
+		}
+	}
+
+	// take one at a time from the most loaded host, so that ties are broken
+	// by the order of hosts
+	for {
+		busiest := ""
+
+		for _, host := range hosts {
+			if load[host.Endpoint] > 0 && (busiest == "" || load[host.Endpoint] > load[busiest]) {
+				busiest = host.Endpoint
+			}
+		}
+
+		if busiest == "" {
+			return ordered
+		}
+
+		for _, mvm := range mvms {
+			if mvm.Spec.Host.Endpoint == busiest && !contains(ordered, mvm.Name) {
+				ordered = append(ordered, mvm)
+
+				break
+			}
+		}
+
+		load[busiest]--
+	}
+}
+
+func countByHost(mvms []infrav1.Microvm) map[string]int {
+	load := map[string]int{}
+
+	for _, mvm := range mvms {
+		load[mvm.Spec.Host.Endpoint]++
+	}
+
+	return load
+}
+
+func contains(mvms []infrav1.Microvm, name string) bool {
+	for _, mvm := range mvms {
+		if mvm.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package replica_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/replica"
+)
+
+func mvmOn(name, endpoint string) infrav1.Microvm {
+	return infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       infrav1.MicrovmSpec{Host: microvm.Host{Endpoint: endpoint}},
+	}
+}
+
+func endpoints(hosts []microvm.Host) []string {
+	out := []string{}
+	for _, host := range hosts {
+		out = append(out, host.Endpoint)
+	}
+
+	return out
+}
+
+func names(mvms []infrav1.Microvm) []string {
+	out := []string{}
+	for _, mvm := range mvms {
+		out = append(out, mvm.Name)
+	}
+
+	return out
+}
+
+func TestHosts(t *testing.T) {
+	g := NewWithT(t)
+
+	rs := &infrav1.MicrovmReplicaSet{}
+	g.Expect(replica.Hosts(rs)).To(BeEmpty())
+
+	rs.Spec.Host = microvm.Host{Endpoint: "a"}
+	g.Expect(endpoints(replica.Hosts(rs))).To(Equal([]string{"a"}))
+
+	rs.Spec.Hosts = []microvm.Host{{Endpoint: "b"}, {Endpoint: "c"}}
+	g.Expect(endpoints(replica.Hosts(rs))).To(Equal([]string{"b", "c"}), "Expected hosts to replace host")
+	g.Expect(replica.HasHost(rs, "a")).To(BeFalse())
+	g.Expect(replica.HasHost(rs, "c")).To(BeTrue())
+}
+
+func TestPlace(t *testing.T) {
+	g := NewWithT(t)
+
+	hosts := []microvm.Host{{Endpoint: "a"}, {Endpoint: "b"}, {Endpoint: "c"}}
+	mvms := []infrav1.Microvm{mvmOn("1", "a"), mvmOn("2", "a"), mvmOn("3", "b")}
+
+	g.Expect(endpoints(replica.Place(hosts, mvms, nil, 4))).To(Equal([]string{"c", "b", "c", "a"}))
+	g.Expect(endpoints(replica.Place(hosts, mvms, map[string]bool{"c": true}, 2))).To(Equal([]string{"b", "a"}))
+	g.Expect(replica.Place(hosts, mvms, map[string]bool{"a": true, "b": true, "c": true}, 2)).To(BeEmpty())
+}
+
+func TestScaleInOrder(t *testing.T) {
+	g := NewWithT(t)
+
+	hosts := []microvm.Host{{Endpoint: "a"}, {Endpoint: "b"}}
+	mvms := []infrav1.Microvm{mvmOn("1", "a"), mvmOn("2", "b"), mvmOn("3", "gone"), mvmOn("4", "b"), mvmOn("5", "b")}
+
+	g.Expect(names(replica.ScaleInOrder(hosts, mvms))).To(Equal([]string{"3", "2", "4", "1", "5"}))
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package replica
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// hashLength is how many hex characters of the template hash are kept.
+const hashLength = 10
+
+// TemplateHash returns a short hash of tmpl which changes whenever it does.
+func TemplateHash(tmpl infrav1.MicrovmTemplateSpec) string {
+	// a template is plain data, so it always marshals
+	raw, _ := json.Marshal(tmpl) //nolint: errchkjson // see above
+
+	sum := sha256.Sum256(raw)
+
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
+
+// LabelValue returns s with every character a label value cannot hold
+// replaced by a dash, trimmed so that it starts and ends with an alphanumeric
+// character and fits in a label value.
+func LabelValue(s string) string {
+	value := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+
+		return '-'
+	}, s)
+
+	if len(value) > validation.LabelValueMaxLength {
+		value = value[:validation.LabelValueMaxLength]
+	}
+
+	return strings.Trim(value, "-_.")
+}
+
+// Hash returns the hash of the template of rs. The hash set by the deployment
+// is kept, as defaulting by the API server can change the template the
+// replicaset holds.
+func Hash(rs *infrav1.MicrovmReplicaSet) string {
+	if hash := rs.Labels[infrav1.MicrovmReplicaSetHashLabel]; hash != "" {
+		return hash
+	}
+
+	return TemplateHash(rs.Spec.Template)
+}
+
+// Provenance returns the labels which record where the Microvms of rs come
+// from: the replicaset and, when it has one, the deployment which created it,
+// the hash of its template and, when it has only one, its host.
+func Provenance(rs *infrav1.MicrovmReplicaSet) map[string]string {
+	labels := map[string]string{
+		infrav1.MicrovmReplicaSetNameLabel: rs.Name,
+		infrav1.MicrovmReplicaSetHashLabel: Hash(rs),
+	}
+
+	if hosts := Hosts(rs); len(hosts) == 1 {
+		labels[infrav1.HostEndpointLabel] = LabelValue(hosts[0].Endpoint)
+	}
+
+	if name, ok := rs.Labels[infrav1.MicrovmDeploymentNameLabel]; ok {
+		labels[infrav1.MicrovmDeploymentNameLabel] = name
+	}
+
+	return labels
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package replica_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/replica"
+)
+
+func TestTemplateHash(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpl := infrav1.MicrovmTemplateSpec{}
+	tmpl.Spec.VCPU = 2
+
+	changed := *tmpl.DeepCopy()
+	changed.Spec.VCPU = 4
+
+	g.Expect(replica.TemplateHash(tmpl)).To(HaveLen(10))
+	g.Expect(replica.TemplateHash(tmpl)).To(Equal(replica.TemplateHash(*tmpl.DeepCopy())), "Expected the hash to be stable")
+	g.Expect(replica.TemplateHash(tmpl)).NotTo(Equal(replica.TemplateHash(changed)), "Expected the hash to change with the template")
+}
+
+func TestLabelValue(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(replica.LabelValue("127.0.0.1:9090")).To(Equal("127.0.0.1-9090"))
+	g.Expect(replica.LabelValue("[::1]:9090")).To(Equal("1--9090"))
+	g.Expect(replica.LabelValue(strings.Repeat("a", 70))).To(HaveLen(63))
+}
+
+func TestProvenance(t *testing.T) {
+	g := NewWithT(t)
+
+	rs := &infrav1.MicrovmReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rs1"},
+		Spec: infrav1.MicrovmReplicaSetSpec{
+			Host: microvm.Host{Endpoint: "1.2.3.4:9090"},
+		},
+	}
+
+	g.Expect(replica.Provenance(rs)).To(Equal(map[string]string{
+		infrav1.MicrovmReplicaSetNameLabel: "rs1",
+		infrav1.MicrovmReplicaSetHashLabel: replica.TemplateHash(rs.Spec.Template),
+		infrav1.HostEndpointLabel:          "1.2.3.4-9090",
+	}), "Expected a replicaset without a deployment to hash its own template")
+
+	rs.Labels = map[string]string{
+		infrav1.MicrovmDeploymentNameLabel: "d1",
+		infrav1.MicrovmReplicaSetHashLabel: "abc123",
+	}
+
+	g.Expect(replica.Provenance(rs)).To(SatisfyAll(
+		HaveKeyWithValue(infrav1.MicrovmDeploymentNameLabel, "d1"),
+		HaveKeyWithValue(infrav1.MicrovmReplicaSetHashLabel, "abc123"),
+	), "Expected the labels set by the deployment to be kept")
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package replica stamps out individual Microvms from a replicaset template.
+package replica
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// Data is the data available to a Microvm template.
+type Data struct {
+	// ReplicaIndex is the index of the replica within its replicaset, starting
+	// at 0. Indexes are reused once the replica holding them is removed.
+	ReplicaIndex int
+	// ReplicaSetName is the name of the owning replicaset.
+	ReplicaSetName string
+	// HostName is the name of the host the replica will run on.
+	HostName string
+	// HostEndpoint is the address of the host the replica will run on.
+	HostEndpoint string
+}
+
+var funcs = template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+	"hex": func(i int) string { return fmt.Sprintf("%02x", i) },
+}
+
+// Render returns a copy of tmpl with every templated field rendered against
+// data. The rendered fields are the name, generate name, labels and annotations
+// in the metadata, and the microvm labels, hostname, host VM namespace and name,
+// kernel command line, network interface MACs and addresses and shutdown agent
+// endpoint in the spec. User data is left as it is, as cloud-init templates
+// it with Jinja, which shares the same delimiters. A name which does not use
+// the replica index would be the same for every replica, so it becomes the
+// generate name instead, unless one is set already.
+func Render(tmpl infrav1.MicrovmTemplateSpec, data Data) (infrav1.MicrovmTemplateSpec, error) {
+	out := *tmpl.DeepCopy()
+	r := renderer{data: data}
+
+	out.Name = r.render("name", out.Name)
+	out.GenerateName = r.render("generateName", out.GenerateName)
+
+	if out.Name != "" && !strings.Contains(tmpl.Name, ".ReplicaIndex") {
+		if out.GenerateName == "" {
+			out.GenerateName = out.Name + "-"
+		}
+
+		out.Name = ""
+	}
+
+	r.renderMap("labels", out.Labels)
+	r.renderMap("annotations", out.Annotations)
+
+	r.renderMap("spec.labels", out.Spec.Labels)
+	r.renderMap("spec.kernelCmdline", out.Spec.KernelCmdLine)
+	out.Spec.Hostname = r.render("spec.hostname", out.Spec.Hostname)
+	out.Spec.HostVMNamespace = r.render("spec.hostVMNamespace", out.Spec.HostVMNamespace)
+	out.Spec.HostVMName = r.render("spec.hostVMName", out.Spec.HostVMName)
+
+	if out.Spec.GracefulShutdown != nil {
+		out.Spec.GracefulShutdown.AgentEndpoint = r.render("spec.gracefulShutdown.agentEndpoint", out.Spec.GracefulShutdown.AgentEndpoint)
+	}
+
+	for i := range out.Spec.NetworkInterfaces {
+		iface := &out.Spec.NetworkInterfaces[i]
+		iface.GuestMAC = r.render(iface.GuestDeviceName+".guestMac", iface.GuestMAC)
+		iface.Address = r.render(iface.GuestDeviceName+".address", iface.Address)
+	}
+
+	if r.err != nil {
+		return infrav1.MicrovmTemplateSpec{}, r.err
+	}
+
+	return out, nil
+}
+
+type renderer struct {
+	data Data
+	err  error
+}
+
+func (r *renderer) render(field, text string) string {
+	if r.err != nil || !strings.Contains(text, "{{") {
+		return text
+	}
+
+	t, err := template.New(field).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		r.err = fmt.Errorf("parsing template for %s: %w", field, err)
+
+		return text
+	}
+
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, r.data); err != nil {
+		r.err = fmt.Errorf("rendering template for %s: %w", field, err)
+
+		return text
+	}
+
+	return buf.String()
+}
+
+func (r *renderer) renderMap(field string, values map[string]string) {
+	for k, v := range values {
+		values[k] = r.render(field+"."+k, v)
+	}
+}
+
+// StaticLabels returns the labels, or annotations, which do not vary between
+// replicas.
+func StaticLabels(labels map[string]string) map[string]string {
+	static := map[string]string{}
+
+	for k, v := range labels {
+		if !strings.Contains(v, "{{") {
+			static[k] = v
+		}
+	}
+
+	return static
+}
+
+// MAC returns a locally administered unicast MAC address which is stable for
+// the given seed, replica index and interface, and distinct between them.
+func MAC(seed string, index int, iface string) string {
+	sum := sha256.Sum256([]byte(seed + "/" + strconv.Itoa(index) + "/" + iface))
+
+	// set the locally administered bit and clear the multicast bit
+	sum[0] = (sum[0] | 0x02) & 0xfe //nolint: gomnd // mac address bits
+
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", sum[0], sum[1], sum[2], sum[3], sum[4], sum[5])
+}
+
+// NextIndex returns the lowest replica index not held by any of the given microvms.
+func NextIndex(mvms []infrav1.Microvm) int {
+	return NextIndices(mvms, 1)[0]
+}
+
+// NextIndices returns the count lowest replica indexes not held by any of the
+// given microvms, in order.
+func NextIndices(mvms []infrav1.Microvm, count int) []int {
+	taken := map[int]bool{}
+
+	for _, mvm := range mvms {
+		if index, ok := Index(&mvm); ok {
+			taken[index] = true
+		}
+	}
+
+	indices := make([]int, 0, count)
+
+	for next := 0; len(indices) < count; next++ {
+		if !taken[next] {
+			indices = append(indices, next)
+		}
+	}
+
+	return indices
+}
+
+// Index returns the replica index recorded on a microvm.
+func Index(mvm *infrav1.Microvm) (int, bool) {
+	raw, ok := mvm.Annotations[infrav1.ReplicaIndexAnnotation]
+	if !ok {
+		return 0, false
+	}
+
+	index, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, false
+	}
+
+	return index, true
+}
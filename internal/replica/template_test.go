@@ -0,0 +1,112 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package replica_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/replica"
+)
+
+func TestRender(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpl := infrav1.MicrovmTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "{{ .ReplicaSetName }}-{{ .ReplicaIndex }}",
+			Labels: map[string]string{"index": "{{ .ReplicaIndex }}", "static": "value"},
+		},
+		Spec: infrav1.MicrovmSpec{
+			Hostname:   "web-{{ .ReplicaIndex }}",
+			HostVMName: "{{ .ReplicaSetName }}-web-{{ .ReplicaIndex }}",
+			UserData:   pointer.String("## template: jinja\n#cloud-config\nhostname: {{ v1.local_hostname }}\n"),
+			VMSpec: microvm.VMSpec{
+				NetworkInterfaces: []microvm.NetworkInterface{
+					{
+						GuestDeviceName: "eth1",
+						GuestMAC:        "02:00:00:00:00:{{ hex .ReplicaIndex }}",
+						Address:         "10.0.0.{{ add 10 .ReplicaIndex }}/24",
+					},
+				},
+			},
+		},
+	}
+
+	out, err := replica.Render(tmpl, replica.Data{ReplicaIndex: 11, ReplicaSetName: "rs1", HostName: "host1"})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(out.Name).To(Equal("rs1-11"))
+	g.Expect(out.Labels).To(Equal(map[string]string{"index": "11", "static": "value"}))
+	g.Expect(out.Spec.Hostname).To(Equal("web-11"))
+	g.Expect(out.Spec.HostVMName).To(Equal("rs1-web-11"))
+	g.Expect(*out.Spec.UserData).To(Equal(*tmpl.Spec.UserData), "Expected jinja in the userdata to be passed through")
+	g.Expect(out.Spec.NetworkInterfaces[0].GuestMAC).To(Equal("02:00:00:00:00:0b"))
+	g.Expect(out.Spec.NetworkInterfaces[0].Address).To(Equal("10.0.0.21/24"))
+
+	g.Expect(tmpl.Labels["index"]).To(Equal("{{ .ReplicaIndex }}"), "Expected the template not to be modified")
+
+	out, err = replica.Render(infrav1.MicrovmTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-{{ .HostName }}"},
+	}, replica.Data{ReplicaIndex: 1, HostName: "host1"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out.Name).To(BeEmpty(), "Expected a name without the replica index not to be shared by every replica")
+	g.Expect(out.GenerateName).To(Equal("web-host1-"))
+
+	_, err = replica.Render(infrav1.MicrovmTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Name: "{{ .Missing }}"},
+	}, replica.Data{})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestMAC(t *testing.T) {
+	g := NewWithT(t)
+
+	mac := replica.MAC("seed", 0, "eth1")
+	g.Expect(mac).To(MatchRegexp(`^[0-9a-f]{2}(:[0-9a-f]{2}){5}$`))
+	g.Expect(mac).To(Equal(replica.MAC("seed", 0, "eth1")), "Expected the MAC to be stable")
+	g.Expect(mac).NotTo(Equal(replica.MAC("seed", 1, "eth1")))
+	g.Expect(mac).NotTo(Equal(replica.MAC("seed", 0, "eth0")))
+
+	var first byte
+	_, err := fmt.Sscanf(mac[:2], "%02x", &first)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(first&0x02).To(Equal(byte(0x02)), "Expected the locally administered bit to be set")
+	g.Expect(first&0x01).To(Equal(byte(0)), "Expected the multicast bit to be clear")
+}
+
+func TestNextIndex(t *testing.T) {
+	g := NewWithT(t)
+
+	withIndex := func(index string) infrav1.Microvm {
+		return infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{infrav1.ReplicaIndexAnnotation: index},
+		}}
+	}
+
+	g.Expect(replica.NextIndex(nil)).To(Equal(0))
+	g.Expect(replica.NextIndex([]infrav1.Microvm{withIndex("0"), withIndex("2")})).To(Equal(1))
+	g.Expect(replica.NextIndex([]infrav1.Microvm{withIndex("1"), withIndex("0"), {}})).To(Equal(2))
+
+	g.Expect(replica.NextIndices([]infrav1.Microvm{withIndex("0"), withIndex("2")}, 3)).To(Equal([]int{1, 3, 4}))
+	g.Expect(replica.NextIndices(nil, 0)).To(BeEmpty())
+}
+
+func TestStaticLabels(t *testing.T) {
+	g := NewWithT(t)
+
+	labels := map[string]string{
+		"app":     "web",
+		"replica": "{{ .ReplicaIndex }}",
+	}
+
+	g.Expect(replica.StaticLabels(labels)).To(Equal(map[string]string{"app": "web"}))
+	g.Expect(replica.StaticLabels(nil)).To(BeEmpty())
+}
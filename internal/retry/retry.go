@@ -0,0 +1,197 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package retry bounds how long each call to a flintlock host may take, and
+// retries the calls which are safe to repeat, so that a flaky network path to
+// a far-edge host does not hang a reconcile.
+package retry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	defaultInitialBackoff = 200 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+)
+
+// Policy describes how calls to a flintlock host are bounded and retried.
+type Policy struct {
+	// Timeout is how long each attempt at a call may take, including
+	// connecting to the host. 0 leaves the attempts to the deadline of the
+	// caller.
+	Timeout time.Duration
+	// MaxAttempts is how many times a call which reads from the host is made
+	// before its error is returned. Calls which change the host are made once.
+	MaxAttempts int
+	// InitialBackoff is how long to wait before the first retry. Each retry
+	// waits twice as long as the last, up to MaxBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff is the longest wait between retries.
+	MaxBackoff time.Duration
+}
+
+// IsZero returns true if the policy neither bounds nor retries calls.
+func (p Policy) IsZero() bool {
+	return p.Timeout == 0 && p.MaxAttempts <= 1
+}
+
+// FactoryFunc wraps factory so that the calls of the clients it returns follow
+// policy.
+func FactoryFunc(factory flclient.FactoryFunc, policy Policy) flclient.FactoryFunc {
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaultInitialBackoff
+	}
+
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultMaxBackoff
+	}
+
+	return func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		client, err := factory(address, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &retryingClient{Client: client, policy: policy}, nil
+	}
+}
+
+// retryingClient is a flintlock client which bounds each call and retries
+// those which read from the host. Streams are passed through, as they outlive
+// the call which opens them.
+type retryingClient struct {
+	flclient.Client
+
+	policy Policy
+}
+
+// attempt makes a single call, bounded by the timeout of the policy.
+func (c *retryingClient) attempt(ctx context.Context, call func(context.Context) error) error {
+	if c.policy.Timeout <= 0 {
+		return call(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.policy.Timeout)
+	defer cancel()
+
+	return call(ctx)
+}
+
+// retry makes call until it succeeds, fails with an error which is not worth
+// retrying, or has been attempted MaxAttempts times. It is only used for calls
+// which are safe to repeat.
+func (c *retryingClient) retry(ctx context.Context, call func(context.Context) error) error {
+	backoff := c.policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := c.attempt(ctx, call)
+		if err == nil || attempt >= c.policy.MaxAttempts || !Unreachable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > c.policy.MaxBackoff {
+			backoff = c.policy.MaxBackoff
+		}
+	}
+}
+
+// Unreachable returns true if err, or an error it wraps, means the host could
+// not be reached in time, rather than that it answered the call.
+func Unreachable(err error) bool {
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &grpcErr) {
+		return false
+	}
+
+	switch grpcErr.GRPCStatus().Code() {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *retryingClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	var resp *flintlockv1.CreateMicroVMResponse
+
+	err := c.attempt(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = c.Client.CreateMicroVM(ctx, in, opts...)
+
+		return err
+	})
+
+	return resp, err
+}
+
+func (c *retryingClient) DeleteMicroVM(
+	ctx context.Context,
+	in *flintlockv1.DeleteMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*emptypb.Empty, error) {
+	var resp *emptypb.Empty
+
+	err := c.attempt(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = c.Client.DeleteMicroVM(ctx, in, opts...)
+
+		return err
+	})
+
+	return resp, err
+}
+
+func (c *retryingClient) GetMicroVM(
+	ctx context.Context,
+	in *flintlockv1.GetMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.GetMicroVMResponse, error) {
+	var resp *flintlockv1.GetMicroVMResponse
+
+	err := c.retry(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = c.Client.GetMicroVM(ctx, in, opts...)
+
+		return err
+	})
+
+	return resp, err
+}
+
+func (c *retryingClient) ListMicroVMs(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.ListMicroVMsResponse, error) {
+	var resp *flintlockv1.ListMicroVMsResponse
+
+	err := c.retry(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = c.Client.ListMicroVMs(ctx, in, opts...)
+
+		return err
+	})
+
+	return resp, err
+}
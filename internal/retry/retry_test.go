@@ -0,0 +1,104 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/retry"
+)
+
+func newFactory(fakeAPIClient *fakes.FakeClient, policy retry.Policy) flclient.FactoryFunc {
+	return retry.FactoryFunc(func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		return fakeAPIClient, nil
+	}, policy)
+}
+
+func TestFactoryFunc_RetriesReads(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeAPIClient := &fakes.FakeClient{}
+	fakeAPIClient.GetMicroVMReturnsOnCall(0, nil, status.Error(codes.Unavailable, "connection refused"))
+	fakeAPIClient.GetMicroVMReturnsOnCall(1, &flintlockv1.GetMicroVMResponse{}, nil)
+	fakeAPIClient.ListMicroVMsReturns(nil, status.Error(codes.Unavailable, "connection refused"))
+	fakeAPIClient.DeleteMicroVMReturns(nil, status.Error(codes.Unavailable, "connection refused"))
+
+	client, err := newFactory(fakeAPIClient, retry.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond})("host1:9090")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = client.GetMicroVM(context.TODO(), &flintlockv1.GetMicroVMRequest{})
+	g.Expect(err).NotTo(HaveOccurred(), "Expected the call to succeed once the host could be reached")
+	g.Expect(fakeAPIClient.GetMicroVMCallCount()).To(Equal(2))
+
+	_, err = client.ListMicroVMs(context.TODO(), &flintlockv1.ListMicroVMsRequest{})
+	g.Expect(status.Code(err)).To(Equal(codes.Unavailable))
+	g.Expect(fakeAPIClient.ListMicroVMsCallCount()).To(Equal(3), "Expected the call to give up after MaxAttempts")
+
+	_, err = client.DeleteMicroVM(context.TODO(), &flintlockv1.DeleteMicroVMRequest{})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(fakeAPIClient.DeleteMicroVMCallCount()).To(Equal(1), "Expected a call which changes the host not to be retried")
+}
+
+func TestFactoryFunc_DoesNotRetryAnswers(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeAPIClient := &fakes.FakeClient{}
+	fakeAPIClient.GetMicroVMReturns(nil, status.Error(codes.NotFound, "microvm not found"))
+
+	client, err := newFactory(fakeAPIClient, retry.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond})("host1:9090")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = client.GetMicroVM(context.TODO(), &flintlockv1.GetMicroVMRequest{})
+	g.Expect(status.Code(err)).To(Equal(codes.NotFound))
+	g.Expect(fakeAPIClient.GetMicroVMCallCount()).To(Equal(1))
+}
+
+func TestFactoryFunc_Timeout(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeAPIClient := &fakes.FakeClient{}
+	fakeAPIClient.DeleteMicroVMStub = func(
+		ctx context.Context,
+		_ *flintlockv1.DeleteMicroVMRequest,
+		_ ...grpc.CallOption,
+	) (*emptypb.Empty, error) {
+		<-ctx.Done()
+
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	client, err := newFactory(fakeAPIClient, retry.Policy{Timeout: 20 * time.Millisecond})("host1:9090")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	start := time.Now()
+	_, err = client.DeleteMicroVM(context.TODO(), &flintlockv1.DeleteMicroVMRequest{})
+	g.Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded))
+	g.Expect(time.Since(start)).To(BeNumerically("<", time.Second), "Expected a hung call to be bounded")
+}
+
+func TestUnreachable(t *testing.T) {
+	g := NewWithT(t)
+
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	g.Expect(retry.Unreachable(unavailable)).To(BeTrue())
+	g.Expect(retry.Unreachable(fmt.Errorf("creating microvm: %w", unavailable))).To(BeTrue(), "Expected a wrapped error to be unwrapped")
+	g.Expect(retry.Unreachable(status.Error(codes.DeadlineExceeded, "deadline exceeded"))).To(BeTrue())
+	g.Expect(retry.Unreachable(status.Error(codes.NotFound, "not found"))).To(BeFalse())
+	g.Expect(retry.Unreachable(errors.New("disk failure"))).To(BeFalse())
+	g.Expect(retry.Unreachable(nil)).To(BeFalse())
+}
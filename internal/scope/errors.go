@@ -6,8 +6,9 @@ package scope
 import "errors"
 
 var (
-	errMicrovmRequired = errors.New("microvm required to create scope")
-	errClientRequired  = errors.New("controller-runtime client required to create scope")
+	errMicrovmRequired         = errors.New("microvm required to create scope")
+	errMicrovmTemplateRequired = errors.New("microvmtemplate required to create scope")
+	errClientRequired          = errors.New("controller-runtime client required to create scope")
 )
 
 type tlsError struct {
@@ -5,25 +5,50 @@ package scope
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
 
 	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
 	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	"github.com/weaveworks-liquidmetal/flintlock/client/cloudinit/userdata"
+	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
 	"sigs.k8s.io/cluster-api/util/conditions"
-	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/go-logr/logr"
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
 	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/dryrun"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestaddr"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/imagepin"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/ipam"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/phonehome"
 )
 
 const ProviderPrefix = "microvm://"
 
+const (
+	defaultProbePeriod           = 10 * time.Second
+	defaultProbeFailureThreshold = 3
+	defaultGuestInfoPeriod       = time.Minute
+)
+
+// vendorDataKey is the metadata key flintlock holds the vendor data of a VM
+// under, which includes the users and their SSH keys.
+const vendorDataKey = "vendor-data"
+
 const (
 	tlsCert = "tls.crt"
 	tlsKey  = "tls.key"
@@ -36,6 +61,10 @@ type MicrovmScopeParams struct {
 
 	Client  client.Client
 	Context context.Context //nolint: containedctx // don't care
+
+	// DefaultTLSSecretRef is used when the Microvm does not set its own
+	// TLSSecretRef. Its namespace defaults to that of the Microvm.
+	DefaultTLSSecretRef *corev1.SecretReference
 }
 
 type MicrovmScope struct {
@@ -44,9 +73,19 @@ type MicrovmScope struct {
 	MicroVM *infrav1.Microvm
 
 	client         client.Client
-	patchHelper    *patch.Helper
+	patchHelper    *apply.Helper
 	controllerName string
 	ctx            context.Context
+
+	defaultTLSSecretRef *corev1.SecretReference
+
+	// ipLeases are the leases of the addresses allocated from pools in this
+	// reconcile, keyed by guest device name.
+	ipLeases map[string]ipam.Lease
+
+	// phoneHomeURL is the URL, with its one-time token, which the VM created
+	// in this reconcile calls back to.
+	phoneHomeURL string
 }
 
 func NewMicrovmScope(params MicrovmScopeParams) (*MicrovmScope, error) {
@@ -58,7 +97,7 @@ func NewMicrovmScope(params MicrovmScopeParams) (*MicrovmScope, error) {
 		return nil, errClientRequired
 	}
 
-	patchHelper, err := patch.NewHelper(params.MicroVM, params.Client)
+	patchHelper, err := apply.NewHelper(params.MicroVM, params.Client)
 	if err != nil {
 		return nil, fmt.Errorf("creating patch helper for microvm: %w", err)
 	}
@@ -70,6 +109,8 @@ func NewMicrovmScope(params MicrovmScopeParams) (*MicrovmScope, error) {
 		Logger:         params.Logger,
 		patchHelper:    patchHelper,
 		ctx:            params.Context,
+
+		defaultTLSSecretRef: params.DefaultTLSSecretRef,
 	}
 
 	return scope, nil
@@ -95,9 +136,13 @@ func (m *MicrovmScope) GetInstanceID() string {
 	return parsed.ID()
 }
 
-// GetMicrovmSpec returns the spec for the MicroVM
+// GetMicrovmSpec returns the spec for the MicroVM, with the images given by tag
+// replaced by the digests they were resolved to.
 func (m *MicrovmScope) GetMicrovmSpec() microvm.VMSpec {
-	return m.MicroVM.Spec.VMSpec
+	spec := *m.MicroVM.Spec.VMSpec.DeepCopy()
+	imagepin.Pin(&spec, m.MicroVM.Status.ResolvedImages)
+
+	return spec
 }
 
 // SetProviderID saves the unique microvm and object ID to the Mvm spec.
@@ -106,6 +151,39 @@ func (m *MicrovmScope) SetProviderID(mvmUID string) {
 	m.MicroVM.Spec.ProviderID = &providerID
 }
 
+// SetPreviousProviderID records the provider ID of the VM which is about to be
+// replaced.
+func (m *MicrovmScope) SetPreviousProviderID(providerID string) {
+	m.MicroVM.Status.PreviousProviderID = providerID
+}
+
+// SetHostAddress records the address the host endpoint resolved to when the
+// host last answered a call.
+func (m *MicrovmScope) SetHostAddress(address string) {
+	m.MicroVM.Status.HostAddress = address
+}
+
+// ProviderEndpoint returns the host endpoint recorded in the provider ID, or
+// an empty string if there is no provider ID.
+func (m *MicrovmScope) ProviderEndpoint() string {
+	id := strings.TrimPrefix(m.GetProviderID(), ProviderPrefix)
+
+	i := strings.LastIndex(id, "/")
+	if i < 0 {
+		return ""
+	}
+
+	return id[:i]
+}
+
+// HostEndpointChanged returns true if the host endpoint on the spec is not the
+// one recorded in the provider ID, such as when a host has been renumbered.
+func (m *MicrovmScope) HostEndpointChanged() bool {
+	endpoint := m.ProviderEndpoint()
+
+	return endpoint != "" && endpoint != m.MicroVM.Spec.Host.Endpoint
+}
+
 // GetProviderID returns the provider if for the vm. If there is no provider id
 // then an empty string will be returned.
 func (m *MicrovmScope) GetProviderID() string {
@@ -125,9 +203,26 @@ func (m *MicrovmScope) GetSSHPublicKeys() []microvm.SSHPublicKey {
 	return nil
 }
 
-// GetLabels returns any user defined or default labels for the microvm.
+// GetLabels returns any user defined or default labels for the microvm, along
+// with the MicrovmUIDLabel which marks the VM as owned by this Microvm, and the
+// MicrovmNamespaceLabel when the VM is created in another namespace.
 func (m *MicrovmScope) GetLabels() map[string]string {
-	return m.MicroVM.Spec.Labels
+	if m.MicroVM.UID == "" {
+		return m.MicroVM.Spec.Labels
+	}
+
+	labels := make(map[string]string, len(m.MicroVM.Spec.Labels)+1)
+	for k, v := range m.MicroVM.Spec.Labels {
+		labels[k] = v
+	}
+
+	labels[infrav1.MicrovmUIDLabel] = string(m.MicroVM.UID)
+
+	if m.MicroVM.Spec.HostVMNamespace != "" {
+		labels[infrav1.MicrovmNamespaceLabel] = m.MicroVM.Namespace
+	}
+
+	return labels
 }
 
 // GetRawBootstrapData will return any scripts intended to run on the microvm
@@ -171,25 +266,40 @@ func (m *MicrovmScope) GetBasicAuthToken() (string, error) {
 
 // GetTLSConfig will fetch the TLSSecretRef and CASecretRef for the MicroVM
 // and return the TLS config for the client.
-// If either are not set, it will be assumed that the host is not
-// configured will TLS and all client calls will be made without credentials.
+// If either are not set, and there is no default secret, it will be assumed
+// that the host is not configured will TLS and all client calls will be made
+// without credentials.
 func (m *MicrovmScope) GetTLSConfig() (*flclient.TLSConfig, error) {
-	if m.MicroVM.Spec.TLSSecretRef == "" {
-		m.V(2).Info("no TLS configuration found. will create insecure connection")
-
-		return nil, nil
-	}
-
 	secretKey := types.NamespacedName{
 		Name:      m.MicroVM.Spec.TLSSecretRef,
 		Namespace: m.MicroVM.Namespace,
 	}
 
+	if secretKey.Name == "" && m.defaultTLSSecretRef != nil {
+		secretKey.Name = m.defaultTLSSecretRef.Name
+
+		if m.defaultTLSSecretRef.Namespace != "" {
+			secretKey.Namespace = m.defaultTLSSecretRef.Namespace
+		}
+	}
+
+	if secretKey.Name == "" {
+		m.V(logging.DebugLevel).Info("no TLS configuration found. will create insecure connection")
+
+		return nil, nil
+	}
+
 	tlsSecret := &corev1.Secret{}
 	if err := m.client.Get(m.ctx, secretKey, tlsSecret); err != nil {
 		return nil, err
 	}
 
+	return TLSConfigFromSecret(tlsSecret)
+}
+
+// TLSConfigFromSecret builds the flintlock client TLS config from a secret
+// holding the client certificate, key and CA certificate.
+func TLSConfigFromSecret(tlsSecret *corev1.Secret) (*flclient.TLSConfig, error) {
 	certBytes, ok := tlsSecret.Data[tlsCert]
 	if !ok {
 		return nil, &tlsError{tlsCert}
@@ -212,6 +322,848 @@ func (m *MicrovmScope) GetTLSConfig() (*flclient.TLSConfig, error) {
 	}, nil
 }
 
+// GracefulShutdown returns the shutdown configuration, or nil if the Microvm
+// should be deleted immediately.
+func (m *MicrovmScope) GracefulShutdown() *infrav1.GracefulShutdown {
+	return m.MicroVM.Spec.GracefulShutdown
+}
+
+// ShutdownRequestedAt returns when the guest was asked to shut down, or nil if
+// it has not been.
+func (m *MicrovmScope) ShutdownRequestedAt() *metav1.Time {
+	return m.MicroVM.Status.ShutdownRequestedAt
+}
+
+// SetShutdownRequested records that the guest has been asked to shut down.
+func (m *MicrovmScope) SetShutdownRequested(at time.Time) {
+	requested := metav1.NewTime(at)
+	m.MicroVM.Status.ShutdownRequestedAt = &requested
+}
+
+// ClearShutdownRequested removes the record of a shutdown request, once the
+// VM it was made for has been deleted to be recreated.
+func (m *MicrovmScope) ClearShutdownRequested() {
+	m.MicroVM.Status.ShutdownRequestedAt = nil
+}
+
+// DeletionStartedAt returns when the VM was first seen deleting on its host,
+// or nil if it has not been.
+func (m *MicrovmScope) DeletionStartedAt() *metav1.Time {
+	return m.MicroVM.Status.DeletionStartedAt
+}
+
+// SetDeletionStarted records that the VM is deleting on its host, keeping the
+// time it was first seen to be.
+func (m *MicrovmScope) SetDeletionStarted(at time.Time) {
+	if m.MicroVM.Status.DeletionStartedAt == nil {
+		started := metav1.NewTime(at)
+		m.MicroVM.Status.DeletionStartedAt = &started
+	}
+}
+
+// ClearDeletionStarted removes the record of a delete, once the VM on the host
+// is no longer deleting.
+func (m *MicrovmScope) ClearDeletionStarted() {
+	m.MicroVM.Status.DeletionStartedAt = nil
+}
+
+// DeleteProtected returns true if the VM of the Microvm must not be deleted
+// from its host.
+func (m *MicrovmScope) DeleteProtected() bool {
+	return m.MicroVM.Annotations[infrav1.DeleteProtectionAnnotation] == "true"
+}
+
+// ForceDeleted returns true if the Microvm may be deleted without deleting its
+// VM from an untrusted host.
+func (m *MicrovmScope) ForceDeleted() bool {
+	return m.MicroVM.Annotations[infrav1.ForceDeleteAnnotation] == "true"
+}
+
+// SetDeleteProtection records whether the VM of the Microvm is protected from
+// deletion.
+func (m *MicrovmScope) SetDeleteProtection() {
+	if !m.DeleteProtected() {
+		conditions.Delete(m.MicroVM, infrav1.MicrovmDeleteAllowedCondition)
+
+		return
+	}
+
+	conditions.MarkFalse(m.MicroVM, infrav1.MicrovmDeleteAllowedCondition, infrav1.MicrovmDeleteProtectedReason,
+		clusterv1.ConditionSeverityInfo, "the %s annotation is set", infrav1.DeleteProtectionAnnotation)
+}
+
+// SetDeleteRefused records that the VM of the protected Microvm was not
+// deleted from its host, for the reason given by action.
+func (m *MicrovmScope) SetDeleteRefused(action string) {
+	conditions.MarkFalse(m.MicroVM, infrav1.MicrovmDeleteAllowedCondition, infrav1.MicrovmDeleteProtectedReason,
+		clusterv1.ConditionSeverityWarning, "refused to delete the VM from its host %s: remove the %s annotation to allow it",
+		action, infrav1.DeleteProtectionAnnotation)
+}
+
+// ShutdownDeadline returns when the grace period for a requested shutdown ends.
+func (m *MicrovmScope) ShutdownDeadline() time.Time {
+	grace := time.Duration(m.GracefulShutdown().GracePeriodSeconds) * time.Second
+
+	return m.ShutdownRequestedAt().Add(grace)
+}
+
+// ExternalResources returns the resources outside flintlock which were created
+// for the Microvm and have not been released.
+func (m *MicrovmScope) ExternalResources() []infrav1.ExternalResourceRef {
+	return m.MicroVM.Status.ExternalResources
+}
+
+// TrackExternalResource records a resource created for the Microvm so that it
+// is released when the Microvm is deleted. Tracking it again has no effect.
+func (m *MicrovmScope) TrackExternalResource(ref infrav1.ExternalResourceRef) {
+	for _, tracked := range m.MicroVM.Status.ExternalResources {
+		if tracked == ref {
+			return
+		}
+	}
+
+	m.MicroVM.Status.ExternalResources = append(m.MicroVM.Status.ExternalResources, ref)
+}
+
+// SetExternalResources replaces the tracked resources, eg with those still held
+// after releasing the rest.
+func (m *MicrovmScope) SetExternalResources(refs []infrav1.ExternalResourceRef) {
+	if len(refs) == 0 {
+		refs = nil
+	}
+
+	m.MicroVM.Status.ExternalResources = refs
+}
+
+// UntrackExternalResource forgets a resource which has been released before
+// the Microvm is deleted.
+func (m *MicrovmScope) UntrackExternalResource(ref infrav1.ExternalResourceRef) {
+	refs := []infrav1.ExternalResourceRef{}
+
+	for _, tracked := range m.MicroVM.Status.ExternalResources {
+		if tracked != ref {
+			refs = append(refs, tracked)
+		}
+	}
+
+	m.SetExternalResources(refs)
+}
+
+// SSHService returns the Service asked for the SSH port of the guest, or nil
+// if there should be none.
+func (m *MicrovmScope) SSHService() *infrav1.SSHService {
+	return m.MicroVM.Spec.SSHService
+}
+
+// SSHServiceName returns the name of the Service created for the SSH port of
+// the guest, or an empty string if none has been.
+func (m *MicrovmScope) SSHServiceName() string {
+	return m.MicroVM.Status.SSHServiceName
+}
+
+// SetSSHServiceName records the name of the Service created for the SSH port
+// of the guest.
+func (m *MicrovmScope) SetSSHServiceName(name string) {
+	m.MicroVM.Status.SSHServiceName = name
+}
+
+// RestartRequested returns true if the restartedAt annotation has been set to
+// a value the VM was not created after.
+func (m *MicrovmScope) RestartRequested() bool {
+	restartedAt := m.MicroVM.Annotations[infrav1.RestartedAtAnnotation]
+
+	return restartedAt != "" && restartedAt != m.MicroVM.Status.RestartedAt
+}
+
+// SetRestarted records the value of the restartedAt annotation as the VM about
+// to be created is created after it.
+func (m *MicrovmScope) SetRestarted() {
+	m.MicroVM.Status.RestartedAt = m.MicroVM.Annotations[infrav1.RestartedAtAnnotation]
+}
+
+// PendingPoll returns how often the VM is checked while it is waiting to be
+// created or to become ready, which is the given period unless the Microvm
+// sets its own.
+func (m *MicrovmScope) PendingPoll(period time.Duration) time.Duration {
+	if intervals := m.MicroVM.Spec.ReconcileIntervals; intervals != nil && intervals.PendingPollSeconds > 0 {
+		return time.Duration(intervals.PendingPollSeconds) * time.Second
+	}
+
+	return period
+}
+
+// RunningResync returns how often the VM is checked once it has been created,
+// or 0 if it is only checked when the Microvm changes.
+func (m *MicrovmScope) RunningResync() time.Duration {
+	if intervals := m.MicroVM.Spec.ReconcileIntervals; intervals != nil && intervals.RunningResyncSeconds > 0 {
+		return time.Duration(intervals.RunningResyncSeconds) * time.Second
+	}
+
+	return 0
+}
+
+// DeletingPoll returns how often the VM is checked while it is being deleted,
+// which is the given period unless the Microvm sets its own.
+func (m *MicrovmScope) DeletingPoll(period time.Duration) time.Duration {
+	if intervals := m.MicroVM.Spec.ReconcileIntervals; intervals != nil && intervals.DeletingPollSeconds > 0 {
+		return time.Duration(intervals.DeletingPollSeconds) * time.Second
+	}
+
+	return period
+}
+
+// LivenessProbe returns the liveness probe of the guest, or nil if it is not
+// probed.
+func (m *MicrovmScope) LivenessProbe() *infrav1.LivenessProbe {
+	return m.MicroVM.Spec.LivenessProbe
+}
+
+// RestartOnFailure returns true if the VM should be recreated when its guest
+// fails the liveness probe.
+func (m *MicrovmScope) RestartOnFailure() bool {
+	return m.MicroVM.Spec.RestartPolicy == infrav1.RestartPolicyAlways
+}
+
+// ProbePeriod returns how often the guest is probed.
+func (m *MicrovmScope) ProbePeriod() time.Duration {
+	if period := m.LivenessProbe().PeriodSeconds; period > 0 {
+		return time.Duration(period) * time.Second
+	}
+
+	return defaultProbePeriod
+}
+
+// NextProbe returns how long until the guest is due to be probed again, which
+// is never sooner than the initial delay after the Microvm last became ready.
+func (m *MicrovmScope) NextProbe(now time.Time) time.Duration {
+	due := time.Time{}
+
+	if ready := conditions.GetLastTransitionTime(m.MicroVM, infrav1.MicrovmReadyCondition); ready != nil {
+		due = ready.Add(time.Duration(m.LivenessProbe().InitialDelaySeconds) * time.Second)
+	}
+
+	if health := m.MicroVM.Status.GuestHealth; health != nil && health.LastProbeTime != nil {
+		if next := health.LastProbeTime.Add(m.ProbePeriod()); next.After(due) {
+			due = next
+		}
+	}
+
+	if !due.After(now) {
+		return 0
+	}
+
+	return due.Sub(now)
+}
+
+// RecordProbe records the result of probing the guest at the given time. It
+// returns true once the guest has failed FailureThreshold probes in a row.
+func (m *MicrovmScope) RecordProbe(at time.Time, passed bool) bool {
+	if m.MicroVM.Status.GuestHealth == nil {
+		m.MicroVM.Status.GuestHealth = &infrav1.GuestHealthStatus{}
+	}
+
+	health := m.MicroVM.Status.GuestHealth
+	probed := metav1.NewTime(at)
+	health.LastProbeTime = &probed
+
+	if passed {
+		health.ConsecutiveFailures = 0
+
+		return false
+	}
+
+	health.ConsecutiveFailures++
+
+	threshold := m.LivenessProbe().FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultProbeFailureThreshold
+	}
+
+	return health.ConsecutiveFailures >= threshold
+}
+
+// RecordRestart records that the VM has been deleted to be created again, so
+// that probing starts afresh once it is ready.
+func (m *MicrovmScope) RecordRestart() {
+	if m.MicroVM.Status.GuestHealth == nil {
+		m.MicroVM.Status.GuestHealth = &infrav1.GuestHealthStatus{}
+	}
+
+	health := m.MicroVM.Status.GuestHealth
+	health.Restarts++
+	health.ConsecutiveFailures = 0
+	health.LastProbeTime = nil
+}
+
+// SetGuestHealthy marks the guest as passing its liveness probe.
+func (m *MicrovmScope) SetGuestHealthy() {
+	conditions.MarkTrue(m.MicroVM, infrav1.MicrovmGuestHealthyCondition)
+}
+
+// SetGuestUnhealthy marks the guest as failing its liveness probe.
+func (m *MicrovmScope) SetGuestUnhealthy(message string) {
+	conditions.MarkFalse(m.MicroVM, infrav1.MicrovmGuestHealthyCondition,
+		infrav1.MicrovmGuestUnhealthyReason, clusterv1.ConditionSeverityWarning, "%s", message)
+}
+
+// GuestAgent returns the agent which is asked about the guest, or nil if the
+// guest has none.
+func (m *MicrovmScope) GuestAgent() *infrav1.GuestAgent {
+	return m.MicroVM.Spec.GuestAgent
+}
+
+// GuestInfoPeriod returns how often the guest agent is asked about the guest.
+func (m *MicrovmScope) GuestInfoPeriod() time.Duration {
+	if period := m.GuestAgent().PeriodSeconds; period > 0 {
+		return time.Duration(period) * time.Second
+	}
+
+	return defaultGuestInfoPeriod
+}
+
+// NextGuestInfo returns how long until the guest agent is due to be asked
+// about the guest again.
+func (m *MicrovmScope) NextGuestInfo(now time.Time) time.Duration {
+	info := m.MicroVM.Status.GuestInfo
+	if info == nil || info.LastUpdateTime == nil {
+		return 0
+	}
+
+	due := info.LastUpdateTime.Add(m.GuestInfoPeriod())
+	if !due.After(now) {
+		return 0
+	}
+
+	return due.Sub(now)
+}
+
+// GuestInfo returns what the guest agent last reported about the guest, or nil
+// if it has not reported on the current VM.
+func (m *MicrovmScope) GuestInfo() *infrav1.GuestInfo {
+	return m.MicroVM.Status.GuestInfo
+}
+
+// SetGuestInfo records what the guest agent reported about the guest, and
+// marks the agent reachable. It is cleared with nil when the VM is created, so
+// that nothing is read from the guest of an earlier VM.
+func (m *MicrovmScope) SetGuestInfo(info *infrav1.GuestInfo) {
+	m.MicroVM.Status.GuestInfo = info
+
+	if info != nil {
+		conditions.MarkTrue(m.MicroVM, infrav1.MicrovmGuestAgentReachableCondition)
+	}
+}
+
+// ReadinessGate returns what the Microvm waits for before it is marked ready.
+func (m *MicrovmScope) ReadinessGate() infrav1.ReadinessGate {
+	return m.MicroVM.Spec.ReadinessGate
+}
+
+// CloudInitState returns how far cloud-init has got in the guest, as last
+// reported, or an empty state if nothing has been. A guest which has called
+// back has finished, unless its agent says cloud-init failed.
+func (m *MicrovmScope) CloudInitState() infrav1.CloudInitState {
+	state := infrav1.CloudInitState("")
+	if m.MicroVM.Status.GuestInfo != nil {
+		state = m.MicroVM.Status.GuestInfo.CloudInit
+	}
+
+	if phoneHome := m.MicroVM.Status.PhoneHome; phoneHome != nil && phoneHome.CalledAt != nil &&
+		state != infrav1.CloudInitError {
+		return infrav1.CloudInitDone
+	}
+
+	return state
+}
+
+// SetPhoneHomeToken records the hash of the one-time token the VM about to be
+// created calls back with, and the URL it calls, or forgets any earlier
+// callback when both are empty.
+func (m *MicrovmScope) SetPhoneHomeToken(hash, url string) {
+	m.phoneHomeURL = url
+
+	if hash == "" {
+		m.MicroVM.Status.PhoneHome = nil
+
+		return
+	}
+
+	m.MicroVM.Status.PhoneHome = &infrav1.PhoneHomeStatus{TokenHash: hash}
+}
+
+// PhoneHomeURL returns the URL the VM created in this reconcile calls back to,
+// or an empty string if it is not asked to.
+func (m *MicrovmScope) PhoneHomeURL() string {
+	return m.phoneHomeURL
+}
+
+// WaitsForPhoneHome returns true if the current VM was given a token to call
+// back with which it has not used yet.
+func (m *MicrovmScope) WaitsForPhoneHome() bool {
+	phoneHome := m.MicroVM.Status.PhoneHome
+
+	return phoneHome != nil && phoneHome.TokenHash != ""
+}
+
+// RecordPhoneHome records the callback of the guest, if it was made with the
+// token of the current VM, and removes it from the annotations. It returns
+// false for a callback of an earlier VM, which is only removed.
+func (m *MicrovmScope) RecordPhoneHome(callback *phonehome.Callback) bool {
+	delete(m.MicroVM.Annotations, phonehome.CallbackAnnotation)
+
+	if !m.WaitsForPhoneHome() || m.MicroVM.Status.PhoneHome.TokenHash != callback.TokenHash {
+		return false
+	}
+
+	calledAt := callback.CalledAt
+	m.MicroVM.Status.PhoneHome = &infrav1.PhoneHomeStatus{
+		CalledAt:   &calledAt,
+		InstanceID: callback.InstanceID,
+		Hostname:   callback.Hostname,
+		FQDN:       callback.FQDN,
+	}
+
+	return true
+}
+
+// SetGuestAgentUnreachable marks the guest agent as not answering. What it
+// last reported is kept.
+func (m *MicrovmScope) SetGuestAgentUnreachable(message string) {
+	conditions.MarkFalse(m.MicroVM, infrav1.MicrovmGuestAgentReachableCondition,
+		infrav1.MicrovmGuestAgentUnreachableReason, clusterv1.ConditionSeverityWarning, "%s", message)
+}
+
+// RestoreFrom returns the name of the MicrovmSnapshot the Microvm is cloned
+// from, or an empty string if it is not.
+func (m *MicrovmScope) RestoreFrom() string {
+	if m.MicroVM.Spec.RestoreFrom == nil {
+		return ""
+	}
+
+	return m.MicroVM.Spec.RestoreFrom.Name
+}
+
+// RestoreSnapshot replaces the vcpu, memory, kernel, initrd and volumes of the
+// VM spec with those of a snapshot. The network interfaces and labels belong
+// to this Microvm and are kept.
+func (m *MicrovmScope) RestoreSnapshot(snapshot *microvm.VMSpec) {
+	restored := snapshot.DeepCopy()
+	spec := &m.MicroVM.Spec.VMSpec
+
+	spec.VCPU = restored.VCPU
+	spec.MemoryMb = restored.MemoryMb
+	spec.Kernel = restored.Kernel
+	spec.KernelCmdLine = restored.KernelCmdLine
+	spec.Initrd = restored.Initrd
+	spec.RootVolume = restored.RootVolume
+	spec.AdditionalVolumes = restored.AdditionalVolumes
+}
+
+// MACPool returns the name of the MicrovmMACPool which gives the network
+// interfaces their addresses, or an empty string if there is none.
+func (m *MicrovmScope) MACPool() string {
+	if m.MicroVM.Spec.MACPoolRef == nil {
+		return ""
+	}
+
+	return m.MicroVM.Spec.MACPoolRef.Name
+}
+
+// InterfacesWithoutMAC returns the guest device names of the network
+// interfaces which have no MAC address.
+func (m *MicrovmScope) InterfacesWithoutMAC() []string {
+	devices := []string{}
+
+	for _, iface := range m.MicroVM.Spec.NetworkInterfaces {
+		if iface.GuestMAC == "" {
+			devices = append(devices, iface.GuestDeviceName)
+		}
+	}
+
+	return devices
+}
+
+// SetGuestMAC sets the MAC address of the network interface with the guest
+// device name.
+func (m *MicrovmScope) SetGuestMAC(device, mac string) {
+	for i := range m.MicroVM.Spec.NetworkInterfaces {
+		if iface := &m.MicroVM.Spec.NetworkInterfaces[i]; iface.GuestDeviceName == device {
+			iface.GuestMAC = mac
+		}
+	}
+}
+
+// InterfaceAddress returns the address of the network interface with the guest
+// device name, and false if there is no such interface.
+func (m *MicrovmScope) InterfaceAddress(device string) (string, bool) {
+	for _, iface := range m.MicroVM.Spec.NetworkInterfaces {
+		if iface.GuestDeviceName == device {
+			return iface.Address, true
+		}
+	}
+
+	return "", false
+}
+
+// GuestAddresses returns the addresses of the network interfaces of the guest,
+// which are the only addresses the operator makes requests to on its behalf.
+func (m *MicrovmScope) GuestAddresses() []netip.Addr {
+	return guestaddr.Addresses(m.MicroVM.Spec.NetworkInterfaces)
+}
+
+// SetIPLease sets the address of the network interface with the guest device
+// name to that of lease, and records lease for the VM to be created with.
+func (m *MicrovmScope) SetIPLease(device string, lease ipam.Lease) {
+	for i := range m.MicroVM.Spec.NetworkInterfaces {
+		if iface := &m.MicroVM.Spec.NetworkInterfaces[i]; iface.GuestDeviceName == device {
+			iface.Address = lease.Address
+		}
+	}
+
+	if m.ipLeases == nil {
+		m.ipLeases = map[string]ipam.Lease{}
+	}
+
+	m.ipLeases[device] = lease
+}
+
+// ImagePolicy returns how the images of the VM are pinned to digests.
+func (m *MicrovmScope) ImagePolicy() infrav1.ImagePolicy {
+	return m.MicroVM.Spec.ImagePolicy
+}
+
+// ResolvedImages returns the digests the images given by tag were resolved to.
+func (m *MicrovmScope) ResolvedImages() map[string]string {
+	return m.MicroVM.Status.ResolvedImages
+}
+
+// SetResolvedImages records the digests the images given by tag were resolved
+// to, which the VM is created from.
+func (m *MicrovmScope) SetResolvedImages(resolved map[string]string) {
+	if len(resolved) == 0 {
+		resolved = nil
+	}
+
+	m.MicroVM.Status.ResolvedImages = resolved
+}
+
+// IPLeases returns the leases recorded with SetIPLease, keyed by guest device
+// name.
+func (m *MicrovmScope) IPLeases() map[string]ipam.Lease {
+	return m.ipLeases
+}
+
+// RecreateOnSpecChange returns true if the VM should be recreated now that the
+// drifted fields of its spec no longer match the VM on the host.
+func (m *MicrovmScope) RecreateOnSpecChange(drifted []string) bool {
+	switch m.MicroVM.Spec.UpdateStrategy {
+	case infrav1.UpdateStrategyRecreate:
+		return true
+	case infrav1.UpdateStrategyResize:
+		return Resized(drifted)
+	default:
+		return false
+	}
+}
+
+// Resized returns true if the vcpu or memory are among the drifted fields.
+func Resized(drifted []string) bool {
+	for _, field := range drifted {
+		if field == "vcpu" || field == "memoryMb" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SpecDrift returns the fields of the VM spec which differ from the VM which
+// flintlock has on the host. Values which flintlock fills in itself, such as
+// MAC addresses and default kernel args, are not compared. There is no drift
+// when flintlock did not return a spec.
+func (m *MicrovmScope) SpecDrift(actual *flintlocktypes.MicroVMSpec) []string {
+	drifted := []string{}
+
+	if actual == nil {
+		return drifted
+	}
+
+	desired := m.GetMicrovmSpec()
+
+	if desired.VCPU != int64(actual.Vcpu) {
+		drifted = append(drifted, "vcpu")
+	}
+
+	if desired.MemoryMb != int64(actual.MemoryInMb) {
+		drifted = append(drifted, "memoryMb")
+	}
+
+	if actual.Kernel == nil ||
+		!sameSource(desired.Kernel, actual.Kernel.Image, actual.Kernel.Filename) {
+		drifted = append(drifted, "kernel")
+	} else if !sameCmdLine(desired.KernelCmdLine, actual.Kernel.Cmdline) {
+		drifted = append(drifted, "kernelCmdline")
+	}
+
+	switch {
+	case desired.Initrd == nil && actual.Initrd == nil:
+	case desired.Initrd == nil || actual.Initrd == nil,
+		!sameSource(*desired.Initrd, actual.Initrd.Image, actual.Initrd.Filename):
+		drifted = append(drifted, "initrd")
+	}
+
+	if !sameVolume(desired.RootVolume, actual.RootVolume) {
+		drifted = append(drifted, "rootVolume")
+	}
+
+	if !sameVolumes(desired.AdditionalVolumes, actual.AdditionalVolumes) {
+		drifted = append(drifted, "volumes")
+	}
+
+	if !sameInterfaces(desired.NetworkInterfaces, actual.Interfaces) {
+		drifted = append(drifted, "networkInterfaces")
+	}
+
+	return drifted
+}
+
+// SetSpecSynced marks the VM on the host as matching the spec.
+func (m *MicrovmScope) SetSpecSynced() {
+	conditions.MarkTrue(m.MicroVM, infrav1.MicrovmSpecSyncedCondition)
+}
+
+// SetSpecDrifted marks the VM on the host as no longer matching the spec.
+func (m *MicrovmScope) SetSpecDrifted(fields []string) {
+	conditions.MarkFalse(m.MicroVM, infrav1.MicrovmSpecSyncedCondition,
+		infrav1.MicrovmSpecDriftedReason, clusterv1.ConditionSeverityWarning,
+		"%s changed since the VM was created", strings.Join(fields, ", "))
+}
+
+// SSHKeysDrift returns the users whose SSH keys differ between the spec and the
+// vendor data of the VM which flintlock has on the host, sorted by name. It
+// returns false if the keys the VM was created with cannot be read.
+func (m *MicrovmScope) SSHKeysDrift(actual *flintlocktypes.MicroVMSpec) ([]string, bool) {
+	applied, ok := appliedSSHKeys(actual.Metadata[vendorDataKey])
+	if !ok {
+		return nil, false
+	}
+
+	desired := map[string][]string{}
+	for _, key := range m.GetSSHPublicKeys() {
+		desired[key.User] = append(desired[key.User], key.AuthorizedKeys...)
+	}
+
+	drifted := []string{}
+
+	for user, keys := range desired {
+		if !sameKeys(keys, applied[user]) {
+			drifted = append(drifted, user)
+		}
+	}
+
+	for user := range applied {
+		if _, ok := desired[user]; !ok {
+			drifted = append(drifted, user)
+		}
+	}
+
+	sort.Strings(drifted)
+
+	return drifted, true
+}
+
+// SetSSHKeysSynced marks the SSH keys in the guest as matching the spec.
+func (m *MicrovmScope) SetSSHKeysSynced() {
+	conditions.MarkTrue(m.MicroVM, infrav1.MicrovmSSHKeysSyncedCondition)
+}
+
+// SetSSHKeysOutdated marks the SSH keys of users in the guest as no longer
+// matching the spec, without them being rolled out.
+func (m *MicrovmScope) SetSSHKeysOutdated(users []string) {
+	conditions.MarkFalse(m.MicroVM, infrav1.MicrovmSSHKeysSyncedCondition,
+		infrav1.MicrovmSSHKeysOutdatedReason, clusterv1.ConditionSeverityWarning,
+		"keys of %s changed since the VM was created", strings.Join(users, ", "))
+}
+
+// SetSSHKeysRotating marks the SSH keys of users as being rolled out by
+// recreating the VM. The condition stays until the new VM has been checked.
+func (m *MicrovmScope) SetSSHKeysRotating(users []string) {
+	conditions.MarkFalse(m.MicroVM, infrav1.MicrovmSSHKeysSyncedCondition,
+		infrav1.MicrovmSSHKeysRotatingReason, clusterv1.ConditionSeverityInfo,
+		"recreating the VM with the new keys of %s", strings.Join(users, ", "))
+}
+
+// appliedSSHKeys reads the SSH keys of each user from the vendor data of a VM.
+func appliedSSHKeys(vendorData string) (map[string][]string, bool) {
+	if vendorData == "" {
+		return nil, false
+	}
+
+	data, err := base64.StdEncoding.DecodeString(vendorData)
+	if err != nil {
+		return nil, false
+	}
+
+	parsed := &userdata.UserData{}
+	if err := yaml.Unmarshal(data, parsed); err != nil {
+		return nil, false
+	}
+
+	keys := map[string][]string{}
+	for _, user := range parsed.Users {
+		keys[user.Name] = append(keys[user.Name], user.SSHAuthorizedKeys...)
+	}
+
+	return keys, true
+}
+
+func sameKeys(desired, actual []string) bool {
+	if len(desired) != len(actual) {
+		return false
+	}
+
+	sortedDesired := append([]string{}, desired...)
+	sortedActual := append([]string{}, actual...)
+
+	sort.Strings(sortedDesired)
+	sort.Strings(sortedActual)
+
+	for i := range sortedDesired {
+		if sortedDesired[i] != sortedActual[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameSource(desired microvm.ContainerFileSource, image string, filename *string) bool {
+	if desired.Image != image {
+		return false
+	}
+
+	// flintlock uses its own default when no filename was asked for
+	return desired.Filename == "" || filename == nil || desired.Filename == *filename
+}
+
+func sameCmdLine(desired, actual map[string]string) bool {
+	for arg, value := range desired {
+		if got, ok := actual[arg]; !ok || got != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameVolume(desired microvm.Volume, actual *flintlocktypes.Volume) bool {
+	if actual == nil || actual.Source == nil || actual.Source.ContainerSource == nil {
+		return false
+	}
+
+	return desired.Image == *actual.Source.ContainerSource && desired.ReadOnly == actual.IsReadOnly
+}
+
+func sameVolumes(desired []microvm.Volume, actual []*flintlocktypes.Volume) bool {
+	if len(desired) != len(actual) {
+		return false
+	}
+
+	for i := range desired {
+		if desired[i].ID != actual[i].Id || !sameVolume(desired[i], actual[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameInterfaces(desired []microvm.NetworkInterface, actual []*flintlocktypes.NetworkInterface) bool {
+	if len(desired) != len(actual) {
+		return false
+	}
+
+	for i := range desired {
+		if desired[i].GuestDeviceName != actual[i].DeviceId {
+			return false
+		}
+
+		if desired[i].Address != "" &&
+			(actual[i].Address == nil || desired[i].Address != actual[i].Address.Address) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ProvisioningPhase is a step in provisioning a Microvm whose time is recorded.
+type ProvisioningPhase string
+
+const (
+	PhaseAccepted   ProvisioningPhase = "accepted"
+	PhaseCreateSent ProvisioningPhase = "create_sent"
+	PhasePending    ProvisioningPhase = "pending"
+	PhaseCreated    ProvisioningPhase = "created"
+	PhaseGuestReady ProvisioningPhase = "guest_ready"
+)
+
+// RecordProvisioningPhase records that the Microvm reached phase at, unless it
+// already had. It returns how long after the Microvm was accepted the phase was
+// reached, or after it was created for the accepted phase itself, and whether
+// the phase was newly recorded. Reaching the guest ready phase also records how
+// long the Microvm took to provision and its guest took to boot.
+func (m *MicrovmScope) RecordProvisioningPhase(phase ProvisioningPhase, at time.Time) (time.Duration, bool) {
+	if m.MicroVM.Status.Provisioning == nil {
+		m.MicroVM.Status.Provisioning = &infrav1.ProvisioningTimestamps{}
+	}
+
+	timestamps := m.MicroVM.Status.Provisioning
+
+	var field **metav1.Time
+
+	switch phase {
+	case PhaseAccepted:
+		field = &timestamps.AcceptedAt
+	case PhaseCreateSent:
+		field = &timestamps.CreateSentAt
+	case PhasePending:
+		field = &timestamps.PendingAt
+	case PhaseCreated:
+		field = &timestamps.CreatedAt
+	case PhaseGuestReady:
+		field = &timestamps.GuestReadyAt
+	default:
+		return 0, false
+	}
+
+	if *field != nil {
+		return 0, false
+	}
+
+	reached := metav1.NewTime(at)
+	*field = &reached
+
+	since := m.MicroVM.CreationTimestamp.Time
+	if phase != PhaseAccepted && timestamps.AcceptedAt != nil {
+		since = timestamps.AcceptedAt.Time
+	}
+
+	if since.IsZero() || at.Before(since) {
+		return 0, true
+	}
+
+	if phase == PhaseGuestReady {
+		timestamps.ProvisioningDuration = &metav1.Duration{Duration: at.Sub(since)}
+
+		if timestamps.CreatedAt != nil && !at.Before(timestamps.CreatedAt.Time) {
+			timestamps.BootDuration = &metav1.Duration{Duration: at.Sub(timestamps.CreatedAt.Time)}
+		}
+	}
+
+	return at.Sub(since), true
+}
+
 // SetReady sets any properties/conditions that are used to indicate that the Microvm is 'Ready'.
 func (m *MicrovmScope) SetReady() {
 	conditions.MarkTrue(m.MicroVM, infrav1.MicrovmReadyCondition)
@@ -229,8 +1181,66 @@ func (m *MicrovmScope) SetNotReady(
 	m.MicroVM.Status.Ready = false
 }
 
-// Patch persists the resource and status.
+// SetFailure records why flintlock failed the Microvm.
+func (m *MicrovmScope) SetFailure(reason, message string) {
+	m.MicroVM.Status.FailureReason = &reason
+	m.MicroVM.Status.FailureMessage = &message
+}
+
+// ClearFailure removes the record of a failure, once the Microvm has recovered.
+func (m *MicrovmScope) ClearFailure() {
+	m.MicroVM.Status.FailureReason = nil
+	m.MicroVM.Status.FailureMessage = nil
+}
+
+// SetHostReachable records that the host of the Microvm answered.
+func (m *MicrovmScope) SetHostReachable() {
+	conditions.MarkTrue(m.MicroVM, infrav1.MicrovmHostReachableCondition)
+}
+
+// SetHostUnreachable records that the host of the Microvm did not answer. It
+// leaves the readiness of the Microvm as it was, as it says nothing of the VM.
+func (m *MicrovmScope) SetHostUnreachable(message string) {
+	conditions.MarkFalse(m.MicroVM, infrav1.MicrovmHostReachableCondition,
+		infrav1.MicrovmHostUnreachableReason, clusterv1.ConditionSeverityWarning, "%s", message)
+}
+
+// SetIdentityVerified records that the host returned the VM in the provider ID.
+func (m *MicrovmScope) SetIdentityVerified() {
+	conditions.MarkTrue(m.MicroVM, infrav1.MicrovmIdentityVerifiedCondition)
+}
+
+// SetIdentityMismatch records that the host returned a VM other than the one
+// in the provider ID.
+func (m *MicrovmScope) SetIdentityMismatch(message string) {
+	conditions.MarkFalse(m.MicroVM, infrav1.MicrovmIdentityVerifiedCondition,
+		infrav1.MicrovmIdentityMismatchReason, clusterv1.ConditionSeverityError, "%s", message)
+	m.SetNotReady(infrav1.MicrovmIdentityMismatchReason, clusterv1.ConditionSeverityError, "%s", message)
+}
+
+// SetDryRun records what a reconcile in dry-run mode would have done to the
+// current spec of the Microvm.
+func (m *MicrovmScope) SetDryRun(actions []infrav1.PlannedAction) {
+	m.MicroVM.Status.DryRun = dryrun.Status(m.MicroVM.Generation, actions)
+}
+
+// ClearDryRun removes what was recorded while the Microvm was reconciled in
+// dry-run mode.
+func (m *MicrovmScope) ClearDryRun() {
+	m.MicroVM.Status.DryRun = nil
+}
+
+// SetObservedGeneration records that the current spec of the Microvm has been
+// processed.
+func (m *MicrovmScope) SetObservedGeneration() {
+	m.MicroVM.Status.ObservedGeneration = m.MicroVM.Generation
+}
+
+// Patch sets the phase from the conditions, then persists the resource and
+// status.
 func (m *MicrovmScope) Patch() error {
+	m.MicroVM.Status.Phase = infrav1.GetPhase(m.MicroVM)
+
 	err := m.patchHelper.Patch(
 		m.ctx,
 		m.MicroVM,
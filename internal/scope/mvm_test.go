@@ -1,17 +1,21 @@
 package scope_test
 
 import (
+	"encoding/base64"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr/testr"
 	. "github.com/onsi/gomega"
 
 	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
 	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -62,6 +66,34 @@ func TestMicrovmGetInstanceID(t *testing.T) {
 	Expect(instanceID).To(Equal(uid))
 }
 
+func TestMicrovmGetLabels(t *testing.T) {
+	RegisterTestingT(t)
+
+	scheme, err := setupScheme()
+	Expect(err).NotTo(HaveOccurred())
+
+	mvm := newMicrovm("m-1", "")
+	mvm.UID = "mvm-uid"
+	mvm.Spec.Labels = map[string]string{"app": "web"}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvm).Build()
+	mvmScope, err := scope.NewMicrovmScope(scope.MicrovmScopeParams{
+		Client:  client,
+		MicroVM: mvm,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	Expect(mvmScope.GetLabels()).To(Equal(map[string]string{
+		"app":                   "web",
+		infrav1.MicrovmUIDLabel: "mvm-uid",
+	}))
+	Expect(mvm.Spec.Labels).To(HaveLen(1), "Expected the spec labels not to be modified")
+
+	mvm.Spec.HostVMNamespace = "cluster-a"
+	Expect(mvmScope.GetLabels()).To(HaveKeyWithValue(infrav1.MicrovmNamespaceLabel, mvm.Namespace),
+		"Expected the namespace of a VM created in another namespace to be recorded")
+}
+
 // This is all temporary
 func TestMicrovmGetBasicAuthToken(t *testing.T) {
 	RegisterTestingT(t)
@@ -179,6 +211,7 @@ func TestMicrovmGetTLSConfig(t *testing.T) {
 		expected    func(*flclient.TLSConfig, error)
 		initObjects []client.Object
 		mvm         *infrav1.Microvm
+		defaultRef  *corev1.SecretReference
 	}{
 		{
 			name: "returns the TLS config from the secret",
@@ -215,6 +248,19 @@ func TestMicrovmGetTLSConfig(t *testing.T) {
 				Expect(cfg).To(BeNil())
 			},
 		},
+		{
+			name: "when the TLSSecretRef is not set on the microvm, returns the TLS config from the default secret",
+			initObjects: []client.Object{
+				otherMvmNoTLS, tlsSecret,
+			},
+			mvm:        otherMvmNoTLS,
+			defaultRef: &corev1.SecretReference{Name: tlsSecretName},
+			expected: func(cfg *flclient.TLSConfig, err error) {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg).ToNot(BeNil())
+				Expect(cfg.Cert).To(Equal([]byte("foo")))
+			},
+		},
 		{
 			name: "when the secret data does not contain the `tls.crt` key, returns an error",
 			initObjects: []client.Object{
@@ -235,6 +281,8 @@ func TestMicrovmGetTLSConfig(t *testing.T) {
 				Client:  client,
 				MicroVM: tc.mvm,
 				Logger:  testr.New(t),
+
+				DefaultTLSSecretRef: tc.defaultRef,
 			})
 			Expect(err).NotTo(HaveOccurred())
 
@@ -243,6 +291,139 @@ func TestMicrovmGetTLSConfig(t *testing.T) {
 	}
 }
 
+func TestMicrovmRecordProvisioningPhase(t *testing.T) {
+	RegisterTestingT(t)
+
+	scheme, err := setupScheme()
+	Expect(err).NotTo(HaveOccurred())
+
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mvm := newMicrovm("m-1", "")
+	mvm.CreationTimestamp = metav1.NewTime(created)
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvm).Build()
+	mvmScope, err := scope.NewMicrovmScope(scope.MicrovmScopeParams{
+		Client:  client,
+		MicroVM: mvm,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	took, recorded := mvmScope.RecordProvisioningPhase(scope.PhaseAccepted, created.Add(2*time.Second))
+	Expect(recorded).To(BeTrue())
+	Expect(took).To(Equal(2 * time.Second))
+
+	took, recorded = mvmScope.RecordProvisioningPhase(scope.PhaseCreated, created.Add(12*time.Second))
+	Expect(recorded).To(BeTrue())
+	Expect(took).To(Equal(10*time.Second), "Later phases are measured from acceptance")
+
+	_, recorded = mvmScope.RecordProvisioningPhase(scope.PhaseCreated, created.Add(time.Minute))
+	Expect(recorded).To(BeFalse(), "A phase is only recorded once")
+	Expect(mvm.Status.Provisioning.CreatedAt.Time).To(Equal(created.Add(12 * time.Second)))
+	Expect(mvm.Status.Provisioning.ProvisioningDuration).To(BeNil())
+
+	took, recorded = mvmScope.RecordProvisioningPhase(scope.PhaseGuestReady, created.Add(42*time.Second))
+	Expect(recorded).To(BeTrue())
+	Expect(took).To(Equal(40 * time.Second))
+	Expect(mvm.Status.Provisioning.ProvisioningDuration.Duration).To(Equal(40*time.Second),
+		"Provisioning is measured from acceptance")
+	Expect(mvm.Status.Provisioning.BootDuration.Duration).To(Equal(30*time.Second),
+		"Booting is measured from the VM being created")
+}
+
+func TestMicrovmSpecDrift(t *testing.T) {
+	RegisterTestingT(t)
+
+	scheme, err := setupScheme()
+	Expect(err).NotTo(HaveOccurred())
+
+	mvm := newMicrovmWithSpec("m-1", infrav1.MicrovmSpec{
+		VMSpec: microvm.VMSpec{
+			VCPU:          2,
+			MemoryMb:      2048,
+			Kernel:        microvm.ContainerFileSource{Image: "kernel:1", Filename: "vmlinux"},
+			KernelCmdLine: map[string]string{"console": "ttyS0"},
+			RootVolume:    microvm.Volume{Image: "root:1"},
+			NetworkInterfaces: []microvm.NetworkInterface{
+				{GuestDeviceName: "eth0", Type: microvm.IfaceTypeMacvtap},
+			},
+		},
+	})
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvm).Build()
+	mvmScope, err := scope.NewMicrovmScope(scope.MicrovmScopeParams{
+		Client:  client,
+		MicroVM: mvm,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	actual := &flintlocktypes.MicroVMSpec{
+		Vcpu:       2,
+		MemoryInMb: 2048,
+		Kernel: &flintlocktypes.Kernel{
+			Image:    "kernel:1",
+			Filename: pointer.String("vmlinux"),
+			Cmdline:  map[string]string{"console": "ttyS0", "i8042.noaux": ""},
+		},
+		RootVolume: &flintlocktypes.Volume{
+			Id:     "root",
+			Source: &flintlocktypes.VolumeSource{ContainerSource: pointer.String("root:1")},
+		},
+		Interfaces: []*flintlocktypes.NetworkInterface{
+			{DeviceId: "eth0", GuestMac: pointer.String("aa:bb:cc:dd:ee:ff")},
+		},
+	}
+	Expect(mvmScope.SpecDrift(actual)).To(BeEmpty(), "Values filled in by flintlock are not drift")
+
+	mvm.Spec.VCPU = 4
+	mvm.Spec.Kernel.Image = "kernel:2"
+	Expect(mvmScope.SpecDrift(actual)).To(ConsistOf("vcpu", "kernel"))
+	Expect(mvmScope.SpecDrift(nil)).To(BeEmpty(), "A missing spec is not drift")
+}
+
+func TestMicrovmSSHKeysDrift(t *testing.T) {
+	RegisterTestingT(t)
+
+	scheme, err := setupScheme()
+	Expect(err).NotTo(HaveOccurred())
+
+	mvm := newMicrovmWithSpec("m-1", infrav1.MicrovmSpec{
+		SSHPublicKeys: []microvm.SSHPublicKey{
+			{User: "root", AuthorizedKeys: []string{"ssh-ed25519 a", "ssh-ed25519 b"}},
+		},
+	})
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvm).Build()
+	mvmScope, err := scope.NewMicrovmScope(scope.MicrovmScopeParams{
+		Client:  client,
+		MicroVM: mvm,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	_, known := mvmScope.SSHKeysDrift(&flintlocktypes.MicroVMSpec{})
+	Expect(known).To(BeFalse(), "Keys are unknown without vendor data")
+
+	_, known = mvmScope.SSHKeysDrift(&flintlocktypes.MicroVMSpec{
+		Metadata: map[string]string{"vendor-data": "not base64!"},
+	})
+	Expect(known).To(BeFalse(), "Keys are unknown when the vendor data cannot be read")
+
+	vendorData := base64.StdEncoding.EncodeToString([]byte(
+		"#cloud-config\nusers:\n- name: root\n  ssh_authorized_keys:\n  - ssh-ed25519 b\n  - ssh-ed25519 a\n"))
+
+	drifted, known := mvmScope.SSHKeysDrift(&flintlocktypes.MicroVMSpec{
+		Metadata: map[string]string{"vendor-data": vendorData},
+	})
+	Expect(known).To(BeTrue())
+	Expect(drifted).To(BeEmpty(), "The order of keys is not drift")
+
+	mvm.Spec.SSHPublicKeys[0].AuthorizedKeys = []string{"ssh-ed25519 a"}
+	drifted, _ = mvmScope.SSHKeysDrift(&flintlocktypes.MicroVMSpec{
+		Metadata: map[string]string{"vendor-data": vendorData},
+	})
+	Expect(drifted).To(Equal([]string{"root"}))
+}
+
 func setupScheme() (*runtime.Scheme, error) {
 	scheme := runtime.NewScheme()
 	if err := infrav1.AddToScheme(scheme); err != nil {
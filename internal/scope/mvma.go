@@ -0,0 +1,120 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package scope
+
+import (
+	"context"
+	"fmt"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/go-logr/logr"
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+)
+
+type MicrovmAutoscalerScopeParams struct {
+	Logger            logr.Logger
+	MicrovmAutoscaler *infrav1.MicrovmAutoscaler
+
+	Client  client.Client
+	Context context.Context //nolint: containedctx // don't care
+}
+
+type MicrovmAutoscalerScope struct {
+	logr.Logger
+
+	MicrovmAutoscaler *infrav1.MicrovmAutoscaler
+
+	client         client.Client
+	patchHelper    *apply.Helper
+	controllerName string
+	ctx            context.Context
+}
+
+func NewMicrovmAutoscalerScope(params MicrovmAutoscalerScopeParams) (*MicrovmAutoscalerScope, error) {
+	if params.MicrovmAutoscaler == nil {
+		return nil, errMicrovmRequired
+	}
+
+	if params.Client == nil {
+		return nil, errClientRequired
+	}
+
+	patchHelper, err := apply.NewHelper(params.MicrovmAutoscaler, params.Client)
+	if err != nil {
+		return nil, fmt.Errorf("creating patch helper for microvmautoscaler: %w", err)
+	}
+
+	scope := &MicrovmAutoscalerScope{
+		MicrovmAutoscaler: params.MicrovmAutoscaler,
+		client:            params.Client,
+		controllerName:    defaults.ManagerName,
+		Logger:            params.Logger,
+		patchHelper:       patchHelper,
+		ctx:               params.Context,
+	}
+
+	return scope, nil
+}
+
+// Name returns the MicrovmAutoscaler name.
+func (m *MicrovmAutoscalerScope) Name() string {
+	return m.MicrovmAutoscaler.Name
+}
+
+// Namespace returns the namespace name.
+func (m *MicrovmAutoscalerScope) Namespace() string {
+	return m.MicrovmAutoscaler.Namespace
+}
+
+// TargetName returns the name of the MicrovmDeployment being scaled.
+func (m *MicrovmAutoscalerScope) TargetName() string {
+	return m.MicrovmAutoscaler.Spec.ScaleTargetRef.Name
+}
+
+// MinReplicas returns the lower replica limit, defaulting to 1.
+func (m *MicrovmAutoscalerScope) MinReplicas() int32 {
+	if m.MicrovmAutoscaler.Spec.MinReplicas == nil {
+		return 1
+	}
+
+	return *m.MicrovmAutoscaler.Spec.MinReplicas
+}
+
+// MaxReplicas returns the upper replica limit.
+func (m *MicrovmAutoscalerScope) MaxReplicas() int32 {
+	return m.MicrovmAutoscaler.Spec.MaxReplicas
+}
+
+// SetActive marks the autoscaler as able to read its metric and scale the target.
+func (m *MicrovmAutoscalerScope) SetActive() {
+	conditions.MarkTrue(m.MicrovmAutoscaler, infrav1.MicrovmAutoscalerScalingActiveCondition)
+}
+
+// SetNotActive marks the autoscaler as unable to scale the target.
+func (m *MicrovmAutoscalerScope) SetNotActive(
+	reason string,
+	severity clusterv1.ConditionSeverity,
+	message string,
+	messageArgs ...interface{},
+) {
+	conditions.MarkFalse(m.MicrovmAutoscaler, infrav1.MicrovmAutoscalerScalingActiveCondition, reason, severity, message, messageArgs...)
+}
+
+// Patch persists the resource and status.
+func (m *MicrovmAutoscalerScope) Patch() error {
+	err := m.patchHelper.Patch(
+		m.ctx,
+		m.MicrovmAutoscaler,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to patch microvmautoscaler: %w", err)
+	}
+
+	return nil
+}
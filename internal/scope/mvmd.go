@@ -7,18 +7,41 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
-	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/go-logr/logr"
 	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
 	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/dryrun"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/replica"
 )
 
+const (
+	defaultFailoverAfter    = 300 * time.Second
+	defaultProgressDeadline = 600 * time.Second
+)
+
+// summarisedConditions are the conditions of a deployment which are
+// summarised into its Ready condition.
+var summarisedConditions = []clusterv1.ConditionType{
+	infrav1.MicrovmDeploymentReadyCondition,
+	infrav1.MicrovmDeploymentReplicasReadyCondition,
+	infrav1.MicrovmDeploymentHostsHealthyCondition,
+	infrav1.MicrovmDeploymentTemplateUpToDateCondition,
+	infrav1.MicrovmDeploymentSpreadCondition,
+}
+
 type MicrovmDeploymentScopeParams struct {
 	Logger            logr.Logger
 	MicrovmDeployment *infrav1.MicrovmDeployment
@@ -32,8 +55,31 @@ type MicrovmDeploymentScope struct {
 
 	MicrovmDeployment *infrav1.MicrovmDeployment
 
+	// template is the content of the referenced MicrovmTemplate, if any.
+	template *infrav1.MicrovmTemplateSpec
+	// hostGroup is the referenced MicrovmHostGroup, if any.
+	hostGroup *infrav1.MicrovmHostGroup
+	// selectedHosts are the hosts matched by the host selector, if any.
+	selectedHosts []microvm.Host
+	// failureDomains holds the failure domain of each host, by endpoint.
+	failureDomains map[string]string
+	// unschedulable holds the endpoints of cordoned hosts.
+	unschedulable infrav1.HostMap
+	// failed holds the endpoints of hosts which have been unreachable for
+	// longer than the failover policy allows.
+	failed infrav1.HostMap
+	// preempted holds the endpoints of hosts needed by a higher priority
+	// deployment.
+	preempted infrav1.HostMap
+	// outOfContact holds the endpoints of hosts which the operator has not
+	// been able to reach recently.
+	outOfContact infrav1.HostMap
+	// lastContacts holds when each host last answered a flintlock call, by
+	// endpoint.
+	lastContacts map[string]time.Time
+
 	client         client.Client
-	patchHelper    *patch.Helper
+	patchHelper    *apply.Helper
 	controllerName string
 	ctx            context.Context
 }
@@ -47,7 +93,7 @@ func NewMicrovmDeploymentScope(params MicrovmDeploymentScopeParams) (*MicrovmDep
 		return nil, errClientRequired
 	}
 
-	patchHelper, err := patch.NewHelper(params.MicrovmDeployment, params.Client)
+	patchHelper, err := apply.NewHelper(params.MicrovmDeployment, params.Client)
 	if err != nil {
 		return nil, fmt.Errorf("creating patch helper for microvmreplicaset: %w", err)
 	}
@@ -76,20 +122,63 @@ func (m *MicrovmDeploymentScope) Namespace() string {
 
 // HasAllSets returns true if all required sets have been created
 func (m *MicrovmDeploymentScope) HasAllSets(count int) bool {
-	return count == len(m.MicrovmDeployment.Spec.Hosts)
+	return count == len(m.Hosts())
 }
 
 // RequiredSets returns the number of sets which should be created
 func (m *MicrovmDeploymentScope) RequiredSets() int {
-	return len(m.MicrovmDeployment.Spec.Hosts)
+	return len(m.Hosts())
 }
 
 // DesiredTotalReplicas returns the toal requested replicas set on the spec.
+// Replicas are not counted for unschedulable hosts, but are for failed and
+// preempted hosts since they are recreated elsewhere.
 func (m *MicrovmDeploymentScope) DesiredTotalReplicas() int32 {
-	return m.DesiredReplicas() * int32(m.RequiredSets())
+	if m.IsSpread() {
+		return m.DesiredReplicas()
+	}
+
+	return m.DesiredReplicas() * int32(len(m.SchedulableHosts())+m.failedHosts())
 }
 
-// DesiredReplicas returns the requested replicas set per set on the spec.
+// FailoverAfter returns how long a host must be unreachable before its
+// replicas are recreated elsewhere, and false if failover is not enabled.
+func (m *MicrovmDeploymentScope) FailoverAfter() (time.Duration, bool) {
+	policy := m.MicrovmDeployment.Spec.FailoverPolicy
+	if policy == nil {
+		return 0, false
+	}
+
+	if policy.UnreachableSeconds < 1 {
+		return defaultFailoverAfter, true
+	}
+
+	return time.Duration(policy.UnreachableSeconds) * time.Second, true
+}
+
+// OrphanOnDelete returns true if the microvms of the replicasets are released
+// rather than deleted with the deployment.
+func (m *MicrovmDeploymentScope) OrphanOnDelete() bool {
+	return m.MicrovmDeployment.Spec.DeletePolicy == infrav1.DeletePolicyOrphan
+}
+
+// IsSpread returns true if the replicas are balanced across the hosts rather
+// than created on every host.
+func (m *MicrovmDeploymentScope) IsSpread() bool {
+	return m.MicrovmDeployment.Spec.SpreadConstraints != nil
+}
+
+// MaxSkew returns the largest permitted difference in replicas between hosts.
+func (m *MicrovmDeploymentScope) MaxSkew() int32 {
+	if !m.IsSpread() || m.MicrovmDeployment.Spec.SpreadConstraints.MaxSkew < 1 {
+		return 1
+	}
+
+	return m.MicrovmDeployment.Spec.SpreadConstraints.MaxSkew
+}
+
+// DesiredReplicas returns the requested replicas set on the spec. This is
+// per set, unless the deployment is spread.
 func (m *MicrovmDeploymentScope) DesiredReplicas() int32 {
 	return *m.MicrovmDeployment.Spec.Replicas
 }
@@ -104,16 +193,409 @@ func (m *MicrovmDeploymentScope) CreatedReplicas() int32 {
 	return *&m.MicrovmDeployment.Status.Replicas
 }
 
-// GetMicrovmSpec returns the spec for the child MicroVM
-func (m *MicrovmDeploymentScope) MicrovmSpec() infrav1.MicrovmSpec {
-	return m.MicrovmDeployment.Spec.Template.Spec
+// MicrovmTemplate returns the template for the child MicroVMs. When the
+// deployment uses a host group, the credentials and labels of the group are
+// filled in wherever the template does not set its own. The restartedAt
+// annotation of the deployment is carried by the template.
+func (m *MicrovmDeploymentScope) MicrovmTemplate() infrav1.MicrovmTemplateSpec {
+	template := m.MicrovmDeployment.Spec.Template
+	if m.template != nil {
+		template = *m.template
+	}
+
+	restartedAt := m.MicrovmDeployment.Annotations[infrav1.RestartedAtAnnotation]
+	if m.hostGroup == nil && restartedAt == "" {
+		return template
+	}
+
+	template = *template.DeepCopy()
+
+	// a restart changes the hash of the template, so that it is rolled out
+	// like any other change to it
+	if restartedAt != "" {
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+
+		template.Annotations[infrav1.RestartedAtAnnotation] = restartedAt
+	}
+
+	if m.hostGroup == nil {
+		return template
+	}
+
+	group := m.hostGroup.Spec
+
+	if template.Spec.TLSSecretRef == "" {
+		template.Spec.TLSSecretRef = group.TLSSecretRef
+	}
+
+	if template.Spec.BasicAuthSecret == "" {
+		template.Spec.BasicAuthSecret = group.BasicAuthSecret
+	}
+
+	for key, value := range group.Labels {
+		if template.Labels == nil {
+			template.Labels = map[string]string{}
+		}
+
+		if _, ok := template.Labels[key]; !ok {
+			template.Labels[key] = value
+		}
+	}
+
+	return template
 }
 
-// Hosts returns the list of hosts for created microvms
+// Selector returns the label selector for the child objects, or nil if the
+// deployment has none.
+func (m *MicrovmDeploymentScope) Selector() (labels.Selector, error) {
+	if m.MicrovmDeployment.Spec.Selector == nil {
+		return nil, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(m.MicrovmDeployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing selector: %w", err)
+	}
+
+	return selector, nil
+}
+
+// TemplateRef returns the reference to the MicrovmTemplate to use instead of
+// the inline template, or nil.
+func (m *MicrovmDeploymentScope) TemplateRef() *corev1.LocalObjectReference {
+	return m.MicrovmDeployment.Spec.TemplateRef
+}
+
+// SetTemplate sets the content of the referenced MicrovmTemplate and records
+// its revision, or clears the revision if mvmT is nil.
+func (m *MicrovmDeploymentScope) SetTemplate(mvmT *infrav1.MicrovmTemplate) {
+	if mvmT == nil {
+		m.template = nil
+		m.MicrovmDeployment.Status.TemplateRevision = 0
+
+		return
+	}
+
+	template := mvmT.EffectiveTemplate()
+	m.template = &template
+	m.MicrovmDeployment.Status.TemplateRevision = mvmT.Status.Revision
+}
+
+// HostGroupRef returns the reference to the MicrovmHostGroup to use instead
+// of the hosts on the spec, or nil.
+func (m *MicrovmDeploymentScope) HostGroupRef() *corev1.LocalObjectReference {
+	return m.MicrovmDeployment.Spec.HostGroupRef
+}
+
+// SetHostGroup sets the referenced MicrovmHostGroup.
+func (m *MicrovmDeploymentScope) SetHostGroup(group *infrav1.MicrovmHostGroup) {
+	m.hostGroup = group
+}
+
+// HostGroup returns the referenced MicrovmHostGroup, or nil if the hosts on the
+// spec are used.
+func (m *MicrovmDeploymentScope) HostGroup() *infrav1.MicrovmHostGroup {
+	return m.hostGroup
+}
+
+// HostSelector returns the label selector for the MicrovmHosts to use instead
+// of the hosts on the spec, or nil if the deployment has none or uses a host
+// group.
+func (m *MicrovmDeploymentScope) HostSelector() (labels.Selector, error) {
+	if m.MicrovmDeployment.Spec.HostGroupRef != nil || m.MicrovmDeployment.Spec.HostSelector == nil {
+		return nil, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(m.MicrovmDeployment.Spec.HostSelector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing host selector: %w", err)
+	}
+
+	return selector, nil
+}
+
+// SetSelectedHosts sets the MicrovmHosts matched by the host selector.
+func (m *MicrovmDeploymentScope) SetSelectedHosts(selected []infrav1.MicrovmHost) {
+	hosts := make([]microvm.Host, 0, len(selected))
+
+	for _, host := range selected {
+		hosts = append(hosts, microvm.Host{Name: host.Name, Endpoint: host.Spec.Endpoint})
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Endpoint < hosts[j].Endpoint
+	})
+
+	m.selectedHosts = hosts
+}
+
+// Hosts returns the list of hosts for created microvms: the members of the
+// host group when one is used, the selected MicrovmHosts when there is a host
+// selector, otherwise the hosts on the spec.
 func (m *MicrovmDeploymentScope) Hosts() []microvm.Host {
+	if m.hostGroup != nil {
+		return m.hostGroup.Status.Hosts
+	}
+
+	if m.selectedHosts != nil {
+		return m.selectedHosts
+	}
+
 	return m.MicrovmDeployment.Spec.Hosts
 }
 
+// SchedulableHosts returns the hosts on the spec which have not been cordoned
+// and have not failed.
+func (m *MicrovmDeploymentScope) SchedulableHosts() []microvm.Host {
+	hosts := []microvm.Host{}
+
+	for _, host := range m.Hosts() {
+		if !m.excluded(host.Endpoint) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// SetUnschedulable sets the endpoints of cordoned hosts.
+func (m *MicrovmDeploymentScope) SetUnschedulable(unschedulable infrav1.HostMap) {
+	m.unschedulable = unschedulable
+}
+
+// SetFailed sets the endpoints of hosts whose replicas are to be recreated
+// elsewhere.
+func (m *MicrovmDeploymentScope) SetFailed(failed infrav1.HostMap) {
+	m.failed = failed
+}
+
+// IsFailed returns true if the replicas of the host at endpoint are being
+// recreated elsewhere.
+func (m *MicrovmDeploymentScope) IsFailed(endpoint string) bool {
+	_, ok := m.failed[endpoint]
+
+	return ok
+}
+
+// SetPreempted sets the endpoints of hosts whose replicas are to be recreated
+// elsewhere to make way for a higher priority deployment.
+func (m *MicrovmDeploymentScope) SetPreempted(preempted infrav1.HostMap) {
+	m.preempted = preempted
+}
+
+// IsPreempted returns true if the replicas of the host at endpoint are making
+// way for a higher priority deployment.
+func (m *MicrovmDeploymentScope) IsPreempted(endpoint string) bool {
+	_, ok := m.preempted[endpoint]
+
+	return ok
+}
+
+// SetOutOfContact sets the endpoints of hosts which the operator has not been
+// able to reach recently. They are not given new replicasets, which is up to
+// the caller, but are only reported here.
+func (m *MicrovmDeploymentScope) SetOutOfContact(outOfContact infrav1.HostMap) {
+	m.outOfContact = outOfContact
+}
+
+// SetLastContacts sets when each host last answered a flintlock call, by
+// endpoint.
+func (m *MicrovmDeploymentScope) SetLastContacts(lastContacts map[string]time.Time) {
+	m.lastContacts = lastContacts
+}
+
+// Priority returns the priority of the microvms of the deployment.
+func (m *MicrovmDeploymentScope) Priority() int32 {
+	return m.MicrovmTemplate().Spec.Priority
+}
+
+// Canary returns the canary rollout of the deployment, or nil if a changed
+// template is not rolled out.
+func (m *MicrovmDeploymentScope) Canary() *infrav1.CanaryRollout {
+	if m.MicrovmDeployment.Spec.Rollout == nil {
+		return nil
+	}
+
+	return m.MicrovmDeployment.Spec.Rollout.Canary
+}
+
+// CanarySoak returns how long the canary must stay ready before the rest of
+// the hosts are updated.
+func (m *MicrovmDeploymentScope) CanarySoak() time.Duration {
+	if canary := m.Canary(); canary != nil {
+		return time.Duration(canary.SoakSeconds) * time.Second
+	}
+
+	return 0
+}
+
+// ProgressDeadline returns how long each step of a rollout has to become ready
+// before it is rolled back.
+func (m *MicrovmDeploymentScope) ProgressDeadline() time.Duration {
+	if canary := m.Canary(); canary != nil && canary.ProgressDeadlineSeconds > 0 {
+		return time.Duration(canary.ProgressDeadlineSeconds) * time.Second
+	}
+
+	return defaultProgressDeadline
+}
+
+// DeploymentProgressDeadline returns how long the deployment may go without
+// more microvms becoming ready while it is incomplete, and whether it is set.
+func (m *MicrovmDeploymentScope) DeploymentProgressDeadline() (time.Duration, bool) {
+	seconds := m.MicrovmDeployment.Spec.ProgressDeadlineSeconds
+	if seconds == nil {
+		return 0, false
+	}
+
+	return time.Duration(*seconds) * time.Second, true
+}
+
+// SetProgress records the progress of the deployment towards its desired
+// state from the ready microvms of all its replicasets, and marks it as no
+// longer progressing once none have become ready within the deadline. A
+// complete deployment is progressing, and its deadline starts again from the
+// next change.
+func (m *MicrovmDeploymentScope) SetProgress(ready int32, complete bool, now time.Time) {
+	deadline, ok := m.DeploymentProgressDeadline()
+	if !ok {
+		m.MicrovmDeployment.Status.Progress = nil
+		conditions.Delete(m.MicrovmDeployment, infrav1.MicrovmDeploymentProgressingCondition)
+
+		return
+	}
+
+	if complete {
+		m.MicrovmDeployment.Status.Progress = nil
+		conditions.MarkTrue(m.MicrovmDeployment, infrav1.MicrovmDeploymentProgressingCondition)
+
+		return
+	}
+
+	progress := m.MicrovmDeployment.Status.Progress
+
+	switch {
+	case progress == nil || ready > progress.ReadyReplicas:
+		progress = &infrav1.ProgressStatus{ReadyReplicas: ready, LastProgressTime: metav1.NewTime(now)}
+		m.MicrovmDeployment.Status.Progress = progress
+	case ready < progress.ReadyReplicas:
+		// losing microvms is not progress, but those which come back are
+		progress.ReadyReplicas = ready
+	}
+
+	if now.Sub(progress.LastProgressTime.Time) > deadline {
+		conditions.MarkFalse(m.MicrovmDeployment, infrav1.MicrovmDeploymentProgressingCondition,
+			infrav1.MicrovmDeploymentProgressDeadlineExceededReason, clusterv1.ConditionSeverityWarning,
+			"no more microvms have become ready in %s", deadline)
+
+		return
+	}
+
+	conditions.MarkTrue(m.MicrovmDeployment, infrav1.MicrovmDeploymentProgressingCondition)
+}
+
+// TemplateHash returns the hash of the template the replicasets are created
+// from.
+func (m *MicrovmDeploymentScope) TemplateHash() string {
+	return replica.TemplateHash(m.MicrovmTemplate())
+}
+
+// Rollout returns the progress of the latest rollout, or nil if there has not
+// been one.
+func (m *MicrovmDeploymentScope) Rollout() *infrav1.RolloutStatus {
+	return m.MicrovmDeployment.Status.Rollout
+}
+
+// SetRollout records the progress of the latest rollout.
+func (m *MicrovmDeploymentScope) SetRollout(status *infrav1.RolloutStatus) {
+	m.MicrovmDeployment.Status.Rollout = status
+}
+
+// SplitRollout separates the replicasets which serve each host from those
+// created with the template alongside them while it is rolled out. A host
+// with an outdated replicaset is served by it until the rollout completes. All
+// of the replicasets serve when the template is not rolled out.
+func (m *MicrovmDeploymentScope) SplitRollout(
+	sets []infrav1.MicrovmReplicaSet,
+) (serving, updated []infrav1.MicrovmReplicaSet) {
+	if m.Canary() == nil {
+		return sets, nil
+	}
+
+	hash := m.TemplateHash()
+	outdated := infrav1.HostMap{}
+
+	for i := range sets {
+		if m.IsOutdated(&sets[i], hash) {
+			outdated[sets[i].Spec.Host.Endpoint] = struct{}{}
+		}
+	}
+
+	for i := range sets {
+		rs := sets[i]
+
+		if _, ok := outdated[rs.Spec.Host.Endpoint]; ok && !m.IsOutdated(&rs, hash) {
+			updated = append(updated, rs)
+		} else {
+			serving = append(serving, rs)
+		}
+	}
+
+	return serving, updated
+}
+
+// IsOutdated returns true if rs was created from a template other than the one
+// with hash, and is to be replaced when the template is rolled out. External
+// replicasets are never replaced.
+func (m *MicrovmDeploymentScope) IsOutdated(rs *infrav1.MicrovmReplicaSet, hash string) bool {
+	return !replica.IsExternal(rs) && replica.Hash(rs) != hash
+}
+
+// isOutOfContact returns true if the operator has not been able to reach the
+// host at endpoint recently.
+func (m *MicrovmDeploymentScope) isOutOfContact(endpoint string) bool {
+	_, ok := m.outOfContact[endpoint]
+
+	return ok
+}
+
+// lastContact returns when the host at endpoint last answered a flintlock
+// call, to the minute so that the status is not rewritten after every call,
+// or nil if it is not known.
+func (m *MicrovmDeploymentScope) lastContact(endpoint string) *metav1.Time {
+	at, ok := m.lastContacts[endpoint]
+	if !ok {
+		return nil
+	}
+
+	contact := metav1.NewTime(at.Truncate(time.Minute))
+
+	return &contact
+}
+
+// excluded returns true if no replicas should run on the host at endpoint.
+func (m *MicrovmDeploymentScope) excluded(endpoint string) bool {
+	_, cordoned := m.unschedulable[endpoint]
+
+	return cordoned || m.IsFailed(endpoint) || m.IsPreempted(endpoint)
+}
+
+// failedHosts returns the number of hosts on the spec which have failed or
+// been preempted, and have not also been cordoned.
+func (m *MicrovmDeploymentScope) failedHosts() int {
+	count := 0
+
+	for _, host := range m.Hosts() {
+		_, cordoned := m.unschedulable[host.Endpoint]
+		if (m.IsFailed(host.Endpoint) || m.IsPreempted(host.Endpoint)) && !cordoned {
+			count++
+		}
+	}
+
+	return count
+}
+
 // DetermineHost returns a host which does not yet have a replicaset
 func (m *MicrovmDeploymentScope) DetermineHost(setHosts infrav1.HostMap) (microvm.Host, error) {
 	for _, host := range m.Hosts() {
@@ -134,6 +616,436 @@ func (m *MicrovmDeploymentScope) ExpiredHosts(setHosts infrav1.HostMap) infrav1.
 	return setHosts
 }
 
+// HostPlan describes every change required to bring the replicasets owned by
+// a MicrovmDeployment in line with its spec.
+type HostPlan struct {
+	// Create holds the hosts which do not yet have a replicaset.
+	Create []microvm.Host
+	// Delete holds the replicasets whose host is no longer in the spec, or which
+	// duplicate another replicaset on the same host.
+	Delete []infrav1.MicrovmReplicaSet
+	// Scale holds the replicasets whose replica count does not match the spec.
+	Scale []infrav1.MicrovmReplicaSet
+	// Drain holds the replicasets on unschedulable hosts which are kept until
+	// their replicas are ready on the other hosts.
+	Drain []infrav1.MicrovmReplicaSet
+	// Replicas holds the number of replicas each host should run, by endpoint.
+	Replicas map[string]int32
+}
+
+// IsEmpty returns true if the plan requires no changes.
+func (p HostPlan) IsEmpty() bool {
+	return len(p.Create) == 0 && len(p.Delete) == 0 && len(p.Scale) == 0 && len(p.Drain) == 0
+}
+
+// PlanHosts compares the given replicasets against the hosts on the spec and
+// returns the full set of additions, removals and scale changes needed in
+// order to converge in a single pass. Replicasets on unschedulable, failed or
+// preempted hosts are drained: they are only deleted once the replicasets on
+// the schedulable hosts are ready at their planned size, or straight away when
+// preempted and there is no other host.
+func (m *MicrovmDeploymentScope) PlanHosts(sets []infrav1.MicrovmReplicaSet) HostPlan {
+	plan := HostPlan{
+		Replicas: m.ReplicasPerHost(sets),
+	}
+
+	wanted := infrav1.HostMap{}
+	for _, host := range m.SchedulableHosts() {
+		wanted[host.Endpoint] = struct{}{}
+	}
+
+	seen := infrav1.HostMap{}
+	draining := []infrav1.MicrovmReplicaSet{}
+
+	for _, rs := range sets {
+		endpoint := rs.Spec.Host.Endpoint
+
+		_, isWanted := wanted[endpoint]
+		_, isSeen := seen[endpoint]
+		if m.excluded(endpoint) && !isSeen {
+			seen[endpoint] = struct{}{}
+			draining = append(draining, rs)
+
+			continue
+		}
+
+		if !isWanted || isSeen {
+			plan.Delete = append(plan.Delete, rs)
+
+			continue
+		}
+
+		seen[endpoint] = struct{}{}
+
+		if rs.Spec.Replicas == nil || *rs.Spec.Replicas != plan.Replicas[endpoint] {
+			plan.Scale = append(plan.Scale, rs)
+		}
+	}
+
+	for _, host := range m.SchedulableHosts() {
+		if _, ok := seen[host.Endpoint]; ok {
+			continue
+		}
+
+		seen[host.Endpoint] = struct{}{}
+		plan.Create = append(plan.Create, host)
+	}
+
+	switch {
+	case m.evacuated(sets, plan):
+		plan.Delete = append(plan.Delete, draining...)
+	case len(m.SchedulableHosts()) == 0:
+		// preempted replicas have nowhere else to go, but must make way anyway
+		for _, rs := range draining {
+			if m.IsPreempted(rs.Spec.Host.Endpoint) {
+				plan.Delete = append(plan.Delete, rs)
+			} else {
+				plan.Drain = append(plan.Drain, rs)
+			}
+		}
+	default:
+		plan.Drain = draining
+	}
+
+	return plan
+}
+
+// evacuated returns true if the replicasets on the schedulable hosts need no
+// changes and all of their replicas are ready, so that the replicasets on
+// unschedulable or failed hosts can be removed without losing capacity.
+func (m *MicrovmDeploymentScope) evacuated(sets []infrav1.MicrovmReplicaSet, plan HostPlan) bool {
+	if len(m.SchedulableHosts()) == 0 || len(plan.Create) > 0 || len(plan.Scale) > 0 {
+		return false
+	}
+
+	var ready int32
+
+	for _, rs := range sets {
+		if !m.excluded(rs.Spec.Host.Endpoint) {
+			ready += rs.Status.ReadyReplicas
+		}
+	}
+
+	return ready >= m.DesiredTotalReplicas()
+}
+
+// ReplicasPerHost returns the number of replicas each schedulable host on the
+// spec should run, keyed by endpoint. Without spread constraints every host runs
+// DesiredReplicas. With them, DesiredReplicas is shared out across the hosts,
+// keeping the current distribution of the given replicasets where possible and
+// only moving replicas when the skew between hosts would exceed MaxSkew.
+func (m *MicrovmDeploymentScope) ReplicasPerHost(sets []infrav1.MicrovmReplicaSet) map[string]int32 {
+	hosts := m.SchedulableHosts()
+	perHost := make(map[string]int32, len(hosts))
+
+	if !m.IsSpread() {
+		for _, host := range hosts {
+			perHost[host.Endpoint] = m.DesiredReplicas()
+		}
+
+		// the replicas of failed and preempted hosts are shared out across the rest
+		extra := m.DesiredReplicas() * int32(m.failedHosts())
+		for i := 0; len(hosts) > 0 && int32(i) < extra; i++ {
+			perHost[hosts[i%len(hosts)].Endpoint]++
+		}
+
+		return perHost
+	}
+
+	if len(hosts) == 0 {
+		return perHost
+	}
+
+	counts := make([]int32, len(hosts))
+	index := make(map[string]int, len(hosts))
+
+	for i, host := range hosts {
+		index[host.Endpoint] = i
+	}
+
+	seen := infrav1.HostMap{}
+
+	for _, rs := range sets {
+		i, ok := index[rs.Spec.Host.Endpoint]
+		if !ok || rs.Spec.Replicas == nil {
+			continue
+		}
+
+		if _, dup := seen[rs.Spec.Host.Endpoint]; dup {
+			continue
+		}
+
+		seen[rs.Spec.Host.Endpoint] = struct{}{}
+		counts[i] = *rs.Spec.Replicas
+	}
+
+	// pinned replicas are set aside, and the rest are spread around them
+	pinned := m.PinnedReplicas()
+
+	for i, host := range hosts {
+		counts[i] -= pinned[host.Endpoint]
+		if counts[i] < 0 {
+			counts[i] = 0
+		}
+	}
+
+	total := sum(counts)
+	desired := m.DesiredReplicas() - sum(values(pinned))
+	domains := m.spreadDomains()
+
+	// grow or shrink to the desired total, one replica at a time on the least
+	// or most loaded host of the least or most loaded domain
+	for ; total < desired; total++ {
+		members := domains[leastLoaded(domainTotals(counts, domains))]
+		counts[members[leastLoaded(subset(counts, members))]]++
+	}
+
+	for ; total > desired; total-- {
+		members := domains[mostLoaded(domainTotals(counts, domains))]
+		counts[members[mostLoaded(subset(counts, members))]]--
+	}
+
+	// then move replicas between domains until the skew is acceptable
+	for {
+		totals := domainTotals(counts, domains)
+		most, least := mostLoaded(totals), leastLoaded(totals)
+
+		if totals[most]-totals[least] <= m.MaxSkew() {
+			break
+		}
+
+		from, to := domains[most], domains[least]
+		counts[from[mostLoaded(subset(counts, from))]]--
+		counts[to[leastLoaded(subset(counts, to))]]++
+	}
+
+	// and between the hosts within each domain
+	for _, members := range domains {
+		for {
+			sub := subset(counts, members)
+			most, least := mostLoaded(sub), leastLoaded(sub)
+
+			if sub[most]-sub[least] <= m.MaxSkew() {
+				break
+			}
+
+			counts[members[most]]--
+			counts[members[least]]++
+		}
+	}
+
+	for i, host := range hosts {
+		perHost[host.Endpoint] = counts[i] + pinned[host.Endpoint]
+	}
+
+	return perHost
+}
+
+// PinnedReplicas returns the number of replicas the placement overrides pin
+// to each schedulable host, keyed by endpoint. Overrides for replicas beyond
+// DesiredReplicas, or for hosts which are not schedulable, are left to the
+// spread constraints. Nothing is pinned when the deployment is not spread.
+func (m *MicrovmDeploymentScope) PinnedReplicas() map[string]int32 {
+	pinned := map[string]int32{}
+	if !m.IsSpread() {
+		return pinned
+	}
+
+	schedulable := infrav1.HostMap{}
+	for _, host := range m.SchedulableHosts() {
+		schedulable[host.Endpoint] = struct{}{}
+	}
+
+	seen := map[int32]bool{}
+
+	for _, override := range m.MicrovmDeployment.Spec.PlacementOverrides {
+		if override.Replica >= m.DesiredReplicas() || seen[override.Replica] {
+			continue
+		}
+
+		seen[override.Replica] = true
+
+		if _, ok := schedulable[override.Host]; ok {
+			pinned[override.Host]++
+		}
+	}
+
+	return pinned
+}
+
+// UnplacedOverrides returns the placement overrides which pin a replica the
+// deployment has to a host which is not schedulable, and so are not honoured.
+func (m *MicrovmDeploymentScope) UnplacedOverrides() []infrav1.PlacementOverride {
+	if !m.IsSpread() {
+		return nil
+	}
+
+	schedulable := infrav1.HostMap{}
+	for _, host := range m.SchedulableHosts() {
+		schedulable[host.Endpoint] = struct{}{}
+	}
+
+	unplaced := []infrav1.PlacementOverride{}
+
+	for _, override := range m.MicrovmDeployment.Spec.PlacementOverrides {
+		if _, ok := schedulable[override.Host]; !ok && override.Replica < m.DesiredReplicas() {
+			unplaced = append(unplaced, override)
+		}
+	}
+
+	return unplaced
+}
+
+// TopologyKey returns the domain replicas are spread across, defaulting to
+// the hosts.
+func (m *MicrovmDeploymentScope) TopologyKey() infrav1.SpreadTopology {
+	if !m.IsSpread() || m.MicrovmDeployment.Spec.SpreadConstraints.TopologyKey == "" {
+		return infrav1.HostSpreadTopology
+	}
+
+	return m.MicrovmDeployment.Spec.SpreadConstraints.TopologyKey
+}
+
+// SetFailureDomains sets the failure domain of each host, by endpoint.
+func (m *MicrovmDeploymentScope) SetFailureDomains(failureDomains map[string]string) {
+	m.failureDomains = failureDomains
+}
+
+// spreadDomains groups the indexes of the schedulable hosts into the domains
+// replicas are spread across, in the order the domains first appear.
+func (m *MicrovmDeploymentScope) spreadDomains() [][]int {
+	domains := [][]int{}
+	index := map[string]int{}
+
+	for i, host := range m.SchedulableHosts() {
+		key := "host/" + host.Endpoint
+		if domain := m.failureDomains[host.Endpoint]; domain != "" && m.TopologyKey() == infrav1.FailureDomainSpreadTopology {
+			key = "domain/" + domain
+		}
+
+		d, ok := index[key]
+		if !ok {
+			d = len(domains)
+			index[key] = d
+			domains = append(domains, nil)
+		}
+
+		domains[d] = append(domains[d], i)
+	}
+
+	return domains
+}
+
+// SpreadSkew returns the difference between the number of ready replicas in
+// the most and least loaded domains, counting the given replicasets on the
+// schedulable hosts.
+func (m *MicrovmDeploymentScope) SpreadSkew(sets []infrav1.MicrovmReplicaSet) int32 {
+	hosts := m.SchedulableHosts()
+	if len(hosts) == 0 {
+		return 0
+	}
+
+	ready := make([]int32, len(hosts))
+	index := make(map[string]int, len(hosts))
+
+	for i, host := range hosts {
+		index[host.Endpoint] = i
+	}
+
+	for _, rs := range sets {
+		if i, ok := index[rs.Spec.Host.Endpoint]; ok {
+			ready[i] += rs.Status.ReadyReplicas
+		}
+	}
+
+	// pinned replicas are not spread, so do not count towards the skew
+	pinned := m.PinnedReplicas()
+
+	for i, host := range hosts {
+		ready[i] -= pinned[host.Endpoint]
+		if ready[i] < 0 {
+			ready[i] = 0
+		}
+	}
+
+	totals := domainTotals(ready, m.spreadDomains())
+
+	return totals[mostLoaded(totals)] - totals[leastLoaded(totals)]
+}
+
+func domainTotals(counts []int32, domains [][]int) []int32 {
+	totals := make([]int32, len(domains))
+
+	for d, members := range domains {
+		totals[d] = sum(subset(counts, members))
+	}
+
+	return totals
+}
+
+func values(counts map[string]int32) []int32 {
+	vals := make([]int32, 0, len(counts))
+
+	for _, c := range counts {
+		vals = append(vals, c)
+	}
+
+	return vals
+}
+
+func subset(counts []int32, members []int) []int32 {
+	sub := make([]int32, len(members))
+
+	for i, member := range members {
+		sub[i] = counts[member]
+	}
+
+	return sub
+}
+
+func sum(counts []int32) int32 {
+	var total int32
+
+	for _, c := range counts {
+		total += c
+	}
+
+	return total
+}
+
+func leastLoaded(counts []int32) int {
+	least := 0
+
+	for i, c := range counts {
+		if c < counts[least] {
+			least = i
+		}
+	}
+
+	return least
+}
+
+func mostLoaded(counts []int32) int {
+	most := 0
+
+	for i, c := range counts {
+		if c > counts[most] {
+			most = i
+		}
+	}
+
+	return most
+}
+
+// SetSelector records the selector in the status for the scale subresource.
+func (m *MicrovmDeploymentScope) SetSelector(selector labels.Selector) {
+	m.MicrovmDeployment.Status.Selector = ""
+
+	if selector != nil {
+		m.MicrovmDeployment.Status.Selector = selector.String()
+	}
+}
+
 // SetCreatedReplicas records the number of microvms which have been created
 // this does not give information about whether the microvms are ready
 func (m *MicrovmDeploymentScope) SetCreatedReplicas(count int32) {
@@ -162,8 +1074,228 @@ func (m *MicrovmDeploymentScope) SetNotReady(
 	m.MicrovmDeployment.Status.Ready = false
 }
 
-// Patch persists the resource and status.
+// SetSpreadSatisfied marks the ready replicas as spread within the constraints.
+func (m *MicrovmDeploymentScope) SetSpreadSatisfied() {
+	conditions.MarkTrue(m.MicrovmDeployment, infrav1.MicrovmDeploymentSpreadCondition)
+}
+
+// SetSpreadViolated marks the ready replicas as spread beyond the constraints.
+func (m *MicrovmDeploymentScope) SetSpreadViolated(
+	reason string,
+	severity clusterv1.ConditionSeverity,
+	message string,
+	messageArgs ...interface{},
+) {
+	conditions.MarkFalse(m.MicrovmDeployment, infrav1.MicrovmDeploymentSpreadCondition, reason, severity, message, messageArgs...)
+}
+
+// ClearSpread removes the spread condition from a deployment which is not spread.
+func (m *MicrovmDeploymentScope) ClearSpread() {
+	conditions.Delete(m.MicrovmDeployment, infrav1.MicrovmDeploymentSpreadCondition)
+}
+
+// SetReplicasReady aggregates the ready conditions of the replicasets which
+// serve the hosts of the deployment.
+func (m *MicrovmDeploymentScope) SetReplicasReady(sets []infrav1.MicrovmReplicaSet) {
+	if len(sets) == 0 {
+		conditions.MarkFalse(m.MicrovmDeployment, infrav1.MicrovmDeploymentReplicasReadyCondition,
+			infrav1.MicrovmDeploymentIncompleteReason, clusterv1.ConditionSeverityInfo, "no microvmreplicasets")
+
+		return
+	}
+
+	from := make([]conditions.Getter, len(sets))
+	for i := range sets {
+		from[i] = readyGetter{&sets[i]}
+	}
+
+	conditions.SetAggregate(m.MicrovmDeployment, infrav1.MicrovmDeploymentReplicasReadyCondition, from)
+}
+
+// SetHostsHealthy reports the hosts of the deployment which are cordoned,
+// unreachable, out of contact or preempted.
+func (m *MicrovmDeploymentScope) SetHostsHealthy() {
+	var cordoned, failed, outOfContact, preempted []string
+
+	for _, host := range m.Hosts() {
+		switch endpoint := host.Endpoint; {
+		case m.IsPreempted(endpoint):
+			preempted = append(preempted, endpoint)
+		case m.IsFailed(endpoint):
+			failed = append(failed, endpoint)
+		case m.isOutOfContact(endpoint):
+			outOfContact = append(outOfContact, endpoint)
+		default:
+			if _, ok := m.unschedulable[endpoint]; ok {
+				cordoned = append(cordoned, endpoint)
+			}
+		}
+	}
+
+	if len(cordoned)+len(failed)+len(outOfContact)+len(preempted) == 0 {
+		conditions.MarkTrue(m.MicrovmDeployment, infrav1.MicrovmDeploymentHostsHealthyCondition)
+
+		return
+	}
+
+	severity := clusterv1.ConditionSeverityInfo
+	if len(failed)+len(preempted) > 0 {
+		severity = clusterv1.ConditionSeverityWarning
+	}
+
+	problems := []string{}
+
+	for _, group := range []struct {
+		state string
+		hosts []string
+	}{
+		{"unreachable", failed},
+		{"preempted", preempted},
+		{"out of contact", outOfContact},
+		{"cordoned", cordoned},
+	} {
+		if len(group.hosts) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %s", group.state, strings.Join(group.hosts, ", ")))
+		}
+	}
+
+	conditions.MarkFalse(m.MicrovmDeployment, infrav1.MicrovmDeploymentHostsHealthyCondition,
+		infrav1.MicrovmDeploymentHostsUnhealthyReason, severity, strings.Join(problems, "; "))
+}
+
+// SetTemplateUpToDate reports how many of the replicasets were created from a
+// previous template.
+func (m *MicrovmDeploymentScope) SetTemplateUpToDate(sets []infrav1.MicrovmReplicaSet) {
+	hash := m.TemplateHash()
+	outdated := 0
+
+	for i := range sets {
+		if m.IsOutdated(&sets[i], hash) {
+			outdated++
+		}
+	}
+
+	if outdated == 0 {
+		conditions.MarkTrue(m.MicrovmDeployment, infrav1.MicrovmDeploymentTemplateUpToDateCondition)
+
+		return
+	}
+
+	reason := infrav1.MicrovmDeploymentTemplateOutdatedReason
+	severity := clusterv1.ConditionSeverityInfo
+
+	if rollout := m.Rollout(); rollout != nil && rollout.TemplateHash == hash {
+		switch rollout.Phase {
+		case infrav1.RolloutPhaseCanary, infrav1.RolloutPhaseProgressing:
+			reason = infrav1.MicrovmDeploymentRollingOutReason
+		case infrav1.RolloutPhaseRolledBack:
+			reason = infrav1.MicrovmDeploymentRolledBackReason
+			severity = clusterv1.ConditionSeverityWarning
+		case infrav1.RolloutPhaseComplete:
+		}
+	}
+
+	conditions.MarkFalse(m.MicrovmDeployment, infrav1.MicrovmDeploymentTemplateUpToDateCondition, reason, severity,
+		"%d of %d microvmreplicasets run an outdated template", outdated, len(sets))
+}
+
+// SetHostSummaries records the state of each of the given replicasets and of
+// the host it runs on, including when the host last answered. A host of the
+// deployment without a replicaset is listed on its own.
+func (m *MicrovmDeploymentScope) SetHostSummaries(sets []infrav1.MicrovmReplicaSet) {
+	summaries := []infrav1.HostSummary{}
+	seen := infrav1.HostMap{}
+
+	for i := range sets {
+		rs := &sets[i]
+		seen[rs.Spec.Host.Endpoint] = struct{}{}
+
+		summaries = append(summaries, infrav1.HostSummary{
+			Host:                  rs.Spec.Host.Endpoint,
+			ReplicaSet:            rs.Name,
+			Replicas:              rs.Status.Replicas,
+			ReadyReplicas:         rs.Status.ReadyReplicas,
+			Condition:             conditions.Get(rs, rs.ReadyConditionType()).DeepCopy(),
+			LastSuccessfulContact: m.lastContact(rs.Spec.Host.Endpoint),
+		})
+	}
+
+	for _, host := range m.Hosts() {
+		if _, ok := seen[host.Endpoint]; ok {
+			continue
+		}
+
+		seen[host.Endpoint] = struct{}{}
+		summaries = append(summaries, infrav1.HostSummary{
+			Host:                  host.Endpoint,
+			LastSuccessfulContact: m.lastContact(host.Endpoint),
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Host != summaries[j].Host {
+			return summaries[i].Host < summaries[j].Host
+		}
+
+		return summaries[i].ReplicaSet < summaries[j].ReplicaSet
+	})
+
+	m.MicrovmDeployment.Status.HostSummaries = summaries
+}
+
+// SetNextHost records the host which will receive the next replicaset of the
+// plan, or clears it when the plan creates none.
+func (m *MicrovmDeploymentScope) SetNextHost(plan HostPlan) {
+	m.MicrovmDeployment.Status.NextHost = ""
+
+	if len(plan.Create) > 0 {
+		m.MicrovmDeployment.Status.NextHost = plan.Create[0].Endpoint
+	}
+}
+
+// readyGetter presents the ready condition of a replicaset as the Ready
+// condition, which is the one conditions.SetAggregate reads from each object.
+type readyGetter struct {
+	infrav1.ReadinessReporter
+}
+
+// GetConditions returns only the ready condition of the replicaset.
+func (g readyGetter) GetConditions() clusterv1.Conditions {
+	ready := conditions.Get(g.ReadinessReporter, g.ReadyConditionType())
+	if ready == nil {
+		return nil
+	}
+
+	condition := *ready
+	condition.Type = clusterv1.ReadyCondition
+
+	return clusterv1.Conditions{condition}
+}
+
+// SetDryRun records what a reconcile in dry-run mode would have done to the
+// current spec of the MicrovmDeployment.
+func (m *MicrovmDeploymentScope) SetDryRun(actions []infrav1.PlannedAction) {
+	m.MicrovmDeployment.Status.DryRun = dryrun.Status(m.MicrovmDeployment.Generation, actions)
+}
+
+// ClearDryRun removes what was recorded while the MicrovmDeployment was reconciled in
+// dry-run mode.
+func (m *MicrovmDeploymentScope) ClearDryRun() {
+	m.MicrovmDeployment.Status.DryRun = nil
+}
+
+// SetObservedGeneration records that the current spec of the MicrovmDeployment has been
+// processed.
+func (m *MicrovmDeploymentScope) SetObservedGeneration() {
+	m.MicrovmDeployment.Status.ObservedGeneration = m.MicrovmDeployment.Generation
+}
+
+// Patch summarises the conditions into the Ready condition and the phase, then
+// persists the resource and status.
 func (m *MicrovmDeploymentScope) Patch() error {
+	conditions.SetSummary(m.MicrovmDeployment, conditions.WithConditions(summarisedConditions...))
+	m.MicrovmDeployment.Status.Phase = infrav1.GetPhase(m.MicrovmDeployment)
+
 	err := m.patchHelper.Patch(
 		m.ctx,
 		m.MicrovmDeployment,
@@ -3,14 +3,20 @@ package scope_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply/applytest"
 	"github.com/weaveworks-liquidmetal/microvm-operator/internal/scope"
 )
 
@@ -110,6 +116,617 @@ func TestExpiredHosts(t *testing.T) {
 	g.Expect(hostMap).To(HaveKey("4"))
 }
 
+func TestPlanHosts(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme, err := setupScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmDep := newDeployment("md-1", 0)
+	mvmDep.Spec.Replicas = pointer.Int32(2)
+	mvmDep.Spec.Hosts = []microvm.Host{
+		{Endpoint: "1"}, {Endpoint: "2"}, {Endpoint: "3"}, {Endpoint: "4"},
+	}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build()
+	mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+		Client:            client,
+		MicrovmDeployment: mvmDep,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	sets := []infrav1.MicrovmReplicaSet{
+		newReplicaSet("rs-1", "1", 2),
+		newReplicaSet("rs-2", "2", 1),
+		newReplicaSet("rs-2-dup", "2", 2),
+		newReplicaSet("rs-5", "5", 2),
+		newReplicaSet("rs-6", "6", 2),
+	}
+
+	plan := mvmScope.PlanHosts(sets)
+	g.Expect(plan.IsEmpty()).To(BeFalse())
+
+	g.Expect(plan.Create).To(ConsistOf(microvm.Host{Endpoint: "3"}, microvm.Host{Endpoint: "4"}))
+	g.Expect(setNames(plan.Delete)).To(ConsistOf("rs-2-dup", "rs-5", "rs-6"))
+	g.Expect(setNames(plan.Scale)).To(ConsistOf("rs-2"))
+
+	converged := []infrav1.MicrovmReplicaSet{
+		newReplicaSet("rs-1", "1", 2),
+		newReplicaSet("rs-2", "2", 2),
+		newReplicaSet("rs-3", "3", 2),
+		newReplicaSet("rs-4", "4", 2),
+	}
+	g.Expect(mvmScope.PlanHosts(converged).IsEmpty()).To(BeTrue())
+}
+
+func TestPlanHosts_Drain(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme, err := setupScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmDep := newDeployment("md-1", 3)
+	mvmDep.Spec.Replicas = pointer.Int32(4)
+	mvmDep.Spec.SpreadConstraints = &infrav1.SpreadConstraints{MaxSkew: 1}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build()
+	mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+		Client:            client,
+		MicrovmDeployment: mvmDep,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mvmScope.SetUnschedulable(infrav1.HostMap{"2": struct{}{}})
+
+	withReady := func(rs infrav1.MicrovmReplicaSet) infrav1.MicrovmReplicaSet {
+		rs.Status.ReadyReplicas = *rs.Spec.Replicas
+
+		return rs
+	}
+
+	// the cordoned host's replicas are moved to the others, but it is kept
+	sets := []infrav1.MicrovmReplicaSet{
+		withReady(newReplicaSet("rs-0", "0", 1)),
+		withReady(newReplicaSet("rs-1", "1", 1)),
+		withReady(newReplicaSet("rs-2", "2", 2)),
+	}
+
+	plan := mvmScope.PlanHosts(sets)
+	g.Expect(plan.Replicas).To(Equal(map[string]int32{"0": 2, "1": 2}))
+	g.Expect(setNames(plan.Scale)).To(ConsistOf("rs-0", "rs-1"))
+	g.Expect(setNames(plan.Drain)).To(ConsistOf("rs-2"))
+	g.Expect(plan.Delete).To(BeEmpty())
+	g.Expect(mvmScope.DesiredTotalReplicas()).To(Equal(int32(4)))
+
+	// until the other hosts are ready at their new size
+	sets[0] = newReplicaSet("rs-0", "0", 2)
+	sets[1] = withReady(newReplicaSet("rs-1", "1", 2))
+
+	plan = mvmScope.PlanHosts(sets)
+	g.Expect(plan.Scale).To(BeEmpty())
+	g.Expect(setNames(plan.Drain)).To(ConsistOf("rs-2"))
+
+	sets[0] = withReady(sets[0])
+
+	plan = mvmScope.PlanHosts(sets)
+	g.Expect(plan.Drain).To(BeEmpty())
+	g.Expect(setNames(plan.Delete)).To(ConsistOf("rs-2"))
+	g.Expect(plan.Create).To(BeEmpty(), "Expected no replicaset to be placed on the cordoned host")
+}
+
+func TestPlanHosts_Preempted(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme, err := setupScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmDep := newDeployment("md-1", 2)
+	mvmDep.Spec.Replicas = pointer.Int32(2)
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build()
+	mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+		Client:            client,
+		MicrovmDeployment: mvmDep,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mvmScope.SetPreempted(infrav1.HostMap{"1": struct{}{}})
+
+	sets := []infrav1.MicrovmReplicaSet{
+		newReplicaSet("rs-0", "0", 2),
+		newReplicaSet("rs-1", "1", 2),
+	}
+
+	// a preempted host's replicas are shared out while it drains
+	plan := mvmScope.PlanHosts(sets)
+	g.Expect(plan.Replicas).To(Equal(map[string]int32{"0": 4}))
+	g.Expect(setNames(plan.Drain)).To(ConsistOf("rs-1"))
+	g.Expect(plan.Delete).To(BeEmpty())
+	g.Expect(mvmScope.DesiredTotalReplicas()).To(Equal(int32(4)))
+
+	// but makes way at once when there is nowhere else to go
+	mvmScope.SetUnschedulable(infrav1.HostMap{"0": struct{}{}})
+
+	plan = mvmScope.PlanHosts(sets)
+	g.Expect(setNames(plan.Drain)).To(ConsistOf("rs-0"))
+	g.Expect(setNames(plan.Delete)).To(ConsistOf("rs-1"))
+}
+
+func TestConditionSummary(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme, err := setupScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmDep := newDeployment("md-1", 3)
+	mvmDep.Spec.Replicas = pointer.Int32(2)
+
+	client := applytest.NewClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build())
+	mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+		Client:            client,
+		MicrovmDeployment: mvmDep,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	sets := []infrav1.MicrovmReplicaSet{
+		newReplicaSet("rs-0", "0", 2),
+		newReplicaSet("rs-1", "1", 2),
+		newReplicaSet("rs-2", "2", 2),
+	}
+	conditions.MarkTrue(&sets[0], infrav1.MicrovmReplicaSetReadyCondition)
+	conditions.MarkTrue(&sets[1], infrav1.MicrovmReplicaSetReadyCondition)
+	conditions.MarkFalse(&sets[2], infrav1.MicrovmReplicaSetReadyCondition,
+		infrav1.MicrovmReplicaSetIncompleteReason, clusterv1.ConditionSeverityInfo, "")
+
+	// the ready conditions of the replicasets are aggregated
+	mvmScope.SetReady()
+	mvmScope.SetReplicasReady(sets)
+	mvmScope.SetHostsHealthy()
+	mvmScope.SetTemplateUpToDate(sets)
+	g.Expect(mvmScope.Patch()).To(Succeed())
+
+	replicas := conditions.Get(mvmDep, infrav1.MicrovmDeploymentReplicasReadyCondition)
+	g.Expect(replicas).NotTo(BeNil())
+	g.Expect(replicas.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(replicas.Message).To(Equal("2 of 3 completed"))
+	g.Expect(conditions.IsTrue(mvmDep, infrav1.MicrovmDeploymentHostsHealthyCondition)).To(BeTrue())
+	g.Expect(conditions.IsTrue(mvmDep, infrav1.MicrovmDeploymentTemplateUpToDateCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(mvmDep, clusterv1.ReadyCondition)).To(Equal(infrav1.MicrovmReplicaSetIncompleteReason))
+
+	// an unhealthy host is the most severe and explains the summary
+	mvmScope.SetFailed(infrav1.HostMap{"1": struct{}{}})
+	mvmScope.SetUnschedulable(infrav1.HostMap{"2": struct{}{}})
+	mvmScope.SetHostsHealthy()
+	g.Expect(mvmScope.Patch()).To(Succeed())
+
+	hosts := conditions.Get(mvmDep, infrav1.MicrovmDeploymentHostsHealthyCondition)
+	g.Expect(hosts).NotTo(BeNil())
+	g.Expect(hosts.Severity).To(Equal(clusterv1.ConditionSeverityWarning))
+	g.Expect(hosts.Message).To(Equal("unreachable: 1; cordoned: 2"))
+	g.Expect(conditions.GetReason(mvmDep, clusterv1.ReadyCondition)).To(Equal(infrav1.MicrovmDeploymentHostsUnhealthyReason))
+	g.Expect(conditions.GetMessage(mvmDep, clusterv1.ReadyCondition)).To(Equal("unreachable: 1; cordoned: 2"))
+
+	// the summary is ready once everything it summarises is
+	mvmScope.SetFailed(nil)
+	mvmScope.SetUnschedulable(nil)
+	conditions.MarkTrue(&sets[2], infrav1.MicrovmReplicaSetReadyCondition)
+	mvmScope.SetReplicasReady(sets)
+	mvmScope.SetHostsHealthy()
+	g.Expect(mvmScope.Patch()).To(Succeed())
+	g.Expect(conditions.IsTrue(mvmDep, clusterv1.ReadyCondition)).To(BeTrue())
+}
+
+func TestSplitRollout(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme, err := setupScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmDep := newDeployment("md-1", 3)
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build()
+	mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+		Client:            client,
+		MicrovmDeployment: mvmDep,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	withHash := func(rs infrav1.MicrovmReplicaSet, hash string) infrav1.MicrovmReplicaSet {
+		rs.Labels = map[string]string{infrav1.MicrovmReplicaSetHashLabel: hash}
+
+		return rs
+	}
+
+	hash := mvmScope.TemplateHash()
+	sets := []infrav1.MicrovmReplicaSet{
+		withHash(newReplicaSet("rs-0", "0", 1), "old"),
+		withHash(newReplicaSet("rs-0-new", "0", 1), hash),
+		withHash(newReplicaSet("rs-1", "1", 1), "old"),
+		withHash(newReplicaSet("rs-2", "2", 1), hash),
+	}
+
+	// every replicaset serves when the template is not rolled out
+	serving, updated := mvmScope.SplitRollout(sets)
+	g.Expect(serving).To(Equal(sets))
+	g.Expect(updated).To(BeEmpty())
+
+	// an updated replicaset is held back while its host has an outdated one
+	mvmDep.Spec.Rollout = &infrav1.RolloutStrategy{Canary: &infrav1.CanaryRollout{Host: "0"}}
+
+	serving, updated = mvmScope.SplitRollout(sets)
+	g.Expect(setNames(serving)).To(ConsistOf("rs-0", "rs-1", "rs-2"))
+	g.Expect(setNames(updated)).To(ConsistOf("rs-0-new"))
+	g.Expect(mvmScope.ProgressDeadline()).To(Equal(10 * time.Minute))
+}
+
+func TestReplicasPerHost_Failover(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme, err := setupScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmDep := newDeployment("md-1", 4)
+	mvmDep.Spec.Replicas = pointer.Int32(3)
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build()
+	mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+		Client:            client,
+		MicrovmDeployment: mvmDep,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// a cordoned host's replicas are dropped, a failed host's are shared out
+	mvmScope.SetUnschedulable(infrav1.HostMap{"0": struct{}{}})
+	mvmScope.SetFailed(infrav1.HostMap{"1": struct{}{}})
+
+	g.Expect(mvmScope.ReplicasPerHost(nil)).To(Equal(map[string]int32{"2": 5, "3": 4}))
+	g.Expect(mvmScope.DesiredTotalReplicas()).To(Equal(int32(9)))
+
+	_, ok := mvmScope.FailoverAfter()
+	g.Expect(ok).To(BeFalse())
+
+	mvmDep.Spec.FailoverPolicy = &infrav1.FailoverPolicy{}
+	after, ok := mvmScope.FailoverAfter()
+	g.Expect(ok).To(BeTrue())
+	g.Expect(after).To(Equal(5 * time.Minute))
+}
+
+func TestReplicasPerHost(t *testing.T) {
+	scheme, err := setupScheme()
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+
+	tt := []struct {
+		name           string
+		replicas       int32
+		maxSkew        int32
+		hosts          int
+		failureDomains map[string]string
+		overrides      []infrav1.PlacementOverride
+		sets           []infrav1.MicrovmReplicaSet
+		expected       map[string]int32
+	}{
+		{
+			name:     "new deployment is balanced across hosts",
+			replicas: 5,
+			maxSkew:  1,
+			hosts:    3,
+			expected: map[string]int32{"0": 2, "1": 2, "2": 1},
+		},
+		{
+			name:     "added host within the skew does not move replicas",
+			replicas: 4,
+			maxSkew:  2,
+			hosts:    3,
+			sets: []infrav1.MicrovmReplicaSet{
+				newReplicaSet("rs-0", "0", 2),
+				newReplicaSet("rs-1", "1", 2),
+			},
+			expected: map[string]int32{"0": 2, "1": 2, "2": 0},
+		},
+		{
+			name:     "added host beyond the skew is rebalanced",
+			replicas: 6,
+			maxSkew:  1,
+			hosts:    3,
+			sets: []infrav1.MicrovmReplicaSet{
+				newReplicaSet("rs-0", "0", 3),
+				newReplicaSet("rs-1", "1", 3),
+			},
+			expected: map[string]int32{"0": 2, "1": 2, "2": 2},
+		},
+		{
+			name:     "replicas from a removed host are taken on by the rest",
+			replicas: 4,
+			maxSkew:  1,
+			hosts:    2,
+			sets: []infrav1.MicrovmReplicaSet{
+				newReplicaSet("rs-0", "0", 1),
+				newReplicaSet("rs-1", "1", 2),
+				newReplicaSet("rs-9", "9", 1),
+			},
+			expected: map[string]int32{"0": 2, "1": 2},
+		},
+		{
+			name:     "scale down removes from the most loaded host",
+			replicas: 2,
+			maxSkew:  1,
+			hosts:    2,
+			sets: []infrav1.MicrovmReplicaSet{
+				newReplicaSet("rs-0", "0", 1),
+				newReplicaSet("rs-1", "1", 2),
+			},
+			expected: map[string]int32{"0": 1, "1": 1},
+		},
+		{
+			name:           "new deployment is balanced across failure domains",
+			replicas:       4,
+			maxSkew:        1,
+			hosts:          3,
+			failureDomains: map[string]string{"0": "a", "1": "a", "2": "b"},
+			expected:       map[string]int32{"0": 1, "1": 1, "2": 2},
+		},
+		{
+			name:           "replicas are moved out of an overloaded failure domain",
+			replicas:       4,
+			maxSkew:        1,
+			hosts:          3,
+			failureDomains: map[string]string{"0": "a", "1": "a", "2": "b"},
+			sets: []infrav1.MicrovmReplicaSet{
+				newReplicaSet("rs-0", "0", 2),
+				newReplicaSet("rs-1", "1", 2),
+			},
+			expected: map[string]int32{"0": 1, "1": 1, "2": 2},
+		},
+		{
+			name:           "host without a failure domain is a domain of its own",
+			replicas:       3,
+			maxSkew:        1,
+			hosts:          3,
+			failureDomains: map[string]string{"0": "a", "1": "a"},
+			expected:       map[string]int32{"0": 1, "1": 1, "2": 1},
+		},
+		{
+			name:      "pinned replicas are placed before the rest are spread",
+			replicas:  5,
+			maxSkew:   1,
+			hosts:     3,
+			overrides: []infrav1.PlacementOverride{{Replica: 0, Host: "2"}, {Replica: 1, Host: "2"}},
+			expected:  map[string]int32{"0": 1, "1": 1, "2": 3},
+		},
+		{
+			name:      "pinned replicas stay put when the deployment is rebalanced",
+			replicas:  4,
+			maxSkew:   1,
+			hosts:     2,
+			overrides: []infrav1.PlacementOverride{{Replica: 0, Host: "1"}},
+			sets: []infrav1.MicrovmReplicaSet{
+				newReplicaSet("rs-0", "0", 3),
+				newReplicaSet("rs-1", "1", 1),
+			},
+			expected: map[string]int32{"0": 2, "1": 2},
+		},
+		{
+			name:      "overrides beyond the replicas or for unknown hosts are spread",
+			replicas:  2,
+			maxSkew:   1,
+			hosts:     2,
+			overrides: []infrav1.PlacementOverride{{Replica: 0, Host: "9"}, {Replica: 2, Host: "1"}},
+			expected:  map[string]int32{"0": 1, "1": 1},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mvmDep := newDeployment("md-1", tc.hosts)
+			mvmDep.Spec.Replicas = pointer.Int32(tc.replicas)
+			mvmDep.Spec.SpreadConstraints = &infrav1.SpreadConstraints{MaxSkew: tc.maxSkew}
+			mvmDep.Spec.PlacementOverrides = tc.overrides
+			if tc.failureDomains != nil {
+				mvmDep.Spec.SpreadConstraints.TopologyKey = infrav1.FailureDomainSpreadTopology
+			}
+
+			client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build()
+			mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+				Client:            client,
+				MicrovmDeployment: mvmDep,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			mvmScope.SetFailureDomains(tc.failureDomains)
+
+			g.Expect(mvmScope.ReplicasPerHost(tc.sets)).To(Equal(tc.expected))
+			g.Expect(mvmScope.DesiredTotalReplicas()).To(Equal(tc.replicas))
+		})
+	}
+}
+
+func TestSpreadSkew(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme, err := setupScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmDep := newDeployment("md-1", 3)
+	mvmDep.Spec.Replicas = pointer.Int32(3)
+	mvmDep.Spec.SpreadConstraints = &infrav1.SpreadConstraints{
+		MaxSkew:     1,
+		TopologyKey: infrav1.FailureDomainSpreadTopology,
+	}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build()
+	mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+		Client:            client,
+		MicrovmDeployment: mvmDep,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	withReady := func(rs infrav1.MicrovmReplicaSet, ready int32) infrav1.MicrovmReplicaSet {
+		rs.Status.ReadyReplicas = ready
+
+		return rs
+	}
+
+	sets := []infrav1.MicrovmReplicaSet{
+		withReady(newReplicaSet("rs-0", "0", 1), 1),
+		withReady(newReplicaSet("rs-1", "1", 1), 1),
+		withReady(newReplicaSet("rs-2", "2", 1), 0),
+	}
+
+	g.Expect(mvmScope.SpreadSkew(sets)).To(Equal(int32(1)))
+
+	mvmScope.SetFailureDomains(map[string]string{"0": "a", "1": "a", "2": "b"})
+	g.Expect(mvmScope.SpreadSkew(sets)).To(Equal(int32(2)), "Expected the skew to be measured between failure domains")
+}
+
+func TestPlacementOverrides(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme, err := setupScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmDep := newDeployment("md-1", 3)
+	mvmDep.Spec.Replicas = pointer.Int32(4)
+	mvmDep.Spec.PlacementOverrides = []infrav1.PlacementOverride{
+		{Replica: 0, Host: "0"},
+		{Replica: 1, Host: "0"},
+		{Replica: 2, Host: "1"},
+	}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build()
+	mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+		Client:            client,
+		MicrovmDeployment: mvmDep,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(mvmScope.PinnedReplicas()).To(BeEmpty(), "Expected nothing to be pinned without spread constraints")
+	g.Expect(mvmScope.UnplacedOverrides()).To(BeEmpty())
+
+	mvmDep.Spec.SpreadConstraints = &infrav1.SpreadConstraints{MaxSkew: 1}
+	mvmScope.SetUnschedulable(infrav1.HostMap{"1": struct{}{}})
+
+	g.Expect(mvmScope.PinnedReplicas()).To(Equal(map[string]int32{"0": 2}))
+	g.Expect(mvmScope.UnplacedOverrides()).To(Equal([]infrav1.PlacementOverride{{Replica: 2, Host: "1"}}))
+	g.Expect(mvmScope.ReplicasPerHost(nil)).To(Equal(map[string]int32{"0": 3, "2": 1}))
+
+	withReady := func(rs infrav1.MicrovmReplicaSet) infrav1.MicrovmReplicaSet {
+		rs.Status.ReadyReplicas = *rs.Spec.Replicas
+
+		return rs
+	}
+
+	sets := []infrav1.MicrovmReplicaSet{
+		withReady(newReplicaSet("rs-0", "0", 3)),
+		withReady(newReplicaSet("rs-2", "2", 1)),
+	}
+	g.Expect(mvmScope.SpreadSkew(sets)).To(BeZero(), "Expected pinned replicas not to count towards the skew")
+}
+
+func TestHostSummaries(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme, err := setupScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmDep := newDeployment("md-1", 0)
+	mvmDep.Spec.Replicas = pointer.Int32(2)
+	mvmDep.Spec.Hosts = []microvm.Host{{Endpoint: "1"}, {Endpoint: "2"}, {Endpoint: "3"}}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build()
+	mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+		Client:            client,
+		MicrovmDeployment: mvmDep,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ready := newReplicaSet("rs-1", "1", 2)
+	ready.Status.Replicas = 2
+	ready.Status.ReadyReplicas = 2
+	conditions.MarkTrue(&ready, infrav1.MicrovmReplicaSetReadyCondition)
+
+	stuck := newReplicaSet("rs-2", "2", 2)
+	stuck.Status.Replicas = 1
+	conditions.MarkFalse(&stuck, infrav1.MicrovmReplicaSetReadyCondition,
+		infrav1.MicrovmReplicaSetProvisionFailedReason, clusterv1.ConditionSeverityError, "")
+
+	sets := []infrav1.MicrovmReplicaSet{stuck, ready}
+
+	plan := mvmScope.PlanHosts(sets)
+	mvmScope.SetHostSummaries(sets)
+	mvmScope.SetNextHost(plan)
+
+	summaries := mvmDep.Status.HostSummaries
+	g.Expect(summaries).To(HaveLen(3))
+
+	g.Expect(summaries[0].Host).To(Equal("1"))
+	g.Expect(summaries[0].ReplicaSet).To(Equal("rs-1"))
+	g.Expect(summaries[0].ReadyReplicas).To(Equal(int32(2)))
+	g.Expect(summaries[0].Condition).NotTo(BeNil())
+	g.Expect(summaries[0].Condition.Status).To(Equal(corev1.ConditionTrue))
+
+	g.Expect(summaries[1].Host).To(Equal("2"))
+	g.Expect(summaries[1].Replicas).To(Equal(int32(1)))
+	g.Expect(summaries[1].ReadyReplicas).To(BeZero())
+	g.Expect(summaries[1].Condition).NotTo(BeNil())
+	g.Expect(summaries[1].Condition.Reason).To(Equal(infrav1.MicrovmReplicaSetProvisionFailedReason))
+
+	g.Expect(summaries[2]).To(Equal(infrav1.HostSummary{Host: "3"}), "Expected a host without a replicaset to be listed")
+	g.Expect(mvmDep.Status.NextHost).To(Equal("3"))
+
+	mvmScope.SetNextHost(scope.HostPlan{})
+	g.Expect(mvmDep.Status.NextHost).To(BeEmpty())
+}
+
+func TestMicrovmTemplate_RestartedAt(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme, err := setupScheme()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mvmDep := newDeployment("md-1", 1)
+	mvmDep.Spec.Template.Annotations = map[string]string{"team": "db"}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mvmDep).Build()
+	mvmScope, err := scope.NewMicrovmDeploymentScope(scope.MicrovmDeploymentScopeParams{
+		Client:            client,
+		MicrovmDeployment: mvmDep,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	hash := mvmScope.TemplateHash()
+
+	mvmDep.Annotations = map[string]string{infrav1.RestartedAtAnnotation: "2022-10-18T10:00:00Z"}
+
+	g.Expect(mvmScope.MicrovmTemplate().Annotations).To(Equal(map[string]string{
+		"team":                        "db",
+		infrav1.RestartedAtAnnotation: "2022-10-18T10:00:00Z",
+	}))
+	g.Expect(mvmScope.TemplateHash()).NotTo(Equal(hash), "Expected a restart to be rolled out as a new template")
+	g.Expect(mvmDep.Spec.Template.Annotations).NotTo(HaveKey(infrav1.RestartedAtAnnotation),
+		"Expected the template of the deployment to be left alone")
+}
+
+func newReplicaSet(name, endpoint string, replicas int32) infrav1.MicrovmReplicaSet {
+	return infrav1.MicrovmReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: infrav1.MicrovmReplicaSetSpec{
+			Host:     microvm.Host{Endpoint: endpoint},
+			Replicas: pointer.Int32(replicas),
+		},
+	}
+}
+
+func setNames(sets []infrav1.MicrovmReplicaSet) []string {
+	names := []string{}
+	for _, rs := range sets {
+		names = append(names, rs.Name)
+	}
+
+	return names
+}
+
 func newHostMap(hostCount int) infrav1.HostMap {
 	hostMap := infrav1.HostMap{}
 	for i := 0; i < hostCount; i++ {
@@ -0,0 +1,264 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package scope
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/go-logr/logr"
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/health"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+)
+
+const (
+	defaultWindowSeconds = 3600
+	defaultMinAttempts   = 10
+
+	milli = 1000
+)
+
+type MicrovmHostScopeParams struct {
+	Logger      logr.Logger
+	MicrovmHost *infrav1.MicrovmHost
+
+	Client  client.Client
+	Context context.Context //nolint: containedctx // don't care
+}
+
+type MicrovmHostScope struct {
+	logr.Logger
+
+	MicrovmHost *infrav1.MicrovmHost
+
+	client         client.Client
+	patchHelper    *apply.Helper
+	controllerName string
+	ctx            context.Context
+}
+
+func NewMicrovmHostScope(params MicrovmHostScopeParams) (*MicrovmHostScope, error) {
+	if params.MicrovmHost == nil {
+		return nil, errMicrovmRequired
+	}
+
+	if params.Client == nil {
+		return nil, errClientRequired
+	}
+
+	patchHelper, err := apply.NewHelper(params.MicrovmHost, params.Client)
+	if err != nil {
+		return nil, fmt.Errorf("creating patch helper for microvmhost: %w", err)
+	}
+
+	scope := &MicrovmHostScope{
+		MicrovmHost:    params.MicrovmHost,
+		client:         params.Client,
+		controllerName: defaults.ManagerName,
+		Logger:         params.Logger,
+		patchHelper:    patchHelper,
+		ctx:            params.Context,
+	}
+
+	return scope, nil
+}
+
+// Name returns the MicrovmHost name.
+func (m *MicrovmHostScope) Name() string {
+	return m.MicrovmHost.Name
+}
+
+// Endpoint returns the flintlock address of the host.
+func (m *MicrovmHostScope) Endpoint() string {
+	return m.MicrovmHost.Spec.Endpoint
+}
+
+// Objective returns the fraction of attempts which should succeed, defaulting
+// to health.DefaultObjective.
+func (m *MicrovmHostScope) Objective() float64 {
+	objective := m.MicrovmHost.Spec.ErrorBudget.Objective
+	if objective == nil {
+		return health.DefaultObjective
+	}
+
+	return objective.AsApproximateFloat64()
+}
+
+// Window returns the length of the rolling window, defaulting to an hour.
+func (m *MicrovmHostScope) Window() time.Duration {
+	seconds := m.MicrovmHost.Spec.ErrorBudget.WindowSeconds
+	if seconds == 0 {
+		seconds = defaultWindowSeconds
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// MinAttempts returns the number of attempts needed before the budget can be
+// exhausted, defaulting to 10.
+func (m *MicrovmHostScope) MinAttempts() int32 {
+	if m.MicrovmHost.Spec.ErrorBudget.MinAttempts == 0 {
+		return defaultMinAttempts
+	}
+
+	return m.MicrovmHost.Spec.ErrorBudget.MinAttempts
+}
+
+// AutoQuarantine returns true if the host should be quarantined when its budget
+// is exhausted.
+func (m *MicrovmHostScope) AutoQuarantine() bool {
+	return m.MicrovmHost.Spec.Quarantine != infrav1.NeverQuarantinePolicy
+}
+
+// IdentityVerification returns how the identity of the host is verified,
+// defaulting to enforcing it.
+func (m *MicrovmHostScope) IdentityVerification() infrav1.IdentityVerification {
+	if m.MicrovmHost.Spec.Identity.Verification == "" {
+		return infrav1.EnforceIdentityVerification
+	}
+
+	return m.MicrovmHost.Spec.Identity.Verification
+}
+
+// TrustedFingerprint returns the fingerprint the host is expected to present:
+// the pinned one if set, otherwise the one first recorded. It is empty when
+// the host has not been seen yet.
+func (m *MicrovmHostScope) TrustedFingerprint() string {
+	if m.MicrovmHost.Spec.Identity.Fingerprint != "" {
+		return m.MicrovmHost.Spec.Identity.Fingerprint
+	}
+
+	if m.MicrovmHost.Status.Identity == nil {
+		return ""
+	}
+
+	return m.MicrovmHost.Status.Identity.Fingerprint
+}
+
+// SetIdentity records id as the trusted identity of the host. An identity
+// which is already recorded keeps the time it was first seen.
+func (m *MicrovmHostScope) SetIdentity(id identity.Identity, at time.Time) {
+	current := m.MicrovmHost.Status.Identity
+	if current != nil && current.Fingerprint == id.Fingerprint {
+		current.SPIFFEID = id.SPIFFEID
+
+		return
+	}
+
+	m.MicrovmHost.Status.Identity = &infrav1.HostIdentity{
+		Fingerprint: id.Fingerprint,
+		SPIFFEID:    id.SPIFFEID,
+		FirstSeen:   metav1.NewTime(at),
+	}
+}
+
+// SetUntrusted records whether connections to the host are refused.
+func (m *MicrovmHostScope) SetUntrusted(untrusted bool) {
+	m.MicrovmHost.Status.Untrusted = untrusted
+}
+
+// SetIdentityVerified marks the host as presenting its trusted identity.
+func (m *MicrovmHostScope) SetIdentityVerified() {
+	conditions.MarkTrue(m.MicrovmHost, infrav1.MicrovmHostIdentityVerifiedCondition)
+}
+
+// SetIdentityNotVerified marks the identity of the host as not verified.
+func (m *MicrovmHostScope) SetIdentityNotVerified(
+	reason string,
+	severity clusterv1.ConditionSeverity,
+	message string,
+	messageArgs ...interface{},
+) {
+	conditions.MarkFalse(m.MicrovmHost, infrav1.MicrovmHostIdentityVerifiedCondition, reason, severity, message, messageArgs...)
+}
+
+// SetReachable records that the host answered.
+func (m *MicrovmHostScope) SetReachable() {
+	m.MicrovmHost.Status.UnreachableSince = nil
+	conditions.MarkTrue(m.MicrovmHost, infrav1.MicrovmHostReachableCondition)
+}
+
+// SetUnreachable records that the host did not answer, keeping the time it was
+// first seen to be unreachable.
+func (m *MicrovmHostScope) SetUnreachable(at time.Time, message string) {
+	if m.MicrovmHost.Status.UnreachableSince == nil {
+		since := metav1.NewTime(at)
+		m.MicrovmHost.Status.UnreachableSince = &since
+	}
+
+	conditions.MarkFalse(m.MicrovmHost, infrav1.MicrovmHostReachableCondition,
+		infrav1.MicrovmHostUnreachableReason, clusterv1.ConditionSeverityWarning, "%s", message)
+}
+
+// ClearIdentityVerification forgets any identity verification state.
+func (m *MicrovmHostScope) ClearIdentityVerification() {
+	conditions.Delete(m.MicrovmHost, infrav1.MicrovmHostIdentityVerifiedCondition)
+	m.MicrovmHost.Status.Untrusted = false
+}
+
+// Buckets returns the outcomes which make up the window.
+func (m *MicrovmHostScope) Buckets() []infrav1.ProvisioningBucket {
+	return m.MicrovmHost.Status.Buckets
+}
+
+// SetBuckets replaces the outcomes which make up the window.
+func (m *MicrovmHostScope) SetBuckets(buckets []infrav1.ProvisioningBucket) {
+	m.MicrovmHost.Status.Buckets = buckets
+}
+
+// SetSummary records the totals for the window on the status.
+func (m *MicrovmHostScope) SetSummary(summary health.Summary) {
+	m.MicrovmHost.Status.Successes = summary.Successes
+	m.MicrovmHost.Status.Failures = summary.Failures
+	m.MicrovmHost.Status.SuccessRatio = ratioQuantity(summary.SuccessRatio)
+	m.MicrovmHost.Status.ErrorBudgetRemaining = ratioQuantity(summary.BudgetRemaining)
+}
+
+// SetQuarantined records whether new Microvms are kept off the host.
+func (m *MicrovmHostScope) SetQuarantined(quarantined bool) {
+	m.MicrovmHost.Status.Quarantined = quarantined
+}
+
+// SetBudgetAvailable marks the host as having error budget remaining.
+func (m *MicrovmHostScope) SetBudgetAvailable() {
+	conditions.MarkTrue(m.MicrovmHost, infrav1.MicrovmHostErrorBudgetCondition)
+}
+
+// SetBudgetExhausted marks the host as having used up its error budget.
+func (m *MicrovmHostScope) SetBudgetExhausted(
+	reason string,
+	severity clusterv1.ConditionSeverity,
+	message string,
+	messageArgs ...interface{},
+) {
+	conditions.MarkFalse(m.MicrovmHost, infrav1.MicrovmHostErrorBudgetCondition, reason, severity, message, messageArgs...)
+}
+
+// Patch persists the resource and status.
+func (m *MicrovmHostScope) Patch() error {
+	err := m.patchHelper.Patch(
+		m.ctx,
+		m.MicrovmHost,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to patch microvmhost: %w", err)
+	}
+
+	return nil
+}
+
+func ratioQuantity(ratio float64) *resource.Quantity {
+	return resource.NewMilliQuantity(int64(math.Round(ratio*milli)), resource.DecimalSI)
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package scope
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/go-logr/logr"
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+)
+
+type MicrovmHostGroupScopeParams struct {
+	Logger           logr.Logger
+	MicrovmHostGroup *infrav1.MicrovmHostGroup
+
+	Client  client.Client
+	Context context.Context //nolint: containedctx // don't care
+}
+
+type MicrovmHostGroupScope struct {
+	logr.Logger
+
+	MicrovmHostGroup *infrav1.MicrovmHostGroup
+
+	client         client.Client
+	patchHelper    *apply.Helper
+	controllerName string
+	ctx            context.Context
+}
+
+func NewMicrovmHostGroupScope(params MicrovmHostGroupScopeParams) (*MicrovmHostGroupScope, error) {
+	if params.MicrovmHostGroup == nil {
+		return nil, errMicrovmRequired
+	}
+
+	if params.Client == nil {
+		return nil, errClientRequired
+	}
+
+	patchHelper, err := apply.NewHelper(params.MicrovmHostGroup, params.Client)
+	if err != nil {
+		return nil, fmt.Errorf("creating patch helper for microvmhostgroup: %w", err)
+	}
+
+	scope := &MicrovmHostGroupScope{
+		MicrovmHostGroup: params.MicrovmHostGroup,
+		client:           params.Client,
+		controllerName:   defaults.ManagerName,
+		Logger:           params.Logger,
+		patchHelper:      patchHelper,
+		ctx:              params.Context,
+	}
+
+	return scope, nil
+}
+
+// Name returns the MicrovmHostGroup name.
+func (m *MicrovmHostGroupScope) Name() string {
+	return m.MicrovmHostGroup.Name
+}
+
+// Namespace returns the namespace name.
+func (m *MicrovmHostGroupScope) Namespace() string {
+	return m.MicrovmHostGroup.Namespace
+}
+
+// Selector returns the selector over MicrovmHosts, or nil if members are only
+// listed explicitly.
+func (m *MicrovmHostGroupScope) Selector() (labels.Selector, error) {
+	if m.MicrovmHostGroup.Spec.Selector == nil {
+		return nil, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(m.MicrovmHostGroup.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing selector: %w", err)
+	}
+
+	return selector, nil
+}
+
+// MaxMicrovmsPerHost returns the number of Microvms at which a member is full,
+// and false if members are never full.
+func (m *MicrovmHostGroupScope) MaxMicrovmsPerHost() (int32, bool) {
+	max := m.MicrovmHostGroup.Spec.Capacity.MaxMicrovmsPerHost
+	if max == nil {
+		return 0, false
+	}
+
+	return *max, true
+}
+
+// Members returns the explicitly listed hosts together with the selected
+// MicrovmHosts, without duplicate endpoints and sorted by endpoint. An
+// explicitly listed host takes precedence over a selected one.
+func (m *MicrovmHostGroupScope) Members(selected []infrav1.MicrovmHost) []microvm.Host {
+	members := []microvm.Host{}
+	seen := infrav1.HostMap{}
+
+	for _, host := range m.MicrovmHostGroup.Spec.Hosts {
+		if _, ok := seen[host.Endpoint]; !ok {
+			seen[host.Endpoint] = struct{}{}
+			members = append(members, host)
+		}
+	}
+
+	for _, host := range selected {
+		if _, ok := seen[host.Spec.Endpoint]; !ok {
+			seen[host.Spec.Endpoint] = struct{}{}
+			members = append(members, microvm.Host{Name: host.Name, Endpoint: host.Spec.Endpoint})
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Endpoint < members[j].Endpoint
+	})
+
+	return members
+}
+
+// SetMembers records the resolved members of the group and those which are full.
+func (m *MicrovmHostGroupScope) SetMembers(hosts []microvm.Host, full []string) {
+	sort.Strings(full)
+
+	m.MicrovmHostGroup.Status.Hosts = hosts
+	m.MicrovmHostGroup.Status.FullHosts = full
+}
+
+// SetObservedGeneration records that the current spec of the MicrovmHostGroup
+// has been processed.
+func (m *MicrovmHostGroupScope) SetObservedGeneration() {
+	m.MicrovmHostGroup.Status.ObservedGeneration = m.MicrovmHostGroup.Generation
+}
+
+// SetReady sets any properties/conditions that are used to indicate that the MicrovmHostGroup is 'Ready'.
+func (m *MicrovmHostGroupScope) SetReady() {
+	conditions.MarkTrue(m.MicrovmHostGroup, infrav1.MicrovmHostGroupReadyCondition)
+	m.MicrovmHostGroup.Status.Ready = true
+}
+
+// SetNotReady sets any properties/conditions that are used to indicate that the MicrovmHostGroup is NOT 'Ready'.
+func (m *MicrovmHostGroupScope) SetNotReady(
+	reason string,
+	severity clusterv1.ConditionSeverity,
+	message string,
+	messageArgs ...interface{},
+) {
+	conditions.MarkFalse(m.MicrovmHostGroup, infrav1.MicrovmHostGroupReadyCondition, reason, severity, message, messageArgs...)
+	m.MicrovmHostGroup.Status.Ready = false
+}
+
+// Patch persists the resource and status.
+func (m *MicrovmHostGroupScope) Patch() error {
+	err := m.patchHelper.Patch(
+		m.ctx,
+		m.MicrovmHostGroup,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to patch microvmhostgroup: %w", err)
+	}
+
+	return nil
+}
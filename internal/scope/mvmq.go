@@ -0,0 +1,119 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package scope
+
+import (
+	"context"
+	"fmt"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/go-logr/logr"
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+)
+
+type MicrovmQuotaScopeParams struct {
+	Logger       logr.Logger
+	MicrovmQuota *infrav1.MicrovmQuota
+
+	Client  client.Client
+	Context context.Context //nolint: containedctx // don't care
+}
+
+type MicrovmQuotaScope struct {
+	logr.Logger
+
+	MicrovmQuota *infrav1.MicrovmQuota
+
+	client         client.Client
+	patchHelper    *apply.Helper
+	controllerName string
+	ctx            context.Context
+}
+
+func NewMicrovmQuotaScope(params MicrovmQuotaScopeParams) (*MicrovmQuotaScope, error) {
+	if params.MicrovmQuota == nil {
+		return nil, errMicrovmRequired
+	}
+
+	if params.Client == nil {
+		return nil, errClientRequired
+	}
+
+	patchHelper, err := apply.NewHelper(params.MicrovmQuota, params.Client)
+	if err != nil {
+		return nil, fmt.Errorf("creating patch helper for microvmquota: %w", err)
+	}
+
+	scope := &MicrovmQuotaScope{
+		MicrovmQuota:   params.MicrovmQuota,
+		client:         params.Client,
+		controllerName: defaults.ManagerName,
+		Logger:         params.Logger,
+		patchHelper:    patchHelper,
+		ctx:            params.Context,
+	}
+
+	return scope, nil
+}
+
+// Name returns the MicrovmQuota name.
+func (m *MicrovmQuotaScope) Name() string {
+	return m.MicrovmQuota.Name
+}
+
+// Namespace returns the namespace name.
+func (m *MicrovmQuotaScope) Namespace() string {
+	return m.MicrovmQuota.Namespace
+}
+
+// Hard returns the limits of the quota.
+func (m *MicrovmQuotaScope) Hard() infrav1.QuotaLimits {
+	return m.MicrovmQuota.Spec.Hard
+}
+
+// SetUsed records what the Microvms of the namespace use between them.
+func (m *MicrovmQuotaScope) SetUsed(used infrav1.QuotaUsage) {
+	m.MicrovmQuota.Status.Used = used
+}
+
+// SetObservedGeneration records that the current spec of the MicrovmQuota
+// has been processed.
+func (m *MicrovmQuotaScope) SetObservedGeneration() {
+	m.MicrovmQuota.Status.ObservedGeneration = m.MicrovmQuota.Generation
+}
+
+// SetReady sets any properties/conditions that are used to indicate that the MicrovmQuota is 'Ready'.
+func (m *MicrovmQuotaScope) SetReady() {
+	conditions.MarkTrue(m.MicrovmQuota, infrav1.MicrovmQuotaReadyCondition)
+	m.MicrovmQuota.Status.Ready = true
+}
+
+// SetNotReady sets any properties/conditions that are used to indicate that the MicrovmQuota is NOT 'Ready'.
+func (m *MicrovmQuotaScope) SetNotReady(
+	reason string,
+	severity clusterv1.ConditionSeverity,
+	message string,
+	messageArgs ...interface{},
+) {
+	conditions.MarkFalse(m.MicrovmQuota, infrav1.MicrovmQuotaReadyCondition, reason, severity, message, messageArgs...)
+	m.MicrovmQuota.Status.Ready = false
+}
+
+// Patch persists the resource and status.
+func (m *MicrovmQuotaScope) Patch() error {
+	err := m.patchHelper.Patch(
+		m.ctx,
+		m.MicrovmQuota,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to patch microvmquota: %w", err)
+	}
+
+	return nil
+}
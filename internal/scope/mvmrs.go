@@ -6,18 +6,31 @@ package scope
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
-	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/go-logr/logr"
 	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
 	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
 	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/dryrun"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/replica"
 )
 
+// defaultMaxCreatePerReconcile is how many microvms are created at once when
+// the replicaset does not say.
+const defaultMaxCreatePerReconcile = 5
+
 type MicrovmReplicaSetScopeParams struct {
 	Logger            logr.Logger
 	MicrovmReplicaSet *infrav1.MicrovmReplicaSet
@@ -31,8 +44,11 @@ type MicrovmReplicaSetScope struct {
 
 	MicrovmReplicaSet *infrav1.MicrovmReplicaSet
 
+	// template is the content of the referenced MicrovmTemplate, if any.
+	template *infrav1.MicrovmTemplateSpec
+
 	client         client.Client
-	patchHelper    *patch.Helper
+	patchHelper    *apply.Helper
 	controllerName string
 	ctx            context.Context
 }
@@ -46,7 +62,7 @@ func NewMicrovmReplicaSetScope(params MicrovmReplicaSetScopeParams) (*MicrovmRep
 		return nil, errClientRequired
 	}
 
-	patchHelper, err := patch.NewHelper(params.MicrovmReplicaSet, params.Client)
+	patchHelper, err := apply.NewHelper(params.MicrovmReplicaSet, params.Client)
 	if err != nil {
 		return nil, fmt.Errorf("creating patch helper for microvmreplicaset: %w", err)
 	}
@@ -78,6 +94,21 @@ func (m *MicrovmReplicaSetScope) DesiredReplicas() int32 {
 	return *m.MicrovmReplicaSet.Spec.Replicas
 }
 
+// MaxCreatePerReconcile returns how many microvms may be created at once.
+func (m *MicrovmReplicaSetScope) MaxCreatePerReconcile() int32 {
+	if limit := m.MicrovmReplicaSet.Spec.MaxCreatePerReconcile; limit != nil && *limit > 0 {
+		return *limit
+	}
+
+	return defaultMaxCreatePerReconcile
+}
+
+// SetDesiredReplicas sets the requested replicas on the spec, such as when
+// they are driven by a Cluster API MachinePool.
+func (m *MicrovmReplicaSetScope) SetDesiredReplicas(count int32) {
+	m.MicrovmReplicaSet.Spec.Replicas = &count
+}
+
 // ReadyReplicas returns the number of replicas which are ready.
 func (m *MicrovmReplicaSetScope) ReadyReplicas() int32 {
 	return *&m.MicrovmReplicaSet.Status.ReadyReplicas
@@ -90,12 +121,143 @@ func (m *MicrovmReplicaSetScope) CreatedReplicas() int32 {
 
 // GetMicrovmSpec returns the spec for the child MicroVM
 func (m *MicrovmReplicaSetScope) MicrovmSpec() infrav1.MicrovmSpec {
-	return m.MicrovmReplicaSet.Spec.Template.Spec
+	return m.MicrovmTemplate().Spec
+}
+
+// MicrovmTemplate returns the template for the child MicroVMs, which is the
+// content of the referenced MicrovmTemplate when there is one.
+func (m *MicrovmReplicaSetScope) MicrovmTemplate() infrav1.MicrovmTemplateSpec {
+	if m.template != nil {
+		return *m.template
+	}
+
+	return m.MicrovmReplicaSet.Spec.Template
+}
+
+// TemplateRef returns the reference to the MicrovmTemplate to use instead of
+// the inline template, or nil.
+func (m *MicrovmReplicaSetScope) TemplateRef() *corev1.LocalObjectReference {
+	return m.MicrovmReplicaSet.Spec.TemplateRef
+}
+
+// SetTemplate sets the content of the referenced MicrovmTemplate and records
+// its revision, or clears the revision if mvmT is nil.
+func (m *MicrovmReplicaSetScope) SetTemplate(mvmT *infrav1.MicrovmTemplate) {
+	if mvmT == nil {
+		m.template = nil
+		m.MicrovmReplicaSet.Status.TemplateRevision = 0
+
+		return
+	}
+
+	template := mvmT.EffectiveTemplate()
+	m.template = &template
+	m.MicrovmReplicaSet.Status.TemplateRevision = mvmT.Status.Revision
+}
+
+// TemplateHash returns the hash of the template the child MicroVMs are
+// created from.
+func (m *MicrovmReplicaSetScope) TemplateHash() string {
+	if m.template != nil {
+		return replica.TemplateHash(*m.template)
+	}
+
+	return replica.Hash(m.MicrovmReplicaSet)
+}
+
+// Hosts returns the hosts the child MicroVMs are created on.
+func (m *MicrovmReplicaSetScope) Hosts() []microvm.Host {
+	return replica.Hosts(m.MicrovmReplicaSet)
+}
+
+// FailoverAfter returns how long one of the hosts must be unreachable before
+// its microvms are recreated on the others, and false if failover is not
+// enabled.
+func (m *MicrovmReplicaSetScope) FailoverAfter() (time.Duration, bool) {
+	policy := m.MicrovmReplicaSet.Spec.FailoverPolicy
+	if policy == nil || len(m.MicrovmReplicaSet.Spec.Hosts) < 2 {
+		return 0, false
+	}
+
+	if policy.UnreachableSeconds < 1 {
+		return defaultFailoverAfter, true
+	}
+
+	return time.Duration(policy.UnreachableSeconds) * time.Second, true
+}
+
+// Selector returns the label selector for adopting and releasing Microvms, or
+// nil if the replicaset has none.
+func (m *MicrovmReplicaSetScope) Selector() (labels.Selector, error) {
+	if m.MicrovmReplicaSet.Spec.Selector == nil {
+		return nil, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(m.MicrovmReplicaSet.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing selector: %w", err)
+	}
+
+	return selector, nil
+}
+
+// ResolvesImages returns true if the images of the template given by tag are
+// resolved to digests before microvms are created from it.
+func (m *MicrovmReplicaSetScope) ResolvesImages() bool {
+	return m.MicrovmSpec().ImagePolicy == infrav1.ImagePolicyResolve
+}
+
+// ResolvedImages returns the digests the images of the template were resolved to.
+func (m *MicrovmReplicaSetScope) ResolvedImages() map[string]string {
+	return m.MicrovmReplicaSet.Status.ResolvedImages
+}
+
+// SetResolvedImages records the digests the images of the template were
+// resolved to, which every microvm is created with.
+func (m *MicrovmReplicaSetScope) SetResolvedImages(resolved map[string]string) {
+	if len(resolved) == 0 {
+		resolved = nil
+	}
+
+	m.MicrovmReplicaSet.Status.ResolvedImages = resolved
+}
+
+// OrphanOnDelete returns true if the microvms are released rather than deleted
+// with the replicaset.
+func (m *MicrovmReplicaSetScope) OrphanOnDelete() bool {
+	return m.MicrovmReplicaSet.Spec.DeletePolicy == infrav1.DeletePolicyOrphan
+}
+
+// RestartedAt returns the value of the restartedAt annotation the Microvms
+// are restarted after, or an empty string if no restart has been asked for.
+func (m *MicrovmReplicaSetScope) RestartedAt() string {
+	return m.MicrovmReplicaSet.Annotations[infrav1.RestartedAtAnnotation]
+}
+
+// ReplacesFailed returns true if mvm has failed in a way the restart policy
+// says it is to be deleted and replaced.
+func (m *MicrovmReplicaSetScope) ReplacesFailed(mvm *infrav1.Microvm) bool {
+	if mvm.Status.VMState == nil || mvm.Annotations[infrav1.DeleteProtectionAnnotation] == "true" {
+		return false
+	}
+
+	switch m.MicrovmReplicaSet.Spec.RestartPolicy {
+	case infrav1.RestartPolicyAlways:
+		return *mvm.Status.VMState == microvm.VMStateFailed || *mvm.Status.VMState == microvm.VMStateUnknown
+	case infrav1.RestartPolicyOnFailure:
+		return *mvm.Status.VMState == microvm.VMStateFailed
+	default:
+		return false
+	}
 }
 
-// GetMicrovmHost returns the host for the child MicroVM
-func (m *MicrovmReplicaSetScope) MicrovmHost() microvm.Host {
-	return m.MicrovmReplicaSet.Spec.Host
+// SetSelector records the selector in the status for the scale subresource.
+func (m *MicrovmReplicaSetScope) SetSelector(selector labels.Selector) {
+	m.MicrovmReplicaSet.Status.Selector = ""
+
+	if selector != nil {
+		m.MicrovmReplicaSet.Status.Selector = selector.String()
+	}
 }
 
 // SetCreatedReplicas records the number of microvms which have been created
@@ -109,6 +271,132 @@ func (m *MicrovmReplicaSetScope) SetReadyReplicas(count int32) {
 	m.MicrovmReplicaSet.Status.ReadyReplicas = count
 }
 
+// SetAvailableReplicas saves the number of the given MicroVMs which have been
+// ready for at least MinReadySeconds at now to the status, and returns how long
+// until the next of those which are ready becomes available, or 0 if none will.
+func (m *MicrovmReplicaSetScope) SetAvailableReplicas(mvms []infrav1.Microvm, now time.Time) time.Duration {
+	minReady := time.Duration(m.MicrovmReplicaSet.Spec.MinReadySeconds) * time.Second
+
+	var (
+		available int32
+		next      time.Duration
+	)
+
+	for i := range mvms {
+		if !mvms[i].Status.Ready {
+			continue
+		}
+
+		// a microvm ready without a condition to say since when has been
+		// ready for as long as can be known
+		condition := conditions.Get(&mvms[i], infrav1.MicrovmReadyCondition)
+		if condition == nil {
+			available++
+
+			continue
+		}
+
+		wait := condition.LastTransitionTime.Add(minReady).Sub(now)
+		if wait <= 0 {
+			available++
+
+			continue
+		}
+
+		if next == 0 || wait < next {
+			next = wait
+		}
+	}
+
+	m.MicrovmReplicaSet.Status.AvailableReplicas = available
+
+	return next
+}
+
+// SetMicrovmSummaries saves the state of each of the given MicroVMs to the
+// status, ordered by name.
+func (m *MicrovmReplicaSetScope) SetMicrovmSummaries(mvms []infrav1.Microvm) {
+	summaries := make([]infrav1.MicrovmSummary, 0, len(mvms))
+
+	for i := range mvms {
+		mvm := &mvms[i]
+
+		var index *int32
+		if i, ok := replica.Index(mvm); ok {
+			index = pointer.Int32(int32(i))
+		}
+
+		summaries = append(summaries, infrav1.MicrovmSummary{
+			Name:          mvm.Name,
+			Host:          mvm.Spec.Host.Endpoint,
+			ReplicaIndex:  index,
+			VMState:       mvm.Status.VMState,
+			Ready:         mvm.Status.Ready,
+			FailureReason: infrav1.GetFailureReason(mvm),
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+
+	m.MicrovmReplicaSet.Status.MicrovmSummaries = summaries
+}
+
+// SetHostSummaries records how many of the given microvms, and how many of
+// those which are ready, are on each host, along with the hosts which have
+// failed. A host which is no longer one of the hosts is listed for as long as
+// microvms are left on it.
+func (m *MicrovmReplicaSetScope) SetHostSummaries(mvms []infrav1.Microvm, failed map[string]bool) {
+	byHost := map[string]*infrav1.ReplicaSetHostSummary{}
+
+	for _, host := range m.Hosts() {
+		byHost[host.Endpoint] = &infrav1.ReplicaSetHostSummary{Host: host.Endpoint}
+	}
+
+	for i := range mvms {
+		endpoint := mvms[i].Spec.Host.Endpoint
+		if _, ok := byHost[endpoint]; !ok {
+			byHost[endpoint] = &infrav1.ReplicaSetHostSummary{Host: endpoint}
+		}
+
+		byHost[endpoint].Replicas++
+
+		if mvms[i].Status.Ready {
+			byHost[endpoint].ReadyReplicas++
+		}
+	}
+
+	summaries := make([]infrav1.ReplicaSetHostSummary, 0, len(byHost))
+
+	for endpoint, summary := range byHost {
+		summary.Failed = failed[endpoint]
+		summaries = append(summaries, *summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Host < summaries[j].Host
+	})
+
+	m.MicrovmReplicaSet.Status.HostSummaries = summaries
+}
+
+// SetProviderIDList saves the provider IDs of the given MicroVMs which have
+// one to the spec, in order, for the Cluster API MachinePool to read.
+func (m *MicrovmReplicaSetScope) SetProviderIDList(mvms []infrav1.Microvm) {
+	ids := []string{}
+
+	for i := range mvms {
+		if id := mvms[i].Spec.ProviderID; id != nil && *id != "" {
+			ids = append(ids, *id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	m.MicrovmReplicaSet.Spec.ProviderIDList = ids
+}
+
 // SetReady sets any properties/conditions that are used to indicate that the Microvm is 'Ready'.
 func (m *MicrovmReplicaSetScope) SetReady() {
 	conditions.MarkTrue(m.MicrovmReplicaSet, infrav1.MicrovmReplicaSetReadyCondition)
@@ -126,8 +414,47 @@ func (m *MicrovmReplicaSetScope) SetNotReady(
 	m.MicrovmReplicaSet.Status.Ready = false
 }
 
-// Patch persists the resource and status.
+// SetHostReachable records that the hosts of the MicrovmReplicaSet are answering.
+func (m *MicrovmReplicaSetScope) SetHostReachable() {
+	conditions.MarkTrue(m.MicrovmReplicaSet, infrav1.MicrovmReplicaSetHostReachableCondition)
+}
+
+// SetHostUnreachable marks the MicrovmReplicaSet degraded because the hosts
+// with the given endpoints are not answering.
+func (m *MicrovmReplicaSetScope) SetHostUnreachable(endpoints []string) {
+	message := "host %s is unreachable"
+	if len(endpoints) > 1 {
+		message = "hosts %s are unreachable"
+	}
+
+	conditions.MarkFalse(m.MicrovmReplicaSet, infrav1.MicrovmReplicaSetHostReachableCondition,
+		infrav1.MicrovmReplicaSetHostUnreachableReason, clusterv1.ConditionSeverityWarning,
+		message, strings.Join(endpoints, ", "))
+}
+
+// SetDryRun records what a reconcile in dry-run mode would have done to the
+// current spec of the MicrovmReplicaSet.
+func (m *MicrovmReplicaSetScope) SetDryRun(actions []infrav1.PlannedAction) {
+	m.MicrovmReplicaSet.Status.DryRun = dryrun.Status(m.MicrovmReplicaSet.Generation, actions)
+}
+
+// ClearDryRun removes what was recorded while the MicrovmReplicaSet was reconciled in
+// dry-run mode.
+func (m *MicrovmReplicaSetScope) ClearDryRun() {
+	m.MicrovmReplicaSet.Status.DryRun = nil
+}
+
+// SetObservedGeneration records that the current spec of the MicrovmReplicaSet has been
+// processed.
+func (m *MicrovmReplicaSetScope) SetObservedGeneration() {
+	m.MicrovmReplicaSet.Status.ObservedGeneration = m.MicrovmReplicaSet.Generation
+}
+
+// Patch sets the phase from the conditions, then persists the resource and
+// status.
 func (m *MicrovmReplicaSetScope) Patch() error {
+	m.MicrovmReplicaSet.Status.Phase = infrav1.GetPhase(m.MicrovmReplicaSet)
+
 	err := m.patchHelper.Patch(
 		m.ctx,
 		m.MicrovmReplicaSet,
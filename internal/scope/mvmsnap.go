@@ -0,0 +1,128 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package scope
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/go-logr/logr"
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/imagepin"
+)
+
+type MicrovmSnapshotScopeParams struct {
+	Logger          logr.Logger
+	MicrovmSnapshot *infrav1.MicrovmSnapshot
+
+	Client  client.Client
+	Context context.Context //nolint: containedctx // don't care
+}
+
+type MicrovmSnapshotScope struct {
+	logr.Logger
+
+	MicrovmSnapshot *infrav1.MicrovmSnapshot
+
+	client         client.Client
+	patchHelper    *apply.Helper
+	controllerName string
+	ctx            context.Context
+}
+
+func NewMicrovmSnapshotScope(params MicrovmSnapshotScopeParams) (*MicrovmSnapshotScope, error) {
+	if params.MicrovmSnapshot == nil {
+		return nil, errMicrovmRequired
+	}
+
+	if params.Client == nil {
+		return nil, errClientRequired
+	}
+
+	patchHelper, err := apply.NewHelper(params.MicrovmSnapshot, params.Client)
+	if err != nil {
+		return nil, fmt.Errorf("creating patch helper for microvmsnapshot: %w", err)
+	}
+
+	scope := &MicrovmSnapshotScope{
+		MicrovmSnapshot: params.MicrovmSnapshot,
+		client:          params.Client,
+		controllerName:  defaults.ManagerName,
+		Logger:          params.Logger,
+		patchHelper:     patchHelper,
+		ctx:             params.Context,
+	}
+
+	return scope, nil
+}
+
+// Name returns the MicrovmSnapshot name.
+func (m *MicrovmSnapshotScope) Name() string {
+	return m.MicrovmSnapshot.Name
+}
+
+// Namespace returns the namespace name.
+func (m *MicrovmSnapshotScope) Namespace() string {
+	return m.MicrovmSnapshot.Namespace
+}
+
+// SourceName returns the name of the Microvm to snapshot.
+func (m *MicrovmSnapshotScope) SourceName() string {
+	return m.MicrovmSnapshot.Spec.SourceRef.Name
+}
+
+// Taken returns true if the snapshot has already been taken, after which it
+// is never changed.
+func (m *MicrovmSnapshotScope) Taken() bool {
+	return m.MicrovmSnapshot.Status.VMSpec != nil
+}
+
+// Take records the VM spec of the source Microvm as the snapshot, with the
+// images it resolved to digests pinned, so that clones boot the same images.
+func (m *MicrovmSnapshotScope) Take(source *infrav1.Microvm, now time.Time) {
+	taken := metav1.NewTime(now)
+
+	m.MicrovmSnapshot.Status.SourceUID = source.UID
+	m.MicrovmSnapshot.Status.TakenAt = &taken
+	m.MicrovmSnapshot.Status.VMSpec = source.Spec.VMSpec.DeepCopy()
+	imagepin.Pin(m.MicrovmSnapshot.Status.VMSpec, source.Status.ResolvedImages)
+}
+
+// SetReady sets any properties/conditions that are used to indicate that the MicrovmSnapshot is 'Ready'.
+func (m *MicrovmSnapshotScope) SetReady() {
+	conditions.MarkTrue(m.MicrovmSnapshot, infrav1.MicrovmSnapshotReadyCondition)
+	m.MicrovmSnapshot.Status.Ready = true
+}
+
+// SetNotReady sets any properties/conditions that are used to indicate that the MicrovmSnapshot is NOT 'Ready'.
+func (m *MicrovmSnapshotScope) SetNotReady(
+	reason string,
+	severity clusterv1.ConditionSeverity,
+	message string,
+	messageArgs ...interface{},
+) {
+	conditions.MarkFalse(m.MicrovmSnapshot, infrav1.MicrovmSnapshotReadyCondition, reason, severity, message, messageArgs...)
+	m.MicrovmSnapshot.Status.Ready = false
+}
+
+// Patch persists the resource and status.
+func (m *MicrovmSnapshotScope) Patch() error {
+	err := m.patchHelper.Patch(
+		m.ctx,
+		m.MicrovmSnapshot,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to patch microvmsnapshot: %w", err)
+	}
+
+	return nil
+}
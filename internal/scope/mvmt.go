@@ -0,0 +1,137 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package scope
+
+import (
+	"context"
+	"fmt"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/go-logr/logr"
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/apply"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/replica"
+)
+
+type MicrovmTemplateScopeParams struct {
+	Logger          logr.Logger
+	MicrovmTemplate *infrav1.MicrovmTemplate
+
+	Client  client.Client
+	Context context.Context //nolint: containedctx // don't care
+}
+
+type MicrovmTemplateScope struct {
+	logr.Logger
+
+	MicrovmTemplate *infrav1.MicrovmTemplate
+
+	client         client.Client
+	patchHelper    *apply.Helper
+	controllerName string
+	ctx            context.Context
+}
+
+func NewMicrovmTemplateScope(params MicrovmTemplateScopeParams) (*MicrovmTemplateScope, error) {
+	if params.MicrovmTemplate == nil {
+		return nil, errMicrovmTemplateRequired
+	}
+
+	if params.Client == nil {
+		return nil, errClientRequired
+	}
+
+	patchHelper, err := apply.NewHelper(params.MicrovmTemplate, params.Client)
+	if err != nil {
+		return nil, fmt.Errorf("creating patch helper for microvmtemplate: %w", err)
+	}
+
+	scope := &MicrovmTemplateScope{
+		MicrovmTemplate: params.MicrovmTemplate,
+		client:          params.Client,
+		controllerName:  defaults.ManagerName,
+		Logger:          params.Logger,
+		patchHelper:     patchHelper,
+		ctx:             params.Context,
+	}
+
+	return scope, nil
+}
+
+// Name returns the MicrovmTemplate name.
+func (m *MicrovmTemplateScope) Name() string {
+	return m.MicrovmTemplate.Name
+}
+
+// Namespace returns the namespace name.
+func (m *MicrovmTemplateScope) Namespace() string {
+	return m.MicrovmTemplate.Namespace
+}
+
+// OCISource returns the OCI artifact the template is resolved from, or nil if
+// the template is defined inline.
+func (m *MicrovmTemplateScope) OCISource() *infrav1.OCITemplateSource {
+	if m.MicrovmTemplate.Source == nil {
+		return nil
+	}
+
+	return m.MicrovmTemplate.Source.OCI
+}
+
+// IsResolved returns true if the template already holds the content of its source.
+func (m *MicrovmTemplateScope) IsResolved() bool {
+	return m.MicrovmTemplate.IsResolved()
+}
+
+// SetResolved records the template content resolved from reference.
+func (m *MicrovmTemplateScope) SetResolved(template infrav1.MicrovmTemplateSpec, reference string) {
+	m.MicrovmTemplate.Status.ResolvedTemplate = &template
+	m.MicrovmTemplate.Status.ResolvedReference = reference
+}
+
+// SetRevision records the revision of the content of the template, which is
+// incremented whenever the content changes.
+func (m *MicrovmTemplateScope) SetRevision() {
+	hash := replica.TemplateHash(m.MicrovmTemplate.EffectiveTemplate())
+	if hash == m.MicrovmTemplate.Status.TemplateHash {
+		return
+	}
+
+	m.MicrovmTemplate.Status.Revision++
+	m.MicrovmTemplate.Status.TemplateHash = hash
+}
+
+// SetReady sets any properties/conditions that are used to indicate that the MicrovmTemplate is 'Ready'.
+func (m *MicrovmTemplateScope) SetReady() {
+	conditions.MarkTrue(m.MicrovmTemplate, infrav1.MicrovmTemplateReadyCondition)
+	m.MicrovmTemplate.Status.Ready = true
+}
+
+// SetNotReady sets any properties/conditions that are used to indicate that the MicrovmTemplate is NOT 'Ready'.
+func (m *MicrovmTemplateScope) SetNotReady(
+	reason string,
+	severity clusterv1.ConditionSeverity,
+	message string,
+	messageArgs ...interface{},
+) {
+	conditions.MarkFalse(m.MicrovmTemplate, infrav1.MicrovmTemplateReadyCondition, reason, severity, message, messageArgs...)
+	m.MicrovmTemplate.Status.Ready = false
+}
+
+// Patch persists the resource and status.
+func (m *MicrovmTemplateScope) Patch() error {
+	err := m.patchHelper.Patch(
+		m.ctx,
+		m.MicrovmTemplate,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to patch microvmtemplate: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package shutdown asks Microvm guests to shut down cleanly.
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestaddr"
+)
+
+const defaultRequestTimeout = 5 * time.Second
+
+// Client requests a graceful shutdown of a guest.
+type Client interface {
+	Shutdown(ctx context.Context, endpoint string) error
+}
+
+// AgentClient requests a shutdown from an agent in the guest over HTTP.
+type AgentClient struct {
+	HTTPClient *http.Client
+}
+
+// NewAgentClient returns a Client which POSTs to /shutdown on the agent endpoint.
+func NewAgentClient() Client {
+	return &AgentClient{HTTPClient: &http.Client{Timeout: defaultRequestTimeout, CheckRedirect: guestaddr.NoRedirects}}
+}
+
+// Shutdown asks the agent at endpoint to shut the guest down. It returns once the
+// agent has accepted the request, not when the guest has stopped.
+func (a *AgentClient) Shutdown(ctx context.Context, endpoint string) error {
+	url := strings.TrimSuffix(endpoint, "/") + "/shutdown"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return fmt.Errorf("building shutdown request: %w", err)
+	}
+
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("requesting shutdown: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s", errShutdownRejected, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package shutdown_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/shutdown"
+)
+
+func TestAgentClient_Shutdown(t *testing.T) {
+	tt := []struct {
+		name     string
+		status   int
+		expected func(*WithT, error)
+	}{
+		{
+			name:   "accepted request succeeds",
+			status: http.StatusAccepted,
+			expected: func(g *WithT, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+			},
+		},
+		{
+			name:   "rejected request fails",
+			status: http.StatusServiceUnavailable,
+			expected: func(g *WithT, err error) {
+				g.Expect(err).To(HaveOccurred())
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				g.Expect(r.Method).To(Equal(http.MethodPost))
+				g.Expect(r.URL.Path).To(Equal("/shutdown"))
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			tc.expected(g, shutdown.NewAgentClient().Shutdown(context.TODO(), server.URL+"/"))
+		})
+	}
+}
+
+func TestAgentClient_Shutdown_Redirect(t *testing.T) {
+	g := NewWithT(t)
+
+	redirected := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/elsewhere" {
+			redirected = true
+			w.WriteHeader(http.StatusAccepted)
+
+			return
+		}
+
+		http.Redirect(w, r, "/elsewhere", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	g.Expect(shutdown.NewAgentClient().Shutdown(context.TODO(), server.URL)).NotTo(Succeed())
+	g.Expect(redirected).To(BeFalse(), "Expected the redirect not to be followed")
+}
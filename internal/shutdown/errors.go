@@ -0,0 +1,8 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package shutdown
+
+import "errors"
+
+var errShutdownRejected = errors.New("guest agent rejected shutdown")
@@ -0,0 +1,157 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package sshservice creates a Service for the SSH port of a guest, so that it
+// can be reached through cluster DNS like any other workload. The Service has
+// no selector and its single EndpointSlice points at the address of the guest.
+package sshservice
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/defaults"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestaddr"
+)
+
+const (
+	// DefaultPort is the port sshd listens on when the Microvm does not say.
+	DefaultPort = 22
+
+	portName = "ssh"
+	suffix   = "-ssh"
+)
+
+// Name returns the name of the Service for the SSH port of the Microvm. It is
+// named after the Microvm, unless that would not be a valid Service name, in
+// which case it is named after its UID.
+func Name(mvm *infrav1.Microvm) string {
+	name := mvm.Name + suffix
+	if len(validation.IsDNS1035Label(name)) == 0 {
+		return name
+	}
+
+	return portName + "-" + string(mvm.UID)
+}
+
+// Port returns the port the Service of sshService exposes.
+func Port(sshService *infrav1.SSHService) int32 {
+	if sshService.Port == 0 {
+		return DefaultPort
+	}
+
+	return sshService.Port
+}
+
+// Address returns the address of the network interface with the guest device
+// name, or of the first interface with an address when device is empty. It
+// returns false if the interface has no address the operator knows of.
+func Address(ifaces []microvm.NetworkInterface, device string) (netip.Addr, bool) {
+	for _, iface := range ifaces {
+		if device != "" && iface.GuestDeviceName != device {
+			continue
+		}
+
+		if addr, ok := guestaddr.Parse(iface.Address); ok {
+			return addr, true
+		}
+
+		if device != "" {
+			break
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// Apply creates or updates the Service called name for the SSH port of the
+// Microvm, and points its EndpointSlice at addr. Both are labelled with the
+// UID of the Microvm, and the EndpointSlice is owned by the Service so that it
+// goes when the Service is deleted.
+func Apply(ctx context.Context, c client.Client, mvm *infrav1.Microvm, name string, addr netip.Addr, port int32) error {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: mvm.Namespace, Name: name},
+	}
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, c, svc, func() error {
+		if uid, ok := svc.Labels[infrav1.MicrovmUIDLabel]; ok && uid != string(mvm.UID) {
+			return fmt.Errorf("%w: %s", errNotOwned, name)
+		}
+
+		svc.Labels = withLabel(svc.Labels, infrav1.MicrovmUIDLabel, string(mvm.UID))
+		svc.Spec.Selector = nil
+		svc.Spec.Ports = []corev1.ServicePort{{
+			Name:       portName,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       port,
+			TargetPort: intstr.FromInt(int(port)),
+		}}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("applying service: %w", err)
+	}
+
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Namespace: mvm.Namespace, Name: name},
+	}
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, c, slice, func() error {
+		slice.Labels = withLabel(slice.Labels, infrav1.MicrovmUIDLabel, string(mvm.UID))
+		slice.Labels[discoveryv1.LabelServiceName] = name
+		slice.Labels[discoveryv1.LabelManagedBy] = defaults.ManagerName
+		slice.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Name:       svc.Name,
+			UID:        svc.UID,
+		}}
+		slice.AddressType = addressType(addr)
+		slice.Endpoints = []discoveryv1.Endpoint{{
+			Addresses: []string{addr.String()},
+		}}
+
+		protocol := corev1.ProtocolTCP
+		slice.Ports = []discoveryv1.EndpointPort{{
+			Name:     pointer.String(portName),
+			Protocol: &protocol,
+			Port:     &port,
+		}}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("applying endpointslice: %w", err)
+	}
+
+	return nil
+}
+
+func addressType(addr netip.Addr) discoveryv1.AddressType {
+	if addr.Is4() {
+		return discoveryv1.AddressTypeIPv4
+	}
+
+	return discoveryv1.AddressTypeIPv6
+}
+
+func withLabel(labels map[string]string, key, value string) map[string]string {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	labels[key] = value
+
+	return labels
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package sshservice_test
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/sshservice"
+)
+
+func TestName(t *testing.T) {
+	g := NewWithT(t)
+
+	mvm := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: "mvm1", UID: "3c5e1a2b-0000-4000-8000-000000000001"}}
+	g.Expect(sshservice.Name(mvm)).To(Equal("mvm1-ssh"))
+
+	mvm.Name = "1.mvm"
+	g.Expect(sshservice.Name(mvm)).To(Equal("ssh-3c5e1a2b-0000-4000-8000-000000000001"),
+		"Expected a microvm name which is not a valid service name to be replaced by its uid")
+
+	mvm.Name = strings.Repeat("a", 60)
+	g.Expect(sshservice.Name(mvm)).To(HavePrefix("ssh-"), "Expected a name too long for a service to be replaced by its uid")
+}
+
+func TestAddress(t *testing.T) {
+	ifaces := []microvm.NetworkInterface{
+		{GuestDeviceName: "eth0"},
+		{GuestDeviceName: "eth1", Address: "10.0.0.5/24"},
+		{GuestDeviceName: "eth2", Address: "fd00::5"},
+	}
+
+	tt := []struct {
+		name     string
+		device   string
+		expected string
+	}{
+		{name: "first interface with an address", expected: "10.0.0.5"},
+		{name: "named interface", device: "eth2", expected: "fd00::5"},
+		{name: "named interface using dhcp", device: "eth0"},
+		{name: "missing interface", device: "eth3"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			addr, ok := sshservice.Address(ifaces, tc.device)
+			if tc.expected == "" {
+				g.Expect(ok).To(BeFalse(), "Expected no address")
+
+				return
+			}
+
+			g.Expect(ok).To(BeTrue(), "Expected an address")
+			g.Expect(addr.String()).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(discoveryv1.AddToScheme(scheme)).To(Succeed())
+
+	mvm := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1", UID: "uid-1"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	err := sshservice.Apply(context.TODO(), c, mvm, "mvm1-ssh", netip.MustParseAddr("10.0.0.5"), 22)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	svc := &corev1.Service{}
+	g.Expect(c.Get(context.TODO(), client.ObjectKey{Namespace: "ns1", Name: "mvm1-ssh"}, svc)).To(Succeed())
+	g.Expect(svc.Labels).To(HaveKeyWithValue(infrav1.MicrovmUIDLabel, "uid-1"))
+	g.Expect(svc.Spec.Selector).To(BeEmpty(), "Expected the service to have no selector")
+	g.Expect(svc.Spec.Ports).To(HaveLen(1))
+	g.Expect(svc.Spec.Ports[0].Port).To(BeEquivalentTo(22))
+
+	err = sshservice.Apply(context.TODO(), c, mvm, "mvm1-ssh", netip.MustParseAddr("fd00::5"), 2222)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	slice := &discoveryv1.EndpointSlice{}
+	g.Expect(c.Get(context.TODO(), client.ObjectKey{Namespace: "ns1", Name: "mvm1-ssh"}, slice)).To(Succeed())
+	g.Expect(slice.Labels).To(HaveKeyWithValue(discoveryv1.LabelServiceName, "mvm1-ssh"))
+	g.Expect(slice.AddressType).To(Equal(discoveryv1.AddressTypeIPv6), "Expected the address type to follow the address")
+	g.Expect(slice.Endpoints).To(HaveLen(1))
+	g.Expect(slice.Endpoints[0].Addresses).To(ConsistOf("fd00::5"), "Expected the endpoint to be updated")
+	g.Expect(*slice.Ports[0].Port).To(BeEquivalentTo(2222))
+	g.Expect(slice.OwnerReferences).To(HaveLen(1))
+	g.Expect(slice.OwnerReferences[0].Name).To(Equal("mvm1-ssh"))
+
+	other := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: "mvm2", Namespace: "ns1", UID: "uid-2"}}
+
+	err = sshservice.Apply(context.TODO(), c, other, "mvm1-ssh", netip.MustParseAddr("10.0.0.6"), 22)
+	g.Expect(err).To(HaveOccurred(), "Expected the service of another microvm not to be taken over")
+}
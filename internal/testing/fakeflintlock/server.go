@@ -0,0 +1,344 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package fakeflintlock serves the flintlock MicroVM service from memory over
+// a real gRPC listener, so that tests can drive the operator through the same
+// client, interceptors and wire format it uses against a flintlock host.
+//
+// Each microvm moves through a programmable sequence of states, one step each
+// time it is read, and errors can be injected into any call.
+package fakeflintlock
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Method names a call of the MicroVM service.
+type Method string
+
+const (
+	CreateMicroVM Method = "CreateMicroVM"
+	DeleteMicroVM Method = "DeleteMicroVM"
+	GetMicroVM    Method = "GetMicroVM"
+	ListMicroVMs  Method = "ListMicroVMs"
+)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithCreateStates sets the states a microvm moves through once created. It
+// reports the first when it is created, and moves on to the next each time it
+// is read, keeping the last. Defaults to PENDING then CREATED.
+func WithCreateStates(states ...flintlocktypes.MicroVMStatus_MicroVMState) Option {
+	return func(s *Server) {
+		s.createStates = states
+	}
+}
+
+// WithDeletingReads sets how many times a microvm reports DELETING when read
+// after being deleted, before it is gone. Defaults to 1.
+func WithDeletingReads(reads int) Option {
+	return func(s *Server) {
+		s.deletingReads = reads
+	}
+}
+
+// microvm is a microvm held by the server, along with where it is in its
+// sequence of states.
+type microvm struct {
+	vm *flintlocktypes.MicroVM
+	// step is the index of the next create state to report.
+	step int
+	// deleting is true once the microvm has been deleted.
+	deleting bool
+	// deletingReads is how many more reads report DELETING.
+	deletingReads int
+}
+
+// Server is a flintlock MicroVM service holding its microvms in memory.
+type Server struct {
+	flintlockv1.UnimplementedMicroVMServer
+
+	createStates  []flintlocktypes.MicroVMStatus_MicroVMState
+	deletingReads int
+
+	listener net.Listener
+	server   *grpc.Server
+
+	mu     sync.Mutex
+	vms    map[string]*microvm
+	next   int
+	errors map[Method]error
+	calls  map[Method]int
+}
+
+// Start serves a new Server on a free port of the loopback interface. It is
+// stopped with Stop.
+func Start(opts ...Option) (*Server, error) {
+	s := &Server{
+		createStates: []flintlocktypes.MicroVMStatus_MicroVMState{
+			flintlocktypes.MicroVMStatus_PENDING,
+			flintlocktypes.MicroVMStatus_CREATED,
+		},
+		deletingReads: 1,
+		vms:           map[string]*microvm{},
+		errors:        map[Method]error{},
+		calls:         map[Method]int{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if len(s.createStates) == 0 {
+		return nil, fmt.Errorf("at least one create state is required")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("listening: %w", err)
+	}
+
+	s.listener = listener
+	s.server = grpc.NewServer()
+	flintlockv1.RegisterMicroVMServer(s.server, s)
+
+	go func() { _ = s.server.Serve(listener) }()
+
+	return s, nil
+}
+
+// Address returns the address the server is listening on, for use as a host
+// endpoint.
+func (s *Server) Address() string {
+	return s.listener.Addr().String()
+}
+
+// Stop stops serving, closing any open connections.
+func (s *Server) Stop() {
+	s.server.Stop()
+}
+
+// SetError makes every call of method fail with err until it is cleared with
+// a nil err. A status error is returned as it is, any other error as Internal.
+func (s *Server) SetError(method Method, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		delete(s.errors, method)
+
+		return
+	}
+
+	s.errors[method] = err
+}
+
+// SetState moves a microvm straight to state, which it keeps from then on.
+func (s *Server) SetState(uid string, state flintlocktypes.MicroVMStatus_MicroVMState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mvm, ok := s.vms[uid]
+	if !ok {
+		return fmt.Errorf("microvm %s not found", uid)
+	}
+
+	mvm.vm.Status.State = state
+	mvm.step = len(s.createStates)
+
+	return nil
+}
+
+// MicroVMs returns a copy of every microvm the server holds, including those
+// being deleted, ordered by UID.
+func (s *Server) MicroVMs() []*flintlocktypes.MicroVM {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vms := make([]*flintlocktypes.MicroVM, 0, len(s.vms))
+	for _, mvm := range s.vms {
+		vms = append(vms, clone(mvm.vm))
+	}
+
+	sort.Slice(vms, func(i, j int) bool {
+		return vms[i].Spec.GetUid() < vms[j].Spec.GetUid()
+	})
+
+	return vms
+}
+
+// Calls returns how many times method has been called.
+func (s *Server) Calls(method Method) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[method]
+}
+
+// call records a call of method, and returns the error injected into it. It
+// must be called with the lock held.
+func (s *Server) call(method Method) error {
+	s.calls[method]++
+
+	err, ok := s.errors[method]
+	if !ok {
+		return nil
+	}
+
+	if _, isStatus := status.FromError(err); isStatus {
+		return err
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *Server) CreateMicroVM(
+	_ context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(CreateMicroVM); err != nil {
+		return nil, err
+	}
+
+	if in.GetMicrovm() == nil {
+		return nil, status.Error(codes.InvalidArgument, "microvm spec is required")
+	}
+
+	s.next++
+	uid := fmt.Sprintf("fakeflintlock-%d", s.next)
+
+	spec := proto.Clone(in.Microvm).(*flintlocktypes.MicroVMSpec)
+	spec.Uid = &uid
+
+	mvm := &microvm{
+		vm: &flintlocktypes.MicroVM{
+			Spec:   spec,
+			Status: &flintlocktypes.MicroVMStatus{State: s.createStates[0]},
+		},
+		step: 1,
+	}
+	s.vms[uid] = mvm
+
+	return &flintlockv1.CreateMicroVMResponse{Microvm: clone(mvm.vm)}, nil
+}
+
+func (s *Server) DeleteMicroVM(
+	_ context.Context,
+	in *flintlockv1.DeleteMicroVMRequest,
+) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(DeleteMicroVM); err != nil {
+		return nil, err
+	}
+
+	mvm, ok := s.vms[in.Uid]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "microvm %s not found", in.Uid)
+	}
+
+	if !mvm.deleting {
+		mvm.deleting = true
+		mvm.deletingReads = s.deletingReads
+		mvm.vm.Status.State = flintlocktypes.MicroVMStatus_DELETING
+	}
+
+	if mvm.deletingReads <= 0 {
+		delete(s.vms, in.Uid)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) GetMicroVM(
+	_ context.Context,
+	in *flintlockv1.GetMicroVMRequest,
+) (*flintlockv1.GetMicroVMResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(GetMicroVM); err != nil {
+		return nil, err
+	}
+
+	mvm, ok := s.vms[in.Uid]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "microvm %s not found", in.Uid)
+	}
+
+	s.advance(in.Uid, mvm)
+
+	return &flintlockv1.GetMicroVMResponse{Microvm: clone(mvm.vm)}, nil
+}
+
+func (s *Server) ListMicroVMs(
+	_ context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+) (*flintlockv1.ListMicroVMsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(ListMicroVMs); err != nil {
+		return nil, err
+	}
+
+	resp := &flintlockv1.ListMicroVMsResponse{}
+
+	for _, mvm := range s.vms {
+		if in.Namespace != "" && mvm.vm.Spec.Namespace != in.Namespace {
+			continue
+		}
+
+		if in.GetName() != "" && mvm.vm.Spec.Id != in.GetName() {
+			continue
+		}
+
+		resp.Microvm = append(resp.Microvm, clone(mvm.vm))
+	}
+
+	sort.Slice(resp.Microvm, func(i, j int) bool {
+		return resp.Microvm[i].Spec.GetUid() < resp.Microvm[j].Spec.GetUid()
+	})
+
+	return resp, nil
+}
+
+// advance moves a microvm which has just been read on to its next state, and
+// removes one which has finished being deleted. It must be called with the
+// lock held.
+func (s *Server) advance(uid string, mvm *microvm) {
+	if mvm.deleting {
+		mvm.deletingReads--
+		if mvm.deletingReads <= 0 {
+			delete(s.vms, uid)
+		}
+
+		return
+	}
+
+	if mvm.step < len(s.createStates) {
+		mvm.vm.Status.State = s.createStates[mvm.step]
+		mvm.step++
+	}
+}
+
+func clone(vm *flintlocktypes.MicroVM) *flintlocktypes.MicroVM {
+	return proto.Clone(vm).(*flintlocktypes.MicroVM)
+}
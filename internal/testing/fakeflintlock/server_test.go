@@ -0,0 +1,144 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package fakeflintlock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	flintlocktypes "github.com/weaveworks-liquidmetal/flintlock/api/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/testing/fakeflintlock"
+)
+
+func start(g *WithT, opts ...fakeflintlock.Option) (*fakeflintlock.Server, flclient.Client) {
+	server, err := fakeflintlock.Start(opts...)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	client, err := flclient.NewFlintlockClient(server.Address())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return server, client
+}
+
+func TestServer_Lifecycle(t *testing.T) {
+	g := NewWithT(t)
+
+	server, client := start(g)
+	defer server.Stop()
+	defer client.Close()
+
+	ctx := context.TODO()
+
+	created, err := client.CreateMicroVM(ctx, &flintlockv1.CreateMicroVMRequest{
+		Microvm: &flintlocktypes.MicroVMSpec{Id: "mvm1", Namespace: "ns1"},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created.Microvm.Status.State).To(Equal(flintlocktypes.MicroVMStatus_PENDING))
+
+	uid := created.Microvm.Spec.GetUid()
+	g.Expect(uid).NotTo(BeEmpty(), "Expected the microvm to have been given a UID")
+
+	got, err := client.GetMicroVM(ctx, &flintlockv1.GetMicroVMRequest{Uid: uid})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(got.Microvm.Status.State).To(Equal(flintlocktypes.MicroVMStatus_CREATED))
+
+	listed, err := client.ListMicroVMs(ctx, &flintlockv1.ListMicroVMsRequest{Namespace: "ns1"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(listed.Microvm).To(HaveLen(1))
+
+	listed, err = client.ListMicroVMs(ctx, &flintlockv1.ListMicroVMsRequest{Namespace: "ns2"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(listed.Microvm).To(BeEmpty())
+
+	_, err = client.DeleteMicroVM(ctx, &flintlockv1.DeleteMicroVMRequest{Uid: uid})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	got, err = client.GetMicroVM(ctx, &flintlockv1.GetMicroVMRequest{Uid: uid})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(got.Microvm.Status.State).To(Equal(flintlocktypes.MicroVMStatus_DELETING))
+
+	_, err = client.GetMicroVM(ctx, &flintlockv1.GetMicroVMRequest{Uid: uid})
+	g.Expect(status.Code(err)).To(Equal(codes.NotFound))
+	g.Expect(err.Error()).To(ContainSubstring("not found"), "Expected the error the operator looks for")
+
+	g.Expect(server.MicroVMs()).To(BeEmpty())
+	g.Expect(server.Calls(fakeflintlock.GetMicroVM)).To(Equal(3))
+}
+
+func TestServer_ProgrammedStates(t *testing.T) {
+	g := NewWithT(t)
+
+	server, client := start(g,
+		fakeflintlock.WithCreateStates(
+			flintlocktypes.MicroVMStatus_PENDING,
+			flintlocktypes.MicroVMStatus_PENDING,
+			flintlocktypes.MicroVMStatus_FAILED,
+		),
+		fakeflintlock.WithDeletingReads(0),
+	)
+	defer server.Stop()
+	defer client.Close()
+
+	ctx := context.TODO()
+
+	created, err := client.CreateMicroVM(ctx, &flintlockv1.CreateMicroVMRequest{Microvm: &flintlocktypes.MicroVMSpec{Id: "mvm1"}})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	uid := created.Microvm.Spec.GetUid()
+
+	for _, expected := range []flintlocktypes.MicroVMStatus_MicroVMState{
+		flintlocktypes.MicroVMStatus_PENDING,
+		flintlocktypes.MicroVMStatus_FAILED,
+		flintlocktypes.MicroVMStatus_FAILED,
+	} {
+		got, err := client.GetMicroVM(ctx, &flintlockv1.GetMicroVMRequest{Uid: uid})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(got.Microvm.Status.State).To(Equal(expected))
+	}
+
+	g.Expect(server.SetState(uid, flintlocktypes.MicroVMStatus_CREATED)).To(Succeed())
+
+	got, err := client.GetMicroVM(ctx, &flintlockv1.GetMicroVMRequest{Uid: uid})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(got.Microvm.Status.State).To(Equal(flintlocktypes.MicroVMStatus_CREATED))
+
+	// without any deleting reads the microvm is gone straight away
+	_, err = client.DeleteMicroVM(ctx, &flintlockv1.DeleteMicroVMRequest{Uid: uid})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(server.MicroVMs()).To(BeEmpty())
+}
+
+func TestServer_SetError(t *testing.T) {
+	g := NewWithT(t)
+
+	server, client := start(g)
+	defer server.Stop()
+	defer client.Close()
+
+	ctx := context.TODO()
+
+	server.SetError(fakeflintlock.CreateMicroVM, status.Error(codes.ResourceExhausted, "host is full"))
+
+	_, err := client.CreateMicroVM(ctx, &flintlockv1.CreateMicroVMRequest{Microvm: &flintlocktypes.MicroVMSpec{Id: "mvm1"}})
+	g.Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+
+	server.SetError(fakeflintlock.CreateMicroVM, errors.New("disk failure"))
+
+	_, err = client.CreateMicroVM(ctx, &flintlockv1.CreateMicroVMRequest{Microvm: &flintlocktypes.MicroVMSpec{Id: "mvm1"}})
+	g.Expect(status.Code(err)).To(Equal(codes.Internal))
+
+	server.SetError(fakeflintlock.CreateMicroVM, nil)
+
+	_, err = client.CreateMicroVM(ctx, &flintlockv1.CreateMicroVMRequest{Microvm: &flintlocktypes.MicroVMSpec{Id: "mvm1"}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(server.MicroVMs()).To(HaveLen(1))
+	g.Expect(server.Calls(fakeflintlock.CreateMicroVM)).To(Equal(3))
+}
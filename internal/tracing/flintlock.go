@@ -0,0 +1,119 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package tracing
+
+import (
+	"context"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// FactoryFunc wraps factory so that each call made by the clients it returns
+// is a span, a child of the reconcile which made it.
+func FactoryFunc(factory flclient.FactoryFunc) flclient.FactoryFunc {
+	return func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		client, err := factory(address, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tracedClient{Client: client, address: address}, nil
+	}
+}
+
+// tracedClient is a flintlock client which records a span for each call.
+type tracedClient struct {
+	flclient.Client
+
+	address string
+}
+
+// start begins the span of a call to method, with the uid of the microvm if
+// the request has one.
+func (c *tracedClient) start(ctx context.Context, method, uid string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{HostKey.String(c.address)}
+	if uid != "" {
+		attrs = append(attrs, UIDKey.String(uid))
+	}
+
+	return tracer().Start(ctx, "flintlock."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func (c *tracedClient) CreateMicroVM(
+	ctx context.Context,
+	in *flintlockv1.CreateMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.CreateMicroVMResponse, error) {
+	ctx, span := c.start(ctx, "CreateMicroVM", "")
+
+	resp, err := c.Client.CreateMicroVM(ctx, in, opts...)
+	if uid := resp.GetMicrovm().GetSpec().GetUid(); uid != "" {
+		span.SetAttributes(UIDKey.String(uid))
+	}
+
+	end(span, err)
+
+	return resp, err
+}
+
+func (c *tracedClient) DeleteMicroVM(
+	ctx context.Context,
+	in *flintlockv1.DeleteMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*emptypb.Empty, error) {
+	ctx, span := c.start(ctx, "DeleteMicroVM", in.GetUid())
+
+	resp, err := c.Client.DeleteMicroVM(ctx, in, opts...)
+	end(span, err)
+
+	return resp, err
+}
+
+func (c *tracedClient) GetMicroVM(
+	ctx context.Context,
+	in *flintlockv1.GetMicroVMRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.GetMicroVMResponse, error) {
+	ctx, span := c.start(ctx, "GetMicroVM", in.GetUid())
+
+	resp, err := c.Client.GetMicroVM(ctx, in, opts...)
+	end(span, err)
+
+	return resp, err
+}
+
+func (c *tracedClient) ListMicroVMs(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (*flintlockv1.ListMicroVMsResponse, error) {
+	ctx, span := c.start(ctx, "ListMicroVMs", "")
+
+	resp, err := c.Client.ListMicroVMs(ctx, in, opts...)
+	end(span, err)
+
+	return resp, err
+}
+
+// ListMicroVMsStream only spans opening the stream, not reading from it.
+func (c *tracedClient) ListMicroVMsStream(
+	ctx context.Context,
+	in *flintlockv1.ListMicroVMsRequest,
+	opts ...grpc.CallOption,
+) (flintlockv1.MicroVM_ListMicroVMsStreamClient, error) {
+	ctx, span := c.start(ctx, "ListMicroVMsStream", "")
+
+	stream, err := c.Client.ListMicroVMsStream(ctx, in, opts...)
+	end(span, err)
+
+	return stream, err
+}
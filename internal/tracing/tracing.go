@@ -0,0 +1,118 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package tracing exports OpenTelemetry spans for each reconcile and each call
+// made to a flintlock host, so that the time taken to provision a microvm can
+// be followed from the reconcile which asked for it down to the host.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	serviceName = "microvm-operator"
+	tracerName  = "github.com/weaveworks-liquidmetal/microvm-operator"
+)
+
+// The attributes the spans carry.
+const (
+	// ControllerKey is the name of the controller which reconciled.
+	ControllerKey = attribute.Key("controller")
+	// NameKey is the name of the object being reconciled.
+	NameKey = attribute.Key("k8s.object.name")
+	// NamespaceKey is the namespace of the object being reconciled.
+	NamespaceKey = semconv.K8SNamespaceNameKey
+	// HostKey is the endpoint of the flintlock host called.
+	HostKey = attribute.Key("flintlock.host")
+	// UIDKey is the id flintlock gave the microvm called about.
+	UIDKey = attribute.Key("flintlock.uid")
+	// RequeueKey is true when the reconcile asked to be requeued.
+	RequeueKey = attribute.Key("reconcile.requeue")
+)
+
+// Options configures where the spans are exported to.
+type Options struct {
+	// Endpoint is the address of the OTLP gRPC collector. No spans are
+	// exported when it is empty.
+	Endpoint string
+	// Insecure connects to the collector without TLS.
+	Insecure bool
+	// SamplingRatio is the fraction of traces which are kept, from 0 to 1.
+	SamplingRatio float64
+}
+
+// Setup exports spans to the collector in opts, returning a function which
+// flushes and stops the exporter. It does nothing when no endpoint is set, so
+// that the spans are dropped without cost.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating otlp exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SamplingRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// tracer returns the tracer of the operator from the provider set up, or one
+// which drops the spans if there is none.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Reconciler wraps r so that each reconcile is a span, named after the
+// controller and tied to the key of the object reconciled. The span is passed
+// down in the context, so that the calls made to flintlock are its children.
+func Reconciler(controller string, r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		ctx, span := tracer().Start(ctx, controller+".Reconcile", trace.WithAttributes(
+			ControllerKey.String(controller),
+			NamespaceKey.String(req.Namespace),
+			NameKey.String(req.Name),
+		))
+
+		result, err := r.Reconcile(ctx, req)
+		span.SetAttributes(RequeueKey.Bool(!result.IsZero()))
+		end(span, err)
+
+		return result, err
+	})
+}
+
+// end records err on span, if there was one, and ends it.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
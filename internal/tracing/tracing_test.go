@@ -0,0 +1,93 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	flintlockv1 "github.com/weaveworks-liquidmetal/flintlock/api/services/microvm/v1alpha1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers/fakes"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+)
+
+// attributes returns the attributes of span as a map.
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+
+	return attrs
+}
+
+func TestReconciler(t *testing.T) {
+	g := NewWithT(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	fakeAPIClient := &fakes.FakeClient{}
+	fakeAPIClient.GetMicroVMReturns(nil, errors.New("host unreachable"))
+
+	factory := tracing.FactoryFunc(func(address string, opts ...flclient.Options) (flclient.Client, error) {
+		return fakeAPIClient, nil
+	})
+
+	r := tracing.Reconciler("microvm", reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		client, err := factory("127.0.0.1:9090")
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		_, err = client.GetMicroVM(ctx, &flintlockv1.GetMicroVMRequest{Uid: "abcdef"})
+
+		return ctrl.Result{Requeue: true}, err
+	}))
+
+	_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns1", Name: "mvm1"}})
+	g.Expect(err).To(HaveOccurred())
+
+	spans := recorder.Ended()
+	g.Expect(spans).To(HaveLen(2))
+
+	call, reconciled := spans[0], spans[1]
+
+	g.Expect(reconciled.Name()).To(Equal("microvm.Reconcile"))
+	g.Expect(reconciled.Status().Code).To(Equal(codes.Error))
+	g.Expect(attributes(reconciled)).To(SatisfyAll(
+		HaveKeyWithValue(tracing.ControllerKey, attribute.StringValue("microvm")),
+		HaveKeyWithValue(tracing.NamespaceKey, attribute.StringValue("ns1")),
+		HaveKeyWithValue(tracing.NameKey, attribute.StringValue("mvm1")),
+		HaveKeyWithValue(tracing.RequeueKey, attribute.BoolValue(true)),
+	))
+
+	g.Expect(call.Name()).To(Equal("flintlock.GetMicroVM"))
+	g.Expect(call.Parent().SpanID()).To(Equal(reconciled.SpanContext().SpanID()), "Expected the call to be a child of the reconcile")
+	g.Expect(call.Status().Code).To(Equal(codes.Error))
+	g.Expect(attributes(call)).To(SatisfyAll(
+		HaveKeyWithValue(tracing.HostKey, attribute.StringValue("127.0.0.1:9090")),
+		HaveKeyWithValue(tracing.UIDKey, attribute.StringValue("abcdef")),
+	))
+}
+
+func TestSetup_NoEndpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	shutdown, err := tracing.Setup(context.TODO(), tracing.Options{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(shutdown(context.TODO())).To(Succeed())
+}
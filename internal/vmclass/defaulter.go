@@ -0,0 +1,130 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package vmclass gives Microvms, and the templates of replicasets and
+// deployments, the defaults of the MicrovmClass they name, so that platform
+// teams can curate the flavours of VM which are offered.
+package vmclass
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// MicrovmPath is the path the Defaulter is served on for Microvms. The
+// Microvm path derived from its kind is already taken by the credentials
+// webhook.
+const MicrovmPath = "/mutate-infrastructure-liquid-metal-io-v1alpha1-microvm-class"
+
+//+kubebuilder:webhook:path=/mutate-infrastructure-liquid-metal-io-v1alpha1-microvm-class,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.liquid-metal.io,resources=microvms,verbs=create;update,versions=v1alpha1,name=mmicrovmclass.infrastructure.liquid-metal.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/mutate-infrastructure-liquid-metal-io-v1alpha1-microvmreplicaset,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.liquid-metal.io,resources=microvmreplicasets,verbs=create;update,versions=v1alpha1,name=mmicrovmreplicasetclass.infrastructure.liquid-metal.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/mutate-infrastructure-liquid-metal-io-v1alpha1-microvmdeployment,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.liquid-metal.io,resources=microvmdeployments,verbs=create;update,versions=v1alpha1,name=mmicrovmdeploymentclass.infrastructure.liquid-metal.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups=infrastructure.liquid-metal.io,resources=microvmclasses,verbs=get;list;watch
+
+// Defaulter fills in the spec of Microvms, and of the templates of
+// MicrovmReplicaSets and MicrovmDeployments, from the MicrovmClass named by
+// their classRef. A Microvm which names a class which does not exist is
+// refused.
+type Defaulter struct {
+	Client client.Client
+}
+
+var _ admission.CustomDefaulter = &Defaulter{}
+
+// SetupWebhookWithManager registers the defaulter as a webhook for Microvms,
+// MicrovmReplicaSets and MicrovmDeployments.
+func (d *Defaulter) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(MicrovmPath, admission.WithCustomDefaulter(&infrav1.Microvm{}, d))
+
+	if err := ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.MicrovmReplicaSet{}).
+		WithDefaulter(d).
+		Complete(); err != nil {
+		return err
+	}
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.MicrovmDeployment{}).
+		WithDefaulter(d).
+		Complete()
+}
+
+// Default fills in the Microvm spec of obj from its class.
+func (d *Defaulter) Default(ctx context.Context, obj runtime.Object) error {
+	var (
+		spec *infrav1.MicrovmSpec
+		path *field.Path
+	)
+
+	switch o := obj.(type) {
+	case *infrav1.Microvm:
+		spec, path = &o.Spec, field.NewPath("spec")
+	case *infrav1.MicrovmReplicaSet:
+		spec, path = &o.Spec.Template.Spec, field.NewPath("spec", "template", "spec")
+	case *infrav1.MicrovmDeployment:
+		spec, path = &o.Spec.Template.Spec, field.NewPath("spec", "template", "spec")
+	default:
+		return fmt.Errorf("expected a microvm, replicaset or deployment but got %T", obj)
+	}
+
+	if spec.ClassRef == nil {
+		return nil
+	}
+
+	class := &infrav1.MicrovmClass{}
+	if err := d.Client.Get(ctx, client.ObjectKey{Name: spec.ClassRef.Name}, class); err != nil {
+		if apierrors.IsNotFound(err) {
+			return apierrors.NewBadRequest(
+				field.NotFound(path.Child("classRef", "name"), spec.ClassRef.Name).Error())
+		}
+
+		return fmt.Errorf("getting microvmclass %s: %w", spec.ClassRef.Name, err)
+	}
+
+	Apply(spec, class.Spec)
+
+	return nil
+}
+
+// Apply sets each field of spec which is not set from class.
+func Apply(spec *infrav1.MicrovmSpec, class infrav1.MicrovmClassSpec) {
+	if spec.VCPU == 0 && class.VCPU != nil {
+		spec.VCPU = *class.VCPU
+	}
+
+	if spec.MemoryMb == 0 && class.MemoryMb != nil {
+		spec.MemoryMb = *class.MemoryMb
+	}
+
+	if spec.Kernel.Image == "" && class.Kernel != nil {
+		spec.Kernel = *class.Kernel
+	}
+
+	for arg, value := range class.KernelCmdLine {
+		if spec.KernelCmdLine == nil {
+			spec.KernelCmdLine = map[string]string{}
+		}
+
+		if _, ok := spec.KernelCmdLine[arg]; !ok {
+			spec.KernelCmdLine[arg] = value
+		}
+	}
+
+	if spec.Initrd == nil && class.Initrd != nil {
+		initrd := *class.Initrd
+		spec.Initrd = &initrd
+	}
+
+	if spec.RootVolume.Image == "" && class.RootVolume != nil {
+		spec.RootVolume = *class.RootVolume
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+package vmclass_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	microvm "github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/vmclass"
+)
+
+func newDefaulter(g *WithT, objects ...runtime.Object) *vmclass.Defaulter {
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	return &vmclass.Defaulter{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build(),
+	}
+}
+
+func newClass() *infrav1.MicrovmClass {
+	return &infrav1.MicrovmClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "small"},
+		Spec: infrav1.MicrovmClassSpec{
+			VCPU:          pointer.Int64(2),
+			MemoryMb:      pointer.Int64(2048),
+			Kernel:        &microvm.ContainerFileSource{Image: "kernel:1", Filename: "vmlinux"},
+			KernelCmdLine: map[string]string{"console": "ttyS0", "quiet": ""},
+			Initrd:        &microvm.ContainerFileSource{Image: "initrd:1"},
+			RootVolume:    &microvm.Volume{ID: "root", Image: "root:1"},
+		},
+	}
+}
+
+func TestApply(t *testing.T) {
+	g := NewWithT(t)
+
+	spec := &infrav1.MicrovmSpec{
+		VMSpec: microvm.VMSpec{
+			VCPU:          4,
+			KernelCmdLine: map[string]string{"console": "ttyS1"},
+		},
+	}
+
+	vmclass.Apply(spec, newClass().Spec)
+
+	g.Expect(spec.VCPU).To(Equal(int64(4)), "Expected the microvm to keep its own vcpu")
+	g.Expect(spec.MemoryMb).To(Equal(int64(2048)))
+	g.Expect(spec.Kernel).To(Equal(microvm.ContainerFileSource{Image: "kernel:1", Filename: "vmlinux"}))
+	g.Expect(spec.KernelCmdLine).To(Equal(map[string]string{"console": "ttyS1", "quiet": ""}),
+		"Expected the class arguments to be added to the microvm's own")
+	g.Expect(spec.Initrd).To(Equal(&microvm.ContainerFileSource{Image: "initrd:1"}))
+	g.Expect(spec.RootVolume).To(Equal(microvm.Volume{ID: "root", Image: "root:1"}))
+}
+
+func TestDefaulter_Default(t *testing.T) {
+	g := NewWithT(t)
+
+	defaulter := newDefaulter(g, newClass())
+	classRef := &corev1.LocalObjectReference{Name: "small"}
+
+	mvm := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1"}}
+	g.Expect(defaulter.Default(context.TODO(), mvm)).To(Succeed(), "Expected a microvm without a class to be left")
+	g.Expect(mvm.Spec.Kernel.Image).To(BeEmpty())
+
+	mvm.Spec.ClassRef = classRef
+	g.Expect(defaulter.Default(context.TODO(), mvm)).To(Succeed())
+	g.Expect(mvm.Spec.Kernel.Image).To(Equal("kernel:1"))
+
+	mvmRS := &infrav1.MicrovmReplicaSet{}
+	mvmRS.Spec.Template.Spec.ClassRef = classRef
+	g.Expect(defaulter.Default(context.TODO(), mvmRS)).To(Succeed())
+	g.Expect(mvmRS.Spec.Template.Spec.RootVolume.Image).To(Equal("root:1"))
+
+	mvmD := &infrav1.MicrovmDeployment{}
+	mvmD.Spec.Template.Spec.ClassRef = classRef
+	g.Expect(defaulter.Default(context.TODO(), mvmD)).To(Succeed())
+	g.Expect(mvmD.Spec.Template.Spec.VCPU).To(Equal(int64(2)))
+}
+
+func TestDefaulter_Default_MissingClass(t *testing.T) {
+	g := NewWithT(t)
+
+	defaulter := newDefaulter(g)
+
+	mvm := &infrav1.Microvm{ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: "ns1"}}
+	mvm.Spec.ClassRef = &corev1.LocalObjectReference{Name: "missing"}
+
+	err := defaulter.Default(context.TODO(), mvm)
+	g.Expect(apierrors.IsBadRequest(err)).To(BeTrue(), "Expected a microvm naming a missing class to be refused")
+	g.Expect(err.Error()).To(ContainSubstring("spec.classRef.name"))
+}
@@ -17,24 +17,60 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	cliflag "k8s.io/component-base/cli/flag"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"github.com/weaveworks-liquidmetal/controller-pkg/client"
 
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
 	infrastructurev1alpha1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	infrastructurev1alpha2 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha2"
 	"github.com/weaveworks-liquidmetal/microvm-operator/controllers"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/audit"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/autoscaler"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/callmeta"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/cloudinit"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/crdcheck"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/credentials"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/drain"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/external"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/featuregates"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/guestagent"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/health"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/heartbeat"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/identity"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/kernelargs"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/logging"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/oci"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/phonehome"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/probe"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/quota"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/ratelimit"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/readiness"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/retry"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/shutdown"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/tracing"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/vmclass"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -45,8 +81,11 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+	utilruntime.Must(expv1.AddToScheme(scheme))
 
 	utilruntime.Must(infrastructurev1alpha1.AddToScheme(scheme))
+	utilruntime.Must(infrastructurev1alpha2.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -54,11 +93,110 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var crdCheck string
+	var configFile string
+	var featureGates map[string]bool
+	var pendingDeleteGrace time.Duration
+	var stuckDeleteTimeout time.Duration
+	var forceDeleteStuck bool
+	var leaseDuration time.Duration
+	var renewDeadline time.Duration
+	var retryPeriod time.Duration
+	var gracefulShutdownTimeout time.Duration
+	var canaryHost string
+	var microvmConcurrency int
+	var replicaSetConcurrency int
+	var deploymentConcurrency int
+	var flintlockQPS float64
+	var flintlockBurst int
+	var flintlockDeleteQPS float64
+	var maxConcurrentDeletes int
+	var flintlockCallTimeout time.Duration
+	var flintlockRetryAttempts int
+	var hostContactTimeout time.Duration
+	var traceFlintlock bool
+	var otlpEndpoint string
+	var otlpInsecure bool
+	var traceSamplingRatio float64
+	var watchNamespaces []string
+	var phoneHomeAddr string
+	var phoneHomeURL string
+	var phoneHomeCertDir string
+	var dryRun bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&leaseDuration, "leader-elect-lease-duration", 15*time.Second,
+		"How long a replica which is not the leader waits before trying to take over an unrenewed lease.")
+	flag.DurationVar(&renewDeadline, "leader-elect-renew-deadline", 10*time.Second,
+		"How long the leader keeps retrying to renew its lease before giving up leadership.")
+	flag.DurationVar(&retryPeriod, "leader-elect-retry-period", 2*time.Second,
+		"How long replicas wait between attempts to acquire or renew the lease.")
+	flag.DurationVar(&gracefulShutdownTimeout, "graceful-shutdown-timeout", 30*time.Second,
+		"How long in-flight reconciles are given to finish on SIGTERM before the manager exits.")
+	flag.StringVar(&canaryHost, "readiness-canary-host", "",
+		"Address of a flintlock host which must be reachable for the manager to report ready. "+
+			"Leave empty to only check that the caches have synced.")
+	flag.IntVar(&microvmConcurrency, "microvm-concurrency", 10,
+		"How many Microvms are reconciled at once.")
+	flag.IntVar(&replicaSetConcurrency, "microvmreplicaset-concurrency", 5,
+		"How many MicrovmReplicaSets are reconciled at once.")
+	flag.IntVar(&deploymentConcurrency, "microvmdeployment-concurrency", 5,
+		"How many MicrovmDeployments are reconciled at once.")
+	flag.Float64Var(&flintlockQPS, "flintlock-qps", 20,
+		"How many calls a second are made to each flintlock host. Set to 0 to disable the limit.")
+	flag.IntVar(&flintlockBurst, "flintlock-burst", 50,
+		"How many calls can be made to each flintlock host in a burst above --flintlock-qps.")
+	flag.Float64Var(&flintlockDeleteQPS, "flintlock-delete-qps", 0,
+		"How many VMs a second are deleted from each flintlock host, within --flintlock-qps. Set to 0 to disable the limit.")
+	flag.IntVar(&maxConcurrentDeletes, "max-concurrent-deletes", 10,
+		"How many Microvms a MicrovmReplicaSet being deleted removes from each host at once. Set to 0 to delete them all at once.")
+	flag.DurationVar(&flintlockCallTimeout, "flintlock-call-timeout", 30*time.Second,
+		"How long each attempt at a call to a flintlock host may take, including connecting to it. Set to 0 to disable the timeout.")
+	flag.IntVar(&flintlockRetryAttempts, "flintlock-retry-attempts", 3,
+		"How many times a call which reads from a flintlock host is made when the host cannot be reached. Set to 1 to disable retries.")
+	flag.DurationVar(&hostContactTimeout, "host-contact-timeout", 5*time.Minute,
+		"How long calls to a flintlock host may fail to reach it before no new replicas are placed on it. "+
+			"Set to 0 to keep placing replicas on hosts however long they cannot be reached.")
+	flag.BoolVar(&traceFlintlock, "trace-flintlock", false,
+		"Log every call made to a flintlock host with its request and response. Very verbose, meant for debugging.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"Address of an OTLP gRPC collector to export a span for each reconcile and flintlock call to. "+
+			"Leave empty to not export any spans.")
+	flag.BoolVar(&otlpInsecure, "otlp-insecure", false,
+		"Connect to the --otlp-endpoint collector without TLS.")
+	flag.Float64Var(&traceSamplingRatio, "trace-sampling-ratio", 1,
+		"The fraction of reconciles which are traced, from 0 to 1.")
+	flag.Var(cliflag.NewStringSlice(&watchNamespaces), "watch-namespaces",
+		"Comma separated namespaces the operator watches, so that each tenant can run its own operator. "+
+			"Every namespace is watched when empty.")
+	flag.StringVar(&phoneHomeAddr, "phone-home-bind-address", "",
+		"The address the server guests call back to once cloud-init has finished binds to. "+
+			"Leave empty to not start it.")
+	flag.StringVar(&phoneHomeURL, "phone-home-url", "",
+		"The base URL guests reach the phone-home server at. Required with --phone-home-bind-address.")
+	flag.StringVar(&phoneHomeCertDir, "phone-home-cert-dir", "/tmp/k8s-webhook-server/serving-certs",
+		"The directory holding the tls.crt and tls.key the phone-home server presents.")
+	flag.DurationVar(&pendingDeleteGrace, "pending-delete-grace", 2*time.Minute,
+		"How long a Microvm deleted while still being created is given for the create to settle before it is deleted.")
+	flag.DurationVar(&stuckDeleteTimeout, "stuck-delete-timeout", 10*time.Minute,
+		"How long a microvm may be deleting on its host before the Microvm is marked stuck.")
+	flag.BoolVar(&forceDeleteStuck, "force-delete-stuck", false,
+		"Ask hosts to delete microvms which are stuck deleting again on every reconcile.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Record the flintlock calls and changes to child objects reconciles would make in the status "+
+			"of Microvms, MicrovmReplicaSets and MicrovmDeployments instead of making them.")
+	flag.StringVar(&configFile, "config", "",
+		"Path to an OperatorConfiguration file. Settings in the file override the equivalent flags, "+
+			"and requeue periods, minimum reconcile intervals, the default TLS secret, --max-concurrent-deletes, --trace-flintlock and --host-contact-timeout are reloaded when it changes.")
+	flag.Var(cliflag.NewMapStringBool(&featureGates), "feature-gates",
+		"A set of key=value pairs that describe feature gates for experimental features. "+
+			"Options are:\n"+strings.Join(featuregates.MutableGates.KnownFeatures(), "\n"))
+	flag.StringVar(&crdCheck, "crd-check", string(crdcheck.Enforce),
+		"What to do when the installed CRDs do not match this binary: enforce refuses to start, "+
+			"warn logs the differences and starts anyway, disabled skips the check.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -67,52 +205,351 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	flagConfig := &configv1.OperatorConfiguration{
+		Metrics: configv1.MetricsConfiguration{BindAddress: metricsAddr},
+		Health:  configv1.HealthConfiguration{BindAddress: probeAddr, CanaryHost: canaryHost},
+		LeaderElection: configv1.LeaderElectionConfiguration{
+			Enabled:       &enableLeaderElection,
+			LeaseDuration: metav1.Duration{Duration: leaseDuration},
+			RenewDeadline: metav1.Duration{Duration: renewDeadline},
+			RetryPeriod:   metav1.Duration{Duration: retryPeriod},
+		},
+		GracefulShutdownTimeout: metav1.Duration{Duration: gracefulShutdownTimeout},
+		WatchNamespaces:         watchNamespaces,
+		Controllers: configv1.ControllersConfiguration{
+			Microvm:           configv1.ControllerConfiguration{MaxConcurrentReconciles: microvmConcurrency},
+			MicrovmReplicaSet: configv1.ControllerConfiguration{MaxConcurrentReconciles: replicaSetConcurrency},
+			MicrovmDeployment: configv1.ControllerConfiguration{MaxConcurrentReconciles: deploymentConcurrency},
+		},
+		Flintlock: configv1.FlintlockConfiguration{
+			QPS:                  flintlockQPS,
+			Burst:                flintlockBurst,
+			DeleteQPS:            flintlockDeleteQPS,
+			MaxConcurrentDeletes: maxConcurrentDeletes,
+			CallTimeout:          metav1.Duration{Duration: flintlockCallTimeout},
+			Retry:                configv1.RetryConfiguration{MaxAttempts: flintlockRetryAttempts},
+			ContactTimeout:       metav1.Duration{Duration: hostContactTimeout},
+		},
+		Logging: configv1.LoggingConfiguration{TraceFlintlock: traceFlintlock},
+		Tracing: configv1.TracingConfiguration{
+			Endpoint:      otlpEndpoint,
+			Insecure:      otlpInsecure,
+			SamplingRatio: traceSamplingRatio,
+		},
+		PhoneHome: configv1.PhoneHomeConfiguration{
+			BindAddress: phoneHomeAddr,
+			URL:         phoneHomeURL,
+			CertDir:     phoneHomeCertDir,
+		},
+		Deletion: configv1.DeletionConfiguration{
+			PendingGrace: metav1.Duration{Duration: pendingDeleteGrace},
+			StuckTimeout: metav1.Duration{Duration: stuckDeleteTimeout},
+			ForceStuck:   forceDeleteStuck,
+		},
+		CRDCheck:     crdCheck,
+		DryRun:       dryRun,
+		FeatureGates: featureGates,
+	}
+
+	cfg := flagConfig.DeepCopy()
+	if configFile != "" {
+		if err := config.Load(configFile, cfg); err != nil {
+			setupLog.Error(err, "unable to load operator configuration", "path", configFile)
+			os.Exit(1)
+		}
+	}
+
+	if err := featuregates.MutableGates.SetFromMap(cfg.FeatureGates); err != nil {
+		setupLog.Error(err, "unable to set feature gates")
+		os.Exit(1)
+	}
+
+	// guests are only asked to call back when there is a server to take it
+	var phoneHomeBaseURL string
+	if cfg.PhoneHome.BindAddress != "" {
+		if cfg.PhoneHome.URL == "" {
+			setupLog.Error(nil, "a phone home URL is required to serve phone home callbacks")
+			os.Exit(1)
+		}
+
+		phoneHomeBaseURL = cfg.PhoneHome.URL
+	}
+
+	configStore := config.NewStore(cfg)
+
+	if cfg.DryRun {
+		setupLog.Info("running in dry-run mode, no changes will be made to microvms or their children")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Endpoint:      cfg.Tracing.Endpoint,
+		Insecure:      cfg.Tracing.Insecure,
+		SamplingRatio: cfg.Tracing.SamplingRatio,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
+	newCache, err := config.NewCacheFunc(cfg.WatchNamespaces)
+	if err != nil {
+		setupLog.Error(err, "unable to restrict the watched namespaces")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
+		MetricsBindAddress:     cfg.Metrics.BindAddress,
 		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
+		HealthProbeBindAddress: cfg.Health.BindAddress,
+		LeaderElection:         cfg.LeaderElection.Enabled != nil && *cfg.LeaderElection.Enabled,
 		LeaderElectionID:       "controller-leader-elect-microvm",
-		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
-		// when the Manager ends. This requires the binary to immediately end when the
-		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
-		// speeds up voluntary leader transitions as the new leader don't have to wait
-		// LeaseDuration time first.
-		//
-		// In the default scaffold provided, the program ends immediately after
-		// the manager stops, so would be fine to enable this option. However,
-		// if you are doing or is intended to do any operation such as perform cleanups
-		// after the manager stops then its usage might be unsafe.
-		// LeaderElectionReleaseOnCancel: true,
+		LeaseDuration:          &cfg.LeaderElection.LeaseDuration.Duration,
+		RenewDeadline:          &cfg.LeaderElection.RenewDeadline.Duration,
+		RetryPeriod:            &cfg.LeaderElection.RetryPeriod.Duration,
+		// The program exits as soon as the manager stops, so the lease can be
+		// handed straight to another replica instead of waiting for it to expire.
+		LeaderElectionReleaseOnCancel: true,
+		GracefulShutdownTimeout:       &cfg.GracefulShutdownTimeout.Duration,
+		NewCache:                      newCache,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	if err := checkCRDs(mgr, crdcheck.Mode(cfg.CRDCheck)); err != nil {
+		setupLog.Error(err, "refusing to start, upgrade the installed CRDs or set --crd-check=warn")
+		os.Exit(1)
+	}
+
+	healthRecorder := health.NewRecorder()
+	heartbeats := heartbeat.NewRegistry()
+
+	externalResources := external.NewDefaultRegistry(mgr.GetClient())
+
+	// every attempt at a call is traced, and is bounded on its own so that a
+	// retry is not starved by an attempt which hung
+	retryPolicy := retry.Policy{
+		Timeout:        cfg.Flintlock.CallTimeout.Duration,
+		MaxAttempts:    cfg.Flintlock.Retry.MaxAttempts,
+		InitialBackoff: cfg.Flintlock.Retry.InitialBackoff.Duration,
+		MaxBackoff:     cfg.Flintlock.Retry.MaxBackoff.Duration,
+	}
+
+	// the limits are shared by the clients of every host, pinned or not
+	var limiter *ratelimit.Limiter
+	if cfg.Flintlock.QPS > 0 || cfg.Flintlock.DeleteQPS > 0 {
+		limiter = ratelimit.NewLimiter(float32(cfg.Flintlock.QPS), cfg.Flintlock.Burst).
+			LimitDeletes(float32(cfg.Flintlock.DeleteQPS))
+	}
+
+	wrapClientFunc := func(mvmClientFunc client.FactoryFunc) client.FactoryFunc {
+		// calls are traced before they are rate limited, so that the time spent
+		// waiting for a token is not counted against the host
+		mvmClientFunc = logging.TraceFactoryFunc(mvmClientFunc, configStore.TraceFlintlock)
+
+		// every call says who made it, so that hosts can audit their VMs
+		mvmClientFunc = callmeta.FactoryFunc(mvmClientFunc, configStore.FlintlockMetadata)
+
+		// each attempt at a call which changes a host is recorded, retries included
+		if featuregates.Gates.Enabled(featuregates.AuditLog) {
+			mvmClientFunc = audit.FactoryFunc(mvmClientFunc, mgr.GetClient())
+		}
+
+		if !retryPolicy.IsZero() {
+			mvmClientFunc = retry.FactoryFunc(mvmClientFunc, retryPolicy)
+		}
+
+		// a host is only out of contact once a call has failed to reach it after
+		// every retry
+		mvmClientFunc = heartbeat.FactoryFunc(mvmClientFunc, heartbeats)
+
+		if limiter != nil {
+			mvmClientFunc = limiter.FactoryFunc(mvmClientFunc)
+		}
+
+		// the spans of flintlock calls include any wait for the rate limit, which
+		// is part of how long provisioning takes
+		return tracing.FactoryFunc(mvmClientFunc)
+	}
+
+	mvmClientFunc := wrapClientFunc(client.NewFlintlockClient)
+
+	// hosts whose identity is enforced are connected to with a client which
+	// checks their certificate on every handshake
+	pinnedClientFunc := func(pin identity.ClientConfig) client.FactoryFunc {
+		return wrapClientFunc(identity.PinnedFactoryFunc(pin))
+	}
+
+	// tags are resolved anonymously over https, as flintlock pulls the images
+	imageResolver := oci.NewResolver()
+
 	if err := (&controllers.MicrovmReconciler{
-		Client:        mgr.GetClient(),
-		Scheme:        mgr.GetScheme(),
-		MvmClientFunc: client.NewFlintlockClient,
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		MvmClientFunc:      mvmClientFunc,
+		PinnedClientFunc:   pinnedClientFunc,
+		ShutdownClient:     shutdown.NewAgentClient(),
+		HealthRecorder:     healthRecorder,
+		Prober:             probe.NewGuestProber(),
+		SSHServices:        featuregates.Gates.Enabled(featuregates.SSHService),
+		GuestAgent:         guestagent.NewAgentClient(),
+		PhoneHomeURL:       phoneHomeBaseURL,
+		PendingDeleteGrace: cfg.Deletion.PendingGrace.Duration,
+		StuckDeleteTimeout: cfg.Deletion.StuckTimeout.Duration,
+		ForceDeleteStuck:   cfg.Deletion.ForceStuck,
+		InPlaceUpdates:     featuregates.Gates.Enabled(featuregates.InPlaceUpdates),
+		ExternalResources:  externalResources,
+		ImageResolver:      imageResolver,
+		DryRun:             cfg.DryRun,
+		Recorder:           mgr.GetEventRecorderFor("microvm-controller"),
+		Config:             configStore,
+
+		MaxConcurrentReconciles: cfg.Controllers.Microvm.MaxConcurrentReconciles,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Microvm")
 		os.Exit(1)
 	}
 	if err = (&controllers.MicrovmReplicaSetReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Config:                  configStore,
+		MaxConcurrentReconciles: cfg.Controllers.MicrovmReplicaSet.MaxConcurrentReconciles,
+		MachinePools:            featuregates.Gates.Enabled(featuregates.MachinePool),
+		Failover:                featuregates.Gates.Enabled(featuregates.Failover),
+		ImageResolver:           imageResolver,
+		DryRun:                  cfg.DryRun,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MicrovmReplicaSet")
 		os.Exit(1)
 	}
 	if err = (&controllers.MicrovmDeploymentReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Config:                  configStore,
+		MaxConcurrentReconciles: cfg.Controllers.MicrovmDeployment.MaxConcurrentReconciles,
+		DryRun:                  cfg.DryRun,
+		Heartbeats:              heartbeats,
+		Failover:                featuregates.Gates.Enabled(featuregates.Failover),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MicrovmDeployment")
+		os.Exit(1)
+	}
+	// the autoscaler changes the replicas of deployments, which a dry run
+	// would only record
+	if !cfg.DryRun {
+		if err = (&controllers.MicrovmAutoscalerReconciler{
+			Client:     mgr.GetClient(),
+			Scheme:     mgr.GetScheme(),
+			SourceFunc: autoscaler.NewPrometheusSource,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "MicrovmAutoscaler")
+			os.Exit(1)
+		}
+	}
+	if err = (&controllers.MicrovmHostReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       healthRecorder,
+		IdentityProber: identity.NewProber(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MicrovmHost")
+		os.Exit(1)
+	}
+	if err = (&controllers.MicrovmHostGroupReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "MicrovmDeployment")
+		setupLog.Error(err, "unable to create controller", "controller", "MicrovmHostGroup")
 		os.Exit(1)
 	}
+	if err = (&controllers.MicrovmMACPoolReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MicrovmMACPool")
+		os.Exit(1)
+	}
+	if err = (&controllers.MicrovmIPPoolReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MicrovmIPPool")
+		os.Exit(1)
+	}
+	if err = (&controllers.MicrovmTemplateReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		FetcherFunc: oci.NewFetcher,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MicrovmTemplate")
+		os.Exit(1)
+	}
+	if err = (&controllers.MicrovmSnapshotReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MicrovmSnapshot")
+		os.Exit(1)
+	}
+	if err = (&controllers.MicrovmQuotaReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MicrovmQuota")
+		os.Exit(1)
+	}
+	if featuregates.Gates.Enabled(featuregates.ExternalResourceGC) && !cfg.DryRun {
+		if err = (&controllers.ExternalResourceGCReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ExternalResourceGC")
+			os.Exit(1)
+		}
+	}
+	if featuregates.Gates.Enabled(featuregates.OrphanedMicrovmGC) && !cfg.DryRun {
+		if err = (&controllers.OrphanedMicrovmGCReconciler{
+			Client:        mgr.GetClient(),
+			Scheme:        mgr.GetScheme(),
+			MvmClientFunc: mvmClientFunc,
+			Config:        configStore,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "OrphanedMicrovmGC")
+			os.Exit(1)
+		}
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&infrastructurev1alpha1.Microvm{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Microvm")
+			os.Exit(1)
+		}
+		if err = (&quota.Validator{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MicrovmQuota")
+			os.Exit(1)
+		}
+		if err = (&cloudinit.Validator{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MicrovmUserData")
+			os.Exit(1)
+		}
+		if err = (&kernelargs.Validator{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MicrovmKernelArgs")
+			os.Exit(1)
+		}
+		if err = (&drain.Validator{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MicrovmDeployment")
+			os.Exit(1)
+		}
+		if err = (&credentials.Defaulter{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MicrovmCredentials")
+			os.Exit(1)
+		}
+		if err = (&vmclass.Defaulter{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MicrovmClass")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -123,10 +560,71 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("cache-sync", readiness.CacheSynced(mgr.GetCache())); err != nil {
+		setupLog.Error(err, "unable to set up cache sync check")
+		os.Exit(1)
+	}
+	if cfg.Health.CanaryHost != "" {
+		if err := mgr.AddReadyzCheck("canary-host", readiness.HostReachable(identity.NewProber(), cfg.Health.CanaryHost)); err != nil {
+			setupLog.Error(err, "unable to set up canary host check")
+			os.Exit(1)
+		}
+	}
+
+	if configFile != "" {
+		if err := mgr.Add(&config.Watcher{
+			Path:   configFile,
+			Base:   flagConfig,
+			Store:  configStore,
+			Logger: ctrl.Log.WithName("config"),
+		}); err != nil {
+			setupLog.Error(err, "unable to set up operator configuration watcher")
+			os.Exit(1)
+		}
+	}
+
+	if phoneHomeBaseURL != "" {
+		if err := mgr.Add(&phonehome.Server{
+			Client:      mgr.GetClient(),
+			BindAddress: cfg.PhoneHome.BindAddress,
+			CertDir:     cfg.PhoneHome.CertDir,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up phone home server")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctrl.SetupSignalHandler())
+
+	// flush the spans of the last reconciles before exiting
+	if err := shutdownTracing(context.Background()); err != nil {
+		setupLog.Error(err, "unable to flush traces")
+	}
+
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// checkCRDs verifies the installed CRDs against the types compiled into the
+// binary, returning an error only if they are incompatible and mode enforces it.
+func checkCRDs(mgr ctrl.Manager, mode crdcheck.Mode) error {
+	switch mode {
+	case crdcheck.Disabled:
+		return nil
+	case crdcheck.Enforce, crdcheck.Warn:
+	default:
+		return fmt.Errorf("unknown --crd-check mode %q", mode)
+	}
+
+	err := crdcheck.Verify(context.Background(), mgr.GetAPIReader(), mgr.GetScheme(), infrastructurev1alpha1.GroupVersion.Group)
+	if err == nil || mode == crdcheck.Enforce {
+		return err
+	}
+
+	setupLog.Error(err, "continuing with incompatible CRDs, fields may be dropped")
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build e2e
+
+package e2e_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+func TestMicrovmReplicaSet_AdoptsOrphans(t *testing.T) {
+	g := NewWithT(t)
+
+	ns := createNamespace(t)
+	host := hosts[0]
+
+	newReplicaSet := func(name string, labels map[string]string) *infrav1.MicrovmReplicaSet {
+		mvmRS := &infrav1.MicrovmReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: labels},
+			Spec: infrav1.MicrovmReplicaSetSpec{
+				Host:     microvm.Host{Endpoint: host.Address()},
+				Replicas: pointer.Int32(2),
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				Template: infrav1.MicrovmTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+					Spec:       newMicrovmSpec(),
+				},
+			},
+		}
+		g.Expect(k8sClient.Create(context.TODO(), mvmRS)).To(Succeed())
+
+		return mvmRS
+	}
+
+	controlledBy := func(mvmRS *infrav1.MicrovmReplicaSet) func(Gomega) {
+		return func(g Gomega) {
+			mvms := &infrav1.MicrovmList{}
+			g.Expect(k8sClient.List(context.TODO(), mvms, client.InNamespace(ns))).To(Succeed())
+			g.Expect(mvms.Items).To(HaveLen(2))
+
+			for _, mvm := range mvms.Items {
+				g.Expect(metav1.IsControlledBy(&mvm, mvmRS)).To(BeTrue(), "Expected %s to be controlled by %s", mvm.Name, mvmRS.Name)
+			}
+		}
+	}
+
+	// the first replicaset was made by a deployment, and leaves its microvms
+	// behind when it is deleted
+	first := newReplicaSet("rs1", map[string]string{infrav1.MicrovmDeploymentNameLabel: "md1"})
+
+	g.Eventually(func(g Gomega) {
+		g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(first), first)).To(Succeed())
+		g.Expect(first.Status.ReadyReplicas).To(Equal(int32(2)))
+	}, timeout).Should(Succeed(), "Expected the replicas to have been created")
+
+	base := first.DeepCopy()
+	first.Spec.DeletePolicy = infrav1.DeletePolicyOrphan
+	g.Expect(k8sClient.Patch(context.TODO(), first, client.MergeFrom(base))).To(Succeed())
+
+	g.Expect(k8sClient.Delete(context.TODO(), first)).To(Succeed())
+	expectGone(g, first)
+
+	mvms := &infrav1.MicrovmList{}
+	g.Expect(k8sClient.List(context.TODO(), mvms, client.InNamespace(ns))).To(Succeed())
+	g.Expect(mvms.Items).To(HaveLen(2), "Expected the microvms to outlive their replicaset")
+
+	for _, mvm := range mvms.Items {
+		g.Expect(metav1.GetControllerOf(&mvm)).To(BeNil())
+		g.Expect(mvm.Labels).NotTo(HaveKey(infrav1.MicrovmDeploymentNameLabel), "Expected the orphan to be left unclaimed")
+	}
+
+	// a replicaset which was not made by the same deployment takes them over
+	// rather than creating replicas of its own
+	second := newReplicaSet("rs2", nil)
+
+	g.Eventually(controlledBy(second), timeout).Should(Succeed(), "Expected the orphans to have been adopted")
+	g.Consistently(func() int {
+		return hostMicrovms(host, ns)
+	}, 20*requeuePeriod).Should(Equal(2), "Expected no replicas to be created alongside the adopted ones")
+
+	g.Expect(k8sClient.Delete(context.TODO(), second)).To(Succeed())
+	expectGone(g, second)
+	g.Eventually(func() int {
+		return hostMicrovms(host, ns)
+	}, timeout).Should(BeZero(), "Expected the adopted microvms to be deleted with their replicaset")
+}
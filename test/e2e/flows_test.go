@@ -0,0 +1,282 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build e2e
+
+package e2e_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/testing/fakeflintlock"
+)
+
+func newMicrovmSpec() infrav1.MicrovmSpec {
+	return infrav1.MicrovmSpec{
+		VMSpec: microvm.VMSpec{
+			VCPU:     2,
+			MemoryMb: 2048,
+			RootVolume: microvm.Volume{
+				Image: "docker.io/richardcase/ubuntu-bionic-test:cloudimage_v0.0.1",
+			},
+			Kernel: microvm.ContainerFileSource{
+				Image:    "docker.io/richardcase/ubuntu-bionic-kernel:0.0.11",
+				Filename: "vmlinuz",
+			},
+			NetworkInterfaces: []microvm.NetworkInterface{
+				{GuestDeviceName: "eth0", Type: microvm.IfaceTypeMacvtap},
+			},
+		},
+	}
+}
+
+// expectGone waits for obj to have been deleted from the apiserver.
+func expectGone(g *WithT, obj client.Object) {
+	g.Eventually(func() bool {
+		err := k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj)
+
+		return apierrors.IsNotFound(err)
+	}, timeout).Should(BeTrue(), "Expected %s to have been deleted", obj.GetName())
+}
+
+// scale sets the replicas of obj with a patch, which the controllers cannot
+// make conflict by updating it in the meantime.
+func scale(g *WithT, obj client.Object, replicas **int32, count int32) {
+	base := obj.DeepCopyObject().(client.Object)
+	*replicas = pointer.Int32(count)
+
+	g.Expect(k8sClient.Patch(context.TODO(), obj, client.MergeFrom(base))).To(Succeed())
+}
+
+func TestMicrovm_CreateAndDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	ns := createNamespace(t)
+	host := hosts[0]
+
+	mvm := &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: ns},
+		Spec:       newMicrovmSpec(),
+	}
+	mvm.Spec.Host = microvm.Host{Endpoint: host.Address()}
+	g.Expect(k8sClient.Create(context.TODO(), mvm)).To(Succeed())
+
+	g.Eventually(func(g Gomega) {
+		g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(mvm), mvm)).To(Succeed())
+		g.Expect(mvm.Status.Ready).To(BeTrue())
+		g.Expect(mvm.Status.Phase).To(Equal(infrav1.PhaseRunning))
+	}, timeout).Should(Succeed(), "Expected the microvm to become ready")
+
+	g.Expect(mvm.Spec.ProviderID).NotTo(BeNil())
+	g.Expect(hostMicrovms(host, ns)).To(Equal(1), "Expected the microvm to have been created on its host")
+
+	g.Expect(k8sClient.Delete(context.TODO(), mvm)).To(Succeed())
+	expectGone(g, mvm)
+	g.Expect(hostMicrovms(host, ns)).To(BeZero(), "Expected the microvm to have been deleted from its host")
+}
+
+func TestMicrovm_RecoversFromHostErrors(t *testing.T) {
+	g := NewWithT(t)
+
+	ns := createNamespace(t)
+	host := hosts[0]
+
+	host.SetError(fakeflintlock.CreateMicroVM, status.Error(codes.Unavailable, "host is restarting"))
+	defer host.SetError(fakeflintlock.CreateMicroVM, nil)
+
+	calls := host.Calls(fakeflintlock.CreateMicroVM)
+
+	mvm := &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: ns},
+		Spec:       newMicrovmSpec(),
+	}
+	mvm.Spec.Host = microvm.Host{Endpoint: host.Address()}
+	g.Expect(k8sClient.Create(context.TODO(), mvm)).To(Succeed())
+
+	g.Eventually(func() int {
+		return host.Calls(fakeflintlock.CreateMicroVM) - calls
+	}, timeout).Should(BeNumerically(">", 1), "Expected the failed create to have been retried")
+
+	g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(mvm), mvm)).To(Succeed())
+	g.Expect(mvm.Status.Ready).To(BeFalse())
+
+	host.SetError(fakeflintlock.CreateMicroVM, nil)
+
+	g.Eventually(func(g Gomega) {
+		g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(mvm), mvm)).To(Succeed())
+		g.Expect(mvm.Status.Ready).To(BeTrue())
+	}, timeout).Should(Succeed(), "Expected the microvm to become ready once the host recovered")
+
+	g.Expect(hostMicrovms(host, ns)).To(Equal(1), "Expected only one microvm to have been created")
+
+	g.Expect(k8sClient.Delete(context.TODO(), mvm)).To(Succeed())
+	expectGone(g, mvm)
+}
+
+func TestMicrovmReplicaSet_CreateScaleDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	ns := createNamespace(t)
+	host := hosts[0]
+
+	mvmRS := &infrav1.MicrovmReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rs1", Namespace: ns},
+		Spec: infrav1.MicrovmReplicaSetSpec{
+			Host:     microvm.Host{Endpoint: host.Address()},
+			Replicas: pointer.Int32(3),
+			Template: infrav1.MicrovmTemplateSpec{Spec: newMicrovmSpec()},
+		},
+	}
+	g.Expect(k8sClient.Create(context.TODO(), mvmRS)).To(Succeed())
+
+	expectReplicaSet := func(replicas int32, msg string) {
+		g.Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(mvmRS), mvmRS)).To(Succeed())
+			g.Expect(mvmRS.Status.Ready).To(BeTrue())
+			g.Expect(mvmRS.Status.Replicas).To(Equal(replicas))
+			g.Expect(mvmRS.Status.ReadyReplicas).To(Equal(replicas))
+			g.Expect(hostMicrovms(host, ns)).To(Equal(int(replicas)))
+		}, timeout).Should(Succeed(), msg)
+	}
+
+	expectReplicaSet(3, "Expected every replica to have been created")
+
+	scale(g, mvmRS, &mvmRS.Spec.Replicas, 1)
+
+	expectReplicaSet(1, "Expected the replicaset to have been scaled down")
+
+	scale(g, mvmRS, &mvmRS.Spec.Replicas, 2)
+
+	expectReplicaSet(2, "Expected the replicaset to have been scaled up")
+
+	g.Expect(k8sClient.Delete(context.TODO(), mvmRS)).To(Succeed())
+	expectGone(g, mvmRS)
+	g.Eventually(func() int {
+		return hostMicrovms(host, ns)
+	}, timeout).Should(BeZero(), "Expected every replica to have been deleted from the host")
+}
+
+func TestMicrovmDeployment_CreateScaleDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	ns := createNamespace(t)
+
+	mvmD := &infrav1.MicrovmDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "md1", Namespace: ns},
+		Spec: infrav1.MicrovmDeploymentSpec{
+			Replicas: pointer.Int32(2),
+			Template: infrav1.MicrovmTemplateSpec{Spec: newMicrovmSpec()},
+		},
+	}
+
+	for _, host := range hosts {
+		mvmD.Spec.Hosts = append(mvmD.Spec.Hosts, microvm.Host{Endpoint: host.Address()})
+	}
+
+	g.Expect(k8sClient.Create(context.TODO(), mvmD)).To(Succeed())
+
+	expectDeployment := func(perHost int32, msg string) {
+		g.Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(mvmD), mvmD)).To(Succeed())
+			g.Expect(mvmD.Status.Ready).To(BeTrue())
+			g.Expect(mvmD.Status.ReadyReplicas).To(Equal(perHost * int32(len(hosts))))
+
+			for _, host := range hosts {
+				g.Expect(hostMicrovms(host, ns)).To(Equal(int(perHost)))
+			}
+		}, timeout).Should(Succeed(), msg)
+	}
+
+	expectDeployment(2, "Expected the replicas to have been created on every host")
+
+	sets := &infrav1.MicrovmReplicaSetList{}
+	g.Expect(k8sClient.List(context.TODO(), sets, client.InNamespace(ns))).To(Succeed())
+	g.Expect(sets.Items).To(HaveLen(len(hosts)), "Expected a replicaset for each host")
+
+	scale(g, mvmD, &mvmD.Spec.Replicas, 1)
+
+	expectDeployment(1, "Expected the deployment to have been scaled down on every host")
+
+	g.Expect(k8sClient.Delete(context.TODO(), mvmD)).To(Succeed())
+	expectGone(g, mvmD)
+
+	for _, host := range hosts {
+		g.Eventually(func() int {
+			return hostMicrovms(host, ns)
+		}, timeout).Should(BeZero(), "Expected every replica to have been deleted from %s", host.Address())
+	}
+}
+
+func TestMicrovmDeployment_SharedNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	ns := createNamespace(t)
+
+	newDeployment := func(name string) *infrav1.MicrovmDeployment {
+		mvmD := &infrav1.MicrovmDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Spec: infrav1.MicrovmDeploymentSpec{
+				Replicas: pointer.Int32(1),
+				Template: infrav1.MicrovmTemplateSpec{Spec: newMicrovmSpec()},
+			},
+		}
+
+		for _, host := range hosts {
+			mvmD.Spec.Hosts = append(mvmD.Spec.Hosts, microvm.Host{Endpoint: host.Address()})
+		}
+
+		g.Expect(k8sClient.Create(context.TODO(), mvmD)).To(Succeed())
+
+		return mvmD
+	}
+
+	expectReady := func(mvmD *infrav1.MicrovmDeployment) {
+		g.Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(mvmD), mvmD)).To(Succeed())
+			g.Expect(mvmD.Status.Ready).To(BeTrue())
+			g.Expect(mvmD.Status.ReadyReplicas).To(Equal(int32(len(hosts))))
+		}, timeout).Should(Succeed(), "Expected %s to count only its own replicas", mvmD.Name)
+	}
+
+	first := newDeployment("md1")
+	second := newDeployment("md2")
+
+	expectReady(first)
+	expectReady(second)
+
+	for _, host := range hosts {
+		g.Eventually(func() int {
+			return hostMicrovms(host, ns)
+		}, timeout).Should(Equal(2), "Expected a replica of each deployment on %s", host.Address())
+	}
+
+	g.Expect(k8sClient.Delete(context.TODO(), second)).To(Succeed())
+	expectGone(g, second)
+
+	for _, host := range hosts {
+		g.Eventually(func() int {
+			return hostMicrovms(host, ns)
+		}, timeout).Should(Equal(1), "Expected only the deleted deployment's replica to be removed from %s", host.Address())
+	}
+
+	expectReady(first)
+
+	sets := &infrav1.MicrovmReplicaSetList{}
+	g.Expect(k8sClient.List(context.TODO(), sets, client.InNamespace(ns))).To(Succeed())
+	g.Expect(sets.Items).To(HaveLen(len(hosts)), "Expected the remaining deployment to keep its replicasets")
+
+	for i := range sets.Items {
+		g.Expect(metav1.IsControlledBy(&sets.Items[i], first)).To(BeTrue())
+	}
+}
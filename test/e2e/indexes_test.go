@@ -0,0 +1,134 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build e2e
+
+package e2e_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/weaveworks-liquidmetal/controller-pkg/types/microvm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+)
+
+// The field indexes the controllers register with the manager, which are only
+// looked up once they have been.
+const (
+	controllerUIDField = ".metadata.controllerUID"
+	hostEndpointField  = ".spec.host.endpoint"
+)
+
+func TestIndexes_ControllerUID(t *testing.T) {
+	g := NewWithT(t)
+
+	ns := createNamespace(t)
+
+	mvmD := &infrav1.MicrovmDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "md1", Namespace: ns},
+		Spec: infrav1.MicrovmDeploymentSpec{
+			Replicas: pointer.Int32(2),
+			Template: infrav1.MicrovmTemplateSpec{Spec: newMicrovmSpec()},
+		},
+	}
+
+	for _, host := range hosts {
+		mvmD.Spec.Hosts = append(mvmD.Spec.Hosts, microvm.Host{Endpoint: host.Address()})
+	}
+
+	g.Expect(k8sClient.Create(context.TODO(), mvmD)).To(Succeed())
+
+	g.Eventually(func(g Gomega) {
+		g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(mvmD), mvmD)).To(Succeed())
+		g.Expect(mvmD.Status.Ready).To(BeTrue())
+		g.Expect(mvmD.Status.ReadyReplicas).To(Equal(int32(2 * len(hosts))))
+	}, timeout).Should(Succeed(), "Expected the deployment to become ready")
+
+	// a lookup which found nothing would have the controllers create more
+	// replicasets and replicas than asked for
+	g.Consistently(func(g Gomega) {
+		for _, host := range hosts {
+			g.Expect(hostMicrovms(host, ns)).To(Equal(2))
+		}
+	}, 20*requeuePeriod).Should(Succeed(), "Expected the controllers to find what they already own")
+
+	sets := &infrav1.MicrovmReplicaSetList{}
+	g.Expect(cachedClient.List(context.TODO(), sets, client.InNamespace(ns),
+		client.MatchingFields{controllerUIDField: string(mvmD.UID)})).To(Succeed())
+	g.Expect(sets.Items).To(HaveLen(len(hosts)), "Expected the replicasets to be indexed by their deployment")
+
+	for _, host := range hosts {
+		onHost := &infrav1.MicrovmReplicaSetList{}
+		g.Expect(cachedClient.List(context.TODO(), onHost, client.InNamespace(ns),
+			client.MatchingFields{hostEndpointField: host.Address()})).To(Succeed())
+		g.Expect(onHost.Items).To(HaveLen(1), "Expected the replicasets to be indexed by their host")
+	}
+
+	for i := range sets.Items {
+		mvms := &infrav1.MicrovmList{}
+		g.Expect(cachedClient.List(context.TODO(), mvms, client.InNamespace(ns),
+			client.MatchingFields{controllerUIDField: string(sets.Items[i].UID)})).To(Succeed())
+		g.Expect(mvms.Items).To(HaveLen(2), "Expected the microvms to be indexed by their replicaset")
+	}
+
+	g.Expect(k8sClient.Delete(context.TODO(), mvmD)).To(Succeed())
+	expectGone(g, mvmD)
+}
+
+func TestIndexes_HostEndpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	ns := createNamespace(t)
+
+	// nothing listens on the endpoint, which the microvm must never be sent to
+	endpoint := "127.0.0.1:1"
+
+	mvmH := &infrav1.MicrovmHost{
+		ObjectMeta: metav1.ObjectMeta{Name: ns},
+		Spec:       infrav1.MicrovmHostSpec{Endpoint: endpoint},
+	}
+	g.Expect(k8sClient.Create(context.TODO(), mvmH)).To(Succeed())
+
+	defer func() {
+		g.Expect(k8sClient.Delete(context.TODO(), mvmH)).To(Succeed())
+	}()
+
+	mvmH.Status.Untrusted = true
+	g.Expect(k8sClient.Status().Update(context.TODO(), mvmH)).To(Succeed())
+
+	mvm := &infrav1.Microvm{
+		ObjectMeta: metav1.ObjectMeta{Name: "mvm1", Namespace: ns},
+		Spec:       newMicrovmSpec(),
+	}
+	mvm.Spec.Host = microvm.Host{Endpoint: endpoint}
+	g.Expect(k8sClient.Create(context.TODO(), mvm)).To(Succeed())
+
+	g.Eventually(func(g Gomega) {
+		g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(mvm), mvm)).To(Succeed())
+		g.Expect(conditions.GetReason(mvm, infrav1.MicrovmReadyCondition)).To(Equal(infrav1.MicrovmHostUntrustedReason))
+	}, timeout).Should(Succeed(), "Expected the host of the microvm to be found by its endpoint")
+
+	mvms := &infrav1.MicrovmList{}
+	g.Expect(cachedClient.List(context.TODO(), mvms, client.InNamespace(ns),
+		client.MatchingFields{hostEndpointField: endpoint})).To(Succeed())
+	g.Expect(mvms.Items).To(HaveLen(1), "Expected the microvm to be indexed by its host")
+
+	mvmHosts := &infrav1.MicrovmHostList{}
+	g.Expect(cachedClient.List(context.TODO(), mvmHosts, client.MatchingFields{hostEndpointField: endpoint})).To(Succeed())
+	g.Expect(mvmHosts.Items).To(HaveLen(1), "Expected the microvmhost to be indexed by its endpoint")
+
+	// the host is never trusted again, so the microvm is let go of without it
+	base := mvm.DeepCopy()
+	mvm.Annotations = map[string]string{infrav1.ForceDeleteAnnotation: "true"}
+	g.Expect(k8sClient.Patch(context.TODO(), mvm, client.MergeFrom(base))).To(Succeed())
+
+	g.Expect(k8sClient.Delete(context.TODO(), mvm)).To(Succeed())
+	expectGone(g, mvm)
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 Weaveworks or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build e2e
+
+// Package e2e_test runs the Microvm, MicrovmReplicaSet and MicrovmDeployment
+// controllers in a manager against an envtest apiserver, with fake flintlock
+// hosts served over gRPC, and drives them through whole create, scale and
+// delete flows. Run it with make test-e2e.
+package e2e_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	flclient "github.com/weaveworks-liquidmetal/controller-pkg/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	configv1 "github.com/weaveworks-liquidmetal/microvm-operator/api/config/v1alpha1"
+	infrav1 "github.com/weaveworks-liquidmetal/microvm-operator/api/v1alpha1"
+	"github.com/weaveworks-liquidmetal/microvm-operator/controllers"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/config"
+	"github.com/weaveworks-liquidmetal/microvm-operator/internal/testing/fakeflintlock"
+)
+
+const (
+	// requeuePeriod is short so that the flows converge in seconds.
+	requeuePeriod = 100 * time.Millisecond
+	// timeout is how long each step of a flow is given to converge.
+	timeout = 30 * time.Second
+	// hostCount is how many fake flintlock hosts are served.
+	hostCount = 2
+)
+
+var (
+	k8sClient client.Client
+	// cachedClient reads through the cache of the manager, as the
+	// controllers do, so that the field indexes they look up can be queried.
+	cachedClient client.Client
+	hosts        []*fakeflintlock.Server
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		fmt.Println("skipping e2e tests: KUBEBUILDER_ASSETS is not set, run them with make test-e2e")
+
+		return 0
+	}
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+	}
+
+	cfg, err := testEnv.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "starting envtest: %s\n", err)
+
+		return 1
+	}
+
+	defer func() { _ = testEnv.Stop() }()
+
+	for i := 0; i < hostCount; i++ {
+		host, err := fakeflintlock.Start()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "starting fake flintlock: %s\n", err)
+
+			return 1
+		}
+
+		defer host.Stop()
+
+		hosts = append(hosts, host)
+	}
+
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(infrav1.AddToScheme(scheme))
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:                 scheme,
+		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: "0",
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "creating manager: %s\n", err)
+
+		return 1
+	}
+
+	if err := setupReconcilers(mgr); err != nil {
+		fmt.Fprintf(os.Stderr, "setting up reconcilers: %s\n", err)
+
+		return 1
+	}
+
+	cachedClient = mgr.GetClient()
+
+	// the tests read through a client of their own, so that they see what has
+	// been persisted rather than what the manager has cached
+	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "creating client: %s\n", err)
+
+		return 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		if err := mgr.Start(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "running manager: %s\n", err)
+		}
+	}()
+
+	code := m.Run()
+
+	cancel()
+	<-stopped
+
+	return code
+}
+
+func setupReconcilers(mgr ctrl.Manager) error {
+	store := config.NewStore(&configv1.OperatorConfiguration{
+		Controllers: configv1.ControllersConfiguration{
+			Microvm:           configv1.ControllerConfiguration{RequeuePeriod: metav1.Duration{Duration: requeuePeriod}},
+			MicrovmReplicaSet: configv1.ControllerConfiguration{RequeuePeriod: metav1.Duration{Duration: requeuePeriod}},
+			MicrovmDeployment: configv1.ControllerConfiguration{RequeuePeriod: metav1.Duration{Duration: requeuePeriod}},
+		},
+	})
+
+	if err := (&controllers.MicrovmReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		MvmClientFunc:  flclient.NewFlintlockClient,
+		Config:         store,
+		InPlaceUpdates: true,
+	}).SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("microvm: %w", err)
+	}
+
+	if err := (&controllers.MicrovmReplicaSetReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Config:   store,
+		Failover: true,
+	}).SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("microvmreplicaset: %w", err)
+	}
+
+	if err := (&controllers.MicrovmDeploymentReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Config:   store,
+		Failover: true,
+	}).SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("microvmdeployment: %w", err)
+	}
+
+	return nil
+}
+
+// createNamespace creates a namespace of its own for a test, so that the
+// microvms on the shared fake hosts can be told apart.
+func createNamespace(t *testing.T) string {
+	t.Helper()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "e2e-"}}
+	if err := k8sClient.Create(context.TODO(), ns); err != nil {
+		t.Fatalf("creating namespace: %s", err)
+	}
+
+	return ns.Name
+}
+
+// hostMicrovms returns how many microvms of the namespace the host holds,
+// including those being deleted.
+func hostMicrovms(host *fakeflintlock.Server, namespace string) int {
+	count := 0
+
+	for _, vm := range host.MicroVMs() {
+		if vm.Spec.Namespace == namespace {
+			count++
+		}
+	}
+
+	return count
+}